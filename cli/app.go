@@ -16,9 +16,12 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/passwordpersist"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/faultinject"
+	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/snapshot/snapshotmaintenance"
@@ -114,11 +117,23 @@ type App struct {
 	updateCheckInterval           time.Duration
 	updateAvailableNotifyInterval time.Duration
 	password                      string
+	passwordSecret                string
 	configPath                    string
+	connectionProfile             string
 	traceStorage                  bool
+	traceStorageRecords           bool
+	traceStorageSampleEvery       int
+	traceStorageSlowThreshold     time.Duration
+	indexFetchParallelism         int
 	metricsListenAddr             string
+	metricsPush                   metricsPusher
+	crashTrace                    crashTracer
+	profiling                     profileFlags
 	keyRingEnabled                bool
 	persistCredentials            bool
+	maxBufferMemoryMB             int64
+	faultInjectionConfigFile      string
+	faultInjection                *faultinject.Options
 	AdvancedCommands              string
 
 	// subcommands
@@ -129,9 +144,11 @@ type App struct {
 	diff        commandDiff
 	index       commandIndex
 	list        commandList
+	logs        commandLogs
 	server      commandServer
 	session     commandSession
 	policy      commandPolicy
+	profile     commandProfile
 	restore     commandRestore
 	show        commandShow
 	snapshot    commandSnapshot
@@ -187,13 +204,27 @@ func (c *App) setup(app *kingpin.Application) {
 	app.Flag("initial-update-check-delay", "Initial delay before first time update check").Default("24h").Hidden().Envar("KOPIA_INITIAL_UPDATE_CHECK_DELAY").DurationVar(&c.initialUpdateCheckDelay)
 	app.Flag("update-check-interval", "Interval between update checks").Default("168h").Hidden().Envar("KOPIA_UPDATE_CHECK_INTERVAL").DurationVar(&c.updateCheckInterval)
 	app.Flag("update-available-notify-interval", "Interval between update notifications").Default("1h").Hidden().Envar("KOPIA_UPDATE_NOTIFY_INTERVAL").DurationVar(&c.updateAvailableNotifyInterval)
-	app.Flag("config-file", "Specify the config file to use.").Default(defaultConfigFileName()).Envar("KOPIA_CONFIG_PATH").StringVar(&c.configPath)
+	app.Flag("config-file", "Specify the config file to use (default is determined by the connection profile).").Envar("KOPIA_CONFIG_PATH").StringVar(&c.configPath)
+	app.Flag("profile", "Name of the connection profile to use.").Short('P').Envar("KOPIA_PROFILE").StringVar(&c.connectionProfile)
+	app.PreAction(c.resolveConfigPath)
 	app.Flag("trace-storage", "Enables tracing of storage operations.").Default("true").Hidden().BoolVar(&c.traceStorage)
+	app.Flag("trace-storage-records", "Emits structured record for storage operations.").Hidden().Envar("KOPIA_TRACE_STORAGE_RECORDS").BoolVar(&c.traceStorageRecords)
+	app.Flag("trace-storage-sample-every", "Emits record for every N-th successful storage operation.").Default("1").Hidden().IntVar(&c.traceStorageSampleEvery)
+	app.Flag("trace-storage-slow-threshold", "Always emits record for storage operations slower than the provided duration.").Hidden().DurationVar(&c.traceStorageSlowThreshold)
+	app.Flag("index-fetch-parallelism", "Maximum number of index blobs downloaded concurrently when opening the repository (0 == default)").Hidden().Envar("KOPIA_INDEX_FETCH_PARALLELISM").IntVar(&c.indexFetchParallelism)
 	app.Flag("metrics-listen-addr", "Expose Prometheus metrics on a given host:port").Hidden().StringVar(&c.metricsListenAddr)
+	c.metricsPush.setup(app)
+	c.crashTrace.setup(app)
+	c.profiling.setup(app)
 	app.Flag("timezone", "Format time according to specified time zone (local, utc, original or time zone name)").Hidden().StringVar(&timeZone)
 	app.Flag("password", "Repository password.").Envar("KOPIA_PASSWORD").Short('p').StringVar(&c.password)
+	app.Flag("password-secret", "Fetch repository password from a secret store, e.g. vault:secret/data/kopia#password, awssm:kopia#password, env:VAR or file:/path").Envar("KOPIA_PASSWORD_SECRET").StringVar(&c.passwordSecret)
 	app.Flag("persist-credentials", "Persist credentials").Default("true").Envar("KOPIA_PERSIST_CREDENTIALS_ON_CONNECT").BoolVar(&c.persistCredentials)
 	app.Flag("advanced-commands", "Enable advanced (and potentially dangerous) commands.").Hidden().Envar("KOPIA_ADVANCED_COMMANDS").StringVar(&c.AdvancedCommands)
+	app.Flag("max-buffer-memory-mb", "Maximum amount of memory used by pending uploads (0 == unlimited, otherwise at least the pack size of 20 MB), writers wait when exceeded").Envar("KOPIA_MAX_BUFFER_MEMORY_MB").Int64Var(&c.maxBufferMemoryMB)
+	app.PreAction(c.applyMemoryBudget)
+	app.Flag("fault-injection-config", "JSON file describing storage faults to inject (testing only).").Hidden().Envar("KOPIA_FAULT_INJECTION_CONFIG").StringVar(&c.faultInjectionConfigFile)
+	app.PreAction(c.loadFaultInjectionConfig)
 
 	c.setupOSSpecificKeychainFlags(app)
 
@@ -215,6 +246,7 @@ func (c *App) setup(app *kingpin.Application) {
 	c.diff.setup(c, app)
 	c.index.setup(c, app)
 	c.list.setup(c, app)
+	c.logs.setup(c, app)
 	c.server.setup(c, app)
 	c.session.setup(c, app)
 	c.restore.setup(c, app)
@@ -222,11 +254,22 @@ func (c *App) setup(app *kingpin.Application) {
 	c.snapshot.setup(c, app)
 	c.manifest.setup(c, app)
 	c.policy.setup(c, app)
+	c.profile.setup(c, app)
 	c.mount.setup(c, app)
 	c.maintenance.setup(c, app)
 	c.repository.setup(c, app)
 }
 
+func (c *App) applyMemoryBudget(_ *kingpin.ParseContext) error {
+	budget := c.maxBufferMemoryMB << 20 //nolint:gomnd
+
+	if minBudget := content.MinMemoryBudget(content.DefaultMaxPackSize); budget != 0 && budget < minBudget {
+		return errors.Errorf("--max-buffer-memory-mb must be at least %v", minBudget>>20) //nolint:gomnd
+	}
+
+	return errors.Wrap(gather.SetMemoryBudget(budget), "invalid memory budget")
+}
+
 // commandParent is implemented by app and commands that can have sub-commands.
 type commandParent interface {
 	Command(name, help string) *kingpin.CmdClause
@@ -251,26 +294,36 @@ func (c *App) Attach(app *kingpin.Application) {
 }
 
 var safetyByName = map[string]maintenance.SafetyParameters{
-	"none": maintenance.SafetyNone,
-	"full": maintenance.SafetyFull,
+	"none":                            maintenance.SafetyNone,
+	"full":                            maintenance.SafetyFull,
+	maintenance.SafetyProfileFast:     maintenance.SafetyFast,
+	maintenance.SafetyProfileDefault:  maintenance.SafetyFull,
+	maintenance.SafetyProfileParanoid: maintenance.SafetyParanoid,
 }
 
-// safetyFlagVar defines c --safety=none|full flag that sets the SafetyParameters.
-func safetyFlagVar(cmd *kingpin.CmdClause, result *maintenance.SafetyParameters) {
-	var str string
+// safetyFlag defines --safety flag that selects the SafetyParameters used by maintenance tasks.
+type safetyFlag struct {
+	name string
+}
 
-	*result = maintenance.SafetyFull
+func (f *safetyFlag) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("safety", "Safety level (none, fast, default, paranoid or full), defaults to the maintenance safety profile of the repository").
+		EnumVar(&f.name, "none", "full", maintenance.SafetyProfileFast, maintenance.SafetyProfileDefault, maintenance.SafetyProfileParanoid)
+}
 
-	cmd.Flag("safety", "Safety level").Default("full").PreAction(func(pc *kingpin.ParseContext) error {
-		r, ok := safetyByName[str]
-		if !ok {
-			return errors.Errorf("unhandled safety level")
-		}
+// parameters returns the SafetyParameters selected by the flag or configured for the repository if not specified.
+func (f *safetyFlag) parameters(ctx context.Context, rep repo.Repository) (maintenance.SafetyParameters, error) {
+	if f.name == "" {
+		// nolint:wrapcheck
+		return maintenance.GetSafetyParameters(ctx, rep)
+	}
 
-		*result = r
+	r, ok := safetyByName[f.name]
+	if !ok {
+		return maintenance.SafetyParameters{}, errors.Errorf("unhandled safety level")
+	}
 
-		return nil
-	}).EnumVar(&str, "full", "none")
+	return r, nil
 }
 
 func (c *App) noRepositoryAction(act func(ctx context.Context) error) func(ctx *kingpin.ParseContext) error {
@@ -370,7 +423,9 @@ func (c *App) maybeRepositoryAction(act func(ctx context.Context, rep repo.Repos
 	return func(kpc *kingpin.ParseContext) error {
 		ctx := c.rootContext()
 
-		if err := withProfiling(func() error {
+		defer c.crashTrace.dumpOnPanic(c.stderrWriter)
+
+		if err := c.profiling.withProfiling(commandName(kpc), func() error {
 			c.mt.startMemoryTracking(ctx)
 			defer c.mt.finishMemoryTracking(ctx)
 
@@ -403,10 +458,15 @@ func (c *App) maybeRepositoryAction(act func(ctx context.Context, rep repo.Repos
 				}
 			}
 
+			if perr := c.metricsPush.push(ctx); perr != nil {
+				log(ctx).Errorf("error pushing metrics: %v", perr)
+			}
+
 			return err
 		}); err != nil {
 			// print error in red
 			log(ctx).Errorf("ERROR: %v", err.Error())
+			c.crashTrace.dump(c.stderrWriter, "error: "+err.Error())
 			c.osExit(1)
 		}
 
@@ -414,12 +474,21 @@ func (c *App) maybeRepositoryAction(act func(ctx context.Context, rep repo.Repos
 	}
 }
 
+// commandName returns the full name of the selected command, such as 'snapshot create'.
+func commandName(kpc *kingpin.ParseContext) string {
+	if kpc == nil || kpc.SelectedCommand == nil {
+		return ""
+	}
+
+	return kpc.SelectedCommand.FullCommand()
+}
+
 func (c *App) maybeRunMaintenance(ctx context.Context, rep repo.Repository) error {
 	if !c.enableAutomaticMaintenance {
 		return nil
 	}
 
-	if rep.ClientOptions().ReadOnly {
+	if rep.ClientOptions().ReadOnly || rep.ClientOptions().DryRun {
 		return nil
 	}
 
@@ -428,12 +497,20 @@ func (c *App) maybeRunMaintenance(ctx context.Context, rep repo.Repository) erro
 		return nil
 	}
 
-	err := repo.DirectWriteSession(ctx, dr, repo.WriteSessionOptions{
-		Purpose:  "maybeRunMaintenance",
-		OnUpload: c.progress.UploadedBytes,
-	}, func(w repo.DirectRepositoryWriter) error {
+	err := c.profiling.withProfiling("maintenance", func() error {
 		// nolint:wrapcheck
-		return snapshotmaintenance.Run(ctx, w, maintenance.ModeAuto, false, maintenance.SafetyFull)
+		return repo.DirectWriteSession(ctx, dr, repo.WriteSessionOptions{
+			Purpose:  "maybeRunMaintenance",
+			OnUpload: c.progress.UploadedBytes,
+		}, func(w repo.DirectRepositoryWriter) error {
+			safety, err := maintenance.GetSafetyParameters(ctx, w)
+			if err != nil {
+				return errors.Wrap(err, "unable to get maintenance safety parameters")
+			}
+
+			// nolint:wrapcheck
+			return snapshotmaintenance.Run(ctx, w, maintenance.ModeAuto, false, safety)
+		})
 	})
 
 	var noe maintenance.NotOwnedError
@@ -443,6 +520,11 @@ func (c *App) maybeRunMaintenance(ctx context.Context, rep repo.Repository) erro
 		return nil
 	}
 
+	if errors.Is(err, repo.ErrRepositoryFrozen) {
+		// automatic maintenance is skipped while the repository is frozen.
+		return nil
+	}
+
 	return errors.Wrap(err, "error running maintenance")
 }
 
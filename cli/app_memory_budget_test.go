@@ -0,0 +1,20 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestMaxBufferMemoryFlag(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	// too small to hold pending packs at the default pack size.
+	env.RunAndExpectFailure(t, "repo", "status", "--max-buffer-memory-mb=1")
+
+	env.RunAndExpectSuccess(t, "repo", "status", "--max-buffer-memory-mb=100")
+	env.RunAndExpectSuccess(t, "snapshot", "create", "--max-buffer-memory-mb=100", env.ConfigDir)
+	env.RunAndExpectSuccess(t, "repo", "status", "--max-buffer-memory-mb=0")
+}
@@ -1,7 +1,11 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -10,6 +14,7 @@
 	"github.com/alecthomas/kingpin"
 	"github.com/fatih/color"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
@@ -17,20 +22,70 @@
 
 const (
 	spinner = `|/-\`
+
+	progressFormatText = "text"
+	progressFormatJSON = "json"
 )
 
 type progressFlags struct {
 	enableProgress         bool
 	progressUpdateInterval time.Duration
+	progressFormat         string
+	progressFD             int
 	out                    textOutput
+
+	jsonWriter io.Writer
 }
 
 func (p *progressFlags) setup(svc appServices, app *kingpin.Application) {
 	app.Flag("progress", "Enable progress bar").Hidden().Default("true").BoolVar(&p.enableProgress)
 	app.Flag("progress-update-interval", "How ofter to update progress information").Hidden().Default("300ms").DurationVar(&p.progressUpdateInterval)
+	app.Flag("progress-format", "Progress format, 'json' emits one progress record per line").Default(progressFormatText).EnumVar(&p.progressFormat, progressFormatText, progressFormatJSON)
+	app.Flag("progress-fd", "File descriptor to write JSON progress records to (defaults to stderr)").IntVar(&p.progressFD)
 	p.out.setup(svc)
 }
 
+// progressJSONWriter returns the writer for JSON progress records.
+func (p *progressFlags) progressJSONWriter() io.Writer {
+	if p.jsonWriter == nil {
+		if p.progressFD > 0 {
+			p.jsonWriter = os.NewFile(uintptr(p.progressFD), "progress")
+		} else {
+			p.jsonWriter = p.out.stderr()
+		}
+	}
+
+	return p.jsonWriter
+}
+
+// progressRecord is a single progress record emitted with --progress-format=json.
+type progressRecord struct {
+	Time     time.Time `json:"time"`
+	Finished bool      `json:"finished,omitempty"`
+
+	HashingFiles  int32 `json:"hashingFiles"`
+	HashedFiles   int32 `json:"hashedFiles"`
+	HashedBytes   int64 `json:"hashedBytes"`
+	CachedFiles   int32 `json:"cachedFiles"`
+	CachedBytes   int64 `json:"cachedBytes"`
+	UploadedBytes int64 `json:"uploadedBytes"`
+
+	IgnoredErrors int32 `json:"ignoredErrors,omitempty"`
+	FatalErrors   int32 `json:"fatalErrors,omitempty"`
+
+	EstimatedFiles          int              `json:"estimatedFiles,omitempty"`
+	EstimatedBytes          int64            `json:"estimatedBytes,omitempty"`
+	PercentComplete         float64          `json:"percentComplete,omitempty"`
+	ETA                     *time.Time       `json:"eta,omitempty"`
+	RemainingTime           string           `json:"remainingTime,omitempty"`
+	RemainingUploadBytes    int64            `json:"remainingUploadBytes,omitempty"`
+	ProcessedBytesPerSecond float64          `json:"processedBytesPerSecond,omitempty"`
+	UploadedBytesPerSecond  float64          `json:"uploadedBytesPerSecond,omitempty"`
+	Streams                 map[string]int64 `json:"streams,omitempty"`
+
+	Message string `json:"message,omitempty"`
+}
+
 type cliProgress struct {
 	snapshotfs.NullUploadProgress
 
@@ -50,13 +105,18 @@ type cliProgress struct {
 	uploading      int32
 	uploadFinished int32
 
-	lastLineLength  int
-	spinPhase       int
-	uploadStartTime timetrack.Estimator
+	lastLineLength int
+	spinPhase      int
+
+	// smoothed transfer rates and completion estimate, protected by outputMutex.
+	throughput snapshotfs.UploadThroughput
 
 	estimatedFileCount  int
 	estimatedTotalBytes int64
 
+	// number of bytes read so far from each streaming file, protected by outputMutex.
+	streamedBytes map[string]int64
+
 	// indicates shared instance that does not reset counters at the beginning of upload.
 	shared bool
 
@@ -87,6 +147,20 @@ func (p *cliProgress) HashedBytes(numBytes int64) {
 	p.maybeOutput()
 }
 
+func (p *cliProgress) StreamedBytes(fname string, totalBytes int64) {
+	p.outputMutex.Lock()
+
+	if p.streamedBytes == nil {
+		p.streamedBytes = map[string]int64{}
+	}
+
+	p.streamedBytes[fname] = totalBytes
+
+	p.outputMutex.Unlock()
+
+	p.maybeOutput()
+}
+
 func (p *cliProgress) Error(path string, err error, isIgnored bool) {
 	if isIgnored {
 		atomic.AddInt32(&p.ignoredErrorCount, 1)
@@ -117,6 +191,11 @@ func (p *cliProgress) output(col *color.Color, msg string) {
 	p.outputMutex.Lock()
 	defer p.outputMutex.Unlock()
 
+	if p.progressFormat == progressFormatJSON {
+		p.outputJSON(msg)
+		return
+	}
+
 	hashedBytes := atomic.LoadInt64(&p.hashedBytes)
 	cachedBytes := atomic.LoadInt64(&p.cachedBytes)
 	uploadedBytes := atomic.LoadInt64(&p.uploadedBytes)
@@ -141,6 +220,10 @@ func (p *cliProgress) output(col *color.Color, msg string) {
 		units.BytesStringBase10(uploadedBytes),
 	)
 
+	if len(p.streamedBytes) > 0 {
+		line += ", streams: " + p.streamSummary()
+	}
+
 	if fatalErrorCount > 0 {
 		line += fmt.Sprintf(" (%v fatal errors)", fatalErrorCount)
 	}
@@ -162,10 +245,13 @@ func (p *cliProgress) output(col *color.Color, msg string) {
 		return
 	}
 
-	if est, ok := p.uploadStartTime.Estimate(float64(hashedBytes+cachedBytes), float64(p.estimatedTotalBytes)); ok {
+	now := clock.Now()
+
+	if est := p.throughput.Update(now, hashedBytes+cachedBytes, uploadedBytes, p.estimatedTotalBytes); est.ETA != nil {
 		line += fmt.Sprintf(", estimated %v", units.BytesStringBase10(p.estimatedTotalBytes))
 		line += fmt.Sprintf(" (%.1f%%)", est.PercentComplete)
-		line += fmt.Sprintf(" %v left", est.Remaining)
+		line += fmt.Sprintf(" %v left", est.ETA.Sub(now).Truncate(time.Second))
+		line += fmt.Sprintf(" at %v/s", units.BytesStringBase10(int64(est.ProcessedBytesPerSecond)))
 	} else {
 		line += ", estimating..."
 	}
@@ -181,6 +267,69 @@ func (p *cliProgress) output(col *color.Color, msg string) {
 	p.out.printStderr("\r%v%v", line, extraSpaces)
 }
 
+// outputJSON emits a single progress record, must be called with outputMutex held.
+func (p *cliProgress) outputJSON(msg string) {
+	hashedBytes := atomic.LoadInt64(&p.hashedBytes)
+	cachedBytes := atomic.LoadInt64(&p.cachedBytes)
+	uploadedBytes := atomic.LoadInt64(&p.uploadedBytes)
+	now := clock.Now()
+
+	rec := &progressRecord{
+		Time:           now,
+		Finished:       atomic.LoadInt32(&p.uploadFinished) == 1,
+		HashingFiles:   atomic.LoadInt32(&p.inProgressHashing),
+		HashedFiles:    atomic.LoadInt32(&p.hashedFiles),
+		HashedBytes:    hashedBytes,
+		CachedFiles:    atomic.LoadInt32(&p.cachedFiles),
+		CachedBytes:    cachedBytes,
+		UploadedBytes:  uploadedBytes,
+		IgnoredErrors:  atomic.LoadInt32(&p.ignoredErrorCount),
+		FatalErrors:    atomic.LoadInt32(&p.fatalErrorCount),
+		EstimatedFiles: p.estimatedFileCount,
+		EstimatedBytes: p.estimatedTotalBytes,
+		Streams:        p.streamedBytes,
+		Message:        strings.TrimSpace(msg),
+	}
+
+	est := p.throughput.Update(now, hashedBytes+cachedBytes, uploadedBytes, p.estimatedTotalBytes)
+
+	rec.ProcessedBytesPerSecond = est.ProcessedBytesPerSecond
+	rec.UploadedBytesPerSecond = est.UploadedBytesPerSecond
+	rec.RemainingUploadBytes = est.RemainingUploadBytes
+
+	if est.ETA != nil {
+		rec.PercentComplete = est.PercentComplete
+		rec.ETA = est.ETA
+		rec.RemainingTime = est.ETA.Sub(now).Truncate(time.Second).String()
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(p.progressJSONWriter(), "%s\n", b) // nolint:errcheck
+}
+
+// streamSummary returns the number of bytes read from each stream, sorted by stream name.
+func (p *cliProgress) streamSummary() string {
+	var names []string
+
+	for n := range p.streamedBytes {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+
+	var parts []string
+
+	for _, n := range names {
+		parts = append(parts, fmt.Sprintf("%v %v", n, units.BytesStringBase10(p.streamedBytes[n])))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
 func (p *cliProgress) spinnerCharacter() string {
 	if atomic.LoadInt32(&p.uploadFinished) == 1 {
 		return "*"
@@ -195,10 +344,9 @@ func (p *cliProgress) spinnerCharacter() string {
 
 func (p *cliProgress) StartShared() {
 	*p = cliProgress{
-		uploading:       1,
-		uploadStartTime: timetrack.Start(),
-		shared:          true,
-		progressFlags:   p.progressFlags,
+		uploading:     1,
+		shared:        true,
+		progressFlags: p.progressFlags,
 	}
 }
 
@@ -214,9 +362,8 @@ func (p *cliProgress) UploadStarted() {
 	}
 
 	*p = cliProgress{
-		uploading:       1,
-		uploadStartTime: timetrack.Start(),
-		progressFlags:   p.progressFlags,
+		uploading:     1,
+		progressFlags: p.progressFlags,
 	}
 }
 
@@ -248,7 +395,7 @@ func (p *cliProgress) Finish() {
 
 	p.output(defaultColor, "")
 
-	if p.enableProgress {
+	if p.enableProgress && p.progressFormat != progressFormatJSON {
 		p.out.printStderr("\n")
 	}
 }
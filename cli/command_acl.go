@@ -2,6 +2,7 @@
 
 type commandServerACL struct {
 	add    commandACLAdd
+	check  commandACLCheck
 	delete commandACLDelete
 	enable commandACLEnable
 	list   commandACLList
@@ -11,6 +12,7 @@ func (c *commandServerACL) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("acl", "Manager server access control list entries")
 
 	c.add.setup(svc, cmd)
+	c.check.setup(svc, cmd)
 	c.delete.setup(svc, cmd)
 	c.enable.setup(svc, cmd)
 	c.list.setup(svc, cmd)
@@ -25,15 +25,9 @@ func (c *commandACLAdd) setup(svc appServices, parent commandParent) {
 }
 
 func (c *commandACLAdd) run(ctx context.Context, rep repo.RepositoryWriter) error {
-	r := acl.TargetRule{}
-
-	for _, v := range strings.Split(c.target, ",") {
-		parts := strings.SplitN(v, "=", 2)
-		if len(parts) != 2 { //nolint:gomnd
-			return errors.Errorf("invalid target labels %q, must be key=value", v)
-		}
-
-		r[parts[0]] = parts[1]
+	r, err := parseACLTarget(c.target)
+	if err != nil {
+		return err
 	}
 
 	al, err := acl.ParseAccessLevel(c.level)
@@ -49,3 +43,18 @@ func (c *commandACLAdd) run(ctx context.Context, rep repo.RepositoryWriter) erro
 
 	return errors.Wrap(acl.AddACL(ctx, rep, e), "error adding ACL entry")
 }
+
+func parseACLTarget(target string) (acl.TargetRule, error) {
+	r := acl.TargetRule{}
+
+	for _, v := range strings.Split(target, ",") {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 { //nolint:gomnd
+			return nil, errors.Errorf("invalid target labels %q, must be key=value", v)
+		}
+
+		r[parts[0]] = parts[1]
+	}
+
+	return r, nil
+}
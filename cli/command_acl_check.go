@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandACLCheck struct {
+	user   string
+	target string
+	level  string
+
+	withRules     []string
+	onlyWithRules bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandACLCheck) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("check", "Evaluate ACL entries to determine whether the user has the specified access to the target")
+	cmd.Flag("user", "User to evaluate (username@hostname)").Required().StringVar(&c.user)
+	cmd.Flag("target", "Labels of the target manifest or 'type=content' (type=T,key1=value1,...,keyN=valueN)").Required().StringVar(&c.target)
+	cmd.Flag("action", "Access the user requests").Required().EnumVar(&c.level, acl.SupportedAccessLevels()...)
+	cmd.Flag("with-rule", "Candidate rule to evaluate together with stored ACL entries (user:access:type=T,key1=value1,...,keyN=valueN)").StringsVar(&c.withRules)
+	cmd.Flag("only-with-rules", "Evaluate candidate rules instead of stored ACL entries").BoolVar(&c.onlyWithRules)
+
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandACLCheck) run(ctx context.Context, rep repo.Repository) error {
+	target, err := parseACLTarget(c.target)
+	if err != nil {
+		return err
+	}
+
+	al, err := acl.ParseAccessLevel(c.level)
+	if err != nil {
+		return errors.Wrap(err, "invalid access level")
+	}
+
+	opt := auth.CheckACLOptions{OnlyCandidateRules: c.onlyWithRules}
+
+	for _, v := range c.withRules {
+		e, err := parseACLRule(v)
+		if err != nil {
+			return err
+		}
+
+		opt.CandidateRules = append(opt.CandidateRules, e)
+	}
+
+	d, err := auth.CheckACL(ctx, rep, c.user, target, al, opt)
+	if err != nil {
+		return errors.Wrap(err, "error evaluating ACL entries")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(d))
+		return nil
+	}
+
+	decision := "DENIED"
+	if d.Allowed {
+		decision = "ALLOWED"
+	}
+
+	c.out.printStdout("%v: %v requests %v access, effective access is %v\n", decision, c.user, al, d.EffectiveAccess)
+
+	switch {
+	case d.LegacyRules:
+		c.out.printStdout("No ACL entries are defined, legacy authorization rules apply.\n")
+	case d.MatchingCandidateRule:
+		e := d.MatchingRule
+		c.out.printStdout("Matching candidate rule: user:%v access:%v target:%v\n", e.User, e.Access, e.Target)
+	case d.MatchingRule != nil:
+		e := d.MatchingRule
+		c.out.printStdout("Matching rule: id:%v user:%v access:%v target:%v\n", d.MatchingRuleID, e.User, e.Access, e.Target)
+	default:
+		c.out.printStdout("No ACL entry matches the user and target.\n")
+	}
+
+	if d.AppendOnly {
+		c.out.printStdout("Access is limited because the user is append-only.\n")
+	}
+
+	return nil
+}
+
+// parseACLRule parses ACL entry in the format 'user:access:target'.
+func parseACLRule(rule string) (*acl.Entry, error) {
+	parts := strings.SplitN(rule, ":", 3)
+	if len(parts) != 3 { //nolint:gomnd
+		return nil, errors.Errorf("invalid rule %q, must be user:access:target", rule)
+	}
+
+	al, err := acl.ParseAccessLevel(parts[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid access level in rule %q", rule)
+	}
+
+	target, err := parseACLTarget(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return &acl.Entry{
+		User:   parts[0],
+		Target: target,
+		Access: al,
+	}, nil
+}
@@ -3,6 +3,7 @@
 type commandBenchmark struct {
 	compression commandBenchmarkCompression
 	crypto      commandBenchmarkCrypto
+	hashing     commandBenchmarkHashing
 	splitters   commandBenchmarkSplitters
 }
 
@@ -11,5 +12,6 @@ func (c *commandBenchmark) setup(svc appServices, parent commandParent) {
 
 	c.compression.setup(svc, cmd)
 	c.crypto.setup(svc, cmd)
+	c.hashing.setup(svc, cmd)
 	c.splitters.setup(svc, cmd)
 }
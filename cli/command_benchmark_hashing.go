@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"sort"
+
+	atunits "github.com/alecthomas/units"
+
+	"github.com/kopia/kopia/internal/timetrack"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/hashing"
+)
+
+type commandBenchmarkHashing struct {
+	blockSize   atunits.Base2Bytes
+	repeat      int
+	optionPrint bool
+
+	out textOutput
+}
+
+func (c *commandBenchmarkHashing) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("hashing", "Run hashing function benchmarks")
+	cmd.Flag("block-size", "Size of a block to hash").Default("1MB").BytesVar(&c.blockSize)
+	cmd.Flag("repeat", "Number of repetitions").Default("100").IntVar(&c.repeat)
+	cmd.Flag("print-options", "Print out options usable for repository creation").BoolVar(&c.optionPrint)
+	cmd.Action(svc.noRepositoryAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandBenchmarkHashing) run(ctx context.Context) error {
+	type benchResult struct {
+		hash       string
+		throughput float64
+	}
+
+	var results []benchResult
+
+	data := make([]byte, c.blockSize)
+
+	const maxHashSize = 64
+
+	var hashOutput [maxHashSize]byte
+
+	for _, ha := range hashing.SupportedAlgorithms() {
+		hf, err := hashing.CreateHashFunc(&content.FormattingOptions{
+			Hash:       ha,
+			HMACSecret: make([]byte, 32), // nolint:gomnd
+		})
+		if err != nil {
+			continue
+		}
+
+		log(ctx).Infof("Benchmarking hash '%v' (%v x %v bytes)", ha, c.repeat, len(data))
+
+		tt := timetrack.Start()
+
+		hashCount := c.repeat
+
+		for i := 0; i < hashCount; i++ {
+			hf(hashOutput[:0], data)
+		}
+
+		_, bytesPerSecond := tt.Completed(float64(len(data)) * float64(hashCount))
+
+		results = append(results, benchResult{hash: ha, throughput: bytesPerSecond})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].throughput > results[j].throughput
+	})
+	c.out.printStdout("     %-20v %v\n", "Hash", "Throughput")
+	c.out.printStdout("-----------------------------------------------------------------\n")
+
+	for ndx, r := range results {
+		c.out.printStdout("%3d. %-20v %v / second", ndx, r.hash, units.BytesStringBase2(int64(r.throughput)))
+
+		if c.optionPrint {
+			c.out.printStdout(",   --block-hash=%s", r.hash)
+		}
+
+		c.out.printStdout("\n")
+	}
+
+	c.out.printStdout("-----------------------------------------------------------------\n")
+	c.out.printStdout("Fastest option for this machine is: --block-hash=%s\n", results[0].hash)
+
+	return nil
+}
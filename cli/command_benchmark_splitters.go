@@ -2,8 +2,13 @@
 
 import (
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -12,6 +17,7 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/timetrack"
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo/splitter"
 )
 
@@ -21,6 +27,9 @@ type commandBenchmarkSplitters struct {
 	blockCount  int
 	printOption bool
 
+	realDataDir     string
+	realDataMaxSize atunits.Base2Bytes
+
 	out textOutput
 }
 
@@ -31,6 +40,8 @@ func (c *commandBenchmarkSplitters) setup(svc appServices, parent commandParent)
 	cmd.Flag("data-size", "Size of a data to split").Default("32MB").BytesVar(&c.blockSize)
 	cmd.Flag("block-count", "Number of data blocks to split").Default("16").IntVar(&c.blockCount)
 	cmd.Flag("print-options", "Print out fastest dynamic splitter option").BoolVar(&c.printOption)
+	cmd.Flag("real-data", "Split files in the provided directory instead of random data and report deduplication ratio").ExistingDirVar(&c.realDataDir)
+	cmd.Flag("real-data-max-size", "Maximum amount of real data to load").Default("1GB").BytesVar(&c.realDataMaxSize)
 
 	cmd.Action(svc.noRepositoryAction(c.run))
 
@@ -49,6 +60,7 @@ type benchResult struct {
 		p75          int
 		p90          int
 		max          int
+		dedupRatio   float64
 	}
 
 	var results []benchResult
@@ -57,26 +69,18 @@ type benchResult struct {
 
 	best.duration = math.MaxInt64
 
-	// generate data blocks
-	var dataBlocks [][]byte
-
-	rnd := rand.New(rand.NewSource(c.randSeed)) //nolint:gosec
-
-	for i := 0; i < c.blockCount; i++ {
-		b := make([]byte, c.blockSize)
-		if _, err := rnd.Read(b); err != nil {
-			return errors.Wrap(err, "error generating random data")
-		}
-
-		dataBlocks = append(dataBlocks, b)
+	dataBlocks, err := c.dataBlocks(ctx)
+	if err != nil {
+		return err
 	}
 
-	log(ctx).Infof("splitting %v blocks of %v each", c.blockCount, c.blockSize)
-
 	for _, sp := range splitter.SupportedAlgorithms() {
 		fact := splitter.GetFactory(sp)
 
-		var segmentLengths []int
+		var (
+			segmentLengths []int
+			segments       [][]byte
+		)
 
 		tt := timetrack.Start()
 
@@ -88,10 +92,13 @@ type benchResult struct {
 				n := s.NextSplitPoint(d)
 				if n < 0 {
 					segmentLengths = append(segmentLengths, len(d))
+					segments = append(segments, d)
+
 					break
 				}
 
 				segmentLengths = append(segmentLengths, n)
+				segments = append(segments, d[0:n])
 				d = d[n:]
 			}
 		}
@@ -111,13 +118,19 @@ type benchResult struct {
 			segmentLengths[len(segmentLengths)*75/100],
 			segmentLengths[len(segmentLengths)*90/100],
 			segmentLengths[len(segmentLengths)-1],
+			0,
+		}
+
+		if c.realDataDir != "" {
+			r.dedupRatio = dedupRatio(segments)
 		}
 
-		c.out.printStdout("%-25v %6v ms count:%v min:%v 10th:%v 25th:%v 50th:%v 75th:%v 90th:%v max:%v\n",
+		c.out.printStdout("%-25v %6v ms count:%v min:%v 10th:%v 25th:%v 50th:%v 75th:%v 90th:%v max:%v%v\n",
 			r.splitter,
 			r.duration.Nanoseconds()/1e6,
 			r.segmentCount,
-			r.min, r.p10, r.p25, r.p50, r.p75, r.p90, r.max)
+			r.min, r.p10, r.p25, r.p50, r.p75, r.p90, r.max,
+			c.dedupInfo(r.dedupRatio))
 
 		results = append(results, r)
 	}
@@ -128,12 +141,13 @@ type benchResult struct {
 	c.out.printStdout("-----------------------------------------------------------------\n")
 
 	for ndx, r := range results {
-		c.out.printStdout("%3v. %-25v %6v ms count:%v min:%v 10th:%v 25th:%v 50th:%v 75th:%v 90th:%v max:%v\n",
+		c.out.printStdout("%3v. %-25v %6v ms count:%v min:%v 10th:%v 25th:%v 50th:%v 75th:%v 90th:%v max:%v%v\n",
 			ndx,
 			r.splitter,
 			r.duration.Nanoseconds()/1e6,
 			r.segmentCount,
-			r.min, r.p10, r.p25, r.p50, r.p75, r.p90, r.max)
+			r.min, r.p10, r.p25, r.p50, r.p75, r.p90, r.max,
+			c.dedupInfo(r.dedupRatio))
 
 		if best.duration > r.duration && !strings.HasPrefix(r.splitter, "FIXED") {
 			best = r
@@ -146,3 +160,106 @@ type benchResult struct {
 
 	return nil
 }
+
+// dataBlocks returns blocks of data to split, either generated randomly or loaded from files in the real data directory.
+func (c *commandBenchmarkSplitters) dataBlocks(ctx context.Context) ([][]byte, error) {
+	if c.realDataDir != "" {
+		return c.loadRealData(ctx)
+	}
+
+	var dataBlocks [][]byte
+
+	rnd := rand.New(rand.NewSource(c.randSeed)) //nolint:gosec
+
+	for i := 0; i < c.blockCount; i++ {
+		b := make([]byte, c.blockSize)
+		if _, err := rnd.Read(b); err != nil {
+			return nil, errors.Wrap(err, "error generating random data")
+		}
+
+		dataBlocks = append(dataBlocks, b)
+	}
+
+	log(ctx).Infof("splitting %v blocks of %v each", c.blockCount, c.blockSize)
+
+	return dataBlocks, nil
+}
+
+// loadRealData loads contents of files in the real data directory, each file becomes a separate block.
+func (c *commandBenchmarkSplitters) loadRealData(ctx context.Context) ([][]byte, error) {
+	var (
+		dataBlocks [][]byte
+		totalSize  int64
+	)
+
+	maxSize := int64(c.realDataMaxSize)
+
+	errLimitReached := errors.New("limit reached")
+
+	err := filepath.Walk(c.realDataDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() || info.Size() == 0 {
+			return nil
+		}
+
+		if totalSize+info.Size() > maxSize {
+			log(ctx).Infof("Reached maximum amount of real data (%v), ignoring remaining files. Override with --real-data-max-size.", units.BytesStringBase2(maxSize))
+			return errLimitReached
+		}
+
+		data, err := ioutil.ReadFile(path) //nolint:gosec
+		if err != nil {
+			return errors.Wrapf(err, "error reading %v", path)
+		}
+
+		dataBlocks = append(dataBlocks, data)
+		totalSize += int64(len(data))
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimitReached) {
+		return nil, errors.Wrap(err, "error loading real data")
+	}
+
+	if len(dataBlocks) == 0 {
+		return nil, errors.Errorf("no data found in %v", c.realDataDir)
+	}
+
+	log(ctx).Infof("splitting %v files from %v (%v)", len(dataBlocks), c.realDataDir, units.BytesStringBase2(totalSize))
+
+	return dataBlocks, nil
+}
+
+func (c *commandBenchmarkSplitters) dedupInfo(ratio float64) string {
+	if c.realDataDir == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" dedup:%.3fx", ratio)
+}
+
+// dedupRatio returns the ratio of the total size of all segments to the total size of unique segments.
+func dedupRatio(segments [][]byte) float64 {
+	var totalBytes, uniqueBytes int64
+
+	seen := map[[sha256.Size]byte]bool{}
+
+	for _, s := range segments {
+		totalBytes += int64(len(s))
+
+		h := sha256.Sum256(s)
+		if !seen[h] {
+			seen[h] = true
+			uniqueBytes += int64(len(s))
+		}
+	}
+
+	if uniqueBytes == 0 {
+		return 1
+	}
+
+	return float64(totalBytes) / float64(uniqueBytes)
+}
@@ -1,11 +1,12 @@
 package cli
 
 type commandBlob struct {
-	delete commandBlobDelete
-	gc     commandBlobGC
-	list   commandBlobList
-	show   commandBlobShow
-	stats  commandBlobStats
+	delete   commandBlobDelete
+	gc       commandBlobGC
+	list     commandBlobList
+	show     commandBlobShow
+	stats    commandBlobStats
+	undelete commandBlobUndelete
 }
 
 func (c *commandBlob) setup(svc appServices, parent commandParent) {
@@ -16,4 +17,5 @@ func (c *commandBlob) setup(svc appServices, parent commandParent) {
 	c.list.setup(svc, cmd)
 	c.show.setup(svc, cmd)
 	c.stats.setup(svc, cmd)
+	c.undelete.setup(svc, cmd)
 }
@@ -14,7 +14,7 @@ type commandBlobGC struct {
 	delete   string
 	parallel int
 	prefix   string
-	safety   maintenance.SafetyParameters
+	safety   safetyFlag
 
 	svc appServices
 }
@@ -24,7 +24,7 @@ func (c *commandBlobGC) setup(svc appServices, parent commandParent) {
 	cmd.Flag("delete", "Whether to delete unused blobs").StringVar(&c.delete)
 	cmd.Flag("parallel", "Number of parallel blob scans").Default("16").IntVar(&c.parallel)
 	cmd.Flag("prefix", "Only GC blobs with given prefix").StringVar(&c.prefix)
-	safetyFlagVar(cmd, &c.safety)
+	c.safety.setup(cmd)
 	cmd.Action(svc.directRepositoryWriteAction(c.run))
 
 	c.svc = svc
@@ -39,7 +39,12 @@ func (c *commandBlobGC) run(ctx context.Context, rep repo.DirectRepositoryWriter
 		Prefix:   blob.ID(c.prefix),
 	}
 
-	n, err := maintenance.DeleteUnreferencedBlobs(ctx, rep, opts, c.safety)
+	safety, err := c.safety.parameters(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	n, err := maintenance.DeleteUnreferencedBlobs(ctx, rep, opts, safety)
 	if err != nil {
 		return errors.Wrap(err, "error deleting unreferenced blobs")
 	}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// undeleteFlags selects deleted blobs to restore from previous versions kept by the storage provider.
+type undeleteFlags struct {
+	prefix        string
+	deletedWithin time.Duration
+	dryRun        bool
+}
+
+func (c *undeleteFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("deleted-within", "Only undelete blobs deleted within the provided duration").DurationVar(&c.deletedWithin)
+	cmd.Flag("dry-run", "Do not modify repository").Short('n').BoolVar(&c.dryRun)
+}
+
+// findDeletedBlobs returns deleted blobs that can be restored and match the flags and provided blob IDs, if any.
+func (c *undeleteFlags) findDeletedBlobs(ctx context.Context, st blob.Storage, blobIDs []string) ([]blob.DeletedMetadata, error) {
+	var (
+		result         []blob.DeletedMetadata
+		unknownDeleted int
+	)
+
+	wanted := map[blob.ID]bool{}
+	for _, b := range blobIDs {
+		wanted[blob.ID(b)] = true
+	}
+
+	if !st.Capabilities().Versioning {
+		return nil, errors.Wrap(blob.ErrUndeleteUnsupported, "storage does not keep versions of deleted blobs")
+	}
+
+	cutoff := clock.Now().Add(-c.deletedWithin)
+
+	if err := blob.ListDeletedBlobs(ctx, st, blob.ID(c.prefix), func(dm blob.DeletedMetadata) error {
+		if len(wanted) > 0 && !wanted[dm.BlobID] {
+			return nil
+		}
+
+		if c.deletedWithin > 0 {
+			if dm.DeletedAt.IsZero() {
+				// deletion time is not reported by some providers, err on the side of restoring too much.
+				unknownDeleted++
+			} else if dm.DeletedAt.Before(cutoff) {
+				return nil
+			}
+		}
+
+		result = append(result, dm)
+
+		return nil
+	}); err != nil {
+		if errors.Is(err, blob.ErrUndeleteUnsupported) {
+			return nil, errors.Wrap(err, "storage does not keep versions of deleted blobs, make sure object versioning is enabled")
+		}
+
+		return nil, errors.Wrap(err, "error listing deleted blobs")
+	}
+
+	if unknownDeleted > 0 {
+		log(ctx).Infof("Deletion time of %v blobs is not known, they will be undeleted regardless of --deleted-within.", unknownDeleted)
+	}
+
+	return result, nil
+}
+
+// undeleteBlobs restores the provided deleted blobs. The format blob is restored last, so that
+// the repository can't be opened until all other blobs have been restored.
+func (c *undeleteFlags) undeleteBlobs(ctx context.Context, st blob.Storage, deleted []blob.DeletedMetadata) error {
+	sort.SliceStable(deleted, func(i, j int) bool {
+		if fi, fj := deleted[i].BlobID == repo.FormatBlobID, deleted[j].BlobID == repo.FormatBlobID; fi != fj {
+			return fj
+		}
+
+		return deleted[i].BlobID < deleted[j].BlobID
+	})
+
+	for _, dm := range deleted {
+		if c.dryRun {
+			log(ctx).Infof("would undelete %v (%v, deleted %v)", dm.BlobID, units.BytesStringBase10(dm.Length), formatDeletedAt(dm))
+			continue
+		}
+
+		if err := blob.UndeleteBlob(ctx, st, dm.BlobID, dm.VersionID); err != nil {
+			return errors.Wrapf(err, "error undeleting %v", dm.BlobID)
+		}
+
+		log(ctx).Infof("undeleted %v (%v, deleted %v)", dm.BlobID, units.BytesStringBase10(dm.Length), formatDeletedAt(dm))
+	}
+
+	return nil
+}
+
+func formatDeletedAt(dm blob.DeletedMetadata) string {
+	if dm.DeletedAt.IsZero() {
+		return "at unknown time"
+	}
+
+	return formatTimestamp(dm.DeletedAt)
+}
+
+// summarizeDeletedBlobs logs the number and total size of deleted blobs grouped by blob ID prefix.
+func summarizeDeletedBlobs(ctx context.Context, deleted []blob.DeletedMetadata) {
+	type group struct {
+		count int
+		bytes int64
+	}
+
+	groups := map[string]*group{}
+
+	var names []string
+
+	for _, dm := range deleted {
+		name := string(dm.BlobID)
+		if !strings.HasPrefix(name, "kopia.") {
+			name = name[0:1] + "*"
+		}
+
+		g := groups[name]
+		if g == nil {
+			g = &group{}
+			groups[name] = g
+			names = append(names, name)
+		}
+
+		g.count++
+		g.bytes += dm.Length
+	}
+
+	sort.Strings(names)
+
+	for _, n := range names {
+		log(ctx).Infof("  %-20v %v blobs (%v)", n, groups[n].count, units.BytesStringBase10(groups[n].bytes))
+	}
+}
+
+type commandBlobUndelete struct {
+	blobIDs []string
+	flags   undeleteFlags
+
+	svc appServices
+}
+
+func (c *commandBlobUndelete) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("undelete", "Restore recently deleted blobs from previous versions kept by the storage provider")
+	cmd.Arg("blobIDs", "Blob IDs (all deleted blobs if not specified)").StringsVar(&c.blobIDs)
+	cmd.Flag("prefix", "Only undelete blobs with given prefix").StringVar(&c.flags.prefix)
+	c.flags.setup(cmd)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+
+	c.svc = svc
+}
+
+func (c *commandBlobUndelete) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	c.svc.advancedCommand(ctx)
+
+	deleted, err := c.flags.findDeletedBlobs(ctx, rep.BlobStorage(), c.blobIDs)
+	if err != nil {
+		return err
+	}
+
+	if len(deleted) == 0 {
+		log(ctx).Infof("No deleted blobs found.")
+		return nil
+	}
+
+	return c.flags.undeleteBlobs(ctx, rep.BlobStorage(), deleted)
+}
@@ -1,10 +1,11 @@
 package cli
 
 type commandCache struct {
-	clear commandCacheClear
-	info  commandCacheInfo
-	set   commandCacheSetParams
-	sync  commandCacheSync
+	clear  commandCacheClear
+	info   commandCacheInfo
+	set    commandCacheSetParams
+	sync   commandCacheSync
+	verify commandCacheVerify
 }
 
 func (c *commandCache) setup(svc appServices, parent commandParent) {
@@ -14,4 +15,5 @@ func (c *commandCache) setup(svc appServices, parent commandParent) {
 	c.info.setup(svc, cmd)
 	c.set.setup(svc, cmd)
 	c.sync.setup(svc, cmd)
+	c.verify.setup(svc, cmd)
 }
@@ -65,6 +65,10 @@ func (c *commandCacheInfo) run(ctx context.Context, rep repo.Repository) error {
 		maybeLimit := ""
 		if l, ok := path2Limit[ent.Name()]; ok {
 			maybeLimit = fmt.Sprintf(" (limit %v)", units.BytesStringBase10(l))
+
+			if opts.FreeSpacePercent > 0 {
+				maybeLimit = fmt.Sprintf(" (limit %v or %v%% of free space)", units.BytesStringBase10(l), opts.FreeSpacePercent)
+			}
 		}
 
 		if ent.Name() == "blob-list" {
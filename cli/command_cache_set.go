@@ -15,6 +15,9 @@ type commandCacheSetParams struct {
 	contentCacheSizeMB     int64
 	maxMetadataCacheSizeMB int64
 	maxListCacheDuration   time.Duration
+	freeSpacePercent       int
+	integrityCheckInterval time.Duration
+	writeJournal           string
 
 	svc appServices
 }
@@ -26,6 +29,9 @@ func (c *commandCacheSetParams) setup(svc appServices, parent commandParent) {
 	cmd.Flag("content-cache-size-mb", "Size of local content cache").PlaceHolder("MB").Default("-1").Int64Var(&c.contentCacheSizeMB)
 	cmd.Flag("metadata-cache-size-mb", "Size of local metadata cache").PlaceHolder("MB").Default("-1").Int64Var(&c.maxMetadataCacheSizeMB)
 	cmd.Flag("max-list-cache-duration", "Duration of index cache").Default("-1ns").DurationVar(&c.maxListCacheDuration)
+	cmd.Flag("max-free-space-percent", "Limit size of each cache to percentage of free disk space (0 to disable)").PlaceHolder("PERCENT").Default("-1").IntVar(&c.freeSpacePercent)
+	cmd.Flag("integrity-check-interval", "Interval between automatic cache integrity checks (0 to disable)").Default("-1ns").DurationVar(&c.integrityCheckInterval)
+	cmd.Flag("write-journal", "Journal pending contents locally, so that they can be recovered after a crash").EnumVar(&c.writeJournal, "true", "false")
 	cmd.Action(svc.repositoryWriterAction(c.run))
 	c.svc = svc
 }
@@ -64,6 +70,34 @@ func (c *commandCacheSetParams) run(ctx context.Context, rep repo.RepositoryWrit
 		changed++
 	}
 
+	if v := c.freeSpacePercent; v != -1 {
+		if v < 0 || v > 100 {
+			return errors.Errorf("invalid free space percentage: %v", v)
+		}
+
+		log(ctx).Infof("changing cache free space limit to %v%%", v)
+		opts.FreeSpacePercent = v
+		changed++
+	}
+
+	if v := c.integrityCheckInterval; v != -1 {
+		log(ctx).Infof("changing cache integrity check interval to %v", v)
+
+		opts.IntegrityCheckIntervalSec = int(v.Seconds())
+		if v == 0 {
+			opts.IntegrityCheckIntervalSec = -1
+		}
+
+		changed++
+	}
+
+	if v := c.writeJournal; v != "" {
+		log(ctx).Infof("setting write journal to %v", v)
+
+		opts.WriteJournal = v == "true"
+		changed++
+	}
+
 	if changed == 0 {
 		return errors.Errorf("no changes")
 	}
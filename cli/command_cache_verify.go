@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandCacheVerify struct {
+	out textOutput
+}
+
+func (c *commandCacheVerify) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("verify", "Verifies integrity of local caches and evicts corrupt entries")
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+
+	c.out.setup(svc)
+}
+
+func (c *commandCacheVerify) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	st, err := rep.ContentManager().VerifyCacheIntegrity(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error verifying cache integrity")
+	}
+
+	c.out.printStdout("Verified %v cache entries (%v), evicted %v (%v).\n",
+		st.CheckedCount, units.BytesStringBase10(st.CheckedBytes),
+		st.EvictedCount, units.BytesStringBase10(st.EvictedBytes))
+
+	return nil
+}
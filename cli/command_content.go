@@ -1,6 +1,7 @@
 package cli
 
 type commandContent struct {
+	analyze commandContentAnalyze
 	delete  commandContentDelete
 	list    commandContentList
 	rewrite commandContentRewrite
@@ -12,6 +13,7 @@ type commandContent struct {
 func (c *commandContent) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("content", "Commands to manipulate content in repository.").Alias("contents").Hidden()
 
+	c.analyze.setup(svc, cmd)
 	c.delete.setup(svc, cmd)
 	c.list.setup(svc, cmd)
 	c.rewrite.setup(svc, cmd)
@@ -0,0 +1,13 @@
+package cli
+
+type commandContentAnalyze struct {
+	duplicates commandContentAnalyzeDuplicates
+	heatmap    commandContentAnalyzeHeatmap
+}
+
+func (c *commandContentAnalyze) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("analyze", "Commands to analyze repository contents.")
+
+	c.duplicates.setup(svc, cmd)
+	c.heatmap.setup(svc, cmd)
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+type commandContentAnalyzeDuplicates struct {
+	sources      []string
+	allSnapshots bool
+	minSize      int64
+	minLocations int
+	maxResults   int
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandContentAnalyzeDuplicates) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("duplicates", "Find identical files appearing in multiple paths or sources.")
+	cmd.Flag("sources", "Analyze the provided sources (defaults to all sources)").StringsVar(&c.sources)
+	cmd.Flag("all-snapshots", "Analyze all snapshots instead of the latest snapshot of each source").BoolVar(&c.allSnapshots)
+	cmd.Flag("min-size", "Ignore files smaller than the provided number of bytes").Default("1").Int64Var(&c.minSize)
+	cmd.Flag("min-locations", "Report files found in at least the provided number of locations").Default("2").IntVar(&c.minLocations)
+	cmd.Flag("max-results", "Maximum number of files to report (0 == unlimited)").Default("20").IntVar(&c.maxResults)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandContentAnalyzeDuplicates) run(ctx context.Context, rep repo.Repository) error {
+	manifests, err := c.loadManifests(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	dups, err := snapshotfs.FindDuplicateFiles(ctx, rep, manifests, snapshotfs.DuplicateFilesOptions{
+		MinSize:      c.minSize,
+		MinLocations: c.minLocations,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error finding duplicate files")
+	}
+
+	var totalSavings, extraCopies int64
+
+	for _, d := range dups {
+		totalSavings += d.LogicalSavings()
+		extraCopies += int64(len(d.Locations) - 1)
+	}
+
+	totalDuplicates := len(dups)
+
+	if c.maxResults > 0 && len(dups) > c.maxResults {
+		dups = dups[0:c.maxResults]
+	}
+
+	if c.jo.jsonOutput {
+		var jl jsonList
+
+		jl.begin(&c.jo)
+		defer jl.end()
+
+		for _, d := range dups {
+			jl.emit(d)
+		}
+
+		return nil
+	}
+
+	for _, d := range dups {
+		c.out.printStdout("%v %v x %v saves %v\n", d.ObjectID, units.BytesStringBase10(d.Size), len(d.Locations), units.BytesStringBase10(d.LogicalSavings()))
+
+		for _, l := range d.Locations {
+			c.out.printStdout("  %v\n", l)
+		}
+	}
+
+	c.out.printStderr("Analyzed %v snapshots and found %v duplicated files with %v extra copies, logical savings: %v.\n",
+		len(manifests), totalDuplicates, extraCopies, units.BytesStringBase10(totalSavings))
+
+	return nil
+}
+
+func (c *commandContentAnalyzeDuplicates) loadManifests(ctx context.Context, rep repo.Repository) ([]*snapshot.Manifest, error) {
+	manifests, err := loadSourceSnapshots(ctx, rep, c.sources)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.allSnapshots {
+		return manifests, nil
+	}
+
+	var latest []*snapshot.Manifest
+
+	for _, group := range snapshot.GroupBySource(manifests) {
+		latest = append(latest, snapshot.SortByTime(group, true)[0])
+	}
+
+	return latest, nil
+}
+
+// loadSourceSnapshots loads all snapshots of the provided sources or of all sources if none are provided.
+func loadSourceSnapshots(ctx context.Context, rep repo.Repository, sources []string) ([]*snapshot.Manifest, error) {
+	var manifestIDs []manifest.ID
+
+	if len(sources) == 0 {
+		man, err := snapshot.ListSnapshotManifests(ctx, rep, nil, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to list snapshot manifests")
+		}
+
+		manifestIDs = append(manifestIDs, man...)
+	}
+
+	for _, srcStr := range sources {
+		src, err := snapshot.ParseSourceInfo(srcStr, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing %q", srcStr)
+		}
+
+		man, err := snapshot.ListSnapshotManifests(ctx, rep, &src, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to list snapshot manifests for %v", src)
+		}
+
+		manifestIDs = append(manifestIDs, man...)
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, manifestIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load snapshots")
+	}
+
+	return manifests, nil
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+const heatmapUnreferencedLabel = "unreferenced"
+
+type commandContentAnalyzeHeatmap struct {
+	sources     []string
+	granularity string
+	parallel    int
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandContentAnalyzeHeatmap) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("heatmap", "Show amount of data by content creation time and by time of the most recent snapshot referencing it.")
+	cmd.Flag("sources", "Only consider contents referenced by snapshots of the provided sources as in use (defaults to all sources)").StringsVar(&c.sources)
+	cmd.Flag("granularity", "Time bucket granularity").Default(snapshotfs.HeatmapGranularityMonth).EnumVar(&c.granularity, snapshotfs.HeatmapGranularities...)
+	cmd.Flag("parallel", "Parallelism of snapshot walk").IntVar(&c.parallel)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandContentAnalyzeHeatmap) run(ctx context.Context, rep repo.DirectRepository) error {
+	manifests, err := loadSourceSnapshots(ctx, rep, c.sources)
+	if err != nil {
+		return err
+	}
+
+	hm, err := snapshotfs.BuildContentHeatmap(ctx, rep, manifests, snapshotfs.ContentHeatmapOptions{
+		Granularity: c.granularity,
+		Parallel:    c.parallel,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error building content heatmap")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(hm))
+		return nil
+	}
+
+	referenced := hm.LastReferencedBuckets()
+
+	// rows are creation buckets, columns are last-reference buckets.
+	header := []string{"created \\ last used"}
+
+	for _, r := range referenced {
+		if r == "" {
+			r = heatmapUnreferencedLabel
+		}
+
+		header = append(header, r)
+	}
+
+	c.out.printStdout("%v\n", formatHeatmapRow(header))
+
+	for _, created := range hm.CreatedBuckets() {
+		row := []string{created}
+
+		for _, r := range referenced {
+			if cell := hm.Cell(created, r); cell != nil {
+				row = append(row, units.BytesStringBase10(cell.PackedBytes))
+			} else {
+				row = append(row, "-")
+			}
+		}
+
+		c.out.printStdout("%v\n", formatHeatmapRow(row))
+	}
+
+	c.printSummary(hm, referenced)
+
+	return nil
+}
+
+// printSummary prints totals of hot data (referenced by snapshots in the most recent time bucket),
+// cold data (only referenced by older snapshots) and data not referenced by any snapshot.
+func (c *commandContentAnalyzeHeatmap) printSummary(hm *snapshotfs.ContentHeatmap, referenced []string) {
+	var (
+		newest string
+
+		hotCount, coldCount, unreferencedCount int64
+		hotBytes, coldBytes, unreferencedBytes int64
+	)
+
+	if len(referenced) > 0 {
+		newest = referenced[len(referenced)-1]
+	}
+
+	for _, cell := range hm.Cells {
+		switch {
+		case cell.LastReferenced == "":
+			unreferencedCount += cell.Count
+			unreferencedBytes += cell.PackedBytes
+
+		case cell.LastReferenced == newest:
+			hotCount += cell.Count
+			hotBytes += cell.PackedBytes
+
+		default:
+			coldCount += cell.Count
+			coldBytes += cell.PackedBytes
+		}
+	}
+
+	c.out.printStderr("\nHot (used in %v): %v contents, %v\n", newest, hotCount, units.BytesStringBase10(hotBytes))
+	c.out.printStderr("Cold (only used by older snapshots): %v contents, %v\n", coldCount, units.BytesStringBase10(coldBytes))
+	c.out.printStderr("Unreferenced: %v contents, %v\n", unreferencedCount, units.BytesStringBase10(unreferencedBytes))
+}
+
+func formatHeatmapRow(cols []string) string {
+	var sb strings.Builder
+
+	for i, col := range cols {
+		width := 14
+		if i == 0 {
+			width = 20
+		}
+
+		sb.WriteString(col)
+
+		for n := len(col); n < width; n++ {
+			sb.WriteByte(' ')
+		}
+	}
+
+	return strings.TrimRight(sb.String(), " ")
+}
@@ -16,7 +16,7 @@ type commandContentRewrite struct {
 	contentRewriteFormatVersion int
 	contentRewritePackPrefix    string
 	contentRewriteDryRun        bool
-	contentRewriteSafety        maintenance.SafetyParameters
+	contentRewriteSafety        safetyFlag
 
 	contentRange contentRangeFlags
 	svc          appServices
@@ -32,7 +32,7 @@ func (c *commandContentRewrite) setup(svc appServices, parent commandParent) {
 	cmd.Flag("pack-prefix", "Only rewrite contents from pack blobs with a given prefix").StringVar(&c.contentRewritePackPrefix)
 	cmd.Flag("dry-run", "Do not actually rewrite, only print what would happen").Short('n').BoolVar(&c.contentRewriteDryRun)
 	c.contentRange.setup(cmd)
-	safetyFlagVar(cmd, &c.contentRewriteSafety)
+	c.contentRewriteSafety.setup(cmd)
 	cmd.Action(svc.directRepositoryWriteAction(c.runContentRewriteCommand))
 
 	c.svc = svc
@@ -41,6 +41,11 @@ func (c *commandContentRewrite) setup(svc appServices, parent commandParent) {
 func (c *commandContentRewrite) runContentRewriteCommand(ctx context.Context, rep repo.DirectRepositoryWriter) error {
 	c.svc.advancedCommand(ctx)
 
+	safety, err := c.contentRewriteSafety.parameters(ctx, rep)
+	if err != nil {
+		return err
+	}
+
 	// nolint:wrapcheck
 	return maintenance.RewriteContents(ctx, rep, &maintenance.RewriteContentsOptions{
 		ContentIDRange: c.contentRange.contentIDRange(),
@@ -50,7 +55,7 @@ func (c *commandContentRewrite) runContentRewriteCommand(ctx context.Context, re
 		Parallel:       c.contentRewriteParallelism,
 		ShortPacks:     c.contentRewriteShortPacks,
 		DryRun:         c.contentRewriteDryRun,
-	}, c.contentRewriteSafety)
+	}, safety)
 }
 
 func toContentIDs(s []string) []content.ID {
@@ -17,7 +17,9 @@ type commandDiff struct {
 	diffSecondObjectPath string
 	diffCompareFiles     bool
 	diffCommandCommand   string
+	diffParallel         int
 
+	jo  jsonOutput
 	out textOutput
 }
 
@@ -27,8 +29,10 @@ func (c *commandDiff) setup(svc appServices, parent commandParent) {
 	cmd.Arg("object-path2", "Second object/path").Required().StringVar(&c.diffSecondObjectPath)
 	cmd.Flag("files", "Compare files by launching diff command for all pairs of (old,new)").Short('f').BoolVar(&c.diffCompareFiles)
 	cmd.Flag("diff-command", "Displays differences between two repository objects (files or directories)").Default(defaultDiffCommand()).Envar("KOPIA_DIFF").StringVar(&c.diffCommandCommand)
+	cmd.Flag("parallel", "Number of directories to compare in parallel").Default("8").IntVar(&c.diffParallel)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 
+	c.jo.setup(svc, cmd)
 	c.out.setup(svc)
 }
 
@@ -56,6 +60,16 @@ func (c *commandDiff) run(ctx context.Context, rep repo.Repository) error {
 	}
 	defer d.Close() //nolint:errcheck
 
+	d.Parallelism = c.diffParallel
+
+	if c.jo.jsonOutput {
+		if c.diffCompareFiles {
+			return errors.New("--files is not supported with --json")
+		}
+
+		return c.emitJSON(ctx, d, ent1, ent2)
+	}
+
 	if c.diffCompareFiles {
 		parts := strings.Split(c.diffCommandCommand, " ")
 		d.DiffCommand = parts[0]
@@ -69,6 +83,24 @@ func (c *commandDiff) run(ctx context.Context, rep repo.Repository) error {
 	return errors.New("comparing files not implemented yet")
 }
 
+func (c *commandDiff) emitJSON(ctx context.Context, d *diff.Comparer, ent1, ent2 fs.Entry) error {
+	changes, err := d.Changes(ctx, ent1, ent2)
+	if err != nil {
+		return errors.Wrap(err, "error comparing directories")
+	}
+
+	var jl jsonList
+
+	jl.begin(&c.jo)
+	defer jl.end()
+
+	for _, ch := range changes {
+		jl.emit(ch)
+	}
+
+	return nil
+}
+
 func defaultDiffCommand() string {
 	if isWindows() {
 		return "cmp"
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/indexexport"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+type commandIndexExport struct {
+	output         string
+	format         string
+	includeDeleted bool
+
+	out textOutput
+}
+
+func (c *commandIndexExport) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("export", "Export all content index entries for offline analysis")
+	cmd.Flag("output", "Output file (defaults to standard output)").Short('o').StringVar(&c.output)
+	cmd.Flag("format", "Output format").Default("csv").EnumVar(&c.format, "csv")
+	cmd.Flag("include-deleted", "Include entries of deleted contents").Default("true").BoolVar(&c.includeDeleted)
+	c.out.setup(svc)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandIndexExport) run(ctx context.Context, rep repo.DirectRepository) error {
+	var output io.Writer = c.out.stdout()
+
+	if c.output != "" {
+		f, err := os.Create(c.output) //nolint:gosec
+		if err != nil {
+			return errors.Wrap(err, "unable to create output file")
+		}
+
+		defer f.Close() //nolint:errcheck,gosec
+
+		output = f
+	}
+
+	w, err := indexexport.NewWriter(output)
+	if err != nil {
+		return errors.Wrap(err, "unable to write output")
+	}
+
+	var count int
+
+	if err := rep.ContentReader().IterateContents(
+		ctx,
+		content.IterateOptions{
+			IncludeDeleted: c.includeDeleted,
+		},
+		func(ci content.Info) error {
+			count++
+			return w.Write(indexexport.RowFromInfo(ci))
+		}); err != nil {
+		return errors.Wrap(err, "error exporting index entries")
+	}
+
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err, "unable to write output")
+	}
+
+	if c.output != "" {
+		log(ctx).Infof("Exported %v index entries to %v.", count, c.output)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/indexexport"
+	"github.com/kopia/kopia/internal/units"
+)
+
+type commandIndexImportAnalysis struct {
+	file string
+	raw  bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandIndexImportAnalysis) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("import-analysis", "Analyze content index entries exported with 'index export' without connecting to the repository")
+	cmd.Arg("file", "File produced by 'index export'").Required().ExistingFileVar(&c.file)
+	cmd.Flag("raw", "Raw numbers").Short('r').BoolVar(&c.raw)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.noRepositoryAction(c.run))
+}
+
+func (c *commandIndexImportAnalysis) run(ctx context.Context) error {
+	f, err := os.Open(c.file) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "unable to open input file")
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	r, err := indexexport.NewReader(f)
+	if err != nil {
+		return errors.Wrap(err, "invalid input file")
+	}
+
+	a := indexexport.NewAnalyzer()
+	if err := a.AddAll(r); err != nil {
+		return errors.Wrap(err, "invalid input file")
+	}
+
+	res := a.Result()
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(res))
+		return nil
+	}
+
+	sizeToString := units.BytesStringBase10
+	if c.raw {
+		sizeToString = func(l int64) string { return strconv.FormatInt(l, 10) }
+	}
+
+	c.out.printStdout("Index entries: %v\n", res.Rows)
+	c.out.printStdout("Contents: %v (%v packed, %v original, %v compressed)\n",
+		res.Contents.Count, sizeToString(res.Contents.PackedBytes), sizeToString(res.Contents.OriginalBytes), res.Contents.CompressedCount)
+	c.out.printStdout("Deleted contents: %v (%v packed)\n", res.Contents.DeletedCount, sizeToString(res.Contents.DeletedBytes))
+
+	c.printPrefixStats("By content prefix:", res.ContentPrefixes, sizeToString)
+	c.printPrefixStats("By pack prefix:", res.PackPrefixes, sizeToString)
+
+	c.out.printStdout("\nPacks: %v\n", res.Packs)
+	c.out.printStdout("Pack utilization (percentage of bytes not deleted):\n")
+
+	prev := 0
+
+	for _, b := range res.Utilization {
+		c.out.printStdout("  %3v%%-%3v%%: %8v packs %15v total %15v deleted\n", prev, b.MaxPercent, b.Packs, sizeToString(b.PackedBytes), sizeToString(b.DeletedBytes))
+		prev = b.MaxPercent
+	}
+
+	return nil
+}
+
+func (c *commandIndexImportAnalysis) printPrefixStats(title string, stats []*indexexport.PrefixStats, sizeToString func(int64) string) {
+	c.out.printStdout("\n%v\n", title)
+
+	for _, s := range stats {
+		prefix := s.Prefix
+		if prefix == "" {
+			prefix = "(none)"
+		}
+
+		c.out.printStdout("  %-8v %10v contents %15v packed %15v original %10v deleted %15v deleted packed\n",
+			prefix, s.Count, sizeToString(s.PackedBytes), sizeToString(s.OriginalBytes), s.DeletedCount, sizeToString(s.DeletedBytes))
+	}
+}
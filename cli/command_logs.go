@@ -0,0 +1,13 @@
+package cli
+
+type commandLogs struct {
+	list   commandLogsList
+	search commandLogsSearch
+}
+
+func (c *commandLogs) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("logs", "Commands to inspect diagnostic logs uploaded to the repository.")
+
+	c.list.setup(svc, cmd)
+	c.search.setup(svc, cmd)
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/repodiag"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandLogsList struct {
+	out textOutput
+}
+
+func (c *commandLogsList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List log blobs.").Alias("ls")
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandLogsList) run(ctx context.Context, rep repo.DirectRepository) error {
+	blobs, err := repodiag.ListLogBlobs(ctx, rep.BlobReader())
+	if err != nil {
+		return errors.Wrap(err, "unable to list logs")
+	}
+
+	for _, li := range blobs {
+		c.out.printStdout("%v %v %v %v\n",
+			formatTimestamp(li.StartTime),
+			li.EndTime.Sub(li.StartTime),
+			li.SessionID,
+			units.BytesStringBase10(li.Length))
+	}
+
+	return nil
+}
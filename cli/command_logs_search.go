@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/repodiag"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandLogsSearch struct {
+	from    string
+	to      string
+	text    string
+	regex   string
+	session string
+
+	out textOutput
+}
+
+func (c *commandLogsSearch) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("search", "Search log lines uploaded to the repository.")
+	cmd.Flag("from", "Only include lines logged at or after the provided time (RFC 3339 time or duration ago, e.g. 2h)").StringVar(&c.from)
+	cmd.Flag("to", "Only include lines logged before the provided time (RFC 3339 time or duration ago, e.g. 2h)").StringVar(&c.to)
+	cmd.Flag("text", "Only include lines containing the provided text").StringVar(&c.text)
+	cmd.Flag("regex", "Only include lines matching the provided regular expression").StringVar(&c.regex)
+	cmd.Flag("session", "Only include lines from the provided logging session").StringVar(&c.session)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandLogsSearch) run(ctx context.Context, rep repo.DirectRepository) error {
+	opt := repodiag.SearchOptions{
+		SessionID: c.session,
+		Text:      c.text,
+	}
+
+	var err error
+
+	if opt.MinTime, err = parseLogSearchTime(c.from, rep.Time()); err != nil {
+		return errors.Wrap(err, "invalid --from")
+	}
+
+	if opt.MaxTime, err = parseLogSearchTime(c.to, rep.Time()); err != nil {
+		return errors.Wrap(err, "invalid --to")
+	}
+
+	if c.regex != "" {
+		if opt.Regexp, err = regexp.Compile(c.regex); err != nil {
+			return errors.Wrap(err, "invalid --regex")
+		}
+	}
+
+	// nolint:wrapcheck
+	return repodiag.SearchLogs(ctx, rep, opt, func(l repodiag.LogLine) error {
+		c.out.printStdout("%v %v\n", l.SessionID, l.Text)
+		return nil
+	})
+}
+
+// parseLogSearchTime parses absolute time in RFC 3339 or snapshot time format or a duration relative to now.
+func parseLogSearchTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return parseTimestamp(s)
+}
@@ -1,9 +1,11 @@
 package cli
 
 type commandMaintenance struct {
-	info commandMaintenanceInfo
-	run  commandMaintenanceRun
-	set  commandMaintenanceSet
+	info                  commandMaintenanceInfo
+	run                   commandMaintenanceRun
+	scrub                 commandMaintenanceScrub
+	set                   commandMaintenanceSet
+	compressionDuplicates commandMaintenanceCompressionDuplicates
 }
 
 func (c *commandMaintenance) setup(svc appServices, parent commandParent) {
@@ -11,5 +13,7 @@ func (c *commandMaintenance) setup(svc appServices, parent commandParent) {
 
 	c.info.setup(svc, cmd)
 	c.run.setup(svc, cmd)
+	c.scrub.setup(svc, cmd)
 	c.set.setup(svc, cmd)
+	c.compressionDuplicates.setup(svc, cmd)
 }
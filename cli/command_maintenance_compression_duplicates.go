@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotmaintenance"
+)
+
+type commandMaintenanceCompressionDuplicates struct {
+	rewrite bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandMaintenanceCompressionDuplicates) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("compression-duplicates", "Find data stored multiple times using different compression settings")
+	cmd.Flag("rewrite", "Rewrite snapshots to reference a single copy of duplicated data").BoolVar(&c.rewrite)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandMaintenanceCompressionDuplicates) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	report, err := snapshotmaintenance.FindCompressionDuplicates(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "error finding compression duplicates")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(report))
+	} else {
+		for _, d := range report.Duplicates {
+			c.out.printStdout("%v %v duplicates: %v\n", d.Canonical, units.BytesStringBase10(d.Length), d.Duplicates)
+		}
+
+		c.out.printStdout("Analyzed %v snapshots and %v chunks, found %v duplicated chunks, reclaimable: %v\n",
+			report.SnapshotsAnalyzed, report.ChunksAnalyzed, len(report.Duplicates), units.BytesStringBase10(report.ReclaimableBytes))
+	}
+
+	if !c.rewrite {
+		return nil
+	}
+
+	stats, err := snapshotmaintenance.RewriteCompressionDuplicates(ctx, rep, report)
+	if err != nil {
+		return errors.Wrap(err, "error rewriting compression duplicates")
+	}
+
+	log(ctx).Infof("Rewrote %v snapshots, skipped %v under legal hold. Duplicates will be reclaimed by full maintenance.", stats.SnapshotsRewritten, stats.SnapshotsSkipped)
+
+	return nil
+}
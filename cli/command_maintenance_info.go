@@ -7,6 +7,7 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/maintenance"
 )
@@ -46,6 +47,32 @@ func (c *commandMaintenanceInfo) run(ctx context.Context, rep repo.DirectReposit
 	c.out.printStdout("Full Cycle:\n")
 	c.displayCycleInfo(&p.FullCycle, s.NextFullMaintenanceTime, rep)
 
+	c.out.printStdout("Scrub:\n")
+	c.displayCycleInfo(&p.Scrub.CycleParams, s.NextScrubTime, rep)
+	c.out.printStdout("  percent per cycle: %v\n", p.Scrub.PercentOrDefault())
+
+	if s.ScrubCursor != "" {
+		c.out.printStdout("  cursor: %v\n", s.ScrubCursor)
+	}
+
+	if len(s.ScrubFindings) > 0 {
+		c.out.printStdout("Corrupted Pack Blobs Found By Scrub:\n")
+
+		for _, f := range s.ScrubFindings {
+			c.out.printStdout("  %v %v: %v\n", formatTimestamp(f.Time), f.BlobID, f.Error)
+		}
+	}
+
+	c.out.printStdout("Rewrite Compression Duplicates: %v\n", p.RewriteCompressionDuplicates)
+
+	c.displaySafety(p)
+
+	lr := p.LogRetention.OrDefault()
+	c.out.printStdout("Log Retention:\n")
+	c.out.printStdout("  max count:       %v\n", lr.MaxCount)
+	c.out.printStdout("  max age:         %v\n", lr.MaxAge)
+	c.out.printStdout("  max total size:  %v\n", units.BytesStringBase2(lr.MaxTotalSize))
+
 	c.out.printStdout("Recent Maintenance Runs:\n")
 
 	for run, timings := range s.Runs {
@@ -70,6 +97,26 @@ func (c *commandMaintenanceInfo) run(ctx context.Context, rep repo.DirectReposit
 	return nil
 }
 
+func (c *commandMaintenanceInfo) displaySafety(p *maintenance.Params) {
+	c.out.printStdout("Safety Profile: %v\n", p.SafetyProfileOrDefault())
+
+	sp, err := p.Safety()
+	if err != nil {
+		c.out.printStdout("  invalid: %v\n", err)
+		return
+	}
+
+	c.out.printStdout("  rewrite min age:                      %v\n", sp.RewriteMinAge)
+	c.out.printStdout("  min content age subject to GC:        %v\n", sp.MinContentAgeSubjectToGC)
+	c.out.printStdout("  margin between snapshot GC:           %v\n", sp.MarginBetweenSnapshotGC)
+	c.out.printStdout("  require two GC cycles:                %v\n", sp.RequireTwoGCCycles)
+	c.out.printStdout("  drop content from index extra margin: %v\n", sp.DropContentFromIndexExtraMargin)
+	c.out.printStdout("  blob delete min age:                  %v\n", sp.BlobDeleteMinAge)
+	c.out.printStdout("  session expiration age:               %v\n", sp.SessionExpirationAge)
+	c.out.printStdout("  min rewrite to orphan deletion delay: %v\n", sp.MinRewriteToOrphanDeletionDelay)
+	c.out.printStdout("  max clock skew:                       %v\n", sp.MaxClockSkew)
+}
+
 func (c *commandMaintenanceInfo) displayCycleInfo(cp *maintenance.CycleParams, t time.Time, rep repo.DirectRepository) {
 	c.out.printStdout("  scheduled: %v\n", cp.Enabled)
 
@@ -11,14 +11,14 @@
 type commandMaintenanceRun struct {
 	maintenanceRunFull  bool
 	maintenanceRunForce bool
-	safety              maintenance.SafetyParameters
+	safety              safetyFlag
 }
 
 func (c *commandMaintenanceRun) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("run", "Run repository maintenance").Default()
 	cmd.Flag("full", "Full maintenance").BoolVar(&c.maintenanceRunFull)
 	cmd.Flag("force", "Run maintenance even if not owned (unsafe)").Hidden().BoolVar(&c.maintenanceRunForce)
-	safetyFlagVar(cmd, &c.safety)
+	c.safety.setup(cmd)
 
 	cmd.Action(svc.directRepositoryWriteAction(c.run))
 }
@@ -29,6 +29,11 @@ func (c *commandMaintenanceRun) run(ctx context.Context, rep repo.DirectReposito
 		mode = maintenance.ModeFull
 	}
 
+	safety, err := c.safety.parameters(ctx, rep)
+	if err != nil {
+		return err
+	}
+
 	// nolint:wrapcheck
-	return snapshotmaintenance.Run(ctx, rep, mode, c.maintenanceRunForce, c.safety)
+	return snapshotmaintenance.Run(ctx, rep, mode, c.maintenanceRunForce, safety)
 }
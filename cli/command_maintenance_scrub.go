@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+type commandMaintenanceScrub struct {
+	percent float64
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandMaintenanceScrub) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("scrub", "Read and verify a portion of pack blobs, continuing where the previous scrub cycle left off")
+	cmd.Flag("percent", "Percentage of pack blobs to verify (defaults to maintenance parameters)").Float64Var(&c.percent)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandMaintenanceScrub) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	percent := c.percent
+
+	if percent == 0 {
+		p, err := maintenance.GetParams(ctx, rep)
+		if err != nil {
+			return errors.Wrap(err, "unable to get maintenance params")
+		}
+
+		percent = p.Scrub.PercentOrDefault()
+	}
+
+	if percent < 0 || percent > 100 {
+		return errors.Errorf("scrub percentage must be greater than 0 and at most 100")
+	}
+
+	result, err := maintenance.RunScrub(ctx, rep, percent)
+	if result == nil {
+		return errors.Wrap(err, "error scrubbing pack blobs")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(result))
+	} else {
+		c.out.printStdout("Verified %v pack blobs (%v), cursor: %v\n", result.PacksChecked, units.BytesStringBase10(result.BytesChecked), result.Cursor)
+
+		for _, f := range result.Findings {
+			c.out.printStdout("CORRUPTED %v: %v\n", f.BlobID, f.Error)
+		}
+	}
+
+	// nolint:wrapcheck
+	return err
+}
@@ -6,24 +6,49 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/maintenance"
 )
 
 type commandMaintenanceSet struct {
 	maintenanceSetOwner          string
+	takeOwnership                bool
+	takeOwnershipWait            time.Duration
 	maintenanceSetEnableQuick    []bool          // optional boolean
 	maintenanceSetEnableFull     []bool          // optional boolean
 	maintenanceSetQuickFrequency []time.Duration // optional duration
 	maintenanceSetFullFrequency  []time.Duration // optional duration
 	maintenanceSetPauseQuick     []time.Duration // optional duration
 	maintenanceSetPauseFull      []time.Duration // optional duration
+	maintenanceSetEnableScrub    []bool          // optional boolean
+	maintenanceSetScrubInterval  []time.Duration // optional duration
+	maintenanceSetScrubPercent   []float64       // optional float64
+
+	rewriteCompressionDuplicates []bool // optional boolean
+
+	maxRetainedLogCount     []int           // optional int
+	maxRetainedLogAge       []time.Duration // optional duration
+	maxTotalRetainedLogSize []int64         // optional int64
+
+	safetyProfile                         string
+	safetyRewriteMinAge                   []time.Duration // optional duration
+	safetyMinContentAgeSubjectToGC        []time.Duration // optional duration
+	safetyMarginBetweenSnapshotGC         []time.Duration // optional duration
+	safetyDropContentFromIndexExtraMargin []time.Duration // optional duration
+	safetyBlobDeleteMinAge                []time.Duration // optional duration
+	safetySessionExpirationAge            []time.Duration // optional duration
+	safetyMinRewriteToOrphanDeletionDelay []time.Duration // optional duration
+	safetyMaxClockSkew                    []time.Duration // optional duration
+	safetyRequireTwoGCCycles              []bool          // optional boolean
 }
 
 func (c *commandMaintenanceSet) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("set", "Set maintenance parameters")
 
 	cmd.Flag("owner", "Set maintenance owner user@hostname").StringVar(&c.maintenanceSetOwner)
+	cmd.Flag("take-ownership", "Safely take over maintenance ownership from an owner that is no longer active (defaults to current user, or --owner)").BoolVar(&c.takeOwnership)
+	cmd.Flag("take-ownership-wait", "Time to wait for the previous owner when taking over ownership (defaults to maximum clock skew)").DurationVar(&c.takeOwnershipWait)
 
 	cmd.Flag("enable-quick", "Enable or disable quick maintenance").BoolListVar(&c.maintenanceSetEnableQuick)
 	cmd.Flag("enable-full", "Enable or disable full maintenance").BoolListVar(&c.maintenanceSetEnableFull)
@@ -34,9 +59,149 @@ func (c *commandMaintenanceSet) setup(svc appServices, parent commandParent) {
 	cmd.Flag("pause-quick", "Pause quick maintenance for a specified duration").DurationListVar(&c.maintenanceSetPauseQuick)
 	cmd.Flag("pause-full", "Pause full maintenance for a specified duration").DurationListVar(&c.maintenanceSetPauseFull)
 
+	cmd.Flag("enable-scrub", "Enable or disable periodic scrubbing of pack blobs").BoolListVar(&c.maintenanceSetEnableScrub)
+	cmd.Flag("scrub-interval", "Set interval between scrub cycles").DurationListVar(&c.maintenanceSetScrubInterval)
+	cmd.Flag("scrub-percent", "Set percentage of pack blobs verified in each scrub cycle").Float64ListVar(&c.maintenanceSetScrubPercent)
+
+	cmd.Flag("rewrite-compression-duplicates", "Enable or disable rewriting of data stored with different compression settings during full maintenance").BoolListVar(&c.rewriteCompressionDuplicates)
+
+	cmd.Flag("max-retained-log-count", "Set maximum number of log blobs to retain").IntsVar(&c.maxRetainedLogCount)
+	cmd.Flag("max-retained-log-age", "Set maximum age of log blobs to retain").DurationListVar(&c.maxRetainedLogAge)
+	cmd.Flag("max-total-retained-log-size-mb", "Set maximum total size of log blobs to retain").Int64ListVar(&c.maxTotalRetainedLogSize)
+
+	cmd.Flag("safety-profile", "Set safety profile used by maintenance").EnumVar(&c.safetyProfile,
+		maintenance.SafetyProfileFast, maintenance.SafetyProfileDefault, maintenance.SafetyProfileParanoid, maintenance.SafetyProfileCustom)
+	cmd.Flag("safety-rewrite-min-age", "Set custom minimum age of contents to rewrite").DurationListVar(&c.safetyRewriteMinAge)
+	cmd.Flag("safety-min-content-age-subject-to-gc", "Set custom minimum age of contents subject to snapshot GC").DurationListVar(&c.safetyMinContentAgeSubjectToGC)
+	cmd.Flag("safety-margin-between-snapshot-gc", "Set custom minimum time between snapshot GC cycles").DurationListVar(&c.safetyMarginBetweenSnapshotGC)
+	cmd.Flag("safety-drop-content-from-index-extra-margin", "Set custom extra margin before dropping deleted contents from indexes").DurationListVar(&c.safetyDropContentFromIndexExtraMargin)
+	cmd.Flag("safety-blob-delete-min-age", "Set custom minimum age of unused blobs to delete").DurationListVar(&c.safetyBlobDeleteMinAge)
+	cmd.Flag("safety-session-expiration-age", "Set custom age after which incomplete sessions are dropped").DurationListVar(&c.safetySessionExpirationAge)
+	cmd.Flag("safety-min-rewrite-to-orphan-deletion-delay", "Set custom minimum time between content rewrite and deletion of orphaned blobs").DurationListVar(&c.safetyMinRewriteToOrphanDeletionDelay)
+	cmd.Flag("safety-max-clock-skew", "Set custom maximum allowed clock skew (0 to disable the check)").DurationListVar(&c.safetyMaxClockSkew)
+	cmd.Flag("safety-require-two-gc-cycles", "Require two snapshot GC cycles before deleting contents").BoolListVar(&c.safetyRequireTwoGCCycles)
+
 	cmd.Action(svc.directRepositoryWriteAction(c.run))
 }
 
+// setSafetyFromFlags updates the safety profile, setting any of the custom margins switches to the custom profile
+// based on currently effective safety parameters.
+func (c *commandMaintenanceSet) setSafetyFromFlags(ctx context.Context, p *maintenance.Params, changed *bool) error {
+	if c.safetyProfile != "" {
+		p.SafetyProfile = c.safetyProfile
+		*changed = true
+
+		log(ctx).Infof("Setting maintenance safety profile to %v.", p.SafetyProfile)
+	}
+
+	durations := []struct {
+		flag []time.Duration
+		ptr  func(sp *maintenance.SafetyParameters) *time.Duration
+	}{
+		{c.safetyRewriteMinAge, func(sp *maintenance.SafetyParameters) *time.Duration { return &sp.RewriteMinAge }},
+		{c.safetyMinContentAgeSubjectToGC, func(sp *maintenance.SafetyParameters) *time.Duration { return &sp.MinContentAgeSubjectToGC }},
+		{c.safetyMarginBetweenSnapshotGC, func(sp *maintenance.SafetyParameters) *time.Duration { return &sp.MarginBetweenSnapshotGC }},
+		{c.safetyDropContentFromIndexExtraMargin, func(sp *maintenance.SafetyParameters) *time.Duration { return &sp.DropContentFromIndexExtraMargin }},
+		{c.safetyBlobDeleteMinAge, func(sp *maintenance.SafetyParameters) *time.Duration { return &sp.BlobDeleteMinAge }},
+		{c.safetySessionExpirationAge, func(sp *maintenance.SafetyParameters) *time.Duration { return &sp.SessionExpirationAge }},
+		{c.safetyMinRewriteToOrphanDeletionDelay, func(sp *maintenance.SafetyParameters) *time.Duration { return &sp.MinRewriteToOrphanDeletionDelay }},
+		{c.safetyMaxClockSkew, func(sp *maintenance.SafetyParameters) *time.Duration { return &sp.MaxClockSkew }},
+	}
+
+	anyCustom := len(c.safetyRequireTwoGCCycles) > 0
+
+	for _, d := range durations {
+		if len(d.flag) > 0 {
+			anyCustom = true
+		}
+	}
+
+	if !anyCustom {
+		if p.SafetyProfile == maintenance.SafetyProfileCustom && p.CustomSafety == nil {
+			return errors.Errorf("custom safety profile requires at least one custom safety parameter")
+		}
+
+		return nil
+	}
+
+	if c.safetyProfile != "" && c.safetyProfile != maintenance.SafetyProfileCustom {
+		return errors.Errorf("custom safety parameters can't be combined with --safety-profile=%v", c.safetyProfile)
+	}
+
+	if p.SafetyProfileOrDefault() != maintenance.SafetyProfileCustom || p.CustomSafety == nil {
+		// start with parameters of the profile that is currently in effect.
+		base, err := p.Safety()
+		if err != nil {
+			base = maintenance.SafetyFull
+		}
+
+		p.CustomSafety = &base
+	}
+
+	p.SafetyProfile = maintenance.SafetyProfileCustom
+
+	for _, d := range durations {
+		if len(d.flag) > 0 {
+			*d.ptr(p.CustomSafety) = d.flag[len(d.flag)-1]
+		}
+	}
+
+	if v := c.safetyRequireTwoGCCycles; len(v) > 0 {
+		p.CustomSafety.RequireTwoGCCycles = v[len(v)-1]
+	}
+
+	*changed = true
+
+	log(ctx).Infof("Setting custom maintenance safety parameters.")
+
+	return errors.Wrap(maintenance.ValidateSafetyParameters(*p.CustomSafety), "invalid safety parameters")
+}
+
+func (c *commandMaintenanceSet) setLogRetentionFromFlags(ctx context.Context, p *maintenance.Params, changed *bool) {
+	if len(c.maxRetainedLogCount)+len(c.maxRetainedLogAge)+len(c.maxTotalRetainedLogSize) == 0 {
+		return
+	}
+
+	p.LogRetention = p.LogRetention.OrDefault()
+
+	if v := c.maxRetainedLogCount; len(v) > 0 {
+		p.LogRetention.MaxCount = v[len(v)-1]
+		*changed = true
+
+		log(ctx).Infof("Setting maximum number of retained log blobs to %v.", p.LogRetention.MaxCount)
+	}
+
+	if v := c.maxRetainedLogAge; len(v) > 0 {
+		p.LogRetention.MaxAge = v[len(v)-1]
+		*changed = true
+
+		log(ctx).Infof("Setting maximum age of retained log blobs to %v.", p.LogRetention.MaxAge)
+	}
+
+	if v := c.maxTotalRetainedLogSize; len(v) > 0 {
+		p.LogRetention.MaxTotalSize = v[len(v)-1] << 20 //nolint:gomnd
+		*changed = true
+
+		log(ctx).Infof("Setting maximum total size of retained log blobs to %v.", units.BytesStringBase2(p.LogRetention.MaxTotalSize))
+	}
+}
+
+func (c *commandMaintenanceSet) setScrubPercentFromFlags(ctx context.Context, p *maintenance.Params, changed *bool) error {
+	if v := c.maintenanceSetScrubPercent; len(v) > 0 {
+		pct := v[len(v)-1]
+		if pct <= 0 || pct > 100 {
+			return errors.Errorf("scrub percentage must be greater than 0 and at most 100")
+		}
+
+		p.Scrub.Percent = pct
+		*changed = true
+
+		log(ctx).Infof("Scrub will verify %v%% of pack blobs in each cycle.", pct)
+	}
+
+	return nil
+}
+
 func (c *commandMaintenanceSet) setMaintenanceOwnerFromFlags(ctx context.Context, p *maintenance.Params, rep repo.DirectRepositoryWriter, changed *bool) {
 	if v := c.maintenanceSetOwner; v != "" {
 		if v == "me" {
@@ -75,7 +240,42 @@ func (c *commandMaintenanceSet) setMaintenanceEnabledAndIntervalFromFlags(ctx co
 	}
 }
 
+func (c *commandMaintenanceSet) runTakeOwnership(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	newOwner := c.maintenanceSetOwner
+	if newOwner == "" || newOwner == "me" {
+		newOwner = rep.ClientOptions().UsernameAtHost()
+	}
+
+	wait := c.takeOwnershipWait
+	if wait == 0 {
+		p, err := maintenance.GetParams(ctx, rep)
+		if err != nil {
+			return errors.Wrap(err, "unable to get current parameters")
+		}
+
+		if sp, err := p.Safety(); err == nil {
+			wait = sp.MaxClockSkew
+		}
+
+		if wait == 0 {
+			wait = repo.MaxClockSkew
+		}
+	}
+
+	if err := maintenance.TakeOwnership(ctx, rep, newOwner, wait); err != nil {
+		return errors.Wrap(err, "unable to take over maintenance ownership")
+	}
+
+	log(ctx).Infof("Maintenance owner is now %v", newOwner)
+
+	return nil
+}
+
 func (c *commandMaintenanceSet) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	if c.takeOwnership {
+		return c.runTakeOwnership(ctx, rep)
+	}
+
 	p, err := maintenance.GetParams(ctx, rep)
 	if err != nil {
 		return errors.Wrap(err, "unable to get current parameters")
@@ -91,6 +291,23 @@ func (c *commandMaintenanceSet) run(ctx context.Context, rep repo.DirectReposito
 	c.setMaintenanceOwnerFromFlags(ctx, p, rep, &changedParams)
 	c.setMaintenanceEnabledAndIntervalFromFlags(ctx, &p.QuickCycle, "quick", c.maintenanceSetEnableQuick, c.maintenanceSetQuickFrequency, &changedParams)
 	c.setMaintenanceEnabledAndIntervalFromFlags(ctx, &p.FullCycle, "full", c.maintenanceSetEnableFull, c.maintenanceSetFullFrequency, &changedParams)
+	c.setMaintenanceEnabledAndIntervalFromFlags(ctx, &p.Scrub.CycleParams, "scrub", c.maintenanceSetEnableScrub, c.maintenanceSetScrubInterval, &changedParams)
+	c.setLogRetentionFromFlags(ctx, p, &changedParams)
+
+	if v := c.rewriteCompressionDuplicates; len(v) > 0 {
+		p.RewriteCompressionDuplicates = v[len(v)-1]
+		changedParams = true
+
+		log(ctx).Infof("Rewriting of compression duplicates during full maintenance set to %v.", p.RewriteCompressionDuplicates)
+	}
+
+	if err := c.setScrubPercentFromFlags(ctx, p, &changedParams); err != nil {
+		return err
+	}
+
+	if err := c.setSafetyFromFlags(ctx, p, &changedParams); err != nil {
+		return err
+	}
 
 	if v := c.maintenanceSetPauseQuick; len(v) > 0 {
 		pauseDuration := v[len(v)-1]
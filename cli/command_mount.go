@@ -2,6 +2,9 @@
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/skratchdot/open-golang/open"
@@ -22,6 +25,11 @@ type commandMount struct {
 	mountFuseAllowOther         bool
 	mountFuseAllowNonEmptyMount bool
 	mountPreferWebDAV           bool
+	mountFuseAttrTimeout        time.Duration
+	mountFuseEntryTimeout       time.Duration
+	mountFuseMaxReadAhead       int
+	mountFuseNegativeLookups    int
+	mountScratchDir             string
 	maxCachedEntries            int
 	maxCachedDirectories        int
 }
@@ -36,6 +44,11 @@ func (c *commandMount) setup(svc appServices, parent commandParent) {
 
 	cmd.Flag("fuse-allow-other", "Allows other users to access the file system.").BoolVar(&c.mountFuseAllowOther)
 	cmd.Flag("fuse-allow-non-empty-mount", "Allows the mounting over a non-empty directory. The files in it will be shadowed by the freshly created mount.").BoolVar(&c.mountFuseAllowNonEmptyMount)
+	cmd.Flag("fuse-attr-timeout", "How long the kernel caches file attributes.").Default("30s").DurationVar(&c.mountFuseAttrTimeout)
+	cmd.Flag("fuse-entry-timeout", "How long the kernel caches directory entries and missing names.").Default("30s").DurationVar(&c.mountFuseEntryTimeout)
+	cmd.Flag("fuse-max-readahead", "Maximum number of bytes the kernel reads ahead (0 = kernel default).").PlaceHolder("BYTES").IntVar(&c.mountFuseMaxReadAhead)
+	cmd.Flag("fuse-negative-lookup-cache", "Number of missing names remembered per directory (0 = disabled).").Default("1000").IntVar(&c.mountFuseNegativeLookups)
+	cmd.Flag("scratch-dir", "Make the mount writable by storing modified and new files in the provided local directory, the repository is never modified (FUSE only).").PlaceHolder("DIR").StringVar(&c.mountScratchDir)
 	cmd.Flag("webdav", "Use WebDAV to mount the repository object regardless of fuse availability.").BoolVar(&c.mountPreferWebDAV)
 
 	cmd.Flag("max-cached-entries", "Limit the number of cached directory entries").Default("100000").IntVar(&c.maxCachedEntries)
@@ -51,6 +64,23 @@ func (c *commandMount) newFSCache() cachefs.DirectoryCacher {
 	})
 }
 
+func (c *commandMount) scratchDir() (string, error) {
+	if c.mountScratchDir == "" {
+		return "", nil
+	}
+
+	dir, err := filepath.Abs(c.mountScratchDir)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid scratch directory")
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil { //nolint:gomnd
+		return "", errors.Wrap(err, "unable to create scratch directory")
+	}
+
+	return dir, nil
+}
+
 func (c *commandMount) run(ctx context.Context, rep repo.Repository) error {
 	var entry fs.Directory
 
@@ -72,11 +102,21 @@ func (c *commandMount) run(ctx context.Context, rep repo.Repository) error {
 	// nolint:forcetypeassert
 	entry = cachefs.Wrap(entry, c.newFSCache()).(fs.Directory)
 
+	scratchDir, err := c.scratchDir()
+	if err != nil {
+		return err
+	}
+
 	ctrl, mountErr := mount.Directory(ctx, entry, c.mountPoint,
 		mount.Options{
-			FuseAllowOther:         c.mountFuseAllowOther,
-			FuseAllowNonEmptyMount: c.mountFuseAllowNonEmptyMount,
-			PreferWebDAV:           c.mountPreferWebDAV,
+			FuseAllowOther:              c.mountFuseAllowOther,
+			FuseAllowNonEmptyMount:      c.mountFuseAllowNonEmptyMount,
+			PreferWebDAV:                c.mountPreferWebDAV,
+			FuseAttrTimeout:             c.mountFuseAttrTimeout,
+			FuseEntryTimeout:            c.mountFuseEntryTimeout,
+			FuseMaxReadAhead:            c.mountFuseMaxReadAhead,
+			FuseNegativeLookupCacheSize: c.mountFuseNegativeLookups,
+			ScratchDir:                  scratchDir,
 		})
 
 	if mountErr != nil {
@@ -85,6 +125,10 @@ func (c *commandMount) run(ctx context.Context, rep repo.Repository) error {
 
 	log(ctx).Infof("Mounted '%v' on %v", c.mountObjectID, ctrl.MountPath())
 
+	if scratchDir != "" {
+		log(ctx).Infof("Modifications are stored in %v and are not saved to the repository.", scratchDir)
+	}
+
 	if c.mountPoint == "*" && !c.mountPointBrowse {
 		log(ctx).Infof("HINT: Pass --browse to automatically open file browser.")
 	}
@@ -12,19 +12,25 @@
 )
 
 type commandPolicy struct {
-	edit   commandPolicyEdit
-	list   commandPolicyList
-	delete commandPolicyDelete
-	set    commandPolicySet
-	show   commandPolicyShow
+	edit    commandPolicyEdit
+	export  commandPolicyExport
+	imp     commandPolicyImport
+	list    commandPolicyList
+	delete  commandPolicyDelete
+	preview commandPolicyRetentionPreview
+	set     commandPolicySet
+	show    commandPolicyShow
 }
 
 func (c *commandPolicy) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("policy", "Commands to manipulate snapshotting policies.").Alias("policies")
 
 	c.edit.setup(svc, cmd)
+	c.export.setup(svc, cmd)
+	c.imp.setup(svc, cmd)
 	c.list.setup(svc, cmd)
 	c.delete.setup(svc, cmd)
+	c.preview.setup(svc, cmd)
 	c.set.setup(svc, cmd)
 	c.show.setup(svc, cmd)
 }
@@ -25,12 +25,14 @@
 `
 
 const policyEditRetentionHelpText = `  # Retention for snapshots of this directory. Options include:
+  #   "template": "default" | "gfs" | "gfs-extended" | "minimal"
   #   "keepLatest": number
   #   "keepDaily": number
   #   "keepHourly": number
   #   "keepWeekly": number
   #   "keepMonthly": number
   #   "keepAnnual": number
+  #   "maxRetainedSize": number
 `
 
 const policyEditFilesHelpText = `
@@ -47,7 +49,13 @@
   # Snapshot scheduling options. Options include:
   #   "intervalSeconds": number /* 86400-day, 3600-hour, 60-minute */
   #   "timeOfDay": [{"hour":H,"min":M},{"hour":H,"min":M}]
-  #   "manual": false /* Only create snapshots manually if set to true. NOTE: cannot be used with the above two fields */
+  #   "cron": ["30 2 * * 1-5", "CRON_TZ=Europe/Berlin 0 12 * * sat"]
+  #   "manual": false /* Only create snapshots manually if set to true. NOTE: cannot be used with the above fields */
+`
+
+const policyEditUploadHelpText = `
+  # Upload options. Options include:
+  #   "maxUploadSpeedBytesPerSecond": number
 `
 
 type commandPolicyEdit struct {
@@ -59,7 +67,7 @@ type commandPolicyEdit struct {
 
 func (c *commandPolicyEdit) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("edit", "Set snapshot policy for a single directory, user@host or a global policy.")
-	cmd.Arg("target", "Target of a policy ('global','user@host','@host') or a path").StringsVar(&c.targets)
+	cmd.Arg("target", "Target of a policy ('global','user@host','@host','user@') or a path").StringsVar(&c.targets)
 	cmd.Flag("global", "Set global policy").BoolVar(&c.global)
 	cmd.Action(svc.repositoryWriterAction(c.run))
 	c.out.setup(svc)
@@ -83,6 +91,7 @@ func (c *commandPolicyEdit) run(ctx context.Context, rep repo.RepositoryWriter)
 		s = insertHelpText(s, `  "retention": {`, policyEditRetentionHelpText)
 		s = insertHelpText(s, `  "files": {`, policyEditFilesHelpText)
 		s = insertHelpText(s, `  "scheduling": {`, policyEditSchedulingHelpText)
+		s = insertHelpText(s, `  "upload": {`, policyEditUploadHelpText)
 
 		var updated *policy.Policy
 
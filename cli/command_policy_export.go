@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+const (
+	policyDocumentFormatYAML = "yaml"
+	policyDocumentFormatJSON = "json"
+)
+
+type commandPolicyExport struct {
+	targets []string
+	global  bool
+	all     bool
+	output  string
+	format  string
+
+	out textOutput
+}
+
+func (c *commandPolicyExport) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("export", "Export policies to a YAML or JSON document, which can be applied using 'policy import'.")
+	cmd.Arg("target", "Target of a policy ('global','user@host','@host','user@') or a path").StringsVar(&c.targets)
+	cmd.Flag("global", "Export global policy").BoolVar(&c.global)
+	cmd.Flag("all", "Export all policies").BoolVar(&c.all)
+	cmd.Flag("output", "File to write, standard output if not specified").Short('o').StringVar(&c.output)
+	cmd.Flag("format", "Document format").Default(policyDocumentFormatYAML).EnumVar(&c.format, policyDocumentFormatYAML, policyDocumentFormatJSON)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandPolicyExport) run(ctx context.Context, rep repo.Repository) error {
+	doc, err := c.policiesToExport(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	b, err := marshalPolicyDocument(doc, c.format)
+	if err != nil {
+		return err
+	}
+
+	if c.output == "" {
+		_, err = c.out.stdout().Write(b)
+
+		return errors.Wrap(err, "error writing policies")
+	}
+
+	if err := ioutil.WriteFile(c.output, b, 0o600); err != nil { //nolint:gomnd
+		return errors.Wrap(err, "error writing policies")
+	}
+
+	log(ctx).Infof("Exported %v policies to %v.", len(doc.Policies), c.output)
+
+	return nil
+}
+
+func (c *commandPolicyExport) policiesToExport(ctx context.Context, rep repo.Repository) (*policy.Document, error) {
+	if c.all {
+		if c.global || len(c.targets) > 0 {
+			return nil, errors.New("--all can't be combined with '--global' or path targets")
+		}
+
+		// nolint:wrapcheck
+		return policy.ExportPolicies(ctx, rep)
+	}
+
+	targets, err := policyTargets(ctx, rep, c.global, c.targets)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &policy.Document{}
+
+	for _, target := range targets {
+		pol, err := policy.GetDefinedPolicy(ctx, rep, target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't get defined policy for %q", target)
+		}
+
+		doc.Policies = append(doc.Policies, &policy.TargetPolicy{Target: target, Policy: pol})
+	}
+
+	return doc, nil
+}
+
+// marshalPolicyDocument serializes the document in the provided format, YAML documents use the same
+// field names as JSON.
+func marshalPolicyDocument(doc *policy.Document, format string) ([]byte, error) {
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "error serializing policies")
+	}
+
+	if format == policyDocumentFormatJSON {
+		return append(b, '\n'), nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "error decoding policies")
+	}
+
+	b, err = yaml.Marshal(jsonNumbersToYAML(v))
+
+	return b, errors.Wrap(err, "error serializing policies as YAML")
+}
+
+// unmarshalPolicyDocument parses the document in YAML or JSON format, rejecting unknown fields.
+func unmarshalPolicyDocument(b []byte) (*policy.Document, error) {
+	var v interface{}
+
+	// JSON is a subset of YAML, so both formats can be parsed as YAML.
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, errors.Wrap(err, "unable to parse policy document")
+	}
+
+	jb, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to convert policy document")
+	}
+
+	doc := &policy.Document{}
+
+	d := json.NewDecoder(bytes.NewReader(jb))
+	d.DisallowUnknownFields()
+
+	if err := d.Decode(doc); err != nil {
+		return nil, errors.Wrap(err, "invalid policy document")
+	}
+
+	return doc, nil
+}
+
+// jsonNumbersToYAML replaces json.Number values with integers or floats, so they are serialized as YAML numbers.
+func jsonNumbersToYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, mv := range v {
+			v[k] = jsonNumbersToYAML(mv)
+		}
+
+		return v
+
+	case []interface{}:
+		for i, sv := range v {
+			v[i] = jsonNumbersToYAML(sv)
+		}
+
+		return v
+
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+
+		return v.String()
+
+	default:
+		return v
+	}
+}
+
+func readPolicyDocument(fname string) (*policy.Document, error) {
+	var (
+		b   []byte
+		err error
+	)
+
+	if fname == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(fname) //nolint:gosec
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read policy document")
+	}
+
+	return unmarshalPolicyDocument(b)
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type commandPolicyImport struct {
+	file         string
+	dryRun       bool
+	deleteOthers bool
+
+	out textOutput
+}
+
+func (c *commandPolicyImport) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("import", "Import policies from a YAML or JSON document produced by 'policy export'.")
+	cmd.Arg("file", "Document to import ('-' for standard input)").Required().StringVar(&c.file)
+	cmd.Flag("dry-run", "Only show changes that would be made").Short('n').BoolVar(&c.dryRun)
+	cmd.Flag("delete-other-policies", "Delete policies of targets not present in the document").BoolVar(&c.deleteOthers)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandPolicyImport) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	doc, err := readPolicyDocument(c.file)
+	if err != nil {
+		return err
+	}
+
+	changes, err := policy.ImportPolicies(ctx, rep, doc, policy.ImportOptions{
+		DeleteOthers: c.deleteOthers,
+		DryRun:       c.dryRun,
+	})
+
+	for _, ch := range changes {
+		c.out.printStdout("%v %v\n", ch.Action, ch.Target)
+
+		for _, d := range ch.Differences {
+			c.out.printStdout("  %v\n", d)
+		}
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "error importing policies")
+	}
+
+	if c.dryRun {
+		c.out.printStdout("Dry run, no changes were made.\n")
+	}
+
+	return nil
+}
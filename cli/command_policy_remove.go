@@ -17,7 +17,7 @@ type commandPolicyDelete struct {
 
 func (c *commandPolicyDelete) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("delete", "Remove snapshot policy for a single directory, user@host or a global policy.").Alias("remove").Alias("rm")
-	cmd.Arg("target", "Target of a policy ('global','user@host','@host') or a path").StringsVar(&c.targets)
+	cmd.Arg("target", "Target of a policy ('global','user@host','@host','user@') or a path").StringsVar(&c.targets)
 	cmd.Flag("global", "Set global policy").BoolVar(&c.global)
 	cmd.Flag("dry-run", "Do not remove").Short('n').BoolVar(&c.dryRun)
 	cmd.Action(svc.repositoryWriterAction(c.run))
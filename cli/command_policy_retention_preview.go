@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type commandPolicyRetentionPreview struct {
+	targets   []string
+	templates []string
+	verbose   bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandPolicyRetentionPreview) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("retention-preview", "Preview which existing snapshots would be kept or deleted by retention templates.")
+	cmd.Arg("target", "Sources to preview retention for").Required().StringsVar(&c.targets)
+	cmd.Flag("template", "Retention templates to preview (all templates if not specified)").EnumsVar(&c.templates, policy.RetentionTemplateNames()...)
+	cmd.Flag("verbose", "Show individual snapshots").Short('v').BoolVar(&c.verbose)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandPolicyRetentionPreview) run(ctx context.Context, rep repo.Repository) error {
+	targets, err := policyTargets(ctx, rep, false, c.targets)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		previews, err := policy.PreviewRetentionTemplates(ctx, rep, target, c.templates...)
+		if err != nil {
+			return errors.Wrapf(err, "unable to preview retention for %v", target)
+		}
+
+		if c.jo.jsonOutput {
+			c.out.printStdout("%s\n", c.jo.jsonBytes(previews))
+			continue
+		}
+
+		c.out.printStdout("%v\n", target)
+
+		for _, p := range previews {
+			c.out.printStdout("  %-14v keep %v, delete %v\n", p.Template, p.KeepCount, p.DeleteCount)
+
+			if !c.verbose {
+				continue
+			}
+
+			for _, s := range p.Snapshots {
+				if s.Keep {
+					c.out.printStdout("    %v keep   %v\n", formatTimestamp(s.StartTime), s.Reasons)
+				} else {
+					c.out.printStdout("    %v delete\n", formatTimestamp(s.StartTime))
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -23,11 +23,12 @@ type commandPolicySet struct {
 	policyFilesFlags
 	policyRetentionFlags
 	policySchedulingFlags
+	policyUploadFlags
 }
 
 func (c *commandPolicySet) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("set", "Set snapshot policy for a single directory, user@host or a global policy.")
-	cmd.Arg("target", "Target of a policy ('global','user@host','@host') or a path").StringsVar(&c.targets)
+	cmd.Arg("target", "Target of a policy ('global','user@host','@host','user@') or a path").StringsVar(&c.targets)
 	cmd.Flag("global", "Set global policy").BoolVar(&c.global)
 	cmd.Flag(inheritPolicyString, "Enable or disable inheriting policies from the parent").BoolListVar(&c.inherit)
 
@@ -37,6 +38,7 @@ func (c *commandPolicySet) setup(svc appServices, parent commandParent) {
 	c.policyFilesFlags.setup(cmd)
 	c.policyRetentionFlags.setup(cmd)
 	c.policySchedulingFlags.setup(cmd)
+	c.policyUploadFlags.setup(cmd)
 
 	cmd.Action(svc.repositoryWriterAction(c.run))
 }
@@ -104,6 +106,10 @@ func (c *commandPolicySet) setPolicyFromFlags(ctx context.Context, p *policy.Pol
 		return errors.Wrap(err, "scheduling policy")
 	}
 
+	if err := c.setUploadPolicyFromFlags(ctx, &p.UploadPolicy, changeCount); err != nil {
+		return errors.Wrap(err, "upload policy")
+	}
+
 	if err := c.setActionsFromFlags(ctx, &p.Actions, changeCount); err != nil {
 		return errors.Wrap(err, "actions policy")
 	}
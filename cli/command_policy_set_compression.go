@@ -15,6 +15,8 @@ type policyCompressionFlags struct {
 	policySetCompressionMinSize   string
 	policySetCompressionMaxSize   string
 
+	policySetParallelCompression string
+
 	policySetAddOnlyCompress    []string
 	policySetRemoveOnlyCompress []string
 	policySetClearOnlyCompress  bool
@@ -29,6 +31,7 @@ func (c *policyCompressionFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("compression", "Compression algorithm").EnumVar(&c.policySetCompressionAlgorithm, supportedCompressionAlgorithms()...)
 	cmd.Flag("compression-min-size", "Min size of file to attempt compression for").StringVar(&c.policySetCompressionMinSize)
 	cmd.Flag("compression-max-size", "Max size of file to attempt compression for").StringVar(&c.policySetCompressionMaxSize)
+	cmd.Flag("parallel-compression", "Maximum number of chunks of a single file to compress in parallel (default: number of CPUs, 1 disables)").PlaceHolder("N").StringVar(&c.policySetParallelCompression)
 
 	// Files to only compress.
 	cmd.Flag("add-only-compress", "List of extensions to add to the only-compress list").PlaceHolder("PATTERN").StringsVar(&c.policySetAddOnlyCompress)
@@ -50,6 +53,14 @@ func (c *policyCompressionFlags) setCompressionPolicyFromFlags(ctx context.Conte
 		return errors.Wrap(err, "maximum file size subject to compression")
 	}
 
+	if err := applyPolicyNumber(ctx, "number of chunks compressed in parallel", &p.ParallelCompression, c.policySetParallelCompression, changeCount); err != nil {
+		return errors.Wrap(err, "number of chunks compressed in parallel")
+	}
+
+	if err := policy.ValidateCompressionPolicy(*p); err != nil {
+		return errors.Wrap(err, "invalid --parallel-compression")
+	}
+
 	if v := c.policySetCompressionAlgorithm; v != "" {
 		*changeCount++
 
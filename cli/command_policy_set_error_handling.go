@@ -11,12 +11,16 @@
 
 type policyErrorFlags struct {
 	policyIgnoreFileErrors      string
+	policyFileErrorAction       string
+	policyFileErrorRetries      string
 	policyIgnoreDirectoryErrors string
 	policyIgnoreUnknownTypes    string
 }
 
 func (c *policyErrorFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("ignore-file-errors", "Ignore errors reading files while traversing ('true', 'false', 'inherit')").EnumVar(&c.policyIgnoreFileErrors, booleanEnumValues...)
+	cmd.Flag("file-error-action", "Action to take when a file can't be read, takes precedence over --ignore-file-errors ('fail', 'skip', 'inherit')").EnumVar(&c.policyFileErrorAction, supportedFileErrorActions()...)
+	cmd.Flag("file-error-retries", "Number of times to retry reading a file before applying the file error action (or 'inherit')").PlaceHolder("N").StringVar(&c.policyFileErrorRetries)
 	cmd.Flag("ignore-dir-errors", "Ignore errors reading directories while traversing ('true', 'false', 'inherit").EnumVar(&c.policyIgnoreDirectoryErrors, booleanEnumValues...)
 	cmd.Flag("ignore-unknown-types", "Ignore unknown entry types in directories ('true', 'false', 'inherit").EnumVar(&c.policyIgnoreUnknownTypes, booleanEnumValues...)
 }
@@ -26,6 +30,29 @@ func (c *policyErrorFlags) setErrorHandlingPolicyFromFlags(ctx context.Context,
 		return errors.Wrap(err, "ignore file errors")
 	}
 
+	switch c.policyFileErrorAction {
+	case "":
+		// not changed
+
+	case inheritPolicyString:
+		*changeCount++
+
+		log(ctx).Infof(" - resetting file error action to a default value inherited from parent.\n")
+
+		fp.FileErrorAction = ""
+
+	default:
+		*changeCount++
+
+		log(ctx).Infof(" - setting file error action to %v.\n", c.policyFileErrorAction)
+
+		fp.FileErrorAction = policy.FileErrorAction(c.policyFileErrorAction)
+	}
+
+	if err := applyPolicyNumber(ctx, "file error retries", &fp.FileErrorRetries, c.policyFileErrorRetries, changeCount); err != nil {
+		return errors.Wrap(err, "file error retries")
+	}
+
 	if err := applyPolicyBoolPtr(ctx, "ignore directory errors", &fp.IgnoreDirectoryErrors, c.policyIgnoreDirectoryErrors, changeCount); err != nil {
 		return errors.Wrap(err, "ignore directory errors")
 	}
@@ -36,3 +63,13 @@ func (c *policyErrorFlags) setErrorHandlingPolicyFromFlags(ctx context.Context,
 
 	return nil
 }
+
+func supportedFileErrorActions() []string {
+	res := []string{inheritPolicyString}
+
+	for _, a := range policy.SupportedFileErrorActions {
+		res = append(res, string(a))
+	}
+
+	return res
+}
@@ -15,6 +15,9 @@ type policyRetentionFlags struct {
 	policySetKeepWeekly  string
 	policySetKeepMonthly string
 	policySetKeepAnnual  string
+
+	policySetMaxRetainedSize   string
+	policySetRetentionTemplate string
 }
 
 func (c *policyRetentionFlags) setup(cmd *kingpin.CmdClause) {
@@ -24,6 +27,8 @@ func (c *policyRetentionFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("keep-weekly", "Number of most-recent weekly backups to keep per source (or 'inherit')").PlaceHolder("N").StringVar(&c.policySetKeepWeekly)
 	cmd.Flag("keep-monthly", "Number of most-recent monthly backups to keep per source (or 'inherit')").PlaceHolder("N").StringVar(&c.policySetKeepMonthly)
 	cmd.Flag("keep-annual", "Number of most-recent annual backups to keep per source (or 'inherit')").PlaceHolder("N").StringVar(&c.policySetKeepAnnual)
+	cmd.Flag("retention-template", "Retention template providing values for counts that are not set (or 'inherit')").PlaceHolder("NAME").EnumVar(&c.policySetRetentionTemplate, append(policy.RetentionTemplateNames(), inheritPolicyString)...)
+	cmd.Flag("max-retained-size", "Maximum total size of retained snapshots per source, older snapshots above the limit are expired (or 'inherit')").PlaceHolder("BYTES").StringVar(&c.policySetMaxRetainedSize)
 }
 
 func (c *policyRetentionFlags) setRetentionPolicyFromFlags(ctx context.Context, rp *policy.RetentionPolicy, changeCount *int) error {
@@ -46,5 +51,28 @@ func (c *policyRetentionFlags) setRetentionPolicyFromFlags(ctx context.Context,
 		}
 	}
 
+	if err := applyPolicyNumber64(ctx, "maximum retained size", &rp.MaxRetainedSize, c.policySetMaxRetainedSize, changeCount); err != nil {
+		return err
+	}
+
+	switch c.policySetRetentionTemplate {
+	case "":
+		// not changed
+
+	case inheritPolicyString:
+		*changeCount++
+
+		log(ctx).Infof(" - resetting retention template to a default value inherited from parent.\n")
+
+		rp.Template = ""
+
+	default:
+		*changeCount++
+
+		log(ctx).Infof(" - setting retention template to %v.\n", c.policySetRetentionTemplate)
+
+		rp.Template = c.policySetRetentionTemplate
+	}
+
 	return nil
 }
@@ -14,12 +14,14 @@
 type policySchedulingFlags struct {
 	policySetInterval   []time.Duration // not a list, just optional duration
 	policySetTimesOfDay []string
+	policySetCron       []string
 	policySetManual     bool
 }
 
 func (c *policySchedulingFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("snapshot-interval", "Interval between snapshots").DurationListVar(&c.policySetInterval)
 	cmd.Flag("snapshot-time", "Times of day when to take snapshot (HH:mm)").StringsVar(&c.policySetTimesOfDay)
+	cmd.Flag("snapshot-cron", "Cron expression when to take snapshot, optionally prefixed with CRON_TZ=<zone> (or 'inherit')").PlaceHolder("EXPR").StringsVar(&c.policySetCron)
 	cmd.Flag("manual", "Only create snapshots manually").BoolVar(&c.policySetManual)
 }
 
@@ -71,6 +73,33 @@ func (c *policySchedulingFlags) setScheduleFromFlags(ctx context.Context, sp *po
 		}
 	}
 
+	if len(c.policySetCron) > 0 {
+		var exprs []string
+
+		for _, expr := range c.policySetCron {
+			if expr == inheritPolicyString {
+				exprs = nil
+				break
+			}
+
+			if err := policy.ValidateCronExpression(expr); err != nil {
+				return errors.Wrap(err, "unable to parse cron expression")
+			}
+
+			exprs = append(exprs, expr)
+		}
+
+		*changeCount++
+
+		sp.Cron = policy.SortAndDedupeCronExpressions(exprs)
+
+		if exprs == nil {
+			log(ctx).Infof(" - resetting snapshot cron expressions to default\n")
+		} else {
+			log(ctx).Infof(" - setting snapshot cron expressions to %v\n", exprs)
+		}
+	}
+
 	if sp.Manual {
 		*changeCount++
 
@@ -84,7 +113,7 @@ func (c *policySchedulingFlags) setScheduleFromFlags(ctx context.Context, sp *po
 
 func (c *policySchedulingFlags) setManualFromFlags(ctx context.Context, sp *policy.SchedulingPolicy, changeCount *int) error {
 	// Cannot set both schedule and manual setting
-	if len(c.policySetInterval) > 0 || len(c.policySetTimesOfDay) > 0 {
+	if len(c.policySetInterval) > 0 || len(c.policySetTimesOfDay) > 0 || len(c.policySetCron) > 0 {
 		return errors.New("cannot set manual field when scheduling snapshots")
 	}
 
@@ -105,6 +134,14 @@ func (c *policySchedulingFlags) setManualFromFlags(ctx context.Context, sp *poli
 		log(ctx).Infof(" - resetting snapshot times of day to default\n")
 	}
 
+	if len(sp.Cron) > 0 {
+		*changeCount++
+
+		sp.Cron = nil
+
+		log(ctx).Infof(" - resetting snapshot cron expressions to default\n")
+	}
+
 	*changeCount++
 
 	sp.Manual = c.policySetManual
@@ -187,6 +187,7 @@ func TestSetSchedulingPolicyFromFlags(t *testing.T) {
 		startingPolicy *policy.SchedulingPolicy
 		intervalArg    []time.Duration
 		timesOfDayArg  []string
+		cronArg        []string
 		manualArg      bool
 		expResult      *policy.SchedulingPolicy
 		expErr         bool
@@ -340,6 +341,53 @@ func TestSetSchedulingPolicyFromFlags(t *testing.T) {
 			expErr:         false,
 			expChangeCount: 2,
 		},
+		{
+			name:           "Cron expressions set, no starting policy",
+			startingPolicy: &policy.SchedulingPolicy{},
+			cronArg: []string{
+				"CRON_TZ=UTC 0 12 * * sat",
+				"30 2 * * 1-5",
+			},
+			expResult: &policy.SchedulingPolicy{
+				Cron: []string{"30 2 * * 1-5", "CRON_TZ=UTC 0 12 * * sat"},
+			},
+			expErr:         false,
+			expChangeCount: 1,
+		},
+		{
+			name:           "Invalid cron expression",
+			startingPolicy: &policy.SchedulingPolicy{},
+			cronArg: []string{
+				"30 25 * * *",
+			},
+			expResult:      &policy.SchedulingPolicy{},
+			expErr:         true,
+			expChangeCount: 0,
+		},
+		{
+			name: "Cron expressions reset",
+			startingPolicy: &policy.SchedulingPolicy{
+				Cron: []string{"30 2 * * 1-5"},
+			},
+			cronArg: []string{
+				"inherit",
+			},
+			expResult:      &policy.SchedulingPolicy{},
+			expErr:         false,
+			expChangeCount: 1,
+		},
+		{
+			name: "Manual flag set to true, starting policy with cron",
+			startingPolicy: &policy.SchedulingPolicy{
+				Cron: []string{"30 2 * * 1-5"},
+			},
+			manualArg: true,
+			expResult: &policy.SchedulingPolicy{
+				Manual: true,
+			},
+			expErr:         false,
+			expChangeCount: 2,
+		},
 	} {
 		t.Log(tc.name)
 
@@ -347,6 +395,7 @@ func TestSetSchedulingPolicyFromFlags(t *testing.T) {
 
 		psf.policySetInterval = tc.intervalArg
 		psf.policySetTimesOfDay = tc.timesOfDayArg
+		psf.policySetCron = tc.cronArg
 		psf.policySetManual = tc.manualArg
 
 		err := psf.setSchedulingPolicyFromFlags(ctx, tc.startingPolicy, &changeCount)
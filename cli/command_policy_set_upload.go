@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type policyUploadFlags struct {
+	policySetMaxUploadSpeed string
+}
+
+func (c *policyUploadFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("max-upload-speed", "Maximum speed of reading files for upload in bytes per second (or 'inherit')").PlaceHolder("BYTES_PER_SEC").StringVar(&c.policySetMaxUploadSpeed)
+}
+
+func (c *policyUploadFlags) setUploadPolicyFromFlags(ctx context.Context, p *policy.UploadPolicy, changeCount *int) error {
+	if err := applyPolicyNumber64(ctx, "maximum upload speed", &p.MaxUploadSpeedBytesPerSecond, c.policySetMaxUploadSpeed, changeCount); err != nil {
+		return errors.Wrap(err, "maximum upload speed")
+	}
+
+	return nil
+}
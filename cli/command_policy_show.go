@@ -14,16 +14,18 @@
 )
 
 type commandPolicyShow struct {
-	global  bool
-	targets []string
-	jo      jsonOutput
-	out     textOutput
+	global     bool
+	targets    []string
+	provenance bool
+	jo         jsonOutput
+	out        textOutput
 }
 
 func (c *commandPolicyShow) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("show", "Show snapshot policy.").Alias("get")
 	cmd.Flag("global", "Get global policy").BoolVar(&c.global)
 	cmd.Arg("target", "Target to show the policy for").StringsVar(&c.targets)
+	cmd.Flag("provenance", "Show the target that defined each field of the effective policy").BoolVar(&c.provenance)
 	c.jo.setup(svc, cmd)
 	c.out.setup(svc)
 	cmd.Action(svc.repositoryReaderAction(c.run))
@@ -41,9 +43,14 @@ func (c *commandPolicyShow) run(ctx context.Context, rep repo.Repository) error
 			return errors.Wrapf(err, "can't get effective policy for %q", target)
 		}
 
-		if c.jo.jsonOutput {
+		switch {
+		case c.provenance && c.jo.jsonOutput:
+			c.out.printStdout("%s\n", c.jo.jsonBytes(policy.Provenance(effective, policies)))
+		case c.provenance:
+			printPolicyProvenance(&c.out, target, policy.Provenance(effective, policies))
+		case c.jo.jsonOutput:
 			c.out.printStdout("%s\n", c.jo.jsonBytes(effective))
-		} else {
+		default:
 			printPolicy(&c.out, effective, policies)
 		}
 	}
@@ -69,6 +76,24 @@ func getDefinitionPoint(target snapshot.SourceInfo, parents []*policy.Policy, ma
 	return "(default)"
 }
 
+func printPolicyProvenance(out *textOutput, target snapshot.SourceInfo, prov []policy.FieldProvenance) {
+	out.printStdout("Effective policy fields for %v:\n\n", target)
+
+	for _, fp := range prov {
+		definedBy := "(default)"
+
+		if fp.Target != nil {
+			if *fp.Target == target {
+				definedBy = "(defined for this target)"
+			} else {
+				definedBy = "inherited from " + fp.Target.String()
+			}
+		}
+
+		out.printStdout("  %-45v %-20v %v\n", fp.Field, fp.Value, definedBy)
+	}
+}
+
 func containsString(s []string, v string) bool {
 	for _, item := range s {
 		if item == v {
@@ -92,11 +117,22 @@ func printPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
 	out.printStdout("\n")
 	printCompressionPolicy(out, p, parents)
 	out.printStdout("\n")
+	printUploadPolicy(out, p, parents)
+	out.printStdout("\n")
 	printActions(out, p, parents)
 }
 
 func printRetentionPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
 	out.printStdout("Retention:\n")
+
+	if p.RetentionPolicy.Template != "" {
+		out.printStdout("  Template:          %v  %v\n",
+			p.RetentionPolicy.Template,
+			getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+				return pol.RetentionPolicy.Template != ""
+			}))
+	}
+
 	out.printStdout("  Annual snapshots:  %3v           %v\n",
 		valueOrNotSet(p.RetentionPolicy.KeepAnnual),
 		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
@@ -127,6 +163,14 @@ func printRetentionPolicy(out *textOutput, p *policy.Policy, parents []*policy.P
 		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
 			return pol.RetentionPolicy.KeepLatest != nil
 		}))
+
+	if maxSize := p.RetentionPolicy.MaxRetainedSize; maxSize > 0 {
+		out.printStdout("  Max retained size: %v  %v\n",
+			units.BytesStringBase2(maxSize),
+			getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+				return pol.RetentionPolicy.MaxRetainedSize != 0
+			}))
+	}
 }
 
 func printFilesPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
@@ -186,6 +230,18 @@ func printErrorHandlingPolicy(out *textOutput, p *policy.Policy, parents []*poli
 			return pol.ErrorHandlingPolicy.IgnoreFileErrors != nil
 		}))
 
+	out.printStdout("  File read error action:        %5v       %v\n",
+		p.ErrorHandlingPolicy.FileErrorActionOrDefault(policy.FileErrorActionFail),
+		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+			return pol.ErrorHandlingPolicy.FileErrorAction != "" || pol.ErrorHandlingPolicy.IgnoreFileErrors != nil
+		}))
+
+	out.printStdout("  File read retries:             %5v       %v\n",
+		p.ErrorHandlingPolicy.FileErrorRetriesOrDefault(0),
+		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+			return pol.ErrorHandlingPolicy.FileErrorRetries != nil
+		}))
+
 	out.printStdout("  Ignore directory read errors:  %5v       %v\n",
 		p.ErrorHandlingPolicy.IgnoreDirectoryErrorsOrDefault(false),
 		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
@@ -233,6 +289,19 @@ func printSchedulingPolicy(out *textOutput, p *policy.Policy, parents []*policy.
 		any = true
 	}
 
+	if len(p.SchedulingPolicy.Cron) > 0 {
+		out.printStdout("    Snapshot cron expressions:\n")
+
+		for _, expr := range p.SchedulingPolicy.Cron {
+			expr := expr
+			out.printStdout("      %-30v %v\n", expr, getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+				return containsString(pol.SchedulingPolicy.Cron, expr)
+			}))
+		}
+
+		any = true
+	}
+
 	if !any {
 		out.printStdout("    None\n")
 	}
@@ -244,6 +313,21 @@ func printSchedulingPolicy(out *textOutput, p *policy.Policy, parents []*policy.
 		}))
 }
 
+func printUploadPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
+	speed := p.UploadPolicy.MaxUploadSpeedBytesPerSecond
+	if speed <= 0 {
+		out.printStdout("Upload speed not limited.\n")
+		return
+	}
+
+	out.printStdout("Upload:\n")
+	out.printStdout("  Max upload speed: %v/s  %v\n",
+		units.BytesStringBase2(speed),
+		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+			return pol.UploadPolicy.MaxUploadSpeedBytesPerSecond != 0
+		}))
+}
+
 func printCompressionPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
 	if p.CompressionPolicy.CompressorName != "" && p.CompressionPolicy.CompressorName != "none" {
 		out.printStdout("Compression:\n")
@@ -290,6 +374,12 @@ func printCompressionPolicy(out *textOutput, p *policy.Policy, parents []*policy
 	default:
 		out.printStdout("  Compress files of all sizes.\n")
 	}
+
+	if v := p.CompressionPolicy.ParallelCompression; v != nil {
+		out.printStdout("  Parallel compression: %v chunks %v\n", *v, getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+			return pol.CompressionPolicy.ParallelCompression != nil
+		}))
+	}
 }
 
 func printActions(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
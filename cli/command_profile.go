@@ -0,0 +1,13 @@
+package cli
+
+type commandProfile struct {
+	list commandProfileList
+	sw   commandProfileSwitch
+}
+
+func (c *commandProfile) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("profile", "Commands to manage repository connection profiles.")
+
+	c.list.setup(svc, cmd)
+	c.sw.setup(svc, cmd)
+}
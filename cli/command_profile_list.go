@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/internal/ospath"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandProfileList struct {
+	out textOutput
+}
+
+func (c *commandProfileList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List connection profiles").Alias("ls")
+	cmd.Action(svc.noRepositoryAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandProfileList) run(ctx context.Context) error {
+	dir := ospath.ConfigDir()
+
+	names, err := listConnectionProfiles(dir)
+	if err != nil {
+		return err
+	}
+
+	active, err := readActiveConnectionProfile(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		marker := " "
+		if name == active {
+			marker = "*"
+		}
+
+		c.out.printStdout("%v %-20v %v\n", marker, name, describeConnectionProfile(connectionProfileConfigFile(dir, name)))
+	}
+
+	return nil
+}
+
+func describeConnectionProfile(configFile string) string {
+	lc, err := repo.LoadConfigFromFile(configFile)
+	if err != nil {
+		return "(invalid config: " + err.Error() + ")"
+	}
+
+	target := "(unknown)"
+
+	switch {
+	case lc.APIServer != nil:
+		target = "server " + lc.APIServer.BaseURL
+	case lc.Storage != nil:
+		target = lc.Storage.Type
+	}
+
+	if lc.Description == "" {
+		return target
+	}
+
+	return lc.Description + " (" + target + ")"
+}
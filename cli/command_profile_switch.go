@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/ospath"
+)
+
+type commandProfileSwitch struct {
+	name string
+}
+
+func (c *commandProfileSwitch) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("switch", "Select the connection profile used when --profile and --config-file are not specified")
+	cmd.Arg("name", "Name of the profile").Required().StringVar(&c.name)
+	cmd.Action(svc.noRepositoryAction(c.run))
+}
+
+func (c *commandProfileSwitch) run(ctx context.Context) error {
+	if err := validateConnectionProfileName(c.name); err != nil {
+		return err
+	}
+
+	dir := ospath.ConfigDir()
+
+	if _, err := os.Stat(connectionProfileConfigFile(dir, c.name)); err != nil {
+		return errors.Wrapf(err, "profile %q is not connected to a repository, use 'kopia repository connect --profile=%v'", c.name, c.name)
+	}
+
+	if err := writeActiveConnectionProfile(dir, c.name); err != nil {
+		return err
+	}
+
+	log(ctx).Infof("Switched to profile %v.", c.name)
+
+	return nil
+}
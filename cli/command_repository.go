@@ -1,25 +1,53 @@
 package cli
 
 type commandRepository struct {
-	connect    commandRepositoryConnect
-	create     commandRepositoryCreate
-	disconnect commandRepositoryDisconnect
-	repair     commandRepositoryRepair
-	setClient  commandRepositorySetClient
-	status     commandRepositoryStatus
-	syncTo     commandRepositorySyncTo
-	upgrade    commandRepositoryUpgrade
+	applyBlobs      commandRepositoryApplyBlobs
+	auditRetention  commandRepositoryAuditRetention
+	changePassword  commandRepositoryChangePassword
+	connect         commandRepositoryConnect
+	create          commandRepositoryCreate
+	disconnect      commandRepositoryDisconnect
+	exportBlobs     commandRepositoryExportBlobs
+	grantRestore    commandRepositoryGrantRestoreAccess
+	metadataReplica commandRepositoryMetadataReplica
+	readReplica     commandRepositoryReadReplica
+	recoverDeleted  commandRepositoryRecoverDeleted
+	repair          commandRepositoryRepair
+	setClient       commandRepositorySetClient
+	setParams       commandRepositorySetParameters
+	stats           commandRepositoryStats
+	status          commandRepositoryStatus
+	syncTo          commandRepositorySyncTo
+	upgrade         commandRepositoryUpgrade
+
+	validateProvider commandRepositoryValidateProvider
+
+	freeze   commandRepositoryFreeze
+	unfreeze commandRepositoryUnfreeze
 }
 
 func (c *commandRepository) setup(svc advancedAppServices, parent commandParent) {
 	cmd := parent.Command("repository", "Commands to manipulate repository.").Alias("repo")
 
+	c.applyBlobs.setup(svc, cmd)
+	c.auditRetention.setup(svc, cmd)
+	c.changePassword.setup(svc, cmd)
 	c.connect.setup(svc, cmd)
 	c.create.setup(svc, cmd)
 	c.disconnect.setup(svc, cmd)
+	c.exportBlobs.setup(svc, cmd)
+	c.grantRestore.setup(svc, cmd)
+	c.metadataReplica.setup(svc, cmd)
+	c.readReplica.setup(svc, cmd)
+	c.recoverDeleted.setup(svc, cmd)
 	c.repair.setup(svc, cmd)
 	c.setClient.setup(svc, cmd)
+	c.setParams.setup(svc, cmd)
+	c.stats.setup(svc, cmd)
 	c.status.setup(svc, cmd)
 	c.syncTo.setup(svc, cmd)
 	c.upgrade.setup(svc, cmd)
+	c.validateProvider.setup(svc, cmd)
+	c.freeze.setup(svc, cmd)
+	c.unfreeze.setup(svc, cmd)
 }
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/blobarchive"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandRepositoryApplyBlobs struct {
+	applyArchive         string
+	applyArchivePassword string
+
+	svc appServices
+	out textOutput
+}
+
+func (c *commandRepositoryApplyBlobs) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("apply-blobs", "Apply blobs from an archive created using 'repository export-blobs' to a replica of the exported repository.")
+	cmd.Arg("archive", "Archive file to apply").Required().ExistingFileVar(&c.applyArchive)
+	cmd.Flag("archive-password", "Password used to encrypt the archive").Envar("KOPIA_ARCHIVE_PASSWORD").StringVar(&c.applyArchivePassword)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+
+	c.svc = svc
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryApplyBlobs) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	pass := c.applyArchivePassword
+	if pass == "" {
+		p, err := askPass(c.svc.stdout(), "Enter archive password: ")
+		if err != nil {
+			return errors.Wrap(err, "password entry")
+		}
+
+		pass = p
+	}
+
+	f, err := os.Open(c.applyArchive)
+	if err != nil {
+		return errors.Wrap(err, "unable to open archive")
+	}
+	defer f.Close() //nolint:errcheck,gosec
+
+	// the entire archive is verified before any blobs are written.
+	a, err := blobarchive.Open(ctx, f, pass)
+	if err != nil {
+		return errors.Wrap(err, "unable to open archive")
+	}
+
+	log(ctx).Infof("Applying %v blobs (%v) exported at %v...", a.Stats().Blobs, units.BytesStringBase10(a.Stats().Bytes), formatTimestamp(a.CreateTime()))
+
+	st, err := a.Apply(ctx, rep.BlobStorage(), rep.UniqueID())
+	if err != nil {
+		return errors.Wrap(err, "error applying blobs")
+	}
+
+	c.out.printStdout("Applied %v blobs (%v), skipped %v existing.\n", st.Written, units.BytesStringBase10(st.Bytes), st.Skipped)
+
+	return nil
+}
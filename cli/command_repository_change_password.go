@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/passwordpersist"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandRepositoryChangePassword struct {
+	newPassword string
+
+	svc advancedAppServices
+}
+
+func (c *commandRepositoryChangePassword) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("change-password", "Change repository password, which rotates the format encryption key.")
+	cmd.Flag("new-password", "New password").StringVar(&c.newPassword)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+
+	c.svc = svc
+}
+
+func (c *commandRepositoryChangePassword) run(ctx context.Context, rep repo.DirectRepository) error {
+	newPass := c.newPassword
+	if newPass == "" {
+		p, err := askForChangedRepositoryPassword(c.svc.stdout())
+		if err != nil {
+			return err
+		}
+
+		newPass = p
+	}
+
+	if err := rep.ChangePassword(ctx, newPass); err != nil {
+		return errors.Wrap(err, "unable to change password")
+	}
+
+	log(ctx).Infof("Password changed, new format key ID: %v", rep.FormatKeyStatus().Current().KeyID)
+
+	// update persisted password, if any, so that the client remains connected.
+	pps := c.svc.passwordPersistenceStrategy()
+	configFile := c.svc.repositoryConfigFileName()
+
+	if _, err := pps.GetPassword(ctx, configFile); err != nil {
+		if errors.Is(err, passwordpersist.ErrPasswordNotFound) {
+			return nil
+		}
+
+		return errors.Wrap(err, "error getting persistent password")
+	}
+
+	return errors.Wrap(pps.PersistPassword(ctx, configFile, newPass), "unable to persist new password")
+}
+
+func askForChangedRepositoryPassword(out io.Writer) (string, error) {
+	for {
+		p1, err := askPass(out, "Enter new password: ")
+		if err != nil {
+			return "", errors.Wrap(err, "password entry")
+		}
+
+		p2, err := askPass(out, "Re-enter new password for verification: ")
+		if err != nil {
+			return "", errors.Wrap(err, "password verification")
+		}
+
+		if p1 != p2 {
+			fmt.Fprintln(out, "Passwords don't match!")
+		} else {
+			return p1, nil
+		}
+	}
+}
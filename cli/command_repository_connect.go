@@ -7,9 +7,11 @@
 	"github.com/alecthomas/kingpin"
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/passwordpersist"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/presigned"
 	"github.com/kopia/kopia/repo/content"
 )
 
@@ -48,44 +50,70 @@ type connectOptions struct {
 	connectMaxCacheSizeMB         int64
 	connectMaxMetadataCacheSizeMB int64
 	connectMaxListCacheDuration   time.Duration
+	connectCacheFreeSpacePercent  int
 	connectHostname               string
 	connectUsername               string
 	connectCheckForUpdates        bool
 	connectReadonly               bool
+	connectDryRun                 bool
 	connectDescription            string
 	connectEnableActions          bool
+	connectUploadParallelism      int
+	connectCheckpointInterval     time.Duration
+	connectIgnoreClientDefaults   bool
+
+	// settings explicitly provided, which take precedence over client defaults published by the repository.
+	clientDefaultsOverrides repo.ClientDefaultsOverrides
 }
 
 func (c *connectOptions) setup(cmd *kingpin.CmdClause) {
 	// Set up flags shared between 'create' and 'connect'. Note that because those flags are used by both command
 	// we must use *Var() methods, otherwise one of the commands would always get default flag values.
 	cmd.Flag("cache-directory", "Cache directory").PlaceHolder("PATH").Envar("KOPIA_CACHE_DIRECTORY").StringVar(&c.connectCacheDirectory)
-	cmd.Flag("content-cache-size-mb", "Size of local content cache").PlaceHolder("MB").Default("5000").Int64Var(&c.connectMaxCacheSizeMB)
-	cmd.Flag("metadata-cache-size-mb", "Size of local metadata cache").PlaceHolder("MB").Default("5000").Int64Var(&c.connectMaxMetadataCacheSizeMB)
+	cmd.Flag("content-cache-size-mb", "Size of local content cache").PlaceHolder("MB").Default("5000").IsSetByUser(&c.clientDefaultsOverrides.MaxCacheSize).Int64Var(&c.connectMaxCacheSizeMB)
+	cmd.Flag("metadata-cache-size-mb", "Size of local metadata cache").PlaceHolder("MB").Default("5000").IsSetByUser(&c.clientDefaultsOverrides.MaxMetadataCacheSize).Int64Var(&c.connectMaxMetadataCacheSizeMB)
 	cmd.Flag("max-list-cache-duration", "Duration of index cache").Default("30s").Hidden().DurationVar(&c.connectMaxListCacheDuration)
+	cmd.Flag("cache-max-free-space-percent", "Limit size of each cache to percentage of free disk space").PlaceHolder("PERCENT").IntVar(&c.connectCacheFreeSpacePercent)
 	cmd.Flag("override-hostname", "Override hostname used by this repository connection").Hidden().StringVar(&c.connectHostname)
 	cmd.Flag("override-username", "Override username used by this repository connection").Hidden().StringVar(&c.connectUsername)
 	cmd.Flag("check-for-updates", "Periodically check for Kopia updates on GitHub").Default("true").Envar(checkForUpdatesEnvar).BoolVar(&c.connectCheckForUpdates)
 	cmd.Flag("readonly", "Make repository read-only to avoid accidental changes").BoolVar(&c.connectReadonly)
+	cmd.Flag("dry-run", "Report changes that commands would make to the repository storage without making them").BoolVar(&c.connectDryRun)
 	cmd.Flag("description", "Human-readable description of the repository").StringVar(&c.connectDescription)
 	cmd.Flag("enable-actions", "Allow snapshot actions").BoolVar(&c.connectEnableActions)
+	cmd.Flag("upload-parallelism", "Default number of files uploaded in parallel when creating snapshots").PlaceHolder("N").IsSetByUser(&c.clientDefaultsOverrides.UploadParallelism).IntVar(&c.connectUploadParallelism)
+	cmd.Flag("checkpoint-interval", "Default frequency of checkpoints when creating snapshots").IsSetByUser(&c.clientDefaultsOverrides.CheckpointInterval).DurationVar(&c.connectCheckpointInterval)
+	cmd.Flag("ignore-client-defaults", "Ignore client defaults published by the repository").BoolVar(&c.connectIgnoreClientDefaults)
 }
 
 func (c *connectOptions) toRepoConnectOptions() *repo.ConnectOptions {
+	var overrides *repo.ClientDefaultsOverrides
+
+	if !c.connectIgnoreClientDefaults {
+		o := c.clientDefaultsOverrides
+		overrides = &o
+	}
+
 	return &repo.ConnectOptions{
 		CachingOptions: content.CachingOptions{
 			CacheDirectory:            c.connectCacheDirectory,
 			MaxCacheSizeBytes:         c.connectMaxCacheSizeMB << 20,         //nolint:gomnd
 			MaxMetadataCacheSizeBytes: c.connectMaxMetadataCacheSizeMB << 20, //nolint:gomnd
 			MaxListCacheDurationSec:   int(c.connectMaxListCacheDuration.Seconds()),
+			FreeSpacePercent:          c.connectCacheFreeSpacePercent,
 		},
 		ClientOptions: repo.ClientOptions{
 			Hostname:      c.connectHostname,
 			Username:      c.connectUsername,
 			ReadOnly:      c.connectReadonly,
+			DryRun:        c.connectDryRun,
 			Description:   c.connectDescription,
 			EnableActions: c.connectEnableActions,
+
+			UploadParallelism:  c.connectUploadParallelism,
+			CheckpointInterval: c.connectCheckpointInterval,
 		},
+		ClientDefaultsOverrides: overrides,
 	}
 }
 
@@ -99,6 +127,13 @@ func (c *App) runConnectCommandWithStorage(ctx context.Context, co *connectOptio
 }
 
 func (c *App) runConnectCommandWithStorageAndPassword(ctx context.Context, co *connectOptions, st blob.Storage, password string) error {
+	if st.ConnectionInfo().Type == presigned.StorageType && !co.connectReadonly {
+		// pre-signed URLs only allow reads.
+		log(ctx).Infof("Connecting in read-only mode.")
+
+		co.connectReadonly = true
+	}
+
 	configFile := c.repositoryConfigFileName()
 	if err := passwordpersist.OnSuccess(
 		ctx, repo.Connect(ctx, configFile, st, password, co.toRepoConnectOptions()),
@@ -109,5 +144,22 @@ func (c *App) runConnectCommandWithStorageAndPassword(ctx context.Context, co *c
 	log(ctx).Infof("Connected to repository.")
 	c.maybeInitializeUpdateCheck(ctx, co)
 
+	if !co.connectReadonly && !co.connectDryRun {
+		warnOnClockSkew(ctx, st)
+	}
+
 	return nil
 }
+
+// warnOnClockSkew warns when local clock differs too much from storage timestamps, which breaks maintenance.
+func warnOnClockSkew(ctx context.Context, st blob.Storage) {
+	skew, err := repo.MeasureClockSkew(ctx, st, clock.Now)
+	if err != nil {
+		log(ctx).Debugf("unable to measure clock skew: %v", err)
+		return
+	}
+
+	if err := repo.CheckClockSkew(skew, repo.MaxClockSkew); err != nil {
+		log(ctx).Errorf("WARNING: %v. Maintenance will not run until the local clock is fixed.", err)
+	}
+}
@@ -16,6 +16,8 @@ type commandRepositoryConnectServer struct {
 	connectAPIServerURL             string
 	connectAPIServerCertFingerprint string
 	connectAPIServerUseGRPCAPI      bool
+	connectAPIServerClientCertFile  string
+	connectAPIServerClientKeyFile   string
 
 	svc advancedAppServices
 	out textOutput
@@ -30,6 +32,8 @@ func (c *commandRepositoryConnectServer) setup(svc advancedAppServices, parent c
 	cmd.Flag("url", "Server URL").Required().StringVar(&c.connectAPIServerURL)
 	cmd.Flag("server-cert-fingerprint", "Server certificate fingerprint").StringVar(&c.connectAPIServerCertFingerprint)
 	cmd.Flag("grpc", "Use GRPC API").Default("true").BoolVar(&c.connectAPIServerUseGRPCAPI)
+	cmd.Flag("client-cert-file", "TLS client certificate PEM file").ExistingFileVar(&c.connectAPIServerClientCertFile)
+	cmd.Flag("client-key-file", "TLS client key PEM file").ExistingFileVar(&c.connectAPIServerClientKeyFile)
 	cmd.Action(svc.noRepositoryAction(c.run))
 }
 
@@ -38,6 +42,12 @@ func (c *commandRepositoryConnectServer) run(ctx context.Context) error {
 		BaseURL:                             strings.TrimSuffix(c.connectAPIServerURL, "/"),
 		TrustedServerCertificateFingerprint: strings.ToLower(c.connectAPIServerCertFingerprint),
 		DisableGRPC:                         !c.connectAPIServerUseGRPCAPI,
+		ClientCertificateFile:               c.connectAPIServerClientCertFile,
+		ClientKeyFile:                       c.connectAPIServerClientKeyFile,
+	}
+
+	if (as.ClientCertificateFile == "") != (as.ClientKeyFile == "") {
+		return errors.Errorf("--client-cert-file and --client-key-file must be specified together")
 	}
 
 	configFile := c.svc.repositoryConfigFileName()
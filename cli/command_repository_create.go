@@ -20,7 +20,14 @@ type commandRepositoryCreate struct {
 	createBlockHashFormat       string
 	createBlockEncryptionFormat string
 	createSplitter              string
+	createFormatVersion         int
 	createOnly                  bool
+	createLabels                map[string]string
+	createBlobIntegrityFooter   bool
+	createStreamedDirectories   bool
+	createCompressedManifests   bool
+	createFIPS                  bool
+	createKeyDerivation         string
 
 	co  connectOptions
 	svc advancedAppServices
@@ -33,7 +40,15 @@ func (c *commandRepositoryCreate) setup(svc advancedAppServices, parent commandP
 	cmd.Flag("block-hash", "Content hash algorithm.").PlaceHolder("ALGO").Default(hashing.DefaultAlgorithm).EnumVar(&c.createBlockHashFormat, hashing.SupportedAlgorithms()...)
 	cmd.Flag("encryption", "Content encryption algorithm.").PlaceHolder("ALGO").Default(encryption.DefaultAlgorithm).EnumVar(&c.createBlockEncryptionFormat, encryption.SupportedAlgorithms(false)...)
 	cmd.Flag("object-splitter", "The splitter to use for new objects in the repository").Default(splitter.DefaultAlgorithm).EnumVar(&c.createSplitter, splitter.SupportedAlgorithms()...)
+	cmd.Flag("format-version", "Force a particular repository format version (1 to 6, 0 = automatic)").Default("0").IntVar(&c.createFormatVersion)
 	cmd.Flag("create-only", "Create repository, but don't connect to it.").Short('c').BoolVar(&c.createOnly)
+	c.createLabels = map[string]string{}
+	cmd.Flag("label", "Repository label (key=value), can be repeated.").StringMapVar(&c.createLabels)
+	cmd.Flag("blob-integrity-footer", "Append authenticated integrity footers to all blobs to detect truncation by storage backends").BoolVar(&c.createBlobIntegrityFooter)
+	cmd.Flag("streamed-directories", "Write directory manifests in the streamed format (requires format version 4)").BoolVar(&c.createStreamedDirectories)
+	cmd.Flag("compressed-manifests", "Compress manifests with zstd (requires format version 6)").BoolVar(&c.createCompressedManifests)
+	cmd.Flag("fips", "Restrict the repository to FIPS-approved algorithms").BoolVar(&c.createFIPS)
+	cmd.Flag("key-derivation", "Password-based key derivation algorithm").PlaceHolder("ALGO").EnumVar(&c.createKeyDerivation, repo.SupportedKeyDerivationAlgorithms()...)
 
 	c.co.setup(cmd)
 	c.svc = svc
@@ -61,18 +76,45 @@ func (c *commandRepositoryCreate) setup(svc advancedAppServices, parent commandP
 }
 
 func (c *commandRepositoryCreate) newRepositoryOptionsFromFlags() *repo.NewRepositoryOptions {
+	hash := c.createBlockHashFormat
+	if (c.createFIPS || repo.FIPSBuild()) && hash == hashing.DefaultAlgorithm {
+		// the default hash is not FIPS-approved, use the FIPS default instead.
+		hash = repo.DefaultFIPSHashAlgorithm
+	}
+
 	return &repo.NewRepositoryOptions{
 		BlockFormat: content.FormattingOptions{
-			Hash:       c.createBlockHashFormat,
+			Hash:       hash,
 			Encryption: c.createBlockEncryptionFormat,
+			Version:    c.createFormatVersion,
+			Features:   c.formatFeaturesFromFlags(),
 		},
 
 		ObjectFormat: object.Format{
 			Splitter: c.createSplitter,
 		},
+
+		Labels:                 c.createLabels,
+		BlobIntegrityFooter:    c.createBlobIntegrityFooter,
+		FIPS:                   c.createFIPS,
+		KeyDerivationAlgorithm: c.createKeyDerivation,
 	}
 }
 
+// formatFeaturesFromFlags returns format features explicitly enabled using flags in addition to the ones
+// implied by the requested format version, or nil if none was.
+func (c *commandRepositoryCreate) formatFeaturesFromFlags() *content.FormatFeatures {
+	if !c.createStreamedDirectories && !c.createCompressedManifests {
+		return nil
+	}
+
+	ff := content.FormatFeaturesForVersion(c.createFormatVersion)
+	ff.StreamedDirectories = ff.StreamedDirectories || c.createStreamedDirectories
+	ff.CompressedManifests = ff.CompressedManifests || c.createCompressedManifests
+
+	return &ff
+}
+
 func (c *commandRepositoryCreate) ensureEmpty(ctx context.Context, s blob.Storage) error {
 	hasDataError := errors.Errorf("has data")
 
@@ -105,6 +147,14 @@ func (c *commandRepositoryCreate) runCreateCommandWithStorage(ctx context.Contex
 	log(ctx).Infof("  encryption:          %v", options.BlockFormat.Encryption)
 	log(ctx).Infof("  splitter:            %v", options.ObjectFormat.Splitter)
 
+	if options.BlobIntegrityFooter {
+		log(ctx).Infof("  blob integrity:      footer")
+	}
+
+	if options.FIPS || repo.FIPSBuild() {
+		log(ctx).Infof("  FIPS mode:           enabled")
+	}
+
 	if err := repo.Initialize(ctx, st, options, pass); err != nil {
 		return errors.Wrap(err, "cannot initialize repository")
 	}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/blobarchive"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// exportedBlobPrefixes are prefixes of immutable pack and index blobs included in blob archives.
+// Pack blobs come first, so that indexes are never applied before the packs they reference.
+var exportedBlobPrefixes = []blob.ID{
+	content.PackBlobIDPrefixRegular,
+	content.PackBlobIDPrefixSpecial,
+	content.IndexBlobPrefix,
+	"m", // index compaction log
+}
+
+type commandRepositoryExportBlobs struct {
+	exportOutput          string
+	exportCheckpoint      string
+	exportCheckpointOnly  bool
+	exportArchivePassword string
+
+	svc appServices
+	out textOutput
+}
+
+func (c *commandRepositoryExportBlobs) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("export-blobs", "Export pack and index blobs created since the last export to an encrypted archive, which can be applied to an offline replica using 'repository apply-blobs'.")
+	cmd.Flag("output", "Archive file to write").Short('o').StringVar(&c.exportOutput)
+	cmd.Flag("checkpoint", "File recording blobs already exported, which is updated after successful export").Required().StringVar(&c.exportCheckpoint)
+	cmd.Flag("checkpoint-only", "Only record current blobs in the checkpoint without exporting them (e.g. after initializing the replica using 'repository sync-to')").BoolVar(&c.exportCheckpointOnly)
+	cmd.Flag("archive-password", "Password used to encrypt the archive").Envar("KOPIA_ARCHIVE_PASSWORD").StringVar(&c.exportArchivePassword)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+
+	c.svc = svc
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryExportBlobs) readCheckpoint() (*blobarchive.Checkpoint, error) {
+	f, err := os.Open(c.exportCheckpoint)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open checkpoint")
+	}
+
+	defer f.Close() //nolint:errcheck,gosec
+
+	cp := &blobarchive.Checkpoint{}
+	if err := json.NewDecoder(f).Decode(cp); err != nil {
+		return nil, errors.Wrap(err, "unable to parse checkpoint")
+	}
+
+	return cp, nil
+}
+
+func (c *commandRepositoryExportBlobs) writeCheckpoint(cp *blobarchive.Checkpoint) error {
+	var buf bytes.Buffer
+
+	if err := json.NewEncoder(&buf).Encode(cp); err != nil {
+		return errors.Wrap(err, "unable to marshal JSON")
+	}
+
+	return errors.Wrap(atomicfile.Write(c.exportCheckpoint, &buf), "error writing checkpoint")
+}
+
+func (c *commandRepositoryExportBlobs) run(ctx context.Context, rep repo.DirectRepository) (err error) {
+	if c.exportCheckpointOnly {
+		return c.recordCheckpoint(ctx, rep)
+	}
+
+	if c.exportOutput == "" {
+		return errors.New("must specify --output")
+	}
+
+	cp, err := c.readCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	if cp == nil {
+		log(ctx).Infof("Checkpoint %v not found, exporting all pack and index blobs.", c.exportCheckpoint)
+	}
+
+	pass := c.exportArchivePassword
+	if pass == "" {
+		if pass, err = askForNewArchivePassword(c.svc.stdout()); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(c.exportOutput)
+	if err != nil {
+		return errors.Wrap(err, "unable to create archive file")
+	}
+
+	st, newCheckpoint, err := blobarchive.Export(ctx, rep.BlobReader(), f, blobarchive.ExportOptions{
+		Prefixes:     exportedBlobPrefixes,
+		Checkpoint:   cp,
+		RepositoryID: rep.UniqueID(),
+		Password:     pass,
+	})
+
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = errors.Wrap(cerr, "error closing archive file")
+	}
+
+	if err != nil {
+		os.Remove(c.exportOutput) //nolint:errcheck
+
+		return errors.Wrap(err, "error exporting blobs")
+	}
+
+	// the checkpoint is only updated once the archive has been fully written.
+	if err := c.writeCheckpoint(newCheckpoint); err != nil {
+		return err
+	}
+
+	c.out.printStdout("Exported %v blobs (%v) to %v\n", st.Blobs, units.BytesStringBase10(st.Bytes), c.exportOutput)
+
+	return nil
+}
+
+func (c *commandRepositoryExportBlobs) recordCheckpoint(ctx context.Context, rep repo.DirectRepository) error {
+	cp := &blobarchive.Checkpoint{Time: clock.Now()}
+
+	for _, prefix := range exportedBlobPrefixes {
+		if err := rep.BlobReader().ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+			cp.Blobs = append(cp.Blobs, bm.BlobID)
+			return nil
+		}); err != nil {
+			return errors.Wrap(err, "error listing blobs")
+		}
+	}
+
+	if err := c.writeCheckpoint(cp); err != nil {
+		return err
+	}
+
+	c.out.printStdout("Recorded %v blobs in %v\n", len(cp.Blobs), c.exportCheckpoint)
+
+	return nil
+}
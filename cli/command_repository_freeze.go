@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+type commandRepositoryFreeze struct {
+	reason string
+
+	out textOutput
+}
+
+func (c *commandRepositoryFreeze) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("freeze", "Freeze repository, blocking all writes and maintenance by all clients until it is unfrozen.")
+	cmd.Flag("reason", "Reason for freezing the repository").StringVar(&c.reason)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryFreeze) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	fi, err := repo.GetFreezeInfo(ctx, rep.BlobReader())
+	if err != nil {
+		return errors.Wrap(err, "unable to get freeze status")
+	}
+
+	if fi != nil {
+		return errors.Errorf("repository is already frozen since %v by %v", formatTimestamp(fi.FrozenAt), fi.FrozenBy)
+	}
+
+	if err := repo.Freeze(ctx, rep, c.reason); err != nil {
+		return errors.Wrap(err, "unable to freeze repository")
+	}
+
+	c.out.printStderr("Repository frozen. Run 'kopia repository unfreeze' to allow writes again.\n")
+
+	return nil
+}
+
+type commandRepositoryUnfreeze struct {
+	out textOutput
+}
+
+func (c *commandRepositoryUnfreeze) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("unfreeze", "Unfreeze repository, allowing writes and maintenance again.")
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryUnfreeze) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	fi, err := repo.GetFreezeInfo(ctx, rep.BlobReader())
+	if err != nil {
+		return errors.Wrap(err, "unable to get freeze status")
+	}
+
+	if fi == nil {
+		return errors.Errorf("repository is not frozen")
+	}
+
+	if err := repo.Unfreeze(ctx, rep); err != nil {
+		return errors.Wrap(err, "unable to unfreeze repository")
+	}
+
+	c.out.printStderr("Repository unfrozen.\n")
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/presigned"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// largeRestoreAccessBlobCount is the number of blobs above which the token and the configuration
+// of repositories connected using it become very large.
+const largeRestoreAccessBlobCount = 100000
+
+type commandRepositoryGrantRestoreAccess struct {
+	snapshotIDs []string
+	all         bool
+	expiration  time.Duration
+	output      string
+	parallel    int
+
+	out textOutput
+}
+
+func (c *commandRepositoryGrantRestoreAccess) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("grant-restore-access", "Generate a token with pre-signed URLs allowing read-only access to the repository without storage credentials.")
+	cmd.Flag("snapshot", "ID of the snapshot to grant access to, can be repeated").StringsVar(&c.snapshotIDs)
+	cmd.Flag("all", "Grant access to all blobs in the repository").BoolVar(&c.all)
+	cmd.Flag("expiration", "Time until the access expires").Default("24h").DurationVar(&c.expiration)
+	cmd.Flag("output", "File to write the token to").Short('o').Required().StringVar(&c.output)
+	cmd.Flag("parallel", "Number of URLs to generate in parallel").Default("16").IntVar(&c.parallel)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryGrantRestoreAccess) run(ctx context.Context, rep repo.DirectRepository) error {
+	if c.expiration <= 0 {
+		return errors.New("expiration must be positive")
+	}
+
+	st := rep.BlobReader()
+
+	if !st.Capabilities().PresignedURLs {
+		return errors.Errorf("%v does not support pre-signed URLs", st.DisplayName())
+	}
+
+	include, err := c.blobFilter(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	opt, err := presigned.NewOptions(ctx, st, c.expiration, c.parallel, include)
+	if err != nil {
+		return errors.Wrap(err, "unable to generate pre-signed URLs")
+	}
+
+	if len(opt.Blobs) > largeRestoreAccessBlobCount {
+		log(ctx).Infof("WARNING: the token contains URLs for %v blobs, which makes it and the configuration of repositories connected using it very large.", len(opt.Blobs))
+	}
+
+	b, err := json.MarshalIndent(opt, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize token")
+	}
+
+	if err := ioutil.WriteFile(c.output, b, 0o600); err != nil { //nolint:gomnd
+		return errors.Wrap(err, "error writing token")
+	}
+
+	log(ctx).Infof("Granted read-only access to %v blobs until %v.", len(opt.Blobs), opt.Expires.Local().Format(time.RFC1123))
+	log(ctx).Infof("The token file grants access to repository storage and must be kept secret, the repository password is still required to read the data.")
+	log(ctx).Infof("To connect use: kopia repository connect presigned --token-file=%v", c.output)
+
+	return nil
+}
+
+// blobFilter returns the function selecting blobs needed to restore the requested snapshots, which are
+// all blobs except packs not holding any of their contents, or nil if access to all blobs was requested.
+func (c *commandRepositoryGrantRestoreAccess) blobFilter(ctx context.Context, rep repo.DirectRepository) (func(bm blob.Metadata) bool, error) {
+	if c.all {
+		if len(c.snapshotIDs) > 0 {
+			return nil, errors.New("--all and --snapshot are mutually exclusive")
+		}
+
+		return nil, nil
+	}
+
+	if len(c.snapshotIDs) == 0 {
+		return nil, errors.New("either --snapshot or --all must be specified")
+	}
+
+	var manifests []*snapshot.Manifest
+
+	for _, id := range c.snapshotIDs {
+		m, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(id))
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading snapshot %v", id)
+		}
+
+		manifests = append(manifests, m)
+	}
+
+	log(ctx).Infof("Looking for packs used by %v snapshots...", len(manifests))
+
+	packs, err := snapshotfs.PackBlobsForSnapshots(ctx, rep, manifests)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find packs used by snapshots")
+	}
+
+	return func(bm blob.Metadata) bool {
+		return !strings.HasPrefix(string(bm.BlobID), string(content.PackBlobIDPrefixRegular)) || packs[bm.BlobID]
+	}, nil
+}
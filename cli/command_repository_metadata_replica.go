@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type commandRepositoryMetadataReplica struct {
+	svc advancedAppServices
+}
+
+func (c *commandRepositoryMetadataReplica) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("metadata-replica", "Manage the replica of repository metadata in a secondary storage location.")
+
+	setCmd := cmd.Command("set", "Mirror repository metadata to a secondary storage location.")
+
+	for _, prov := range storageProviders {
+		f := prov.newFlags()
+		cc := setCmd.Command(prov.name, "Mirror repository metadata to "+prov.description)
+		f.setup(svc, cc)
+		cc.Action(func(_ *kingpin.ParseContext) error {
+			ctx := svc.rootContext()
+
+			st, err := f.connect(ctx, true)
+			if err != nil {
+				return errors.Wrap(err, "can't connect to storage")
+			}
+
+			defer st.Close(ctx) //nolint:errcheck
+
+			return c.runSet(ctx, st)
+		})
+	}
+
+	cmd.Command("sync", "Copy repository metadata missing in the replica.").Action(svc.noRepositoryAction(c.runSync))
+	cmd.Command("restore", "Copy repository metadata missing in the repository from the replica.").Action(svc.noRepositoryAction(c.runRestore))
+	cmd.Command("clear", "Stop mirroring repository metadata.").Action(svc.noRepositoryAction(c.runClear))
+
+	c.svc = svc
+}
+
+// openPrimaryStorage returns storage of the connected repository, bypassing the repository format,
+// so that it can be used even if the format blob has been lost.
+func (c *commandRepositoryMetadataReplica) openPrimaryStorage(ctx context.Context) (*repo.LocalConfig, blob.Storage, error) {
+	lc, err := repo.LoadConfigFromFile(c.svc.repositoryConfigFileName())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to load repository configuration")
+	}
+
+	if lc.Storage == nil {
+		return nil, nil, errors.Errorf("metadata replica is only supported for directly-connected repositories")
+	}
+
+	st, err := blob.NewStorage(ctx, *lc.Storage)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot open storage")
+	}
+
+	return lc, st, nil
+}
+
+func (c *commandRepositoryMetadataReplica) runSet(ctx context.Context, rst blob.Storage) error {
+	_, st, err := c.openPrimaryStorage(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer st.Close(ctx) //nolint:errcheck
+
+	log(ctx).Infof("Copying repository metadata to %v...", rst.DisplayName())
+
+	n, err := repo.SyncMetadataReplica(ctx, st, rst)
+	if err != nil {
+		return err
+	}
+
+	log(ctx).Infof("Copied %v metadata blobs.", n)
+
+	ci := rst.ConnectionInfo()
+
+	if err := repo.SetMetadataReplica(ctx, c.svc.repositoryConfigFileName(), &ci); err != nil {
+		return errors.Wrap(err, "unable to save configuration")
+	}
+
+	log(ctx).Infof("Repository metadata will be mirrored to %v.", rst.DisplayName())
+
+	return nil
+}
+
+func (c *commandRepositoryMetadataReplica) openReplicaStorage(ctx context.Context, lc *repo.LocalConfig) (blob.Storage, error) {
+	if lc.MetadataReplica == nil {
+		return nil, errors.Errorf("metadata replica is not configured")
+	}
+
+	rst, err := blob.NewStorage(ctx, *lc.MetadataReplica)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open metadata replica storage")
+	}
+
+	return rst, nil
+}
+
+func (c *commandRepositoryMetadataReplica) runSync(ctx context.Context) error {
+	return c.copyMetadata(ctx, false)
+}
+
+func (c *commandRepositoryMetadataReplica) runRestore(ctx context.Context) error {
+	return c.copyMetadata(ctx, true)
+}
+
+func (c *commandRepositoryMetadataReplica) copyMetadata(ctx context.Context, restore bool) error {
+	lc, st, err := c.openPrimaryStorage(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer st.Close(ctx) //nolint:errcheck
+
+	rst, err := c.openReplicaStorage(ctx, lc)
+	if err != nil {
+		return err
+	}
+
+	defer rst.Close(ctx) //nolint:errcheck
+
+	src, dst := blob.Storage(st), rst
+
+	if restore {
+		if lc.ReadOnly {
+			return errors.Errorf("repository is connected in read-only mode")
+		}
+
+		src, dst = rst, st
+	}
+
+	log(ctx).Infof("Copying repository metadata from %v to %v...", src.DisplayName(), dst.DisplayName())
+
+	n, err := repo.SyncMetadataReplica(ctx, src, dst)
+	if err != nil {
+		return err
+	}
+
+	log(ctx).Infof("Copied %v metadata blobs.", n)
+
+	return nil
+}
+
+func (c *commandRepositoryMetadataReplica) runClear(ctx context.Context) error {
+	if err := repo.SetMetadataReplica(ctx, c.svc.repositoryConfigFileName(), nil); err != nil {
+		return errors.Wrap(err, "unable to save configuration")
+	}
+
+	log(ctx).Infof("Repository metadata will no longer be mirrored.")
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type commandRepositoryReadReplica struct {
+	svc advancedAppServices
+}
+
+func (c *commandRepositoryReadReplica) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("read-replica", "Manage copies of the repository used for reads when the primary storage is unavailable.")
+
+	addCmd := cmd.Command("add", "Add a copy of the repository (for example created using 'sync-to') as a read replica.")
+
+	for _, prov := range storageProviders {
+		f := prov.newFlags()
+		cc := addCmd.Command(prov.name, "Add read replica in "+prov.description)
+		f.setup(svc, cc)
+		cc.Action(func(_ *kingpin.ParseContext) error {
+			ctx := svc.rootContext()
+
+			st, err := f.connect(ctx, false)
+			if err != nil {
+				return errors.Wrap(err, "can't connect to storage")
+			}
+
+			defer st.Close(ctx) //nolint:errcheck
+
+			return c.runAdd(ctx, st)
+		})
+	}
+
+	cmd.Command("clear", "Remove all read replicas.").Action(svc.noRepositoryAction(c.runClear))
+
+	c.svc = svc
+}
+
+func (c *commandRepositoryReadReplica) runAdd(ctx context.Context, st blob.Storage) error {
+	// make sure the replica is a copy of the repository and not some unrelated location.
+	if _, err := st.GetMetadata(ctx, repo.FormatBlobID); err != nil {
+		return errors.Wrapf(err, "unable to find repository in %v", st.DisplayName())
+	}
+
+	if err := repo.AddReadReplica(ctx, c.svc.repositoryConfigFileName(), st.ConnectionInfo()); err != nil {
+		return errors.Wrap(err, "unable to save configuration")
+	}
+
+	log(ctx).Infof("Reads failing in the primary storage will be attempted in %v.", st.DisplayName())
+
+	return nil
+}
+
+func (c *commandRepositoryReadReplica) runClear(ctx context.Context) error {
+	if err := repo.ClearReadReplicas(ctx, c.svc.repositoryConfigFileName()); err != nil {
+		return errors.Wrap(err, "unable to save configuration")
+	}
+
+	log(ctx).Infof("Read replicas have been removed.")
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type commandRepositoryRecoverDeleted struct {
+	flags undeleteFlags
+}
+
+func (c *commandRepositoryRecoverDeleted) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("recover-deleted", "Recovers repository blobs deleted from the storage (such as after an accidental deletion of bucket contents) using object versioning of the storage provider.")
+
+	c.flags.setup(cmd)
+
+	for _, prov := range storageProviders {
+		f := prov.newFlags()
+		cc := cmd.Command(prov.name, "Recover deleted repository blobs in "+prov.description)
+		f.setup(svc, cc)
+		cc.Action(func(_ *kingpin.ParseContext) error {
+			ctx := svc.rootContext()
+			st, err := f.connect(ctx, false)
+			if err != nil {
+				return errors.Wrap(err, "can't connect to storage")
+			}
+
+			return c.runWithStorage(ctx, st)
+		})
+	}
+}
+
+func (c *commandRepositoryRecoverDeleted) runWithStorage(ctx context.Context, st blob.Storage) error {
+	log(ctx).Infof("Looking for deleted blobs in %v...", st.DisplayName())
+
+	deleted, err := c.flags.findDeletedBlobs(ctx, st, nil)
+	if err != nil {
+		return err
+	}
+
+	if len(deleted) == 0 {
+		log(ctx).Infof("No deleted blobs found.")
+		return nil
+	}
+
+	log(ctx).Infof("Found %v deleted blobs that can be recovered:", len(deleted))
+	summarizeDeletedBlobs(ctx, deleted)
+
+	if _, err := st.GetMetadata(ctx, repo.FormatBlobID); errors.Is(err, blob.ErrBlobNotFound) && !containsBlob(deleted, repo.FormatBlobID) {
+		log(ctx).Infof("WARNING: format blob %v is missing and can't be undeleted, use 'kopia repository repair' after recovering other blobs.", repo.FormatBlobID)
+	}
+
+	if c.flags.dryRun {
+		log(ctx).Infof("Dry run, nothing was recovered. Run again without --dry-run to recover the blobs listed above.")
+		return nil
+	}
+
+	if err := c.flags.undeleteBlobs(ctx, st, deleted); err != nil {
+		return err
+	}
+
+	log(ctx).Infof("Recovered %v blobs.", len(deleted))
+	log(ctx).Infof("To confirm that the repository is consistent, connect to it and run 'kopia content verify' followed by 'kopia snapshot verify'.")
+
+	return nil
+}
+
+func containsBlob(list []blob.DeletedMetadata, id blob.ID) bool {
+	for _, dm := range list {
+		if dm.BlobID == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestRecoverDeleted(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	now := clock.Now().Add(-48 * time.Hour)
+	st := blobtesting.NewVersionedMapStorage(blobtesting.DataMap{}, nil, func() time.Time { return now })
+
+	for _, id := range []blob.ID{repo.FormatBlobID, "p1", "p2", "n1", "q1", "x1"} {
+		require.NoError(t, st.PutBlob(ctx, id, gather.FromSlice([]byte(id))))
+	}
+
+	// x1 was deleted long time ago, everything else except q1 was deleted recently.
+	require.NoError(t, st.DeleteBlob(ctx, "x1"))
+
+	now = clock.Now()
+
+	for _, id := range []blob.ID{repo.FormatBlobID, "p1", "p2", "n1"} {
+		require.NoError(t, st.DeleteBlob(ctx, id))
+	}
+
+	c := &commandRepositoryRecoverDeleted{
+		flags: undeleteFlags{deletedWithin: 24 * time.Hour, dryRun: true},
+	}
+
+	require.NoError(t, c.runWithStorage(ctx, st))
+	blobtesting.AssertListResults(ctx, t, st, "", "q1")
+
+	c.flags.dryRun = false
+
+	require.NoError(t, c.runWithStorage(ctx, st))
+	blobtesting.AssertListResults(ctx, t, st, "", repo.FormatBlobID, "n1", "p1", "p2", "q1")
+	blobtesting.AssertGetBlob(ctx, t, st, repo.FormatBlobID, []byte(repo.FormatBlobID))
+
+	// undeleting specific blobs, regardless of deletion time.
+	c.flags.deletedWithin = 0
+
+	deleted, err := c.flags.findDeletedBlobs(ctx, st, []string{"x1"})
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.NoError(t, c.flags.undeleteBlobs(ctx, st, deleted))
+	blobtesting.AssertGetBlob(ctx, t, st, "x1", []byte("x1"))
+
+	// storage without versioning.
+	_, err = c.flags.findDeletedBlobs(ctx, blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), nil)
+	require.ErrorIs(t, err, blob.ErrUndeleteUnsupported)
+}
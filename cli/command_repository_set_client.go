@@ -11,6 +11,8 @@
 type commandRepositorySetClient struct {
 	repoClientOptionsReadOnly    bool
 	repoClientOptionsReadWrite   bool
+	repoClientOptionsDryRun      bool
+	repoClientOptionsNoDryRun    bool
 	repoClientOptionsDescription []string
 	repoClientOptionsUsername    []string
 	repoClientOptionsHostname    []string
@@ -23,6 +25,8 @@ func (c *commandRepositorySetClient) setup(svc appServices, parent commandParent
 
 	cmd.Flag("read-only", "Set repository to read-only").BoolVar(&c.repoClientOptionsReadOnly)
 	cmd.Flag("read-write", "Set repository to read-write").BoolVar(&c.repoClientOptionsReadWrite)
+	cmd.Flag("dry-run", "Report changes to the repository storage without making them").BoolVar(&c.repoClientOptionsDryRun)
+	cmd.Flag("no-dry-run", "Make changes to the repository storage").BoolVar(&c.repoClientOptionsNoDryRun)
 	cmd.Flag("description", "Change description").StringsVar(&c.repoClientOptionsDescription)
 	cmd.Flag("username", "Change username").StringsVar(&c.repoClientOptionsUsername)
 	cmd.Flag("hostname", "Change hostname").StringsVar(&c.repoClientOptionsHostname)
@@ -58,6 +62,28 @@ func (c *commandRepositorySetClient) run(ctx context.Context, rep repo.Repositor
 		}
 	}
 
+	if c.repoClientOptionsDryRun {
+		if opt.DryRun {
+			log(ctx).Infof("Repository is already in dry-run mode.")
+		} else {
+			opt.DryRun = true
+			anyChange = true
+
+			log(ctx).Infof("Setting repository to dry-run mode.")
+		}
+	}
+
+	if c.repoClientOptionsNoDryRun {
+		if !opt.DryRun {
+			log(ctx).Infof("Repository is not in dry-run mode.")
+		} else {
+			opt.DryRun = false
+			anyChange = true
+
+			log(ctx).Infof("Disabling dry-run mode.")
+		}
+	}
+
 	if v := c.repoClientOptionsDescription; len(v) > 0 {
 		opt.Description = v[0]
 		anyChange = true
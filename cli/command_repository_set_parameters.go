@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+type commandRepositorySetParameters struct {
+	addLabels    map[string]string
+	removeLabels []string
+
+	maxFormatKeyAge    string
+	formatKeyAgeAction string
+
+	clientDefaultsSet        repo.ClientDefaultsOverrides
+	clientDefaultCacheMB     int64
+	clientDefaultMetaMB      int64
+	clientDefaultParallelism int
+	clientDefaultCheckpoint  time.Duration
+	clearClientDefaults      bool
+}
+
+func (c *commandRepositorySetParameters) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("set-parameters", "Set repository parameters.")
+	c.addLabels = map[string]string{}
+	cmd.Flag("label", "Add or update repository label (key=value), can be repeated.").StringMapVar(&c.addLabels)
+	cmd.Flag("remove-label", "Remove repository label, can be repeated.").StringsVar(&c.removeLabels)
+	cmd.Flag("max-format-key-age", "Maximum age of the format key, after which password change is required (0 to disable)").StringVar(&c.maxFormatKeyAge)
+	cmd.Flag("format-key-age-action", "Action taken when the format key is too old").EnumVar(&c.formatKeyAgeAction, repo.FormatKeyActionWarn, repo.FormatKeyActionBlock)
+	cmd.Flag("client-default-content-cache-size-mb", "Content cache size recommended to clients (0 to remove)").PlaceHolder("MB").IsSetByUser(&c.clientDefaultsSet.MaxCacheSize).Int64Var(&c.clientDefaultCacheMB)
+	cmd.Flag("client-default-metadata-cache-size-mb", "Metadata cache size recommended to clients (0 to remove)").PlaceHolder("MB").IsSetByUser(&c.clientDefaultsSet.MaxMetadataCacheSize).Int64Var(&c.clientDefaultMetaMB)
+	cmd.Flag("client-default-upload-parallelism", "Upload parallelism recommended to clients (0 to remove)").PlaceHolder("N").IsSetByUser(&c.clientDefaultsSet.UploadParallelism).IntVar(&c.clientDefaultParallelism)
+	cmd.Flag("client-default-checkpoint-interval", "Checkpoint interval recommended to clients (0 to remove)").IsSetByUser(&c.clientDefaultsSet.CheckpointInterval).DurationVar(&c.clientDefaultCheckpoint)
+	cmd.Flag("clear-client-defaults", "Remove all client defaults").BoolVar(&c.clearClientDefaults)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandRepositorySetParameters) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	var anyChange bool
+
+	policyChanged, err := c.setFormatKeyPolicy(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if policyChanged {
+		anyChange = true
+	}
+
+	defaultsChanged, err := c.setClientDefaults(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if defaultsChanged {
+		anyChange = true
+	}
+
+	labels := rep.Labels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	for k, v := range c.addLabels {
+		if old, ok := labels[k]; ok && old == v {
+			log(ctx).Infof("Label %v is already set to %v.", k, v)
+			continue
+		}
+
+		labels[k] = v
+		anyChange = true
+
+		log(ctx).Infof("Setting label %v to %v", k, v)
+	}
+
+	for _, k := range c.removeLabels {
+		if _, ok := labels[k]; !ok {
+			log(ctx).Infof("Label %v is not set.", k)
+			continue
+		}
+
+		delete(labels, k)
+		anyChange = true
+
+		log(ctx).Infof("Removing label %v", k)
+	}
+
+	if !anyChange {
+		return errors.Errorf("no changes")
+	}
+
+	if len(c.addLabels) == 0 && len(c.removeLabels) == 0 {
+		return nil
+	}
+
+	return errors.Wrap(rep.SetLabels(ctx, labels), "error setting repository labels")
+}
+
+func (c *commandRepositorySetParameters) setFormatKeyPolicy(ctx context.Context, rep repo.DirectRepositoryWriter) (bool, error) {
+	if c.maxFormatKeyAge == "" && c.formatKeyAgeAction == "" {
+		return false, nil
+	}
+
+	p := rep.FormatKeyStatus().Policy
+
+	if c.maxFormatKeyAge != "" {
+		d, err := time.ParseDuration(c.maxFormatKeyAge)
+		if err != nil {
+			return false, errors.Wrap(err, "invalid max format key age")
+		}
+
+		p.MaxAge = d
+
+		log(ctx).Infof("Setting max format key age to %v", d)
+	}
+
+	if c.formatKeyAgeAction != "" {
+		p.Action = c.formatKeyAgeAction
+
+		log(ctx).Infof("Setting format key age action to %v", p.Action)
+	}
+
+	return true, errors.Wrap(rep.SetFormatKeyPolicy(ctx, p), "error setting format key policy")
+}
+
+func (c *commandRepositorySetParameters) setClientDefaults(ctx context.Context, rep repo.DirectRepositoryWriter) (bool, error) {
+	o := c.clientDefaultsSet
+	if !o.MaxCacheSize && !o.MaxMetadataCacheSize && !o.UploadParallelism && !o.CheckpointInterval && !c.clearClientDefaults {
+		return false, nil
+	}
+
+	d := &repo.ClientDefaults{}
+
+	if !c.clearClientDefaults {
+		if existing := rep.ClientDefaults(); existing != nil {
+			d = existing
+		}
+	}
+
+	if o.MaxCacheSize {
+		d.MaxCacheSizeBytes = c.clientDefaultCacheMB << 20 //nolint:gomnd
+	}
+
+	if o.MaxMetadataCacheSize {
+		d.MaxMetadataCacheSizeBytes = c.clientDefaultMetaMB << 20 //nolint:gomnd
+	}
+
+	if o.UploadParallelism {
+		d.UploadParallelism = c.clientDefaultParallelism
+	}
+
+	if o.CheckpointInterval {
+		d.CheckpointInterval = c.clientDefaultCheckpoint
+	}
+
+	log(ctx).Infof("Setting client defaults to %+v", *d)
+
+	return true, errors.Wrap(rep.SetClientDefaults(ctx, d), "error setting client defaults")
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+type commandRepositoryStats struct {
+	raw bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRepositoryStats) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("stats", "Display repository-wide statistics maintained incrementally by all clients.")
+	cmd.Flag("raw", "Raw numbers").Short('r').BoolVar(&c.raw)
+	c.jo.setup(svc, cmd)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryStats) run(ctx context.Context, rep repo.DirectRepository) error {
+	s, err := repo.GetStats(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get repository stats")
+	}
+
+	if s == nil {
+		return errors.Errorf("repository statistics are not available yet, they will be computed during the next full maintenance")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(s))
+		return nil
+	}
+
+	sum := s.Sum()
+
+	c.out.printStdout("Logical bytes:       %v\n", c.bytes(s.LogicalBytes))
+	c.out.printStdout("Contents:            %v\n", c.count(sum.Count))
+	c.out.printStdout("Original bytes:      %v\n", c.bytes(sum.OriginalBytes))
+	c.out.printStdout("Stored bytes:        %v\n", c.bytes(sum.PackedBytes))
+	c.out.printStdout("Dedup ratio:         %.2f\n", s.DedupRatio())
+	c.out.printStdout("Updated:             %v\n", formatTimestamp(s.UpdatedAt))
+
+	if !s.RecomputedAt.IsZero() {
+		c.out.printStdout("Recomputed:          %v\n", formatTimestamp(s.RecomputedAt))
+	}
+
+	var prefixes []content.ID
+	for p := range s.ByPrefix {
+		prefixes = append(prefixes, p)
+	}
+
+	sort.Slice(prefixes, func(i, j int) bool { return prefixes[i] < prefixes[j] })
+
+	c.out.printStdout("\nBy prefix:\n")
+
+	for _, p := range prefixes {
+		pt := s.ByPrefix[p]
+
+		name := string(p)
+		if name == "" {
+			name = "(none)"
+		}
+
+		c.out.printStdout("  %-8v %v contents, %v original, %v stored\n", name, c.count(pt.Count), c.bytes(pt.OriginalBytes), c.bytes(pt.PackedBytes))
+	}
+
+	return nil
+}
+
+func (c *commandRepositoryStats) bytes(v int64) string {
+	if c.raw {
+		return strconv.FormatInt(v, 10)
+	}
+
+	return units.BytesStringBase10(v)
+}
+
+func (c *commandRepositoryStats) count(v int64) string {
+	if c.raw {
+		return strconv.FormatInt(v, 10)
+	}
+
+	return units.Count(v)
+}
@@ -6,17 +6,23 @@
 	"io/ioutil"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/providervalidation"
 	"github.com/kopia/kopia/internal/scrubber"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
 )
 
 type commandRepositoryStatus struct {
 	statusReconnectToken                bool
 	statusReconnectTokenIncludePassword bool
+	statusCheckStorage                  bool
 
 	svc advancedAppServices
 	out textOutput
@@ -26,12 +32,33 @@ func (c *commandRepositoryStatus) setup(svc advancedAppServices, parent commandP
 	cmd := parent.Command("status", "Display the status of connected repository.")
 	cmd.Flag("reconnect-token", "Display reconnect command").Short('t').BoolVar(&c.statusReconnectToken)
 	cmd.Flag("reconnect-token-with-password", "Include password in reconnect token").Short('s').BoolVar(&c.statusReconnectTokenIncludePassword)
+	cmd.Flag("check-storage", "Probe the storage with a small write/read/list/delete and report latency and capabilities").BoolVar(&c.statusCheckStorage)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 
 	c.svc = svc
 	c.out.setup(svc)
 }
 
+func (c *commandRepositoryStatus) printFIPSStatus(s repo.FIPSStatus) {
+	mode := "disabled"
+	if s.Enabled {
+		mode = "enabled"
+	}
+
+	if repo.FIPSBuild() {
+		mode += " (enforced by build)"
+	}
+
+	c.out.printStdout("FIPS mode:           %v\n", mode)
+
+	if s.Compliant() {
+		c.out.printStdout("FIPS compliant:      yes\n")
+		return
+	}
+
+	c.out.printStdout("FIPS compliant:      no (%v)\n", strings.Join(s.Violations, ", "))
+}
+
 func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository) error {
 	c.out.printStdout("Config file:         %v\n", c.svc.repositoryConfigFileName())
 	c.out.printStdout("\n")
@@ -39,6 +66,7 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 	c.out.printStdout("Hostname:            %v\n", rep.ClientOptions().Hostname)
 	c.out.printStdout("Username:            %v\n", rep.ClientOptions().Username)
 	c.out.printStdout("Read-only:           %v\n", rep.ClientOptions().ReadOnly)
+	c.out.printStdout("Dry-run:             %v\n", rep.ClientOptions().DryRun)
 
 	dr, ok := rep.(repo.DirectRepository)
 	if !ok {
@@ -54,6 +82,12 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 		c.out.printStdout("Storage config:      %v\n", string(cjson))
 	}
 
+	if c.statusCheckStorage {
+		if err := c.checkStorage(ctx, dr); err != nil {
+			return err
+		}
+	}
+
 	c.out.printStdout("\n")
 	c.out.printStdout("Unique ID:           %x\n", dr.UniqueID())
 	c.out.printStdout("Hash:                %v\n", dr.ContentReader().ContentFormat().Hash)
@@ -61,6 +95,47 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 	c.out.printStdout("Splitter:            %v\n", dr.ObjectFormat().Splitter)
 	c.out.printStdout("Format version:      %v\n", dr.ContentReader().ContentFormat().Version)
 	c.out.printStdout("Max pack length:     %v\n", units.BytesStringBase2(int64(dr.ContentReader().ContentFormat().MaxPackSize)))
+	c.out.printStdout("Integrity footer:    %v\n", dr.BlobIntegrityFooter())
+
+	f := dr.ContentReader().ContentFormat()
+	ff := f.EnabledFeatures()
+	c.out.printStdout("Streamed dirs:       %v\n", ff.StreamedDirectories)
+	c.out.printStdout("Zstd manifests:      %v\n", ff.CompressedManifests)
+	c.printFIPSStatus(dr.FIPSStatus())
+
+	if labels := dr.Labels(); len(labels) > 0 {
+		var keys []string
+		for k := range labels {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		c.out.printStdout("Labels:\n")
+
+		for _, k := range keys {
+			c.out.printStdout("  %v=%v\n", k, labels[k])
+		}
+	}
+
+	if d := dr.ClientDefaults(); !d.IsEmpty() {
+		c.printClientDefaults(d)
+	}
+
+	c.printFormatKeyStatus(dr.FormatKeyStatus(), dr.Time())
+
+	fi, err := repo.GetFreezeInfo(ctx, dr.BlobReader())
+	if err != nil {
+		return errors.Wrap(err, "unable to get freeze status")
+	}
+
+	if fi != nil {
+		c.out.printStdout("Frozen:              since %v by %v\n", formatTimestamp(fi.FrozenAt), fi.FrozenBy)
+
+		if fi.Reason != "" {
+			c.out.printStdout("Freeze reason:       %v\n", fi.Reason)
+		}
+	}
 
 	if !c.statusReconnectToken {
 		return nil
@@ -91,6 +166,48 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 	return nil
 }
 
+func (c *commandRepositoryStatus) checkStorage(ctx context.Context, dr repo.DirectRepository) error {
+	type blobStorageProvider interface {
+		BlobStorage() blob.Storage
+	}
+
+	bsp, ok := dr.(blobStorageProvider)
+	if !ok {
+		return errors.Errorf("storage check is not supported for this repository")
+	}
+
+	// do not write to the storage when the repository is connected in read-only mode.
+	readOnly := dr.ClientOptions().ReadOnly || dr.ClientOptions().DryRun
+
+	r := providervalidation.CheckHealth(ctx, bsp.BlobStorage(), readOnly)
+
+	c.out.printStdout("Storage health:\n")
+
+	for _, p := range r.Probes {
+		status := "OK"
+		if p.Error != "" {
+			status = "FAILED: " + p.Error
+		}
+
+		c.out.printStdout("  %-18v %-12v %v\n", p.Operation, p.Latency.Round(time.Microsecond), status)
+	}
+
+	c.out.printStdout("  set-time supported: %v\n", r.Capabilities.SetTime)
+	c.out.printStdout("  retention supported: %v\n", r.Capabilities.Retention)
+	c.out.printStdout("  versioning supported: %v\n", r.Capabilities.Versioning)
+	c.out.printStdout("  conditional put supported: %v\n", r.Capabilities.ConditionalPut)
+	c.out.printStdout("  server-side copy supported: %v\n", r.Capabilities.ServerSideCopy)
+	c.out.printStdout("  batch delete supported: %v\n", r.Capabilities.BatchDelete)
+	c.out.printStdout("  ordered listing supported: %v\n", r.Capabilities.OrderedListing)
+	c.out.printStdout("  pre-signed URLs supported: %v\n", r.Capabilities.PresignedURLs)
+
+	if !r.Healthy() {
+		return errors.Errorf("storage health check failed")
+	}
+
+	return nil
+}
+
 func scanCacheDir(dirname string) (fileCount int, totalFileLength int64, err error) {
 	entries, err := ioutil.ReadDir(dirname)
 	if err != nil {
@@ -119,3 +236,56 @@ func scanCacheDir(dirname string) (fileCount int, totalFileLength int64, err err
 
 	return
 }
+
+func (c *commandRepositoryStatus) printFormatKeyStatus(ks repo.FormatKeyStatus, now time.Time) {
+	cur := ks.Current()
+	if cur == nil {
+		return
+	}
+
+	c.out.printStdout("Format key ID:       %v (age %v)\n", cur.KeyID, now.Sub(cur.CreatedAt).Truncate(time.Second))
+
+	if ks.Policy.MaxAge > 0 {
+		action := ks.Policy.Action
+		if action == "" {
+			action = repo.FormatKeyActionWarn
+		}
+
+		c.out.printStdout("Max format key age:  %v (%v)\n", ks.Policy.MaxAge, action)
+
+		if ks.Expired(now) {
+			c.out.printStdout("                     format key has expired, change repository password to rotate it\n")
+		}
+	}
+
+	c.out.printStdout("Format key history:\n")
+
+	for _, k := range ks.History {
+		by := k.CreatedBy
+		if by == "" {
+			by = "(repository creation)"
+		}
+
+		c.out.printStdout("  %v %v %v\n", k.KeyID, formatTimestamp(k.CreatedAt), by)
+	}
+}
+
+func (c *commandRepositoryStatus) printClientDefaults(d *repo.ClientDefaults) {
+	c.out.printStdout("Client defaults:\n")
+
+	if d.MaxCacheSizeBytes > 0 {
+		c.out.printStdout("  content cache size:  %v\n", units.BytesStringBase2(d.MaxCacheSizeBytes))
+	}
+
+	if d.MaxMetadataCacheSizeBytes > 0 {
+		c.out.printStdout("  metadata cache size: %v\n", units.BytesStringBase2(d.MaxMetadataCacheSizeBytes))
+	}
+
+	if d.UploadParallelism > 0 {
+		c.out.printStdout("  upload parallelism:  %v\n", d.UploadParallelism)
+	}
+
+	if d.CheckpointInterval > 0 {
+		c.out.printStdout("  checkpoint interval: %v\n", d.CheckpointInterval)
+	}
+}
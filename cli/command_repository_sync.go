@@ -30,9 +30,8 @@ type commandRepositorySyncTo struct {
 	repositorySyncDestinationMustExist bool
 	repositorySyncTimes                bool
 
-	lastSyncProgress       string
-	syncProgressMutex      sync.Mutex
-	setTimeUnsupportedOnce sync.Once
+	lastSyncProgress  string
+	syncProgressMutex sync.Mutex
 
 	out textOutput
 }
@@ -73,13 +72,18 @@ func (c *commandRepositorySyncTo) setup(svc advancedAppServices, parent commandP
 				return errors.Errorf("sync only supports directly-connected repositories")
 			}
 
-			return c.runSyncWithStorage(ctx, dr.BlobReader(), st)
+			// blobs read from the repository have integrity footers stripped, add them back when writing.
+			return c.runSyncWithStorage(ctx, dr.BlobReader(), repo.WrapBlobStorage(dr, st))
 		})
 	}
 }
 
 const syncProgressInterval = 300 * time.Millisecond
 
+// some providers preserve timestamps of copied blobs only to the nearest second, so source timestamps are compared
+// at that precision to avoid copying the same blobs again on every sync.
+const syncTimestampPrecision = time.Second
+
 func (c *commandRepositorySyncTo) runSyncWithStorage(ctx context.Context, src blob.Reader, dst blob.Storage) error {
 	log(ctx).Infof("Synchronizing repositories:")
 	log(ctx).Infof("  Source:      %v", src.DisplayName())
@@ -89,6 +93,12 @@ func (c *commandRepositorySyncTo) runSyncWithStorage(ctx context.Context, src bl
 		log(ctx).Infof("NOTE: By default no BLOBs are deleted, pass --delete to allow it.")
 	}
 
+	if c.repositorySyncTimes && !dst.Capabilities().SetTime {
+		log(ctx).Infof("NOTE: Destination repository does not support setting time, blob times will not be synchronized.")
+
+		c.repositorySyncTimes = false
+	}
+
 	if err := c.ensureRepositoriesHaveSameFormatBlob(ctx, src, dst); err != nil {
 		return err
 	}
@@ -126,7 +136,7 @@ func (c *commandRepositorySyncTo) runSyncWithStorage(ctx context.Context, src bl
 		case !exists:
 			blobsToCopy = append(blobsToCopy, srcmd)
 			totalCopyBytes += srcmd.Length
-		case srcmd.Timestamp.After(dstmd.Timestamp) && c.repositorySyncUpdate:
+		case srcmd.Timestamp.Truncate(syncTimestampPrecision).After(dstmd.Timestamp) && c.repositorySyncUpdate:
 			blobsToCopy = append(blobsToCopy, srcmd)
 			totalCopyBytes += srcmd.Length
 		default:
@@ -307,12 +317,6 @@ func (c *commandRepositorySyncTo) syncCopyBlob(ctx context.Context, m blob.Metad
 
 	if c.repositorySyncTimes {
 		if err := dst.SetTime(ctx, m.BlobID, m.Timestamp); err != nil {
-			if errors.Is(err, blob.ErrSetTimeUnsupported) {
-				c.setTimeUnsupportedOnce.Do(func() {
-					log(ctx).Infof("destination repository does not support setting time")
-				})
-			}
-
 			return errors.Wrapf(err, "error setting time on destination '%v'", m.BlobID)
 		}
 	}
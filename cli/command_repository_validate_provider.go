@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/providervalidation"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandRepositoryValidateProvider struct {
+	opt providervalidation.Options
+
+	out textOutput
+}
+
+func (c *commandRepositoryValidateProvider) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("validate-provider", "Validate that the storage provider is compatible with Kopia. Blobs created during validation are deleted afterwards.")
+	cmd.Flag("stress", "Run high-concurrency stress test after basic validation").BoolVar(&c.opt.Stress)
+	cmd.Flag("stress-workers", "Number of parallel workers in stress test").Default("100").IntVar(&c.opt.StressWorkers)
+	cmd.Flag("stress-duration", "Duration of stress test").Default("30s").DurationVar(&c.opt.StressDuration)
+	cmd.Flag("stress-blob-size", "Size of blobs written during stress test").Default("65536").IntVar(&c.opt.StressBlobSize)
+	cmd.Flag("stress-shared-blobs", "Number of blobs concurrently overwritten by all workers").Default("10").IntVar(&c.opt.StressSharedBlobs)
+	cmd.Flag("list-after-write-wait", "How long to wait for written blobs to appear in listings").Default("30s").DurationVar(&c.opt.ListAfterWriteWait)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryValidateProvider) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	report, err := providervalidation.ValidateProvider(ctx, rep.BlobStorage(), c.opt)
+
+	if report != nil {
+		if _, rerr := report.WriteTo(c.out.stdout()); rerr != nil {
+			return errors.Wrap(rerr, "error writing report")
+		}
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "provider validation failed")
+	}
+
+	c.out.printStdout("Provider validation succeeded.\n")
+
+	return nil
+}
@@ -11,6 +11,7 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
@@ -76,6 +77,12 @@ type commandRestore struct {
 	restoreSkipPermissions        bool
 	restoreIncremental            bool
 	restoreIgnoreErrors           bool
+	restoreCaseCollisions         string
+	restoreSymlinks               string
+	restoreCloneFiles             bool
+	restoreWindowsJunctions       bool
+	restoreSkipPreflight          bool
+	restorePreflightOnly          bool
 }
 
 func (c *commandRestore) setup(svc appServices, parent commandParent) {
@@ -94,6 +101,14 @@ func (c *commandRestore) setup(svc appServices, parent commandParent) {
 	cmd.Flag("ignore-permission-errors", "Ignore permission errors").Default("true").BoolVar(&c.restoreIgnorePermissionErrors)
 	cmd.Flag("ignore-errors", "Ignore all errors").BoolVar(&c.restoreIgnoreErrors)
 	cmd.Flag("skip-existing", "Skip files and symlinks that exist in the output").BoolVar(&c.restoreIncremental)
+	cmd.Flag("case-collisions", "How to handle entries whose names differ only by case ('auto' renames them when restoring to a case-insensitive filesystem)").Default(caseCollisionsAuto).EnumVar(&c.restoreCaseCollisions,
+		caseCollisionsAuto, caseCollisionsNone, string(restore.CaseCollisionRename), string(restore.CaseCollisionSkip), string(restore.CaseCollisionFail))
+	cmd.Flag("symlinks", "How to restore symbolic links ('follow' restores link targets found in the snapshot, 'rewrite-absolute' makes absolute targets relative to the restore root)").Default(symlinksRestore).EnumVar(&c.restoreSymlinks,
+		symlinksRestore, string(restore.SymlinkSkip), string(restore.SymlinkFollow), string(restore.SymlinkRewriteAbsolute))
+	cmd.Flag("windows-junctions", "Restore symbolic links to directories as junctions on Windows").BoolVar(&c.restoreWindowsJunctions)
+	cmd.Flag("clone-files", "Restore files with identical contents as clones (reflinks) on filesystems that support it").Default("true").BoolVar(&c.restoreCloneFiles)
+	cmd.Flag("skip-preflight", "Skip checking available space, permissions and path lengths before restoring to local filesystem").BoolVar(&c.restoreSkipPreflight)
+	cmd.Flag("preflight-only", "Only check whether the snapshot can be restored to local filesystem, without restoring it").BoolVar(&c.restorePreflightOnly)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 }
 
@@ -106,6 +121,13 @@ func (c *commandRestore) setup(svc appServices, parent commandParent) {
 	restoreModeTgz           = "tgz"
 )
 
+const (
+	caseCollisionsAuto = "auto"
+	caseCollisionsNone = "none"
+)
+
+const symlinksRestore = "restore"
+
 func (c *commandRestore) restoreOutput(ctx context.Context) (restore.Output, error) {
 	p, err := filepath.Abs(c.restoreTargetPath)
 	if err != nil {
@@ -124,6 +146,8 @@ func (c *commandRestore) restoreOutput(ctx context.Context) (restore.Output, err
 			SkipOwners:             c.restoreSkipOwners,
 			SkipPermissions:        c.restoreSkipPermissions,
 			SkipTimes:              c.restoreSkipTimes,
+			WindowsJunctions:       c.restoreWindowsJunctions,
+			CloneFiles:             c.restoreCloneFiles,
 		}, nil
 
 	case restoreModeZip, restoreModeZipNoCompress:
@@ -184,8 +208,34 @@ func (c *commandRestore) detectRestoreMode(ctx context.Context, m string) string
 	}
 }
 
+func (c *commandRestore) caseCollisionAction(ctx context.Context, output restore.Output) restore.CaseCollisionAction {
+	switch c.restoreCaseCollisions {
+	case caseCollisionsAuto:
+		if fo, ok := output.(*restore.FilesystemOutput); ok && restore.IsCaseInsensitiveFilesystem(fo.TargetPath) {
+			log(ctx).Infof("Target filesystem is case-insensitive, entries with names differing only by case will be renamed.")
+			return restore.CaseCollisionRename
+		}
+
+		return restore.CaseCollisionNone
+
+	case caseCollisionsNone:
+		return restore.CaseCollisionNone
+
+	default:
+		return restore.CaseCollisionAction(c.restoreCaseCollisions)
+	}
+}
+
+func (c *commandRestore) symlinkAction() restore.SymlinkAction {
+	if c.restoreSymlinks == symlinksRestore {
+		return restore.SymlinkRestore
+	}
+
+	return restore.SymlinkAction(c.restoreSymlinks)
+}
+
 func printRestoreStats(ctx context.Context, st restore.Stats) {
-	var maybeSkipped, maybeErrors string
+	var maybeSkipped, maybeErrors, maybeCollisions string
 
 	if st.SkippedCount > 0 {
 		maybeSkipped = fmt.Sprintf(", skipped %v (%v)", st.SkippedCount, units.BytesStringBase10(st.SkippedTotalFileSize))
@@ -195,12 +245,16 @@ func printRestoreStats(ctx context.Context, st restore.Stats) {
 		maybeErrors = fmt.Sprintf(", ignored %v errors", st.IgnoredErrorCount)
 	}
 
-	log(ctx).Infof("Restored %v files, %v directories and %v symbolic links (%v)%v%v.\n",
+	if st.CaseCollisionCount > 0 {
+		maybeCollisions = fmt.Sprintf(", %v case collisions", st.CaseCollisionCount)
+	}
+
+	log(ctx).Infof("Restored %v files, %v directories and %v symbolic links (%v)%v%v%v.\n",
 		st.RestoredFileCount,
 		st.RestoredDirCount,
 		st.RestoredSymlinkCount,
 		units.BytesStringBase10(st.RestoredTotalFileSize),
-		maybeSkipped, maybeErrors)
+		maybeSkipped, maybeErrors, maybeCollisions)
 }
 
 func (c *commandRestore) run(ctx context.Context, rep repo.Repository) error {
@@ -214,46 +268,61 @@ func (c *commandRestore) run(ctx context.Context, rep repo.Repository) error {
 		return errors.Wrap(err, "unable to get filesystem entry")
 	}
 
+	opts := restore.Options{
+		Parallel:       c.restoreParallel,
+		Incremental:    c.restoreIncremental,
+		IgnoreErrors:   c.restoreIgnoreErrors,
+		CaseCollisions: c.caseCollisionAction(ctx, output),
+		Symlinks:       c.symlinkAction(),
+	}
+
+	if fo, ok := output.(*restore.FilesystemOutput); ok && !c.restoreSkipPreflight {
+		if err := c.preflight(ctx, fo, rootEntry, opts); err != nil {
+			return err
+		}
+	}
+
+	if c.restorePreflightOnly {
+		return nil
+	}
+
 	eta := timetrack.Start()
 
-	st, err := restore.Entry(ctx, rep, output, rootEntry, restore.Options{
-		Parallel:     c.restoreParallel,
-		Incremental:  c.restoreIncremental,
-		IgnoreErrors: c.restoreIgnoreErrors,
-		ProgressCallback: func(ctx context.Context, stats restore.Stats) {
-			restoredCount := stats.RestoredFileCount + stats.RestoredDirCount + stats.RestoredSymlinkCount + stats.SkippedCount
-			enqueuedCount := stats.EnqueuedFileCount + stats.EnqueuedDirCount + stats.EnqueuedSymlinkCount
-
-			if restoredCount == 0 {
-				return
-			}
-
-			var maybeRemaining, maybeSkipped, maybeErrors string
-
-			if est, ok := eta.Estimate(float64(stats.RestoredTotalFileSize), float64(stats.EnqueuedTotalFileSize)); ok {
-				bitsPerSecond := est.SpeedPerSecond * float64(bitsPerByte)
-				maybeRemaining = fmt.Sprintf(" %v (%.1f%%) remaining %v",
-					units.BitsPerSecondsString(bitsPerSecond),
-					est.PercentComplete,
-					est.Remaining)
-			}
-
-			if stats.SkippedCount > 0 {
-				maybeSkipped = fmt.Sprintf(", skipped %v (%v)", stats.SkippedCount, units.BytesStringBase10(stats.SkippedTotalFileSize))
-			}
-
-			if stats.IgnoredErrorCount > 0 {
-				maybeErrors = fmt.Sprintf(", ignored %v errors", stats.IgnoredErrorCount)
-			}
-
-			log(ctx).Infof("Processed %v (%v) of %v (%v)%v%v%v.",
-				restoredCount, units.BytesStringBase10(stats.RestoredTotalFileSize),
-				enqueuedCount, units.BytesStringBase10(stats.EnqueuedTotalFileSize),
-				maybeSkipped,
-				maybeErrors,
-				maybeRemaining)
-		},
-	})
+	opts.ProgressCallback = func(ctx context.Context, stats restore.Stats) {
+		restoredCount := stats.RestoredFileCount + stats.RestoredDirCount + stats.RestoredSymlinkCount + stats.SkippedCount
+		enqueuedCount := stats.EnqueuedFileCount + stats.EnqueuedDirCount + stats.EnqueuedSymlinkCount
+
+		if restoredCount == 0 {
+			return
+		}
+
+		var maybeRemaining, maybeSkipped, maybeErrors string
+
+		if est, ok := eta.Estimate(float64(stats.RestoredTotalFileSize), float64(stats.EnqueuedTotalFileSize)); ok {
+			bitsPerSecond := est.SpeedPerSecond * float64(bitsPerByte)
+			maybeRemaining = fmt.Sprintf(" %v (%.1f%%) remaining %v",
+				units.BitsPerSecondsString(bitsPerSecond),
+				est.PercentComplete,
+				est.Remaining)
+		}
+
+		if stats.SkippedCount > 0 {
+			maybeSkipped = fmt.Sprintf(", skipped %v (%v)", stats.SkippedCount, units.BytesStringBase10(stats.SkippedTotalFileSize))
+		}
+
+		if stats.IgnoredErrorCount > 0 {
+			maybeErrors = fmt.Sprintf(", ignored %v errors", stats.IgnoredErrorCount)
+		}
+
+		log(ctx).Infof("Processed %v (%v) of %v (%v)%v%v%v.",
+			restoredCount, units.BytesStringBase10(stats.RestoredTotalFileSize),
+			enqueuedCount, units.BytesStringBase10(stats.EnqueuedTotalFileSize),
+			maybeSkipped,
+			maybeErrors,
+			maybeRemaining)
+	}
+
+	st, err := restore.Entry(ctx, rep, output, rootEntry, opts)
 	if err != nil {
 		return errors.Wrap(err, "error restoring")
 	}
@@ -262,3 +331,35 @@ func (c *commandRestore) run(ctx context.Context, rep repo.Repository) error {
 
 	return nil
 }
+
+func (c *commandRestore) preflight(ctx context.Context, fo *restore.FilesystemOutput, rootEntry fs.Entry, opts restore.Options) error {
+	r, err := fo.Preflight(ctx, rootEntry, opts)
+	if err != nil {
+		return errors.Wrap(err, "restore preflight failed")
+	}
+
+	maybeAvailable := "unknown"
+	if r.AvailableBytes >= 0 {
+		maybeAvailable = units.BytesStringBase10(r.AvailableBytes)
+	}
+
+	log(ctx).Infof("Restoring %v files, %v directories and %v symbolic links (%v) requires %v, available %v.",
+		r.FileCount, r.DirCount, r.SymlinkCount,
+		units.BytesStringBase10(r.TotalFileSize),
+		units.BytesStringBase10(r.RequiredBytes),
+		maybeAvailable)
+
+	if r.OK() {
+		return nil
+	}
+
+	for _, i := range r.Issues {
+		log(ctx).Errorf("%v: %v", i.Path, i.Problem)
+	}
+
+	if r.OmittedIssues > 0 {
+		log(ctx).Errorf("... and %v more issues", r.OmittedIssues)
+	}
+
+	return errors.Errorf("restore preflight found %v issues, nothing was restored (use --skip-preflight to restore anyway)", len(r.Issues)+r.OmittedIssues)
+}
@@ -8,16 +8,17 @@
 )
 
 type commandServer struct {
-	acl     commandServerACL
-	user    commandServerUser
-	cancel  commandServerCancel
-	flush   commandServerFlush
-	pause   commandServerPause
-	refresh commandServerRefresh
-	resume  commandServerResume
-	start   commandServerStart
-	status  commandServerStatus
-	upload  commandServerUpload
+	acl      commandServerACL
+	user     commandServerUser
+	cancel   commandServerCancel
+	flush    commandServerFlush
+	logLevel commandServerLogLevel
+	pause    commandServerPause
+	refresh  commandServerRefresh
+	resume   commandServerResume
+	start    commandServerStart
+	status   commandServerStatus
+	upload   commandServerUpload
 }
 
 type serverFlags struct {
@@ -47,6 +48,7 @@ func (c *commandServer) setup(svc advancedAppServices, parent commandParent) {
 
 	c.cancel.setup(svc, cmd)
 	c.flush.setup(svc, cmd)
+	c.logLevel.setup(svc, cmd)
 	c.pause.setup(svc, cmd)
 	c.refresh.setup(svc, cmd)
 	c.resume.setup(svc, cmd)
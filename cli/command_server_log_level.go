@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/internal/serverapi"
+)
+
+type commandServerLogLevel struct {
+	sf serverClientFlags
+
+	module string
+	level  string
+
+	out textOutput
+}
+
+func (c *commandServerLogLevel) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("log-level", "Display or change log levels of individual modules in a running server.")
+	cmd.Arg("module", "Logging module").StringVar(&c.module)
+	cmd.Arg("level", "New log level (debug, info, warning, error), empty to revert to the default").StringVar(&c.level)
+	c.sf.setup(cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerLogLevel) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	var (
+		resp *serverapi.LogLevelsResponse
+		err  error
+	)
+
+	if c.module == "" {
+		resp, err = serverapi.GetLogLevels(ctx, cli)
+	} else {
+		resp, err = serverapi.SetLogLevel(ctx, cli, &serverapi.ModuleLogLevel{Module: c.module, Level: c.level})
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to manage log levels")
+	}
+
+	for _, ml := range resp.Levels {
+		c.out.printStdout("%v=%v\n", ml.Module, ml.Level)
+	}
+
+	return nil
+}
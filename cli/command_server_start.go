@@ -22,6 +22,9 @@
 
 	"github.com/kopia/kopia/internal/auth"
 	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/logfile"
+	"github.com/kopia/kopia/internal/repodiag"
+	"github.com/kopia/kopia/internal/secrets"
 	"github.com/kopia/kopia/internal/server"
 	"github.com/kopia/kopia/repo"
 )
@@ -39,9 +42,12 @@ type commandServerStart struct {
 	serverStartInsecure        bool
 	serverStartMaxConcurrency  int
 
+	serverStartEventWebhookURL string
+
 	serverStartWithoutPassword bool
 	serverStartRandomPassword  bool
 	serverStartHtpasswdFile    string
+	serverStartHtpasswdSecret  string
 
 	serverAuthCookieSingingKey string
 
@@ -54,6 +60,10 @@ type commandServerStart struct {
 	serverStartTLSGenerateCertValidDays int
 	serverStartTLSGenerateCertNames     []string
 	serverStartTLSPrintFullServerCert   bool
+	serverStartTLSClientCAFile          string
+	serverStartTLSCertSecret            string
+	serverStartTLSKeySecret             string
+	serverStartSecretRefreshInterval    time.Duration
 	uiTitlePrefix                       string
 
 	sf  serverFlags
@@ -72,10 +82,12 @@ func (c *commandServerStart) setup(svc advancedAppServices, parent commandParent
 	cmd.Flag("refresh-interval", "Frequency for refreshing repository status").Default("300s").DurationVar(&c.serverStartRefreshInterval)
 	cmd.Flag("insecure", "Allow insecure configurations (do not use in production)").Hidden().BoolVar(&c.serverStartInsecure)
 	cmd.Flag("max-concurrency", "Maximum number of server goroutines").Default("0").IntVar(&c.serverStartMaxConcurrency)
+	cmd.Flag("event-webhook-url", "URL that receives JSON events when clients of any user connect, disconnect or fail authentication").Envar("KOPIA_SERVER_EVENT_WEBHOOK_URL").StringVar(&c.serverStartEventWebhookURL)
 
 	cmd.Flag("without-password", "Start the server without a password").Hidden().BoolVar(&c.serverStartWithoutPassword)
 	cmd.Flag("random-password", "Generate random password and print to stderr").Hidden().BoolVar(&c.serverStartRandomPassword)
 	cmd.Flag("htpasswd-file", "Path to htpasswd file that contains allowed user@hostname entries").Hidden().ExistingFileVar(&c.serverStartHtpasswdFile)
+	cmd.Flag("htpasswd-secret", "Secret holding htpasswd file contents (e.g. vault:secret/data/kopia#htpasswd)").Hidden().StringVar(&c.serverStartHtpasswdSecret)
 
 	cmd.Flag("auth-cookie-signing-key", "Force particular auth cookie signing key").Envar("KOPIA_AUTH_COOKIE_SIGNING_KEY").Hidden().StringVar(&c.serverAuthCookieSingingKey)
 
@@ -88,6 +100,10 @@ func (c *commandServerStart) setup(svc advancedAppServices, parent commandParent
 	cmd.Flag("tls-generate-cert-valid-days", "How long should the TLS certificate be valid").Default("3650").Hidden().IntVar(&c.serverStartTLSGenerateCertValidDays)
 	cmd.Flag("tls-generate-cert-name", "Host names/IP addresses to generate TLS certificate for").Default("127.0.0.1").Hidden().StringsVar(&c.serverStartTLSGenerateCertNames)
 	cmd.Flag("tls-print-server-cert", "Print server certificate").Hidden().BoolVar(&c.serverStartTLSPrintFullServerCert)
+	cmd.Flag("tls-client-ca-file", "Require repository clients to present TLS certificates for username@hostname issued by CA in the provided PEM file").StringVar(&c.serverStartTLSClientCAFile)
+	cmd.Flag("tls-cert-secret", "Secret holding TLS certificate PEM (e.g. vault:secret/data/kopia#cert or awssm:kopia-tls#cert)").StringVar(&c.serverStartTLSCertSecret)
+	cmd.Flag("tls-key-secret", "Secret holding TLS key PEM (e.g. vault:secret/data/kopia#key or awssm:kopia-tls#key)").StringVar(&c.serverStartTLSKeySecret)
+	cmd.Flag("secret-refresh-interval", "How often to check secrets for rotation (0 to disable)").Default("5m").DurationVar(&c.serverStartSecretRefreshInterval)
 
 	cmd.Flag("ui-title-prefix", "UI title prefix").Hidden().Envar("KOPIA_UI_TITLE_PREFIX").StringVar(&c.uiTitlePrefix)
 
@@ -108,6 +124,9 @@ func (c *commandServerStart) run(ctx context.Context, rep repo.Repository) error
 		return errors.Wrap(err, "unable to initialize authentication")
 	}
 
+	lm := repodiag.NewLogManager(clock.Now)
+	defer logfile.AddLogSink(lm)()
+
 	srv, err := server.New(ctx, server.Options{
 		ConfigFile:           c.svc.repositoryConfigFileName(),
 		ConnectOptions:       c.co.toRepoConnectOptions(),
@@ -118,11 +137,17 @@ func (c *commandServerStart) run(ctx context.Context, rep repo.Repository) error
 		AuthCookieSigningKey: c.serverAuthCookieSingingKey,
 		UIUser:               c.sf.serverUsername,
 		PasswordPersist:      c.svc.passwordPersistenceStrategy(),
+		LogManager:           lm,
+
+		RequireClientCertificates: c.serverStartTLSClientCAFile != "",
+		EventWebhookURL:           c.serverStartEventWebhookURL,
 	})
 	if err != nil {
 		return errors.Wrap(err, "unable to initialize server")
 	}
 
+	defer srv.Close(ctx)
+
 	if err = maybeAutoUpgradeRepository(ctx, rep); err != nil {
 		return errors.Wrap(err, "error upgrading repository")
 	}
@@ -179,6 +204,10 @@ func (c *commandServerStart) run(ctx context.Context, rep repo.Repository) error
 	}
 
 	onExternalConfigReloadRequest(func() {
+		if rerr := logfile.ReloadModuleLevels(ctx); rerr != nil {
+			log(ctx).Errorf("unable to reload log levels: %v", rerr)
+		}
+
 		if rerr := srv.Refresh(ctx); rerr != nil {
 			log(ctx).Errorf("refresh failed: %v", rerr)
 		}
@@ -193,25 +222,36 @@ func (c *commandServerStart) run(ctx context.Context, rep repo.Repository) error
 }
 
 func initPrometheus(mux *http.ServeMux) error {
+	_, pe, err := newPrometheusExporter()
+	if err != nil {
+		return err
+	}
+
+	mux.Handle("/metrics", pe)
+
+	return nil
+}
+
+// newPrometheusExporter returns a registry that gathers process, Go runtime and Kopia metrics
+// along with the exporter that serves them over HTTP.
+func newPrometheusExporter() (*prom.Registry, *prometheus.Exporter, error) {
 	reg := prom.NewRegistry()
 	if err := reg.Register(prom.NewProcessCollector(prom.ProcessCollectorOpts{})); err != nil {
-		return errors.Wrap(err, "error registering process collector")
+		return nil, nil, errors.Wrap(err, "error registering process collector")
 	}
 
 	if err := reg.Register(prom.NewGoCollector()); err != nil {
-		return errors.Wrap(err, "error registering go collector")
+		return nil, nil, errors.Wrap(err, "error registering go collector")
 	}
 
 	pe, err := prometheus.NewExporter(prometheus.Options{
 		Registry: reg,
 	})
 	if err != nil {
-		return errors.Wrap(err, "unable to initialize prometheus exporter")
+		return nil, nil, errors.Wrap(err, "unable to initialize prometheus exporter")
 	}
 
-	mux.Handle("/metrics", pe)
-
-	return nil
+	return reg, pe, nil
 }
 
 func stripProtocol(addr string) string {
@@ -287,6 +327,16 @@ func (c *commandServerStart) getAuthenticator(ctx context.Context) (auth.Authent
 		authenticators = append(authenticators, auth.AuthenticateHtpasswdFile(f))
 	}
 
+	// handle passwords from htpasswd file stored in a secret store.
+	if c.serverStartHtpasswdSecret != "" {
+		f, err := c.htpasswdFromSecret(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		authenticators = append(authenticators, auth.AuthenticateHtpasswdFile(f))
+	}
+
 	// handle UI password (--without-password, --password or --random-password)
 	switch {
 	case c.serverStartWithoutPassword:
@@ -322,3 +372,27 @@ func (c *commandServerStart) getAuthenticator(ctx context.Context) (auth.Authent
 
 	return auth.CombineAuthenticators(authenticators...), nil
 }
+
+// htpasswdFromSecret returns htpasswd file fetched from a secret store, which is reloaded when the secret is rotated.
+func (c *commandServerStart) htpasswdFromSecret(ctx context.Context) (*htpasswd.File, error) {
+	var f *htpasswd.File
+
+	if err := secrets.Watch(ctx, c.serverStartHtpasswdSecret, c.serverStartSecretRefreshInterval, func(v []byte) error {
+		if f == nil {
+			nf, err := htpasswd.NewFromReader(bytes.NewReader(v), htpasswd.DefaultSystems, nil)
+			if err != nil {
+				return errors.Wrap(err, "error parsing htpasswd")
+			}
+
+			f = nf
+
+			return nil
+		}
+
+		return errors.Wrap(f.ReloadFromReader(bytes.NewReader(v), nil), "error parsing htpasswd")
+	}); err != nil {
+		return nil, errors.Wrap(err, "error initializing htpasswd from secret")
+	}
+
+	return f, nil
+}
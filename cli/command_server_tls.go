@@ -14,10 +14,12 @@
 	"net"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/secrets"
 	"github.com/kopia/kopia/internal/tlsutil"
 )
 
@@ -81,14 +83,55 @@ func (c *commandServerStart) maybeGenerateTLS(ctx context.Context) error {
 	return nil
 }
 
+// tlsConfig returns TLS configuration, which optionally verifies client certificates.
+func (c *commandServerStart) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{} //nolint:gosec
+
+	if c.serverStartTLSClientCAFile != "" {
+		pool, err := tlsutil.LoadCertificatePool(c.serverStartTLSClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load client CA")
+		}
+
+		// client certificates are verified when presented, the server requires them
+		// from repository users, but not from the UI user.
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+}
+
 func (c *commandServerStart) startServerWithOptionalTLSAndListener(ctx context.Context, httpServer *http.Server, listener net.Listener) error {
 	if err := c.maybeGenerateTLS(ctx); err != nil {
 		return err
 	}
 
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+
 	switch {
+	case c.serverStartTLSCertSecret != "" && c.serverStartTLSKeySecret != "":
+		// PEM fetched from secret store, reloaded on rotation.
+		sc, err := c.certificateFromSecrets(ctx)
+		if err != nil {
+			return err
+		}
+
+		tlsConfig.GetCertificate = sc.get
+		httpServer.TLSConfig = tlsConfig
+
+		fmt.Fprintf(c.out.stderr(), "SERVER ADDRESS: https://%v\n", httpServer.Addr)
+		c.showServerUIPrompt(ctx)
+
+		return errors.Wrap(httpServer.ServeTLS(listener, "", ""), "error starting TLS server")
+
 	case c.serverStartTLSCertFile != "" && c.serverStartTLSKeyFile != "":
 		// PEM files provided
+		httpServer.TLSConfig = tlsConfig
+
 		fmt.Fprintf(c.out.stderr(), "SERVER ADDRESS: https://%v\n", httpServer.Addr)
 		c.showServerUIPrompt(ctx)
 
@@ -101,15 +144,14 @@ func (c *commandServerStart) startServerWithOptionalTLSAndListener(ctx context.C
 			return errors.Wrap(err, "unable to generate server cert")
 		}
 
-		httpServer.TLSConfig = &tls.Config{
-			MinVersion: tls.VersionTLS13,
-			Certificates: []tls.Certificate{
-				{
-					Certificate: [][]byte{cert.Raw},
-					PrivateKey:  key,
-				},
+		tlsConfig.MinVersion = tls.VersionTLS13
+		tlsConfig.Certificates = []tls.Certificate{
+			{
+				Certificate: [][]byte{cert.Raw},
+				PrivateKey:  key,
 			},
 		}
+		httpServer.TLSConfig = tlsConfig
 
 		fingerprint := sha256.Sum256(cert.Raw)
 		fmt.Fprintf(c.out.stderr(), "SERVER CERT SHA256: %v\n", hex.EncodeToString(fingerprint[:]))
@@ -135,6 +177,10 @@ func (c *commandServerStart) startServerWithOptionalTLSAndListener(ctx context.C
 			return errors.Errorf("TLS not configured. To start server without encryption pass --insecure.")
 		}
 
+		if c.serverStartTLSClientCAFile != "" {
+			return errors.Errorf("client certificates require TLS")
+		}
+
 		fmt.Fprintf(c.out.stderr(), "SERVER ADDRESS: http://%v\n", httpServer.Addr)
 		c.showServerUIPrompt(ctx)
 
@@ -142,6 +188,67 @@ func (c *commandServerStart) startServerWithOptionalTLSAndListener(ctx context.C
 	}
 }
 
+// secretCertificate holds TLS certificate fetched from a secret store, which is replaced
+// when the certificate or key secrets are rotated.
+type secretCertificate struct {
+	mu      sync.Mutex
+	certPEM []byte
+	keyPEM  []byte
+	cert    *tls.Certificate
+}
+
+func (s *secretCertificate) update(certPEM, keyPEM []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if certPEM != nil {
+		s.certPEM = certPEM
+	}
+
+	if keyPEM != nil {
+		s.keyPEM = keyPEM
+	}
+
+	if s.certPEM == nil || s.keyPEM == nil {
+		return nil
+	}
+
+	// certificate and key are rotated separately, keep serving previous certificate until they match.
+	cert, err := tls.X509KeyPair(s.certPEM, s.keyPEM)
+	if err != nil {
+		return errors.Wrap(err, "invalid TLS certificate or key")
+	}
+
+	s.cert = &cert
+
+	return nil
+}
+
+func (s *secretCertificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cert, nil
+}
+
+func (c *commandServerStart) certificateFromSecrets(ctx context.Context) (*secretCertificate, error) {
+	sc := &secretCertificate{}
+
+	if err := secrets.Watch(ctx, c.serverStartTLSCertSecret, c.serverStartSecretRefreshInterval, func(v []byte) error {
+		return sc.update(v, nil)
+	}); err != nil {
+		return nil, errors.Wrap(err, "unable to get TLS certificate")
+	}
+
+	if err := secrets.Watch(ctx, c.serverStartTLSKeySecret, c.serverStartSecretRefreshInterval, func(v []byte) error {
+		return sc.update(nil, v)
+	}); err != nil {
+		return nil, errors.Wrap(err, "unable to get TLS key")
+	}
+
+	return sc, nil
+}
+
 func (c *commandServerStart) showServerUIPrompt(ctx context.Context) {
 	if c.serverStartUI {
 		log(ctx).Infof("Open the address above in a web browser to use the UI.")
@@ -5,11 +5,18 @@ type commandSnapshot struct {
 	moveHistory commandSnapshotCopyMoveHistory
 	create      commandSnapshotCreate
 	delete      commandSnapshotDelete
+	deleted     commandSnapshotDeleted
 	estimate    commandSnapshotEstimate
 	expire      commandSnapshotExpire
+	export      commandSnapshotExport
 	gc          commandSnapshotGC
+	health      commandSnapshotHealth
+	importCmd   commandSnapshotImport
+	legalHold   commandSnapshotLegalHold
 	list        commandSnapshotList
 	migrate     commandSnapshotMigrate
+	replicate   commandSnapshotReplicate
+	replStatus  commandSnapshotReplicationStatus
 	restore     commandSnapshotRestore
 	verify      commandSnapshotVerify
 }
@@ -20,11 +27,18 @@ func (c *commandSnapshot) setup(svc advancedAppServices, parent commandParent) {
 	c.moveHistory.setup(svc, cmd, true)
 	c.create.setup(svc, cmd)
 	c.delete.setup(svc, cmd)
+	c.deleted.setup(svc, cmd)
 	c.estimate.setup(svc, cmd)
 	c.expire.setup(svc, cmd)
+	c.export.setup(svc, cmd)
 	c.gc.setup(svc, cmd)
+	c.health.setup(svc, cmd)
+	c.importCmd.setup(svc, cmd)
+	c.legalHold.setup(svc, cmd)
 	c.list.setup(svc, cmd)
 	c.migrate.setup(svc, cmd)
+	c.replicate.setup(svc, cmd)
+	c.replStatus.setup(svc, cmd)
 	c.restore.setup(svc, cmd)
 	c.verify.setup(svc, cmd)
 }
@@ -11,6 +11,7 @@
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
@@ -30,13 +31,19 @@ type commandSnapshotCreate struct {
 	snapshotCreateFailFast                bool
 	snapshotCreateForceHash               int
 	snapshotCreateParallelUploads         int
+	snapshotCreateParallelSources         int
 	snapshotCreateStartTime               string
 	snapshotCreateEndTime                 string
 	snapshotCreateForceEnableActions      bool
 	snapshotCreateForceDisableActions     bool
 	snapshotCreateStdinFileName           string
+	snapshotCreateStreams                 []string
 	snapshotCreateCheckpointUploadLimitMB int64
 	snapshotCreateTags                    []string
+	snapshotCreateCheckConsistency        bool
+	snapshotCreateCheckFilesPercent       int
+	snapshotCreateCheckMaxMB              int64
+	snapshotCreateHashCache               bool
 
 	jo  jsonOutput
 	svc appServices
@@ -54,12 +61,18 @@ func (c *commandSnapshotCreate) setup(svc appServices, parent commandParent) {
 	cmd.Flag("fail-fast", "Fail fast when creating snapshot.").Envar("KOPIA_SNAPSHOT_FAIL_FAST").BoolVar(&c.snapshotCreateFailFast)
 	cmd.Flag("force-hash", "Force hashing of source files for a given percentage of files [0..100]").Default("0").IntVar(&c.snapshotCreateForceHash)
 	cmd.Flag("parallel", "Upload N files in parallel").PlaceHolder("N").Default("0").IntVar(&c.snapshotCreateParallelUploads)
+	cmd.Flag("parallel-sources", "Snapshot up to N sources concurrently, sharing the file parallelism").PlaceHolder("N").Default("4").IntVar(&c.snapshotCreateParallelSources)
 	cmd.Flag("start-time", "Override snapshot start timestamp.").StringVar(&c.snapshotCreateStartTime)
 	cmd.Flag("end-time", "Override snapshot end timestamp.").StringVar(&c.snapshotCreateEndTime)
 	cmd.Flag("force-enable-actions", "Enable snapshot actions even if globally disabled on this client").Hidden().BoolVar(&c.snapshotCreateForceEnableActions)
 	cmd.Flag("force-disable-actions", "Disable snapshot actions even if globally enabled on this client").Hidden().BoolVar(&c.snapshotCreateForceDisableActions)
 	cmd.Flag("stdin-file", "File path to be used for stdin data snapshot.").StringVar(&c.snapshotCreateStdinFileName)
+	cmd.Flag("stream", "Snapshot named stream as a file, PATH is a named pipe or file ('-' for stdin). Can be repeated.").PlaceHolder("NAME=PATH").StringsVar(&c.snapshotCreateStreams)
 	cmd.Flag("tags", "Tags applied on the snapshot. Must be provided in the <key>:<value> format.").StringsVar(&c.snapshotCreateTags)
+	cmd.Flag("check-consistency", "Verify data written by the snapshot after it completes and fail on errors").BoolVar(&c.snapshotCreateCheckConsistency)
+	cmd.Flag("check-consistency-files-percent", "Percentage of new files read in full when checking consistency [0..100]").Default("10").IntVar(&c.snapshotCreateCheckFilesPercent)
+	cmd.Flag("check-consistency-max-mb", "Maximum amount of data (in MB) read when checking consistency (0 == unlimited)").PlaceHolder("MB").Default("1000").Int64Var(&c.snapshotCreateCheckMaxMB)
+	cmd.Flag("hash-cache", "Use local cache of file hashes to avoid re-hashing unchanged files when previous snapshots can't be used").Default("true").BoolVar(&c.snapshotCreateHashCache)
 
 	c.jo.setup(svc, cmd)
 	c.out.setup(svc)
@@ -96,37 +109,60 @@ func (c *commandSnapshotCreate) run(ctx context.Context, rep repo.RepositoryWrit
 		return errors.New("description too long")
 	}
 
-	u := c.setupUploader(rep)
-
-	var finalErrors []string
+	streams, err := c.snapshotStreams()
+	if err != nil {
+		return err
+	}
 
 	tags, err := getTags(c.snapshotCreateTags)
 	if err != nil {
 		return err
 	}
 
-	for _, snapshotDir := range sources {
-		if u.IsCanceled() {
-			log(ctx).Infof("Upload canceled")
-			break
-		}
+	var sourceInfos []snapshot.SourceInfo
 
+	for _, snapshotDir := range sources {
 		dir, err := filepath.Abs(snapshotDir)
 		if err != nil {
 			return errors.Errorf("invalid source: '%s': %s", snapshotDir, err)
 		}
 
-		sourceInfo := snapshot.SourceInfo{
+		sourceInfos = append(sourceInfos, snapshot.SourceInfo{
 			Path:     filepath.Clean(dir),
 			Host:     rep.ClientOptions().Hostname,
 			UserName: rep.ClientOptions().Username,
+		})
+	}
+
+	// streams are shared by all sources, so they can't be read concurrently by multiple uploads.
+	if len(sourceInfos) > 1 && len(streams) == 0 && c.snapshotCreateParallelSources > 1 {
+		return c.snapshotSourcesConcurrently(ctx, rep, sourceInfos, tags)
+	}
+
+	u := c.setupUploader(rep)
+
+	// all streams must be read concurrently, since they may be written to by a single process.
+	if len(streams) > u.ParallelUploads {
+		u.ParallelUploads = len(streams)
+	}
+
+	var finalErrors []string
+
+	for _, sourceInfo := range sourceInfos {
+		if u.IsCanceled() {
+			log(ctx).Infof("Upload canceled")
+			break
 		}
 
-		if err := c.snapshotSingleSource(ctx, rep, u, sourceInfo, tags); err != nil {
+		if _, err := c.snapshotSingleSource(ctx, rep, u, sourceInfo, tags, streams); err != nil {
 			finalErrors = append(finalErrors, err.Error())
 		}
 	}
 
+	return combinedSnapshotError(finalErrors)
+}
+
+func combinedSnapshotError(finalErrors []string) error {
 	if len(finalErrors) == 0 {
 		return nil
 	}
@@ -194,12 +230,17 @@ func (c *commandSnapshotCreate) setupUploader(rep repo.RepositoryWriter) *snapsh
 
 	if interval := c.snapshotCreateCheckpointInterval; interval != 0 {
 		u.CheckpointInterval = interval
+	} else if interval := rep.ClientOptions().CheckpointInterval; interval != 0 {
+		u.CheckpointInterval = interval
 	}
 
 	onCtrlC(u.Cancel)
 
 	u.ForceHashPercentage = c.snapshotCreateForceHash
 	u.ParallelUploads = c.snapshotCreateParallelUploads
+	if u.ParallelUploads == 0 {
+		u.ParallelUploads = rep.ClientOptions().UploadParallelism
+	}
 
 	u.FailFast = c.snapshotCreateFailFast
 	u.Progress = c.svc.getProgress()
@@ -222,7 +263,9 @@ func startTimeAfterEndTime(startTime, endTime time.Time) bool {
 		startTime.After(endTime)
 }
 
-func (c *commandSnapshotCreate) snapshotSingleSource(ctx context.Context, rep repo.RepositoryWriter, u *snapshotfs.Uploader, sourceInfo snapshot.SourceInfo, tags map[string]string) error {
+// snapshotSingleSource uploads and saves the snapshot of the provided source, the manifest is returned if it was saved,
+// even when the snapshot has errors.
+func (c *commandSnapshotCreate) snapshotSingleSource(ctx context.Context, rep repo.RepositoryWriter, u *snapshotfs.Uploader, sourceInfo snapshot.SourceInfo, tags map[string]string, streams []snapshotStream) (*snapshot.Manifest, error) {
 	log(ctx).Infof("Snapshotting %v ...", sourceInfo)
 
 	var (
@@ -231,37 +274,58 @@ func (c *commandSnapshotCreate) snapshotSingleSource(ctx context.Context, rep re
 		setManual bool
 	)
 
-	if c.snapshotCreateStdinFileName != "" {
-		// stdin source will be snapshotted using a virtual static root directory with a single streaming file entry
-		// Create a new static directory with the given name and add a streaming file entry with os.Stdin reader
-		fsEntry = virtualfs.NewStaticDirectory(sourceInfo.Path, fs.Entries{
-			virtualfs.StreamingFileFromReader(c.snapshotCreateStdinFileName, os.Stdin),
-		})
+	if len(streams) > 0 {
+		// streams will be snapshotted using a virtual static root directory with a streaming file entry per stream.
+		fsEntry = virtualfs.NewStaticDirectory(sourceInfo.Path, streamingEntries(streams))
 		setManual = true
 	} else {
 		fsEntry, err = getLocalFSEntry(ctx, sourceInfo.Path)
 		if err != nil {
-			return errors.Wrap(err, "unable to get local filesystem entry")
+			return nil, errors.Wrap(err, "unable to get local filesystem entry")
 		}
 	}
 
 	previous, err := findPreviousSnapshotManifest(ctx, rep, sourceInfo, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	policyTree, err := policy.TreeForSource(ctx, rep, sourceInfo)
 	if err != nil {
-		return errors.Wrap(err, "unable to get policy tree")
+		return nil, errors.Wrap(err, "unable to get policy tree")
 	}
 
 	log(ctx).Debugf("uploading %v using %v previous manifests", sourceInfo, len(previous))
 
+	var hashCacheFile string
+
+	if !setManual {
+		hashCacheFile = c.hashCacheFileName(rep, sourceInfo)
+	}
+
+	if hashCacheFile != "" {
+		u.HashCache, err = snapshotfs.LoadHashCache(hashCacheFile)
+		if err != nil {
+			log(ctx).Infof("Ignoring hash cache: %v", err)
+
+			u.HashCache = snapshotfs.NewHashCache()
+		}
+
+		defer func() { u.HashCache = nil }()
+	}
+
 	manifest, err := u.Upload(ctx, fsEntry, policyTree, sourceInfo, previous...)
 	if err != nil {
 		// fail-fast uploads will fail here without recording a manifest, other uploads will
 		// possibly fail later.
-		return errors.Wrap(err, "upload error")
+		return nil, errors.Wrap(err, "upload error")
+	}
+
+	// hash cache of an incomplete snapshot does not include files which were not visited.
+	if hashCacheFile != "" && manifest.IncompleteReason == "" {
+		if err := u.HashCache.Save(hashCacheFile); err != nil {
+			log(ctx).Errorf("unable to save hash cache: %v", err)
+		}
 	}
 
 	manifest.Description = c.snapshotCreateDescription
@@ -289,26 +353,85 @@ func (c *commandSnapshotCreate) snapshotSingleSource(ctx context.Context, rep re
 	}
 
 	if _, err = snapshot.SaveSnapshot(ctx, rep, manifest); err != nil {
-		return errors.Wrap(err, "cannot save manifest")
+		return nil, errors.Wrap(err, "cannot save manifest")
 	}
 
 	if _, err = policy.ApplyRetentionPolicy(ctx, rep, sourceInfo, true); err != nil {
-		return errors.Wrap(err, "unable to apply retention policy")
+		if !errors.Is(err, repo.ErrAccessDenied) {
+			return manifest, errors.Wrap(err, "unable to apply retention policy")
+		}
+
+		// append-only users can't delete snapshots, retention is applied by the repository owner.
+		log(ctx).Infof("Not applying retention policy, deleting snapshots is not allowed for this user.")
 	}
 
 	if setManual {
 		if err = policy.SetManual(ctx, rep, sourceInfo); err != nil {
-			return errors.Wrap(err, "unable to set manual field in scheduling policy for source")
+			return manifest, errors.Wrap(err, "unable to set manual field in scheduling policy for source")
 		}
 	}
 
 	if ferr := rep.Flush(ctx); ferr != nil {
-		return errors.Wrap(ferr, "flush error")
+		return manifest, errors.Wrap(ferr, "flush error")
 	}
 
 	c.svc.getProgress().Finish()
 
-	return c.reportSnapshotStatus(ctx, manifest)
+	if err := c.reportSnapshotStatus(ctx, manifest); err != nil {
+		return manifest, err
+	}
+
+	if c.snapshotCreateCheckConsistency {
+		return manifest, c.checkSnapshotConsistency(ctx, rep, manifest, previous)
+	}
+
+	return manifest, nil
+}
+
+// hashCacheFileName returns the name of the local hash cache file of the source or an empty string
+// if the hash cache is not used.
+func (c *commandSnapshotCreate) hashCacheFileName(rep repo.Repository, si snapshot.SourceInfo) string {
+	if !c.snapshotCreateHashCache {
+		return ""
+	}
+
+	// the cache is keyed by repository ID, which is only known when connected directly.
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok {
+		return ""
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return snapshotfs.HashCacheFileName(filepath.Join(cacheDir, "kopia", "hash-cache"), dr.UniqueID(), si)
+}
+
+func (c *commandSnapshotCreate) checkSnapshotConsistency(ctx context.Context, rep repo.Repository, manifest *snapshot.Manifest, previous []*snapshot.Manifest) error {
+	log(ctx).Infof("Checking consistency of snapshot %v ...", manifest.ID)
+
+	res, err := snapshotfs.CheckNewSnapshotData(ctx, rep, manifest, previous, snapshotfs.ConsistencyCheckOptions{
+		FilesPercent: c.snapshotCreateCheckFilesPercent,
+		MaxBytes:     c.snapshotCreateCheckMaxMB << 20, //nolint:gomnd
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to check snapshot consistency")
+	}
+
+	for _, e := range res.Errors {
+		log(ctx).Errorf("consistency check failed on %v: %v", e.Path, e.Error)
+	}
+
+	log(ctx).Infof("Checked %v new directories and %v new files, read %v files (%v).",
+		res.DirCount, res.FileCount, res.ReadCount, units.BytesStringBase10(res.ReadBytes))
+
+	if len(res.Errors) > 0 {
+		return errors.Errorf("consistency check of snapshot %v found %v errors", manifest.ID, len(res.Errors))
+	}
+
+	return nil
 }
 
 func (c *commandSnapshotCreate) reportSnapshotStatus(ctx context.Context, manifest *snapshot.Manifest) error {
@@ -328,6 +451,10 @@ func (c *commandSnapshotCreate) reportSnapshotStatus(ctx context.Context, manife
 
 	log(ctx).Infof("Created%v snapshot with root %v and ID %v in %v", maybePartial, manifest.RootObjectID(), snapID, manifest.EndTime.Sub(manifest.StartTime).Truncate(time.Second))
 
+	for _, sf := range manifest.SkippedFiles {
+		log(ctx).Errorf("Skipped unreadable file %v: %v", sf.EntryPath, sf.Error)
+	}
+
 	if ds := manifest.RootEntry.DirSummary; ds != nil {
 		if ds.IgnoredErrorCount > 0 {
 			log(ctx).Errorf("Ignored %v error(s) while snapshotting %v.", ds.IgnoredErrorCount, sourceInfo)
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// snapshotSourcesConcurrently uploads multiple sources at the same time using a separate uploader for each source.
+// The file parallelism budget specified by --parallel is divided among sources being uploaded concurrently
+// and progress is reported for all sources combined.
+func (c *commandSnapshotCreate) snapshotSourcesConcurrently(ctx context.Context, rep repo.RepositoryWriter, sources []snapshot.SourceInfo, tags map[string]string) error {
+	concurrency := c.snapshotCreateParallelSources
+	if concurrency > len(sources) {
+		concurrency = len(sources)
+	}
+
+	budget := c.snapshotCreateParallelUploads
+	if budget == 0 {
+		budget = runtime.NumCPU()
+	}
+
+	perSource := budget / concurrency
+	if perSource < 1 {
+		perSource = 1
+	}
+
+	log(ctx).Infof("Snapshotting %v sources, up to %v at a time.", len(sources), concurrency)
+
+	var (
+		mu          sync.Mutex
+		finalErrors []string
+		manifests   []*snapshot.Manifest
+
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, concurrency)
+		canceled int32
+	)
+
+	onCtrlC(func() { atomic.StoreInt32(&canceled, 1) })
+
+	startTime := clock.Now()
+
+	c.svc.getProgress().StartShared()
+
+	for _, si := range sources {
+		sem <- struct{}{}
+
+		if atomic.LoadInt32(&canceled) != 0 {
+			<-sem
+
+			log(ctx).Infof("Upload canceled")
+
+			break
+		}
+
+		wg.Add(1)
+
+		go func(si snapshot.SourceInfo) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			u := c.setupUploader(rep)
+			u.ParallelUploads = perSource
+
+			man, err := c.snapshotSingleSource(ctx, rep, u, si, tags, nil)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if man != nil {
+				manifests = append(manifests, man)
+			}
+
+			if err != nil {
+				finalErrors = append(finalErrors, fmt.Sprintf("%v: %v", si, err))
+			}
+		}(si)
+	}
+
+	wg.Wait()
+
+	c.svc.getProgress().FinishShared()
+	c.out.printStderr("\r\n")
+
+	c.reportCombinedSummary(ctx, manifests, len(finalErrors), clock.Since(startTime))
+
+	return combinedSnapshotError(finalErrors)
+}
+
+func (c *commandSnapshotCreate) reportCombinedSummary(ctx context.Context, manifests []*snapshot.Manifest, numErrors int, elapsed time.Duration) {
+	var (
+		partial    int
+		totalFiles int64
+		totalBytes int64
+	)
+
+	for _, m := range manifests {
+		if m.IncompleteReason != "" {
+			partial++
+		}
+
+		totalFiles += int64(m.Stats.TotalFileCount)
+		totalBytes += m.Stats.TotalFileSize
+	}
+
+	log(ctx).Infof("Created %v snapshot(s) (%v partial) with %v files of total size %v in %v, %v source(s) had errors.",
+		len(manifests), partial, totalFiles, units.BytesStringBase10(totalBytes), elapsed.Truncate(time.Second), numErrors)
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+)
+
+// stdinStreamPath is the stream path that refers to standard input.
+const stdinStreamPath = "-"
+
+// snapshotStream is a named stream captured as a virtual file in a snapshot.
+type snapshotStream struct {
+	name string
+	path string
+}
+
+// snapshotStreams returns the streams to be captured based on --stdin-file and --stream flags.
+func (c *commandSnapshotCreate) snapshotStreams() ([]snapshotStream, error) {
+	var result []snapshotStream
+
+	if c.snapshotCreateStdinFileName != "" {
+		result = append(result, snapshotStream{c.snapshotCreateStdinFileName, stdinStreamPath})
+	}
+
+	for _, s := range c.snapshotCreateStreams {
+		p := strings.Index(s, "=")
+		if p <= 0 || p == len(s)-1 {
+			return nil, errors.Errorf("invalid stream %q, must be NAME=PATH", s)
+		}
+
+		result = append(result, snapshotStream{s[0:p], s[p+1:]})
+	}
+
+	names := map[string]bool{}
+	usesStdin := false
+
+	for _, st := range result {
+		if strings.ContainsAny(st.name, `/\`) {
+			return nil, errors.Errorf("invalid stream name %q", st.name)
+		}
+
+		if names[st.name] {
+			return nil, errors.Errorf("duplicate stream name %q", st.name)
+		}
+
+		names[st.name] = true
+
+		if st.path == stdinStreamPath {
+			if usesStdin {
+				return nil, errors.New("standard input can be used by only one stream")
+			}
+
+			usesStdin = true
+		}
+	}
+
+	return result, nil
+}
+
+// streamingEntries returns streaming file entries for the provided streams. Streams other than stdin are opened
+// only when they're read, so that named pipes whose writers start after the snapshot don't block.
+func streamingEntries(streams []snapshotStream) fs.Entries {
+	var entries fs.Entries
+
+	for _, st := range streams {
+		if st.path == stdinStreamPath {
+			entries = append(entries, virtualfs.StreamingFileFromReader(st.name, os.Stdin))
+			continue
+		}
+
+		path := st.path
+
+		entries = append(entries, virtualfs.StreamingFileWithOpener(st.name, func(ctx context.Context) (io.Reader, error) {
+			f, err := os.Open(path) //nolint:gosec
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to open stream")
+			}
+
+			return f, nil
+		}))
+	}
+
+	return entries
+}
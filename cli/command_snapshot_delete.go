@@ -47,6 +47,10 @@ func (c *commandSnapshotDelete) run(ctx context.Context, rep repo.RepositoryWrit
 func (c *commandSnapshotDelete) deleteSnapshot(ctx context.Context, rep repo.RepositoryWriter, m *snapshot.Manifest) error {
 	desc := fmt.Sprintf("snapshot %v of %v at %v", m.ID, m.Source, formatTimestamp(m.StartTime))
 
+	if err := snapshot.VerifyNotUnderLegalHold(ctx, rep, m.ID); err != nil {
+		return errors.Wrapf(err, "unable to delete %v", desc)
+	}
+
 	if !c.snapshotDeleteConfirm {
 		log(ctx).Infof("Would delete %v (pass --delete to confirm)\n", desc)
 		return nil
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+type commandSnapshotDeleted struct {
+	source     string
+	maxResults int
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandSnapshotDeleted) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("deleted", "List files and directories present in older snapshots of a source, but missing in the latest one.").Alias("trash")
+	cmd.Arg("source", "Source path or user@host:/path").Required().StringVar(&c.source)
+	cmd.Flag("max-results", "Maximum number of entries to list (0 == unlimited)").Default("100").IntVar(&c.maxResults)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandSnapshotDeleted) run(ctx context.Context, rep repo.Repository) error {
+	src, err := snapshot.ParseSourceInfo(c.source, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+	if err != nil {
+		return errors.Wrapf(err, "error parsing %q", c.source)
+	}
+
+	manifests, err := snapshot.ListSnapshots(ctx, rep, src)
+	if err != nil {
+		return errors.Wrapf(err, "unable to list snapshots of %v", src)
+	}
+
+	entries, err := snapshotfs.FindDeletedEntries(ctx, rep, manifests, snapshotfs.DeletedEntriesOptions{
+		MaxResults: c.maxResults,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error finding deleted entries")
+	}
+
+	if c.jo.jsonOutput {
+		var jl jsonList
+
+		jl.begin(&c.jo)
+		defer jl.end()
+
+		for _, e := range entries {
+			jl.emit(e)
+		}
+
+		return nil
+	}
+
+	for _, e := range entries {
+		name := e.Path
+		if e.IsDir {
+			name += "/"
+		}
+
+		c.out.printStdout("%v %10v %v\n", formatTimestamp(e.LastSnapshotTime), units.BytesStringBase10(e.Size), name)
+		c.out.printStdout("  restore: kopia restore %v <target-path>\n", e.RestoreRoot)
+	}
+
+	c.out.printStderr("Found %v deleted entries in %v snapshots of %v.\n", len(entries), len(manifests), src)
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotarchive"
+)
+
+type commandSnapshotExport struct {
+	exportOutput          string
+	exportSources         []string
+	exportAll             bool
+	exportLatestOnly      bool
+	exportArchivePassword string
+
+	svc appServices
+	out textOutput
+}
+
+func (c *commandSnapshotExport) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("export", "Export snapshots to a self-contained encrypted archive which can be imported into another repository.")
+	cmd.Arg("source", "Sources to export").StringsVar(&c.exportSources)
+	cmd.Flag("output", "Archive file to write").Short('o').Required().StringVar(&c.exportOutput)
+	cmd.Flag("all", "Export snapshots of all sources").BoolVar(&c.exportAll)
+	cmd.Flag("latest-only", "Only export the latest snapshot of each source").BoolVar(&c.exportLatestOnly)
+	cmd.Flag("archive-password", "Password used to encrypt the archive").Envar("KOPIA_ARCHIVE_PASSWORD").StringVar(&c.exportArchivePassword)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+
+	c.svc = svc
+	c.out.setup(svc)
+}
+
+func (c *commandSnapshotExport) getSourcesToExport(ctx context.Context, rep repo.Repository) ([]snapshot.SourceInfo, error) {
+	if c.exportAll {
+		// nolint:wrapcheck
+		return snapshot.ListSources(ctx, rep)
+	}
+
+	if len(c.exportSources) == 0 {
+		return nil, errors.New("must specify sources to export or --all")
+	}
+
+	var result []snapshot.SourceInfo
+
+	for _, p := range c.exportSources {
+		src, err := snapshot.ParseSourceInfo(p, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse %q", p)
+		}
+
+		result = append(result, src)
+	}
+
+	return result, nil
+}
+
+func (c *commandSnapshotExport) getSnapshotsToExport(ctx context.Context, rep repo.Repository) ([]*snapshot.Manifest, error) {
+	sources, err := c.getSourcesToExport(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*snapshot.Manifest
+
+	for _, src := range sources {
+		snapshots, err := snapshot.ListSnapshots(ctx, rep, src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing snapshots of %v", src)
+		}
+
+		var complete []*snapshot.Manifest
+
+		for _, m := range snapshots {
+			if m.IncompleteReason == "" {
+				complete = append(complete, m)
+			}
+		}
+
+		sort.Slice(complete, func(i, j int) bool {
+			return complete[i].StartTime.After(complete[j].StartTime)
+		})
+
+		if c.exportLatestOnly && len(complete) > 0 {
+			complete = complete[0:1]
+		}
+
+		result = append(result, complete...)
+	}
+
+	return result, nil
+}
+
+func (c *commandSnapshotExport) run(ctx context.Context, rep repo.DirectRepository) (err error) {
+	manifests, err := c.getSnapshotsToExport(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if len(manifests) == 0 {
+		return errors.New("no snapshots to export")
+	}
+
+	pass := c.exportArchivePassword
+	if pass == "" {
+		if pass, err = askForNewArchivePassword(c.svc.stdout()); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(c.exportOutput)
+	if err != nil {
+		return errors.Wrap(err, "unable to create archive file")
+	}
+
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = errors.Wrap(cerr, "error closing archive file")
+		}
+
+		if err != nil {
+			os.Remove(c.exportOutput) //nolint:errcheck
+		}
+	}()
+
+	st, err := snapshotarchive.Export(ctx, rep, manifests, f, pass)
+	if err != nil {
+		return errors.Wrap(err, "error exporting snapshots")
+	}
+
+	c.out.printStdout("Exported %v snapshots (%v contents, %v) to %v\n", st.Snapshots, st.Contents, units.BytesStringBase10(st.Bytes), c.exportOutput)
+
+	return nil
+}
+
+func askForNewArchivePassword(out io.Writer) (string, error) {
+	for {
+		p1, err := askPass(out, "Enter password to encrypt the archive: ")
+		if err != nil {
+			return "", errors.Wrap(err, "password entry")
+		}
+
+		p2, err := askPass(out, "Re-enter password for verification: ")
+		if err != nil {
+			return "", errors.Wrap(err, "password verification")
+		}
+
+		if p1 != p2 {
+			fmt.Fprintln(out, "Passwords don't match!")
+		} else {
+			return p1, nil
+		}
+	}
+}
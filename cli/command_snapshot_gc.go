@@ -7,24 +7,28 @@
 
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
-	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/snapshot/snapshotgc"
 )
 
 type commandSnapshotGC struct {
 	snapshotGCDelete bool
-	snapshotGCSafety maintenance.SafetyParameters
+	snapshotGCSafety safetyFlag
 }
 
 func (c *commandSnapshotGC) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("gc", "Mark contents as deleted which are not used by any snapshot").Hidden()
 	cmd.Flag("delete", "Delete unreferenced contents").BoolVar(&c.snapshotGCDelete)
-	safetyFlagVar(cmd, &c.snapshotGCSafety)
+	c.snapshotGCSafety.setup(cmd)
 	cmd.Action(svc.directRepositoryWriteAction(c.run))
 }
 
 func (c *commandSnapshotGC) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
-	st, err := snapshotgc.Run(ctx, rep, c.snapshotGCDelete, c.snapshotGCSafety)
+	safety, err := c.snapshotGCSafety.parameters(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	st, err := snapshotgc.Run(ctx, rep, c.snapshotGCDelete, safety)
 
 	log(ctx).Infof("GC found %v unused contents (%v bytes)", st.UnusedCount, units.BytesStringBase2(st.UnusedBytes))
 	log(ctx).Infof("GC found %v unused contents that are too recent to delete (%v bytes)", st.TooRecentCount, units.BytesStringBase2(st.TooRecentBytes))
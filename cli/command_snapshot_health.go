@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshothealth"
+)
+
+type commandSnapshotHealth struct {
+	thresholds snapshothealth.Thresholds
+	failOn     string
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandSnapshotHealth) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("health", "Report whether sources are snapshotted according to their schedules.")
+	cmd.Flag("warn-missed-runs", "Number of missed scheduled snapshots after which the source is yellow (0 disables)").Default("1").IntVar(&c.thresholds.WarnMissedRuns)
+	cmd.Flag("critical-missed-runs", "Number of missed scheduled snapshots after which the source is red (0 disables)").Default("3").IntVar(&c.thresholds.CriticalMissedRuns)
+	cmd.Flag("warn-failures", "Number of consecutive failed snapshots after which the source is yellow (0 disables)").Default("1").IntVar(&c.thresholds.WarnConsecutiveFailures)
+	cmd.Flag("critical-failures", "Number of consecutive failed snapshots after which the source is red (0 disables)").Default("3").IntVar(&c.thresholds.CriticalConsecutiveFailures)
+	cmd.Flag("grace-period", "Time after the scheduled snapshot time after which the snapshot is considered missed").Default("15m").DurationVar(&c.thresholds.GracePeriod)
+	cmd.Flag("fail-on", "Fail the command if any source has at least the provided status").Default("never").EnumVar(&c.failOn, "never", string(snapshothealth.StatusYellow), string(snapshothealth.StatusRed))
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandSnapshotHealth) run(ctx context.Context, rep repo.Repository) error {
+	report, err := snapshothealth.EvaluateAll(ctx, rep, clock.Now(), c.thresholds)
+	if err != nil {
+		return errors.Wrap(err, "unable to evaluate snapshot health")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(report))
+	} else {
+		c.printReport(report)
+	}
+
+	if c.failOn != "never" && report.Status.AtLeast(snapshothealth.Status(c.failOn)) {
+		return errors.Errorf("snapshot health is %v", report.Status)
+	}
+
+	return nil
+}
+
+func (c *commandSnapshotHealth) printReport(report *snapshothealth.Report) {
+	for _, h := range report.Sources {
+		lastSuccess := "never"
+		if h.LastSuccessTime != nil {
+			lastSuccess = formatTimestamp(*h.LastSuccessTime)
+		}
+
+		c.out.printStdout("%-6v %v\n", strings.ToUpper(string(h.Status)), h.Source)
+		c.out.printStdout("  last success: %v, missed runs: %v, consecutive failures: %v\n", lastSuccess, h.MissedRuns, h.ConsecutiveFailures)
+
+		for _, r := range h.Reasons {
+			c.out.printStdout("  %v\n", r)
+		}
+	}
+
+	c.out.printStdout("Overall status: %v\n", strings.ToUpper(string(report.Status)))
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotarchive"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+type commandSnapshotImport struct {
+	importArchive         string
+	importArchivePassword string
+
+	svc appServices
+	out textOutput
+}
+
+func (c *commandSnapshotImport) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("import", "Import snapshots from an archive created using 'snapshot export'.")
+	cmd.Arg("archive", "Archive file to import").Required().ExistingFileVar(&c.importArchive)
+	cmd.Flag("archive-password", "Password used to encrypt the archive").Envar("KOPIA_ARCHIVE_PASSWORD").StringVar(&c.importArchivePassword)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+
+	c.svc = svc
+	c.out.setup(svc)
+}
+
+func (c *commandSnapshotImport) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	pass := c.importArchivePassword
+	if pass == "" {
+		p, err := askPass(c.svc.stdout(), "Enter archive password: ")
+		if err != nil {
+			return errors.Wrap(err, "password entry")
+		}
+
+		pass = p
+	}
+
+	f, err := os.Open(c.importArchive)
+	if err != nil {
+		return errors.Wrap(err, "unable to open archive")
+	}
+
+	a, err := snapshotarchive.Open(ctx, f, pass)
+	if err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return errors.Wrap(err, "unable to open archive")
+	}
+
+	defer a.Close(ctx) //nolint:errcheck
+
+	uploader := snapshotfs.NewUploader(rep)
+	uploader.Progress = c.svc.getProgress()
+
+	onCtrlC(uploader.Cancel)
+
+	c.svc.getProgress().StartShared()
+
+	st, err := a.Import(ctx, uploader, rep)
+
+	c.svc.getProgress().FinishShared()
+	c.out.printStderr("\r\n")
+
+	if err != nil {
+		return errors.Wrap(err, "error importing snapshots")
+	}
+
+	c.out.printStdout("Imported %v snapshots, skipped %v existing.\n", st.Imported, st.Skipped)
+
+	return nil
+}
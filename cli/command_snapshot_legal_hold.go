@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+type commandSnapshotLegalHold struct {
+	add    commandSnapshotLegalHoldAdd
+	list   commandSnapshotLegalHoldList
+	remove commandSnapshotLegalHoldRemove
+}
+
+func (c *commandSnapshotLegalHold) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("legal-hold", "Manage legal holds preventing deletion of snapshots.")
+
+	c.add.setup(svc, cmd)
+	c.list.setup(svc, cmd)
+	c.remove.setup(svc, cmd)
+}
+
+type commandSnapshotLegalHoldAdd struct {
+	snapshotIDs []string
+	reason      string
+
+	out textOutput
+}
+
+func (c *commandSnapshotLegalHoldAdd) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("add", "Place a legal hold on snapshots.")
+	cmd.Arg("id", "Snapshot ID").Required().StringsVar(&c.snapshotIDs)
+	cmd.Flag("reason", "Reason for the legal hold").StringVar(&c.reason)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+}
+
+func (c *commandSnapshotLegalHoldAdd) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	for _, id := range c.snapshotIDs {
+		h, err := snapshot.AddLegalHold(ctx, rep, manifest.ID(id), c.reason)
+		if err != nil {
+			return errors.Wrapf(err, "unable to place legal hold on %v", id)
+		}
+
+		c.out.printStdout("Placed legal hold %v on snapshot %v.\n", h.ID, h.SnapshotID)
+	}
+
+	return nil
+}
+
+type commandSnapshotLegalHoldList struct {
+	snapshotID string
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandSnapshotLegalHoldList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List legal holds.").Alias("ls")
+	cmd.Arg("id", "Snapshot ID").StringVar(&c.snapshotID)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandSnapshotLegalHoldList) run(ctx context.Context, rep repo.Repository) error {
+	holds, err := snapshot.ListLegalHolds(ctx, rep, manifest.ID(c.snapshotID))
+	if err != nil {
+		return errors.Wrap(err, "error listing legal holds")
+	}
+
+	var jl jsonList
+
+	jl.begin(&c.jo)
+	defer jl.end()
+
+	for _, h := range holds {
+		if c.jo.jsonOutput {
+			jl.emit(legalHoldListItem{h.ID, h})
+		} else {
+			c.out.printStdout("id:%v snapshot:%v created:%v by:%v reason:%q\n", h.ID, h.SnapshotID, formatTimestamp(h.CreatedAt), h.CreatedBy, h.Reason)
+		}
+	}
+
+	return nil
+}
+
+type legalHoldListItem struct {
+	ID manifest.ID `json:"id"`
+	*snapshot.LegalHold
+}
+
+type commandSnapshotLegalHoldRemove struct {
+	holdIDs []string
+	confirm bool
+}
+
+func (c *commandSnapshotLegalHoldRemove) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("remove", "Remove legal holds.").Alias("rm").Alias("delete")
+	cmd.Arg("id", "Legal hold ID").Required().StringsVar(&c.holdIDs)
+	cmd.Flag("delete", "Really remove").BoolVar(&c.confirm)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+}
+
+func (c *commandSnapshotLegalHoldRemove) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	for _, id := range c.holdIDs {
+		if !c.confirm {
+			log(ctx).Infof("would remove legal hold %v, pass --delete to actually remove", id)
+			continue
+		}
+
+		if err := snapshot.RemoveLegalHold(ctx, rep, manifest.ID(id)); err != nil {
+			return errors.Wrapf(err, "unable to remove legal hold %v", id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/snapshotreplication"
+)
+
+type commandSnapshotReplicate struct {
+	replicateSourceConfig string
+	replicateSources      []string
+	replicateInterval     time.Duration
+
+	svc advancedAppServices
+	out textOutput
+}
+
+func (c *commandSnapshotReplicate) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("replicate", "Incrementally replicate new snapshots from another repository")
+	cmd.Flag("source-config", "Configuration file for the source repository").Required().ExistingFileVar(&c.replicateSourceConfig)
+	cmd.Flag("interval", "Keep replicating at the provided interval until interrupted (0 replicates once)").DurationVar(&c.replicateInterval)
+	cmd.Arg("source", "Sources to replicate (all sources if not specified)").StringsVar(&c.replicateSources)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+
+	c.svc = svc
+	c.out.setup(svc)
+}
+
+func (c *commandSnapshotReplicate) run(ctx context.Context, destRepo repo.RepositoryWriter) error {
+	sourceRepo, err := c.openSourceRepo(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer sourceRepo.Close(ctx) //nolint:errcheck
+
+	var opt snapshotreplication.Options
+
+	for _, s := range c.replicateSources {
+		si, err := snapshot.ParseSourceInfo(s, sourceRepo.ClientOptions().Hostname, sourceRepo.ClientOptions().Username)
+		if err != nil {
+			return errors.Wrapf(err, "invalid source: '%s'", s)
+		}
+
+		opt.Sources = append(opt.Sources, si)
+	}
+
+	var (
+		mu       sync.Mutex
+		canceled = make(chan struct{})
+		uploader *snapshotfs.Uploader
+	)
+
+	onCtrlC(func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		select {
+		case <-canceled:
+		default:
+			close(canceled)
+
+			if uploader != nil {
+				uploader.Cancel()
+			}
+		}
+	})
+
+	for {
+		mu.Lock()
+		uploader = snapshotfs.NewUploader(destRepo)
+		uploader.Progress = c.svc.getProgress()
+		u := uploader
+		mu.Unlock()
+
+		if err := c.replicateOnce(ctx, u, sourceRepo, destRepo, opt); err != nil {
+			return err
+		}
+
+		if c.replicateInterval <= 0 || u.IsCanceled() {
+			return nil
+		}
+
+		log(ctx).Infof("Next replication in %v.", c.replicateInterval)
+
+		select {
+		case <-canceled:
+			return nil
+		case <-time.After(c.replicateInterval):
+		}
+
+		if err := sourceRepo.Refresh(ctx); err != nil {
+			return errors.Wrap(err, "unable to refresh source repository")
+		}
+	}
+}
+
+func (c *commandSnapshotReplicate) replicateOnce(ctx context.Context, uploader *snapshotfs.Uploader, sourceRepo repo.DirectRepository, destRepo repo.RepositoryWriter, opt snapshotreplication.Options) error {
+	c.svc.getProgress().StartShared()
+
+	st, err := snapshotreplication.Replicate(ctx, uploader, sourceRepo, destRepo, opt)
+
+	c.svc.getProgress().FinishShared()
+	c.out.printStderr("\r\n")
+
+	if err != nil {
+		return errors.Wrap(err, "error replicating snapshots")
+	}
+
+	log(ctx).Infof("Replicated %v snapshots, skipped %v existing.", st.Replicated, st.Skipped)
+
+	return nil
+}
+
+func (c *commandSnapshotReplicate) openSourceRepo(ctx context.Context) (repo.DirectRepository, error) {
+	pass, err := c.svc.passwordPersistenceStrategy().GetPassword(ctx, c.replicateSourceConfig)
+	if err != nil {
+		pass, err = c.svc.getPasswordFromFlags(ctx, false, false)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "source repository password")
+	}
+
+	sourceRepo, err := repo.Open(ctx, c.replicateSourceConfig, pass, c.svc.optionsFromFlags(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't open source repository")
+	}
+
+	dr, ok := sourceRepo.(repo.DirectRepository)
+	if !ok {
+		sourceRepo.Close(ctx) //nolint:errcheck
+
+		return nil, errors.New("replication requires direct connection to the source repository")
+	}
+
+	return dr, nil
+}
+
+type commandSnapshotReplicationStatus struct {
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandSnapshotReplicationStatus) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("replication-status", "Show status of snapshot replication into this repository")
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandSnapshotReplicationStatus) run(ctx context.Context, rep repo.Repository) error {
+	states, err := snapshotreplication.ListStates(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to list replication status")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(states))
+		return nil
+	}
+
+	for _, s := range states {
+		c.out.printStdout("%v from %v: %v snapshots, last snapshot %v, last replicated %v\n",
+			s.Source, s.SourceRepositoryID, s.Replicated, formatTimestamp(s.LastStartTime), formatTimestamp(s.LastReplication))
+	}
+
+	return nil
+}
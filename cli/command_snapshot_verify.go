@@ -5,10 +5,14 @@
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"strings"
 	"sync"
+	"time"
 
+	atunits "github.com/alecthomas/units"
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/iocopy"
 	"github.com/kopia/kopia/internal/parallelwork"
 	"github.com/kopia/kopia/internal/timetrack"
@@ -17,6 +21,7 @@
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
@@ -28,6 +33,11 @@ type commandSnapshotVerify struct {
 	verifyCommandSources        []string
 	verifyCommandParallel       int
 	verifyCommandFilesPercent   int
+	verifyCommandCaseCollisions bool
+	verifyCommandMaxDuration    time.Duration
+	verifyCommandMaxReadBytes   atunits.Base2Bytes
+
+	svc appServices
 }
 
 func (c *commandSnapshotVerify) setup(svc appServices, parent commandParent) {
@@ -39,7 +49,12 @@ func (c *commandSnapshotVerify) setup(svc appServices, parent commandParent) {
 	cmd.Flag("sources", "Verify the provided sources").StringsVar(&c.verifyCommandSources)
 	cmd.Flag("parallel", "Parallelization").Default("16").IntVar(&c.verifyCommandParallel)
 	cmd.Flag("verify-files-percent", "Randomly verify a percentage of files").Default("0").IntVar(&c.verifyCommandFilesPercent)
+	cmd.Flag("report-case-collisions", "Report entries whose names differ only by case, which collide when restored to case-insensitive filesystems").BoolVar(&c.verifyCommandCaseCollisions)
+	cmd.Flag("max-duration", "Read files until the specified time elapses, resuming where the previous verification left off").DurationVar(&c.verifyCommandMaxDuration)
+	cmd.Flag("max-read-bytes", "Read files until the specified number of bytes is read, resuming where the previous verification left off").BytesVar(&c.verifyCommandMaxReadBytes)
 	cmd.Action(svc.repositoryReaderAction(c.run))
+
+	c.svc = svc
 }
 
 type verifier struct {
@@ -56,6 +71,12 @@ type verifier struct {
 
 	errorsThreshold      int
 	downloadFilesPercent int
+
+	reportCaseCollisions bool
+	caseCollisions       int
+
+	// when non-nil, files are read in a resumable order within the budget after all objects are verified.
+	sampling *verifySampler
 }
 
 func (v *verifier) progressCallback(ctx context.Context, enqueued, active, completed int64) {
@@ -87,6 +108,14 @@ func (v *verifier) reportError(ctx context.Context, path string, err error) {
 	v.errors = append(v.errors, err)
 }
 
+func (v *verifier) reportCaseCollision(ctx context.Context, path string, names []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	log(ctx).Infof("case collision in %v: %v", path, strings.Join(names, ", "))
+	v.caseCollisions++
+}
+
 func (v *verifier) shouldEnqueue(oid object.ID) bool {
 	v.mu.Lock()
 	defer v.mu.Unlock()
@@ -133,6 +162,12 @@ func (v *verifier) doVerifyDirectory(ctx context.Context, oid object.ID, path st
 		return nil
 	}
 
+	if v.reportCaseCollisions {
+		for _, names := range restore.CaseCollisions(entries) {
+			v.reportCaseCollision(ctx, path, names)
+		}
+	}
+
 	for _, e := range entries {
 		if v.tooManyErrors() {
 			break
@@ -174,6 +209,11 @@ func (v *verifier) doVerifyObject(ctx context.Context, oid object.ID, path strin
 		}
 	}
 
+	if v.sampling != nil {
+		v.sampling.add(oid, path)
+		return nil
+	}
+
 	//nolint:gomnd,gosec
 	if rand.Intn(100) < v.downloadFilesPercent {
 		if err := v.readEntireObject(ctx, oid, path); err != nil {
@@ -185,18 +225,23 @@ func (v *verifier) doVerifyObject(ctx context.Context, oid object.ID, path strin
 }
 
 func (v *verifier) readEntireObject(ctx context.Context, oid object.ID, path string) error {
+	_, err := v.readEntireObjectWithLength(ctx, oid, path)
+	return err
+}
+
+func (v *verifier) readEntireObjectWithLength(ctx context.Context, oid object.ID, path string) (int64, error) {
 	log(ctx).Debugf("reading object %v %v", oid, path)
 
 	// also read the entire file
 	r, err := v.rep.OpenObject(ctx, oid)
 	if err != nil {
-		return errors.Wrapf(err, "unable to open object %v", oid)
+		return 0, errors.Wrapf(err, "unable to open object %v", oid)
 	}
 	defer r.Close() //nolint:errcheck
 
-	_, err = iocopy.Copy(ioutil.Discard, r)
+	n, err := iocopy.Copy(ioutil.Discard, r)
 
-	return errors.Wrap(err, "unable to read data")
+	return n, errors.Wrap(err, "unable to read data")
 }
 
 func (c *commandSnapshotVerify) run(ctx context.Context, rep repo.Repository) error {
@@ -211,6 +256,22 @@ func (c *commandSnapshotVerify) run(ctx context.Context, rep repo.Repository) er
 		seen:                 map[object.ID]bool{},
 		errorsThreshold:      c.verifyCommandErrorThreshold,
 		downloadFilesPercent: c.verifyCommandFilesPercent,
+		reportCaseCollisions: c.verifyCommandCaseCollisions,
+	}
+
+	if c.verifyCommandMaxDuration > 0 || c.verifyCommandMaxReadBytes > 0 {
+		if c.verifyCommandFilesPercent > 0 {
+			log(ctx).Infof("Ignoring --verify-files-percent, files are sampled within --max-duration and --max-read-bytes.")
+		}
+
+		v.sampling = &verifySampler{
+			maxBytes:  int64(c.verifyCommandMaxReadBytes),
+			stateFile: c.svc.repositoryConfigFileName() + ".verify-sampling.json",
+		}
+
+		if c.verifyCommandMaxDuration > 0 {
+			v.sampling.deadline = clock.Now().Add(c.verifyCommandMaxDuration)
+		}
 	}
 
 	if dr, ok := rep.(repo.DirectRepository); ok {
@@ -231,6 +292,16 @@ func (c *commandSnapshotVerify) run(ctx context.Context, rep repo.Repository) er
 		return errors.Wrap(err, "error processing work queue")
 	}
 
+	if v.sampling != nil && !v.tooManyErrors() {
+		if err := v.sampling.run(ctx, v, c.verifyCommandParallel); err != nil {
+			return err
+		}
+	}
+
+	if c.verifyCommandCaseCollisions {
+		log(ctx).Infof("Found %v case collisions.", v.caseCollisions)
+	}
+
 	if len(v.errors) == 0 {
 		return nil
 	}
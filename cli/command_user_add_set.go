@@ -17,6 +17,9 @@ type commandServerUserAddSet struct {
 	userSetPassword            string
 	userSetPasswordHashVersion int
 	userSetPasswordHash        string
+	userSetAppendOnly          string
+	userSetEventWebhookURL     string
+	userSetEventWebhookURLSet  bool
 
 	isNew bool // true == 'add', false == 'update'
 	out   textOutput
@@ -30,13 +33,15 @@ func (c *commandServerUserAddSet) setup(svc appServices, parent commandParent, i
 	if isNew {
 		cmd = parent.Command("add", "Add new repository user").Alias("create")
 	} else {
-		cmd = parent.Command("set", "Set password for a repository user.").Alias("update")
+		cmd = parent.Command("set", "Set password or permissions for a repository user.").Alias("update")
 	}
 
 	cmd.Flag("ask-password", "Ask for user password").BoolVar(&c.userAskPassword)
 	cmd.Flag("user-password", "Password").StringVar(&c.userSetPassword)
 	cmd.Flag("user-password-hash", "Password hash").StringVar(&c.userSetPasswordHash)
 	cmd.Flag("user-password-hash-version", "Password hash version").Default("1").IntVar(&c.userSetPasswordHashVersion)
+	cmd.Flag("append-only", "Only allow the user to create snapshots and read own data, but not delete or modify them").EnumVar(&c.userSetAppendOnly, "true", "false")
+	cmd.Flag("event-webhook-url", "URL that receives JSON events when clients of the user connect, disconnect or fail authentication (empty to remove)").IsSetByUser(&c.userSetEventWebhookURLSet).StringVar(&c.userSetEventWebhookURL)
 	cmd.Arg("username", "Username").Required().StringVar(&c.userSetName)
 	cmd.Action(svc.repositoryWriterAction(c.runServerUserAddSet))
 
@@ -90,6 +95,16 @@ func (c *commandServerUserAddSet) runServerUserAddSet(ctx context.Context, rep r
 		changed = true
 	}
 
+	if v := c.userSetAppendOnly; v != "" {
+		up.AppendOnly = v == "true"
+		changed = true
+	}
+
+	if c.userSetEventWebhookURLSet {
+		up.EventWebhookURL = c.userSetEventWebhookURL
+		changed = true
+	}
+
 	if up.PasswordHash == nil || c.userAskPassword {
 		pwd, err := askPass(c.out.stdout(), "Enter new password for user "+username+": ")
 		if err != nil {
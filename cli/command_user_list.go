@@ -36,7 +36,11 @@ func (c *commandServerUserList) runUserList(ctx context.Context, rep repo.Reposi
 		if c.jo.jsonOutput {
 			jl.emit(p)
 		} else {
-			c.out.printStdout("%v\n", p.Username)
+			if p.AppendOnly {
+				c.out.printStdout("%v (append-only)\n", p.Username)
+			} else {
+				c.out.printStdout("%v\n", p.Username)
+			}
 		}
 	}
 
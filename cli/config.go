@@ -2,8 +2,10 @@
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -14,10 +16,15 @@
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/localfs"
-	"github.com/kopia/kopia/internal/ospath"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/faultinject"
+	"github.com/kopia/kopia/repo/blob/tracing"
+	"github.com/kopia/kopia/repo/logging"
 )
 
+var storageTraceLog = logging.GetContextLoggerFunc("kopia/storage-trace")
+
 func deprecatedFlag(w io.Writer, help string) func(_ *kingpin.ParseContext) error {
 	return func(_ *kingpin.ParseContext) error {
 		fmt.Fprintf(w, "DEPRECATED: %v\n", help)
@@ -66,15 +73,58 @@ func (c *App) optionsFromFlags(ctx context.Context) *repo.Options {
 		opts.TraceStorage = log(ctx).Debugf
 	}
 
+	if c.traceStorageRecords || c.crashTrace.enabled() {
+		opts.StorageTracing = &tracing.Options{
+			Output: func(ctx context.Context, r tracing.Record) {
+				c.crashTrace.add(ctx, r)
+
+				if c.traceStorageRecords {
+					storageTraceLog(ctx).Debugf("%v", r)
+				}
+			},
+		}
+
+		// when only the crash trace is enabled, it receives all records.
+		if c.traceStorageRecords {
+			opts.StorageTracing.SampleEvery = c.traceStorageSampleEvery
+			opts.StorageTracing.SlowThreshold = c.traceStorageSlowThreshold
+		}
+	}
+
+	if fi := c.faultInjection; fi != nil {
+		// injected faults are not retried, so that they surface to the repository.
+		opts.WrapStorage = func(st blob.Storage) blob.Storage {
+			return faultinject.NewWrapper(st, *fi)
+		}
+	}
+
+	opts.IndexFetchParallelism = c.indexFetchParallelism
+
 	return &opts
 }
 
-func (c *App) repositoryConfigFileName() string {
-	return c.configPath
+func (c *App) loadFaultInjectionConfig(_ *kingpin.ParseContext) error {
+	if c.faultInjectionConfigFile == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(c.faultInjectionConfigFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to read fault injection config")
+	}
+
+	opt := &faultinject.Options{}
+	if err := json.Unmarshal(b, opt); err != nil {
+		return errors.Wrap(err, "invalid fault injection config")
+	}
+
+	c.faultInjection = opt
+
+	return nil
 }
 
-func defaultConfigFileName() string {
-	return filepath.Join(ospath.ConfigDir(), "repository.config")
+func (c *App) repositoryConfigFileName() string {
+	return c.configPath
 }
 
 func resolveSymlink(path string) (string, error) {
@@ -101,7 +151,9 @@ func getLocalFSEntry(ctx context.Context, path0 string) (fs.Entry, error) {
 		log(ctx).Infof("%v resolved to %v", path0, path)
 	}
 
-	e, err := localfs.NewEntry(path)
+	// each entry gets its own cache, so that hard-linked files and directories reachable
+	// through multiple paths are only read once per upload.
+	e, err := localfs.NewMetadataCache(localfs.DefaultMetadataCacheSize).NewEntry(path)
 	if err != nil {
 		return nil, errors.Wrap(err, "can't get local fs entry")
 	}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/ospath"
+)
+
+// Connection profiles are named repository config files stored in the config directory,
+// the default profile uses the default config file.
+const (
+	defaultConnectionProfile        = "default"
+	defaultConfigFileBaseName       = "repository.config"
+	connectionProfileConfigPrefix   = "repository-"
+	connectionProfileConfigSuffix   = ".config"
+	activeConnectionProfileFileName = "active-profile"
+)
+
+var validConnectionProfileName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+func validateConnectionProfileName(name string) error {
+	if !validConnectionProfileName.MatchString(name) {
+		return errors.Errorf("invalid profile name %q, only letters, digits, '.', '_' and '-' are allowed", name)
+	}
+
+	return nil
+}
+
+// connectionProfileConfigFile returns the name of the config file of the profile with the provided name.
+func connectionProfileConfigFile(configDir, name string) string {
+	if name == defaultConnectionProfile {
+		return filepath.Join(configDir, defaultConfigFileBaseName)
+	}
+
+	return filepath.Join(configDir, connectionProfileConfigPrefix+name+connectionProfileConfigSuffix)
+}
+
+// listConnectionProfiles returns sorted names of profiles which have config files in the provided directory.
+func listConnectionProfiles(configDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(configDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "unable to list config directory")
+	}
+
+	var result []string
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		if e.Name() == defaultConfigFileBaseName {
+			result = append(result, defaultConnectionProfile)
+			continue
+		}
+
+		if !strings.HasPrefix(e.Name(), connectionProfileConfigPrefix) || !strings.HasSuffix(e.Name(), connectionProfileConfigSuffix) {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(e.Name(), connectionProfileConfigPrefix), connectionProfileConfigSuffix)
+		if validateConnectionProfileName(name) == nil && name != defaultConnectionProfile {
+			result = append(result, name)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// readActiveConnectionProfile returns the name of the profile selected using 'kopia profile switch'.
+func readActiveConnectionProfile(configDir string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(configDir, activeConnectionProfileFileName)) //nolint:gosec
+	if err != nil {
+		if os.IsNotExist(err) {
+			return defaultConnectionProfile, nil
+		}
+
+		return "", errors.Wrap(err, "unable to read active profile")
+	}
+
+	name := strings.TrimSpace(string(b))
+	if name == "" {
+		return defaultConnectionProfile, nil
+	}
+
+	return name, validateConnectionProfileName(name)
+}
+
+// writeActiveConnectionProfile selects the profile used when neither --profile nor --config-file are specified.
+func writeActiveConnectionProfile(configDir, name string) error {
+	fname := filepath.Join(configDir, activeConnectionProfileFileName)
+
+	if name == defaultConnectionProfile {
+		if err := os.Remove(fname); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "unable to remove active profile")
+		}
+
+		return nil
+	}
+
+	if err := os.MkdirAll(configDir, 0o700); err != nil { //nolint:gomnd
+		return errors.Wrap(err, "unable to create config directory")
+	}
+
+	return errors.Wrap(atomicfile.Write(fname, strings.NewReader(name+"\n")), "unable to write active profile")
+}
+
+// resolveConfigPath determines the config file based on --config-file, --profile or the active profile.
+func (c *App) resolveConfigPath(_ *kingpin.ParseContext) error {
+	if c.configPath != "" {
+		if c.connectionProfile != "" {
+			return errors.Errorf("--profile and --config-file can't be specified at the same time")
+		}
+
+		return nil
+	}
+
+	name := c.connectionProfile
+	if name == "" {
+		active, err := readActiveConnectionProfile(ospath.ConfigDir())
+		if err != nil {
+			return err
+		}
+
+		name = active
+	}
+
+	if err := validateConnectionProfileName(name); err != nil {
+		return err
+	}
+
+	c.connectionProfile = name
+	c.configPath = connectionProfileConfigFile(ospath.ConfigDir(), name)
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionProfiles(t *testing.T) {
+	dir := t.TempDir()
+
+	names, err := listConnectionProfiles(dir)
+	require.NoError(t, err)
+	require.Empty(t, names)
+
+	active, err := readActiveConnectionProfile(dir)
+	require.NoError(t, err)
+	require.Equal(t, defaultConnectionProfile, active)
+
+	require.Equal(t, filepath.Join(dir, "repository.config"), connectionProfileConfigFile(dir, defaultConnectionProfile))
+
+	for _, f := range []string{
+		"repository.config",
+		"repository.config.kopia-password",
+		"repository-work.config",
+		"repository-work.config.update-info.json",
+		"repository-home.config",
+		"other.config",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, f), nil, 0o600))
+	}
+
+	names, err = listConnectionProfiles(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"default", "home", "work"}, names)
+
+	require.NoError(t, writeActiveConnectionProfile(dir, "work"))
+
+	active, err = readActiveConnectionProfile(dir)
+	require.NoError(t, err)
+	require.Equal(t, "work", active)
+	require.Equal(t, filepath.Join(dir, "repository-work.config"), connectionProfileConfigFile(dir, active))
+
+	require.NoError(t, writeActiveConnectionProfile(dir, defaultConnectionProfile))
+
+	active, err = readActiveConnectionProfile(dir)
+	require.NoError(t, err)
+	require.Equal(t, defaultConnectionProfile, active)
+
+	require.Error(t, validateConnectionProfileName("../escape"))
+	require.Error(t, validateConnectionProfileName(""))
+	require.NoError(t, validateConnectionProfileName("my-profile_1"))
+}
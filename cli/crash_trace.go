@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob/tracing"
+)
+
+const defaultCrashTraceBufferSize = 1000
+
+// crashTracer keeps recent storage trace records in memory, independent of logging, and dumps them
+// when the command panics or fails, which provides context for crashes that occur with logging disabled.
+type crashTracer struct {
+	bufferSize int
+	outputFile string
+
+	buf *tracing.RingBuffer
+}
+
+func (c *crashTracer) setup(app *kingpin.Application) {
+	app.Flag("crash-trace-buffer-size", "Number of recent storage operations kept in memory and dumped on crash (0 disables)").Default(fmt.Sprintf("%v", defaultCrashTraceBufferSize)).Hidden().Envar("KOPIA_CRASH_TRACE_BUFFER_SIZE").IntVar(&c.bufferSize)
+	app.Flag("crash-trace-file", "Write crash trace to the provided file instead of a PEM block on standard error").Hidden().Envar("KOPIA_CRASH_TRACE_FILE").StringVar(&c.outputFile)
+	app.PreAction(c.initialize)
+}
+
+func (c *crashTracer) initialize(*kingpin.ParseContext) error {
+	if c.bufferSize > 0 {
+		c.buf = tracing.NewRingBuffer(c.bufferSize)
+	}
+
+	return nil
+}
+
+func (c *crashTracer) enabled() bool {
+	return c.buf != nil
+}
+
+func (c *crashTracer) add(ctx context.Context, r tracing.Record) {
+	if c.buf != nil {
+		c.buf.Add(ctx, r)
+	}
+}
+
+// dumpOnPanic must be deferred, it dumps the trace and re-panics.
+func (c *crashTracer) dumpOnPanic(stderr io.Writer) {
+	if r := recover(); r != nil {
+		c.dump(stderr, fmt.Sprintf("panic: %v", r))
+		panic(r)
+	}
+}
+
+// dump writes the buffered trace records to the configured file or as a PEM block to the provided writer.
+func (c *crashTracer) dump(stderr io.Writer, reason string) {
+	if c.buf == nil || len(c.buf.Entries()) == 0 {
+		return
+	}
+
+	if c.outputFile != "" {
+		if err := c.writeFile(); err != nil {
+			fmt.Fprintf(stderr, "unable to write crash trace: %v\n", err) // nolint:errcheck
+			return
+		}
+
+		fmt.Fprintf(stderr, "Recent storage operations (%v) were written to %v\n", reason, c.outputFile) // nolint:errcheck
+
+		return
+	}
+
+	fmt.Fprintf(stderr, "Recent storage operations (%v):\n", reason) // nolint:errcheck
+
+	if err := c.buf.WritePEM(stderr); err != nil {
+		fmt.Fprintf(stderr, "unable to write crash trace: %v\n", err) // nolint:errcheck
+	}
+}
+
+func (c *crashTracer) writeFile() error {
+	f, err := os.Create(c.outputFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to create crash trace file")
+	}
+
+	defer f.Close() //nolint:errcheck,gosec
+
+	_, err = c.buf.WriteTo(f)
+
+	// nolint:wrapcheck
+	return err
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const metricsPushTimeout = 30 * time.Second
+
+// metricsPusher pushes metrics to Prometheus Pushgateway at the end of a command, which makes
+// short-lived invocations, such as snapshots triggered by cron, observable.
+type metricsPusher struct {
+	addr     string
+	job      string
+	grouping []string
+}
+
+func (c *metricsPusher) setup(app *kingpin.Application) {
+	app.Flag("metrics-push-addr", "Address of Prometheus Pushgateway to push metrics to at the end of the command").Hidden().Envar("KOPIA_METRICS_PUSH_ADDR").StringVar(&c.addr)
+	app.Flag("metrics-push-job", "Job name used when pushing metrics").Default("kopia").Hidden().Envar("KOPIA_METRICS_PUSH_JOB").StringVar(&c.job)
+	app.Flag("metrics-push-grouping", "Grouping label used when pushing metrics (key:value)").Hidden().StringsVar(&c.grouping)
+	app.PreAction(c.validate)
+}
+
+func (c *metricsPusher) validate(*kingpin.ParseContext) error {
+	_, err := c.groupingLabels()
+	return err
+}
+
+func (c *metricsPusher) groupingLabels() ([][2]string, error) {
+	var result [][2]string
+
+	for _, g := range c.grouping {
+		parts := strings.SplitN(g, ":", 2) // nolint:gomnd
+		if len(parts) != 2 || parts[0] == "" { // nolint:gomnd
+			return nil, errors.Errorf("invalid metrics grouping %q, must be key:value", g)
+		}
+
+		result = append(result, [2]string{parts[0], parts[1]})
+	}
+
+	return result, nil
+}
+
+func (c *metricsPusher) enabled() bool {
+	return c.addr != ""
+}
+
+func (c *metricsPusher) pusher() (*push.Pusher, error) {
+	reg, _, err := newPrometheusExporter()
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := c.groupingLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	p := push.New(c.addr, c.job).Gatherer(reg).Client(&http.Client{Timeout: metricsPushTimeout})
+
+	for _, l := range labels {
+		p = p.Grouping(l[0], l[1])
+	}
+
+	return p, nil
+}
+
+// push replaces metrics of the job and grouping in the Pushgateway with current values.
+func (c *metricsPusher) push(ctx context.Context) error {
+	if !c.enabled() {
+		return nil
+	}
+
+	p, err := c.pusher()
+	if err != nil {
+		return err
+	}
+
+	log(ctx).Debugf("pushing metrics to %v", c.addr)
+
+	return errors.Wrap(p.Push(), "unable to push metrics")
+}
@@ -11,6 +11,8 @@
 	"golang.org/x/term"
 
 	"github.com/kopia/kopia/internal/passwordpersist"
+	"github.com/kopia/kopia/internal/secrets"
+	_ "github.com/kopia/kopia/internal/secrets/awssm" // register AWS Secrets Manager provider
 )
 
 func askForNewRepositoryPassword(out io.Writer) (string, error) {
@@ -53,6 +55,14 @@ func (c *App) getPasswordFromFlags(ctx context.Context, isNew, allowPersistent b
 	case c.password != "":
 		// password provided via --password flag or KOPIA_PASSWORD environment variable
 		return strings.TrimSpace(c.password), nil
+	case c.passwordSecret != "":
+		// password fetched from a secret store each time the repository is opened, so rotated passwords are picked up.
+		v, err := secrets.Get(ctx, c.passwordSecret)
+		if err != nil {
+			return "", errors.Wrap(err, "unable to get repository password")
+		}
+
+		return strings.TrimSpace(string(v)), nil
 	case isNew:
 		// this is a new repository, ask for password
 		return askForNewRepositoryPassword(c.stdoutWriter)
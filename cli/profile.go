@@ -2,33 +2,90 @@
 
 package cli
 
-import "github.com/pkg/profile"
-
-var (
-	profileDir      = app.Flag("profile-dir", "Write profile to the specified directory").Hidden().String()
-	profileCPU      = app.Flag("profile-cpu", "Enable CPU profiling").Hidden().Bool()
-	profileMemory   = app.Flag("profile-memory", "Enable memory profiling").Hidden().Int()
-	profileBlocking = app.Flag("profile-blocking", "Enable block profiling").Hidden().Bool()
-	profileMutex    = app.Flag("profile-mutex", "Enable mutex profiling").Hidden().Bool()
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/profile"
+
+	"github.com/kopia/kopia/internal/clock"
 )
 
+type profileFlags struct {
+	profileDir      string
+	profileCPU      bool
+	profileMemory   int
+	profileBlocking bool
+	profileMutex    bool
+	profileOnly     string
+
+	mu     sync.Mutex
+	active bool
+}
+
+func (c *profileFlags) setup(app *kingpin.Application) {
+	app.Flag("profile-dir", "Write profile to the specified directory").Hidden().StringVar(&c.profileDir)
+	app.Flag("profile-cpu", "Enable CPU profiling").Hidden().BoolVar(&c.profileCPU)
+	app.Flag("profile-memory", "Enable memory profiling").Hidden().IntVar(&c.profileMemory)
+	app.Flag("profile-blocking", "Enable block profiling").Hidden().BoolVar(&c.profileBlocking)
+	app.Flag("profile-mutex", "Enable mutex profiling").Hidden().BoolVar(&c.profileMutex)
+	app.Flag("profile-only", "Only profile the specified comma-separated operations (e.g. 'snapshot,maintenance')").Hidden().Envar("KOPIA_PROFILE_ONLY").StringVar(&c.profileOnly)
+}
+
 // withProfiling runs the given callback with profiling enabled, configured according to command line flags.
-func withProfiling(callback func() error) error {
-	if *profileDir != "" {
-		pp := profile.ProfilePath(*profileDir)
-		if *profileMemory > 0 {
-			defer profile.Start(pp, profile.MemProfileRate(*profileMemory)).Stop()
-		}
-		if *profileCPU {
-			defer profile.Start(pp, profile.CPUProfile).Stop()
-		}
-		if *profileBlocking {
-			defer profile.Start(pp, profile.BlockProfile).Stop()
-		}
-		if *profileMutex {
-			defer profile.Start(pp, profile.MutexProfile).Stop()
-		}
+// When profiling is restricted to particular operations, each profiled operation is written to its own
+// subdirectory and nested operations are covered by the profile of the outer operation.
+func (c *profileFlags) withProfiling(operation string, callback func() error) error {
+	if c.profileDir == "" || !profilingSelected(c.profileOnly, operation) || !c.tryActivate() {
+		return callback()
+	}
+
+	defer c.deactivate()
+
+	dir := c.profileDir
+	if c.profileOnly != "" {
+		dir = filepath.Join(dir, strings.ReplaceAll(operation, " ", "-")+"-"+clock.Now().Format("20060102-150405"))
+	}
+
+	pp := profile.ProfilePath(dir)
+
+	if c.profileMemory > 0 {
+		defer profile.Start(pp, profile.MemProfileRate(c.profileMemory)).Stop()
+	}
+
+	if c.profileCPU {
+		defer profile.Start(pp, profile.CPUProfile).Stop()
+	}
+
+	if c.profileBlocking {
+		defer profile.Start(pp, profile.BlockProfile).Stop()
+	}
+
+	if c.profileMutex {
+		defer profile.Start(pp, profile.MutexProfile).Stop()
 	}
 
 	return callback()
 }
+
+func (c *profileFlags) tryActivate() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.active {
+		return false
+	}
+
+	c.active = true
+
+	return true
+}
+
+func (c *profileFlags) deactivate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.active = false
+}
@@ -2,7 +2,13 @@
 
 package cli
 
+import "github.com/alecthomas/kingpin"
+
+type profileFlags struct{}
+
+func (c *profileFlags) setup(app *kingpin.Application) {}
+
 // withProfiling runs the given callback with profiling enabled, configured according to command line flags.
-func withProfiling(callback func() error) error {
+func (c *profileFlags) withProfiling(operation string, callback func() error) error {
 	return callback()
 }
@@ -0,0 +1,25 @@
+package cli
+
+import "strings"
+
+// profilingSelected determines whether the operation (such as 'snapshot create') should be profiled
+// given a comma-separated list of selected operations. Selecting a command also selects all its
+// subcommands and an empty list selects all operations.
+func profilingSelected(only, operation string) bool {
+	if only == "" {
+		return true
+	}
+
+	for _, s := range strings.Split(only, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if operation == s || strings.HasPrefix(operation, s+" ") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,27 @@
+package cli
+
+import "testing"
+
+func TestProfilingSelected(t *testing.T) {
+	cases := []struct {
+		only      string
+		operation string
+		want      bool
+	}{
+		{"", "snapshot create", true},
+		{"snapshot", "snapshot create", true},
+		{"snapshot", "snapshot", true},
+		{"snapshot", "snapshots", false},
+		{"snapshot create", "snapshot list", false},
+		{"snapshot, maintenance", "maintenance", true},
+		{"snapshot,maintenance", "maintenance run", true},
+		{"snapshot,maintenance", "server start", false},
+		{",", "server start", false},
+	}
+
+	for _, tc := range cases {
+		if got := profilingSelected(tc.only, tc.operation); got != tc.want {
+			t.Errorf("profilingSelected(%q, %q) = %v, want %v", tc.only, tc.operation, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// verifySamplingState is persisted in a JSON file next to the configuration file and allows
+// budgeted verifications to resume reading files where the previous verification left off.
+type verifySamplingState struct {
+	LastObjectID object.ID `json:"lastObjectID"`
+	Time         time.Time `json:"time"`
+}
+
+type verifySampleCandidate struct {
+	oid  object.ID
+	path string
+}
+
+// verifySampler reads files within time and byte budgets. Files are read in the order of their object IDs,
+// starting after the last file read by the previous verification and wrapping around, so that repeated
+// verifications eventually read all files.
+type verifySampler struct {
+	deadline  time.Time
+	maxBytes  int64
+	stateFile string
+
+	mu         sync.Mutex
+	candidates []verifySampleCandidate
+	start      int
+	taken      int
+	bytesRead  int64
+}
+
+func (s *verifySampler) add(oid object.ID, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.candidates = append(s.candidates, verifySampleCandidate{oid, path})
+}
+
+func (s *verifySampler) budgetExhaustedLocked() bool {
+	if !s.deadline.IsZero() && !clock.Now().Before(s.deadline) {
+		return true
+	}
+
+	return s.maxBytes > 0 && s.bytesRead >= s.maxBytes
+}
+
+// take returns the next file to read or false if all files have been read or the budget is exhausted.
+func (s *verifySampler) take() (verifySampleCandidate, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.taken >= len(s.candidates) || s.budgetExhaustedLocked() {
+		return verifySampleCandidate{}, false
+	}
+
+	c := s.candidates[(s.start+s.taken)%len(s.candidates)]
+	s.taken++
+
+	return c, true
+}
+
+func (s *verifySampler) addBytesRead(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bytesRead += n
+}
+
+func (s *verifySampler) run(ctx context.Context, v *verifier, parallel int) error {
+	if len(s.candidates) == 0 {
+		return nil
+	}
+
+	sort.Slice(s.candidates, func(i, j int) bool {
+		return s.candidates[i].oid < s.candidates[j].oid
+	})
+
+	if st, err := s.loadState(); err == nil {
+		s.start = sort.Search(len(s.candidates), func(i int) bool {
+			return s.candidates[i].oid > st.LastObjectID
+		})
+	} else if !os.IsNotExist(errors.Cause(err)) {
+		log(ctx).Debugf("unable to load verification sampling state: %v", err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for !v.tooManyErrors() {
+				c, ok := s.take()
+				if !ok {
+					return
+				}
+
+				n, err := v.readEntireObjectWithLength(ctx, c.oid, c.path)
+				if err != nil {
+					v.reportError(ctx, c.path, errors.Wrapf(err, "error reading object %v", c.oid))
+				}
+
+				s.addBytesRead(n)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if s.taken == 0 {
+		log(ctx).Infof("Verification budget exhausted before reading any files.")
+		return nil
+	}
+
+	if s.taken == len(s.candidates) {
+		log(ctx).Infof("Read all %v files (%v).", s.taken, units.BytesStringBase10(s.bytesRead))
+	} else {
+		log(ctx).Infof("Read %v out of %v files (%v) within the budget, next verification will resume from where it left off.", s.taken, len(s.candidates), units.BytesStringBase10(s.bytesRead))
+	}
+
+	return s.saveState(&verifySamplingState{
+		LastObjectID: s.candidates[(s.start+s.taken-1)%len(s.candidates)].oid,
+		Time:         clock.Now(),
+	})
+}
+
+func (s *verifySampler) loadState() (*verifySamplingState, error) {
+	f, err := os.Open(s.stateFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open verification sampling state")
+	}
+	defer f.Close() //nolint:errcheck,gosec
+
+	st := &verifySamplingState{}
+	if err := json.NewDecoder(f).Decode(st); err != nil {
+		return nil, errors.Wrap(err, "unable to parse verification sampling state")
+	}
+
+	return st, nil
+}
+
+func (s *verifySampler) saveState(st *verifySamplingState) error {
+	var buf bytes.Buffer
+
+	if err := json.NewEncoder(&buf).Encode(st); err != nil {
+		return errors.Wrap(err, "unable to marshal JSON")
+	}
+
+	return errors.Wrap(atomicfile.Write(s.stateFile, &buf), "error writing verification sampling state")
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/presigned"
+)
+
+type storagePresignedFlags struct {
+	tokenFile string
+}
+
+func (c *storagePresignedFlags) setup(_ storageProviderServices, cmd *kingpin.CmdClause) {
+	cmd.Flag("token-file", "File generated using 'kopia repository grant-restore-access'").Required().ExistingFileVar(&c.tokenFile)
+}
+
+func (c *storagePresignedFlags) connect(ctx context.Context, isNew bool) (blob.Storage, error) {
+	if isNew {
+		return nil, errors.New("pre-signed URLs only allow reading existing repository")
+	}
+
+	b, err := ioutil.ReadFile(c.tokenFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read token file")
+	}
+
+	var opt presigned.Options
+
+	if err := json.Unmarshal(b, &opt); err != nil {
+		return nil, errors.Wrap(err, "invalid token file")
+	}
+
+	// nolint:wrapcheck
+	return presigned.New(ctx, &opt)
+}
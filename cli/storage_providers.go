@@ -30,6 +30,7 @@ type storageProvider struct {
 	{"b2", "a B2 bucket", func() storageFlags { return &storageB2Flags{} }},
 	{"filesystem", "a filesystem", func() storageFlags { return &storageFilesystemFlags{} }},
 	{"gcs", "a Google Cloud Storage bucket", func() storageFlags { return &storageGCSFlags{} }},
+	{"presigned", "pre-signed URLs generated using 'repository grant-restore-access'", func() storageFlags { return &storagePresignedFlags{} }},
 	{"rclone", "an rclone-based provided", func() storageFlags { return &storageRcloneFlags{} }},
 	{"s3", "an S3 bucket", func() storageFlags { return &storageS3Flags{} }},
 	{"sftp", "an SFTP storage", func() storageFlags { return &storageSFTPFlags{} }},
@@ -20,6 +20,8 @@ func (c *storageWebDAVFlags) setup(_ storageProviderServices, cmd *kingpin.CmdCl
 	cmd.Flag("flat", "Use flat directory structure").BoolVar(&c.connectFlat)
 	cmd.Flag("webdav-username", "WebDAV username").Envar("KOPIA_WEBDAV_USERNAME").StringVar(&c.options.Username)
 	cmd.Flag("webdav-password", "WebDAV password").Envar("KOPIA_WEBDAV_PASSWORD").StringVar(&c.options.Password)
+	cmd.Flag("webdav-parallel-range-reads", "Number of concurrent HTTP range requests used to read a single blob (0 disables)").IntVar(&c.options.ParallelRangeReads)
+	cmd.Flag("webdav-max-concurrent-range-reads", "Maximum number of concurrent range requests to the WebDAV server").IntVar(&c.options.MaxConcurrentRangeReads)
 }
 
 func (c *storageWebDAVFlags) connect(ctx context.Context, isNew bool) (blob.Storage, error) {
@@ -33,6 +33,22 @@ type DeviceInfo struct {
 	Rdev uint64 `json:"rdev"`
 }
 
+// HasInode is optionally implemented by entries which can report the inode number of the underlying file.
+type HasInode interface {
+	Inode() uint64
+}
+
+// HardLinkedFile is optionally implemented by files reachable through multiple hard links, which allows
+// a value computed from the file contents, such as its object ID, to be shared by all the links.
+type HardLinkedFile interface {
+	File
+
+	// SharedValue returns the value set through any link to the same file, as long as the file
+	// has not been modified since.
+	SharedValue() (interface{}, bool)
+	SetSharedValue(v interface{})
+}
+
 // Entries is a list of entries sorted by name.
 type Entries []Entry
 
@@ -68,6 +84,35 @@ type DirectoryWithSummary interface {
 	Summary(ctx context.Context) (*DirectorySummary, error)
 }
 
+// DirectoryIterator is optionally implemented by Directory that can enumerate its entries
+// without reading all of them into memory.
+type DirectoryIterator interface {
+	IterateEntries(ctx context.Context, callback func(ctx context.Context, e Entry) error) error
+}
+
+// IterateEntries invokes the callback for each entry in the directory. Directories that implement
+// DirectoryIterator are enumerated incrementally, others are read using Readdir().
+func IterateEntries(ctx context.Context, d Directory, callback func(ctx context.Context, e Entry) error) error {
+	if di, ok := d.(DirectoryIterator); ok {
+		// nolint:wrapcheck
+		return di.IterateEntries(ctx, callback)
+	}
+
+	entries, err := d.Readdir(ctx)
+	if err != nil {
+		// nolint:wrapcheck
+		return err
+	}
+
+	for _, e := range entries {
+		if err := callback(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ErrorEntry represents entry in a Directory that had encountered an error or is unknown/unsupported (ErrUnknown).
 type ErrorEntry interface {
 	Entry
@@ -102,30 +102,74 @@ func isCorrectCacheDirSignature(ctx context.Context, f fs.File) (bool, error) {
 	return string(sig) == validSignature, nil
 }
 
-func (d *ignoreDirectory) skipCacheDirectory(ctx context.Context, entries fs.Entries, relativePath string, policyTree *policy.Tree) fs.Entries {
-	if !policyTree.EffectivePolicy().FilesPolicy.IgnoreCacheDirectoriesOrDefault(true) {
-		return entries
+// findEntryFunc returns the entry with the provided name in a directory or nil if not found.
+type findEntryFunc func(ctx context.Context, name string) fs.Entry
+
+func findInEntries(entries fs.Entries) findEntryFunc {
+	return func(ctx context.Context, name string) fs.Entry {
+		return entries.FindByName(name)
 	}
+}
 
-	f, ok := entries.FindByName(repo.CacheDirMarkerFile).(fs.File)
-	if ok {
-		correct, err := isCorrectCacheDirSignature(ctx, f)
-		if err != nil {
-			log(ctx).Debugf("unable to check cache dir signature, assuming not a cache directory: %v", err)
-			return entries
+func (d *ignoreDirectory) findChild(ctx context.Context, name string) fs.Entry {
+	e, err := d.Directory.Child(ctx, name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrEntryNotFound) {
+			log(ctx).Debugf("unable to get %v in %v: %v", name, d.relativePath, err)
 		}
 
-		if correct {
-			// if the given directory contains a marker file used for kopia cache, pretend the directory was empty.
-			for _, oi := range d.parentContext.onIgnore {
-				oi(relativePath, d)
-			}
+		return nil
+	}
+
+	return e
+}
+
+// isCacheDirectory determines whether the directory contains a marker file used for kopia cache,
+// in which case it's reported as ignored.
+func (d *ignoreDirectory) isCacheDirectory(ctx context.Context, find findEntryFunc) bool {
+	if !d.policyTree.EffectivePolicy().FilesPolicy.IgnoreCacheDirectoriesOrDefault(true) {
+		return false
+	}
+
+	f, ok := find(ctx, repo.CacheDirMarkerFile).(fs.File)
+	if !ok {
+		return false
+	}
+
+	correct, err := isCorrectCacheDirSignature(ctx, f)
+	if err != nil {
+		log(ctx).Debugf("unable to check cache dir signature, assuming not a cache directory: %v", err)
+		return false
+	}
 
-			return nil
+	if correct {
+		for _, oi := range d.parentContext.onIgnore {
+			oi(d.relativePath, d)
 		}
 	}
 
-	return entries
+	return correct
+}
+
+// maybeInclude returns the entry as it should be returned by the directory or false if it's ignored.
+func (d *ignoreDirectory) maybeInclude(thisContext *ignoreContext, e fs.Entry) (fs.Entry, bool) {
+	if !thisContext.shouldIncludeByName(d.relativePath+"/"+e.Name(), e) {
+		return nil, false
+	}
+
+	if maxSize := thisContext.maxFileSize; maxSize > 0 && e.Size() > maxSize {
+		return nil, false
+	}
+
+	if !thisContext.shouldIncludeByDevice(e, d) {
+		return nil, false
+	}
+
+	if dir, ok := e.(fs.Directory); ok {
+		e = &ignoreDirectory{d.relativePath + "/" + e.Name(), thisContext, d.policyTree.Child(e.Name()), dir}
+	}
+
+	return e, true
 }
 
 func (d *ignoreDirectory) Readdir(ctx context.Context) (fs.Entries, error) {
@@ -135,9 +179,12 @@ func (d *ignoreDirectory) Readdir(ctx context.Context) (fs.Entries, error) {
 		return nil, err
 	}
 
-	entries = d.skipCacheDirectory(ctx, entries, d.relativePath, d.policyTree)
+	if d.isCacheDirectory(ctx, findInEntries(entries)) {
+		// pretend the directory was empty.
+		return nil, nil
+	}
 
-	thisContext, err := d.buildContext(ctx, entries)
+	thisContext, err := d.buildContext(ctx, findInEntries(entries))
 	if err != nil {
 		return nil, err
 	}
@@ -145,29 +192,54 @@ func (d *ignoreDirectory) Readdir(ctx context.Context) (fs.Entries, error) {
 	result := make(fs.Entries, 0, len(entries))
 
 	for _, e := range entries {
-		if !thisContext.shouldIncludeByName(d.relativePath+"/"+e.Name(), e) {
-			continue
+		if e, ok := d.maybeInclude(thisContext, e); ok {
+			result = append(result, e)
 		}
+	}
 
-		if maxSize := thisContext.maxFileSize; maxSize > 0 && e.Size() > maxSize {
-			continue
-		}
+	return result, nil
+}
 
-		if !thisContext.shouldIncludeByDevice(e, d) {
-			continue
+// IterateEntries implements fs.DirectoryIterator, it looks up cache marker and dotignore files by name,
+// so that entries of the underlying directory can be enumerated incrementally.
+func (d *ignoreDirectory) IterateEntries(ctx context.Context, callback func(ctx context.Context, e fs.Entry) error) error {
+	if _, ok := d.Directory.(fs.DirectoryIterator); !ok {
+		// looking up entries by name would read the underlying directory multiple times.
+		entries, err := d.Readdir(ctx)
+		if err != nil {
+			return err
 		}
 
-		if dir, ok := e.(fs.Directory); ok {
-			e = &ignoreDirectory{d.relativePath + "/" + e.Name(), thisContext, d.policyTree.Child(e.Name()), dir}
+		for _, e := range entries {
+			if err := callback(ctx, e); err != nil {
+				return err
+			}
 		}
 
-		result = append(result, e)
+		return nil
 	}
 
-	return result, nil
+	if d.isCacheDirectory(ctx, d.findChild) {
+		// pretend the directory was empty.
+		return nil
+	}
+
+	thisContext, err := d.buildContext(ctx, d.findChild)
+	if err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return fs.IterateEntries(ctx, d.Directory, func(ctx context.Context, e fs.Entry) error {
+		if e, ok := d.maybeInclude(thisContext, e); ok {
+			return callback(ctx, e)
+		}
+
+		return nil
+	})
 }
 
-func (d *ignoreDirectory) buildContext(ctx context.Context, entries fs.Entries) (*ignoreContext, error) {
+func (d *ignoreDirectory) buildContext(ctx context.Context, find findEntryFunc) (*ignoreContext, error) {
 	effectiveDotIgnoreFiles := d.parentContext.dotIgnoreFiles
 
 	pol := d.policyTree.DefinedPolicy()
@@ -178,7 +250,7 @@ func (d *ignoreDirectory) buildContext(ctx context.Context, entries fs.Entries)
 	var foundDotIgnoreFiles bool
 
 	for _, dotfile := range effectiveDotIgnoreFiles {
-		if e := entries.FindByName(dotfile); e != nil {
+		if e := find(ctx, dotfile); e != nil {
 			foundDotIgnoreFiles = true
 		}
 	}
@@ -202,7 +274,7 @@ func (d *ignoreDirectory) buildContext(ctx context.Context, entries fs.Entries)
 		}
 	}
 
-	if err := newic.loadDotIgnoreFiles(ctx, d.relativePath, entries, effectiveDotIgnoreFiles); err != nil {
+	if err := newic.loadDotIgnoreFiles(ctx, d.relativePath, find, effectiveDotIgnoreFiles); err != nil {
 		return nil, err
 	}
 
@@ -238,9 +310,9 @@ func (c *ignoreContext) overrideFromPolicy(fp *policy.FilesPolicy, dirPath strin
 	return nil
 }
 
-func (c *ignoreContext) loadDotIgnoreFiles(ctx context.Context, dirPath string, entries fs.Entries, dotIgnoreFiles []string) error {
+func (c *ignoreContext) loadDotIgnoreFiles(ctx context.Context, dirPath string, find findEntryFunc, dotIgnoreFiles []string) error {
 	for _, dotIgnoreFile := range dotIgnoreFiles {
-		e := entries.FindByName(dotIgnoreFile)
+		e := find(ctx, dotIgnoreFile)
 		if e == nil {
 			// no dotfile
 			continue
@@ -346,7 +418,10 @@ func New(dir fs.Directory, policyTree *policy.Tree, options ...Option) fs.Direct
 	return &ignoreDirectory{".", rootContext, policyTree, dir}
 }
 
-var _ fs.Directory = &ignoreDirectory{}
+var (
+	_ fs.Directory         = &ignoreDirectory{}
+	_ fs.DirectoryIterator = &ignoreDirectory{}
+)
 
 // ReportIgnoredFiles returns an Option causing ignorefs to call the provided function whenever a file or directory is ignored.
 func ReportIgnoredFiles(f IgnoreCallback) Option {
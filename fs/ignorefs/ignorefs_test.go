@@ -2,6 +2,7 @@
 
 import (
 	"bytes"
+	"context"
 	"sort"
 	"testing"
 
@@ -507,10 +508,71 @@ func TestIgnoreFS(t *testing.T) {
 
 			expectedFiles := addAndSubtractFiles(originalFiles, tc.addedFiles, tc.ignoredFiles)
 			verifyDirectoryTree(t, ifs, expectedFiles)
+
+			// entries of directories that can be iterated are looked up by name.
+			verifyDirectoryTree(t, iterateAll{ignorefs.New(iteratingDirectory{root}, tc.policyTree)}, expectedFiles)
 		})
 	}
 }
 
+// iteratingDirectory implements fs.DirectoryIterator on top of another directory.
+type iteratingDirectory struct {
+	fs.Directory
+}
+
+func (d iteratingDirectory) IterateEntries(ctx context.Context, callback func(ctx context.Context, e fs.Entry) error) error {
+	entries, err := d.Directory.Readdir(ctx)
+	if err != nil {
+		return err
+	}
+
+	// return entries in reverse order, since callers must not depend on it.
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if sd, ok := e.(fs.Directory); ok {
+			e = iteratingDirectory{sd}
+		}
+
+		if err := callback(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d iteratingDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	e, err := d.Directory.Child(ctx, name)
+	if sd, ok := e.(fs.Directory); ok {
+		e = iteratingDirectory{sd}
+	}
+
+	return e, err
+}
+
+// iterateAll implements Readdir() using fs.IterateEntries().
+type iterateAll struct {
+	fs.Directory
+}
+
+func (d iterateAll) Readdir(ctx context.Context) (fs.Entries, error) {
+	var entries fs.Entries
+
+	err := fs.IterateEntries(ctx, d.Directory, func(ctx context.Context, e fs.Entry) error {
+		if sd, ok := e.(fs.Directory); ok {
+			e = iterateAll{sd}
+		}
+
+		entries = append(entries, e)
+
+		return nil
+	})
+
+	entries.Sort()
+
+	return entries, err
+}
+
 func addAndSubtractFiles(original, added, removed []string) []string {
 	m := map[string]bool{}
 	for _, ri := range removed {
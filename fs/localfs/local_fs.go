@@ -11,6 +11,7 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/atomicfile"
 )
 
 const (
@@ -25,6 +26,8 @@ type filesystemEntry struct {
 	mode       os.FileMode
 	owner      fs.OwnerInfo
 	device     fs.DeviceInfo
+	inode      uint64
+	nlink      uint64
 
 	parentDir string
 }
@@ -49,6 +52,10 @@ func (e *filesystemEntry) ModTime() time.Time {
 	return time.Unix(0, e.mtimeNanos)
 }
 
+func (e *filesystemEntry) Inode() uint64 {
+	return e.inode
+}
+
 func (e *filesystemEntry) Sys() interface{} {
 	return nil
 }
@@ -79,12 +86,17 @@ func newEntry(fi os.FileInfo, parentDir string) filesystemEntry {
 		fi.Mode(),
 		platformSpecificOwnerInfo(fi),
 		platformSpecificDeviceInfo(fi),
+		platformSpecificInode(fi),
+		platformSpecificLinkCount(fi),
 		parentDir,
 	}
 }
 
 type filesystemDirectory struct {
 	filesystemEntry
+
+	// cache shared by all directories reached from the same root, may be nil.
+	cache *MetadataCache
 }
 
 type filesystemSymlink struct {
@@ -95,6 +107,13 @@ type filesystemFile struct {
 	filesystemEntry
 }
 
+// hardLinkedFile is a file with multiple hard links, reached through a directory with a cache.
+type hardLinkedFile struct {
+	filesystemFile
+
+	cache *MetadataCache
+}
+
 type filesystemErrorEntry struct {
 	filesystemEntry
 	err error
@@ -108,7 +127,7 @@ func (fsd *filesystemDirectory) Size() int64 {
 func (fsd *filesystemDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
 	fullPath := fsd.fullPath()
 
-	st, err := os.Lstat(filepath.Join(fullPath, name))
+	st, err := os.Lstat(atomicfile.MaybePrefixLongFilenameOnWindows(filepath.Join(fullPath, name)))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fs.ErrEntryNotFound
@@ -117,7 +136,7 @@ func (fsd *filesystemDirectory) Child(ctx context.Context, name string) (fs.Entr
 		return nil, errors.Wrap(err, "unable to get child")
 	}
 
-	return entryFromChildFileInfo(st, fullPath), nil
+	return entryFromChildFileInfo(st, fullPath, fsd.cache), nil
 }
 
 type entryWithError struct {
@@ -126,40 +145,81 @@ type entryWithError struct {
 }
 
 func (fsd *filesystemDirectory) Readdir(ctx context.Context) (fs.Entries, error) {
-	fullPath := fsd.fullPath()
+	var entries fs.Entries
 
-	f, direrr := os.Open(fullPath) //nolint:gosec
-	if direrr != nil {
-		return nil, errors.Wrap(direrr, "unable to read directory")
-	}
-	defer f.Close() //nolint:errcheck,gosec
+	err := fsd.IterateEntries(ctx, func(ctx context.Context, e fs.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
 
-	// start feeding directory entry names to namesCh
-	namesCh := make(chan string, dirListingPrefetch)
+	entries.Sort()
 
-	var readDirErr error
+	// return any error encountered when listing or reading the directory
+	return entries, err
+}
 
-	go func() {
-		defer close(namesCh)
+// IterateEntries implements fs.DirectoryIterator, entries are not sorted.
+func (fsd *filesystemDirectory) IterateEntries(ctx context.Context, callback func(ctx context.Context, e fs.Entry) error) error {
+	names, cached, collectNames := fsd.cache.getDirectory(fsd)
+	if cached {
+		return fsd.iterateEntries(ctx, func(namesCh chan<- string) error {
+			for _, n := range names {
+				namesCh <- n
+			}
 
+			return nil
+		}, callback)
+	}
+
+	f, direrr := os.Open(atomicfile.MaybePrefixLongFilenameOnWindows(fsd.fullPath())) //nolint:gosec
+	if direrr != nil {
+		return errors.Wrap(direrr, "unable to read directory")
+	}
+	defer f.Close() //nolint:errcheck,gosec
+
+	err := fsd.iterateEntries(ctx, func(namesCh chan<- string) error {
 		for {
-			names, err := f.Readdirnames(numEntriesToRead)
-			for _, name := range names {
+			batch, err := f.Readdirnames(numEntriesToRead)
+			for _, name := range batch {
 				namesCh <- name
 			}
 
+			if collectNames {
+				names = append(names, batch...)
+				collectNames = fsd.cache.canCacheNames(len(names))
+			}
+
 			if err == nil {
 				continue
 			}
 
 			if errors.Is(err, io.EOF) {
-				break
+				return nil
 			}
 
-			readDirErr = err
-
-			break
+			return err
 		}
+	}, callback)
+	if err == nil && collectNames {
+		fsd.cache.putDirectory(fsd, names)
+	}
+
+	return err
+}
+
+// iterateEntries invokes the callback for entries with names fed to namesCh by the provided function.
+func (fsd *filesystemDirectory) iterateEntries(ctx context.Context, listNames func(namesCh chan<- string) error, callback func(ctx context.Context, e fs.Entry) error) error {
+	fullPath := fsd.fullPath()
+
+	// start feeding directory entry names to namesCh
+	namesCh := make(chan string, dirListingPrefetch)
+
+	var listErr error
+
+	go func() {
+		defer close(namesCh)
+
+		listErr = listNames(namesCh)
 	}()
 
 	entriesCh := make(chan entryWithError, dirListingPrefetch)
@@ -175,7 +235,7 @@ func (fsd *filesystemDirectory) Readdir(ctx context.Context) (fs.Entries, error)
 			defer workersWG.Done()
 
 			for n := range namesCh {
-				fi, staterr := os.Lstat(fullPath + "/" + n)
+				fi, staterr := os.Lstat(atomicfile.MaybePrefixLongFilenameOnWindows(fullPath + "/" + n))
 
 				switch {
 				case os.IsNotExist(staterr):
@@ -186,7 +246,7 @@ func (fsd *filesystemDirectory) Readdir(ctx context.Context) (fs.Entries, error)
 					continue
 				}
 
-				entriesCh <- entryWithError{entry: entryFromChildFileInfo(fi, fullPath)}
+				entriesCh <- entryWithError{entry: entryFromChildFileInfo(fi, fullPath, fsd.cache)}
 			}
 		}()
 	}
@@ -197,30 +257,35 @@ func (fsd *filesystemDirectory) Readdir(ctx context.Context) (fs.Entries, error)
 		close(entriesCh)
 	}()
 
-	// drain the entriesCh into a slice and sort it
-	var entries fs.Entries
+	// drain the entriesCh, after the first error the callback is no longer invoked
+	var firstErr error
 
 	for e := range entriesCh {
-		if e.err != nil {
-			// only return the first error
-			if readDirErr == nil {
-				readDirErr = e.err
-			}
+		if firstErr != nil {
+			continue
+		}
 
+		if e.err != nil {
+			firstErr = e.err
 			continue
 		}
 
-		entries = append(entries, e.entry)
+		firstErr = callback(ctx, e.entry)
 	}
 
-	entries.Sort()
+	// listing has finished when all workers are done.
+	if listErr != nil {
+		return listErr
+	}
 
-	// return any error encountered when listing or reading the directory
-	return entries, readDirErr
+	return firstErr
 }
 
 type fileWithMetadata struct {
 	*os.File
+
+	// parent directory of the file, without long filename prefix.
+	parentDir string
 }
 
 func (f *fileWithMetadata) Entry() (fs.Entry, error) {
@@ -229,21 +294,29 @@ func (f *fileWithMetadata) Entry() (fs.Entry, error) {
 		return nil, errors.Wrap(err, "unable to stat() local file")
 	}
 
-	return &filesystemFile{newEntry(fi, filepath.Dir(f.Name()))}, nil
+	return &filesystemFile{newEntry(fi, f.parentDir)}, nil
 }
 
 func (fsf *filesystemFile) Open(ctx context.Context) (fs.Reader, error) {
-	f, err := os.Open(fsf.fullPath())
+	f, err := os.Open(atomicfile.MaybePrefixLongFilenameOnWindows(fsf.fullPath()))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to open local file")
 	}
 
-	return &fileWithMetadata{f}, nil
+	return &fileWithMetadata{f, fsf.parentDir}, nil
+}
+
+func (f *hardLinkedFile) SharedValue() (interface{}, bool) {
+	return f.cache.sharedValue(&f.filesystemEntry)
+}
+
+func (f *hardLinkedFile) SetSharedValue(v interface{}) {
+	f.cache.setSharedValue(&f.filesystemEntry, v)
 }
 
 func (fsl *filesystemSymlink) Readlink(ctx context.Context) (string, error) {
 	// nolint:wrapcheck
-	return os.Readlink(fsl.fullPath())
+	return os.Readlink(atomicfile.MaybePrefixLongFilenameOnWindows(fsl.fullPath()))
 }
 
 func (e *filesystemErrorEntry) ErrorInfo() error {
@@ -253,24 +326,12 @@ func (e *filesystemErrorEntry) ErrorInfo() error {
 // NewEntry returns fs.Entry for the specified path, the result will be one of supported entry types: fs.File, fs.Directory, fs.Symlink
 // or fs.UnsupportedEntry.
 func NewEntry(path string) (fs.Entry, error) {
-	fi, err := os.Lstat(path)
+	fi, err := os.Lstat(atomicfile.MaybePrefixLongFilenameOnWindows(path))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to determine entry type")
 	}
 
-	switch fi.Mode() & os.ModeType {
-	case os.ModeDir:
-		return &filesystemDirectory{newEntry(fi, filepath.Dir(path))}, nil
-
-	case os.ModeSymlink:
-		return &filesystemSymlink{newEntry(fi, filepath.Dir(path))}, nil
-
-	case 0:
-		return &filesystemFile{newEntry(fi, filepath.Dir(path))}, nil
-
-	default:
-		return &filesystemErrorEntry{newEntry(fi, filepath.Dir(path)), fs.ErrUnknown}, nil
-	}
+	return entryFromChildFileInfo(fi, filepath.Dir(path), nil), nil
 }
 
 // Directory returns fs.Directory for the specified path.
@@ -287,16 +348,21 @@ func Directory(path string) (fs.Directory, error) {
 	return nil, errors.Errorf("not a directory: %v", path)
 }
 
-func entryFromChildFileInfo(fi os.FileInfo, parentDir string) fs.Entry {
+func entryFromChildFileInfo(fi os.FileInfo, parentDir string, cache *MetadataCache) fs.Entry {
 	switch fi.Mode() & os.ModeType {
 	case os.ModeDir:
-		return &filesystemDirectory{newEntry(fi, parentDir)}
+		return &filesystemDirectory{newEntry(fi, parentDir), cache}
 
 	case os.ModeSymlink:
 		return &filesystemSymlink{newEntry(fi, parentDir)}
 
 	case 0:
-		return &filesystemFile{newEntry(fi, parentDir)}
+		f := filesystemFile{newEntry(fi, parentDir)}
+		if cache != nil && f.nlink > 1 && f.inode != 0 {
+			return &hardLinkedFile{f, cache}
+		}
+
+		return &f
 
 	default:
 		return &filesystemErrorEntry{newEntry(fi, parentDir), fs.ErrUnknown}
@@ -304,8 +370,9 @@ func entryFromChildFileInfo(fi os.FileInfo, parentDir string) fs.Entry {
 }
 
 var (
-	_ fs.Directory  = &filesystemDirectory{}
-	_ fs.File       = &filesystemFile{}
-	_ fs.Symlink    = &filesystemSymlink{}
-	_ fs.ErrorEntry = &filesystemErrorEntry{}
+	_ fs.Directory      = &filesystemDirectory{}
+	_ fs.File           = &filesystemFile{}
+	_ fs.HardLinkedFile = &hardLinkedFile{}
+	_ fs.Symlink        = &filesystemSymlink{}
+	_ fs.ErrorEntry     = &filesystemErrorEntry{}
 )
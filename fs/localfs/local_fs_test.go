@@ -5,11 +5,13 @@
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/atomicfile"
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/internal/testutil"
@@ -97,6 +99,51 @@ func TestFiles(t *testing.T) {
 	verifyChild(t, dir)
 }
 
+func TestLongPaths(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	tmp := testutil.TempDirectory(t)
+
+	// build a directory tree nested beyond MAX_PATH (260 characters) on Windows.
+	deepDir := tmp
+	for i := 0; i < 5; i++ {
+		deepDir = filepath.Join(deepDir, strings.Repeat(fmt.Sprintf("%v", i), 60))
+	}
+
+	assertNoError(t, os.MkdirAll(atomicfile.MaybePrefixLongFilenameOnWindows(deepDir), 0o777))
+	assertNoError(t, ioutil.WriteFile(atomicfile.MaybePrefixLongFilenameOnWindows(filepath.Join(deepDir, "f1")), []byte{1, 2, 3}, 0o777))
+
+	dir, err := Directory(deepDir)
+	assertNoError(t, err)
+
+	entries, err := dir.Readdir(ctx)
+	assertNoError(t, err)
+
+	if len(entries) != 1 || entries[0].Name() != "f1" || entries[0].Size() != 3 {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+
+	r, err := entries[0].(fs.File).Open(ctx)
+	assertNoError(t, err)
+
+	defer r.Close() //nolint:errcheck
+
+	data, err := ioutil.ReadAll(r)
+	assertNoError(t, err)
+
+	if len(data) != 3 {
+		t.Errorf("unexpected data: %v", data)
+	}
+
+	e, err := r.Entry()
+	assertNoError(t, err)
+
+	// local paths must not include long filename prefix.
+	if got, want := e.LocalFilesystemPath(), filepath.Join(deepDir, "f1"); got != want {
+		t.Errorf("unexpected local path: %v, want %v", got, want)
+	}
+}
+
 func verifyChild(t *testing.T, dir fs.Directory) {
 	t.Helper()
 
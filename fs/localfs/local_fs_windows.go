@@ -13,3 +13,11 @@ func platformSpecificOwnerInfo(fi os.FileInfo) fs.OwnerInfo {
 func platformSpecificDeviceInfo(fi os.FileInfo) fs.DeviceInfo {
 	return fs.DeviceInfo{}
 }
+
+func platformSpecificInode(fi os.FileInfo) uint64 {
+	return 0
+}
+
+func platformSpecificLinkCount(fi os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,246 @@
+package localfs
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/atomicfile"
+)
+
+// DefaultMetadataCacheSize is the default number of directory entry names and file values kept by MetadataCache.
+const DefaultMetadataCacheSize = 100000
+
+type inodeKey struct {
+	dev   uint64
+	inode uint64
+}
+
+type cachedInode struct {
+	key inodeKey
+
+	// metadata of the entry when it was cached, a cached value is only used if they are unchanged.
+	mtimeNanos int64
+	size       int64
+
+	// number of times a directory has been read.
+	reads int
+
+	// names of directory entries, only kept after the directory was read at least twice.
+	names []string
+
+	// value shared by all hard links of a file.
+	value    interface{}
+	hasValue bool
+}
+
+func (ci *cachedInode) cost() int {
+	return len(ci.names) + 1
+}
+
+// MetadataCache caches metadata of local entries reachable through multiple paths within a single
+// pass over the filesystem, such as one snapshot upload, keyed by device and inode.
+//
+// Names of entries are cached for directories that were read more than once (bind mounts, hard-linked
+// directories), which avoids opening and listing them again, their entries are still stat()-ed to be current.
+// Files with multiple hard links share a value, such as the object ID computed from their contents, which
+// avoids opening and reading the file again for each link.
+//
+// Cached items are discarded when modification time or size of the entry changes and the least
+// recently used items are evicted when the cache grows above its size.
+type MetadataCache struct {
+	mu        sync.Mutex
+	maxSize   int
+	size      int
+	items     map[inodeKey]*list.Element // of *cachedInode
+	evictList *list.List
+
+	hits   int64
+	misses int64
+}
+
+// NewMetadataCache returns a new empty metadata cache holding up to the provided number of directory entry
+// names and file values.
+func NewMetadataCache(maxSize int) *MetadataCache {
+	return &MetadataCache{
+		maxSize:   maxSize,
+		items:     map[inodeKey]*list.Element{},
+		evictList: list.New(),
+	}
+}
+
+// NewEntry returns fs.Entry for the specified path, directories returned by it and their descendants share the cache.
+func (c *MetadataCache) NewEntry(path string) (fs.Entry, error) {
+	fi, err := os.Lstat(atomicfile.MaybePrefixLongFilenameOnWindows(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to determine entry type")
+	}
+
+	return entryFromChildFileInfo(fi, filepath.Dir(path), c), nil
+}
+
+// Stats returns the number of lookups served from the cache and those that missed.
+func (c *MetadataCache) Stats() (hits, misses int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+}
+
+func entryKey(e *filesystemEntry) (inodeKey, bool) {
+	// inode numbers are not available on all platforms.
+	if e.inode == 0 {
+		return inodeKey{}, false
+	}
+
+	return inodeKey{e.device.Dev, e.inode}, true
+}
+
+// lookupLocked returns the cached item for the provided entry, discarding it if the entry has changed.
+func (c *MetadataCache) lookupLocked(key inodeKey, e *filesystemEntry) *cachedInode {
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+
+	ci := el.Value.(*cachedInode) //nolint:forcetypeassert
+	if ci.mtimeNanos != e.mtimeNanos || ci.size != e.size {
+		c.removeLocked(el)
+		return nil
+	}
+
+	c.evictList.MoveToFront(el)
+
+	return ci
+}
+
+// addLocked adds a new item for the provided entry.
+func (c *MetadataCache) addLocked(key inodeKey, e *filesystemEntry) *cachedInode {
+	ci := &cachedInode{key: key, mtimeNanos: e.mtimeNanos, size: e.size}
+
+	c.items[key] = c.evictList.PushFront(ci)
+	c.size += ci.cost()
+	c.evictLocked()
+
+	return ci
+}
+
+// updateLocked updates the item after its cost has changed from the provided value.
+func (c *MetadataCache) updateLocked(ci *cachedInode, oldCost int) {
+	c.size += ci.cost() - oldCost
+	c.evictLocked()
+}
+
+func (c *MetadataCache) removeLocked(el *list.Element) {
+	ci := c.evictList.Remove(el).(*cachedInode) //nolint:forcetypeassert
+
+	delete(c.items, ci.key)
+	c.size -= ci.cost()
+}
+
+func (c *MetadataCache) evictLocked() {
+	for c.size > c.maxSize && c.evictList.Len() > 0 {
+		c.removeLocked(c.evictList.Back())
+	}
+}
+
+// getDirectory returns cached names of entries in the provided directory and whether names should be collected
+// to be cached, which happens once the directory is read for the second time.
+func (c *MetadataCache) getDirectory(fsd *filesystemDirectory) (names []string, cached, collectNames bool) {
+	if c == nil {
+		return nil, false, false
+	}
+
+	key, ok := entryKey(&fsd.filesystemEntry)
+	if !ok {
+		return nil, false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ci := c.lookupLocked(key, &fsd.filesystemEntry)
+
+	switch {
+	case ci == nil:
+		ci = c.addLocked(key, &fsd.filesystemEntry)
+	case ci.names != nil:
+		atomic.AddInt64(&c.hits, 1)
+		return ci.names, true, false
+	}
+
+	ci.reads++
+
+	atomic.AddInt64(&c.misses, 1)
+
+	return nil, false, ci.reads >= 2 //nolint:gomnd
+}
+
+// canCacheNames returns true if the provided number of names fits in the cache.
+func (c *MetadataCache) canCacheNames(n int) bool {
+	return n < c.maxSize
+}
+
+// putDirectory caches names of entries of the provided directory.
+func (c *MetadataCache) putDirectory(fsd *filesystemDirectory, names []string) {
+	if c == nil || !c.canCacheNames(len(names)) {
+		return
+	}
+
+	key, ok := entryKey(&fsd.filesystemEntry)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ci := c.lookupLocked(key, &fsd.filesystemEntry)
+	if ci == nil {
+		return
+	}
+
+	oldCost := ci.cost()
+	ci.names = names
+	c.updateLocked(ci, oldCost)
+}
+
+func (c *MetadataCache) sharedValue(e *filesystemEntry) (interface{}, bool) {
+	key, ok := entryKey(e)
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ci := c.lookupLocked(key, e)
+	if ci == nil || !ci.hasValue {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+
+	atomic.AddInt64(&c.hits, 1)
+
+	return ci.value, true
+}
+
+func (c *MetadataCache) setSharedValue(e *filesystemEntry, v interface{}) {
+	key, ok := entryKey(e)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ci := c.lookupLocked(key, e)
+	if ci == nil {
+		ci = c.addLocked(key, e)
+	}
+
+	ci.value = v
+	ci.hasValue = true
+}
@@ -0,0 +1,165 @@
+package localfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestMetadataCacheDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inode numbers are not available on Windows")
+	}
+
+	ctx := testlogging.Context(t)
+	tmp := testutil.TempDirectory(t)
+
+	sub := filepath.Join(tmp, "sub")
+	require.NoError(t, os.Mkdir(sub, 0o777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sub, "f1"), []byte{1, 2, 3}, 0o777))
+
+	c := NewMetadataCache(DefaultMetadataCacheSize)
+
+	e, err := c.NewEntry(sub)
+	require.NoError(t, err)
+
+	dir := e.(*filesystemDirectory)
+
+	// the same directory seen through another path, such as a bind mount.
+	require.NoError(t, os.Symlink(tmp, filepath.Join(tmp, "other")))
+
+	alias := *dir
+	alias.parentDir = filepath.Join(tmp, "other")
+
+	// listing is only cached after the directory is read for the second time.
+	for _, d := range []*filesystemDirectory{dir, &alias} {
+		entries, rerr := d.Readdir(ctx)
+		require.NoError(t, rerr)
+		require.Len(t, entries, 1)
+	}
+
+	hits, misses := c.Stats()
+	require.Equal(t, int64(0), hits)
+	require.Equal(t, int64(2), misses)
+
+	// modifying file contents does not change the directory, cached listing is used but entries are current.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sub, "f1"), []byte{1, 2, 3, 4}, 0o777))
+
+	entries, err := alias.Readdir(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, int64(4), entries[0].Size())
+	require.Equal(t, filepath.Join(tmp, "other", "sub", "f1"), entries[0].LocalFilesystemPath())
+
+	hits, _ = c.Stats()
+	require.Equal(t, int64(1), hits)
+
+	// adding an entry changes directory mtime, which invalidates the cached listing.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sub, "f2"), []byte{1}, 0o777))
+
+	// make sure mtime is different even on filesystems with coarse timestamps.
+	mtime := dir.ModTime().Add(time.Second)
+	require.NoError(t, os.Chtimes(sub, mtime, mtime))
+
+	e, err = c.NewEntry(sub)
+	require.NoError(t, err)
+
+	entries, err = e.(fs.Directory).Readdir(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	hits, _ = c.Stats()
+	require.Equal(t, int64(1), hits)
+}
+
+func TestMetadataCacheHardLinkedFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inode numbers are not available on Windows")
+	}
+
+	ctx := testlogging.Context(t)
+	tmp := testutil.TempDirectory(t)
+
+	require.NoError(t, os.Mkdir(filepath.Join(tmp, "a"), 0o777))
+	require.NoError(t, os.Mkdir(filepath.Join(tmp, "b"), 0o777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "a", "f"), []byte{1, 2, 3}, 0o777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "a", "single"), []byte{1, 2, 3}, 0o777))
+	require.NoError(t, os.Link(filepath.Join(tmp, "a", "f"), filepath.Join(tmp, "b", "f")))
+
+	c := NewMetadataCache(DefaultMetadataCacheSize)
+
+	child := func(dir, name string) fs.Entry {
+		t.Helper()
+
+		d, err := c.NewEntry(filepath.Join(tmp, dir))
+		require.NoError(t, err)
+
+		e, err := d.(fs.Directory).Child(ctx, name)
+		require.NoError(t, err)
+
+		return e
+	}
+
+	// files with a single link don't share values.
+	_, ok := child("a", "single").(fs.HardLinkedFile)
+	require.False(t, ok)
+
+	f1 := child("a", "f").(fs.HardLinkedFile)
+
+	_, ok = f1.SharedValue()
+	require.False(t, ok)
+
+	f1.SetSharedValue("value")
+
+	// value is visible through the other link.
+	v, ok := child("b", "f").(fs.HardLinkedFile).SharedValue()
+	require.True(t, ok)
+	require.Equal(t, "value", v)
+
+	// value is discarded after the file is modified.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "a", "f"), []byte{1, 2, 3, 4}, 0o777))
+
+	_, ok = child("b", "f").(fs.HardLinkedFile).SharedValue()
+	require.False(t, ok)
+
+	// entries that are not cached are not hard-linked files.
+	d, err := Directory(filepath.Join(tmp, "b"))
+	require.NoError(t, err)
+
+	entries, err := d.Readdir(ctx)
+	require.NoError(t, err)
+
+	_, ok = entries[0].(fs.HardLinkedFile)
+	require.False(t, ok)
+}
+
+func TestMetadataCacheEviction(t *testing.T) {
+	c := NewMetadataCache(3)
+
+	for i := uint64(1); i <= 5; i++ {
+		c.setSharedValue(&filesystemEntry{inode: i, mtimeNanos: 1, size: 1}, i)
+	}
+
+	require.Equal(t, 3, c.evictList.Len())
+
+	// least recently used values are evicted.
+	_, ok := c.sharedValue(&filesystemEntry{inode: 2, mtimeNanos: 1, size: 1})
+	require.False(t, ok)
+
+	v, ok := c.sharedValue(&filesystemEntry{inode: 5, mtimeNanos: 1, size: 1})
+	require.True(t, ok)
+	require.Equal(t, uint64(5), v)
+
+	// stale values are not returned.
+	_, ok = c.sharedValue(&filesystemEntry{inode: 5, mtimeNanos: 2, size: 1})
+	require.False(t, ok)
+}
@@ -92,7 +92,7 @@ func NewStaticDirectory(name string, entries fs.Entries) fs.Directory {
 // virtualFile is an implementation of fs.StreamingFile with an io.Reader.
 type virtualFile struct {
 	virtualEntry
-	reader io.Reader
+	open func(ctx context.Context) (io.Reader, error)
 }
 
 var errReaderAlreadyUsed = errors.New("cannot use streaming file reader more than once")
@@ -101,25 +101,34 @@ type virtualFile struct {
 // Note: Caller of this function has to ensure concurrency safety.
 // The file's reader is set to nil after the first call.
 func (vf *virtualFile) GetReader(ctx context.Context) (io.Reader, error) {
-	if vf.reader == nil {
+	if vf.open == nil {
 		return nil, errReaderAlreadyUsed
 	}
 
 	// reader must be fetched only once
-	ret := vf.reader
-	vf.reader = nil
+	open := vf.open
+	vf.open = nil
 
-	return ret, nil
+	return open(ctx)
 }
 
 // StreamingFileFromReader returns a streaming file with given name and reader.
 func StreamingFileFromReader(name string, reader io.Reader) fs.StreamingFile {
+	return StreamingFileWithOpener(name, func(ctx context.Context) (io.Reader, error) {
+		return reader, nil
+	})
+}
+
+// StreamingFileWithOpener returns a streaming file with given name, whose reader is obtained
+// by calling the provided function when the file is read, which allows opening named pipes lazily.
+// If the returned reader implements io.Closer, it is closed by the uploader once the stream has been read.
+func StreamingFileWithOpener(name string, open func(ctx context.Context) (io.Reader, error)) fs.StreamingFile {
 	return &virtualFile{
 		virtualEntry: virtualEntry{
 			name: name,
 			mode: defaultPermissions,
 		},
-		reader: reader,
+		open: open,
 	}
 }
 
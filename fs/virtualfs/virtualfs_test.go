@@ -1,8 +1,11 @@
 package virtualfs
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
@@ -109,3 +112,39 @@ func TestStreamingFileGetReader(t *testing.T) {
 		t.Fatalf("did not get expected error: (actual) %v != %v (expected)", err, errReaderAlreadyUsed)
 	}
 }
+
+func TestStreamingFileWithOpener(t *testing.T) {
+	content := []byte("Temporary file content")
+	opened := 0
+
+	f := StreamingFileWithOpener("stream-file", func(ctx context.Context) (io.Reader, error) {
+		opened++
+		return bytes.NewReader(content), nil
+	})
+
+	if opened != 0 {
+		t.Fatalf("stream opened too early")
+	}
+
+	reader, err := f.GetReader(context.TODO())
+	if err != nil {
+		t.Fatalf("error getting streaming file reader: %v", err)
+	}
+
+	result, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("error reading streaming file: %v", err)
+	}
+
+	if !reflect.DeepEqual(result, content) {
+		t.Fatalf("did not get expected file content: (actual) %v != %v (expected)", result, content)
+	}
+
+	if _, err = f.GetReader(context.TODO()); !errors.Is(err, errReaderAlreadyUsed) {
+		t.Fatalf("did not get expected error: (actual) %v != %v (expected)", err, errReaderAlreadyUsed)
+	}
+
+	if opened != 1 {
+		t.Fatalf("unexpected number of opens: %v", opened)
+	}
+}
@@ -21,6 +21,7 @@ export class BeginRestore extends Component {
             overwriteDirectories: false,
             overwriteSymlinks: false,
             ignorePermissionErrors: true,
+            caseCollisions: "",
             restoreTask: "",
         };
 
@@ -42,6 +43,7 @@ export class BeginRestore extends Component {
             options: {
                 incremental: this.state.incremental,
                 ignoreErrors: this.state.continueOnErrors,
+                caseCollisions: this.state.caseCollisions,
             },
         }
 
@@ -117,6 +119,18 @@ export class BeginRestore extends Component {
                 <Form.Row>
                     {RequiredBoolean(this, "Overwrite Symbolic Links", "overwriteSymlinks")}
                 </Form.Row>
+                <Form.Row>
+                    <Form.Group>
+                        <Form.Label>Entries With Names Differing Only By Case</Form.Label>
+                        <Form.Control as="select" size="sm" name="caseCollisions" onChange={this.handleChange} value={this.state.caseCollisions}>
+                            <option value="">Restore unchanged</option>
+                            <option value="rename">Rename</option>
+                            <option value="skip">Skip</option>
+                            <option value="fail">Fail</option>
+                        </Form.Control>
+                        <Form.Text className="text-muted">Such entries overwrite each other when restored to case-insensitive filesystems (Windows, macOS).</Form.Text>
+                    </Form.Group>
+                </Form.Row>
                 <Form.Row>
                     {RequiredBoolean(this, "Disable ZIP compression", "uncompressedZip", "Do not compress when restoring to a ZIP file (faster).")}
                 </Form.Row>
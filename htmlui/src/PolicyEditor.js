@@ -190,6 +190,18 @@ export class PolicyEditor extends Component {
                 <Tab eventKey="retention" title="Retention">
                     <div className="tab-body">
                         <p className="policy-help">Controls how many latest snapshots to keep per source directory</p>
+                        <Form.Row>
+                            <Form.Group as={Col}>
+                                <Form.Label>Template</Form.Label>
+                                <Form.Control as="select"
+                                    name="policy.retention.template"
+                                    onChange={this.handleChange}
+                                    value={stateProperty(this, "policy.retention.template")}>
+                                    <option value="">(none)</option>
+                                    {["default", "gfs", "gfs-extended", "minimal"].map(x => <option key={x} value={x}>{x}</option>)}
+                                </Form.Control>
+                            </Form.Group>
+                        </Form.Row>
                         <Form.Row>
                             {OptionalNumberField(this, "Latest", "policy.retention.keepLatest", { placeholder: "# of latest snapshots" })}
                             {OptionalNumberField(this, "Hourly", "policy.retention.keepHourly", { placeholder: "# of hourly snapshots" })}
@@ -200,6 +212,9 @@ export class PolicyEditor extends Component {
                             {OptionalNumberField(this, "Monthly", "policy.retention.keepMonthly", { placeholder: "# of monthly snapshots" })}
                             {OptionalNumberField(this, "Annual", "policy.retention.keepAnnual", { placeholder: "# of annual snapshots" })}
                         </Form.Row>
+                        <Form.Row>
+                            {OptionalNumberField(this, "Max Retained Size", "policy.retention.maxRetainedSize", { placeholder: "bytes" })}
+                        </Form.Row>
                     </div>
                 </Tab>
                 <Tab eventKey="files" title="Files">
@@ -229,6 +244,19 @@ export class PolicyEditor extends Component {
                             {OptionalBoolean(this, "Ignore File Errors", "policy.errorHandling.ignoreFileErrors", "inherit from parent")}
                             {OptionalBoolean(this, "Ignore Unknown Types", "policy.errorHandling.ignoreUnknownTypes", "inherit from parent")}
                         </Form.Row>
+                        <Form.Row>
+                            <Form.Group as={Col}>
+                                <Form.Label>File Error Action</Form.Label>
+                                <Form.Control as="select"
+                                    name="policy.errorHandling.fileErrorAction"
+                                    onChange={this.handleChange}
+                                    value={stateProperty(this, "policy.errorHandling.fileErrorAction")}>
+                                    <option value="">(inherit from parent)</option>
+                                    {["fail", "skip"].map(x => <option key={x} value={x}>{x}</option>)}
+                                </Form.Control>
+                            </Form.Group>
+                            {OptionalNumberField(this, "File Error Retries", "policy.errorHandling.fileErrorRetries", { placeholder: "# of retries before applying action" })}
+                        </Form.Row>
                     </div>
                 </Tab>
                 <Tab eventKey="compression" title="Compression">
@@ -254,12 +282,23 @@ export class PolicyEditor extends Component {
                         </Form.Row>
                     </div>
                 </Tab>
+                <Tab eventKey="upload" title="Upload">
+                    <div className="tab-body">
+                        <p className="policy-help">Controls the speed of uploading files, independently of repository-level throttling.</p>
+                        <Form.Row>
+                            {OptionalNumberField(this, "Max Upload Speed", "policy.upload.maxUploadSpeedBytesPerSecond", { placeholder: "bytes per second" })}
+                        </Form.Row>
+                    </div>
+                </Tab>
                 <Tab eventKey="scheduling" title="Scheduling">
                     <div className="tab-body">
                         <p className="policy-help">Controls when snapshots are automatically created.</p>
                         <Form.Row>
                             {OptionalNumberField(this, "Snapshot Interval", "policy.scheduling.intervalSeconds", { placeholder: "seconds" })}
                         </Form.Row>
+                        <Form.Row>
+                            {StringList(this, "Cron Expressions", "policy.scheduling.cron", "Snapshot at times matching cron expressions (one per line), e.g. '30 2 * * 1-5', optionally prefixed with 'CRON_TZ=<zone> '")}
+                        </Form.Row>
                         <Form.Row>
                             {OptionalBoolean(this, "Only create snapshots manually (disables scheduled snapshots)", "policy.scheduling.manual")}
                         </Form.Row>
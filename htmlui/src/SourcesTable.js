@@ -121,6 +121,15 @@ export class SourcesTable extends Component {
                             totals += " " + percent + "%";
                         }
                     }
+
+                    if (u.processedBytesPerSecond) {
+                        totals += " " + sizeDisplayName(Math.round(u.processedBytesPerSecond)) + "/s";
+                    }
+
+                    if (u.eta) {
+                        title += "\n ETA " + moment(u.eta).fromNow();
+                        totals += ", " + moment(u.eta).fromNow(true) + " left";
+                    }
                 }
 
                 return <>
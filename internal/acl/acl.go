@@ -101,6 +101,7 @@ func oneOf(allowed ...string) valueValidatorFunc {
 			policy.PolicyTypeGlobal,
 			policy.PolicyTypeHost,
 			policy.PolicyTypeUser,
+			policy.PolicyTypeUserAllHosts,
 			policy.PolicyTypePath,
 		),
 	},
@@ -109,10 +110,13 @@ func oneOf(allowed ...string) valueValidatorFunc {
 		snapshot.UsernameLabel: nonEmptyString,
 		snapshot.PathLabel:     nonEmptyString,
 	},
+	snapshot.LegalHoldManifestType: {
+		snapshot.LegalHoldSnapshotIDLabel: nonEmptyString,
+	},
 	user.ManifestType: {
 		user.UsernameAtHostnameLabel: nonEmptyString,
 	},
-	aclManifestType: {},
+	ManifestType: {},
 }
 
 // Validate validates entry.
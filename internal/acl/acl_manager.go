@@ -11,9 +11,8 @@
 	"github.com/kopia/kopia/repo/manifest"
 )
 
-const (
-	aclManifestType = "acl"
-)
+// ManifestType is the type of manifests storing ACL entries.
+const ManifestType = "acl"
 
 func matchOrWildcard(rule, actual string) bool {
 	if rule == "*" {
@@ -48,8 +47,18 @@ func EntriesForUser(entries []*Entry, username, hostname string) []*Entry {
 // EffectivePermissions computes the effective access level for a given user@hostname to subject
 // for a given set of ACL Entries.
 func EffectivePermissions(username, hostname string, target map[string]string, entries []*Entry) AccessLevel {
+	highest, _ := MatchingEntry(username, hostname, target, entries)
+
+	return highest
+}
+
+// MatchingEntry computes the effective access level for a given user@hostname to subject
+// and returns the first entry that grants it, or nil if no entry matches.
+func MatchingEntry(username, hostname string, target map[string]string, entries []*Entry) (AccessLevel, *Entry) {
 	highest := AccessLevelNone
 
+	var matching *Entry
+
 	for _, e := range entries {
 		if !userMatches(e.User, username, hostname) {
 			continue
@@ -61,10 +70,11 @@ func EffectivePermissions(username, hostname string, target map[string]string, e
 
 		if e.Access > highest {
 			highest = e.Access
+			matching = e
 		}
 	}
 
-	return highest
+	return highest, matching
 }
 
 // LoadEntries returns the set of all ACLs in the repository, using old list as a cache.
@@ -74,7 +84,7 @@ func LoadEntries(ctx context.Context, rep repo.Repository, old []*Entry) ([]*Ent
 	}
 
 	entries, err := rep.FindManifests(ctx, map[string]string{
-		manifest.TypeLabelKey: aclManifestType,
+		manifest.TypeLabelKey: ManifestType,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "error listing ACL manifests")
@@ -115,7 +125,7 @@ func AddACL(ctx context.Context, w repo.RepositoryWriter, e *Entry) error {
 	}
 
 	manifestID, err := w.PutManifest(ctx, map[string]string{
-		manifest.TypeLabelKey: aclManifestType,
+		manifest.TypeLabelKey: ManifestType,
 	}, e)
 	if err != nil {
 		return errors.Wrap(err, "error writing manifest")
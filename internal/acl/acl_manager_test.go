@@ -149,6 +149,51 @@ func TestEffectivePermissions(t *testing.T) {
 	}
 }
 
+func TestMatchingEntry(t *testing.T) {
+	target := map[string]string{manifest.TypeLabelKey: snapshot.ManifestType}
+
+	read := &acl.Entry{
+		Target: acl.TargetRule{manifest.TypeLabelKey: snapshot.ManifestType},
+		User:   "*@*",
+		Access: acl.AccessLevelRead,
+	}
+
+	full := &acl.Entry{
+		Target: acl.TargetRule{manifest.TypeLabelKey: snapshot.ManifestType},
+		User:   actualUser + "@*",
+		Access: acl.AccessLevelFull,
+	}
+
+	anotherFull := &acl.Entry{
+		Target: acl.TargetRule{manifest.TypeLabelKey: snapshot.ManifestType},
+		User:   actualUserAtHostname,
+		Access: acl.AccessLevelFull,
+	}
+
+	otherType := &acl.Entry{
+		Target: acl.TargetRule{manifest.TypeLabelKey: policy.ManifestType},
+		User:   actualUserAtHostname,
+		Access: acl.AccessLevelFull,
+	}
+
+	level, e := acl.MatchingEntry(actualUser, actualHostname, target, nil)
+	require.Equal(t, acl.AccessLevelNone, level)
+	require.Nil(t, e)
+
+	level, e = acl.MatchingEntry(actualUser, actualHostname, target, []*acl.Entry{otherType})
+	require.Equal(t, acl.AccessLevelNone, level)
+	require.Nil(t, e)
+
+	// the first entry granting the highest access level is returned.
+	level, e = acl.MatchingEntry(actualUser, actualHostname, target, []*acl.Entry{read, otherType, full, anotherFull})
+	require.Equal(t, acl.AccessLevelFull, level)
+	require.Same(t, full, e)
+
+	level, e = acl.MatchingEntry("alice", actualHostname, target, []*acl.Entry{read, otherType, full, anotherFull})
+	require.Equal(t, acl.AccessLevelRead, level)
+	require.Same(t, read, e)
+}
+
 func TestLoadEntries(t *testing.T) {
 	ctx, env := repotesting.NewEnvironment(t)
 
@@ -220,7 +265,18 @@ func TestACLEntryValidation(t *testing.T) {
 				},
 				Access: acl.AccessLevelFull,
 			},
-			WantErr: "invalid 'type' label, must be one of: acl, content, policy, snapshot, user",
+			WantErr: "invalid 'type' label, must be one of: acl, content, legal-hold, policy, snapshot, user",
+		},
+		{
+			Entry: &acl.Entry{
+				User: "foo@bar",
+				Target: acl.TargetRule{
+					"type":     "legal-hold",
+					"hostname": "foo",
+				},
+				Access: acl.AccessLevelFull,
+			},
+			WantErr: "unsupported label 'hostname' for type 'legal-hold', must be one of: snapshotID",
 		},
 		{
 			Entry: &acl.Entry{
@@ -253,7 +309,7 @@ func TestACLEntryValidation(t *testing.T) {
 				},
 				Access: acl.AccessLevelFull,
 			},
-			WantErr: "invalid label 'policyType=blah' for type 'policy': must be one of: global, host, user, path",
+			WantErr: "invalid label 'policyType=blah' for type 'policy': must be one of: global, host, user, user-all-hosts, path",
 		},
 		{
 			Entry: &acl.Entry{
@@ -4,6 +4,7 @@
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -136,6 +137,7 @@ type Options struct {
 	Password string
 
 	TrustedServerCertificateFingerprint string
+	ClientCertificates                  []tls.Certificate
 
 	LogRequests bool
 }
@@ -151,6 +153,16 @@ func NewKopiaAPIClient(options Options) (*KopiaAPIClient, error) {
 		transport = http.DefaultTransport
 	}
 
+	if len(options.ClientCertificates) > 0 {
+		t2 := transport.(*http.Transport).Clone()
+		if t2.TLSClientConfig == nil {
+			t2.TLSClientConfig = &tls.Config{} //nolint:gosec
+		}
+
+		t2.TLSClientConfig.Certificates = options.ClientCertificates
+		transport = t2
+	}
+
 	// wrap with a round-tripper that provides basic authentication
 	if options.Username != "" || options.Password != "" {
 		transport = basicAuthTransport{transport, options.Username, options.Password}
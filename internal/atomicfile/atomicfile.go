@@ -3,7 +3,6 @@
 
 import (
 	"io"
-	"path/filepath"
 	"runtime"
 	"strings"
 
@@ -19,33 +18,77 @@
 // use some low-level Windows APIs.
 // Because long file names have certain limitations:
 // - we must replace forward slashes with backslashes.
-// - dummy path element (\.\) must be removed.
+// - dummy path elements (\.\) must be removed and parent elements (\..\) resolved.
+// - UNC paths (\\server\share\...) must use \\?\UNC\ prefix instead.
+// Paths that are already prefixed are returned unchanged, so it's safe to call this function more than once.
 func MaybePrefixLongFilenameOnWindows(fname string) string {
 	if runtime.GOOS != "windows" {
 		return fname
 	}
 
+	return prefixLongFilename(fname)
+}
+
+func prefixLongFilename(fname string) string {
 	if len(fname) < maxPathLength {
 		return fname
 	}
 
-	if !filepath.IsAbs(fname) {
+	fixed := strings.ReplaceAll(fname, "/", "\\")
+
+	switch {
+	case strings.HasPrefix(fixed, "\\\\?\\"), strings.HasPrefix(fixed, "\\\\.\\"):
+		// already prefixed or device path
+		return fname
+
+	case strings.HasPrefix(fixed, "\\\\"):
+		// UNC path - \\server\share\...
+		return "\\\\?\\UNC\\" + cleanWindowsPath(fixed[2:], 2) //nolint:gomnd
+
+	case isDriveAbsolutePath(fixed):
+		return "\\\\?\\" + cleanWindowsPath(fixed, 1)
+
+	default:
 		// only convert absolute paths
 		return fname
 	}
+}
 
-	fixed := strings.ReplaceAll(fname, "/", "\\")
+// isDriveAbsolutePath returns true if the provided path is in the form X:\...
+func isDriveAbsolutePath(p string) bool {
+	if len(p) < 3 { //nolint:gomnd
+		return false
+	}
 
-	for {
-		fixed2 := strings.ReplaceAll(fixed, "\\.\\", "\\")
-		if fixed2 == fixed {
-			break
-		}
+	c := p[0]
+
+	return ((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) && p[1] == ':' && p[2] == '\\'
+}
+
+// cleanWindowsPath removes empty and dummy path elements and resolves parent elements
+// in a backslash-separated path, preserving the provided number of leading root elements.
+func cleanWindowsPath(p string, rootElements int) string {
+	var result []string
 
-		fixed = fixed2
+	for i, e := range strings.Split(p, "\\") {
+		switch {
+		case i < rootElements:
+			result = append(result, e)
+
+		case e == "" || e == ".":
+			continue
+
+		case e == "..":
+			if len(result) > rootElements {
+				result = result[0 : len(result)-1]
+			}
+
+		default:
+			result = append(result, e)
+		}
 	}
 
-	return "\\\\?\\" + fixed
+	return strings.Join(result, "\\")
 }
 
 // Write is a wrapper around atomic.WriteFile that handles long file names on Windows.
@@ -41,3 +41,41 @@ func TestMaybePrefixLongFilenameOnWindows(t *testing.T) {
 		}
 	}
 }
+
+func TestPrefixLongFilename(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		// too short
+		{"C:\\Short.txt", "C:\\Short.txt"},
+
+		// parent elements
+		{"C:\\" + veryLongSegment + "\\foo\\..\\bar", "\\\\?\\C:\\" + veryLongSegment + "\\bar"},
+		{"C:\\..\\" + veryLongSegment + "\\foo", "\\\\?\\C:\\" + veryLongSegment + "\\foo"},
+		{"C:\\" + veryLongSegment + "\\\\foo\\", "\\\\?\\C:\\" + veryLongSegment + "\\foo"},
+
+		// UNC paths
+		{"\\\\server\\share\\" + veryLongSegment + "\\foo", "\\\\?\\UNC\\server\\share\\" + veryLongSegment + "\\foo"},
+		{"//server/share/" + veryLongSegment + "/../foo", "\\\\?\\UNC\\server\\share\\foo"},
+
+		// already prefixed
+		{"\\\\?\\C:\\" + veryLongSegment + "\\foo", "\\\\?\\C:\\" + veryLongSegment + "\\foo"},
+		{"\\\\?\\UNC\\server\\share\\" + veryLongSegment, "\\\\?\\UNC\\server\\share\\" + veryLongSegment},
+
+		// relative
+		{veryLongSegment + "\\foo", veryLongSegment + "\\foo"},
+		{"\\" + veryLongSegment + "\\foo", "\\" + veryLongSegment + "\\foo"},
+	}
+
+	for _, tc := range cases {
+		if got := prefixLongFilename(tc.input); got != tc.want {
+			t.Errorf("invalid result for %v: got %v, want %v", tc.input, got, tc.want)
+		}
+
+		// prefixing must be idempotent
+		if got := prefixLongFilename(prefixLongFilename(tc.input)); got != tc.want {
+			t.Errorf("prefixing is not idempotent for %v: got %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@
 	"strings"
 
 	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/internal/user"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
@@ -49,6 +50,50 @@ func NoAccess() AuthorizationInfo {
 	return noAccessAuthorizationInfo{}
 }
 
+type appendOnlyAuthorizationInfo struct {
+	inner AuthorizationInfo
+}
+
+func (a appendOnlyAuthorizationInfo) ContentAccessLevel() AccessLevel {
+	return capAccessLevel(a.inner.ContentAccessLevel(), AccessLevelAppend)
+}
+
+func (a appendOnlyAuthorizationInfo) ManifestAccessLevel(labels map[string]string) AccessLevel {
+	return capAccessLevel(a.inner.ManifestAccessLevel(labels), appendOnlyMaxAccessLevel(labels))
+}
+
+// appendOnlyReadOnlyManifestTypes are types of manifests that append-only users can't write at all,
+// because the latest manifest takes precedence, so adding one would change retention or access.
+var appendOnlyReadOnlyManifestTypes = map[string]bool{
+	policy.ManifestType: true,
+	user.ManifestType:   true,
+	acl.ManifestType:    true,
+}
+
+// appendOnlyMaxAccessLevel returns the maximum access level of append-only users to the manifest with given labels.
+func appendOnlyMaxAccessLevel(labels map[string]string) AccessLevel {
+	if appendOnlyReadOnlyManifestTypes[labels[manifest.TypeLabelKey]] {
+		return AccessLevelRead
+	}
+
+	return AccessLevelAppend
+}
+
+func capAccessLevel(l, max AccessLevel) AccessLevel {
+	if l > max {
+		return max
+	}
+
+	return l
+}
+
+// AppendOnly returns AuthorizationInfo which grants at most append access on top of the provided one,
+// so that the user can add snapshots and read data it has access to, but not delete or replace anything.
+// Policies, user profiles and ACL entries are read-only, since adding them replaces the existing ones.
+func AppendOnly(inner AuthorizationInfo) AuthorizationInfo {
+	return appendOnlyAuthorizationInfo{inner}
+}
+
 type legacyAuthorizationInfo struct {
 	usernameAtHostname string
 }
@@ -65,9 +110,19 @@ func (la legacyAuthorizationInfo) ManifestAccessLevel(labels map[string]string)
 			if strings.HasSuffix(la.usernameAtHostname, "@"+labels[snapshot.HostnameLabel]) {
 				return AccessLevelRead
 			}
+
+		case policy.PolicyTypeUserAllHosts:
+			if strings.HasPrefix(la.usernameAtHostname, labels[snapshot.UsernameLabel]+"@") {
+				return AccessLevelRead
+			}
 		}
 	}
 
+	// everybody can read legal holds, so that retention policy keeps held snapshots.
+	if labels[manifest.TypeLabelKey] == snapshot.LegalHoldManifestType {
+		return AccessLevelRead
+	}
+
 	// full access to policies/snapshots for the username@hostname
 	if labels[snapshot.UsernameLabel]+"@"+labels[snapshot.HostnameLabel] == la.usernameAtHostname {
 		return AccessLevelFull
@@ -42,6 +42,14 @@
 		},
 		Access: AccessLevelRead,
 	},
+	{
+		// everybody can read legal holds, so that retention policy keeps held snapshots.
+		User: anyUser,
+		Target: acl.TargetRule{
+			manifest.TypeLabelKey: snapshot.LegalHoldManifestType,
+		},
+		Access: AccessLevelRead,
+	},
 	{
 		// users *@host can read own host's policy.
 		User: anyUser,
@@ -52,6 +60,16 @@
 		},
 		Access: AccessLevelRead,
 	},
+	{
+		// users username@* can read own user-level policy.
+		User: anyUser,
+		Target: acl.TargetRule{
+			manifest.TypeLabelKey:  policy.ManifestType,
+			policy.PolicyTypeLabel: policy.PolicyTypeUserAllHosts,
+			policy.UsernameLabel:   acl.OwnUser,
+		},
+		Access: AccessLevelRead,
+	},
 	{
 		// username@hostname has full access to their own policies
 		User: anyUser,
@@ -90,6 +108,7 @@ type aclCache struct {
 	lastRep         repo.Repository
 	nextRefreshTime time.Time
 	aclEntries      []*acl.Entry
+	userProfiles    map[string]*user.Profile
 }
 
 // Authorize returns authorization info based on ACLs stored in the repository falling back to legacy authorizer
@@ -125,13 +144,28 @@ func (ac *aclCache) Authorize(ctx context.Context, rep repo.Repository, username
 		} else {
 			ac.aclEntries = newMap
 		}
+
+		newProfiles, err := user.LoadProfileMap(ctx, rep, ac.userProfiles)
+		if err != nil {
+			log(ctx).Errorf("unable to load user profiles: %v", err)
+		} else {
+			ac.userProfiles = newProfiles
+		}
 	}
 
+	var result AuthorizationInfo
+
 	if len(ac.aclEntries) == 0 {
-		return legacyAuthorizationInfo{usernameAtHostname}
+		result = legacyAuthorizationInfo{usernameAtHostname}
+	} else {
+		result = aclEntriesAuthorizer{acl.EntriesForUser(ac.aclEntries, u, h), u, h}
+	}
+
+	if p := ac.userProfiles[usernameAtHostname]; p != nil && p.AppendOnly {
+		return AppendOnly(result)
 	}
 
-	return aclEntriesAuthorizer{acl.EntriesForUser(ac.aclEntries, u, h), u, h}
+	return result
 }
 
 func (ac *aclCache) Refresh(ctx context.Context) error {
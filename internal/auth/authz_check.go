@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/internal/user"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+// ACLDecision describes the result of evaluating access of a user to a target,
+// as it would be made by DefaultAuthorizer.
+type ACLDecision struct {
+	Allowed         bool        `json:"allowed"`
+	EffectiveAccess AccessLevel `json:"effectiveAccess"`
+
+	// MatchingRule is the ACL entry granting the effective access, nil if no entry matches.
+	MatchingRule   *acl.Entry  `json:"matchingRule,omitempty"`
+	MatchingRuleID manifest.ID `json:"matchingRuleID,omitempty"`
+
+	// MatchingCandidateRule is set when MatchingRule is one of the candidate rules being checked.
+	MatchingCandidateRule bool `json:"matchingCandidateRule,omitempty"`
+
+	// LegacyRules is set when there are no ACL entries and legacy authorization rules apply.
+	LegacyRules bool `json:"legacyRules,omitempty"`
+
+	// AppendOnly is set when the access of the user is capped because their profile is append-only.
+	AppendOnly bool `json:"appendOnly,omitempty"`
+}
+
+// CheckACLOptions provides ACL entries that are not stored in the repository yet to CheckACL.
+type CheckACLOptions struct {
+	// CandidateRules are evaluated together with the ACL entries stored in the repository.
+	CandidateRules []*acl.Entry
+
+	// OnlyCandidateRules causes CandidateRules to be evaluated instead of the stored ACL entries.
+	OnlyCandidateRules bool
+}
+
+// CheckACL evaluates the ACL entries and user profiles currently stored in the repository, together with optional
+// candidate rules, to determine whether the provided user has the requested access to the target, which allows
+// ACL changes to be validated before rollout.
+func CheckACL(ctx context.Context, rep repo.Repository, usernameAtHostname string, target map[string]string, access AccessLevel, opt CheckACLOptions) (*ACLDecision, error) {
+	parts := strings.Split(usernameAtHostname, "@")
+	if len(parts) != 2 { //nolint:gomnd
+		return nil, errors.Errorf("invalid user %q, must be username@hostname", usernameAtHostname)
+	}
+
+	for _, e := range opt.CandidateRules {
+		if err := e.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid candidate rule")
+		}
+	}
+
+	var entries []*acl.Entry
+
+	if !opt.OnlyCandidateRules {
+		stored, err := acl.LoadEntries(ctx, rep, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load ACL entries")
+		}
+
+		entries = append(entries, stored...)
+	}
+
+	entries = append(entries, opt.CandidateRules...)
+
+	profiles, err := user.LoadProfileMap(ctx, rep, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load user profiles")
+	}
+
+	d := &ACLDecision{}
+
+	if len(entries) == 0 {
+		d.LegacyRules = true
+		d.EffectiveAccess = legacyAccessLevel(legacyAuthorizationInfo{usernameAtHostname}, target)
+	} else {
+		d.EffectiveAccess, d.MatchingRule = acl.MatchingEntry(parts[0], parts[1], target, entries)
+		if d.MatchingRule != nil {
+			d.MatchingRuleID = d.MatchingRule.ManifestID
+			d.MatchingCandidateRule = isCandidateRule(d.MatchingRule, opt.CandidateRules)
+		}
+	}
+
+	if p := profiles[usernameAtHostname]; p != nil && p.AppendOnly {
+		d.AppendOnly = true
+		d.EffectiveAccess = capAccessLevel(d.EffectiveAccess, appendOnlyMaxAccessLevel(target))
+	}
+
+	d.Allowed = access != AccessLevelNone && d.EffectiveAccess >= access
+
+	return d, nil
+}
+
+func isCandidateRule(e *acl.Entry, candidates []*acl.Entry) bool {
+	for _, c := range candidates {
+		if c == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+func legacyAccessLevel(ai AuthorizationInfo, target map[string]string) AccessLevel {
+	if target[manifest.TypeLabelKey] == acl.ContentManifestType {
+		return ai.ContentAccessLevel()
+	}
+
+	return ai.ManifestAccessLevel(target)
+}
@@ -9,6 +9,7 @@
 	"github.com/kopia/kopia/internal/acl"
 	"github.com/kopia/kopia/internal/auth"
 	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/user"
 	"github.com/kopia/kopia/repo"
 )
 
@@ -47,6 +48,11 @@
 	"path":     "/path",
 }
 
+var legalHold = map[string]string{
+	"type":       "legal-hold",
+	"snapshotID": "abcdef",
+}
+
 var fooAtBarPolicy = map[string]string{
 	"type":       "policy",
 	"username":   "foo",
@@ -61,6 +67,12 @@
 	"policyType": "user",
 }
 
+var fooPolicy = map[string]string{
+	"type":       "policy",
+	"username":   "foo",
+	"policyType": "user-all-hosts",
+}
+
 var barPolicy = map[string]string{
 	"type":       "policy",
 	"hostname":   "bar",
@@ -85,10 +97,12 @@ func TestNoAccess(t *testing.T) {
 	verifyManifestAccessLevel(t, na, fooAtBazPathPolicy, auth.AccessLevelNone)
 	verifyManifestAccessLevel(t, na, fooAtBarPolicy, auth.AccessLevelNone)
 	verifyManifestAccessLevel(t, na, fooAtBazPolicy, auth.AccessLevelNone)
+	verifyManifestAccessLevel(t, na, fooPolicy, auth.AccessLevelNone)
 	verifyManifestAccessLevel(t, na, barPolicy, auth.AccessLevelNone)
 	verifyManifestAccessLevel(t, na, bazPolicy, auth.AccessLevelNone)
 	verifyManifestAccessLevel(t, na, fooAtBarSnapshot, auth.AccessLevelNone)
 	verifyManifestAccessLevel(t, na, fooAtBazSnapshot, auth.AccessLevelNone)
+	verifyManifestAccessLevel(t, na, legalHold, auth.AccessLevelNone)
 }
 
 func TestLegacyAuthorizer(t *testing.T) {
@@ -124,6 +138,7 @@ func verifyLegacyAuthorizer(ctx context.Context, t *testing.T, rep repo.Reposito
 		fooAtBazPathPolicyAccess auth.AccessLevel
 		fooAtBarPolicyAccess     auth.AccessLevel
 		fooAtBazPolicyAccess     auth.AccessLevel
+		fooPolicyAccess          auth.AccessLevel
 		barPolicyAccess          auth.AccessLevel
 		bazPolicyAccess          auth.AccessLevel
 		fooAtBarSnapshotAccess   auth.AccessLevel
@@ -136,6 +151,7 @@ func verifyLegacyAuthorizer(ctx context.Context, t *testing.T, rep repo.Reposito
 			fooAtBazPathPolicyAccess: auth.AccessLevelNone,
 			fooAtBarPolicyAccess:     auth.AccessLevelFull, // full access to own user policy
 			fooAtBazPolicyAccess:     auth.AccessLevelNone,
+			fooPolicyAccess:          auth.AccessLevelRead, // read access to own user-level policy
 			barPolicyAccess:          auth.AccessLevelRead, // read access to own host policy
 			bazPolicyAccess:          auth.AccessLevelNone,
 			fooAtBarSnapshotAccess:   auth.AccessLevelFull, // full access to own snapshot
@@ -148,6 +164,7 @@ func verifyLegacyAuthorizer(ctx context.Context, t *testing.T, rep repo.Reposito
 			fooAtBazPathPolicyAccess: auth.AccessLevelNone,
 			fooAtBarPolicyAccess:     auth.AccessLevelNone,
 			fooAtBazPolicyAccess:     auth.AccessLevelNone,
+			fooPolicyAccess:          auth.AccessLevelNone,
 			barPolicyAccess:          auth.AccessLevelRead,
 			bazPolicyAccess:          auth.AccessLevelNone,
 			fooAtBarSnapshotAccess:   auth.AccessLevelNone,
@@ -160,6 +177,7 @@ func verifyLegacyAuthorizer(ctx context.Context, t *testing.T, rep repo.Reposito
 			fooAtBazPathPolicyAccess: auth.AccessLevelNone,
 			fooAtBarPolicyAccess:     auth.AccessLevelNone,
 			fooAtBazPolicyAccess:     auth.AccessLevelNone,
+			fooPolicyAccess:          auth.AccessLevelNone,
 			barPolicyAccess:          auth.AccessLevelNone,
 			bazPolicyAccess:          auth.AccessLevelNone,
 			fooAtBarSnapshotAccess:   auth.AccessLevelNone,
@@ -181,10 +199,14 @@ func verifyLegacyAuthorizer(ctx context.Context, t *testing.T, rep repo.Reposito
 			verifyManifestAccessLevel(t, a, fooAtBazPathPolicy, tc.fooAtBazPathPolicyAccess)
 			verifyManifestAccessLevel(t, a, fooAtBarPolicy, tc.fooAtBarPolicyAccess)
 			verifyManifestAccessLevel(t, a, fooAtBazPolicy, tc.fooAtBazPolicyAccess)
+			verifyManifestAccessLevel(t, a, fooPolicy, tc.fooPolicyAccess)
 			verifyManifestAccessLevel(t, a, barPolicy, tc.barPolicyAccess)
 			verifyManifestAccessLevel(t, a, bazPolicy, tc.bazPolicyAccess)
 			verifyManifestAccessLevel(t, a, fooAtBarSnapshot, tc.fooAtBarSnapshotAccess)
 			verifyManifestAccessLevel(t, a, fooAtBazSnapshot, tc.fooAtBazSnapshotAccess)
+
+			// everybody can read, but not add or remove legal holds.
+			verifyManifestAccessLevel(t, a, legalHold, auth.AccessLevelRead)
 		})
 	}
 }
@@ -196,3 +218,145 @@ func verifyManifestAccessLevel(t *testing.T, a auth.AuthorizationInfo, labels ma
 		t.Errorf("invalid access level to %v: %v, want %v", labels, got, want)
 	}
 }
+
+func TestDefaultAuthorizer_AppendOnlyUser(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	require.NoError(t, user.SetUserProfile(ctx, env.RepositoryWriter, &user.Profile{Username: "foo@bar", AppendOnly: true}))
+	require.NoError(t, user.SetUserProfile(ctx, env.RepositoryWriter, &user.Profile{Username: "foo@baz"}))
+
+	a := auth.DefaultAuthorizer().Authorize(ctx, env.RepositoryWriter, "foo@bar")
+
+	require.Equal(t, auth.AccessLevelAppend, a.ContentAccessLevel())
+	verifyManifestAccessLevel(t, a, globalPolicyLabels, auth.AccessLevelRead)
+	verifyManifestAccessLevel(t, a, barPolicy, auth.AccessLevelRead)
+	verifyManifestAccessLevel(t, a, fooAtBarSnapshot, auth.AccessLevelAppend)
+	verifyManifestAccessLevel(t, a, fooAtBazSnapshot, auth.AccessLevelNone)
+
+	// newer policies replace older ones, so append-only users can't write them.
+	verifyManifestAccessLevel(t, a, fooAtBarPolicy, auth.AccessLevelRead)
+	verifyManifestAccessLevel(t, a, fooAtBarPathPolicy, auth.AccessLevelRead)
+
+	// users without the append-only flag are not affected.
+	a = auth.DefaultAuthorizer().Authorize(ctx, env.RepositoryWriter, "foo@baz")
+
+	require.Equal(t, auth.AccessLevelFull, a.ContentAccessLevel())
+	verifyManifestAccessLevel(t, a, fooAtBazSnapshot, auth.AccessLevelFull)
+}
+
+func TestCheckACL(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	// no ACLs, legacy rules apply.
+	d, err := auth.CheckACL(ctx, env.Repository, "foo@bar", fooAtBarSnapshot, auth.AccessLevelFull, auth.CheckACLOptions{})
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.True(t, d.LegacyRules)
+	require.Nil(t, d.MatchingRule)
+
+	// the first candidate rule disables legacy rules.
+	readOwnSnapshots := &acl.Entry{
+		User:   "foo@bar",
+		Target: acl.TargetRule{"type": "snapshot", "username": acl.OwnUser},
+		Access: acl.AccessLevelRead,
+	}
+
+	d, err = auth.CheckACL(ctx, env.Repository, "foo@bar", fooAtBarSnapshot, auth.AccessLevelFull, auth.CheckACLOptions{
+		CandidateRules: []*acl.Entry{readOwnSnapshots},
+	})
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	require.False(t, d.LegacyRules)
+	require.Equal(t, auth.AccessLevelRead, d.EffectiveAccess)
+	require.Equal(t, readOwnSnapshots, d.MatchingRule)
+	require.True(t, d.MatchingCandidateRule)
+
+	for _, e := range auth.DefaultACLs {
+		require.NoError(t, acl.AddACL(ctx, env.RepositoryWriter, e))
+	}
+
+	d, err = auth.CheckACL(ctx, env.RepositoryWriter, "foo@bar", fooAtBarSnapshot, auth.AccessLevelFull, auth.CheckACLOptions{})
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.False(t, d.LegacyRules)
+	require.Equal(t, auth.AccessLevelFull, d.EffectiveAccess)
+	require.NotNil(t, d.MatchingRule)
+	require.NotEmpty(t, d.MatchingRuleID)
+	require.Equal(t, "snapshot", d.MatchingRule.Target["type"])
+
+	d, err = auth.CheckACL(ctx, env.RepositoryWriter, "foo@bar", globalPolicyLabels, auth.AccessLevelAppend, auth.CheckACLOptions{})
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	require.Equal(t, auth.AccessLevelRead, d.EffectiveAccess)
+
+	d, err = auth.CheckACL(ctx, env.RepositoryWriter, "evil@bar", fooAtBarSnapshot, auth.AccessLevelRead, auth.CheckACLOptions{})
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	require.Equal(t, auth.AccessLevelNone, d.EffectiveAccess)
+	require.Nil(t, d.MatchingRule)
+
+	// append-only users are capped at append access.
+	require.NoError(t, user.SetUserProfile(ctx, env.RepositoryWriter, &user.Profile{Username: "foo@bar", AppendOnly: true}))
+
+	d, err = auth.CheckACL(ctx, env.RepositoryWriter, "foo@bar", fooAtBarSnapshot, auth.AccessLevelFull, auth.CheckACLOptions{})
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	require.True(t, d.AppendOnly)
+	require.Equal(t, auth.AccessLevelAppend, d.EffectiveAccess)
+
+	d, err = auth.CheckACL(ctx, env.RepositoryWriter, "foo@bar", fooAtBarPathPolicy, auth.AccessLevelAppend, auth.CheckACLOptions{})
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	require.Equal(t, auth.AccessLevelRead, d.EffectiveAccess)
+
+	_, err = auth.CheckACL(ctx, env.RepositoryWriter, "foo", fooAtBarSnapshot, auth.AccessLevelRead, auth.CheckACLOptions{})
+	require.Error(t, err)
+
+	_, err = auth.CheckACL(ctx, env.RepositoryWriter, "foo@bar", fooAtBarSnapshot, auth.AccessLevelRead, auth.CheckACLOptions{
+		CandidateRules: []*acl.Entry{{User: "foo", Target: acl.TargetRule{"type": "snapshot"}, Access: acl.AccessLevelRead}},
+	})
+	require.Error(t, err)
+}
+
+func TestCheckACLCandidateRules(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	for _, e := range auth.DefaultACLs {
+		require.NoError(t, acl.AddACL(ctx, env.RepositoryWriter, e))
+	}
+
+	writeGlobalPolicy := &acl.Entry{
+		User:   "foo@bar",
+		Target: acl.TargetRule{"type": "policy", "policyType": "global"},
+		Access: acl.AccessLevelFull,
+	}
+
+	// candidate rule evaluated alongside stored entries.
+	d, err := auth.CheckACL(ctx, env.RepositoryWriter, "foo@bar", globalPolicyLabels, auth.AccessLevelFull, auth.CheckACLOptions{
+		CandidateRules: []*acl.Entry{writeGlobalPolicy},
+	})
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.Equal(t, writeGlobalPolicy, d.MatchingRule)
+	require.True(t, d.MatchingCandidateRule)
+	require.Empty(t, d.MatchingRuleID)
+
+	// stored entry still matches other targets.
+	d, err = auth.CheckACL(ctx, env.RepositoryWriter, "foo@bar", fooAtBarSnapshot, auth.AccessLevelFull, auth.CheckACLOptions{
+		CandidateRules: []*acl.Entry{writeGlobalPolicy},
+	})
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.False(t, d.MatchingCandidateRule)
+	require.NotEmpty(t, d.MatchingRuleID)
+
+	// candidate rules evaluated instead of stored entries.
+	d, err = auth.CheckACL(ctx, env.RepositoryWriter, "foo@bar", fooAtBarSnapshot, auth.AccessLevelRead, auth.CheckACLOptions{
+		CandidateRules:     []*acl.Entry{writeGlobalPolicy},
+		OnlyCandidateRules: true,
+	})
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+	require.False(t, d.LegacyRules)
+	require.Nil(t, d.MatchingRule)
+}
@@ -0,0 +1,393 @@
+// Package blobarchive implements encrypted archives of repository blobs created since a recorded checkpoint,
+// which can be copied to offline media and applied to an offline replica of the repository.
+package blobarchive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/encryptedarchive"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("blobarchive")
+
+// The archive starts with recordTypeHeader, followed by blob records, each carrying a chunk of a blob,
+// the last record is always recordTypeEnd.
+const (
+	archiveMagic   = "KOPIABLB"
+	archiveVersion = 1
+
+	recordTypeHeader = 1
+	recordTypeBlob   = 2
+	recordTypeEnd    = 3
+
+	// blobs are split into chunks to stay below the maximum record size.
+	maxChunkSize = encryptedarchive.MaxRecordSize / 2
+
+	chunkOffsetSize = 8
+	blobLengthSize  = 8
+)
+
+// ErrInvalidPassword is returned when archive password is invalid.
+var ErrInvalidPassword = encryptedarchive.ErrInvalidPassword
+
+// Checkpoint records IDs of blobs already exported, so that subsequent exports only include blobs created later.
+type Checkpoint struct {
+	Time  time.Time `json:"time"`
+	Blobs []blob.ID `json:"blobs"`
+}
+
+func (c *Checkpoint) contains() map[blob.ID]bool {
+	result := map[blob.ID]bool{}
+
+	if c == nil {
+		return result
+	}
+
+	for _, id := range c.Blobs {
+		result[id] = true
+	}
+
+	return result
+}
+
+// Stats describes the contents of an archive.
+type Stats struct {
+	Blobs int   `json:"blobs"`
+	Bytes int64 `json:"bytes"`
+}
+
+// archiveHeader is the payload of the header record.
+type archiveHeader struct {
+	RepositoryIDHash string    `json:"repositoryIDHash"`
+	CreateTime       time.Time `json:"createTime"`
+	Since            time.Time `json:"since,omitempty"`
+}
+
+// ExportOptions provides options for Export.
+type ExportOptions struct {
+	// Prefixes of blobs to export, in the order in which they will be applied.
+	Prefixes []blob.ID
+
+	// Blobs recorded in the checkpoint are not exported, nil exports all blobs.
+	Checkpoint *Checkpoint
+
+	// Unique ID of the repository, archives can only be applied to replicas of the same repository.
+	RepositoryID []byte
+
+	Password string
+}
+
+func repositoryIDHash(id []byte) string {
+	h := sha256.Sum256(id)
+
+	return hex.EncodeToString(h[:])
+}
+
+// Export writes an encrypted archive containing all blobs with the provided prefixes that are not in the checkpoint
+// and returns a new checkpoint which includes the exported blobs.
+func Export(ctx context.Context, r blob.Reader, output io.Writer, opt ExportOptions) (*Stats, *Checkpoint, error) {
+	newCheckpoint := &Checkpoint{Time: clock.Now()}
+	exported := opt.Checkpoint.contains()
+
+	var toExport []blob.Metadata
+
+	for _, prefix := range opt.Prefixes {
+		var blobs []blob.Metadata
+
+		if err := r.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+			newCheckpoint.Blobs = append(newCheckpoint.Blobs, bm.BlobID)
+
+			if !exported[bm.BlobID] {
+				blobs = append(blobs, bm)
+			}
+
+			return nil
+		}); err != nil {
+			return nil, nil, errors.Wrapf(err, "error listing blobs with prefix %q", prefix)
+		}
+
+		sort.Slice(blobs, func(i, j int) bool { return blobs[i].BlobID < blobs[j].BlobID })
+
+		toExport = append(toExport, blobs...)
+	}
+
+	sort.Slice(newCheckpoint.Blobs, func(i, j int) bool { return newCheckpoint.Blobs[i] < newCheckpoint.Blobs[j] })
+
+	w, err := encryptedarchive.NewWriter(output, archiveMagic, archiveVersion, opt.Password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hdr := archiveHeader{
+		RepositoryIDHash: repositoryIDHash(opt.RepositoryID),
+		CreateTime:       newCheckpoint.Time,
+	}
+
+	if opt.Checkpoint != nil {
+		hdr.Since = opt.Checkpoint.Time
+	}
+
+	b, err := json.Marshal(hdr)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to serialize archive header")
+	}
+
+	if err := w.WriteRecord(recordTypeHeader, b); err != nil {
+		return nil, nil, err
+	}
+
+	log(ctx).Infof("Exporting %v blobs...", len(toExport))
+
+	st := &Stats{}
+
+	for _, bm := range toExport {
+		data, err := r.GetBlob(ctx, bm.BlobID, 0, -1)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "error reading blob %v", bm.BlobID)
+		}
+
+		if err := writeBlobRecords(w, bm.BlobID, data); err != nil {
+			return nil, nil, err
+		}
+
+		st.Blobs++
+		st.Bytes += int64(len(data))
+	}
+
+	b, err = json.Marshal(st)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to serialize archive stats")
+	}
+
+	if err := w.WriteRecord(recordTypeEnd, b); err != nil {
+		return nil, nil, err
+	}
+
+	return st, newCheckpoint, nil
+}
+
+// writeBlobRecords writes the blob as a sequence of records, each of which is:
+// blob ID length (1 byte), blob ID, total blob length (8 bytes), chunk offset (8 bytes), chunk data.
+func writeBlobRecords(w *encryptedarchive.Writer, id blob.ID, data []byte) error {
+	if len(id) == 0 || len(id) > 255 { // nolint:gomnd
+		return errors.Errorf("invalid blob ID %v", id)
+	}
+
+	var lengthAndOffset [blobLengthSize + chunkOffsetSize]byte
+
+	binary.BigEndian.PutUint64(lengthAndOffset[0:blobLengthSize], uint64(len(data)))
+
+	for off := 0; off == 0 || off < len(data); off += maxChunkSize {
+		end := off + maxChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		binary.BigEndian.PutUint64(lengthAndOffset[blobLengthSize:], uint64(off))
+
+		if err := w.WriteRecord(recordTypeBlob, []byte{byte(len(id))}, []byte(id), lengthAndOffset[:], data[off:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type blobChunk struct {
+	id     blob.ID
+	length int64
+	offset int64
+	data   []byte
+}
+
+func parseBlobChunk(payload []byte) (blobChunk, error) {
+	if len(payload) == 0 || len(payload) < 1+int(payload[0])+blobLengthSize+chunkOffsetSize {
+		return blobChunk{}, errors.New("invalid blob record")
+	}
+
+	idLen := int(payload[0])
+	p := payload[1+idLen:]
+
+	return blobChunk{
+		id:     blob.ID(payload[1 : 1+idLen]),
+		length: int64(binary.BigEndian.Uint64(p[0:blobLengthSize])),
+		offset: int64(binary.BigEndian.Uint64(p[blobLengthSize : blobLengthSize+chunkOffsetSize])),
+		data:   p[blobLengthSize+chunkOffsetSize:],
+	}, nil
+}
+
+// Archive is an opened blob archive.
+type Archive struct {
+	rr     *encryptedarchive.Reader
+	header archiveHeader
+	stats  Stats
+}
+
+// Open opens the archive stored in the provided reader and verifies its integrity.
+func Open(ctx context.Context, r io.ReaderAt, password string) (*Archive, error) {
+	rr, err := encryptedarchive.NewReader(r, archiveMagic, archiveVersion, password)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{rr: rr}
+
+	blobs := 0
+
+	if err := a.iterateBlobs(func(id blob.ID, data []byte) error {
+		blobs++
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if a.stats.Blobs != blobs {
+		return nil, errors.New("archive is incomplete")
+	}
+
+	log(ctx).Debugf("opened archive with %v blobs", blobs)
+
+	return a, nil
+}
+
+// iterateBlobs reads all records of the archive and invokes the callback for each complete blob.
+func (a *Archive) iterateBlobs(cb func(id blob.ID, data []byte) error) error {
+	var (
+		current blobChunk
+		buf     []byte
+	)
+
+	loc := a.rr.FirstRecord()
+
+	for seq := 0; ; seq++ {
+		recordType, payload, next, err := a.rr.ReadRecord(loc)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("archive is truncated")
+			}
+
+			return err
+		}
+
+		if (seq == 0) != (recordType == recordTypeHeader) {
+			return errors.New("invalid archive header")
+		}
+
+		switch recordType {
+		case recordTypeHeader:
+			if err := json.Unmarshal(payload, &a.header); err != nil {
+				return errors.New("invalid header record")
+			}
+
+		case recordTypeBlob:
+			c, err := parseBlobChunk(payload)
+			if err != nil {
+				return err
+			}
+
+			if c.offset == 0 {
+				if len(buf) != 0 {
+					return errors.Errorf("blob %v is incomplete", current.id)
+				}
+
+				current = c
+			}
+
+			if c.id != current.id || c.length != current.length || c.offset != int64(len(buf)) {
+				return errors.Errorf("unexpected chunk of blob %v", c.id)
+			}
+
+			buf = append(buf, c.data...)
+
+			if int64(len(buf)) == current.length {
+				if err := cb(current.id, buf); err != nil {
+					return err
+				}
+
+				buf = nil
+			}
+
+		case recordTypeEnd:
+			if len(buf) != 0 {
+				return errors.Errorf("blob %v is incomplete", current.id)
+			}
+
+			if err := json.Unmarshal(payload, &a.stats); err != nil {
+				return errors.New("invalid end record")
+			}
+
+			return nil
+
+		default:
+			return errors.Errorf("unsupported record type %v", recordType)
+		}
+
+		loc = next
+	}
+}
+
+// Stats returns statistics of the archive.
+func (a *Archive) Stats() Stats {
+	return a.stats
+}
+
+// CreateTime returns the time when the archive was created.
+func (a *Archive) CreateTime() time.Time {
+	return a.header.CreateTime
+}
+
+// ApplyStats describes the results of applying the archive.
+type ApplyStats struct {
+	Written int   `json:"written"`
+	Skipped int   `json:"skipped"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// Apply writes blobs from the archive to the provided storage of a replica of the repository with the given unique ID.
+// Blobs already present in the storage are skipped, so applying the same archive multiple times is safe.
+func (a *Archive) Apply(ctx context.Context, st blob.Storage, repositoryID []byte) (*ApplyStats, error) {
+	if a.header.RepositoryIDHash != repositoryIDHash(repositoryID) {
+		return nil, errors.New("archive was exported from a different repository")
+	}
+
+	as := &ApplyStats{}
+
+	if err := a.iterateBlobs(func(id blob.ID, data []byte) error {
+		bm, err := st.GetMetadata(ctx, id)
+
+		switch {
+		case err == nil && bm.Length == int64(len(data)):
+			as.Skipped++
+			return nil
+
+		case err != nil && !errors.Is(err, blob.ErrBlobNotFound):
+			return errors.Wrapf(err, "error checking blob %v", id)
+		}
+
+		if err := st.PutBlob(ctx, id, gather.FromSlice(data)); err != nil {
+			return errors.Wrapf(err, "error writing blob %v", id)
+		}
+
+		as.Written++
+		as.Bytes += int64(len(data))
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return as, nil
+}
@@ -0,0 +1,103 @@
+package blobarchive_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobarchive"
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const archivePassword = "archive-password"
+
+var (
+	repositoryID = []byte("repository-id")
+	prefixes     = []blob.ID{"p", "q", "n"}
+)
+
+func TestExportApply(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	src := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	dst := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+
+	for id, data := range map[blob.ID]string{
+		"p1":               "pack1",
+		"q1":               "pack2",
+		"n1":               "index1",
+		"kopia.repository": "format",
+		"p0":               "",
+	} {
+		require.NoError(t, src.PutBlob(ctx, id, gather.FromSlice([]byte(data))))
+	}
+
+	var buf bytes.Buffer
+
+	st, cp, err := blobarchive.Export(ctx, src, &buf, blobarchive.ExportOptions{
+		Prefixes:     prefixes,
+		RepositoryID: repositoryID,
+		Password:     archivePassword,
+	})
+	require.NoError(t, err)
+	require.Equal(t, blobarchive.Stats{Blobs: 4, Bytes: 16}, *st)
+	require.Equal(t, []blob.ID{"n1", "p0", "p1", "q1"}, cp.Blobs)
+
+	data := buf.Bytes()
+
+	_, err = blobarchive.Open(ctx, bytes.NewReader(data), "wrong-password")
+	require.ErrorIs(t, err, blobarchive.ErrInvalidPassword)
+
+	_, err = blobarchive.Open(ctx, bytes.NewReader(data[0:len(data)-10]), archivePassword)
+	require.Error(t, err)
+
+	a, err := blobarchive.Open(ctx, bytes.NewReader(data), archivePassword)
+	require.NoError(t, err)
+	require.Equal(t, *st, a.Stats())
+
+	_, err = a.Apply(ctx, dst, []byte("other-repository-id"))
+	require.Error(t, err)
+
+	ast, err := a.Apply(ctx, dst, repositoryID)
+	require.NoError(t, err)
+	require.Equal(t, blobarchive.ApplyStats{Written: 4, Bytes: 16}, *ast)
+
+	// applying again skips existing blobs.
+	ast, err = a.Apply(ctx, dst, repositoryID)
+	require.NoError(t, err)
+	require.Equal(t, blobarchive.ApplyStats{Skipped: 4}, *ast)
+
+	// the next export only includes blobs created after the checkpoint.
+	require.NoError(t, src.PutBlob(ctx, "p2", gather.FromSlice([]byte("pack3"))))
+	require.NoError(t, src.PutBlob(ctx, "n2", gather.FromSlice([]byte("index2"))))
+
+	buf.Reset()
+
+	st, cp, err = blobarchive.Export(ctx, src, &buf, blobarchive.ExportOptions{
+		Prefixes:     prefixes,
+		Checkpoint:   cp,
+		RepositoryID: repositoryID,
+		Password:     archivePassword,
+	})
+	require.NoError(t, err)
+	require.Equal(t, blobarchive.Stats{Blobs: 2, Bytes: 11}, *st)
+	require.Len(t, cp.Blobs, 6)
+
+	a, err = blobarchive.Open(ctx, bytes.NewReader(buf.Bytes()), archivePassword)
+	require.NoError(t, err)
+
+	ast, err = a.Apply(ctx, dst, repositoryID)
+	require.NoError(t, err)
+	require.Equal(t, blobarchive.ApplyStats{Written: 2, Bytes: 11}, *ast)
+
+	got, err := dst.GetBlob(ctx, "p2", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("pack3"), got)
+
+	_, err = dst.GetBlob(ctx, "kopia.repository", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+}
@@ -269,6 +269,15 @@ func (s *eventuallyConsistentStorage) DisplayName() string {
 	return s.realStorage.DisplayName()
 }
 
+func (s *eventuallyConsistentStorage) Capabilities() blob.Capabilities {
+	c := s.realStorage.Capabilities()
+
+	// listing merges cached results, so it can't be resumed.
+	c.OrderedListing = false
+
+	return c
+}
+
 // NewEventuallyConsistentStorage returns an eventually-consistent storage wrapper on top
 // of provided storage.
 func NewEventuallyConsistentStorage(st blob.Storage, listSettleTime time.Duration, timeNow func() time.Time) blob.Storage {
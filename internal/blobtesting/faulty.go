@@ -107,6 +107,29 @@ func (s *FaultyStorage) DisplayName() string {
 	return s.Base.DisplayName()
 }
 
+// ListBlobsAfter implements blob.OrderedLister.
+func (s *FaultyStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	if err := s.getNextFault(ctx, "ListBlobs", prefix); err != nil {
+		return err
+	}
+
+	return blob.ListBlobsAfter(ctx, s.Base, prefix, startAfter, func(bm blob.Metadata) error {
+		if err := s.getNextFault(ctx, "ListBlobsItem", prefix); err != nil {
+			return err
+		}
+		return callback(bm)
+	})
+}
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s *FaultyStorage) PresignedGetURL(ctx context.Context, id blob.ID, expiration time.Duration) (string, error) {
+	return blob.PresignedGetURL(ctx, s.Base, id, expiration)
+}
+
+func (s *FaultyStorage) Capabilities() blob.Capabilities {
+	return s.Base.Capabilities()
+}
+
 func (s *FaultyStorage) getNextFault(ctx context.Context, method string, args ...interface{}) error {
 	s.mu.Lock()
 
@@ -93,12 +93,17 @@ func (s *mapStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
 }
 
 func (s *mapStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	return s.ListBlobsAfter(ctx, prefix, "", callback)
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s *mapStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
 	s.mutex.RLock()
 
 	keys := []blob.ID{}
 
 	for k := range s.data {
-		if strings.HasPrefix(string(k), string(prefix)) {
+		if strings.HasPrefix(string(k), string(prefix)) && k > startAfter {
 			keys = append(keys, k)
 		}
 	}
@@ -167,6 +172,13 @@ func (s *mapStorage) DisplayName() string {
 	return "Map"
 }
 
+func (s *mapStorage) Capabilities() blob.Capabilities {
+	return blob.Capabilities{
+		SetTime:        true,
+		OrderedListing: true,
+	}
+}
+
 // NewMapStorage returns an implementation of Storage backed by the contents of given map.
 // Used primarily for testing.
 func NewMapStorage(data DataMap, keyTime map[blob.ID]time.Time, timeNow func() time.Time) blob.Storage {
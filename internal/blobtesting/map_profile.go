@@ -0,0 +1,376 @@
+package blobtesting
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// ErrThrottled is returned by map storage operations rejected due to simulated throttling.
+var ErrThrottled = errors.New("simulated throttling, please retry")
+
+// MapStorageProfile describes cloud-like behaviors simulated by map storage.
+// The zero value describes strongly-consistent storage without latency or errors.
+type MapStorageProfile struct {
+	// Latency is the real-time delay applied to each operation.
+	Latency time.Duration
+
+	// ReadAfterWriteDelay is the time after a blob is written or deleted
+	// during which GetBlob() and GetMetadata() still return its previous state.
+	ReadAfterWriteDelay time.Duration
+
+	// ListDelay is the time after a blob is written or deleted during which
+	// ListBlobs() still returns its previous state.
+	ListDelay time.Duration
+
+	// ThrottleProbability is the probability in [0..1] of an operation failing with ErrThrottled.
+	ThrottleProbability float64
+
+	// Seed initializes the random number generator used to decide which operations are throttled.
+	Seed int64
+}
+
+// Predefined map storage profiles.
+var (
+	// ProfileStronglyConsistent simulates storage with read-after-write and list-after-write consistency.
+	ProfileStronglyConsistent = MapStorageProfile{}
+
+	// ProfileEventuallyConsistent simulates storage where changes take some time to become visible,
+	// with listings lagging behind reads.
+	ProfileEventuallyConsistent = MapStorageProfile{
+		ReadAfterWriteDelay: 1 * time.Second,
+		ListDelay:           5 * time.Second,
+	}
+
+	// ProfileThrottled simulates storage that rejects some of the requests.
+	ProfileThrottled = MapStorageProfile{
+		ThrottleProbability: 0.1,
+	}
+)
+
+// blobVersion is a state of a blob which may not be visible yet.
+type blobVersion struct {
+	changeTime time.Time // time of the change, zero for the initial state
+	exists     bool
+	data       []byte
+	timestamp  time.Time
+}
+
+// profiledMapStorage wraps mapStorage, simulating the behaviors described by a profile.
+// The underlying mapStorage always reflects the latest state, while states of blobs
+// changed recently enough to not be visible yet are tracked in pending.
+type profiledMapStorage struct {
+	base    *mapStorage
+	profile MapStorageProfile
+
+	mu      sync.Mutex
+	rnd     *rand.Rand
+	pending map[blob.ID][]blobVersion
+}
+
+// visibleVersionLocked returns the version of a pending blob visible after a given delay,
+// and whether the blob has pending versions. Versions which are no longer needed are removed.
+func (s *profiledMapStorage) visibleVersionLocked(id blob.ID, delay time.Duration) (blobVersion, bool) {
+	versions := s.pending[id]
+	if len(versions) == 0 {
+		return blobVersion{}, false
+	}
+
+	now := s.base.timeNow()
+
+	maxDelay := s.profile.ReadAfterWriteDelay
+	if s.profile.ListDelay > maxDelay {
+		maxDelay = s.profile.ListDelay
+	}
+
+	// drop versions that are superseded by versions visible to all operations.
+	for len(versions) > 1 && !now.Before(versions[1].changeTime.Add(maxDelay)) {
+		versions = versions[1:]
+	}
+
+	if len(versions) == 1 && !now.Before(versions[0].changeTime.Add(maxDelay)) {
+		delete(s.pending, id)
+		return blobVersion{}, false
+	}
+
+	s.pending[id] = versions
+
+	v := versions[0]
+
+	for _, nv := range versions[1:] {
+		if now.Before(nv.changeTime.Add(delay)) {
+			break
+		}
+
+		v = nv
+	}
+
+	return v, true
+}
+
+// recordChangeLocked records the new state of a blob, before it is applied to the underlying storage.
+func (s *profiledMapStorage) recordChangeLocked(id blob.ID, newVersion blobVersion) {
+	if s.profile.ReadAfterWriteDelay <= 0 && s.profile.ListDelay <= 0 {
+		return
+	}
+
+	// make sure the pending versions are up-to-date.
+	s.visibleVersionLocked(id, 0)
+
+	if len(s.pending[id]) == 0 {
+		s.base.mutex.RLock()
+		data, exists := s.base.data[id]
+		initial := blobVersion{
+			exists:    exists,
+			data:      data,
+			timestamp: s.base.keyTime[id],
+		}
+		s.base.mutex.RUnlock()
+
+		s.pending[id] = []blobVersion{initial}
+	}
+
+	newVersion.changeTime = s.base.timeNow()
+	s.pending[id] = append(s.pending[id], newVersion)
+}
+
+func (s *profiledMapStorage) simulate(ctx context.Context) error {
+	if s.profile.Latency > 0 {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "simulated latency interrupted")
+		case <-time.After(s.profile.Latency):
+		}
+	}
+
+	if s.profile.ThrottleProbability <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	throttled := s.rnd.Float64() < s.profile.ThrottleProbability
+	s.mu.Unlock()
+
+	if throttled {
+		return ErrThrottled
+	}
+
+	return nil
+}
+
+func (s *profiledMapStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	if err := s.simulate(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	v, pending := s.visibleVersionLocked(id, s.profile.ReadAfterWriteDelay)
+	s.mu.Unlock()
+
+	if !pending {
+		return s.base.GetBlob(ctx, id, offset, length)
+	}
+
+	if !v.exists {
+		return nil, blob.ErrBlobNotFound
+	}
+
+	return NewMapStorage(DataMap{id: v.data}, nil, nil).GetBlob(ctx, id, offset, length)
+}
+
+func (s *profiledMapStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	if err := s.simulate(ctx); err != nil {
+		return blob.Metadata{}, err
+	}
+
+	s.mu.Lock()
+	v, pending := s.visibleVersionLocked(id, s.profile.ReadAfterWriteDelay)
+	s.mu.Unlock()
+
+	if !pending {
+		return s.base.GetMetadata(ctx, id)
+	}
+
+	if !v.exists {
+		return blob.Metadata{}, blob.ErrBlobNotFound
+	}
+
+	return blob.Metadata{
+		BlobID:    id,
+		Length:    int64(len(v.data)),
+		Timestamp: v.timestamp,
+	}, nil
+}
+
+func (s *profiledMapStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	if err := s.simulate(ctx); err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+
+	data.WriteTo(&b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recordChangeLocked(id, blobVersion{
+		exists:    true,
+		data:      b.Bytes(),
+		timestamp: s.base.timeNow(),
+	})
+
+	return s.base.PutBlob(ctx, id, data)
+}
+
+func (s *profiledMapStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	if err := s.simulate(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recordChangeLocked(id, blobVersion{})
+
+	return s.base.DeleteBlob(ctx, id)
+}
+
+func (s *profiledMapStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	if err := s.simulate(ctx); err != nil {
+		return err
+	}
+
+	var latest []blob.Metadata
+
+	if err := s.base.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+		latest = append(latest, bm)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+
+	var result []blob.Metadata
+
+	for _, bm := range latest {
+		if _, pending := s.pending[bm.BlobID]; !pending {
+			result = append(result, bm)
+		}
+	}
+
+	for id := range s.pending {
+		if !strings.HasPrefix(string(id), string(prefix)) {
+			continue
+		}
+
+		if v, pending := s.visibleVersionLocked(id, s.profile.ListDelay); !pending {
+			// the blob is no longer pending, report its latest state.
+			for _, bm := range latest {
+				if bm.BlobID == id {
+					result = append(result, bm)
+				}
+			}
+		} else if v.exists {
+			result = append(result, blob.Metadata{
+				BlobID:    id,
+				Length:    int64(len(v.data)),
+				Timestamp: v.timestamp,
+			})
+		}
+	}
+
+	s.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BlobID < result[j].BlobID
+	})
+
+	for _, bm := range result {
+		if err := callback(bm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *profiledMapStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	if err := s.simulate(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.base.mutex.RLock()
+	data, exists := s.base.data[id]
+	s.base.mutex.RUnlock()
+
+	// changing the timestamp is subject to the same delays as writes.
+	if exists {
+		s.recordChangeLocked(id, blobVersion{
+			exists:    true,
+			data:      data,
+			timestamp: t,
+		})
+	}
+
+	return s.base.SetTime(ctx, id, t)
+}
+
+func (s *profiledMapStorage) TouchBlob(ctx context.Context, id blob.ID, threshold time.Duration) error {
+	if err := s.simulate(ctx); err != nil {
+		return err
+	}
+
+	return s.base.TouchBlob(ctx, id, threshold)
+}
+
+func (s *profiledMapStorage) Close(ctx context.Context) error {
+	return s.base.Close(ctx)
+}
+
+func (s *profiledMapStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *profiledMapStorage) DisplayName() string {
+	return s.base.DisplayName()
+}
+
+func (s *profiledMapStorage) Capabilities() blob.Capabilities {
+	c := s.base.Capabilities()
+
+	// listing simulates eventual consistency, so it can't be resumed.
+	c.OrderedListing = false
+
+	return c
+}
+
+// NewMapStorageWithProfile returns an implementation of Storage backed by the contents of given map,
+// which simulates latency, eventual consistency and throttling as described by the profile.
+// Visibility of changes is based on the provided time function, so tests can use fake time
+// to control it.
+func NewMapStorageWithProfile(data DataMap, keyTime map[blob.ID]time.Time, timeNow func() time.Time, profile MapStorageProfile) blob.Storage {
+	if timeNow == nil {
+		timeNow = clock.Now
+	}
+
+	return &profiledMapStorage{
+		base:    NewMapStorage(data, keyTime, timeNow).(*mapStorage),
+		profile: profile,
+		rnd:     rand.New(rand.NewSource(profile.Seed)), //nolint:gosec
+		pending: map[blob.ID][]blobVersion{},
+	}
+}
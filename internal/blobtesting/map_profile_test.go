@@ -0,0 +1,102 @@
+package blobtesting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestMapStorageWithProfileStronglyConsistent(t *testing.T) {
+	r := NewMapStorageWithProfile(DataMap{}, nil, nil, ProfileStronglyConsistent)
+
+	VerifyStorage(testlogging.Context(t), t, r)
+}
+
+func TestMapStorageWithProfileEventuallyConsistent(t *testing.T) {
+	ctx := testlogging.Context(t)
+	ta := faketime.NewTimeAdvance(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), 0)
+	p := ProfileEventuallyConsistent
+
+	r := NewMapStorageWithProfile(DataMap{"old": []byte("old")}, nil, ta.NowFunc(), p)
+
+	require.NoError(t, r.PutBlob(ctx, "new", gather.FromSlice([]byte("new"))))
+	require.NoError(t, r.PutBlob(ctx, "old", gather.FromSlice([]byte("updated"))))
+	require.NoError(t, r.DeleteBlob(ctx, "old"))
+
+	// nothing is visible yet
+	_, err := r.GetBlob(ctx, "new", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	verifyBlobContents(ctx, t, r, "old", "old")
+	require.Equal(t, []blob.ID{"old"}, listBlobIDs(ctx, t, r))
+
+	// reads see the latest state, listings don't
+	ta.Advance(p.ReadAfterWriteDelay)
+
+	verifyBlobContents(ctx, t, r, "new", "new")
+
+	_, err = r.GetMetadata(ctx, "old")
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	require.Equal(t, []blob.ID{"old"}, listBlobIDs(ctx, t, r))
+
+	// everything settled
+	ta.Advance(p.ListDelay)
+
+	require.Equal(t, []blob.ID{"new"}, listBlobIDs(ctx, t, r))
+	require.Empty(t, r.(*profiledMapStorage).pending)
+}
+
+func TestMapStorageWithProfileThrottled(t *testing.T) {
+	ctx := testlogging.Context(t)
+	p := MapStorageProfile{ThrottleProbability: 0.5, Seed: 1}
+
+	r := NewMapStorageWithProfile(DataMap{}, nil, nil, p)
+
+	var throttled, succeeded int
+
+	for i := 0; i < 100; i++ {
+		err := r.PutBlob(ctx, "blob", gather.FromSlice([]byte("data")))
+
+		switch {
+		case errors.Is(err, ErrThrottled):
+			throttled++
+		case err == nil:
+			succeeded++
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	require.Greater(t, throttled, 0)
+	require.Greater(t, succeeded, 0)
+}
+
+func verifyBlobContents(ctx context.Context, t *testing.T, r blob.Storage, id blob.ID, want string) {
+	t.Helper()
+
+	got, err := r.GetBlob(ctx, id, 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, want, string(got))
+}
+
+func listBlobIDs(ctx context.Context, t *testing.T, r blob.Storage) []blob.ID {
+	t.Helper()
+
+	var ids []blob.ID
+
+	require.NoError(t, r.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		ids = append(ids, bm.BlobID)
+		return nil
+	}))
+
+	return ids
+}
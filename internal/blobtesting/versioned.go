@@ -0,0 +1,160 @@
+package blobtesting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// deletedVersion is a previous version of a deleted blob.
+type deletedVersion struct {
+	versionID string
+	data      []byte
+	timestamp time.Time
+	deletedAt time.Time
+}
+
+// versionedMapStorage is a map storage that keeps previous versions of deleted blobs.
+type versionedMapStorage struct {
+	blob.Storage
+
+	timeNow func() time.Time
+
+	mu          sync.Mutex
+	nextVersion int
+	deleted     map[blob.ID][]deletedVersion
+}
+
+func (s *versionedMapStorage) Capabilities() blob.Capabilities {
+	c := s.Storage.Capabilities()
+	c.Versioning = true
+	c.OrderedListing = false
+
+	return c
+}
+
+func (s *versionedMapStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	bm, err := s.Storage.GetMetadata(ctx, id)
+	if errors.Is(err, blob.ErrBlobNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	data, err := s.Storage.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextVersion++
+
+	s.deleted[id] = append(s.deleted[id], deletedVersion{
+		versionID: fmt.Sprintf("v%v", s.nextVersion),
+		data:      data,
+		timestamp: bm.Timestamp,
+		deletedAt: s.timeNow(),
+	})
+
+	// nolint:wrapcheck
+	return s.Storage.DeleteBlob(ctx, id)
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s *versionedMapStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	var result []blob.DeletedMetadata
+
+	s.mu.Lock()
+
+	for id, versions := range s.deleted {
+		if !strings.HasPrefix(string(id), string(prefix)) {
+			continue
+		}
+
+		latest := versions[len(versions)-1]
+
+		result = append(result, blob.DeletedMetadata{
+			Metadata: blob.Metadata{
+				BlobID:    id,
+				Length:    int64(len(latest.data)),
+				Timestamp: latest.timestamp,
+			},
+			VersionID: latest.versionID,
+			DeletedAt: latest.deletedAt,
+		})
+	}
+
+	s.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BlobID < result[j].BlobID
+	})
+
+	for _, dm := range result {
+		if _, err := s.Storage.GetMetadata(ctx, dm.BlobID); err == nil {
+			// blob was re-created after deletion.
+			continue
+		}
+
+		if err := callback(dm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s *versionedMapStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	s.mu.Lock()
+
+	var found *deletedVersion
+
+	for _, v := range s.deleted[id] {
+		if v.versionID == versionID {
+			v := v
+			found = &v
+		}
+	}
+
+	s.mu.Unlock()
+
+	if found == nil {
+		return blob.ErrBlobNotFound
+	}
+
+	if err := s.Storage.PutBlob(ctx, id, gather.FromSlice(found.data)); err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	// nolint:wrapcheck
+	return s.Storage.SetTime(ctx, id, found.timestamp)
+}
+
+// NewVersionedMapStorage returns an implementation of Storage backed by the contents of given map, which keeps
+// previous versions of deleted blobs that can be restored using blob.Undeleter.
+// Used primarily for testing.
+func NewVersionedMapStorage(data DataMap, keyTime map[blob.ID]time.Time, timeNow func() time.Time) blob.Storage {
+	if timeNow == nil {
+		timeNow = clock.Now
+	}
+
+	return &versionedMapStorage{
+		Storage: NewMapStorage(data, keyTime, timeNow),
+		timeNow: timeNow,
+		deleted: map[blob.ID][]deletedVersion{},
+	}
+}
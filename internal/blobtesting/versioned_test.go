@@ -0,0 +1,44 @@
+package blobtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestVersionedMapStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	VerifyStorage(ctx, t, NewVersionedMapStorage(DataMap{}, nil, nil))
+
+	st := NewVersionedMapStorage(DataMap{}, nil, nil)
+
+	require.NoError(t, st.PutBlob(ctx, "a1", gather.FromSlice([]byte{1})))
+	require.NoError(t, st.PutBlob(ctx, "a2", gather.FromSlice([]byte{2})))
+	require.NoError(t, st.DeleteBlob(ctx, "a1"))
+	require.NoError(t, st.DeleteBlob(ctx, "a2"))
+	require.NoError(t, st.PutBlob(ctx, "a2", gather.FromSlice([]byte{3})))
+
+	var deleted []blob.DeletedMetadata
+
+	require.NoError(t, blob.ListDeletedBlobs(ctx, st, "a", func(dm blob.DeletedMetadata) error {
+		deleted = append(deleted, dm)
+		return nil
+	}))
+
+	// a2 was re-created and is not reported.
+	require.Len(t, deleted, 1)
+	require.Equal(t, blob.ID("a1"), deleted[0].BlobID)
+	require.False(t, deleted[0].DeletedAt.IsZero())
+
+	require.NoError(t, blob.UndeleteBlob(ctx, st, "a1", deleted[0].VersionID))
+	AssertGetBlob(ctx, t, st, "a1", []byte{1})
+	AssertGetBlob(ctx, t, st, "a2", []byte{3})
+
+	require.ErrorIs(t, blob.UndeleteBlob(ctx, st, "a1", "no-such-version"), blob.ErrBlobNotFound)
+	require.ErrorIs(t, blob.UndeleteBlob(ctx, NewMapStorage(DataMap{}, nil, nil), "a1", "v1"), blob.ErrUndeleteUnsupported)
+}
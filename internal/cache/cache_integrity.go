@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/stats"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// EntryValidator performs additional validation of a cache entry that has passed verification by StorageProtection.
+type EntryValidator func(key string, data []byte) error
+
+// IntegrityStats describes the results of VerifyIntegrity.
+type IntegrityStats struct {
+	CheckedCount int   `json:"checkedCount"`
+	CheckedBytes int64 `json:"checkedBytes"`
+	EvictedCount int   `json:"evictedCount"`
+	EvictedBytes int64 `json:"evictedBytes"`
+}
+
+// Add adds the provided stats to the receiver.
+func (s *IntegrityStats) Add(other IntegrityStats) {
+	s.CheckedCount += other.CheckedCount
+	s.CheckedBytes += other.CheckedBytes
+	s.EvictedCount += other.EvictedCount
+	s.EvictedBytes += other.EvictedBytes
+}
+
+// VerifyIntegrity reads all items in the cache, verifies them using the StorageProtection of the cache
+// and the optional validator and evicts items that fail verification, so they will be fetched again
+// from the underlying storage when next accessed.
+func (c *PersistentCache) VerifyIntegrity(ctx context.Context, validate EntryValidator) (IntegrityStats, error) {
+	var st IntegrityStats
+
+	if c == nil {
+		return st, nil
+	}
+
+	t0 := clock.Now()
+
+	err := c.cacheStorage.ListBlobs(ctx, "", func(it blob.Metadata) error {
+		if err := ctx.Err(); err != nil {
+			// nolint:wrapcheck
+			return err
+		}
+
+		st.CheckedCount++
+		st.CheckedBytes += it.Length
+
+		verr := c.verifyEntry(ctx, it.BlobID, validate)
+		if verr == nil || errors.Is(verr, blob.ErrBlobNotFound) {
+			// valid or removed concurrently by a sweep.
+			return nil
+		}
+
+		log(ctx).Infof("evicting invalid %v entry %v: %v", c.description, it.BlobID, verr)
+
+		stats.Record(ctx, MetricMalformedCacheDataCount.M(1))
+
+		if err := c.cacheStorage.DeleteBlob(ctx, it.BlobID); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			log(ctx).Errorf("unable to delete %v entry %v: %v", c.description, it.BlobID, err)
+			return nil
+		}
+
+		st.EvictedCount++
+		st.EvictedBytes += it.Length
+
+		return nil
+	})
+	if err != nil {
+		return st, errors.Wrapf(err, "error verifying %v", c.description)
+	}
+
+	log(ctx).Debugf("verified %v items (%v bytes) of %v in %v, evicted %v", st.CheckedCount, st.CheckedBytes, c.description, clock.Since(t0), st.EvictedCount)
+
+	return st, nil
+}
+
+func (c *PersistentCache) verifyEntry(ctx context.Context, id blob.ID, validate EntryValidator) error {
+	v, err := c.cacheStorage.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return errors.Wrap(err, "error reading entry")
+	}
+
+	vb, err := c.storageProtection.Verify(string(id), v)
+	if err != nil {
+		return errors.Wrap(err, "verification failed")
+	}
+
+	if validate == nil {
+		return nil
+	}
+
+	return validate(string(id), vb)
+}
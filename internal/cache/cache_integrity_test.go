@@ -0,0 +1,59 @@
+package cache_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/cache"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestVerifyIntegrity(t *testing.T) {
+	cacheDir := testutil.TempDirectory(t)
+	ctx := testlogging.Context(t)
+
+	const maxSizeBytes = 10000
+
+	cs, err := cache.NewStorageOrNil(ctx, cacheDir, maxSizeBytes, "subdir")
+	require.NoError(t, err)
+
+	pc, err := cache.NewPersistentCache(ctx, "testing", cs, cache.ChecksumProtection([]byte{1, 2, 3}), cache.SizeLimit{MaxSizeBytes: maxSizeBytes}, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
+	require.NoError(t, err)
+
+	defer pc.Close(ctx)
+
+	someData := bytes.Repeat([]byte{1}, 300)
+
+	pc.Put(ctx, "good", someData)
+	pc.Put(ctx, "rejected", someData)
+
+	// simulate corruption by writing data without a valid checksum.
+	require.NoError(t, cs.PutBlob(ctx, "corrupt", gather.FromSlice(someData)))
+
+	st, err := pc.VerifyIntegrity(ctx, func(key string, data []byte) error {
+		if key == "rejected" {
+			return errors.Errorf("rejected")
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, st.CheckedCount)
+	require.Equal(t, 2, st.EvictedCount)
+
+	verifyBlobExists(ctx, t, cs, "good")
+	verifyBlobDoesNotExist(ctx, t, cs, "rejected")
+	verifyBlobDoesNotExist(ctx, t, cs, "corrupt")
+	verifyCached(ctx, t, pc, "good", someData)
+
+	// a second pass finds nothing to evict.
+	st, err = pc.VerifyIntegrity(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, st.CheckedCount)
+	require.Zero(t, st.EvictedCount)
+}
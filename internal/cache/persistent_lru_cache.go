@@ -35,7 +35,7 @@ type PersistentCache struct {
 	cacheStorage      Storage
 	storageProtection StorageProtection
 
-	maxSizeBytes   int64
+	sizeLimit      SizeLimit
 	sweepFrequency time.Duration
 	touchThreshold time.Duration
 	description    string
@@ -196,34 +196,44 @@ func (c *PersistentCache) sweepDirectory(ctx context.Context) (err error) {
 		heap.Push(&h, it)
 		totalRetainedSize += it.Length
 
-		if totalRetainedSize > c.maxSizeBytes {
-			oldest := heap.Pop(&h).(blob.Metadata) //nolint:forcetypeassert
-			if delerr := c.cacheStorage.DeleteBlob(ctx, oldest.BlobID); delerr != nil {
-				log(ctx).Errorf("unable to remove %v: %v", oldest.BlobID, delerr)
-			} else {
-				totalRetainedSize -= oldest.Length
-			}
-		}
 		return nil
 	})
 	if err != nil {
 		return errors.Wrapf(err, "error listing %v", c.description)
 	}
 
-	log(ctx).Debugf("finished sweeping %v in %v and retained %v/%v bytes (%v %%)", c.description, clock.Since(t0), totalRetainedSize, c.maxSizeBytes, 100*totalRetainedSize/c.maxSizeBytes)
+	// the limit may depend on free disk space, in which case it is re-evaluated on each sweep
+	// and the cache gradually shrinks by evicting least recently used items.
+	maxSizeBytes := c.sizeLimit.effectiveMaxSize(ctx, totalRetainedSize)
+
+	for totalRetainedSize > maxSizeBytes && h.Len() > 0 {
+		oldest := heap.Pop(&h).(blob.Metadata) //nolint:forcetypeassert
+		if delerr := c.cacheStorage.DeleteBlob(ctx, oldest.BlobID); delerr != nil {
+			log(ctx).Errorf("unable to remove %v: %v", oldest.BlobID, delerr)
+		} else {
+			totalRetainedSize -= oldest.Length
+		}
+	}
+
+	var pct int64
+	if maxSizeBytes > 0 {
+		pct = 100 * totalRetainedSize / maxSizeBytes
+	}
+
+	log(ctx).Debugf("finished sweeping %v in %v and retained %v/%v bytes (%v %%)", c.description, clock.Since(t0), totalRetainedSize, maxSizeBytes, pct)
 
 	return nil
 }
 
 // NewPersistentCache creates the persistent cache in the provided storage.
-func NewPersistentCache(ctx context.Context, description string, cacheStorage Storage, storageProtection StorageProtection, maxSizeBytes int64, touchThreshold, sweepFrequency time.Duration) (*PersistentCache, error) {
+func NewPersistentCache(ctx context.Context, description string, cacheStorage Storage, storageProtection StorageProtection, sizeLimit SizeLimit, touchThreshold, sweepFrequency time.Duration) (*PersistentCache, error) {
 	if storageProtection == nil {
 		storageProtection = nullStorageProtection{}
 	}
 
 	c := &PersistentCache{
 		cacheStorage:        cacheStorage,
-		maxSizeBytes:        maxSizeBytes,
+		sizeLimit:           sizeLimit,
 		periodicSweepClosed: make(chan struct{}),
 		touchThreshold:      touchThreshold,
 		sweepFrequency:      sweepFrequency,
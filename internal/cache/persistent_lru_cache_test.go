@@ -25,7 +25,7 @@ func TestPersistentLRUCache(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	pc, err := cache.NewPersistentCache(ctx, "testing", cs, cache.ChecksumProtection([]byte{1, 2, 3}), maxSizeBytes, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
+	pc, err := cache.NewPersistentCache(ctx, "testing", cs, cache.ChecksumProtection([]byte{1, 2, 3}), cache.SizeLimit{MaxSizeBytes: maxSizeBytes}, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -63,7 +63,7 @@ func TestPersistentLRUCache(t *testing.T) {
 	verifyBlobExists(ctx, t, cs, "key3")
 	verifyBlobExists(ctx, t, cs, "key4")
 
-	pc, err = cache.NewPersistentCache(ctx, "testing", cs, cache.ChecksumProtection([]byte{1, 2, 3}), maxSizeBytes, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
+	pc, err = cache.NewPersistentCache(ctx, "testing", cs, cache.ChecksumProtection([]byte{1, 2, 3}), cache.SizeLimit{MaxSizeBytes: maxSizeBytes}, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
 	if err != nil {
 		t.Fatal(err)
 	}
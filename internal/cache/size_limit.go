@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/internal/diskspace"
+)
+
+// freeSpaceBytesFunc returns free space on the volume containing the provided path, overridden in tests.
+var freeSpaceBytesFunc = diskspace.AvailableBytes
+
+// SizeLimit specifies the maximum size of the cache.
+type SizeLimit struct {
+	// MaxSizeBytes is the maximum total size of cached items.
+	MaxSizeBytes int64
+
+	// FreeSpacePercent, when non-zero, further limits the size of the cache to the provided percentage
+	// of space available to the cache on the volume containing FreeSpaceDirectory.
+	// Space available to the cache is the free space on the volume plus space already used by the cache.
+	FreeSpacePercent   int
+	FreeSpaceDirectory string
+}
+
+// effectiveMaxSize returns the maximum size of the cache given the number of bytes currently used by it.
+func (l SizeLimit) effectiveMaxSize(ctx context.Context, usedBytes int64) int64 {
+	if l.FreeSpacePercent <= 0 || l.FreeSpaceDirectory == "" {
+		return l.MaxSizeBytes
+	}
+
+	free, err := freeSpaceBytesFunc(l.FreeSpaceDirectory)
+	if err != nil {
+		log(ctx).Debugf("unable to determine free space in %v: %v", l.FreeSpaceDirectory, err)
+		return l.MaxSizeBytes
+	}
+
+	limit := (free + usedBytes) / 100 * int64(l.FreeSpacePercent) //nolint:gomnd
+
+	if l.MaxSizeBytes > 0 && limit > l.MaxSizeBytes {
+		return l.MaxSizeBytes
+	}
+
+	return limit
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestSizeLimitEffectiveMaxSize(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var (
+		freeSpace    int64 = 1000000
+		freeSpaceErr error
+	)
+
+	old := freeSpaceBytesFunc
+	freeSpaceBytesFunc = func(path string) (int64, error) { return freeSpace, freeSpaceErr }
+
+	defer func() { freeSpaceBytesFunc = old }()
+
+	cases := []struct {
+		limit     SizeLimit
+		usedBytes int64
+		want      int64
+	}{
+		{SizeLimit{MaxSizeBytes: 5000}, 100, 5000},
+		{SizeLimit{MaxSizeBytes: 5000, FreeSpacePercent: 10}, 100, 5000},
+		{SizeLimit{MaxSizeBytes: 5000000, FreeSpacePercent: 10, FreeSpaceDirectory: "dir"}, 0, 100000},
+		{SizeLimit{MaxSizeBytes: 5000000, FreeSpacePercent: 10, FreeSpaceDirectory: "dir"}, 200000, 120000},
+		{SizeLimit{MaxSizeBytes: 50000, FreeSpacePercent: 10, FreeSpaceDirectory: "dir"}, 200000, 50000},
+	}
+
+	for _, tc := range cases {
+		if got := tc.limit.effectiveMaxSize(ctx, tc.usedBytes); got != tc.want {
+			t.Errorf("invalid effective size of %+v with %v used: %v, want %v", tc.limit, tc.usedBytes, got, tc.want)
+		}
+	}
+
+	// when free space can't be determined, fall back to max size.
+	freeSpaceErr = errors.Errorf("some error")
+
+	if got, want := (SizeLimit{MaxSizeBytes: 5000, FreeSpacePercent: 10, FreeSpaceDirectory: "dir"}).effectiveMaxSize(ctx, 0), int64(5000); got != want {
+		t.Errorf("invalid effective size on error: %v, want %v", got, want)
+	}
+}
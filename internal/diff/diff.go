@@ -2,24 +2,69 @@
 package diff
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/iocopy"
+	"github.com/kopia/kopia/internal/parallelwork"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
 )
 
 var log = logging.GetContextLoggerFunc("diff")
 
+// ChangeType describes the kind of change between two entries.
+type ChangeType string
+
+// Supported change types.
+const (
+	ChangeAdded       ChangeType = "added"
+	ChangeRemoved     ChangeType = "removed"
+	ChangeModified    ChangeType = "modified"
+	ChangeTypeChanged ChangeType = "typeChanged"
+)
+
+// EntryMetadata describes an entry on one side of the comparison.
+type EntryMetadata struct {
+	Type     snapshot.EntryType `json:"type"`
+	Mode     string             `json:"mode"`
+	Size     int64              `json:"size"`
+	ModTime  time.Time          `json:"mtime"`
+	UserID   uint32             `json:"uid"`
+	GroupID  uint32             `json:"gid"`
+	ObjectID object.ID          `json:"obj,omitempty"`
+}
+
+// Change describes a single difference between two filesystems.
+type Change struct {
+	Type ChangeType     `json:"type"`
+	Path string         `json:"path"`
+	Old  *EntryMetadata `json:"old,omitempty"`
+	New  *EntryMetadata `json:"new,omitempty"`
+
+	// Differences lists metadata fields that differ between old and new entries of a modified entry.
+	Differences []string `json:"differences,omitempty"`
+
+	oldEntry fs.Entry
+	newEntry fs.Entry
+}
+
 // Comparer outputs diff information between two filesystems.
 type Comparer struct {
 	out    io.Writer
@@ -27,11 +72,63 @@ type Comparer struct {
 
 	DiffCommand   string
 	DiffArguments []string
+
+	// Parallelism is the maximum number of directories compared concurrently.
+	Parallelism int
+}
+
+// comparison holds the state of a single invocation of Changes.
+type comparison struct {
+	queue *parallelwork.Queue
+
+	mu      sync.Mutex
+	changes []*Change
 }
 
 // Compare compares two filesystem entries and emits their diff information.
 func (c *Comparer) Compare(ctx context.Context, e1, e2 fs.Entry) error {
-	return c.compareEntry(ctx, e1, e2, ".")
+	changes, err := c.Changes(ctx, e1, e2)
+	if err != nil {
+		return err
+	}
+
+	for _, ch := range changes {
+		c.printChange(ch)
+
+		if err := c.compareFiles(ctx, ch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Changes compares two filesystem entries, processing directories in parallel, and returns
+// the list of changes sorted by path.
+func (c *Comparer) Changes(ctx context.Context, e1, e2 fs.Entry) ([]*Change, error) {
+	cmp := &comparison{queue: parallelwork.NewQueue()}
+
+	cmp.queue.EnqueueBack(ctx, func() error {
+		return cmp.compareEntry(ctx, e1, e2, ".")
+	})
+
+	if err := cmp.queue.Process(ctx, c.parallelism()); err != nil {
+		return nil, errors.Wrap(err, "error comparing entries")
+	}
+
+	sort.Slice(cmp.changes, func(i, j int) bool {
+		return pathLess(cmp.changes[i].Path, cmp.changes[j].Path)
+	})
+
+	return cmp.changes, nil
+}
+
+func (c *Comparer) parallelism() int {
+	if c.Parallelism > 0 {
+		return c.Parallelism
+	}
+
+	return runtime.NumCPU()
 }
 
 // Close removes all temporary files used by the comparer.
@@ -40,7 +137,20 @@ func (c *Comparer) Close() error {
 	return os.RemoveAll(c.tmpDir)
 }
 
-func (c *Comparer) compareDirectories(ctx context.Context, dir1, dir2 fs.Directory, parent string) error {
+func (cmp *comparison) addChange(ch *Change) {
+	cmp.mu.Lock()
+	defer cmp.mu.Unlock()
+
+	cmp.changes = append(cmp.changes, ch)
+}
+
+func (cmp *comparison) enqueueDirectories(ctx context.Context, dir1, dir2 fs.Directory, parent string) {
+	cmp.queue.EnqueueBack(ctx, func() error {
+		return cmp.compareDirectories(ctx, dir1, dir2, parent)
+	})
+}
+
+func (cmp *comparison) compareDirectories(ctx context.Context, dir1, dir2 fs.Directory, parent string) error {
 	log(ctx).Debugf("comparing directories %v", parent)
 
 	var entries1, entries2 fs.Entries
@@ -61,11 +171,10 @@ func (c *Comparer) compareDirectories(ctx context.Context, dir1, dir2 fs.Directo
 		}
 	}
 
-	return c.compareDirectoryEntries(ctx, entries1, entries2, parent)
+	return cmp.compareDirectoryEntries(ctx, entries1, entries2, parent)
 }
 
-// nolint:gocyclo
-func (c *Comparer) compareEntry(ctx context.Context, e1, e2 fs.Entry, path string) error {
+func (cmp *comparison) compareEntry(ctx context.Context, e1, e2 fs.Entry, path string) error {
 	// see if we have the same object IDs, which implies identical objects, thanks to content-addressable-storage
 	if h1, ok := e1.(object.HasObjectID); ok {
 		if h2, ok := e2.(object.HasObjectID); ok {
@@ -76,128 +185,177 @@ func (c *Comparer) compareEntry(ctx context.Context, e1, e2 fs.Entry, path strin
 		}
 	}
 
-	if e1 == nil {
-		if dir2, isDir2 := e2.(fs.Directory); isDir2 {
-			c.output("added directory %v\n", path)
-			return c.compareDirectories(ctx, nil, dir2, path)
+	ch := &Change{
+		Path:     path,
+		Old:      entryMetadata(e1),
+		New:      entryMetadata(e2),
+		oldEntry: e1,
+		newEntry: e2,
+	}
+
+	dir1, isDir1 := e1.(fs.Directory)
+	dir2, isDir2 := e2.(fs.Directory)
+
+	switch {
+	case e1 == nil && e2 == nil:
+		return nil
+
+	case e1 == nil:
+		ch.Type = ChangeAdded
+		cmp.addChange(ch)
+
+		if isDir2 {
+			cmp.enqueueDirectories(ctx, nil, dir2, path)
 		}
 
-		c.output("added file %v (%v bytes)\n", path, e2.Size())
+	case e2 == nil:
+		ch.Type = ChangeRemoved
+		cmp.addChange(ch)
 
-		if f, ok := e2.(fs.File); ok {
-			if err := c.compareFiles(ctx, nil, f, path); err != nil {
-				return err
-			}
+		if isDir1 {
+			cmp.enqueueDirectories(ctx, dir1, nil, path)
 		}
 
-		return nil
-	}
+	case isDir1 != isDir2:
+		ch.Type = ChangeTypeChanged
+		cmp.addChange(ch)
 
-	if e2 == nil {
-		if dir1, isDir1 := e1.(fs.Directory); isDir1 {
-			c.output("removed directory %v\n", path)
-			return c.compareDirectories(ctx, dir1, nil, path)
+	case isDir1:
+		if ch.Differences = metadataDifferences(e1, e2); len(ch.Differences) > 0 {
+			ch.Type = ChangeModified
+			cmp.addChange(ch)
 		}
 
-		c.output("removed file %v (%v bytes)\n", path, e1.Size())
+		cmp.enqueueDirectories(ctx, dir1, dir2, path)
 
-		if f, ok := e1.(fs.File); ok {
-			if err := c.compareFiles(ctx, f, nil, path); err != nil {
-				return err
-			}
+	default:
+		ch.Differences = metadataDifferences(e1, e2)
+
+		modified, err := contentsDiffer(ctx, ch)
+		if err != nil {
+			return errors.Wrapf(err, "error comparing contents of %v", path)
 		}
 
-		return nil
+		if modified || len(ch.Differences) > 0 {
+			ch.Type = ChangeModified
+			cmp.addChange(ch)
+		}
+	}
+
+	return nil
+}
+
+// contentsDiffer determines whether the contents of the two entries of a change differ, by comparing
+// object IDs when available or by hashing contents of files otherwise.
+func contentsDiffer(ctx context.Context, ch *Change) (bool, error) {
+	if ch.Old.ObjectID != "" || ch.New.ObjectID != "" {
+		return ch.Old.ObjectID != ch.New.ObjectID, nil
 	}
 
-	compareEntry(e1, e2, path, c.out)
+	f1, ok1 := ch.oldEntry.(fs.File)
+	f2, ok2 := ch.newEntry.(fs.File)
 
-	dir1, isDir1 := e1.(fs.Directory)
-	dir2, isDir2 := e2.(fs.Directory)
+	if !ok1 || !ok2 {
+		return false, nil
+	}
 
-	if isDir1 {
-		if !isDir2 {
-			// right is a non-directory, left is a directory
-			c.output("changed %v from directory to non-directory\n", path)
-			return nil
-		}
+	if f1.Size() != f2.Size() {
+		return true, nil
+	}
 
-		return c.compareDirectories(ctx, dir1, dir2, path)
+	h1, err := hashFile(ctx, f1)
+	if err != nil {
+		return false, err
 	}
 
-	if isDir2 {
-		// left is non-directory, right is a directory
-		log(ctx).Infof("changed %v from non-directory to a directory", path)
-		return nil
+	h2, err := hashFile(ctx, f2)
+	if err != nil {
+		return false, err
 	}
 
-	if f1, ok := e1.(fs.File); ok {
-		if f2, ok := e2.(fs.File); ok {
-			c.output("changed %v at %v (size %v -> %v)\n", path, e2.ModTime().String(), e1.Size(), e2.Size())
+	return !bytes.Equal(h1, h2), nil
+}
 
-			if err := c.compareFiles(ctx, f1, f2, path); err != nil {
-				return err
-			}
-		}
+func hashFile(ctx context.Context, f fs.File) ([]byte, error) {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening file")
 	}
+	defer r.Close() //nolint:errcheck
 
-	return nil
+	h := sha256.New()
+
+	if _, err := iocopy.Copy(h, r); err != nil {
+		return nil, errors.Wrap(err, "error reading file")
+	}
+
+	return h.Sum(nil), nil
 }
 
-func compareEntry(e1, e2 fs.Entry, fullpath string, out io.Writer) bool {
-	if e1 == e2 { // in particular e1 == nil && e2 == nil
-		return true
+func entryMetadata(e fs.Entry) *EntryMetadata {
+	if e == nil {
+		return nil
 	}
 
-	if e1 == nil {
-		fmt.Fprintln(out, fullpath, "does not exist in source directory")
-		return false
+	md := &EntryMetadata{
+		Type:    entryType(e),
+		Mode:    e.Mode().String(),
+		Size:    e.Size(),
+		ModTime: e.ModTime(),
+		UserID:  e.Owner().UserID,
+		GroupID: e.Owner().GroupID,
 	}
 
-	if e2 == nil {
-		fmt.Fprintln(out, fullpath, "does not exist in destination directory")
-		return false
+	if h, ok := e.(object.HasObjectID); ok {
+		md.ObjectID = h.ObjectID()
 	}
 
-	equal := true
+	return md
+}
 
-	if m1, m2 := e1.Mode(), e2.Mode(); m1 != m2 {
-		equal = false
+func entryType(e fs.Entry) snapshot.EntryType {
+	switch e.(type) {
+	case fs.Directory:
+		return snapshot.EntryTypeDirectory
+	case fs.Symlink:
+		return snapshot.EntryTypeSymlink
+	case fs.File:
+		return snapshot.EntryTypeFile
+	default:
+		return snapshot.EntryTypeUnknown
+	}
+}
+
+func metadataDifferences(e1, e2 fs.Entry) []string {
+	var result []string
 
-		fmt.Fprintln(out, fullpath, "modes differ: ", m1, m2)
+	if m1, m2 := e1.Mode(), e2.Mode(); m1 != m2 {
+		result = append(result, fmt.Sprintf("modes differ: %v %v", m1, m2))
 	}
 
 	if s1, s2 := e1.Size(), e2.Size(); s1 != s2 {
-		equal = false
-
-		fmt.Fprintln(out, fullpath, "sizes differ: ", s1, s2)
+		result = append(result, fmt.Sprintf("sizes differ: %v %v", s1, s2))
 	}
 
 	if mt1, mt2 := e1.ModTime(), e2.ModTime(); !mt1.Equal(mt2) {
-		equal = false
-
-		fmt.Fprintln(out, fullpath, "modification times differ: ", mt1, mt2)
+		result = append(result, fmt.Sprintf("modification times differ: %v %v", mt1, mt2))
 	}
 
 	o1, o2 := e1.Owner(), e2.Owner()
 	if o1.UserID != o2.UserID {
-		equal = false
-
-		fmt.Fprintln(out, fullpath, "owner users differ: ", o1.UserID, o2.UserID)
+		result = append(result, fmt.Sprintf("owner users differ: %v %v", o1.UserID, o2.UserID))
 	}
 
 	if o1.GroupID != o2.GroupID {
-		equal = false
-
-		fmt.Fprintln(out, fullpath, "owner groups differ: ", o1.GroupID, o2.GroupID)
+		result = append(result, fmt.Sprintf("owner groups differ: %v %v", o1.GroupID, o2.GroupID))
 	}
 
 	// don't compare filesystem boundaries (e1.Device()), it's pretty useless and is not stored in backups
 
-	return equal
+	return result
 }
 
-func (c *Comparer) compareDirectoryEntries(ctx context.Context, entries1, entries2 fs.Entries, dirPath string) error {
+func (cmp *comparison) compareDirectoryEntries(ctx context.Context, entries1, entries2 fs.Entries, dirPath string) error {
 	e1byname := map[string]fs.Entry{}
 	for _, e1 := range entries1 {
 		e1byname[e1.Name()] = e1
@@ -205,7 +363,7 @@ func (c *Comparer) compareDirectoryEntries(ctx context.Context, entries1, entrie
 
 	for _, e2 := range entries2 {
 		entryName := e2.Name()
-		if err := c.compareEntry(ctx, e1byname[entryName], e2, dirPath+"/"+entryName); err != nil {
+		if err := cmp.compareEntry(ctx, e1byname[entryName], e2, dirPath+"/"+entryName); err != nil {
 			return errors.Wrapf(err, "error comparing %v", entryName)
 		}
 
@@ -216,7 +374,7 @@ func (c *Comparer) compareDirectoryEntries(ctx context.Context, entries1, entrie
 	for _, e1 := range entries1 {
 		entryName := e1.Name()
 		if _, ok := e1byname[entryName]; ok {
-			if err := c.compareEntry(ctx, e1, nil, dirPath+"/"+entryName); err != nil {
+			if err := cmp.compareEntry(ctx, e1, nil, dirPath+"/"+entryName); err != nil {
 				return errors.Wrapf(err, "error comparing %v", entryName)
 			}
 		}
@@ -225,11 +383,68 @@ func (c *Comparer) compareDirectoryEntries(ctx context.Context, entries1, entrie
 	return nil
 }
 
-func (c *Comparer) compareFiles(ctx context.Context, f1, f2 fs.File, fname string) error {
+// pathLess orders paths component-by-component, so that entries are listed right after their parent directory.
+func pathLess(p1, p2 string) bool {
+	c1 := strings.Split(p1, "/")
+	c2 := strings.Split(p2, "/")
+
+	for i := 0; i < len(c1) && i < len(c2); i++ {
+		if c1[i] != c2[i] {
+			return c1[i] < c2[i]
+		}
+	}
+
+	return len(c1) < len(c2)
+}
+
+func (c *Comparer) printChange(ch *Change) {
+	switch ch.Type {
+	case ChangeAdded:
+		if ch.New.Type == snapshot.EntryTypeDirectory {
+			c.output("added directory %v\n", ch.Path)
+		} else {
+			c.output("added file %v (%v bytes)\n", ch.Path, ch.New.Size)
+		}
+
+	case ChangeRemoved:
+		if ch.Old.Type == snapshot.EntryTypeDirectory {
+			c.output("removed directory %v\n", ch.Path)
+		} else {
+			c.output("removed file %v (%v bytes)\n", ch.Path, ch.Old.Size)
+		}
+
+	case ChangeTypeChanged:
+		if ch.Old.Type == snapshot.EntryTypeDirectory {
+			c.output("changed %v from directory to non-directory\n", ch.Path)
+		} else {
+			c.output("changed %v from non-directory to a directory\n", ch.Path)
+		}
+
+	case ChangeModified:
+		for _, d := range ch.Differences {
+			c.output("%v %v\n", ch.Path, d)
+		}
+
+		if ch.New.Type != snapshot.EntryTypeDirectory {
+			c.output("changed %v at %v (size %v -> %v)\n", ch.Path, ch.New.ModTime.String(), ch.Old.Size, ch.New.Size)
+		}
+	}
+}
+
+func (c *Comparer) compareFiles(ctx context.Context, ch *Change) error {
 	if c.DiffCommand == "" {
 		return nil
 	}
 
+	f1, _ := ch.oldEntry.(fs.File)
+	f2, _ := ch.newEntry.(fs.File)
+
+	if f1 == nil && f2 == nil {
+		return nil
+	}
+
+	fname := ch.Path
+
 	oldName := "/dev/null"
 	newName := "/dev/null"
 
@@ -0,0 +1,83 @@
+package diff_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/diff"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestChanges(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	dir1 := mockfs.NewDirectory()
+	dir1.AddFile("same", []byte("same"), 0o644)
+	dir1.AddFile("modified", []byte("aaaa"), 0o644)
+	dir1.AddFile("chmod", []byte("x"), 0o644)
+	dir1.AddFile("removed", []byte("removed"), 0o644)
+	dir1.AddDir("sub", 0o755)
+	dir1.AddFile("sub/file", []byte("old"), 0o644)
+	dir1.AddDir("became-file", 0o755)
+
+	dir2 := mockfs.NewDirectory()
+	dir2.AddFile("same", []byte("same"), 0o644)
+	dir2.AddFile("modified", []byte("bbbb"), 0o644)
+	dir2.AddFile("chmod", []byte("x"), 0o600)
+	dir2.AddFile("added", []byte("added"), 0o644)
+	dir2.AddDir("sub", 0o755)
+	dir2.AddFile("sub/file", []byte("new"), 0o644)
+	dir2.AddDir("sub/newdir", 0o755)
+	dir2.AddFile("sub/newdir/f", []byte("f"), 0o644)
+	dir2.AddFile("became-file", []byte("f"), 0o644)
+
+	var out bytes.Buffer
+
+	c, err := diff.NewComparer(&out)
+	require.NoError(t, err)
+
+	defer c.Close() //nolint:errcheck
+
+	c.Parallelism = 4
+
+	changes, err := c.Changes(ctx, dir1, dir2)
+	require.NoError(t, err)
+
+	var got []string
+
+	for _, ch := range changes {
+		got = append(got, string(ch.Type)+" "+ch.Path)
+	}
+
+	require.Equal(t, []string{
+		"added ./added",
+		"typeChanged ./became-file",
+		"modified ./chmod",
+		"modified ./modified",
+		"removed ./removed",
+		"modified ./sub/file",
+		"added ./sub/newdir",
+		"added ./sub/newdir/f",
+	}, got)
+
+	require.Equal(t, snapshot.EntryTypeDirectory, changes[1].Old.Type)
+	require.Equal(t, snapshot.EntryTypeFile, changes[1].New.Type)
+	require.Nil(t, changes[0].Old)
+	require.Nil(t, changes[4].New)
+	require.Len(t, changes[2].Differences, 1)
+	require.Empty(t, changes[3].Differences)
+
+	require.NoError(t, c.Compare(ctx, dir1, dir2))
+	require.True(t, strings.Contains(out.String(), "added file ./added (5 bytes)"), out.String())
+	require.True(t, strings.Contains(out.String(), "added directory ./sub/newdir"), out.String())
+	require.True(t, strings.Contains(out.String(), "changed ./became-file from directory to non-directory"), out.String())
+
+	changes, err = c.Changes(ctx, dir1, dir1)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
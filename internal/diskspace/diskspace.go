@@ -0,0 +1,2 @@
+// Package diskspace determines space available on local volumes.
+package diskspace
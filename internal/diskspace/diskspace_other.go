@@ -0,0 +1,12 @@
+// +build !linux,!darwin,!freebsd,!windows
+
+package diskspace
+
+import (
+	"github.com/pkg/errors"
+)
+
+// AvailableBytes is not supported on this platform.
+func AvailableBytes(path string) (int64, error) {
+	return 0, errors.Errorf("unable to determine free space of %v on this platform", path)
+}
@@ -0,0 +1,19 @@
+package diskspace_test
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/internal/diskspace"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestAvailableBytes(t *testing.T) {
+	v, err := diskspace.AvailableBytes(testutil.TempDirectory(t))
+	if err != nil {
+		t.Skipf("free space not supported: %v", err)
+	}
+
+	if v <= 0 {
+		t.Errorf("unexpected free space: %v", v)
+	}
+}
@@ -0,0 +1,19 @@
+// +build linux darwin freebsd
+
+package diskspace
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// AvailableBytes returns the number of bytes available to the current user on the volume containing the provided path.
+func AvailableBytes(path string) (int64, error) {
+	var stat unix.Statfs_t
+
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, errors.Wrapf(err, "unable to stat file system of %v", path)
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), nil //nolint:unconvert
+}
@@ -0,0 +1,22 @@
+package diskspace
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// AvailableBytes returns the number of bytes available to the current user on the volume containing the provided path.
+func AvailableBytes(path string) (int64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid path %v", path)
+	}
+
+	var freeBytesAvailable uint64
+
+	if err := windows.GetDiskFreeSpaceEx(p, &freeBytesAvailable, nil, nil); err != nil {
+		return 0, errors.Wrapf(err, "unable to get free space of %v", path)
+	}
+
+	return int64(freeBytesAvailable), nil
+}
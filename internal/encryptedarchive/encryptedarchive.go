@@ -0,0 +1,219 @@
+// Package encryptedarchive implements the format of portable archive files consisting of a sequence
+// of records encrypted using a key derived from a password.
+package encryptedarchive
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Layout of the archive:
+//
+//	magic   - 8 bytes, identifies the type of the archive
+//	version - 1 byte
+//	salt    - 32 bytes, used to derive encryption key from the password
+//
+// followed by a sequence of records, each of which is:
+//
+//	length  - 4 bytes, big-endian length of the encrypted record that follows
+//	nonce   - 12 bytes
+//	data    - AES256-GCM-encrypted record type (1 byte) followed by payload
+//
+// Each record is authenticated together with archive header and its sequence number,
+// so records can't be reordered or moved between archives. Archives should end with
+// a record marking the end, which makes truncated archives detectable.
+const (
+	// MagicLength is the required length of the magic string identifying the type of the archive.
+	MagicLength = 8
+
+	// MaxRecordSize is the maximum size of a single encrypted record.
+	MaxRecordSize = 64 << 20
+
+	archiveSaltSize   = 32
+	archiveHeaderSize = MagicLength + 1 + archiveSaltSize
+	archiveKeySize    = 32
+
+	recordLengthSize = 4
+)
+
+// ErrInvalidPassword is returned when archive password is invalid.
+var ErrInvalidPassword = errors.New("invalid archive password")
+
+func deriveArchiveKey(password string, salt []byte) ([]byte, error) {
+	// nolint:gomnd
+	key, err := scrypt.Key([]byte(password), salt, 65536, 8, 1, archiveKeySize)
+
+	return key, errors.Wrap(err, "unable to derive archive key")
+}
+
+func newArchiveCipher(password string, header []byte) (cipher.AEAD, error) {
+	key, err := deriveArchiveKey(password, header[len(header)-archiveSaltSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AES-256 cipher")
+	}
+
+	// nolint:wrapcheck
+	return cipher.NewGCM(c)
+}
+
+func recordAdditionalData(header []byte, seq uint64) []byte {
+	var seqBuf [8]byte
+
+	binary.BigEndian.PutUint64(seqBuf[:], seq)
+
+	return append(append([]byte(nil), header...), seqBuf[:]...)
+}
+
+// Writer writes encrypted records to the archive.
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	seq    uint64
+}
+
+// NewWriter writes the archive header to the provided writer and returns a Writer for the records that follow.
+func NewWriter(w io.Writer, magic string, version byte, password string) (*Writer, error) {
+	if len(magic) != MagicLength {
+		return nil, errors.Errorf("invalid archive magic %q", magic)
+	}
+
+	header := make([]byte, archiveHeaderSize)
+	copy(header, magic)
+	header[MagicLength] = version
+
+	if _, err := rand.Read(header[MagicLength+1:]); err != nil {
+		return nil, errors.Wrap(err, "unable to generate salt")
+	}
+
+	aead, err := newArchiveCipher(password, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, errors.Wrap(err, "error writing archive header")
+	}
+
+	return &Writer{w: w, aead: aead, header: header}, nil
+}
+
+// WriteRecord encrypts and writes a single record consisting of the record type and concatenated payload.
+func (w *Writer) WriteRecord(recordType byte, payload ...[]byte) error {
+	plaintext := []byte{recordType}
+	for _, p := range payload {
+		plaintext = append(plaintext, p...)
+	}
+
+	nonce := make([]byte, w.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "unable to initialize nonce")
+	}
+
+	rec := make([]byte, recordLengthSize, recordLengthSize+len(nonce)+len(plaintext)+w.aead.Overhead())
+	rec = append(rec, nonce...)
+	rec = w.aead.Seal(rec, nonce, plaintext, recordAdditionalData(w.header, w.seq))
+
+	if len(rec)-recordLengthSize > MaxRecordSize {
+		return errors.Errorf("record too big: %v", len(rec))
+	}
+
+	binary.BigEndian.PutUint32(rec, uint32(len(rec)-recordLengthSize))
+
+	w.seq++
+
+	_, err := w.w.Write(rec)
+
+	return errors.Wrap(err, "error writing archive record")
+}
+
+// Location identifies a single record in the archive.
+type Location struct {
+	offset int64
+	seq    uint64
+}
+
+// Reader reads encrypted records from the archive.
+type Reader struct {
+	r      io.ReaderAt
+	aead   cipher.AEAD
+	header []byte
+}
+
+// NewReader reads and validates the archive header and returns a Reader for the records that follow.
+func NewReader(r io.ReaderAt, magic string, version byte, password string) (*Reader, error) {
+	header := make([]byte, archiveHeaderSize)
+
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, errors.Wrap(err, "error reading archive header")
+	}
+
+	if string(header[0:MagicLength]) != magic {
+		return nil, errors.New("unrecognized archive type")
+	}
+
+	if v := header[MagicLength]; v != version {
+		return nil, errors.Errorf("unsupported archive version %v", v)
+	}
+
+	aead, err := newArchiveCipher(password, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{r: r, aead: aead, header: header}, nil
+}
+
+// FirstRecord returns the location of the first record in the archive.
+func (r *Reader) FirstRecord() Location {
+	return Location{offset: archiveHeaderSize}
+}
+
+// ReadRecord reads and decrypts the record at a given location and returns its type, payload and the location of the next record.
+// io.EOF is returned when reading past the end of the archive.
+func (r *Reader) ReadRecord(loc Location) (recordType byte, payload []byte, next Location, err error) {
+	var lenBuf [recordLengthSize]byte
+
+	if _, err := r.r.ReadAt(lenBuf[:], loc.offset); err != nil {
+		return 0, nil, Location{}, errors.Wrap(err, "error reading record length")
+	}
+
+	length := int64(binary.BigEndian.Uint32(lenBuf[:]))
+	if length < int64(r.aead.NonceSize()+r.aead.Overhead()) || length > MaxRecordSize {
+		return 0, nil, Location{}, errors.Errorf("invalid record length %v", length)
+	}
+
+	rec := make([]byte, length)
+
+	if _, err := r.r.ReadAt(rec, loc.offset+recordLengthSize); err != nil {
+		return 0, nil, Location{}, errors.Wrap(err, "error reading record")
+	}
+
+	nonce := rec[0:r.aead.NonceSize()]
+
+	plaintext, err := r.aead.Open(nil, nonce, rec[len(nonce):], recordAdditionalData(r.header, loc.seq))
+	if err != nil {
+		if loc.seq == 0 {
+			return 0, nil, Location{}, ErrInvalidPassword
+		}
+
+		return 0, nil, Location{}, errors.Wrap(err, "unable to decrypt record")
+	}
+
+	if len(plaintext) == 0 {
+		return 0, nil, Location{}, errors.New("empty record")
+	}
+
+	return plaintext[0], plaintext[1:], Location{offset: loc.offset + recordLengthSize + length, seq: loc.seq + 1}, nil
+}
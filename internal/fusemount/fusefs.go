@@ -8,6 +8,7 @@
 import (
 	"io"
 	"os"
+	"path/filepath"
 	"sync"
 	"syscall"
 
@@ -24,9 +25,23 @@
 
 const fakeBlockSize = 4096
 
+// Options controls the behavior of FUSE nodes.
+type Options struct {
+	// MaxNegativeLookups is the maximum number of names not found in each directory that are remembered,
+	// so that repeated lookups of missing files don't need to read the directory again. 0 disables the cache.
+	MaxNegativeLookups int
+
+	// ScratchDir enables a copy-on-write overlay stored in the provided local directory. Files opened for
+	// writing are copied to the scratch directory first and new files and directories are created there,
+	// so the repository is never modified. Deleting and renaming entries is not supported.
+	ScratchDir string
+}
+
 type fuseNode struct {
 	gofusefs.Inode
 	entry fs.Entry
+	path  string // relative to the root of the mount
+	opts  *Options
 }
 
 func populateAttributes(a *fuse.Attr, e fs.Entry) {
@@ -41,8 +56,21 @@ func populateAttributes(a *fuse.Attr, e fs.Entry) {
 	a.Blocks = (a.Size + fakeBlockSize - 1) / fakeBlockSize
 }
 
+func (n *fuseNode) populateAttributes(a *fuse.Attr) {
+	if st, ok := n.scratchStat(); ok {
+		a.FromStat(st)
+		return
+	}
+
+	populateAttributes(a, n.entry)
+}
+
 func (n *fuseNode) Getattr(ctx context.Context, fh gofusefs.FileHandle, a *fuse.AttrOut) syscall.Errno {
-	populateAttributes(&a.Attr, n.entry)
+	if fga, ok := fh.(gofusefs.FileGetattrer); ok {
+		return fga.Getattr(ctx, a)
+	}
+
+	n.populateAttributes(&a.Attr)
 
 	a.Ino = n.StableAttr().Ino
 
@@ -54,6 +82,13 @@ type fuseFileNode struct {
 }
 
 func (f *fuseFileNode) Open(ctx context.Context, flags uint32) (gofusefs.FileHandle, uint32, syscall.Errno) {
+	if f.hasScratch() {
+		if _, ok := f.scratchStat(); ok || isWriteAccess(flags) {
+			fh, errno := f.openScratch(ctx, flags)
+			return fh, 0, errno
+		}
+	}
+
 	reader, err := f.entry.(fs.File).Open(ctx)
 	if err != nil {
 		log(ctx).Errorf("error opening %v: %v", f.entry.Name(), err)
@@ -99,6 +134,42 @@ func (f *fuseFileHandle) Release(ctx context.Context) syscall.Errno {
 
 type fuseDirectoryNode struct {
 	fuseNode
+
+	// names that were not found in the directory, since snapshot directories never change.
+	negativeLookupsMutex sync.Mutex
+	negativeLookups      map[string]struct{}
+}
+
+func (dir *fuseDirectoryNode) isKnownMissing(fileName string) bool {
+	dir.negativeLookupsMutex.Lock()
+	defer dir.negativeLookupsMutex.Unlock()
+
+	_, ok := dir.negativeLookups[fileName]
+
+	return ok
+}
+
+func (dir *fuseDirectoryNode) addKnownMissing(fileName string) {
+	if dir.opts.MaxNegativeLookups <= 0 {
+		return
+	}
+
+	dir.negativeLookupsMutex.Lock()
+	defer dir.negativeLookupsMutex.Unlock()
+
+	if dir.negativeLookups == nil || len(dir.negativeLookups) >= dir.opts.MaxNegativeLookups {
+		// start over when full, this is cheaper than tracking the least recently used names.
+		dir.negativeLookups = map[string]struct{}{}
+	}
+
+	dir.negativeLookups[fileName] = struct{}{}
+}
+
+func (dir *fuseDirectoryNode) removeKnownMissing(fileName string) {
+	dir.negativeLookupsMutex.Lock()
+	defer dir.negativeLookupsMutex.Unlock()
+
+	delete(dir.negativeLookups, fileName)
 }
 
 func (dir *fuseDirectoryNode) directory() fs.Directory {
@@ -106,6 +177,10 @@ func (dir *fuseDirectoryNode) directory() fs.Directory {
 }
 
 func (dir *fuseDirectoryNode) Lookup(ctx context.Context, fileName string, out *fuse.EntryOut) (*gofusefs.Inode, syscall.Errno) {
+	if dir.isKnownMissing(fileName) {
+		return nil, syscall.ENOENT
+	}
+
 	entries, err := dir.directory().Readdir(ctx)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -118,7 +193,17 @@ func (dir *fuseDirectoryNode) Lookup(ctx context.Context, fileName string, out *
 	}
 
 	e := entries.FindByName(fileName)
+	if e == nil && dir.hasScratch() {
+		if child, se, err := dir.scratchChild(ctx, fileName); err == nil {
+			populateAttributes(&out.Attr, se)
+
+			return child, gofusefs.OK
+		}
+	}
+
 	if e == nil {
+		dir.addKnownMissing(fileName)
+
 		return nil, syscall.ENOENT
 	}
 
@@ -126,14 +211,17 @@ func (dir *fuseDirectoryNode) Lookup(ctx context.Context, fileName string, out *
 		Mode: entryToFuseMode(e),
 	}
 
-	n, err := newFuseNode(e)
+	childPath := filepath.Join(dir.path, fileName)
+
+	n, err := newFuseNode(e, childPath, dir.opts)
 	if err != nil {
 		return nil, syscall.EIO
 	}
 
 	child := dir.NewInode(ctx, n, stable)
 
-	populateAttributes(&out.Attr, e)
+	// attributes of a modified file come from its scratch copy.
+	(&fuseNode{entry: e, path: childPath, opts: dir.opts}).populateAttributes(&out.Attr)
 
 	return child, gofusefs.OK
 }
@@ -153,6 +241,13 @@ func (dir *fuseDirectoryNode) Readdir(ctx context.Context) (gofusefs.DirStream,
 		})
 	}
 
+	for _, fi := range dir.scratchEntries(entries) {
+		result = append(result, fuse.DirEntry{
+			Name: fi.Name(),
+			Mode: fileInfoToFuseMode(fi),
+		})
+	}
+
 	return gofusefs.NewListDirStream(result), gofusefs.OK
 }
 
@@ -183,26 +278,37 @@ func entryToFuseMode(e fs.Entry) uint32 {
 	}
 }
 
-func newFuseNode(e fs.Entry) (gofusefs.InodeEmbedder, error) {
+func fileInfoToFuseMode(fi os.FileInfo) uint32 {
+	switch {
+	case fi.IsDir():
+		return fuse.S_IFDIR
+	case fi.Mode()&os.ModeSymlink != 0:
+		return fuse.S_IFLNK
+	default:
+		return fuse.S_IFREG
+	}
+}
+
+func newFuseNode(e fs.Entry, path string, opts *Options) (gofusefs.InodeEmbedder, error) {
 	switch e := e.(type) {
 	case fs.Directory:
-		return newDirectoryNode(e), nil
+		return newDirectoryNode(e, path, opts), nil
 	case fs.File:
-		return &fuseFileNode{fuseNode{entry: e}}, nil
+		return &fuseFileNode{fuseNode{entry: e, path: path, opts: opts}}, nil
 	case fs.Symlink:
-		return &fuseSymlinkNode{fuseNode{entry: e}}, nil
+		return &fuseSymlinkNode{fuseNode{entry: e, path: path, opts: opts}}, nil
 	default:
 		return nil, errors.Errorf("entry type not supported: %v", e.Mode())
 	}
 }
 
-func newDirectoryNode(dir fs.Directory) gofusefs.InodeEmbedder {
-	return &fuseDirectoryNode{fuseNode{entry: dir}}
+func newDirectoryNode(dir fs.Directory, path string, opts *Options) gofusefs.InodeEmbedder {
+	return &fuseDirectoryNode{fuseNode: fuseNode{entry: dir, path: path, opts: opts}}
 }
 
 // NewDirectoryNode returns FUSE Node for a given fs.Directory.
-func NewDirectoryNode(dir fs.Directory) gofusefs.InodeEmbedder {
-	return newDirectoryNode(dir)
+func NewDirectoryNode(dir fs.Directory, opts Options) gofusefs.InodeEmbedder {
+	return newDirectoryNode(dir, "", &opts)
 }
 
 var (
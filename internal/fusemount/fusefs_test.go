@@ -0,0 +1,53 @@
+// +build !windows,!openbsd
+
+package fusemount
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestNegativeLookupCache(t *testing.T) {
+	cases := []struct {
+		desc               string
+		maxNegativeLookups int
+		names              []string
+		wantReaddirs       int
+	}{
+		{"hit", 10, []string{"a", "a", "a"}, 1},
+		{"different names", 10, []string{"a", "b", "a", "b"}, 2},
+		{"eviction when full", 2, []string{"a", "b", "c", "a"}, 4},
+		{"no eviction below limit", 3, []string{"a", "b", "c", "a"}, 3},
+		{"disabled", 0, []string{"a", "a", "a"}, 3},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := testlogging.Context(t)
+
+			md := mockfs.NewDirectory()
+			md.AddFile("existing", []byte{1, 2, 3}, 0o644)
+
+			readdirs := 0
+			md.OnReaddir(func() { readdirs++ })
+
+			dir := newDirectoryNode(md, "", &Options{MaxNegativeLookups: tc.maxNegativeLookups}).(*fuseDirectoryNode)
+
+			for _, name := range tc.names {
+				_, errno := dir.Lookup(ctx, name, &fuse.EntryOut{})
+				require.Equal(t, syscall.ENOENT, errno)
+			}
+
+			require.Equal(t, tc.wantReaddirs, readdirs)
+			require.LessOrEqual(t, len(dir.negativeLookups), tc.maxNegativeLookups)
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// +build !windows,!openbsd
+
+package fusemount
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	gofusefs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+)
+
+const (
+	scratchDirPermissions = 0o700
+
+	// scratch copies must remain writable by the owner regardless of permissions in the snapshot.
+	scratchOwnerPermissions = 0o600
+)
+
+// The scratch overlay makes the mount copy-on-write: when a file is opened for writing, its contents
+// are copied to the scratch directory and all further access to that file goes to the copy.
+// New files and directories are created in the scratch directory. The repository is never modified.
+
+func isWriteAccess(flags uint32) bool {
+	return flags&(syscall.O_WRONLY|syscall.O_RDWR|syscall.O_TRUNC|syscall.O_APPEND) != 0
+}
+
+func (n *fuseNode) hasScratch() bool {
+	return n.opts.ScratchDir != ""
+}
+
+// scratchPath returns the path of the node in the scratch directory.
+func (n *fuseNode) scratchPath() string {
+	return filepath.Join(n.opts.ScratchDir, n.path)
+}
+
+// scratchStat returns the attributes of the scratch copy of the node, if it exists.
+func (n *fuseNode) scratchStat() (*syscall.Stat_t, bool) {
+	if !n.hasScratch() {
+		return nil, false
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Lstat(n.scratchPath(), &st); err != nil {
+		return nil, false
+	}
+
+	return &st, true
+}
+
+// copyUp makes a writable copy of the file in the scratch directory, unless one already exists.
+func (f *fuseFileNode) copyUp(ctx context.Context) error {
+	target := f.scratchPath()
+
+	if _, err := os.Lstat(target); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), scratchDirPermissions); err != nil {
+		return errors.Wrap(err, "unable to create scratch directory")
+	}
+
+	r, err := f.entry.(fs.File).Open(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to open file")
+	}
+	defer r.Close() //nolint:errcheck
+
+	tmp, err := ioutil.TempFile(filepath.Dir(target), ".kopia-scratch-*")
+	if err != nil {
+		return errors.Wrap(err, "unable to create scratch file")
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close() //nolint:errcheck,gosec
+		return errors.Wrap(err, "unable to copy file contents")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "unable to close scratch file")
+	}
+
+	if err := os.Chmod(tmp.Name(), f.entry.Mode().Perm()|scratchOwnerPermissions); err != nil {
+		return errors.Wrap(err, "unable to set scratch file permissions")
+	}
+
+	if err := os.Chtimes(tmp.Name(), f.entry.ModTime(), f.entry.ModTime()); err != nil {
+		return errors.Wrap(err, "unable to set scratch file time")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), target), "unable to rename scratch file")
+}
+
+// openScratch opens the scratch copy of the file, creating it first when opened for writing.
+func (f *fuseFileNode) openScratch(ctx context.Context, flags uint32) (gofusefs.FileHandle, syscall.Errno) {
+	if isWriteAccess(flags) {
+		if err := f.copyUp(ctx); err != nil {
+			log(ctx).Errorf("unable to copy %v to scratch directory: %v", f.entry.Name(), err)
+
+			return nil, syscall.EIO
+		}
+	}
+
+	fd, err := syscall.Open(f.scratchPath(), int(flags)&^(syscall.O_CREAT|syscall.O_EXCL), 0)
+	if err != nil {
+		return nil, gofusefs.ToErrno(err)
+	}
+
+	return gofusefs.NewLoopbackFile(fd), gofusefs.OK
+}
+
+func (f *fuseFileNode) Setattr(ctx context.Context, fh gofusefs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	if !f.hasScratch() {
+		return syscall.EROFS
+	}
+
+	if err := f.copyUp(ctx); err != nil {
+		log(ctx).Errorf("unable to copy %v to scratch directory: %v", f.entry.Name(), err)
+
+		return syscall.EIO
+	}
+
+	p := f.scratchPath()
+
+	if sz, ok := in.GetSize(); ok {
+		if err := os.Truncate(p, int64(sz)); err != nil {
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	if mode, ok := in.GetMode(); ok {
+		if err := os.Chmod(p, os.FileMode(mode)&os.ModePerm); err != nil {
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	atime, aok := in.GetATime()
+	mtime, mok := in.GetMTime()
+
+	if aok || mok {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return gofusefs.ToErrno(err)
+		}
+
+		if !aok {
+			atime = fi.ModTime()
+		}
+
+		if !mok {
+			mtime = fi.ModTime()
+		}
+
+		if err := os.Chtimes(p, atime, mtime); err != nil {
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	return f.Getattr(ctx, fh, out)
+}
+
+// scratchEntries returns the entries present only in the scratch directory.
+func (dir *fuseDirectoryNode) scratchEntries(entries fs.Entries) []os.FileInfo {
+	if !dir.hasScratch() {
+		return nil
+	}
+
+	infos, err := ioutil.ReadDir(dir.scratchPath())
+	if err != nil {
+		return nil
+	}
+
+	var result []os.FileInfo
+
+	for _, fi := range infos {
+		if entries.FindByName(fi.Name()) == nil {
+			result = append(result, fi)
+		}
+	}
+
+	return result
+}
+
+// scratchChild returns the FUSE node for an entry that exists only in the scratch directory.
+func (dir *fuseDirectoryNode) scratchChild(ctx context.Context, name string) (*gofusefs.Inode, fs.Entry, error) {
+	e, err := localfs.NewEntry(filepath.Join(dir.scratchPath(), name))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to read scratch entry")
+	}
+
+	n, err := newFuseNode(e, filepath.Join(dir.path, name), dir.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return dir.NewInode(ctx, n, gofusefs.StableAttr{Mode: entryToFuseMode(e)}), e, nil
+}
+
+// ensureScratchDir creates the directory in the scratch directory, so that new entries can be created in it.
+func (dir *fuseDirectoryNode) ensureScratchDir() error {
+	return errors.Wrap(os.MkdirAll(dir.scratchPath(), scratchDirPermissions), "unable to create scratch directory")
+}
+
+func (dir *fuseDirectoryNode) Create(ctx context.Context, name string, flags, mode uint32, out *fuse.EntryOut) (*gofusefs.Inode, gofusefs.FileHandle, uint32, syscall.Errno) {
+	if !dir.hasScratch() {
+		return nil, nil, 0, syscall.EROFS
+	}
+
+	if err := dir.ensureScratchDir(); err != nil {
+		log(ctx).Errorf("error creating %v: %v", name, err)
+
+		return nil, nil, 0, syscall.EIO
+	}
+
+	fd, err := syscall.Open(filepath.Join(dir.scratchPath(), name), int(flags)|syscall.O_CREAT, mode)
+	if err != nil {
+		return nil, nil, 0, gofusefs.ToErrno(err)
+	}
+
+	child, _, err := dir.scratchChild(ctx, name)
+	if err != nil {
+		syscall.Close(fd) //nolint:errcheck
+
+		log(ctx).Errorf("error creating %v: %v", name, err)
+
+		return nil, nil, 0, syscall.EIO
+	}
+
+	dir.removeKnownMissing(name)
+
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd, &st); err == nil {
+		out.Attr.FromStat(&st)
+	}
+
+	return child, gofusefs.NewLoopbackFile(fd), 0, gofusefs.OK
+}
+
+func (dir *fuseDirectoryNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*gofusefs.Inode, syscall.Errno) {
+	if !dir.hasScratch() {
+		return nil, syscall.EROFS
+	}
+
+	if entries, err := dir.directory().Readdir(ctx); err == nil && entries.FindByName(name) != nil {
+		return nil, syscall.EEXIST
+	}
+
+	if err := dir.ensureScratchDir(); err != nil {
+		log(ctx).Errorf("error creating %v: %v", name, err)
+
+		return nil, syscall.EIO
+	}
+
+	if err := os.Mkdir(filepath.Join(dir.scratchPath(), name), os.FileMode(mode)&os.ModePerm); err != nil {
+		return nil, gofusefs.ToErrno(err)
+	}
+
+	child, e, err := dir.scratchChild(ctx, name)
+	if err != nil {
+		log(ctx).Errorf("error creating %v: %v", name, err)
+
+		return nil, syscall.EIO
+	}
+
+	dir.removeKnownMissing(name)
+
+	populateAttributes(&out.Attr, e)
+
+	return child, gofusefs.OK
+}
+
+var (
+	_ gofusefs.NodeSetattrer = (*fuseFileNode)(nil)
+	_ gofusefs.NodeCreater   = (*fuseDirectoryNode)(nil)
+	_ gofusefs.NodeMkdirer   = (*fuseDirectoryNode)(nil)
+)
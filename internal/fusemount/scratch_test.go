@@ -0,0 +1,212 @@
+// +build !windows,!openbsd
+
+package fusemount
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"testing"
+
+	gofusefs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+var originalContents = []byte("original contents")
+
+// newScratchTestRoot returns the root node of a tree backed by a mock snapshot directory, which
+// is attached to a FUSE bridge, so that child nodes can be created without mounting it.
+func newScratchTestRoot(t *testing.T, scratchDir string) (*mockfs.Directory, *fuseDirectoryNode) {
+	t.Helper()
+
+	md := mockfs.NewDirectory()
+	md.AddFile("file.txt", originalContents, 0o444)
+	md.AddDir("subdir", 0o755)
+
+	root := newDirectoryNode(md, "", &Options{ScratchDir: scratchDir, MaxNegativeLookups: 10}).(*fuseDirectoryNode)
+	gofusefs.NewNodeFS(root, &gofusefs.Options{})
+
+	return md, root
+}
+
+func lookupFile(ctx context.Context, t *testing.T, dir *fuseDirectoryNode, name string) *fuseFileNode {
+	t.Helper()
+
+	inode, errno := dir.Lookup(ctx, name, &fuse.EntryOut{})
+	require.Equal(t, gofusefs.OK, errno)
+
+	f, ok := inode.Operations().(*fuseFileNode)
+	require.True(t, ok, "not a file node: %T", inode.Operations())
+
+	return f
+}
+
+func readHandle(ctx context.Context, t *testing.T, fh gofusefs.FileHandle) []byte {
+	t.Helper()
+
+	buf := make([]byte, 1000)
+
+	res, errno := fh.(gofusefs.FileReader).Read(ctx, buf, 0)
+	require.Equal(t, gofusefs.OK, errno)
+
+	b, status := res.Bytes(buf)
+	require.Equal(t, fuse.OK, status)
+
+	return b
+}
+
+func readSnapshotFile(ctx context.Context, t *testing.T, md *mockfs.Directory, name string) []byte {
+	t.Helper()
+
+	e, err := md.Child(ctx, name)
+	require.NoError(t, err)
+
+	r, err := e.(fs.File).Open(ctx)
+	require.NoError(t, err)
+
+	defer r.Close() //nolint:errcheck
+
+	b, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	return b
+}
+
+func dirStreamNames(t *testing.T, ds gofusefs.DirStream) []string {
+	t.Helper()
+
+	var names []string
+
+	for ds.HasNext() {
+		e, errno := ds.Next()
+		require.Equal(t, gofusefs.OK, errno)
+
+		names = append(names, e.Name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func TestScratchWriteCreatesCopy(t *testing.T) {
+	ctx := testlogging.Context(t)
+	scratchDir := t.TempDir()
+	md, root := newScratchTestRoot(t, scratchDir)
+
+	f := lookupFile(ctx, t, root, "file.txt")
+
+	fh, _, errno := f.Open(ctx, syscall.O_RDWR)
+	require.Equal(t, gofusefs.OK, errno)
+
+	// opening for writing copies the file to the scratch directory.
+	b, err := ioutil.ReadFile(filepath.Join(scratchDir, "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, originalContents, b)
+
+	modified := []byte("modified contents, which are longer")
+
+	n, errno := fh.(gofusefs.FileWriter).Write(ctx, modified, 0)
+	require.Equal(t, gofusefs.OK, errno)
+	require.Equal(t, uint32(len(modified)), n)
+	require.Equal(t, gofusefs.OK, fh.(gofusefs.FileReleaser).Release(ctx))
+
+	b, err = ioutil.ReadFile(filepath.Join(scratchDir, "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, modified, b)
+
+	// the snapshot contents are unchanged.
+	require.Equal(t, originalContents, readSnapshotFile(ctx, t, md, "file.txt"))
+
+	// subsequent opens and attributes use the scratch copy, including for nodes looked up again.
+	f = lookupFile(ctx, t, root, "file.txt")
+
+	fh, _, errno = f.Open(ctx, syscall.O_RDONLY)
+	require.Equal(t, gofusefs.OK, errno)
+	require.Equal(t, modified, readHandle(ctx, t, fh))
+	require.Equal(t, gofusefs.OK, fh.(gofusefs.FileReleaser).Release(ctx))
+
+	var out fuse.AttrOut
+
+	require.Equal(t, gofusefs.OK, f.Getattr(ctx, nil, &out))
+	require.Equal(t, uint64(len(modified)), out.Size)
+}
+
+func TestScratchReadOnlyOpenUsesSnapshot(t *testing.T) {
+	ctx := testlogging.Context(t)
+	scratchDir := t.TempDir()
+	_, root := newScratchTestRoot(t, scratchDir)
+
+	f := lookupFile(ctx, t, root, "file.txt")
+
+	fh, _, errno := f.Open(ctx, syscall.O_RDONLY)
+	require.Equal(t, gofusefs.OK, errno)
+	require.IsType(t, &fuseFileHandle{}, fh)
+	require.Equal(t, originalContents, readHandle(ctx, t, fh))
+	require.Equal(t, gofusefs.OK, fh.(gofusefs.FileReleaser).Release(ctx))
+
+	entries, err := ioutil.ReadDir(scratchDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestScratchNewEntriesListed(t *testing.T) {
+	ctx := testlogging.Context(t)
+	scratchDir := t.TempDir()
+	md, root := newScratchTestRoot(t, scratchDir)
+
+	// remember the name as missing, creating it must invalidate the negative lookup.
+	_, errno := root.Lookup(ctx, "new.txt", &fuse.EntryOut{})
+	require.Equal(t, syscall.ENOENT, errno)
+
+	_, fh, _, errno := root.Create(ctx, "new.txt", syscall.O_WRONLY, 0o644, &fuse.EntryOut{})
+	require.Equal(t, gofusefs.OK, errno)
+
+	_, errno = fh.(gofusefs.FileWriter).Write(ctx, []byte("new"), 0)
+	require.Equal(t, gofusefs.OK, errno)
+	require.Equal(t, gofusefs.OK, fh.(gofusefs.FileReleaser).Release(ctx))
+
+	_, errno = root.Mkdir(ctx, "newdir", 0o755, &fuse.EntryOut{})
+	require.Equal(t, gofusefs.OK, errno)
+
+	// directories existing in the snapshot can't be created again.
+	_, errno = root.Mkdir(ctx, "subdir", 0o755, &fuse.EntryOut{})
+	require.Equal(t, syscall.EEXIST, errno)
+
+	ds, errno := root.Readdir(ctx)
+	require.Equal(t, gofusefs.OK, errno)
+	require.Equal(t, []string{"file.txt", "new.txt", "newdir", "subdir"}, dirStreamNames(t, ds))
+
+	f := lookupFile(ctx, t, root, "new.txt")
+
+	fh, _, errno = f.Open(ctx, syscall.O_RDONLY)
+	require.Equal(t, gofusefs.OK, errno)
+	require.Equal(t, []byte("new"), readHandle(ctx, t, fh))
+	require.Equal(t, gofusefs.OK, fh.(gofusefs.FileReleaser).Release(ctx))
+
+	// the snapshot directory is unchanged.
+	entries, err := md.Readdir(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
+
+func TestScratchDisabledIsReadOnly(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, root := newScratchTestRoot(t, "")
+
+	_, _, _, errno := root.Create(ctx, "new.txt", syscall.O_WRONLY, 0o644, &fuse.EntryOut{})
+	require.Equal(t, syscall.EROFS, errno)
+
+	_, errno = root.Mkdir(ctx, "newdir", 0o755, &fuse.EntryOut{})
+	require.Equal(t, syscall.EROFS, errno)
+
+	f := lookupFile(ctx, t, root, "file.txt")
+	require.Equal(t, syscall.EROFS, f.Setattr(ctx, nil, &fuse.SetAttrIn{}, &fuse.AttrOut{}))
+}
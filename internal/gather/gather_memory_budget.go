@@ -0,0 +1,141 @@
+package gather
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/stats"
+)
+
+var (
+	budgetMutex    sync.Mutex
+	budgetLimit    int64                 // maximum number of bytes that can be reserved, 0 == unlimited
+	budgetReserved int64                 // number of bytes currently reserved
+	budgetChanged  = make(chan struct{}) // closed and replaced whenever waiters may be able to proceed
+)
+
+// SetMemoryBudget sets the maximum number of bytes that can be reserved using AcquireMemory().
+// Zero disables the limit. Raising the limit wakes up any goroutines waiting for memory.
+func SetMemoryBudget(n int64) error {
+	if n < 0 {
+		return errors.Errorf("invalid memory budget: %v", n)
+	}
+
+	if n > 0 && n < chunkSize {
+		return errors.Errorf("memory budget must be at least %v bytes", chunkSize)
+	}
+
+	budgetMutex.Lock()
+	defer budgetMutex.Unlock()
+
+	budgetLimit = n
+	notifyBudgetChangedLocked()
+
+	return nil
+}
+
+// MemoryBudget returns the current memory budget, zero means unlimited.
+func MemoryBudget() int64 {
+	budgetMutex.Lock()
+	defer budgetMutex.Unlock()
+
+	return budgetLimit
+}
+
+// ReservedMemory returns the number of bytes currently reserved using AcquireMemory().
+func ReservedMemory() int64 {
+	budgetMutex.Lock()
+	defer budgetMutex.Unlock()
+
+	return budgetReserved
+}
+
+// AcquireMemory reserves n bytes of the memory budget, blocking until enough memory is released
+// by other callers or the context is canceled. A reservation is always granted when nothing else
+// is reserved, so a single request larger than the budget cannot block forever.
+//
+// Callers must not hold locks that are needed to release memory while calling AcquireMemory,
+// and must eventually call ReleaseMemory() with the same number of bytes.
+func AcquireMemory(ctx context.Context, n int64) error {
+	t0 := time.Now()
+	blocked := false
+
+	for {
+		ch, reserved, ok := tryAcquireMemory(n)
+		if ok {
+			stats.Record(ctx, metricReservedBytes.M(reserved))
+
+			if blocked {
+				stats.Record(ctx,
+					metricBlockedAcquisitions.M(1),
+					metricBlockedDuration.M(time.Since(t0).Milliseconds()))
+			}
+
+			return nil
+		}
+
+		blocked = true
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "error waiting for memory budget")
+		}
+	}
+}
+
+// TryAcquireMemory reserves n bytes of the memory budget if they are available without waiting
+// and returns true if the reservation was granted.
+func TryAcquireMemory(ctx context.Context, n int64) bool {
+	_, reserved, ok := tryAcquireMemory(n)
+	if ok {
+		stats.Record(ctx, metricReservedBytes.M(reserved))
+	}
+
+	return ok
+}
+
+// ReleaseMemory returns n bytes previously reserved by AcquireMemory() to the budget.
+func ReleaseMemory(n int64) {
+	if n == 0 {
+		return
+	}
+
+	reserved := releaseMemoryInternal(n)
+
+	stats.Record(context.Background(), metricReservedBytes.M(reserved))
+}
+
+func tryAcquireMemory(n int64) (changed <-chan struct{}, reserved int64, ok bool) {
+	budgetMutex.Lock()
+	defer budgetMutex.Unlock()
+
+	if budgetLimit > 0 && budgetReserved > 0 && budgetReserved+n > budgetLimit {
+		return budgetChanged, budgetReserved, false
+	}
+
+	budgetReserved += n
+
+	return nil, budgetReserved, true
+}
+
+func releaseMemoryInternal(n int64) int64 {
+	budgetMutex.Lock()
+	defer budgetMutex.Unlock()
+
+	budgetReserved -= n
+	if budgetReserved < 0 {
+		panic("gather: memory released more than it was reserved")
+	}
+
+	notifyBudgetChangedLocked()
+
+	return budgetReserved
+}
+
+func notifyBudgetChangedLocked() {
+	close(budgetChanged)
+	budgetChanged = make(chan struct{})
+}
@@ -0,0 +1,246 @@
+package gather
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/stats/view"
+)
+
+func setMemoryBudgetForTesting(t *testing.T, n int64) {
+	t.Helper()
+
+	budgetMutex.Lock()
+	reserved := budgetReserved
+	budgetMutex.Unlock()
+
+	if reserved != 0 {
+		t.Fatalf("memory reserved at the start of the test: %v", reserved)
+	}
+
+	if err := SetMemoryBudget(n); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		SetMemoryBudget(0) // nolint:errcheck
+	})
+}
+
+func currentOutstandingChunks() int {
+	freeListMutex.Lock()
+	defer freeListMutex.Unlock()
+
+	return outstandingChunks
+}
+
+func TestSetMemoryBudgetValidation(t *testing.T) {
+	if err := SetMemoryBudget(-1); err == nil {
+		t.Errorf("expected error for negative budget")
+	}
+
+	if err := SetMemoryBudget(chunkSize - 1); err == nil {
+		t.Errorf("expected error for budget smaller than a chunk")
+	}
+
+	setMemoryBudgetForTesting(t, chunkSize)
+
+	if got, want := MemoryBudget(), int64(chunkSize); got != want {
+		t.Errorf("unexpected budget %v, want %v", got, want)
+	}
+}
+
+func TestAcquireMemoryBlocksUntilReleased(t *testing.T) {
+	setMemoryBudgetForTesting(t, 2*chunkSize)
+
+	ctx := context.Background()
+
+	if err := AcquireMemory(ctx, 2*chunkSize); err != nil {
+		t.Fatal(err)
+	}
+
+	// budget is exhausted, acquisition must not succeed before the deadline.
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	if err := AcquireMemory(shortCtx, chunkSize); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v, want deadline exceeded", err)
+	}
+
+	acquired := make(chan error, 1)
+
+	go func() {
+		acquired <- AcquireMemory(ctx, chunkSize)
+	}()
+
+	ReleaseMemory(2 * chunkSize)
+
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+
+	ReleaseMemory(chunkSize)
+}
+
+func TestAcquireMemoryLargerThanBudget(t *testing.T) {
+	setMemoryBudgetForTesting(t, chunkSize)
+
+	// with nothing reserved, requests larger than the budget are granted.
+	if err := AcquireMemory(context.Background(), 10*chunkSize); err != nil {
+		t.Fatal(err)
+	}
+
+	ReleaseMemory(10 * chunkSize)
+}
+
+func TestSetMemoryBudgetWakesWaiters(t *testing.T) {
+	setMemoryBudgetForTesting(t, chunkSize)
+
+	ctx := context.Background()
+
+	if err := AcquireMemory(ctx, chunkSize); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error, 1)
+
+	go func() {
+		acquired <- AcquireMemory(ctx, chunkSize)
+	}()
+
+	if err := SetMemoryBudget(2 * chunkSize); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+
+	ReleaseMemory(2 * chunkSize)
+}
+
+func TestReleaseMemoryMoreThanReserved(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+
+		budgetMutex.Lock()
+		budgetReserved = 0
+		budgetMutex.Unlock()
+	}()
+
+	ReleaseMemory(1)
+}
+
+func TestWriteBufferNotLimitedByMemoryBudget(t *testing.T) {
+	setMemoryBudgetForTesting(t, chunkSize)
+
+	ctx := context.Background()
+
+	// exhaust the budget, write buffers must still be able to grow since
+	// blocking them could deadlock the holders of partially-filled buffers.
+	if err := AcquireMemory(ctx, chunkSize); err != nil {
+		t.Fatal(err)
+	}
+
+	defer ReleaseMemory(chunkSize)
+
+	before := currentOutstandingChunks()
+
+	var b WriteBuffer
+
+	data := make([]byte, 5*chunkSize+100)
+	b.Append(data)
+
+	if got, want := b.Length(), len(data); got != want {
+		t.Errorf("invalid length %v, want %v", got, want)
+	}
+
+	if got, want := currentOutstandingChunks()-before, 6; got != want {
+		t.Errorf("unexpected outstanding chunks %v, want %v", got, want)
+	}
+
+	b.Close()
+
+	if got, want := currentOutstandingChunks(), before; got != want {
+		t.Errorf("unexpected outstanding chunks after close %v, want %v", got, want)
+	}
+}
+
+func TestWriteBufferConcurrentAppend(t *testing.T) {
+	setMemoryBudgetForTesting(t, 2*chunkSize)
+
+	const (
+		numWorkers = 8
+		numWrites  = 100
+		writeSize  = 50000
+	)
+
+	before := currentOutstandingChunks()
+
+	var (
+		shared WriteBuffer
+		wg     sync.WaitGroup
+	)
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			var own WriteBuffer
+			defer own.Close()
+
+			data := make([]byte, writeSize)
+
+			for j := 0; j < numWrites; j++ {
+				own.Append(data)
+				shared.Append(data)
+			}
+
+			if got, want := own.Length(), numWrites*writeSize; got != want {
+				t.Errorf("invalid length %v, want %v", got, want)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got, want := shared.Length(), numWorkers*numWrites*writeSize; got != want {
+		t.Errorf("invalid shared length %v, want %v", got, want)
+	}
+
+	shared.Close()
+
+	if got, want := currentOutstandingChunks(), before; got != want {
+		t.Errorf("unexpected outstanding chunks %v, want %v", got, want)
+	}
+}
+
+func TestMemoryBudgetMetrics(t *testing.T) {
+	setMemoryBudgetForTesting(t, 2*chunkSize)
+
+	if err := AcquireMemory(context.Background(), 12345); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := view.RetrieveData(metricReservedBytes.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+
+	if got, want := rows[0].Data.(*view.LastValueData).Value, float64(12345); got != want {
+		t.Errorf("unexpected reserved bytes %v, want %v", got, want)
+	}
+
+	ReleaseMemory(12345)
+}
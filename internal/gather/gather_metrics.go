@@ -0,0 +1,53 @@
+package gather
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+// write buffer and memory budget metrics.
+var (
+	metricOutstandingBytes = stats.Int64(
+		"kopia/gather/outstanding_bytes",
+		"Number of bytes held by write buffers and not released yet",
+		stats.UnitBytes,
+	)
+
+	metricReservedBytes = stats.Int64(
+		"kopia/gather/reserved_bytes",
+		"Number of bytes currently reserved from the memory budget",
+		stats.UnitBytes,
+	)
+
+	metricBlockedAcquisitions = stats.Int64(
+		"kopia/gather/blocked_acquisitions",
+		"Number of memory reservations that had to wait for the memory budget",
+		stats.UnitDimensionless,
+	)
+
+	metricBlockedDuration = stats.Int64(
+		"kopia/gather/blocked_duration_ms",
+		"Total time spent waiting for the memory budget",
+		stats.UnitMilliseconds,
+	)
+)
+
+func simpleAggregation(m stats.Measure, agg *view.Aggregation) *view.View {
+	return &view.View{
+		Name:        m.Name(),
+		Aggregation: agg,
+		Description: m.Description(),
+		Measure:     m,
+	}
+}
+
+func init() {
+	if err := view.Register(
+		simpleAggregation(metricOutstandingBytes, view.LastValue()),
+		simpleAggregation(metricReservedBytes, view.LastValue()),
+		simpleAggregation(metricBlockedAcquisitions, view.Sum()),
+		simpleAggregation(metricBlockedDuration, view.Sum()),
+	); err != nil {
+		panic("unable to register opencensus views: " + err.Error())
+	}
+}
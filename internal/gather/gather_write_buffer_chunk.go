@@ -1,7 +1,10 @@
 package gather
 
 import (
+	"context"
 	"sync"
+
+	"go.opencensus.io/stats"
 )
 
 const chunkSize = 1 << 20 // 1MB chunks
@@ -10,21 +13,32 @@
 	freeListMutex         sync.Mutex
 	freeList              [][]byte
 	freeListHighWaterMark int
+	outstandingChunks     int // number of chunks returned by allocChunk() and not released yet
 )
 
 func allocChunk() []byte {
+	ch, outstanding := allocChunkInternal()
+
+	stats.Record(context.Background(), metricOutstandingBytes.M(int64(outstanding)*chunkSize))
+
+	return ch
+}
+
+func allocChunkInternal() (ch []byte, outstanding int) {
 	freeListMutex.Lock()
 	defer freeListMutex.Unlock()
 
+	outstandingChunks++
+
 	l := len(freeList)
 	if l == 0 {
-		return make([]byte, 0, chunkSize)
+		return make([]byte, 0, chunkSize), outstandingChunks
 	}
 
-	ch := freeList[l-1]
+	ch = freeList[l-1]
 	freeList = freeList[0 : l-1]
 
-	return ch
+	return ch, outstandingChunks
 }
 
 func releaseChunk(s []byte) {
@@ -32,11 +46,24 @@ func releaseChunk(s []byte) {
 		return
 	}
 
+	outstanding := releaseChunkInternal(s)
+
+	stats.Record(context.Background(), metricOutstandingBytes.M(int64(outstanding)*chunkSize))
+}
+
+func releaseChunkInternal(s []byte) int {
 	freeListMutex.Lock()
 	defer freeListMutex.Unlock()
 
+	outstandingChunks--
+	if outstandingChunks < 0 {
+		panic("gather: chunk released more times than it was allocated")
+	}
+
 	freeList = append(freeList, s[:0])
 	if len(freeList) > freeListHighWaterMark {
 		freeListHighWaterMark = len(freeList)
 	}
+
+	return outstandingChunks
 }
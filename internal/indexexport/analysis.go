@@ -0,0 +1,185 @@
+package indexexport
+
+import (
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+// utilizationBuckets are upper bounds (in percent) of pack utilization reported by Analysis.
+var utilizationBuckets = []int{10, 25, 50, 75, 90, 100}
+
+// PrefixStats contains statistics of contents or packs sharing the same ID prefix.
+type PrefixStats struct {
+	Prefix          string `json:"prefix"`
+	Count           int64  `json:"count"`
+	PackedBytes     int64  `json:"packedBytes"`
+	OriginalBytes   int64  `json:"originalBytes"`
+	DeletedCount    int64  `json:"deletedCount"`
+	DeletedBytes    int64  `json:"deletedPackedBytes"`
+	CompressedCount int64  `json:"compressedCount"`
+}
+
+// UtilizationBucket describes packs whose utilization (the percentage of bytes used by contents
+// which are not deleted) is greater than the previous bucket and at most MaxPercent.
+type UtilizationBucket struct {
+	MaxPercent   int   `json:"maxPercent"`
+	Packs        int   `json:"packs"`
+	PackedBytes  int64 `json:"packedBytes"`
+	DeletedBytes int64 `json:"deletedPackedBytes"`
+}
+
+// Analysis summarizes exported index entries.
+type Analysis struct {
+	Rows            int64                `json:"rows"`
+	Contents        PrefixStats          `json:"contents"`
+	ContentPrefixes []*PrefixStats       `json:"contentPrefixes"`
+	PackPrefixes    []*PrefixStats       `json:"packPrefixes"`
+	Packs           int                  `json:"packs"`
+	Utilization     []*UtilizationBucket `json:"utilization"`
+}
+
+type packUsage struct {
+	liveBytes    int64
+	deletedBytes int64
+}
+
+// Analyzer computes Analysis of index entries.
+type Analyzer struct {
+	rows            int64
+	total           PrefixStats
+	contentPrefixes map[string]*PrefixStats
+	packPrefixes    map[string]*PrefixStats
+	packs           map[string]*packUsage
+}
+
+// NewAnalyzer returns a new Analyzer.
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{
+		contentPrefixes: map[string]*PrefixStats{},
+		packPrefixes:    map[string]*PrefixStats{},
+		packs:           map[string]*packUsage{},
+	}
+}
+
+func statsFor(m map[string]*PrefixStats, prefix string) *PrefixStats {
+	s := m[prefix]
+	if s == nil {
+		s = &PrefixStats{Prefix: prefix}
+		m[prefix] = s
+	}
+
+	return s
+}
+
+func (s *PrefixStats) add(r Row) {
+	if r.Deleted {
+		s.DeletedCount++
+		s.DeletedBytes += int64(r.PackedLength)
+
+		return
+	}
+
+	s.Count++
+	s.PackedBytes += int64(r.PackedLength)
+	s.OriginalBytes += int64(r.OriginalLength)
+
+	if r.CompressionHeaderID != 0 {
+		s.CompressedCount++
+	}
+}
+
+// Add adds a single row to the analysis.
+func (a *Analyzer) Add(r Row) {
+	a.rows++
+	a.total.add(r)
+	statsFor(a.contentPrefixes, string(content.ID(r.ContentID).Prefix())).add(r)
+
+	packPrefix := ""
+	if r.PackBlobID != "" {
+		packPrefix = r.PackBlobID[0:1]
+	}
+
+	statsFor(a.packPrefixes, packPrefix).add(r)
+
+	pu := a.packs[r.PackBlobID]
+	if pu == nil {
+		pu = &packUsage{}
+		a.packs[r.PackBlobID] = pu
+	}
+
+	if r.Deleted {
+		pu.deletedBytes += int64(r.PackedLength)
+	} else {
+		pu.liveBytes += int64(r.PackedLength)
+	}
+}
+
+// AddAll adds all rows returned by the reader to the analysis.
+func (a *Analyzer) AddAll(r *Reader) error {
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		a.Add(row)
+	}
+}
+
+func sortedStats(m map[string]*PrefixStats) []*PrefixStats {
+	var result []*PrefixStats
+
+	for _, s := range m {
+		result = append(result, s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Prefix < result[j].Prefix
+	})
+
+	return result
+}
+
+// Result returns the analysis of rows added so far.
+func (a *Analyzer) Result() *Analysis {
+	res := &Analysis{
+		Rows:            a.rows,
+		Contents:        a.total,
+		ContentPrefixes: sortedStats(a.contentPrefixes),
+		PackPrefixes:    sortedStats(a.packPrefixes),
+		Packs:           len(a.packs),
+	}
+
+	for _, max := range utilizationBuckets {
+		res.Utilization = append(res.Utilization, &UtilizationBucket{MaxPercent: max})
+	}
+
+	for _, pu := range a.packs {
+		total := pu.liveBytes + pu.deletedBytes
+		if total == 0 {
+			continue
+		}
+
+		pct := pu.liveBytes * 100 / total //nolint:gomnd
+
+		for _, b := range res.Utilization {
+			if pct <= int64(b.MaxPercent) {
+				b.Packs++
+				b.PackedBytes += total
+				b.DeletedBytes += pu.deletedBytes
+
+				break
+			}
+		}
+	}
+
+	return res
+}
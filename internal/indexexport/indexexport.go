@@ -0,0 +1,245 @@
+// Package indexexport implements export of content index entries for offline analysis.
+//
+// Entries are exported as CSV with a header row followed by one row per index entry,
+// with the following columns:
+//
+//	contentID            - content ID, including its prefix
+//	packBlobID           - ID of the pack blob containing the content
+//	packOffset           - offset of the content in the pack blob
+//	packedLength         - length of the content in the pack blob (after compression and encryption)
+//	originalLength       - length of the content before compression and encryption
+//	timestamp            - time the entry was written (or deleted), in seconds since Unix epoch
+//	deleted              - "true" if the entry marks a deleted content, "false" otherwise
+//	formatVersion        - version of the index entry format
+//	compressionHeaderID  - ID of the compression method, 0 if not compressed
+//	encryptionKeyID      - ID of the encryption key
+//
+// Readers match columns by name, so files with columns in a different order, or with additional
+// columns, can be read as well.
+package indexexport
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+// Names of exported columns.
+const (
+	ColumnContentID           = "contentID"
+	ColumnPackBlobID          = "packBlobID"
+	ColumnPackOffset          = "packOffset"
+	ColumnPackedLength        = "packedLength"
+	ColumnOriginalLength      = "originalLength"
+	ColumnTimestamp           = "timestamp"
+	ColumnDeleted             = "deleted"
+	ColumnFormatVersion       = "formatVersion"
+	ColumnCompressionHeaderID = "compressionHeaderID"
+	ColumnEncryptionKeyID     = "encryptionKeyID"
+)
+
+// Columns lists exported columns in the order in which they are written.
+var Columns = []string{
+	ColumnContentID,
+	ColumnPackBlobID,
+	ColumnPackOffset,
+	ColumnPackedLength,
+	ColumnOriginalLength,
+	ColumnTimestamp,
+	ColumnDeleted,
+	ColumnFormatVersion,
+	ColumnCompressionHeaderID,
+	ColumnEncryptionKeyID,
+}
+
+// Row is a single exported index entry.
+type Row struct {
+	ContentID           string `json:"contentID"`
+	PackBlobID          string `json:"packBlobID"`
+	PackOffset          uint32 `json:"packOffset"`
+	PackedLength        uint32 `json:"packedLength"`
+	OriginalLength      uint32 `json:"originalLength"`
+	TimestampSeconds    int64  `json:"timestamp"`
+	Deleted             bool   `json:"deleted"`
+	FormatVersion       byte   `json:"formatVersion"`
+	CompressionHeaderID uint32 `json:"compressionHeaderID"`
+	EncryptionKeyID     byte   `json:"encryptionKeyID"`
+}
+
+// RowFromInfo returns the exported representation of the provided index entry.
+func RowFromInfo(i content.Info) Row {
+	return Row{
+		ContentID:           string(i.GetContentID()),
+		PackBlobID:          string(i.GetPackBlobID()),
+		PackOffset:          i.GetPackOffset(),
+		PackedLength:        i.GetPackedLength(),
+		OriginalLength:      i.GetOriginalLength(),
+		TimestampSeconds:    i.GetTimestampSeconds(),
+		Deleted:             i.GetDeleted(),
+		FormatVersion:       i.GetFormatVersion(),
+		CompressionHeaderID: uint32(i.GetCompressionHeaderID()),
+		EncryptionKeyID:     i.GetEncryptionKeyID(),
+	}
+}
+
+func (r Row) values() []string {
+	return []string{
+		r.ContentID,
+		r.PackBlobID,
+		strconv.FormatUint(uint64(r.PackOffset), 10),
+		strconv.FormatUint(uint64(r.PackedLength), 10),
+		strconv.FormatUint(uint64(r.OriginalLength), 10),
+		strconv.FormatInt(r.TimestampSeconds, 10),
+		strconv.FormatBool(r.Deleted),
+		strconv.FormatUint(uint64(r.FormatVersion), 10),
+		strconv.FormatUint(uint64(r.CompressionHeaderID), 10),
+		strconv.FormatUint(uint64(r.EncryptionKeyID), 10),
+	}
+}
+
+// Writer writes exported index entries.
+type Writer struct {
+	w *csv.Writer
+}
+
+// NewWriter returns a Writer which writes CSV to the provided output, starting with the header row.
+func NewWriter(output io.Writer) (*Writer, error) {
+	w := csv.NewWriter(output)
+
+	if err := w.Write(Columns); err != nil {
+		return nil, errors.Wrap(err, "error writing header")
+	}
+
+	return &Writer{w}, nil
+}
+
+// Write writes a single row.
+func (w *Writer) Write(r Row) error {
+	return errors.Wrap(w.w.Write(r.values()), "error writing row")
+}
+
+// Flush writes any buffered rows to the underlying output.
+func (w *Writer) Flush() error {
+	w.w.Flush()
+
+	return errors.Wrap(w.w.Error(), "error flushing output")
+}
+
+// Reader reads exported index entries.
+type Reader struct {
+	r       *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+// NewReader returns a Reader of CSV produced by Writer.
+func NewReader(input io.Reader) (*Reader, error) {
+	r := csv.NewReader(input)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading header")
+	}
+
+	columns := map[string]int{}
+
+	for i, h := range header {
+		columns[h] = i
+	}
+
+	for _, c := range Columns {
+		if _, ok := columns[c]; !ok {
+			return nil, errors.Errorf("missing column %q", c)
+		}
+	}
+
+	return &Reader{r: r, columns: columns, line: 1}, nil
+}
+
+// Read reads the next row, returns io.EOF at the end of input.
+func (r *Reader) Read() (Row, error) {
+	rec, err := r.r.Read()
+	if errors.Is(err, io.EOF) {
+		return Row{}, io.EOF
+	}
+
+	r.line++
+
+	if err != nil {
+		return Row{}, errors.Wrap(err, "error reading row")
+	}
+
+	p := rowParser{rec: rec, columns: r.columns}
+
+	row := Row{
+		ContentID:           p.str(ColumnContentID),
+		PackBlobID:          p.str(ColumnPackBlobID),
+		PackOffset:          uint32(p.uint(ColumnPackOffset, 32)),
+		PackedLength:        uint32(p.uint(ColumnPackedLength, 32)),
+		OriginalLength:      uint32(p.uint(ColumnOriginalLength, 32)),
+		TimestampSeconds:    p.int(ColumnTimestamp),
+		Deleted:             p.bool(ColumnDeleted),
+		FormatVersion:       byte(p.uint(ColumnFormatVersion, 8)),
+		CompressionHeaderID: uint32(p.uint(ColumnCompressionHeaderID, 32)),
+		EncryptionKeyID:     byte(p.uint(ColumnEncryptionKeyID, 8)),
+	}
+
+	if p.err != nil {
+		return Row{}, errors.Wrapf(p.err, "invalid row on line %v", r.line)
+	}
+
+	return row, nil
+}
+
+// rowParser parses columns of a single record, remembering the first error.
+type rowParser struct {
+	rec     []string
+	columns map[string]int
+	err     error
+}
+
+func (p *rowParser) str(column string) string {
+	i := p.columns[column]
+	if i >= len(p.rec) {
+		if p.err == nil {
+			p.err = errors.Errorf("missing value of %q", column)
+		}
+
+		return ""
+	}
+
+	return p.rec[i]
+}
+
+func (p *rowParser) check(column string, err error) {
+	if err != nil && p.err == nil {
+		p.err = errors.Wrapf(err, "invalid value of %q", column)
+	}
+}
+
+func (p *rowParser) uint(column string, bitSize int) uint64 {
+	v, err := strconv.ParseUint(p.str(column), 10, bitSize)
+	p.check(column, err)
+
+	return v
+}
+
+func (p *rowParser) int(column string) int64 {
+	v, err := strconv.ParseInt(p.str(column), 10, 64)
+	p.check(column, err)
+
+	return v
+}
+
+func (p *rowParser) bool(column string) bool {
+	v, err := strconv.ParseBool(p.str(column))
+	p.check(column, err)
+
+	return v
+}
@@ -0,0 +1,102 @@
+package indexexport_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/indexexport"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	rows := []indexexport.Row{
+		{ContentID: "k0123", PackBlobID: "q01", PackOffset: 0, PackedLength: 100, OriginalLength: 300, TimestampSeconds: 1600000000, FormatVersion: 2, CompressionHeaderID: 0x1100},
+		{ContentID: "abcd", PackBlobID: "p01", PackOffset: 0, PackedLength: 50, OriginalLength: 40, TimestampSeconds: 1600000001, FormatVersion: 2},
+		{ContentID: "abce", PackBlobID: "p01", PackOffset: 50, PackedLength: 150, OriginalLength: 140, TimestampSeconds: 1600000002, Deleted: true, FormatVersion: 2, EncryptionKeyID: 1},
+	}
+
+	var buf bytes.Buffer
+
+	w, err := indexexport.NewWriter(&buf)
+	require.NoError(t, err)
+
+	for _, r := range rows {
+		require.NoError(t, w.Write(r))
+	}
+
+	require.NoError(t, w.Flush())
+	require.True(t, strings.HasPrefix(buf.String(), strings.Join(indexexport.Columns, ",")+"\n"))
+
+	r, err := indexexport.NewReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	var got []indexexport.Row
+
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+
+		got = append(got, row)
+	}
+
+	require.Equal(t, rows, got)
+
+	// invalid values and missing columns are reported.
+	_, err = indexexport.NewReader(strings.NewReader("contentID,packBlobID\n"))
+	require.Error(t, err)
+
+	r, err = indexexport.NewReader(strings.NewReader(strings.Join(indexexport.Columns, ",") + "\nk1,q1,x,1,1,1,false,1,0,0\n"))
+	require.NoError(t, err)
+
+	_, err = r.Read()
+	require.Error(t, err)
+}
+
+func TestAnalyzer(t *testing.T) {
+	a := indexexport.NewAnalyzer()
+
+	a.Add(indexexport.Row{ContentID: "k0123", PackBlobID: "q01", PackedLength: 100, OriginalLength: 300, CompressionHeaderID: 0x1100})
+	a.Add(indexexport.Row{ContentID: "abcd", PackBlobID: "p01", PackedLength: 50, OriginalLength: 40})
+	a.Add(indexexport.Row{ContentID: "abce", PackBlobID: "p01", PackedLength: 150, OriginalLength: 140, Deleted: true})
+
+	res := a.Result()
+
+	require.Equal(t, int64(3), res.Rows)
+	require.Equal(t, int64(2), res.Contents.Count)
+	require.Equal(t, int64(150), res.Contents.PackedBytes)
+	require.Equal(t, int64(340), res.Contents.OriginalBytes)
+	require.Equal(t, int64(1), res.Contents.CompressedCount)
+	require.Equal(t, int64(1), res.Contents.DeletedCount)
+	require.Equal(t, int64(150), res.Contents.DeletedBytes)
+
+	require.Len(t, res.ContentPrefixes, 2)
+	require.Equal(t, "", res.ContentPrefixes[0].Prefix)
+	require.Equal(t, "k", res.ContentPrefixes[1].Prefix)
+
+	require.Len(t, res.PackPrefixes, 2)
+	require.Equal(t, "p", res.PackPrefixes[0].Prefix)
+	require.Equal(t, int64(1), res.PackPrefixes[0].DeletedCount)
+
+	require.Equal(t, 2, res.Packs)
+
+	// p01 is 25% utilized, q01 is fully utilized.
+	for _, b := range res.Utilization {
+		switch b.MaxPercent {
+		case 25:
+			require.Equal(t, 1, b.Packs)
+			require.Equal(t, int64(200), b.PackedBytes)
+			require.Equal(t, int64(150), b.DeletedBytes)
+		case 100:
+			require.Equal(t, 1, b.Packs)
+		default:
+			require.Zero(t, b.Packs)
+		}
+	}
+}
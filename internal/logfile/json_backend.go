@@ -0,0 +1,46 @@
+package logfile
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	logging "github.com/op/go-logging"
+	"github.com/pkg/errors"
+)
+
+// jsonLogEntry is a single line of structured log output.
+type jsonLogEntry struct {
+	Time    time.Time `json:"ts"`
+	Level   string    `json:"level"`
+	Module  string    `json:"module"`
+	Message string    `json:"msg"`
+}
+
+// jsonBackend is a logging backend that writes each record as a single line of JSON,
+// suitable for ingestion by log aggregation systems.
+type jsonBackend struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (b *jsonBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	v, err := json.Marshal(jsonLogEntry{
+		Time:    rec.Time.UTC(),
+		Level:   strings.ToLower(level.String()),
+		Module:  rec.Module,
+		Message: rec.Message(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal log entry")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, err = b.w.Write(append(v, '\n'))
+
+	return errors.Wrap(err, "unable to write log entry")
+}
@@ -0,0 +1,44 @@
+package logfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	logging "github.com/op/go-logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONBackend(t *testing.T) {
+	var buf bytes.Buffer
+
+	b := &jsonBackend{w: &buf}
+	ts := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	require.NoError(t, b.Log(logging.INFO, 0, &logging.Record{
+		Time:   ts,
+		Module: "kopia/repo",
+		Level:  logging.INFO,
+		Args:   []interface{}{"line1\nline2 \"quoted\""},
+	}))
+	require.NoError(t, b.Log(logging.ERROR, 0, &logging.Record{
+		Time:   ts,
+		Module: "kopia/cli",
+		Level:  logging.ERROR,
+		Args:   []interface{}{"failed"},
+	}))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var e jsonLogEntry
+
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &e))
+	require.Equal(t, jsonLogEntry{Time: ts, Level: "info", Module: "kopia/repo", Message: "line1\nline2 \"quoted\""}, e)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	require.Equal(t, "error", e.Level)
+	require.Equal(t, "failed", e.Message)
+}
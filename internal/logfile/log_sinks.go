@@ -0,0 +1,47 @@
+package logfile
+
+import (
+	"io"
+	"sync"
+
+	logging "github.com/op/go-logging"
+)
+
+var sinkLogFormat = logging.MustStringFormatter(
+	`%{time:2006-01-02T15:04:05.000Z07:00} %{level:.1s} %{module} %{message}`)
+
+// sinkBackend forwards log records to all registered sinks.
+type sinkBackend struct {
+	mu    sync.Mutex
+	sinks map[*io.Writer]logging.Backend
+}
+
+var sinks = &sinkBackend{sinks: map[*io.Writer]logging.Backend{}} // nolint:gochecknoglobals
+
+func (s *sinkBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range s.sinks {
+		r2 := *rec
+		b.Log(level, calldepth+1, &r2) //nolint:errcheck
+	}
+
+	return nil
+}
+
+// AddLogSink registers a writer that receives log output at the log file level, one line per
+// write prefixed with the timestamp in RFC 3339 format, and returns a function that unregisters it.
+func AddLogSink(w io.Writer) (remove func()) {
+	key := &w
+
+	sinks.mu.Lock()
+	sinks.sinks[key] = logging.NewBackendFormatter(logging.NewLogBackend(w, "", 0), sinkLogFormat)
+	sinks.mu.Unlock()
+
+	return func() {
+		sinks.mu.Lock()
+		delete(sinks.sinks, key)
+		sinks.mu.Unlock()
+	}
+}
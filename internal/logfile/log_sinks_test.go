@@ -0,0 +1,31 @@
+package logfile
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	logging "github.com/op/go-logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogSinks(t *testing.T) {
+	var buf bytes.Buffer
+
+	remove := AddLogSink(&buf)
+
+	rec := &logging.Record{
+		Time:   time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Module: "kopia/server",
+		Level:  logging.INFO,
+		Args:   []interface{}{"hello"},
+	}
+
+	require.NoError(t, sinks.Log(logging.INFO, 0, rec))
+	require.Equal(t, "2021-01-02T03:04:05.000Z I kopia/server hello\n", buf.String())
+
+	remove()
+
+	require.NoError(t, sinks.Log(logging.INFO, 0, rec))
+	require.Equal(t, "2021-01-02T03:04:05.000Z I kopia/server hello\n", buf.String())
+}
@@ -33,6 +33,12 @@
 // warning is for backwards compatibility, same as error.
 var logLevels = []string{"debug", "info", "warning", "error"}
 
+// supported console log formats.
+const (
+	logFormatConsole = "console"
+	logFormatJSON    = "json"
+)
+
 type loggingFlags struct {
 	logFile               string
 	contentLogFile        string
@@ -46,6 +52,8 @@ type loggingFlags struct {
 	forceColor            bool
 	disableColor          bool
 	consoleLogTimestamps  bool
+	logFormat             string
+	logLevelsFile         string
 }
 
 func (c *loggingFlags) setup(app *kingpin.Application) {
@@ -62,6 +70,8 @@ func (c *loggingFlags) setup(app *kingpin.Application) {
 	app.Flag("force-color", "Force color output").Hidden().Envar("KOPIA_FORCE_COLOR").BoolVar(&c.forceColor)
 	app.Flag("disable-color", "Disable color output").Hidden().Envar("KOPIA_DISABLE_COLOR").BoolVar(&c.disableColor)
 	app.Flag("console-timestamps", "Log timestamps to stderr.").Hidden().Default("false").Envar("KOPIA_CONSOLE_TIMESTAMPS").BoolVar(&c.consoleLogTimestamps)
+	app.Flag("log-levels-file", "File with per-module log level overrides (module=level), re-loaded by the server on SIGHUP").Envar("KOPIA_LOG_LEVELS_FILE").StringVar(&c.logLevelsFile)
+	app.Flag("log-format", "Console log format, 'json' emits one structured JSON object per line").Default(logFormatConsole).Envar("KOPIA_LOG_FORMAT").EnumVar(&c.logFormat, logFormatConsole, logFormatJSON)
 
 	app.PreAction(c.initialize)
 }
@@ -92,12 +102,17 @@ func (c *loggingFlags) initialize(ctx *kingpin.ParseContext) error {
 		suffix = strings.ReplaceAll(c.FullCommand(), " ", "-")
 	}
 
+	if err := levels.reset(c.logLevelsFile); err != nil {
+		return err
+	}
+
 	// activate backends
 	logging.SetBackend(
 		multiLogger{
 			c.setupConsoleBackend(),
 			c.setupLogFileBackend(now, suffix),
 			c.setupContentLogFileBackend(now, suffix),
+			c.setupSinkBackend(),
 		},
 	)
 
@@ -129,6 +144,17 @@ func (m multiLogger) Log(l logging.Level, calldepth int, rec *logging.Record) er
 }
 
 func (c *loggingFlags) setupConsoleBackend() logging.Backend {
+	if c.logFormat == logFormatJSON {
+		l := logging.AddModuleLevel(&jsonBackend{w: os.Stderr})
+
+		// do not output content logs to the console
+		l.SetLevel(logging.CRITICAL, content.FormatLogModule)
+		l.SetLevel(logLevelFromFlag(c.logLevel), "")
+		levels.register(l)
+
+		return l
+	}
+
 	var (
 		prefix         = "%{color}"
 		suffix         = "%{message}%{color:reset}"
@@ -153,6 +179,7 @@ func (c *loggingFlags) setupConsoleBackend() logging.Backend {
 
 	// log everything else at a level specified using --log-level
 	l.SetLevel(logLevelFromFlag(c.logLevel), "")
+	levels.register(l)
 
 	return l
 }
@@ -205,6 +232,18 @@ func (c *loggingFlags) setupLogFileBackend(now time.Time, suffix string) logging
 
 	// log everything else at a level specified using --file-level
 	l.SetLevel(logLevelFromFlag(c.fileLogLevel), "")
+	levels.register(l)
+
+	return l
+}
+
+func (c *loggingFlags) setupSinkBackend() logging.Backend {
+	l := logging.AddModuleLevel(sinks)
+
+	// do not output content logs to sinks
+	l.SetLevel(logging.CRITICAL, content.FormatLogModule)
+	l.SetLevel(logLevelFromFlag(c.fileLogLevel), "")
+	levels.register(l)
 
 	return l
 }
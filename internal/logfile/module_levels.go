@@ -0,0 +1,201 @@
+package logfile
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	logging "github.com/op/go-logging"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+// ModuleLevel is a log level override for a single logging module.
+type ModuleLevel struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+}
+
+// moduleLevels holds log level overrides applied to console and log file backends at runtime.
+type moduleLevels struct {
+	mu        sync.Mutex
+	overrides map[string]string
+	backends  []logging.LeveledBackend
+
+	// file with overrides that's loaded on startup and re-loaded by Reload().
+	fileName string
+}
+
+var levels = &moduleLevels{overrides: map[string]string{}} // nolint:gochecknoglobals
+
+// reset removes all backends and loads overrides from the provided file, if any.
+func (m *moduleLevels) reset(fileName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.backends = nil
+	m.fileName = fileName
+	m.overrides = map[string]string{}
+
+	if fileName == "" {
+		return nil
+	}
+
+	overrides, err := readModuleLevelsFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	m.overrides = overrides
+
+	return nil
+}
+
+// register starts applying module level overrides to the provided backend, whose
+// default level must already be set.
+func (m *moduleLevels) register(l logging.LeveledBackend) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.backends = append(m.backends, l)
+
+	for module, level := range m.overrides {
+		l.SetLevel(logLevelFromFlag(level), module)
+	}
+}
+
+func (m *moduleLevels) setLocked(module, level string) {
+	if level == "" {
+		delete(m.overrides, module)
+	} else {
+		m.overrides[module] = level
+	}
+
+	for _, l := range m.backends {
+		if level == "" {
+			// revert to default level of the backend.
+			l.SetLevel(l.GetLevel(""), module)
+		} else {
+			l.SetLevel(logLevelFromFlag(level), module)
+		}
+	}
+}
+
+func validateModuleLevel(module, level string) error {
+	if module == "" || module == content.FormatLogModule {
+		return errors.Errorf("invalid logging module %q", module)
+	}
+
+	if level == "" {
+		return nil
+	}
+
+	for _, l := range logLevels {
+		if l == level {
+			return nil
+		}
+	}
+
+	return errors.Errorf("invalid log level %q", level)
+}
+
+// SetModuleLevel overrides the log level of the provided module in the console and log file output.
+// Empty level reverts the module to the level specified using command-line flags.
+func SetModuleLevel(module, level string) error {
+	if err := validateModuleLevel(module, level); err != nil {
+		return err
+	}
+
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+
+	levels.setLocked(module, level)
+
+	return nil
+}
+
+// ModuleLevels returns current log level overrides sorted by module name.
+func ModuleLevels() []ModuleLevel {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+
+	var result []ModuleLevel
+
+	for module, level := range levels.overrides {
+		result = append(result, ModuleLevel{module, level})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Module < result[j].Module
+	})
+
+	return result
+}
+
+// ReloadModuleLevels re-reads the log levels file specified using --log-levels-file and replaces
+// all module level overrides with its contents.
+func ReloadModuleLevels(ctx context.Context) error {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+
+	if levels.fileName == "" {
+		return nil
+	}
+
+	overrides, err := readModuleLevelsFile(levels.fileName)
+	if err != nil {
+		return err
+	}
+
+	for module := range levels.overrides {
+		if _, ok := overrides[module]; !ok {
+			levels.setLocked(module, "")
+		}
+	}
+
+	for module, level := range overrides {
+		levels.setLocked(module, level)
+	}
+
+	log(ctx).Debugf("loaded %v log level overrides from %v", len(overrides), levels.fileName)
+
+	return nil
+}
+
+// readModuleLevelsFile reads a file with lines in the form 'module=level'.
+// Empty lines and lines starting with '#' are ignored.
+func readModuleLevelsFile(fname string) (map[string]string, error) {
+	f, err := os.Open(fname) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open log levels file")
+	}
+	defer f.Close() //nolint:errcheck
+
+	result := map[string]string{}
+
+	s := bufio.NewScanner(f)
+	for lineNo := 1; s.Scan(); lineNo++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 { //nolint:gomnd
+			return nil, errors.Errorf("malformed line %v of %v", lineNo, fname)
+		}
+
+		module, level := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if err := validateModuleLevel(module, level); err != nil {
+			return nil, errors.Wrapf(err, "line %v of %v", lineNo, fname)
+		}
+
+		result[module] = level
+	}
+
+	return result, errors.Wrap(s.Err(), "error reading log levels file")
+}
@@ -0,0 +1,46 @@
+package logfile
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	logging "github.com/op/go-logging"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestModuleLevels(t *testing.T) {
+	ctx := testlogging.Context(t)
+	fname := filepath.Join(t.TempDir(), "levels")
+
+	require.NoError(t, ioutil.WriteFile(fname, []byte("# comment\n\nkopia/repo=debug\n"), 0o600))
+	require.NoError(t, levels.reset(fname))
+
+	defer levels.reset("") //nolint:errcheck
+
+	l := logging.AddModuleLevel(&jsonBackend{w: ioutil.Discard})
+	l.SetLevel(logging.INFO, "")
+	levels.register(l)
+
+	require.Equal(t, logging.DEBUG, l.GetLevel("kopia/repo"))
+	require.Equal(t, logging.INFO, l.GetLevel("kopia/cli"))
+
+	require.Error(t, SetModuleLevel("kopia/cli", "verbose"))
+	require.Error(t, SetModuleLevel("", "debug"))
+	require.NoError(t, SetModuleLevel("kopia/cli", "error"))
+	require.Equal(t, logging.ERROR, l.GetLevel("kopia/cli"))
+	require.Equal(t, []ModuleLevel{{"kopia/cli", "error"}, {"kopia/repo", "debug"}}, ModuleLevels())
+
+	// reloading replaces all overrides.
+	require.NoError(t, ioutil.WriteFile(fname, []byte("kopia/server=warning\n"), 0o600))
+	require.NoError(t, ReloadModuleLevels(ctx))
+	require.Equal(t, []ModuleLevel{{"kopia/server", "warning"}}, ModuleLevels())
+	require.Equal(t, logging.INFO, l.GetLevel("kopia/cli"))
+	require.Equal(t, logging.INFO, l.GetLevel("kopia/repo"))
+	require.Equal(t, logging.WARNING, l.GetLevel("kopia/server"))
+
+	require.NoError(t, ioutil.WriteFile(fname, []byte("malformed\n"), 0o600))
+	require.Error(t, ReloadModuleLevels(ctx))
+}
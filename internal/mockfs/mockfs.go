@@ -8,6 +8,7 @@
 	"io"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/kopia/kopia/fs"
@@ -142,6 +143,23 @@ func (imd *Directory) AddDir(name string, permissions os.FileMode) *Directory {
 	return subdir
 }
 
+// AddSymlink adds a fake symbolic link with a given name, target and permissions.
+func (imd *Directory) AddSymlink(name, target string, permissions os.FileMode) fs.Symlink {
+	imd, name = imd.resolveSubdir(name)
+
+	sl := &inmemorySymlink{
+		entry: entry{
+			name: name,
+			mode: permissions | os.ModeSymlink,
+		},
+		target: target,
+	}
+
+	imd.addChild(sl)
+
+	return sl
+}
+
 // AddErrorEntry adds a fake directory with a given name and permissions.
 func (imd *Directory) AddErrorEntry(name string, permissions os.FileMode, err error) *ErrorEntry {
 	imd, name = imd.resolveSubdir(name)
@@ -271,6 +289,21 @@ func (imf *File) SetContents(b []byte) {
 	}
 }
 
+// FailOpen causes the next n attempts to open the file to fail with the provided error.
+func (imf *File) FailOpen(n int, err error) {
+	source := imf.source
+
+	var failures int32
+
+	imf.source = func() (ReaderSeekerCloser, error) {
+		if atomic.AddInt32(&failures, 1) <= int32(n) {
+			return nil, err
+		}
+
+		return source()
+	}
+}
+
 type fileReader struct {
 	ReaderSeekerCloser
 	entry fs.Entry
@@ -295,10 +328,11 @@ func (imf *File) Open(ctx context.Context) (fs.Reader, error) {
 
 type inmemorySymlink struct {
 	entry
+	target string
 }
 
 func (imsl *inmemorySymlink) Readlink(ctx context.Context) (string, error) {
-	panic("not implemented yet")
+	return imsl.target, nil
 }
 
 // NewDirectory returns new mock directory.
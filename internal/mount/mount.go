@@ -3,6 +3,7 @@
 
 import (
 	"context"
+	"time"
 
 	"github.com/kopia/kopia/repo/logging"
 )
@@ -25,4 +26,19 @@ type Options struct {
 	FuseAllowNonEmptyMount bool
 	// Use WebDAV even on platforms that support FUSE.
 	PreferWebDAV bool
+
+	// How long the kernel caches attributes and directory entries, 0 uses the default. Supported only on Fuse.
+	FuseAttrTimeout  time.Duration
+	FuseEntryTimeout time.Duration
+
+	// Maximum number of bytes the kernel reads ahead of the current file offset, 0 uses the default.
+	// Supported only on Fuse.
+	FuseMaxReadAhead int
+
+	// Maximum number of missing names remembered per directory, 0 disables the cache. Supported only on Fuse.
+	FuseNegativeLookupCacheSize int
+
+	// Local directory where modifications of the mounted files are stored, which makes the mount writable
+	// without modifying the repository. Supported only on Fuse.
+	ScratchDir string
 }
@@ -17,19 +17,28 @@
 )
 
 // we're serving read-only filesystem, cache some attributes for 30 seconds.
-var cacheTimeout = 30 * time.Second
+const defaultCacheTimeout = 30 * time.Second
+
+func durationOrDefault(v, def time.Duration) *time.Duration {
+	if v == 0 {
+		v = def
+	}
+
+	return &v
+}
 
 func (mo *Options) toFuseMountOptions() *gofusefs.Options {
 	o := &gofusefs.Options{
 		MountOptions: fuse.MountOptions{
-			AllowOther: mo.FuseAllowOther,
-			Name:       "kopia",
-			FsName:     "kopia",
-			Debug:      os.Getenv("KOPIA_DEBUG_FUSE") != "",
+			AllowOther:   mo.FuseAllowOther,
+			MaxReadAhead: mo.FuseMaxReadAhead,
+			Name:         "kopia",
+			FsName:       "kopia",
+			Debug:        os.Getenv("KOPIA_DEBUG_FUSE") != "",
 		},
-		EntryTimeout:    &cacheTimeout,
-		AttrTimeout:     &cacheTimeout,
-		NegativeTimeout: &cacheTimeout,
+		EntryTimeout:    durationOrDefault(mo.FuseEntryTimeout, defaultCacheTimeout),
+		AttrTimeout:     durationOrDefault(mo.FuseAttrTimeout, defaultCacheTimeout),
+		NegativeTimeout: durationOrDefault(mo.FuseEntryTimeout, defaultCacheTimeout),
 	}
 
 	o.Options = append(o.Options, "noatime")
@@ -56,10 +65,17 @@ func Directory(ctx context.Context, entry fs.Directory, mountPoint string, mount
 	}
 
 	if mountOptions.PreferWebDAV {
+		if mountOptions.ScratchDir != "" {
+			return nil, errors.Errorf("scratch directory is not supported with WebDAV")
+		}
+
 		return newPosixWedavController(ctx, entry, mountPoint, isTempDir)
 	}
 
-	rootNode := fusemount.NewDirectoryNode(entry)
+	rootNode := fusemount.NewDirectoryNode(entry, fusemount.Options{
+		MaxNegativeLookups: mountOptions.FuseNegativeLookupCacheSize,
+		ScratchDir:         mountOptions.ScratchDir,
+	})
 
 	fuseServer, err := gofusefs.Mount(mountPoint, rootNode, mountOptions.toFuseMountOptions())
 	if err != nil {
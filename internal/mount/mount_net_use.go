@@ -14,11 +14,15 @@
 )
 
 // Directory mounts a given directory under a provided drive letter.
-func Directory(ctx context.Context, entry fs.Directory, driveLetter string, _ Options) (Controller, error) {
+func Directory(ctx context.Context, entry fs.Directory, driveLetter string, mountOptions Options) (Controller, error) {
 	if !isValidWindowsDriveOrAsterisk(driveLetter) {
 		return nil, errors.Errorf("must be a valid drive letter or asteris")
 	}
 
+	if mountOptions.ScratchDir != "" {
+		return nil, errors.Errorf("scratch directory is not supported on Windows")
+	}
+
 	c, err := DirectoryWebDAV(ctx, entry)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,167 @@
+package providervalidation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Names of probes performed by CheckHealth.
+const (
+	ProbeList        = "list"
+	ProbeGetMetadata = "get-metadata"
+	ProbePut         = "put"
+	ProbeGet         = "get"
+	ProbeSetTime     = "set-time"
+	ProbeDelete      = "delete"
+)
+
+const healthProbeBlobSize = 1024
+
+// HealthProbe describes the result of a single operation performed by CheckHealth.
+type HealthProbe struct {
+	Operation string        `json:"operation"`
+	Latency   time.Duration `json:"latency"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// HealthReport describes the results of CheckHealth.
+type HealthReport struct {
+	ReadOnly     bool              `json:"readOnly"`
+	Probes       []HealthProbe     `json:"probes"`
+	Capabilities blob.Capabilities `json:"capabilities"`
+}
+
+// Healthy returns true if all probes succeeded.
+func (r *HealthReport) Healthy() bool {
+	for _, p := range r.Probes {
+		if p.Error != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckHealth performs a quick probe of the storage, measuring latency of basic blob operations
+// and verifying capabilities reported by the provider. When readOnly is true, the probe does not write,
+// otherwise a single small blob is written and deleted before the function returns.
+// Failures of individual operations are reported in HealthReport and don't cause an error.
+func CheckHealth(ctx context.Context, st blob.Storage, readOnly bool) *HealthReport {
+	r := &HealthReport{ReadOnly: readOnly, Capabilities: st.Capabilities()}
+	prefix := blob.ID(fmt.Sprintf("z-health-%x-", randomBytes(8))) //nolint:gomnd
+	id := prefix + "blob"
+
+	if readOnly {
+		r.probe(ctx, ProbeList, func() error {
+			return expectListResult(ctx, st, prefix, id, false)
+		})
+
+		r.probe(ctx, ProbeGetMetadata, func() error {
+			if _, err := st.GetMetadata(ctx, id); !errors.Is(err, blob.ErrBlobNotFound) {
+				return errors.Errorf("unexpected result when getting metadata of non-existent blob: %v", err)
+			}
+
+			return nil
+		})
+
+		return r
+	}
+
+	defer cleanupBlobs(ctx, st, prefix)
+
+	data := randomBytes(healthProbeBlobSize)
+
+	if !r.probe(ctx, ProbePut, func() error {
+		// nolint:wrapcheck
+		return st.PutBlob(ctx, id, gather.FromSlice(data))
+	}) {
+		return r
+	}
+
+	r.probe(ctx, ProbeGet, func() error {
+		got, err := st.GetBlob(ctx, id, 0, -1)
+		if err != nil {
+			return errors.Wrap(err, "error reading blob")
+		}
+
+		if !bytes.Equal(got, data) {
+			return errors.Errorf("invalid data returned")
+		}
+
+		return nil
+	})
+
+	r.probe(ctx, ProbeGetMetadata, func() error {
+		md, err := st.GetMetadata(ctx, id)
+		if err != nil {
+			return errors.Wrap(err, "error getting metadata")
+		}
+
+		if md.Length != int64(len(data)) {
+			return errors.Errorf("invalid length %v, expected %v", md.Length, len(data))
+		}
+
+		return nil
+	})
+
+	r.probe(ctx, ProbeList, func() error {
+		return expectListResult(ctx, st, prefix, id, true)
+	})
+
+	if r.Capabilities.SetTime {
+		r.probe(ctx, ProbeSetTime, func() error {
+			// nolint:wrapcheck
+			return st.SetTime(ctx, id, clock.Now())
+		})
+	}
+
+	r.probe(ctx, ProbeDelete, func() error {
+		// nolint:wrapcheck
+		return st.DeleteBlob(ctx, id)
+	})
+
+	return r
+}
+
+// probe runs the provided operation, recording its latency and result. Returns true on success.
+func (r *HealthReport) probe(ctx context.Context, op string, f func() error) bool {
+	t0 := clock.Now()
+	err := f()
+
+	p := HealthProbe{
+		Operation: op,
+		Latency:   clock.Now().Sub(t0),
+	}
+
+	if err != nil {
+		log(ctx).Debugf("health probe %v failed: %v", op, err)
+
+		p.Error = err.Error()
+	}
+
+	r.Probes = append(r.Probes, p)
+
+	return err == nil
+}
+
+// expectListResult performs a single listing and ensures it reflects the expected presence of the blob.
+func expectListResult(ctx context.Context, st blob.Storage, prefix, id blob.ID, want bool) error {
+	found, err := isListed(ctx, st, prefix, id)
+	if err != nil {
+		return err
+	}
+
+	if found != want {
+		return errors.Errorf("unexpected listing result for %v: found=%v, expected %v", id, found, want)
+	}
+
+	return nil
+}
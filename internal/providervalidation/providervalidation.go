@@ -0,0 +1,311 @@
+// Package providervalidation implements validation to ensure the blob storage is compatible with kopia requirements.
+package providervalidation
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("providervalidation")
+
+// Options provides options for provider validation.
+type Options struct {
+	// BlobPrefix is prepended to names of all blobs created during validation,
+	// a random prefix is used if empty.
+	BlobPrefix blob.ID
+
+	// Stress enables the stress phase, which runs after basic validation succeeds.
+	Stress bool
+
+	StressWorkers      int           // number of parallel workers
+	StressDuration     time.Duration // how long to run the stress phase
+	StressBlobSize     int           // size of blobs written during the stress phase
+	StressSharedBlobs  int           // number of blobs concurrently overwritten by all workers
+	ListAfterWriteWait time.Duration // how long to wait for a written blob to appear in listings
+}
+
+// DefaultOptions is the default set of options.
+// nolint:gomnd,gochecknoglobals
+var DefaultOptions = Options{
+	StressWorkers:      100,
+	StressDuration:     30 * time.Second,
+	StressBlobSize:     64 * 1024,
+	StressSharedBlobs:  10,
+	ListAfterWriteWait: 30 * time.Second,
+}
+
+// ValidateProvider runs a series of tests against provided storage to validate that
+// it can be used with kopia, returning the report of the stress phase if enabled.
+// All blobs created during validation are deleted before it returns.
+func ValidateProvider(ctx context.Context, st blob.Storage, opt Options) (*StressReport, error) {
+	if opt.BlobPrefix == "" {
+		opt.BlobPrefix = randomPrefix()
+	}
+
+	defer cleanupBlobs(ctx, st, opt.BlobPrefix)
+
+	log(ctx).Infof("Validating basic blob operations with prefix %v...", opt.BlobPrefix)
+
+	if opt.ListAfterWriteWait <= 0 {
+		opt.ListAfterWriteWait = DefaultOptions.ListAfterWriteWait
+	}
+
+	if err := validateBasicOperations(ctx, st, opt.BlobPrefix+"basic-", opt); err != nil {
+		return nil, errors.Wrap(err, "basic validation failed")
+	}
+
+	log(ctx).Infof("Validating timestamp precision...")
+
+	if err := validateTimestampPrecision(ctx, st, opt.BlobPrefix+"time-", opt); err != nil {
+		return nil, errors.Wrap(err, "timestamp validation failed")
+	}
+
+	if !opt.Stress {
+		return nil, nil
+	}
+
+	log(ctx).Infof("Running stress test with %v workers for %v...", opt.StressWorkers, opt.StressDuration)
+
+	report, err := runStress(ctx, st, opt.BlobPrefix+"stress-", opt)
+	if err != nil {
+		return report, errors.Wrap(err, "stress validation failed")
+	}
+
+	return report, nil
+}
+
+func validateBasicOperations(ctx context.Context, st blob.Storage, prefix blob.ID, opt Options) error {
+	const blobSize = 1000
+
+	id := prefix + "blob"
+	data := randomBytes(blobSize)
+
+	if _, err := st.GetBlob(ctx, id, 0, -1); !errors.Is(err, blob.ErrBlobNotFound) {
+		return errors.Errorf("unexpected result when getting non-existent blob: %v", err)
+	}
+
+	if _, err := st.GetMetadata(ctx, id); !errors.Is(err, blob.ErrBlobNotFound) {
+		return errors.Errorf("unexpected result when getting metadata of non-existent blob: %v", err)
+	}
+
+	if err := st.PutBlob(ctx, id, gather.FromSlice(data)); err != nil {
+		return errors.Wrap(err, "error writing blob")
+	}
+
+	if err := verifyBlob(ctx, st, id, data); err != nil {
+		return err
+	}
+
+	for _, r := range []struct{ offset, length int64 }{
+		{0, 1},
+		{0, blobSize},
+		{blobSize - 1, 1},
+		{blobSize / 2, blobSize / 4},
+		{blobSize, 0},
+	} {
+		got, err := st.GetBlob(ctx, id, r.offset, r.length)
+		if err != nil {
+			return errors.Wrapf(err, "error reading range %v+%v", r.offset, r.length)
+		}
+
+		if !bytes.Equal(got, data[r.offset:r.offset+r.length]) {
+			return errors.Errorf("invalid data returned for range %v+%v", r.offset, r.length)
+		}
+	}
+
+	if err := expectListed(ctx, st, prefix, id, true, opt.ListAfterWriteWait); err != nil {
+		return err
+	}
+
+	data = randomBytes(blobSize / 2)
+
+	if err := st.PutBlob(ctx, id, gather.FromSlice(data)); err != nil {
+		return errors.Wrap(err, "error overwriting blob")
+	}
+
+	if err := verifyBlob(ctx, st, id, data); err != nil {
+		return errors.Wrap(err, "after overwrite")
+	}
+
+	if err := st.DeleteBlob(ctx, id); err != nil {
+		return errors.Wrap(err, "error deleting blob")
+	}
+
+	if _, err := st.GetBlob(ctx, id, 0, -1); !errors.Is(err, blob.ErrBlobNotFound) {
+		return errors.Errorf("unexpected result when getting deleted blob: %v", err)
+	}
+
+	return expectListed(ctx, st, prefix, id, false, opt.ListAfterWriteWait)
+}
+
+// timestampPrecisions lists the precisions reported by validateTimestampPrecision, from finest to coarsest.
+// nolint:gochecknoglobals
+var timestampPrecisions = []time.Duration{
+	time.Nanosecond,
+	time.Microsecond,
+	time.Millisecond,
+	time.Second,
+}
+
+// validateTimestampPrecision ensures that timestamps set using SetTime are returned
+// without modifications beyond truncation to a precision supported by the provider, which is reported.
+func validateTimestampPrecision(ctx context.Context, st blob.Storage, prefix blob.ID, opt Options) error {
+	id := prefix + "blob"
+
+	if err := st.PutBlob(ctx, id, gather.FromSlice(randomBytes(1))); err != nil {
+		return errors.Wrap(err, "error writing blob")
+	}
+
+	// timestamp in the past with non-zero digits at all sub-second precisions.
+	want := clock.Now().Add(-24 * time.Hour).Truncate(time.Second).Add(123456789 * time.Nanosecond) //nolint:gomnd
+
+	if err := st.SetTime(ctx, id, want); err != nil {
+		if errors.Is(err, blob.ErrSetTimeUnsupported) {
+			log(ctx).Infof("Provider does not support setting blob timestamps.")
+			return nil
+		}
+
+		return errors.Wrap(err, "error setting blob time")
+	}
+
+	bm, err := st.GetMetadata(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "error getting blob metadata")
+	}
+
+	precision, ok := timestampPrecision(want, bm.Timestamp)
+	if !ok {
+		return errors.Errorf("unexpected timestamp after SetTime(): %v, want %v", bm.Timestamp, want)
+	}
+
+	log(ctx).Infof("Timestamp precision: %v", precision)
+
+	if err := expectListed(ctx, st, prefix, id, true, opt.ListAfterWriteWait); err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return st.ListBlobs(ctx, prefix, func(lbm blob.Metadata) error {
+		if lbm.BlobID == id && !lbm.Timestamp.Equal(bm.Timestamp) {
+			// kopia does not rely on this, but tools comparing listings across storages will see a different time.
+			log(ctx).Infof("Listing reports timestamp %v instead of %v returned by GetMetadata().", lbm.Timestamp, bm.Timestamp)
+		}
+
+		return nil
+	})
+}
+
+// timestampPrecision returns the finest precision at which the provided timestamps are equal.
+func timestampPrecision(want, got time.Time) (time.Duration, bool) {
+	for _, p := range timestampPrecisions {
+		if want.Truncate(p).Equal(got) {
+			return p, true
+		}
+	}
+
+	return 0, false
+}
+
+// verifyBlob ensures the blob and its metadata reflect the provided contents.
+func verifyBlob(ctx context.Context, st blob.Storage, id blob.ID, want []byte) error {
+	got, err := st.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return errors.Wrapf(err, "error reading %v", id)
+	}
+
+	if !bytes.Equal(got, want) {
+		return errors.Errorf("invalid data returned for %v", id)
+	}
+
+	md, err := st.GetMetadata(ctx, id)
+	if err != nil {
+		return errors.Wrapf(err, "error getting metadata of %v", id)
+	}
+
+	if md.BlobID != id || md.Length != int64(len(want)) {
+		return errors.Errorf("invalid metadata returned for %v: %v", id, md)
+	}
+
+	return nil
+}
+
+// expectListed waits until listing blobs with a given prefix reflects the expected presence of the blob.
+func expectListed(ctx context.Context, st blob.Storage, prefix, id blob.ID, want bool, wait time.Duration) error {
+	deadline := clock.Now().Add(wait)
+
+	for {
+		found, err := isListed(ctx, st, prefix, id)
+		if err != nil {
+			return err
+		}
+
+		if found == want {
+			return nil
+		}
+
+		if !clock.Now().Before(deadline) {
+			return errors.Errorf("unexpected listing result for %v after %v: found=%v, expected %v", id, wait, found, want)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "interrupted while waiting for listing")
+		case <-time.After(listAfterWritePollInterval):
+		}
+	}
+}
+
+func isListed(ctx context.Context, st blob.Storage, prefix, id blob.ID) (bool, error) {
+	found := false
+
+	if err := st.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+		if bm.BlobID == id {
+			found = true
+		}
+
+		return nil
+	}); err != nil {
+		return false, errors.Wrapf(err, "error listing blobs with prefix %v", prefix)
+	}
+
+	return found, nil
+}
+
+func cleanupBlobs(ctx context.Context, st blob.Storage, prefix blob.ID) {
+	var ids []blob.ID
+
+	if err := st.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+		ids = append(ids, bm.BlobID)
+		return nil
+	}); err != nil {
+		log(ctx).Errorf("unable to list blobs to clean up: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		if err := st.DeleteBlob(ctx, id); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			log(ctx).Errorf("unable to delete %v: %v", id, err)
+		}
+	}
+}
+
+func randomPrefix() blob.ID {
+	return blob.ID(fmt.Sprintf("z-validate-%x-", randomBytes(8))) //nolint:gomnd
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b) //nolint:errcheck
+
+	return b
+}
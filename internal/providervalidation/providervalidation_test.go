@@ -0,0 +1,188 @@
+package providervalidation_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/providervalidation"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestProviderValidation(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	report, err := providervalidation.ValidateProvider(ctx, st, providervalidation.Options{})
+	require.NoError(t, err)
+	require.Nil(t, report)
+	require.Empty(t, data)
+}
+
+func TestProviderValidationStress(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	opt := providervalidation.DefaultOptions
+	opt.Stress = true
+	opt.StressWorkers = 10
+	opt.StressDuration = 500 * time.Millisecond
+	opt.StressBlobSize = 100
+
+	report, err := providervalidation.ValidateProvider(ctx, st, opt)
+	require.NoError(t, err)
+	require.False(t, report.Failed())
+	require.Empty(t, data)
+
+	for _, op := range []string{
+		providervalidation.OpPut,
+		providervalidation.OpGet,
+		providervalidation.OpOverwrite,
+		providervalidation.OpGetShared,
+		providervalidation.OpListAfterWrite,
+	} {
+		require.NotZero(t, report.Operations[op].Count, op)
+	}
+
+	require.Equal(t, report.ListAfterWrite.Samples, report.ListAfterWrite.Immediate)
+
+	var buf bytes.Buffer
+
+	_, err = report.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "Inconsistencies: 0")
+}
+
+func TestProviderValidationStressFailures(t *testing.T) {
+	ctx := testlogging.Context(t)
+	base := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+
+	opt := providervalidation.DefaultOptions
+	opt.BlobPrefix = "validate-"
+	opt.Stress = true
+	opt.StressWorkers = 5
+	opt.StressDuration = 200 * time.Millisecond
+	opt.StressBlobSize = 100
+
+	// basic validation catches corruption before the stress phase.
+	report, err := providervalidation.ValidateProvider(ctx, &corruptingStorage{base, "validate-"}, opt)
+	require.Error(t, err)
+	require.Nil(t, report)
+
+	// corruption of blobs written by the stress phase is reported.
+	report, err = providervalidation.ValidateProvider(ctx, &corruptingStorage{base, "validate-stress-"}, opt)
+	require.Error(t, err)
+	require.True(t, report.Failed())
+	require.NotZero(t, report.InconsistencyCount)
+}
+
+func TestProviderValidationListAfterWriteDelay(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := blobtesting.NewMapStorageWithProfile(blobtesting.DataMap{}, nil, nil, blobtesting.MapStorageProfile{
+		ListDelay: 300 * time.Millisecond,
+	})
+
+	opt := providervalidation.DefaultOptions
+	opt.Stress = true
+	opt.StressWorkers = 5
+	opt.StressDuration = time.Second
+	opt.StressBlobSize = 100
+
+	report, err := providervalidation.ValidateProvider(ctx, st, opt)
+	require.NoError(t, err)
+	require.NotZero(t, report.ListAfterWrite.Samples)
+	require.Zero(t, report.ListAfterWrite.Immediate)
+	require.GreaterOrEqual(t, report.ListAfterWrite.MaxDelay, 300*time.Millisecond)
+}
+
+// corruptingStorage flips a byte of each blob with a given prefix it returns.
+type corruptingStorage struct {
+	blob.Storage
+	prefix blob.ID
+}
+
+func (s *corruptingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	b, err := s.Storage.GetBlob(ctx, id, offset, length)
+	if err == nil && len(b) > 0 && strings.HasPrefix(string(id), string(s.prefix)) {
+		b[0] ^= 1
+	}
+
+	return b, err
+}
+
+func TestProviderValidationTimestampPrecision(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	// truncation to a coarser precision is acceptable.
+	_, err := providervalidation.ValidateProvider(ctx, &timeAdjustingStorage{
+		Storage: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+		adjust:  func(t time.Time) time.Time { return t.Truncate(time.Second) },
+	}, providervalidation.Options{})
+	require.NoError(t, err)
+
+	// other changes are not.
+	_, err = providervalidation.ValidateProvider(ctx, &timeAdjustingStorage{
+		Storage: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+		adjust:  func(t time.Time) time.Time { return t.Add(time.Second) },
+	}, providervalidation.Options{})
+	require.Error(t, err)
+}
+
+// timeAdjustingStorage modifies times passed to SetTime.
+type timeAdjustingStorage struct {
+	blob.Storage
+	adjust func(t time.Time) time.Time
+}
+
+func (s *timeAdjustingStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	return s.Storage.SetTime(ctx, id, s.adjust(t))
+}
+
+func TestCheckHealth(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	r := providervalidation.CheckHealth(ctx, st, false)
+	require.True(t, r.Healthy())
+	require.True(t, r.Capabilities.SetTime)
+	require.Empty(t, data)
+
+	var ops []string
+	for _, p := range r.Probes {
+		ops = append(ops, p.Operation)
+	}
+
+	require.Equal(t, []string{
+		providervalidation.ProbePut,
+		providervalidation.ProbeGet,
+		providervalidation.ProbeGetMetadata,
+		providervalidation.ProbeList,
+		providervalidation.ProbeSetTime,
+		providervalidation.ProbeDelete,
+	}, ops)
+
+	// read-only probe does not write anything.
+	r = providervalidation.CheckHealth(ctx, st, true)
+	require.True(t, r.Healthy())
+	require.True(t, r.Capabilities.SetTime)
+	require.False(t, r.Capabilities.Versioning)
+	require.Len(t, r.Probes, 2)
+
+	// capabilities are reported by the provider.
+	r = providervalidation.CheckHealth(ctx, blobtesting.NewVersionedMapStorage(blobtesting.DataMap{}, nil, nil), true)
+	require.True(t, r.Capabilities.Versioning)
+
+	// corruption is reported as a failed probe.
+	r = providervalidation.CheckHealth(ctx, &corruptingStorage{st, "z-health-"}, false)
+	require.False(t, r.Healthy())
+	require.Empty(t, data)
+}
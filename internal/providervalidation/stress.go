@@ -0,0 +1,537 @@
+package providervalidation
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Names of operations performed during the stress phase.
+const (
+	OpPut            = "put"
+	OpGet            = "get"
+	OpDelete         = "delete"
+	OpOverwrite      = "overwrite-shared"
+	OpGetShared      = "get-shared"
+	OpListAfterWrite = "list-after-write"
+)
+
+const (
+	maxReportedInconsistencies = 100
+	listAfterWritePollInterval = 100 * time.Millisecond
+)
+
+// OperationStats summarizes executions of a single operation during the stress phase.
+type OperationStats struct {
+	Count        int           `json:"count"`
+	Errors       int           `json:"errors"`
+	TotalLatency time.Duration `json:"totalLatency"`
+	MaxLatency   time.Duration `json:"maxLatency"`
+}
+
+// AverageLatency returns the average latency of the operation.
+func (s *OperationStats) AverageLatency() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return s.TotalLatency / time.Duration(s.Count)
+}
+
+// ListAfterWriteStats summarizes the time it took for written blobs to appear in listings.
+type ListAfterWriteStats struct {
+	Samples    int           `json:"samples"`
+	Immediate  int           `json:"immediate"`
+	Timeouts   int           `json:"timeouts"`
+	TotalDelay time.Duration `json:"totalDelay"`
+	MaxDelay   time.Duration `json:"maxDelay"`
+}
+
+// AverageDelay returns the average delay before written blobs appeared in listings.
+func (s *ListAfterWriteStats) AverageDelay() time.Duration {
+	if s.Samples == 0 {
+		return 0
+	}
+
+	return s.TotalDelay / time.Duration(s.Samples)
+}
+
+// StressReport describes the results of the stress phase.
+type StressReport struct {
+	Workers        int                        `json:"workers"`
+	Duration       time.Duration              `json:"duration"`
+	Operations     map[string]*OperationStats `json:"operations"`
+	ListAfterWrite ListAfterWriteStats        `json:"listAfterWrite"`
+
+	// Inconsistencies lists (up to 100) cases where the storage returned
+	// data or results inconsistent with previously completed operations.
+	Inconsistencies    []string `json:"inconsistencies,omitempty"`
+	InconsistencyCount int      `json:"inconsistencyCount"`
+
+	// FirstErrors lists (up to 100) errors returned by the storage.
+	FirstErrors []string `json:"firstErrors,omitempty"`
+	ErrorCount  int      `json:"errorCount"`
+
+	mu sync.Mutex
+	// hashes of all contents written to each shared blob
+	sharedBlobContents map[blob.ID]map[string]bool
+	// shared blobs which have been successfully written at least once
+	sharedBlobsWritten map[blob.ID]bool
+}
+
+// Failed returns true if the stress phase encountered errors, inconsistencies or
+// blobs that did not appear in listings in time.
+func (r *StressReport) Failed() bool {
+	return r.ErrorCount > 0 || r.InconsistencyCount > 0 || r.ListAfterWrite.Timeouts > 0
+}
+
+// WriteTo writes human-readable report to the provided writer.
+func (r *StressReport) WriteTo(w io.Writer) (int64, error) {
+	var b []byte
+
+	printf := func(msg string, args ...interface{}) {
+		b = append(b, fmt.Sprintf(msg, args...)...)
+	}
+
+	printf("Stress test with %v workers ran for %v.\n\n", r.Workers, r.Duration.Round(time.Millisecond))
+	printf("%-18v %8v %8v %12v %12v\n", "Operation", "Count", "Errors", "Avg Latency", "Max Latency")
+
+	var ops []string
+	for op := range r.Operations {
+		ops = append(ops, op)
+	}
+
+	sort.Strings(ops)
+
+	for _, op := range ops {
+		s := r.Operations[op]
+		printf("%-18v %8v %8v %12v %12v\n", op, s.Count, s.Errors, s.AverageLatency().Round(time.Microsecond), s.MaxLatency.Round(time.Microsecond))
+	}
+
+	law := r.ListAfterWrite
+	printf("\nList-after-write: %v samples, %v visible immediately, %v timed out, average delay %v, max delay %v\n",
+		law.Samples, law.Immediate, law.Timeouts, law.AverageDelay().Round(time.Millisecond), law.MaxDelay.Round(time.Millisecond))
+
+	printf("Inconsistencies: %v\n", r.InconsistencyCount)
+
+	for _, s := range r.Inconsistencies {
+		printf("  %v\n", s)
+	}
+
+	printf("Errors: %v\n", r.ErrorCount)
+
+	for _, s := range r.FirstErrors {
+		printf("  %v\n", s)
+	}
+
+	n, err := w.Write(b)
+
+	return int64(n), errors.Wrap(err, "error writing report")
+}
+
+func (r *StressReport) recordOperation(op string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.Operations[op]
+	if s == nil {
+		s = &OperationStats{}
+		r.Operations[op] = s
+	}
+
+	s.Count++
+	s.TotalLatency += latency
+
+	if latency > s.MaxLatency {
+		s.MaxLatency = latency
+	}
+
+	if err != nil {
+		s.Errors++
+		r.ErrorCount++
+
+		if len(r.FirstErrors) < maxReportedInconsistencies {
+			r.FirstErrors = append(r.FirstErrors, fmt.Sprintf("%v: %v", op, err))
+		}
+	}
+}
+
+func (r *StressReport) recordInconsistency(msg string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.InconsistencyCount++
+
+	if len(r.Inconsistencies) < maxReportedInconsistencies {
+		r.Inconsistencies = append(r.Inconsistencies, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (r *StressReport) recordListAfterWrite(delay time.Duration, timedOut bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	law := &r.ListAfterWrite
+
+	if timedOut {
+		law.Timeouts++
+		return
+	}
+
+	law.Samples++
+	law.TotalDelay += delay
+
+	if delay == 0 {
+		law.Immediate++
+	}
+
+	if delay > law.MaxDelay {
+		law.MaxDelay = delay
+	}
+}
+
+// addSharedContents registers contents which may be returned when reading a shared blob.
+func (r *StressReport) addSharedContents(id blob.ID, h string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sharedBlobContents[id] == nil {
+		r.sharedBlobContents[id] = map[string]bool{}
+	}
+
+	r.sharedBlobContents[id][h] = true
+}
+
+// sharedBlobWritten marks the shared blob as successfully written at least once.
+func (r *StressReport) sharedBlobWritten(id blob.ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sharedBlobsWritten[id] = true
+}
+
+func (r *StressReport) isSharedBlobWritten(id blob.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sharedBlobsWritten[id]
+}
+
+func (r *StressReport) isSharedContents(id blob.ID, h string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sharedBlobContents[id][h]
+}
+
+// stressWorker performs random operations on its own blobs and on blobs shared by all workers.
+type stressWorker struct {
+	st     blob.Storage
+	opt    Options
+	prefix blob.ID
+	report *StressReport
+	rnd    *rand.Rand
+
+	nextSeq int
+	blobs   map[blob.ID][]byte // own blobs and their contents
+	blobIDs []blob.ID
+}
+
+func runStress(ctx context.Context, st blob.Storage, prefix blob.ID, opt Options) (*StressReport, error) {
+	if opt.StressWorkers <= 0 {
+		opt.StressWorkers = DefaultOptions.StressWorkers
+	}
+
+	if opt.StressBlobSize <= 0 {
+		opt.StressBlobSize = DefaultOptions.StressBlobSize
+	}
+
+	if opt.StressSharedBlobs <= 0 {
+		opt.StressSharedBlobs = DefaultOptions.StressSharedBlobs
+	}
+
+	if opt.ListAfterWriteWait <= 0 {
+		opt.ListAfterWriteWait = DefaultOptions.ListAfterWriteWait
+	}
+
+	report := &StressReport{
+		Workers:            opt.StressWorkers,
+		Operations:         map[string]*OperationStats{},
+		sharedBlobContents: map[blob.ID]map[string]bool{},
+		sharedBlobsWritten: map[blob.ID]bool{},
+	}
+
+	start := clock.Now()
+	deadline := start.Add(opt.StressDuration)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < opt.StressWorkers; i++ {
+		w := &stressWorker{
+			st:     st,
+			opt:    opt,
+			prefix: prefix,
+			report: report,
+			rnd:    rand.New(rand.NewSource(clock.Now().UnixNano() + int64(i))), //nolint:gosec
+			blobs:  map[blob.ID][]byte{},
+		}
+
+		wid := i
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			w.run(ctx, wid, deadline)
+		}()
+	}
+
+	wg.Wait()
+
+	report.Duration = clock.Since(start)
+
+	if err := ctx.Err(); err != nil {
+		return report, errors.Wrap(err, "stress test interrupted")
+	}
+
+	if report.Failed() {
+		return report, errors.Errorf("stress test found %v errors, %v inconsistencies and %v list-after-write timeouts",
+			report.ErrorCount, report.InconsistencyCount, report.ListAfterWrite.Timeouts)
+	}
+
+	return report, nil
+}
+
+func (w *stressWorker) run(ctx context.Context, workerID int, deadline time.Time) {
+	const pcnt = 100
+
+	for ctx.Err() == nil && clock.Now().Before(deadline) {
+		switch n := w.rnd.Intn(pcnt); {
+		case n < 30: //nolint:gomnd
+			w.put(ctx, workerID)
+		case n < 50: //nolint:gomnd
+			w.get(ctx)
+		case n < 60: //nolint:gomnd
+			w.delete(ctx)
+		case n < 75: //nolint:gomnd
+			w.overwriteShared(ctx)
+		case n < 95: //nolint:gomnd
+			w.getShared(ctx)
+		default:
+			w.listAfterWrite(ctx, workerID)
+		}
+	}
+}
+
+// timed runs the provided function and records its latency and error.
+func (w *stressWorker) timed(op string, f func() error) error {
+	t0 := clock.Now()
+	err := f()
+	w.report.recordOperation(op, clock.Since(t0), err)
+
+	return err
+}
+
+func (w *stressWorker) newBlobID(workerID int) blob.ID {
+	w.nextSeq++
+
+	return w.prefix + blob.ID(fmt.Sprintf("w%v-%v", workerID, w.nextSeq))
+}
+
+func (w *stressWorker) randomData() []byte {
+	b := make([]byte, w.opt.StressBlobSize)
+	w.rnd.Read(b) //nolint:errcheck
+
+	return b
+}
+
+func (w *stressWorker) put(ctx context.Context, workerID int) {
+	id := w.newBlobID(workerID)
+	data := w.randomData()
+
+	if err := w.timed(OpPut, func() error {
+		return w.st.PutBlob(ctx, id, gather.FromSlice(data))
+	}); err != nil {
+		return
+	}
+
+	w.blobs[id] = data
+	w.blobIDs = append(w.blobIDs, id)
+}
+
+func (w *stressWorker) randomOwnBlob() (blob.ID, bool) {
+	if len(w.blobIDs) == 0 {
+		return "", false
+	}
+
+	return w.blobIDs[w.rnd.Intn(len(w.blobIDs))], true
+}
+
+func (w *stressWorker) get(ctx context.Context) {
+	id, ok := w.randomOwnBlob()
+	if !ok {
+		return
+	}
+
+	var got []byte
+
+	if err := w.timed(OpGet, func() error {
+		var err error
+		got, err = w.st.GetBlob(ctx, id, 0, -1)
+
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			w.report.recordInconsistency("blob %v not found after it was written", id)
+			return nil
+		}
+
+		return err
+	}); err != nil || got == nil {
+		return
+	}
+
+	if sha(got) != sha(w.blobs[id]) {
+		w.report.recordInconsistency("blob %v returned data different from what was written", id)
+	}
+}
+
+func (w *stressWorker) delete(ctx context.Context) {
+	id, ok := w.randomOwnBlob()
+	if !ok {
+		return
+	}
+
+	if err := w.timed(OpDelete, func() error {
+		return w.st.DeleteBlob(ctx, id)
+	}); err != nil {
+		return
+	}
+
+	delete(w.blobs, id)
+
+	for i, bid := range w.blobIDs {
+		if bid == id {
+			w.blobIDs = append(w.blobIDs[:i], w.blobIDs[i+1:]...)
+			break
+		}
+	}
+
+	if _, err := w.st.GetBlob(ctx, id, 0, -1); !errors.Is(err, blob.ErrBlobNotFound) {
+		w.report.recordInconsistency("blob %v still readable after it was deleted (err=%v)", id, err)
+	}
+}
+
+func (w *stressWorker) sharedBlobID() blob.ID {
+	return w.prefix + blob.ID(fmt.Sprintf("shared-%v", w.rnd.Intn(w.opt.StressSharedBlobs)))
+}
+
+func (w *stressWorker) overwriteShared(ctx context.Context) {
+	id := w.sharedBlobID()
+	data := w.randomData()
+
+	// register contents before writing, since concurrent readers may observe them
+	// as soon as the write begins.
+	w.report.addSharedContents(id, sha(data))
+
+	if err := w.timed(OpOverwrite, func() error {
+		return w.st.PutBlob(ctx, id, gather.FromSlice(data))
+	}); err != nil {
+		return
+	}
+
+	w.report.sharedBlobWritten(id)
+}
+
+func (w *stressWorker) getShared(ctx context.Context) {
+	id := w.sharedBlobID()
+
+	var got []byte
+
+	writtenBefore := w.report.isSharedBlobWritten(id)
+
+	if err := w.timed(OpGetShared, func() error {
+		var err error
+		got, err = w.st.GetBlob(ctx, id, 0, -1)
+
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			if writtenBefore {
+				w.report.recordInconsistency("shared blob %v not found after it was written", id)
+			}
+
+			return nil
+		}
+
+		return err
+	}); err != nil || got == nil {
+		return
+	}
+
+	if !w.report.isSharedContents(id, sha(got)) {
+		w.report.recordInconsistency("shared blob %v returned data that was never written (torn or mixed write?)", id)
+	}
+}
+
+func (w *stressWorker) listAfterWrite(ctx context.Context, workerID int) {
+	id := w.newBlobID(workerID)
+	data := w.randomData()
+
+	if err := w.timed(OpListAfterWrite, func() error {
+		return w.st.PutBlob(ctx, id, gather.FromSlice(data))
+	}); err != nil {
+		return
+	}
+
+	w.blobs[id] = data
+	w.blobIDs = append(w.blobIDs, id)
+
+	written := clock.Now()
+
+	for attempt := 0; ; attempt++ {
+		found, err := isListed(ctx, w.st, id, id)
+		if err != nil {
+			w.report.recordOperation(OpListAfterWrite, 0, err)
+			return
+		}
+
+		delay := clock.Since(written)
+
+		if found {
+			if attempt == 0 {
+				delay = 0
+			}
+
+			w.report.recordListAfterWrite(delay, false)
+
+			return
+		}
+
+		if delay >= w.opt.ListAfterWriteWait {
+			w.report.recordListAfterWrite(0, true)
+			w.report.recordInconsistency("blob %v not listed %v after it was written", id, w.opt.ListAfterWriteWait)
+
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listAfterWritePollInterval):
+		}
+	}
+}
+
+func sha(b []byte) string {
+	h := sha256.Sum256(b)
+	return string(h[:])
+}
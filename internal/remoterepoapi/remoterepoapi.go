@@ -15,6 +15,9 @@ type Parameters struct {
 	HMACSecret   []byte `json:"hmacSecret"`
 
 	object.Format
+
+	// ClientDefaults contains JSON-encoded client defaults published by the repository (repo.ClientDefaults).
+	ClientDefaults json.RawMessage `json:"clientDefaults,omitempty"`
 }
 
 // GetHashFunction returns the name of the hash function for remote repository.
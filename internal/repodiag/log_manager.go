@@ -0,0 +1,266 @@
+// Package repodiag manages diagnostic logs uploaded to the repository.
+package repodiag
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// LogBlobPrefix is the prefix of blobs holding diagnostic logs.
+const LogBlobPrefix blob.ID = "_log_"
+
+const (
+	logKeySize = 32
+
+	// maximum number of bytes buffered between uploads, logs written beyond that are dropped.
+	maxBufferedLogBytes = 16 << 20
+
+	sessionIDLength = 8
+)
+
+var (
+	logKeyPurpose    = []byte("diagnostic logs")
+	logAEADExtraData = []byte("logs")
+)
+
+// LogManager buffers log output of a long-running process and uploads it to the repository
+// as encrypted log blobs.
+type LogManager struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	startTime time.Time
+	endTime   time.Time
+	dropped   int
+	sessionID string
+	nextSeq   int
+	timeNow   func() time.Time
+}
+
+// NewLogManager returns a LogManager for a new logging session, which uses the provided
+// time function (clock.Now if nil) to determine the time range of uploaded logs.
+func NewLogManager(timeNow func() time.Time) *LogManager {
+	b := make([]byte, sessionIDLength)
+	rand.Read(b) //nolint:errcheck
+
+	if timeNow == nil {
+		timeNow = clock.Now
+	}
+
+	return &LogManager{
+		sessionID: hex.EncodeToString(b),
+		timeNow:   timeNow,
+	}
+}
+
+// SessionID returns the identifier of the logging session.
+func (m *LogManager) SessionID() string {
+	return m.sessionID
+}
+
+// Write implements io.Writer, each write should contain one or more complete log lines.
+func (m *LogManager) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.buf.Len()+len(p) > maxBufferedLogBytes {
+		m.dropped++
+		return len(p), nil
+	}
+
+	now := m.timeNow()
+	if m.buf.Len() == 0 {
+		m.startTime = now
+	}
+
+	m.endTime = now
+
+	// nolint:wrapcheck
+	return m.buf.Write(p)
+}
+
+// Flush uploads buffered logs to the repository.
+func (m *LogManager) Flush(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	m.mu.Lock()
+
+	if m.buf.Len() == 0 {
+		m.mu.Unlock()
+		return nil
+	}
+
+	if m.dropped > 0 {
+		fmt.Fprintf(&m.buf, "%v (%v log writes dropped due to buffer overflow)\n", m.timeNow().Format(time.RFC3339Nano), m.dropped) //nolint:errcheck
+		m.dropped = 0
+	}
+
+	data := append([]byte(nil), m.buf.Bytes()...)
+	info := LogBlobInfo{
+		StartTime: m.startTime.Truncate(time.Second),
+		EndTime:   m.endTime.Truncate(time.Second).Add(time.Second),
+		SessionID: m.sessionID,
+		Seq:       m.nextSeq,
+	}
+
+	m.buf.Reset()
+	m.nextSeq++
+	m.mu.Unlock()
+
+	encrypted, err := encryptLogs(rep.DeriveKey(logKeyPurpose, logKeySize), data)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(rep.BlobStorage().PutBlob(ctx, info.blobID(), gather.FromSlice(encrypted)), "error writing log blob")
+}
+
+// LogBlobInfo describes a single blob of diagnostic logs.
+type LogBlobInfo struct {
+	BlobID    blob.ID   `json:"id"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	SessionID string    `json:"sessionID"`
+	Seq       int       `json:"seq"`
+	Length    int64     `json:"length"`
+}
+
+func (i LogBlobInfo) blobID() blob.ID {
+	return blob.ID(fmt.Sprintf("%v%v_%v_%v_%v", LogBlobPrefix, i.StartTime.Unix(), i.EndTime.Unix(), i.SessionID, i.Seq))
+}
+
+// parseLogBlobID parses the ID of a log blob in the form _log_<start>_<end>_<session>_<seq>.
+func parseLogBlobID(id blob.ID) (LogBlobInfo, bool) {
+	parts := strings.Split(strings.TrimPrefix(string(id), string(LogBlobPrefix)), "_")
+	if len(parts) != 4 { //nolint:gomnd
+		return LogBlobInfo{}, false
+	}
+
+	start, err1 := strconv.ParseInt(parts[0], 10, 64)
+	end, err2 := strconv.ParseInt(parts[1], 10, 64)
+	seq, err3 := strconv.Atoi(parts[3])
+
+	if err1 != nil || err2 != nil || err3 != nil {
+		return LogBlobInfo{}, false
+	}
+
+	return LogBlobInfo{
+		BlobID:    id,
+		StartTime: time.Unix(start, 0),
+		EndTime:   time.Unix(end, 0),
+		SessionID: parts[2],
+		Seq:       seq,
+	}, true
+}
+
+// ListLogBlobs returns all log blobs in the repository sorted by their start time.
+func ListLogBlobs(ctx context.Context, st blob.Reader) ([]LogBlobInfo, error) {
+	var result []LogBlobInfo
+
+	if err := st.ListBlobs(ctx, LogBlobPrefix, func(bm blob.Metadata) error {
+		if li, ok := parseLogBlobID(bm.BlobID); ok {
+			li.Length = bm.Length
+			result = append(result, li)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error listing log blobs")
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if a, b := result[i].StartTime, result[j].StartTime; !a.Equal(b) {
+			return a.Before(b)
+		}
+
+		return result[i].Seq < result[j].Seq
+	})
+
+	return result, nil
+}
+
+// ReadLogBlob returns decrypted contents of the provided log blob.
+func ReadLogBlob(ctx context.Context, rep repo.DirectRepository, id blob.ID) ([]byte, error) {
+	v, err := rep.BlobReader().GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading log blob %v", id)
+	}
+
+	return decryptLogs(rep.DeriveKey(logKeyPurpose, logKeySize), v)
+}
+
+func getLogsAES256GCM(key []byte) (cipher.AEAD, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AES-256 cipher")
+	}
+
+	// nolint:wrapcheck
+	return cipher.NewGCM(c)
+}
+
+func encryptLogs(key, data []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return nil, errors.Wrap(err, "unable to compress logs")
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "unable to compress logs")
+	}
+
+	c, err := getLogsAES256GCM(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get cipher")
+	}
+
+	nonce := make([]byte, c.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "unable to initialize nonce")
+	}
+
+	return c.Seal(append([]byte(nil), nonce...), nonce, compressed.Bytes(), logAEADExtraData), nil
+}
+
+func decryptLogs(key, v []byte) ([]byte, error) {
+	c, err := getLogsAES256GCM(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get cipher")
+	}
+
+	if len(v) < c.NonceSize() {
+		return nil, errors.Errorf("invalid log blob")
+	}
+
+	compressed, err := c.Open(nil, v[0:c.NonceSize()], v[c.NonceSize():], logAEADExtraData)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt log blob")
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decompress log blob")
+	}
+
+	data, err := ioutil.ReadAll(zr)
+
+	return data, errors.Wrap(err, "unable to decompress log blob")
+}
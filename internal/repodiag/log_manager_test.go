@@ -0,0 +1,132 @@
+package repodiag_test
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/repodiag"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+)
+
+func TestLogManager(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	t0 := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+	ta := faketime.NewTimeAdvance(t0, 0)
+
+	lm1 := repodiag.NewLogManager(ta.NowFunc())
+	lm2 := repodiag.NewLogManager(ta.NowFunc())
+
+	// nothing to flush
+	require.NoError(t, lm1.Flush(ctx, env.RepositoryWriter))
+
+	writeLogLine(t, lm1, ta, "I kopia/server starting server")
+	ta.Advance(time.Minute)
+	writeLogLine(t, lm1, ta, "E kopia/server error uploading blob abc123")
+	require.NoError(t, lm1.Flush(ctx, env.RepositoryWriter))
+
+	ta.Advance(time.Minute)
+	writeLogLine(t, lm2, ta, "I kopia/server another session")
+	require.NoError(t, lm2.Flush(ctx, env.RepositoryWriter))
+
+	ta.Advance(time.Minute)
+	writeLogLine(t, lm1, ta, "D kopia/repo error uploading blob def456")
+	require.NoError(t, lm1.Flush(ctx, env.RepositoryWriter))
+
+	blobs, err := repodiag.ListLogBlobs(ctx, env.RepositoryWriter.BlobReader())
+	require.NoError(t, err)
+	require.Len(t, blobs, 3)
+
+	require.Equal(t, []string{
+		"starting server",
+		"error uploading blob abc123",
+		"another session",
+		"error uploading blob def456",
+	}, searchLogs(ctx, t, env.RepositoryWriter, repodiag.SearchOptions{}))
+
+	require.Equal(t, []string{
+		"error uploading blob abc123",
+		"error uploading blob def456",
+	}, searchLogs(ctx, t, env.RepositoryWriter, repodiag.SearchOptions{Text: "uploading"}))
+
+	require.Equal(t, []string{
+		"error uploading blob def456",
+	}, searchLogs(ctx, t, env.RepositoryWriter, repodiag.SearchOptions{Regexp: regexp.MustCompile(`blob d[a-f0-9]+$`)}))
+
+	require.Equal(t, []string{
+		"another session",
+	}, searchLogs(ctx, t, env.RepositoryWriter, repodiag.SearchOptions{SessionID: lm2.SessionID()}))
+
+	require.Equal(t, []string{
+		"error uploading blob abc123",
+		"another session",
+	}, searchLogs(ctx, t, env.RepositoryWriter, repodiag.SearchOptions{
+		MinTime: t0.Add(time.Minute),
+		MaxTime: t0.Add(3 * time.Minute),
+	}))
+}
+
+func TestCleanupLogs(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	ta := faketime.NewTimeAdvance(time.Now(), 0)
+
+	for i := 0; i < 5; i++ {
+		lm := repodiag.NewLogManager(ta.NowFunc())
+		writeLogLine(t, lm, ta, fmt.Sprintf("I kopia/server session %v", i))
+		require.NoError(t, lm.Flush(ctx, env.RepositoryWriter))
+	}
+
+	deleted, err := repodiag.CleanupLogs(ctx, env.RepositoryWriter, repodiag.DefaultLogRetention())
+	require.NoError(t, err)
+	require.Empty(t, deleted)
+
+	deleted, err = repodiag.CleanupLogs(ctx, env.RepositoryWriter, repodiag.LogRetentionOptions{MaxCount: 3})
+	require.NoError(t, err)
+	require.Len(t, deleted, 2)
+
+	blobs, err := repodiag.ListLogBlobs(ctx, env.RepositoryWriter.BlobReader())
+	require.NoError(t, err)
+	require.Len(t, blobs, 3)
+
+	deleted, err = repodiag.CleanupLogs(ctx, env.RepositoryWriter, repodiag.LogRetentionOptions{MaxTotalSize: blobs[0].Length})
+	require.NoError(t, err)
+	require.Len(t, deleted, 2)
+
+	// logs older than max age are deleted.
+	env.MustReopen(t, func(o *repo.Options) {
+		o.TimeNowFunc = func() time.Time { return time.Now().Add(60 * 24 * time.Hour) }
+	})
+
+	deleted, err = repodiag.CleanupLogs(ctx, env.RepositoryWriter, repodiag.LogRetentionOptions{MaxAge: 30 * 24 * time.Hour})
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+}
+
+func writeLogLine(t *testing.T, lm *repodiag.LogManager, ta *faketime.TimeAdvance, text string) {
+	t.Helper()
+
+	_, err := fmt.Fprintf(lm, "%v %v\n", ta.NowFunc()().Format("2006-01-02T15:04:05.000Z07:00"), text)
+	require.NoError(t, err)
+}
+
+func searchLogs(ctx context.Context, t *testing.T, rep repo.DirectRepository, opt repodiag.SearchOptions) []string {
+	t.Helper()
+
+	var result []string
+
+	require.NoError(t, repodiag.SearchLogs(ctx, rep, opt, func(l repodiag.LogLine) error {
+		// strip timestamp, level and module
+		result = append(result, regexp.MustCompile(`^\S+ \S+ \S+ `).ReplaceAllString(l.Text, ""))
+		return nil
+	}))
+
+	return result
+}
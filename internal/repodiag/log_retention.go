@@ -0,0 +1,79 @@
+package repodiag
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+// LogRetentionOptions specifies how many diagnostic logs are retained in the repository.
+// Log blobs are deleted, oldest first, when any of the limits is exceeded.
+type LogRetentionOptions struct {
+	MaxTotalSize int64         `json:"maxTotalSize"`
+	MaxCount     int           `json:"maxCount"`
+	MaxAge       time.Duration `json:"maxAge"`
+}
+
+// DefaultLogRetention returns default log retention options.
+func DefaultLogRetention() LogRetentionOptions {
+	return LogRetentionOptions{
+		MaxTotalSize: 1 << 30,             //nolint:gomnd
+		MaxCount:     10000,               //nolint:gomnd
+		MaxAge:       30 * 24 * time.Hour, //nolint:gomnd
+	}
+}
+
+// OrDefault returns default log retention options if none were specified.
+func (o LogRetentionOptions) OrDefault() LogRetentionOptions {
+	if o == (LogRetentionOptions{}) {
+		return DefaultLogRetention()
+	}
+
+	return o
+}
+
+// CleanupLogs deletes log blobs exceeding the provided retention limits and returns the deleted blobs.
+func CleanupLogs(ctx context.Context, rep repo.DirectRepositoryWriter, opt LogRetentionOptions) ([]LogBlobInfo, error) {
+	allBlobs, err := ListLogBlobs(ctx, rep.BlobReader())
+	if err != nil {
+		return nil, err
+	}
+
+	// newest first
+	sort.SliceStable(allBlobs, func(i, j int) bool {
+		return allBlobs[i].EndTime.After(allBlobs[j].EndTime)
+	})
+
+	var (
+		totalSize int64
+		cutoff    time.Time
+		toDelete  []LogBlobInfo
+	)
+
+	if opt.MaxAge > 0 {
+		cutoff = rep.Time().Add(-opt.MaxAge)
+	}
+
+	for i, li := range allBlobs {
+		totalSize += li.Length
+
+		switch {
+		case opt.MaxCount > 0 && i >= opt.MaxCount,
+			opt.MaxTotalSize > 0 && totalSize > opt.MaxTotalSize,
+			li.EndTime.Before(cutoff):
+			toDelete = append(toDelete, li)
+		}
+	}
+
+	for _, li := range toDelete {
+		if err := rep.BlobStorage().DeleteBlob(ctx, li.BlobID); err != nil {
+			return nil, errors.Wrapf(err, "error deleting log blob %v", li.BlobID)
+		}
+	}
+
+	return toDelete, nil
+}
@@ -0,0 +1,123 @@
+package repodiag
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kopia/kopia/repo"
+)
+
+// SearchOptions specifies criteria for searching diagnostic logs.
+type SearchOptions struct {
+	MinTime   time.Time      // inclusive, zero for no limit
+	MaxTime   time.Time      // exclusive, zero for no limit
+	SessionID string         // empty to search all sessions
+	Text      string         // substring that must be present in matching lines
+	Regexp    *regexp.Regexp // regular expression that must match
+}
+
+// LogLine is a single line of diagnostic logs.
+type LogLine struct {
+	Time      time.Time
+	SessionID string
+	Text      string
+}
+
+// SearchLogs invokes the provided callback for each log line matching the search criteria, in chronological order.
+func SearchLogs(ctx context.Context, rep repo.DirectRepository, opt SearchOptions, cb func(l LogLine) error) error {
+	blobs, err := ListLogBlobs(ctx, rep.BlobReader())
+	if err != nil {
+		return err
+	}
+
+	for _, li := range blobs {
+		if !opt.blobMayMatch(li) {
+			continue
+		}
+
+		data, err := ReadLogBlob(ctx, rep, li.BlobID)
+		if err != nil {
+			return err
+		}
+
+		if err := searchLogBlob(data, li, opt, cb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *SearchOptions) blobMayMatch(li LogBlobInfo) bool {
+	if o.SessionID != "" && li.SessionID != o.SessionID {
+		return false
+	}
+
+	if !o.MaxTime.IsZero() && !li.StartTime.Before(o.MaxTime) {
+		return false
+	}
+
+	if !o.MinTime.IsZero() && li.EndTime.Before(o.MinTime) {
+		return false
+	}
+
+	return true
+}
+
+func (o *SearchOptions) lineMatches(l LogLine) bool {
+	if !o.MinTime.IsZero() && l.Time.Before(o.MinTime) {
+		return false
+	}
+
+	if !o.MaxTime.IsZero() && !l.Time.Before(o.MaxTime) {
+		return false
+	}
+
+	if o.Text != "" && !strings.Contains(l.Text, o.Text) {
+		return false
+	}
+
+	if o.Regexp != nil && !o.Regexp.MatchString(l.Text) {
+		return false
+	}
+
+	return true
+}
+
+func searchLogBlob(data []byte, li LogBlobInfo, opt SearchOptions, cb func(l LogLine) error) error {
+	// lines that don't start with a timestamp (such as multi-line messages) inherit the time of the previous line.
+	lastTime := li.StartTime
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, maxBufferedLogBytes)
+
+	for s.Scan() {
+		l := LogLine{
+			Time:      lastTime,
+			SessionID: li.SessionID,
+			Text:      s.Text(),
+		}
+
+		if p := strings.IndexByte(l.Text, ' '); p > 0 {
+			if t, err := time.Parse(time.RFC3339, l.Text[0:p]); err == nil {
+				l.Time = t
+				lastTime = t
+			}
+		}
+
+		if !opt.lineMatches(l) {
+			continue
+		}
+
+		if err := cb(l); err != nil {
+			return err
+		}
+	}
+
+	// nolint:wrapcheck
+	return s.Err()
+}
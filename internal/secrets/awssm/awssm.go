@@ -0,0 +1,42 @@
+// Package awssm registers secret provider "awssm" which fetches secrets from AWS Secrets Manager.
+//
+// Credentials and region are determined using the standard AWS SDK configuration chain
+// (environment variables, shared config files and instance roles).
+package awssm
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/secrets"
+)
+
+func getSecret(ctx context.Context, name string) ([]byte, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AWS session")
+	}
+
+	out, err := secretsmanager.New(sess).GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting secret value")
+	}
+
+	if out.SecretString != nil {
+		return []byte(*out.SecretString), nil
+	}
+
+	return out.SecretBinary, nil
+}
+
+func init() {
+	secrets.Register("awssm", secrets.ProviderFunc(getSecret))
+}
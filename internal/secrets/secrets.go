@@ -0,0 +1,185 @@
+// Package secrets fetches secrets such as passwords and TLS keys from external secret stores.
+//
+// Secrets are referenced as "<provider>:<name>[#<field>]", for example:
+//
+//   env:KOPIA_SERVER_KEY
+//   file:/etc/kopia/server.key
+//   vault:secret/data/kopia#password
+//   awssm:prod/kopia#password
+//
+// When a field is specified, the secret must be a JSON object and the value of the field is returned.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("secrets")
+
+// Provider fetches secrets from an external secret store.
+type Provider interface {
+	GetSecret(ctx context.Context, name string) ([]byte, error)
+}
+
+// ProviderFunc is a function that implements Provider.
+type ProviderFunc func(ctx context.Context, name string) ([]byte, error)
+
+// GetSecret implements Provider.
+func (f ProviderFunc) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	return f(ctx, name)
+}
+
+var (
+	providersMutex sync.RWMutex
+	providers      = map[string]Provider{}
+)
+
+// Register registers secret provider for the provided reference prefix.
+func Register(prefix string, p Provider) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+
+	providers[prefix] = p
+}
+
+func providerForPrefix(prefix string) Provider {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+
+	return providers[prefix]
+}
+
+// Get fetches the value of the secret with the provided reference.
+func Get(ctx context.Context, ref string) ([]byte, error) {
+	parts := strings.SplitN(ref, ":", 2) //nolint:gomnd
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, errors.Errorf("invalid secret reference %q, must be <provider>:<name>", ref)
+	}
+
+	p := providerForPrefix(parts[0])
+	if p == nil {
+		return nil, errors.Errorf("unknown secret provider %q", parts[0])
+	}
+
+	name, field := parts[1], ""
+	if idx := strings.LastIndex(name, "#"); idx >= 0 {
+		name, field = name[0:idx], name[idx+1:]
+	}
+
+	v, err := p.GetSecret(ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error fetching secret %q", ref)
+	}
+
+	if field == "" {
+		return v, nil
+	}
+
+	return extractField(v, field)
+}
+
+func extractField(v []byte, field string) ([]byte, error) {
+	var m map[string]interface{}
+
+	if err := json.Unmarshal(v, &m); err != nil {
+		return nil, errors.Wrap(err, "secret with field selector is not a JSON object")
+	}
+
+	switch fv := m[field].(type) {
+	case string:
+		return []byte(fv), nil
+
+	case nil:
+		return nil, errors.Errorf("field %q not found in secret", field)
+
+	default:
+		return nil, errors.Errorf("field %q of secret is not a string", field)
+	}
+}
+
+// Watch fetches the secret with the provided reference and invokes the callback with its value.
+// After that the secret is fetched periodically until the context is canceled and the callback is
+// invoked whenever the value changes, which allows picking up secrets rotated in the secret store.
+// Errors fetching or applying rotated secrets are logged and the previous value remains in use.
+func Watch(ctx context.Context, ref string, interval time.Duration, onChange func(v []byte) error) error {
+	v, err := Get(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	if err := onChange(v); err != nil {
+		return err
+	}
+
+	if interval <= 0 {
+		return nil
+	}
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-t.C:
+				nv, err := Get(ctx, ref)
+				if err != nil {
+					log(ctx).Errorf("unable to refresh secret: %v", err)
+					continue
+				}
+
+				if bytes.Equal(nv, v) {
+					continue
+				}
+
+				if err := onChange(nv); err != nil {
+					log(ctx).Errorf("unable to apply rotated secret %q: %v", ref, err)
+					continue
+				}
+
+				log(ctx).Infof("secret %q has been rotated", ref)
+
+				v = nv
+			}
+		}
+	}()
+
+	return nil
+}
+
+func getEnvSecret(ctx context.Context, name string) ([]byte, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, errors.Errorf("environment variable %v not set", name)
+	}
+
+	return []byte(v), nil
+}
+
+func getFileSecret(ctx context.Context, name string) ([]byte, error) {
+	v, err := ioutil.ReadFile(name) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading secret file")
+	}
+
+	return v, nil
+}
+
+func init() {
+	Register("env", ProviderFunc(getEnvSecret))
+	Register("file", ProviderFunc(getFileSecret))
+}
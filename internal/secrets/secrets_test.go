@@ -0,0 +1,138 @@
+package secrets_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/secrets"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestGet(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	fname := filepath.Join(testutil.TempDirectory(t), "secret")
+	require.NoError(t, ioutil.WriteFile(fname, []byte(`{"password":"file-pass","n":1}`), 0o600))
+
+	os.Setenv("KOPIA_SECRETS_TEST", "env-pass")
+	defer os.Unsetenv("KOPIA_SECRETS_TEST")
+
+	cases := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "env:KOPIA_SECRETS_TEST", want: "env-pass"},
+		{ref: "env:KOPIA_SECRETS_TEST_MISSING", wantErr: true},
+		{ref: "file:" + fname, want: `{"password":"file-pass","n":1}`},
+		{ref: "file:" + fname + "#password", want: "file-pass"},
+		{ref: "file:" + fname + "#n", wantErr: true},
+		{ref: "file:" + fname + "#no-such-field", wantErr: true},
+		{ref: "env:KOPIA_SECRETS_TEST#password", wantErr: true},
+		{ref: "no-such-provider:foo", wantErr: true},
+		{ref: "env:", wantErr: true},
+		{ref: "foo", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		v, err := secrets.Get(ctx, tc.ref)
+		if tc.wantErr {
+			require.Error(t, err, tc.ref)
+			continue
+		}
+
+		require.NoError(t, err, tc.ref)
+		require.Equal(t, tc.want, string(v), tc.ref)
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "tok" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/kopia":
+			w.Write([]byte(`{"data":{"data":{"password":"v2-pass"},"metadata":{"version":3}}}`)) //nolint:errcheck
+		case "/v1/kv/kopia":
+			w.Write([]byte(`{"data":{"password":"v1-pass"}}`)) //nolint:errcheck
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := &secrets.VaultProvider{Address: srv.URL, Token: "tok"}
+
+	v, err := p.GetSecret(ctx, "secret/data/kopia")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"password":"v2-pass"}`, string(v))
+
+	v, err = p.GetSecret(ctx, "kv/kopia")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"password":"v1-pass"}`, string(v))
+
+	_, err = p.GetSecret(ctx, "kv/missing")
+	require.Error(t, err)
+
+	p.Token = "bad"
+
+	_, err = p.GetSecret(ctx, "kv/kopia")
+	require.Error(t, err)
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(testlogging.Context(t))
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		current = "v1"
+		applied = make(chan string, 10)
+	)
+
+	secrets.Register("watchtest", secrets.ProviderFunc(func(ctx context.Context, name string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return []byte(current), nil
+	}))
+
+	require.NoError(t, secrets.Watch(ctx, "watchtest:x", 10*time.Millisecond, func(v []byte) error {
+		applied <- string(v)
+		return nil
+	}))
+
+	require.Equal(t, "v1", <-applied)
+
+	mu.Lock()
+	current = "v2"
+	mu.Unlock()
+
+	select {
+	case v := <-applied:
+		require.Equal(t, "v2", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotated secret was not applied")
+	}
+
+	// unchanged values are not applied again.
+	time.Sleep(50 * time.Millisecond)
+	require.Len(t, applied, 0)
+
+	require.Error(t, secrets.Watch(ctx, "watchtest-missing:x", time.Second, func(v []byte) error { return nil }))
+}
@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// VaultProvider fetches secrets from HashiCorp Vault using its HTTP API.
+// Secret names are API paths relative to /v1/, such as "secret/data/kopia" for KV version 2 engines,
+// and the data stored in the secret is returned as JSON object.
+type VaultProvider struct {
+	Address   string
+	Token     string
+	Namespace string
+
+	Client *http.Client
+}
+
+// GetSecret implements Provider.
+func (p *VaultProvider) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	if p.Address == "" || p.Token == "" {
+		return nil, errors.Errorf("vault address and token must be provided")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.Address, "/")+"/v1/"+strings.TrimPrefix(name, "/"), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+
+	req.Header.Set("X-Vault-Token", p.Token)
+
+	if p.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+
+	cli := p.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "error contacting vault")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading vault response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected vault response: %v", resp.Status)
+	}
+
+	var r struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, errors.Wrap(err, "invalid vault response")
+	}
+
+	// KV version 2 engines nest secret data along with version metadata.
+	if d, ok := r.Data["data"]; ok {
+		if _, ok := r.Data["metadata"]; ok {
+			return d, nil
+		}
+	}
+
+	v, err := json.Marshal(r.Data)
+
+	return v, errors.Wrap(err, "error serializing secret")
+}
+
+func init() {
+	Register("vault", ProviderFunc(func(ctx context.Context, name string) ([]byte, error) {
+		p := &VaultProvider{
+			Address:   os.Getenv("VAULT_ADDR"),
+			Token:     os.Getenv("VAULT_TOKEN"),
+			Namespace: os.Getenv("VAULT_NAMESPACE"),
+		}
+
+		return p.GetSecret(ctx, name)
+	}))
+}
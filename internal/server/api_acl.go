@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/serverapi"
+)
+
+func (s *Server) handleACLCheck(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	var req serverapi.ACLCheckRequest
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "malformed request body")
+	}
+
+	if len(req.Target) == 0 {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "missing target")
+	}
+
+	al, err := acl.ParseAccessLevel(req.Access)
+	if err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	d, err := auth.CheckACL(ctx, s.rep, req.User, req.Target, al, auth.CheckACLOptions{
+		CandidateRules:     req.CandidateRules,
+		OnlyCandidateRules: req.OnlyCandidateRules,
+	})
+	if err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	return &serverapi.ACLCheckResponse{ACLDecision: *d}, nil
+}
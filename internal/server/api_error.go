@@ -27,6 +27,10 @@ func accessDeniedError() *apiError {
 	return &apiError{http.StatusForbidden, serverapi.ErrorAccessDenied, "access is denied"}
 }
 
+func legalHoldError(err error) *apiError {
+	return &apiError{http.StatusConflict, serverapi.ErrorLegalHold, err.Error()}
+}
+
 func repositoryNotWritableError() *apiError {
 	return internalServerError(errors.Errorf("repository is not writable"))
 }
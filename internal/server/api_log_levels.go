@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/kopia/kopia/internal/logfile"
+	"github.com/kopia/kopia/internal/serverapi"
+)
+
+func (s *Server) handleLogLevelsGet(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	return currentLogLevels(), nil
+}
+
+func (s *Server) handleLogLevelsPut(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	var req serverapi.ModuleLogLevel
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "malformed request body")
+	}
+
+	if err := logfile.SetModuleLevel(req.Module, req.Level); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	log(ctx).Infof("log level of module %q changed to %q", req.Module, req.Level)
+
+	return currentLogLevels(), nil
+}
+
+func currentLogLevels() *serverapi.LogLevelsResponse {
+	resp := &serverapi.LogLevelsResponse{
+		Levels: []serverapi.ModuleLogLevel{},
+	}
+
+	for _, ml := range logfile.ModuleLevels() {
+		resp.Levels = append(resp.Levels, serverapi.ModuleLogLevel{Module: ml.Module, Level: ml.Level})
+	}
+
+	return resp
+}
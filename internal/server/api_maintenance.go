@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+func (s *Server) handleMaintenanceInfo(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	dr, ok := s.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, notFoundError("maintenance information not available")
+	}
+
+	p, err := maintenance.GetParams(ctx, dr)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	sched, err := maintenance.GetSchedule(ctx, dr)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return maintenanceInfoResponse(p, sched, dr.Time()), nil
+}
+
+func maintenanceCycleInfo(cp maintenance.CycleParams, nextRun, now time.Time) serverapi.MaintenanceCycleInfo {
+	return serverapi.MaintenanceCycleInfo{
+		CycleParams: cp,
+		NextRun:     nextRun,
+		Overdue:     cp.Enabled && !nextRun.IsZero() && now.After(nextRun.Add(cp.Interval)),
+	}
+}
+
+func maintenanceInfoResponse(p *maintenance.Params, sched *maintenance.Schedule, now time.Time) *serverapi.MaintenanceInfoResponse {
+	resp := &serverapi.MaintenanceInfoResponse{
+		Owner:      p.Owner,
+		QuickCycle: maintenanceCycleInfo(p.QuickCycle, sched.NextQuickMaintenanceTime, now),
+		FullCycle:  maintenanceCycleInfo(p.FullCycle, sched.NextFullMaintenanceTime, now),
+		Scrub:      maintenanceCycleInfo(p.Scrub.CycleParams, sched.NextScrubTime, now),
+		Runs:       sched.Runs,
+
+		ScrubFindings: sched.ScrubFindings,
+	}
+
+	resp.SafetyProfile = p.SafetyProfileOrDefault()
+	if sp, err := p.Safety(); err == nil {
+		resp.Safety = &sp
+	}
+
+	if resp.Runs == nil {
+		resp.Runs = map[maintenance.TaskType][]maintenance.RunInfo{}
+	}
+
+	for taskType, runs := range resp.Runs {
+		if len(runs) > 0 && !runs[0].Success {
+			resp.FailedTasks = append(resp.FailedTasks, taskType)
+		}
+	}
+
+	sort.Slice(resp.FailedTasks, func(i, j int) bool {
+		return resp.FailedTasks[i] < resp.FailedTasks[j]
+	})
+
+	return resp
+}
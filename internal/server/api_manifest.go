@@ -13,6 +13,7 @@
 	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
 )
 
 func (s *Server) handleManifestGet(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
@@ -62,6 +63,16 @@ func (s *Server) handleManifestDelete(ctx context.Context, r *http.Request, body
 		return nil, accessDeniedError()
 	}
 
+	if em.Labels[manifest.TypeLabelKey] == snapshot.ManifestType {
+		if err := snapshot.VerifyNotUnderLegalHold(ctx, s.rep, mid); err != nil {
+			if errors.Is(err, snapshot.ErrSnapshotUnderLegalHold) {
+				return nil, legalHoldError(err)
+			}
+
+			return nil, internalServerError(err)
+		}
+	}
+
 	err = rw.DeleteManifest(ctx, mid)
 	if errors.Is(err, manifest.ErrNotFound) {
 		return nil, notFoundError("manifest not found")
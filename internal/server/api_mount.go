@@ -4,6 +4,7 @@
 	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -13,6 +14,9 @@
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
+// number of missing names remembered per directory of mounted snapshots.
+const defaultMountNegativeLookupCacheSize = 1000
+
 func (s *Server) handleMountCreate(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
 	req := &serverapi.MountSnapshotRequest{}
 	if err := json.Unmarshal(body, req); err != nil {
@@ -31,7 +35,7 @@ func (s *Server) handleMountCreate(ctx context.Context, r *http.Request, body []
 		log(ctx).Debugf("mount controller for %v not found, starting", oid)
 
 		var err error
-		c, err = mount.Directory(ctx, snapshotfs.DirectoryEntry(s.rep, oid, nil), "*", mount.Options{})
+		c, err = mount.Directory(ctx, snapshotfs.DirectoryEntry(s.rep, oid, nil), "*", mountOptionsFromRequest(req.Options))
 
 		if err != nil {
 			return nil, internalServerError(err)
@@ -53,6 +57,28 @@ func (s *Server) handleMountCreate(ctx context.Context, r *http.Request, body []
 	}, nil
 }
 
+func mountOptionsFromRequest(o *serverapi.MountOptions) mount.Options {
+	if o == nil {
+		return mount.Options{
+			FuseNegativeLookupCacheSize: defaultMountNegativeLookupCacheSize,
+		}
+	}
+
+	mo := mount.Options{
+		FuseAllowOther:              o.AllowOther,
+		FuseAttrTimeout:             time.Duration(o.AttrTimeoutSeconds) * time.Second,
+		FuseEntryTimeout:            time.Duration(o.EntryTimeoutSeconds) * time.Second,
+		FuseMaxReadAhead:            o.MaxReadAhead,
+		FuseNegativeLookupCacheSize: o.NegativeLookupCacheSize,
+	}
+
+	if mo.FuseNegativeLookupCacheSize == 0 {
+		mo.FuseNegativeLookupCacheSize = defaultMountNegativeLookupCacheSize
+	}
+
+	return mo
+}
+
 func (s *Server) handleMountGet(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
 	oid := object.ID(mux.Vars(r)["rootObjectID"])
 
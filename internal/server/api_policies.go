@@ -61,6 +61,21 @@ func (s *Server) handlePolicyGet(ctx context.Context, r *http.Request, body []by
 	return pol, nil
 }
 
+func (s *Server) handlePolicyProvenance(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	target := getPolicyTargetFromURL(r.URL)
+
+	effective, prov, err := policy.GetEffectivePolicyWithProvenance(ctx, s.rep, target)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.PolicyProvenanceResponse{
+		Target:     target,
+		Effective:  effective,
+		Provenance: prov,
+	}, nil
+}
+
 func (s *Server) handlePolicyDelete(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
 	w, ok := s.rep.(repo.RepositoryWriter)
 	if !ok {
@@ -99,3 +114,20 @@ func (s *Server) handlePolicyPut(ctx context.Context, r *http.Request, body []by
 
 	return &serverapi.Empty{}, nil
 }
+
+func (s *Server) handlePolicyRetentionPreview(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	templates := r.URL.Query()["template"]
+
+	for _, t := range templates {
+		if _, ok := policy.RetentionTemplate(t); !ok {
+			return nil, requestError(serverapi.ErrorMalformedRequest, "unknown retention template")
+		}
+	}
+
+	previews, err := policy.PreviewRetentionTemplates(ctx, s.rep, getPolicyTargetFromURL(r.URL), templates...)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.RetentionPreviewResponse{Previews: previews}, nil
+}
@@ -9,6 +9,7 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/passwordpersist"
+	"github.com/kopia/kopia/internal/providervalidation"
 	"github.com/kopia/kopia/internal/remoterepoapi"
 	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/repo"
@@ -35,6 +36,15 @@ func (s *Server) handleRepoParameters(ctx context.Context, r *http.Request, body
 		Format:       dr.ObjectFormat(),
 	}
 
+	if d := dr.ClientDefaults(); !d.IsEmpty() {
+		v, err := json.Marshal(d)
+		if err != nil {
+			return nil, internalServerError(err)
+		}
+
+		rp.ClientDefaults = v
+	}
+
 	return rp, nil
 }
 
@@ -55,6 +65,7 @@ func (s *Server) handleRepoStatus(ctx context.Context, r *http.Request, body []b
 			MaxPackSize:   dr.ContentReader().ContentFormat().MaxPackSize,
 			Splitter:      dr.ObjectFormat().Splitter,
 			Storage:       dr.BlobReader().ConnectionInfo().Type,
+			Labels:        dr.Labels(),
 			ClientOptions: dr.ClientOptions(),
 		}, nil
 	}
@@ -75,6 +86,45 @@ type remoteRepository interface {
 	return result, nil
 }
 
+func (s *Server) handleRepoStats(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	dr, ok := s.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, notFoundError("repository statistics not available")
+	}
+
+	st, err := repo.GetStats(ctx, dr)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.RepoStatsResponse{Stats: st}, nil
+}
+
+func (s *Server) handleRepoCheckStorage(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	type blobStorageProvider interface {
+		BlobStorage() blob.Storage
+	}
+
+	bsp, ok := s.rep.(blobStorageProvider)
+	if !ok {
+		return nil, notFoundError("storage check not available")
+	}
+
+	opt := s.rep.ClientOptions()
+	report := providervalidation.CheckHealth(ctx, bsp.BlobStorage(), opt.ReadOnly || opt.DryRun)
+
+	resp := &serverapi.CheckStorageResponse{
+		Healthy: report.Healthy(),
+		Report:  report,
+	}
+
+	if dr, ok := s.rep.(repo.DirectRepository); ok {
+		resp.Storage = dr.BlobReader().ConnectionInfo().Type
+	}
+
+	return resp, nil
+}
+
 func maybeDecodeToken(req *serverapi.ConnectRepositoryRequest) *apiError {
 	if req.Token != "" {
 		ci, password, err := repo.DecodeToken(req.Token)
@@ -25,6 +25,7 @@ func restoreCounters(s restore.Stats) map[string]uitask.CounterValue {
 		"Ignored Errors":       uitask.SimpleCounter(int64(s.IgnoredErrorCount)),
 		"Skipped Files":        uitask.SimpleCounter(int64(s.SkippedCount)),
 		"Skipped Bytes":        uitask.BytesCounter(s.SkippedTotalFileSize),
+		"Case Collisions":      uitask.SimpleCounter(int64(s.CaseCollisionCount)),
 	}
 }
 
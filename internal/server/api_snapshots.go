@@ -2,12 +2,16 @@
 
 import (
 	"context"
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 
+	"github.com/kopia/kopia/internal/diff"
 	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
 func (s *Server) handleSnapshotList(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
@@ -45,6 +49,43 @@ func (s *Server) handleSnapshotList(ctx context.Context, r *http.Request, body [
 	return resp, nil
 }
 
+func (s *Server) handleSnapshotCompare(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	var req serverapi.SnapshotCompareRequest
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "malformed request body")
+	}
+
+	if req.Left == "" || req.Right == "" {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "both left and right must be specified")
+	}
+
+	left, err := snapshotfs.FilesystemEntryFromIDWithPath(ctx, s.rep, req.Left, false)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	right, err := snapshotfs.FilesystemEntryFromIDWithPath(ctx, s.rep, req.Right, false)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	d, err := diff.NewComparer(ioutil.Discard)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+	defer d.Close() //nolint:errcheck
+
+	changes, err := d.Changes(ctx, left, right)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.SnapshotCompareResponse{
+		Changes: append([]*diff.Change{}, changes...),
+	}, nil
+}
+
 func sourceMatchesURLFilter(src snapshot.SourceInfo, query url.Values) bool {
 	if v := query.Get("host"); v != "" && src.Host != v {
 		return false
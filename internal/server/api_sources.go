@@ -6,14 +6,18 @@
 	"net/http"
 	"os"
 	"sort"
+	"strconv"
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/ospath"
 	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/snapshothealth"
 )
 
 func (s *Server) handleSourcesList(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
@@ -41,6 +45,111 @@ func (s *Server) handleSourcesList(ctx context.Context, r *http.Request, body []
 	return resp, nil
 }
 
+func (s *Server) handleSourcesHealth(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	now := clock.Now()
+	resp := snapshothealth.NewReport()
+
+	for _, v := range s.sourceManagers {
+		if !sourceMatchesURLFilter(v.src, r.URL.Query()) {
+			continue
+		}
+
+		h, err := v.health(ctx, now, snapshothealth.DefaultThresholds)
+		if err != nil {
+			return nil, internalServerError(err)
+		}
+
+		resp.Add(h)
+	}
+
+	resp.Sort()
+
+	return resp, nil
+}
+
+func (s *Server) handleSourcesDeleted(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	q := r.URL.Query()
+
+	src := snapshot.SourceInfo{
+		Host:     q.Get("host"),
+		UserName: q.Get("userName"),
+		Path:     q.Get("path"),
+	}
+
+	if src.Host == "" || src.UserName == "" || src.Path == "" {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "host, userName and path must be provided")
+	}
+
+	var opt snapshotfs.DeletedEntriesOptions
+
+	if v := q.Get("maxResults"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, requestError(serverapi.ErrorMalformedRequest, "invalid maxResults")
+		}
+
+		opt.MaxResults = n
+	}
+
+	manifests, err := snapshot.ListSnapshots(ctx, s.rep, src)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	entries, err := snapshotfs.FindDeletedEntries(ctx, s.rep, manifests, opt)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	resp := &serverapi.DeletedEntriesResponse{
+		Source:  src,
+		Entries: []*snapshotfs.DeletedEntry{},
+	}
+
+	resp.Entries = append(resp.Entries, entries...)
+
+	return resp, nil
+}
+
+func (s *Server) handleSourcesRuns(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	q := r.URL.Query()
+
+	src := snapshot.SourceInfo{
+		Host:     q.Get("host"),
+		UserName: q.Get("userName"),
+		Path:     q.Get("path"),
+	}
+
+	if src.Host == "" || src.UserName == "" || src.Path == "" {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "host, userName and path must be provided")
+	}
+
+	results, err := snapshot.ListRunResults(ctx, s.rep, src)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	if v := q.Get("maxResults"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, requestError(serverapi.ErrorMalformedRequest, "invalid maxResults")
+		}
+
+		if n > 0 && len(results) > n {
+			results = results[0:n]
+		}
+	}
+
+	resp := &serverapi.SourceRunsResponse{
+		Source: src,
+		Runs:   []*snapshot.RunResult{},
+	}
+
+	resp.Runs = append(resp.Runs, results...)
+
+	return resp, nil
+}
+
 func (s *Server) handleSourcesCreate(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
 	var req serverapi.CreateSnapshotSourceRequest
 
@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"net/http"
 	"runtime"
@@ -12,16 +13,19 @@
 	"golang.org/x/sync/semaphore"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/grpcapi"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
 )
 
 type grpcServerState struct {
@@ -56,16 +60,60 @@ func (s *Server) authenticateGRPCSession(ctx context.Context) (string, error) {
 		username := u[0] + "@" + h[0]
 		password := p[0]
 
+		if !s.hasValidClientCertificate(grpcPeerTLSState(ctx), username) {
+			s.emitGRPCAuthenticationFailed(ctx, username, "missing or invalid client certificate")
+			return "", status.Errorf(codes.PermissionDenied, "missing or invalid client certificate for %v", username)
+		}
+
 		if s.authenticator.IsValid(ctx, s.rep, username, password) {
 			return username, nil
 		}
 
+		s.emitGRPCAuthenticationFailed(ctx, username, "invalid credentials")
+
 		return "", status.Errorf(codes.PermissionDenied, "access denied for %v", username)
 	}
 
+	s.emitGRPCAuthenticationFailed(ctx, "", "missing credentials")
+
 	return "", status.Errorf(codes.PermissionDenied, "missing credentials")
 }
 
+func (s *Server) emitGRPCAuthenticationFailed(ctx context.Context, username, reason string) {
+	s.events.emit(ctx, ConnectionEvent{
+		Type:       EventAuthenticationFailed,
+		Protocol:   "grpc",
+		Username:   username,
+		RemoteAddr: grpcPeerAddr(ctx),
+		Reason:     reason,
+	})
+}
+
+// grpcPeerAddr returns the address of the GRPC peer or empty string if not known.
+func grpcPeerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	return p.Addr.String()
+}
+
+// grpcPeerTLSState returns the TLS connection state of the GRPC peer or nil if not using TLS.
+func grpcPeerTLSState(ctx context.Context) *tls.ConnectionState {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	ti, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+
+	return &ti.State
+}
+
 // Session handles GRPC session from a repository client.
 func (s *Server) Session(srv grpcapi.KopiaRepository_SessionServer) error {
 	ctx := srv.Context()
@@ -90,6 +138,26 @@ func (s *Server) Session(srv grpcapi.KopiaRepository_SessionServer) error {
 		return status.Errorf(codes.PermissionDenied, "peer not found in context")
 	}
 
+	sessionStart := clock.Now()
+
+	s.events.emit(ctx, ConnectionEvent{
+		Time:       sessionStart,
+		Type:       EventClientConnected,
+		Protocol:   "grpc",
+		Username:   username,
+		RemoteAddr: p.Addr.String(),
+	})
+
+	defer func() {
+		s.events.emit(ctx, ConnectionEvent{
+			Type:            EventClientDisconnected,
+			Protocol:        "grpc",
+			Username:        username,
+			RemoteAddr:      p.Addr.String(),
+			SessionDuration: clock.Since(sessionStart),
+		})
+	}()
+
 	log(ctx).Infof("starting session for user %q from %v", username, p.Addr)
 	defer log(ctx).Infof("session ended for user %q from %v", username, p.Addr)
 
@@ -340,6 +408,12 @@ func handleDeleteManifestRequest(ctx context.Context, dw repo.DirectRepositoryWr
 		return accessDeniedResponse()
 	}
 
+	if em.Labels[manifest.TypeLabelKey] == snapshot.ManifestType {
+		if err := snapshot.VerifyNotUnderLegalHold(ctx, dw, em.ID); err != nil {
+			return errorResponse(err)
+		}
+	}
+
 	if err := dw.DeleteManifest(ctx, manifest.ID(req.GetManifestId())); err != nil {
 		return errorResponse(err)
 	}
@@ -372,6 +446,8 @@ func errorResponse(err error) *grpcapi.SessionResponse {
 		errorCode = grpcapi.ErrorResponse_MANIFEST_NOT_FOUND
 	case errors.Is(err, object.ErrObjectNotFound):
 		errorCode = grpcapi.ErrorResponse_OBJECT_NOT_FOUND
+	case errors.Is(err, snapshot.ErrSnapshotUnderLegalHold):
+		errorCode = grpcapi.ErrorResponse_LEGAL_HOLD
 	default:
 		errorCode = grpcapi.ErrorResponse_UNKNOWN_ERROR
 	}
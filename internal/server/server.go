@@ -3,6 +3,7 @@
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -18,6 +19,7 @@
 	"github.com/kopia/kopia/internal/auth"
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/passwordpersist"
+	"github.com/kopia/kopia/internal/repodiag"
 	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/uitask"
 	"github.com/kopia/kopia/repo"
@@ -62,6 +64,8 @@ type Server struct {
 
 	authCookieSigningKey []byte
 
+	events *eventEmitter
+
 	grpcServerState
 }
 
@@ -74,17 +78,25 @@ func (s *Server) APIHandlers(legacyAPI bool) http.Handler {
 	m.HandleFunc("/api/v1/sources", s.handleAPI(requireUIUser, s.handleSourcesCreate)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/sources/upload", s.handleAPI(requireUIUser, s.handleUpload)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/sources/cancel", s.handleAPI(requireUIUser, s.handleCancel)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/sources/health", s.handleAPI(requireUIUser, s.handleSourcesHealth)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/sources/deleted", s.handleAPI(requireUIUser, s.handleSourcesDeleted)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/sources/runs", s.handleAPI(requireUIUser, s.handleSourcesRuns)).Methods(http.MethodGet)
 
 	// snapshots
 	m.HandleFunc("/api/v1/snapshots", s.handleAPI(requireUIUser, s.handleSnapshotList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/snapshots/compare", s.handleAPI(requireUIUser, s.handleSnapshotCompare)).Methods(http.MethodPost)
 
 	m.HandleFunc("/api/v1/policy", s.handleAPI(requireUIUser, s.handlePolicyGet)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/policy", s.handleAPI(requireUIUser, s.handlePolicyPut)).Methods(http.MethodPut)
 	m.HandleFunc("/api/v1/policy", s.handleAPI(requireUIUser, s.handlePolicyDelete)).Methods(http.MethodDelete)
 
+	m.HandleFunc("/api/v1/policy/provenance", s.handleAPI(requireUIUser, s.handlePolicyProvenance)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/policy/retention-preview", s.handleAPI(requireUIUser, s.handlePolicyRetentionPreview)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/policies", s.handleAPI(requireUIUser, s.handlePolicyList)).Methods(http.MethodGet)
 
 	m.HandleFunc("/api/v1/refresh", s.handleAPI(anyAuthenticatedUser, s.handleRefresh)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/log-levels", s.handleAPIPossiblyNotConnected(requireUIUser, s.handleLogLevelsGet)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/log-levels", s.handleAPIPossiblyNotConnected(requireUIUser, s.handleLogLevelsPut)).Methods(http.MethodPut)
 	m.HandleFunc("/api/v1/shutdown", s.handleAPIPossiblyNotConnected(requireUIUser, s.handleShutdown)).Methods(http.MethodPost)
 
 	m.HandleFunc("/api/v1/objects/{objectID}", s.requireAuth(s.handleObjectGet)).Methods(http.MethodGet)
@@ -94,6 +106,9 @@ func (s *Server) APIHandlers(legacyAPI bool) http.Handler {
 	// methods that can be called by any authenticated user (UI or remote user).
 	m.HandleFunc("/api/v1/flush", s.handleAPI(anyAuthenticatedUser, s.handleFlush)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/repo/status", s.handleAPIPossiblyNotConnected(anyAuthenticatedUser, s.handleRepoStatus)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/repo/stats", s.handleAPI(anyAuthenticatedUser, s.handleRepoStats)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/repo/check-storage", s.handleAPI(requireUIUser, s.handleRepoCheckStorage)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/repo/maintenance", s.handleAPI(requireUIUser, s.handleMaintenanceInfo)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/repo/sync", s.handleAPI(anyAuthenticatedUser, s.handleRepoSync)).Methods(http.MethodPost)
 
 	m.HandleFunc("/api/v1/repo/connect", s.handleAPIPossiblyNotConnected(requireUIUser, s.handleRepoConnect)).Methods(http.MethodPost)
@@ -122,6 +137,8 @@ func (s *Server) APIHandlers(legacyAPI bool) http.Handler {
 	m.HandleFunc("/api/v1/mounts/{rootObjectID}", s.handleAPI(requireUIUser, s.handleMountGet)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/mounts", s.handleAPI(requireUIUser, s.handleMountList)).Methods(http.MethodGet)
 
+	m.HandleFunc("/api/v1/acl/check", s.handleAPI(requireUIUser, s.handleACLCheck)).Methods(http.MethodPost)
+
 	m.HandleFunc("/api/v1/current-user", s.handleAPIPossiblyNotConnected(requireUIUser, s.handleCurrentUser)).Methods(http.MethodGet)
 
 	m.HandleFunc("/api/v1/tasks-summary", s.handleAPI(requireUIUser, s.handleTaskSummary)).Methods(http.MethodGet)
@@ -146,21 +163,47 @@ func (s *Server) isAuthenticated(w http.ResponseWriter, r *http.Request) bool {
 		return false
 	}
 
+	if username != s.options.UIUser && !s.hasValidClientCertificate(r.TLS, username) {
+		s.events.emit(r.Context(), ConnectionEvent{
+			Type:       EventAuthenticationFailed,
+			Protocol:   "http",
+			Username:   username,
+			RemoteAddr: r.RemoteAddr,
+			Reason:     "missing or invalid client certificate",
+		})
+
+		http.Error(w, "Missing or invalid client certificate.\n", http.StatusUnauthorized)
+
+		return false
+	}
+
 	if c, err := r.Cookie(kopiaAuthCookie); err == nil && c != nil {
 		if s.isAuthCookieValid(username, c.Value) {
 			// found a short-term JWT cookie that matches given username, trust it.
 			// this avoids potentially expensive password hashing inside the authenticator.
+			s.events.httpRequestAuthenticated(r.Context(), username, r.RemoteAddr)
+
 			return true
 		}
 	}
 
 	if !s.authenticator.IsValid(r.Context(), s.rep, username, password) {
+		s.events.emit(r.Context(), ConnectionEvent{
+			Type:       EventAuthenticationFailed,
+			Protocol:   "http",
+			Username:   username,
+			RemoteAddr: r.RemoteAddr,
+			Reason:     "invalid credentials",
+		})
+
 		w.Header().Set("WWW-Authenticate", `Basic realm="Kopia"`)
 		http.Error(w, "Access denied.\n", http.StatusUnauthorized)
 
 		return false
 	}
 
+	s.events.httpRequestAuthenticated(r.Context(), username, r.RemoteAddr)
+
 	now := clock.Now()
 
 	ac, err := s.generateShortTermAuthCookie(username, now)
@@ -427,6 +470,7 @@ func (s *Server) SetRepository(ctx context.Context, rep repo.Repository) error {
 	}
 
 	if s.rep != nil {
+		s.uploadLogs(ctx, s.rep)
 		s.unmountAll(ctx)
 
 		// close previous source managers
@@ -479,10 +523,31 @@ func (s *Server) refreshPeriodically(ctx context.Context, r repo.Repository) {
 			if err := s.SyncSources(ctx); err != nil {
 				log(ctx).Errorf("unable to sync sources: %v", err)
 			}
+
+			s.uploadLogs(ctx, r)
 		}
 	}
 }
 
+// uploadLogs uploads buffered server logs to the repository, if it's directly connected.
+func (s *Server) uploadLogs(ctx context.Context, rep repo.Repository) {
+	lm := s.options.LogManager
+	if lm == nil {
+		return
+	}
+
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok || dr.ClientOptions().ReadOnly {
+		return
+	}
+
+	if err := repo.DirectWriteSession(ctx, dr, repo.WriteSessionOptions{Purpose: "upload logs"}, func(dw repo.DirectRepositoryWriter) error {
+		return lm.Flush(ctx, dw)
+	}); err != nil {
+		log(ctx).Errorf("unable to upload logs: %v", err)
+	}
+}
+
 func (s *Server) periodicMaintenance(ctx context.Context, rep repo.Repository) {
 	for {
 		select {
@@ -515,8 +580,13 @@ func periodicMaintenanceOnce(ctx context.Context, rep repo.Repository) error {
 	return repo.DirectWriteSession(ctx, dr, repo.WriteSessionOptions{
 		Purpose: "periodicMaintenanceOnce",
 	}, func(w repo.DirectRepositoryWriter) error {
+		safety, err := maintenance.GetSafetyParameters(ctx, w)
+		if err != nil {
+			return errors.Wrap(err, "unable to get maintenance safety parameters")
+		}
+
 		// nolint:wrapcheck
-		return snapshotmaintenance.Run(ctx, w, maintenance.ModeAuto, false, maintenance.SafetyFull)
+		return snapshotmaintenance.Run(ctx, w, maintenance.ModeAuto, false, safety)
 	})
 }
 
@@ -606,6 +676,20 @@ func (s *Server) syncSourcesLocked(ctx context.Context) error {
 	return nil
 }
 
+// hasValidClientCertificate determines whether the connection has presented verified TLS client certificate
+// issued to the provided user, if client certificates are required.
+func (s *Server) hasValidClientCertificate(cs *tls.ConnectionState, usernameAtHostname string) bool {
+	if !s.options.RequireClientCertificates {
+		return true
+	}
+
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return false
+	}
+
+	return cs.VerifiedChains[0][0].Subject.CommonName == usernameAtHostname
+}
+
 // Options encompasses all API server options.
 type Options struct {
 	ConfigFile           string
@@ -617,6 +701,17 @@ type Options struct {
 	PasswordPersist      passwordpersist.Strategy
 	AuthCookieSigningKey string
 	UIUser               string // name of the user allowed to access the UI
+
+	// LogManager, when provided, receives server logs which are periodically uploaded to the repository.
+	LogManager *repodiag.LogManager
+
+	// RequireClientCertificates requires repository users to present verified TLS client certificate
+	// whose common name is username@hostname.
+	RequireClientCertificates bool
+
+	// EventWebhookURL, when provided, receives connection events of all users as JSON POST requests,
+	// in addition to webhooks configured in user profiles.
+	EventWebhookURL string
 }
 
 // New creates a Server.
@@ -647,5 +742,7 @@ func New(ctx context.Context, options Options) (*Server, error) {
 		authCookieSigningKey: []byte(options.AuthCookieSigningKey),
 	}
 
+	s.events = newEventEmitter(options.EventWebhookURL, s.userEventWebhookURL)
+
 	return s, nil
 }
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/user"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var eventLog = logging.GetContextLoggerFunc("kopia/server/events")
+
+const (
+	eventWebhookTimeout   = 10 * time.Second
+	eventWebhookQueueSize = 1000
+
+	// httpSessionIdleTimeout is the time without requests after which a client of the HTTP API is considered disconnected.
+	httpSessionIdleTimeout = 5 * time.Minute
+)
+
+// ConnectionEventType identifies the type of ConnectionEvent.
+type ConnectionEventType string
+
+// Supported connection event types.
+const (
+	EventClientConnected      ConnectionEventType = "client-connected"
+	EventClientDisconnected   ConnectionEventType = "client-disconnected"
+	EventAuthenticationFailed ConnectionEventType = "authentication-failed"
+)
+
+// ConnectionEvent describes a single event related to clients connecting to the server, suitable for
+// consumption by SIEM systems.
+type ConnectionEvent struct {
+	Time       time.Time           `json:"time"`
+	Type       ConnectionEventType `json:"type"`
+	Protocol   string              `json:"protocol"`
+	Username   string              `json:"username,omitempty"`
+	RemoteAddr string              `json:"remoteAddr,omitempty"`
+	Reason     string              `json:"reason,omitempty"`
+
+	// SessionDuration is the duration of the session that ended, set for EventClientDisconnected.
+	SessionDuration time.Duration `json:"sessionDuration,omitempty"`
+}
+
+// httpSession is a series of requests to the stateless HTTP API made by the same user from the same host.
+type httpSession struct {
+	username   string
+	remoteAddr string
+	started    time.Time
+	lastSeen   time.Time
+}
+
+// eventEmitter logs connection events and delivers them in the background to the server-wide webhook
+// and the webhook configured for the user the event is about, so that slow webhook endpoints don't
+// delay client requests.
+type eventEmitter struct {
+	webhookURL     string
+	userWebhookURL func(ctx context.Context, username string) string
+	client         *http.Client
+
+	queue  chan ConnectionEvent
+	stop   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu           sync.Mutex
+	closed       bool
+	httpSessions map[string]*httpSession // keyed by username and remote host
+}
+
+func newEventEmitter(webhookURL string, userWebhookURL func(ctx context.Context, username string) string) *eventEmitter {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	e := &eventEmitter{
+		webhookURL:     webhookURL,
+		userWebhookURL: userWebhookURL,
+		client:         &http.Client{Timeout: eventWebhookTimeout},
+		queue:          make(chan ConnectionEvent, eventWebhookQueueSize),
+		stop:           make(chan struct{}),
+		cancel:         cancel,
+		httpSessions:   map[string]*httpSession{},
+	}
+
+	e.wg.Add(2) //nolint:gomnd
+
+	go e.deliverWebhooks(ctx)
+	go e.expireHTTPSessions(ctx)
+
+	return e
+}
+
+// close ends all HTTP sessions and stops background goroutines after delivering queued events,
+// which is abandoned after eventWebhookTimeout.
+func (e *eventEmitter) close(ctx context.Context) {
+	e.endHTTPSessions(ctx, func(*httpSession) bool { return true }, "server shutdown")
+
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+
+	e.closed = true
+	close(e.queue)
+	close(e.stop)
+	e.mu.Unlock()
+
+	t := time.AfterFunc(eventWebhookTimeout, e.cancel)
+	defer t.Stop()
+
+	e.wg.Wait()
+	e.cancel()
+}
+
+func (e *eventEmitter) emit(ctx context.Context, ev ConnectionEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = clock.Now()
+	}
+
+	b, err := json.Marshal(ev)
+	if err != nil {
+		log(ctx).Errorf("unable to serialize connection event: %v", err)
+		return
+	}
+
+	eventLog(ctx).Infof("%s", b)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+
+	select {
+	case e.queue <- ev:
+	default:
+		log(ctx).Errorf("connection event webhook queue is full, dropping %v event", ev.Type)
+	}
+}
+
+// httpRequestAuthenticated records a request of the provided user to the HTTP API and emits
+// EventClientConnected if it starts a new session.
+func (e *eventEmitter) httpRequestAuthenticated(ctx context.Context, username, remoteAddr string) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	key := username + "@" + host
+	now := clock.Now()
+
+	e.mu.Lock()
+
+	if hs := e.httpSessions[key]; hs != nil {
+		hs.lastSeen = now
+		e.mu.Unlock()
+
+		return
+	}
+
+	e.httpSessions[key] = &httpSession{username, remoteAddr, now, now}
+	e.mu.Unlock()
+
+	e.emit(ctx, ConnectionEvent{
+		Time:       now,
+		Type:       EventClientConnected,
+		Protocol:   "http",
+		Username:   username,
+		RemoteAddr: remoteAddr,
+	})
+}
+
+// endHTTPSessions removes HTTP sessions matching the provided predicate and emits EventClientDisconnected for them.
+func (e *eventEmitter) endHTTPSessions(ctx context.Context, shouldEnd func(hs *httpSession) bool, reason string) {
+	var ended []*httpSession
+
+	e.mu.Lock()
+
+	for k, hs := range e.httpSessions {
+		if shouldEnd(hs) {
+			ended = append(ended, hs)
+			delete(e.httpSessions, k)
+		}
+	}
+
+	e.mu.Unlock()
+
+	for _, hs := range ended {
+		e.emit(ctx, ConnectionEvent{
+			Type:            EventClientDisconnected,
+			Protocol:        "http",
+			Username:        hs.username,
+			RemoteAddr:      hs.remoteAddr,
+			Reason:          reason,
+			SessionDuration: hs.lastSeen.Sub(hs.started),
+		})
+	}
+}
+
+// endIdleHTTPSessions ends HTTP sessions without requests since httpSessionIdleTimeout before the provided time.
+func (e *eventEmitter) endIdleHTTPSessions(ctx context.Context, now time.Time) {
+	e.endHTTPSessions(ctx, func(hs *httpSession) bool {
+		return now.Sub(hs.lastSeen) >= httpSessionIdleTimeout
+	}, "idle")
+}
+
+func (e *eventEmitter) expireHTTPSessions(ctx context.Context) {
+	defer e.wg.Done()
+
+	t := time.NewTicker(httpSessionIdleTimeout / 5) //nolint:gomnd
+	defer t.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+
+		case <-t.C:
+			e.endIdleHTTPSessions(ctx, clock.Now())
+		}
+	}
+}
+
+func (e *eventEmitter) deliverWebhooks(ctx context.Context) {
+	defer e.wg.Done()
+
+	for ev := range e.queue {
+		if ctx.Err() != nil {
+			// abandoned on shutdown, drain the queue.
+			continue
+		}
+
+		for _, u := range e.webhookURLs(ctx, ev) {
+			if err := e.postWebhook(ctx, u, ev); err != nil {
+				log(ctx).Errorf("unable to deliver %v event to webhook: %v", ev.Type, err)
+			}
+		}
+	}
+}
+
+// webhookURLs returns the URLs of webhooks the provided event should be delivered to.
+func (e *eventEmitter) webhookURLs(ctx context.Context, ev ConnectionEvent) []string {
+	var result []string
+
+	if e.webhookURL != "" {
+		result = append(result, e.webhookURL)
+	}
+
+	if ev.Username != "" && e.userWebhookURL != nil {
+		if u := e.userWebhookURL(ctx, ev.Username); u != "" && u != e.webhookURL {
+			result = append(result, u)
+		}
+	}
+
+	return result
+}
+
+func (e *eventEmitter) postWebhook(ctx context.Context, webhookURL string, ev ConnectionEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize event")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "unable to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "unable to send request")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("webhook returned %v", resp.Status)
+	}
+
+	return nil
+}
+
+// userEventWebhookURL returns the event webhook URL configured in the profile of the provided user, if any.
+func (s *Server) userEventWebhookURL(ctx context.Context, username string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.rep == nil {
+		return ""
+	}
+
+	p, err := user.GetUserProfile(ctx, s.rep, username)
+	if err != nil {
+		return ""
+	}
+
+	return p.EventWebhookURL
+}
+
+// Close ends sessions of HTTP API clients and stops delivering connection events,
+// it must be called after the HTTP server has been shut down.
+func (s *Server) Close(ctx context.Context) {
+	s.events.close(ctx)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func receivingWebhook(t *testing.T) (*httptest.Server, chan ConnectionEvent) {
+	t.Helper()
+
+	received := make(chan ConnectionEvent, 10)
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev ConnectionEvent
+
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+
+		received <- ev
+	}))
+	t.Cleanup(hs.Close)
+
+	return hs, received
+}
+
+func TestEventWebhook(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	hs, received := receivingWebhook(t)
+
+	e := newEventEmitter(hs.URL, nil)
+	e.emit(ctx, ConnectionEvent{
+		Type:       EventAuthenticationFailed,
+		Protocol:   "grpc",
+		Username:   "user@host",
+		RemoteAddr: "127.0.0.1:1234",
+		Reason:     "invalid credentials",
+	})
+
+	ev := <-received
+	require.Equal(t, EventAuthenticationFailed, ev.Type)
+	require.Equal(t, "user@host", ev.Username)
+	require.Equal(t, "invalid credentials", ev.Reason)
+	require.False(t, ev.Time.IsZero())
+
+	e.close(ctx)
+
+	// events emitted after close are only logged.
+	e.emit(ctx, ConnectionEvent{Type: EventAuthenticationFailed, Protocol: "grpc"})
+	require.Empty(t, received)
+}
+
+func TestEventWebhookPerUser(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	hs, received := receivingWebhook(t)
+
+	e := newEventEmitter("", func(ctx context.Context, username string) string {
+		if username == "user1@host" {
+			return hs.URL
+		}
+
+		return ""
+	})
+
+	e.emit(ctx, ConnectionEvent{Type: EventClientConnected, Protocol: "grpc", Username: "user2@host"})
+	e.emit(ctx, ConnectionEvent{Type: EventClientConnected, Protocol: "grpc", Username: "user1@host"})
+	e.close(ctx)
+
+	require.Len(t, received, 1)
+	require.Equal(t, "user1@host", (<-received).Username)
+}
+
+func TestHTTPSessionEvents(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	hs, received := receivingWebhook(t)
+
+	e := newEventEmitter(hs.URL, nil)
+
+	e.httpRequestAuthenticated(ctx, "user1@host", "10.0.0.1:1234")
+	e.httpRequestAuthenticated(ctx, "user1@host", "10.0.0.1:5678")
+	e.httpRequestAuthenticated(ctx, "user2@host", "10.0.0.1:1234")
+
+	ev := <-received
+	require.Equal(t, EventClientConnected, ev.Type)
+	require.Equal(t, "http", ev.Protocol)
+	require.Equal(t, "user1@host", ev.Username)
+
+	ev = <-received
+	require.Equal(t, EventClientConnected, ev.Type)
+	require.Equal(t, "user2@host", ev.Username)
+
+	// sessions that are not idle yet are kept.
+	e.endIdleHTTPSessions(ctx, clock.Now())
+	require.Len(t, e.httpSessions, 2)
+
+	e.endIdleHTTPSessions(ctx, clock.Now().Add(httpSessionIdleTimeout))
+	require.Empty(t, e.httpSessions)
+
+	for i := 0; i < 2; i++ {
+		ev = <-received
+		require.Equal(t, EventClientDisconnected, ev.Type)
+		require.Equal(t, "idle", ev.Reason)
+	}
+
+	// remaining sessions are ended on close.
+	e.httpRequestAuthenticated(ctx, "user1@host", "10.0.0.1:1234")
+	require.Equal(t, EventClientConnected, (<-received).Type)
+
+	e.close(ctx)
+
+	ev = <-received
+	require.Equal(t, EventClientDisconnected, ev.Type)
+	require.Equal(t, "server shutdown", ev.Reason)
+}
@@ -16,16 +16,20 @@
 
 	"github.com/kopia/kopia/internal/apiclient"
 	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/passwordpersist"
 	"github.com/kopia/kopia/internal/repotesting"
 	"github.com/kopia/kopia/internal/server"
+	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/internal/user"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
 )
 
 const (
@@ -44,10 +48,20 @@
 func startServer(ctx context.Context, t *testing.T) *repo.APIServerInfo {
 	_, env := repotesting.NewEnvironment(t)
 
+	return startServerWithEnvironment(ctx, t, env)
+}
+
+// nolint:thelper
+func startServerWithEnvironment(ctx context.Context, t *testing.T, env *repotesting.Environment) *repo.APIServerInfo {
+	return startServerWithAuthorizer(ctx, t, env, auth.LegacyAuthorizer())
+}
+
+// nolint:thelper
+func startServerWithAuthorizer(ctx context.Context, t *testing.T, env *repotesting.Environment, authorizer auth.Authorizer) *repo.APIServerInfo {
 	s, err := server.New(ctx, server.Options{
 		ConfigFile:      env.ConfigFile(),
 		PasswordPersist: passwordpersist.File,
-		Authorizer:      auth.LegacyAuthorizer(),
+		Authorizer:      authorizer,
 		Authenticator: auth.CombineAuthenticators(
 			auth.AuthenticateSingleUser(testUsername+"@"+testHostname, testPassword),
 			auth.AuthenticateSingleUser(testUIUsername, testUIPassword),
@@ -61,7 +75,10 @@ func startServer(ctx context.Context, t *testing.T) *repo.APIServerInfo {
 	s.SetRepository(ctx, env.Repository)
 
 	// ensure we disconnect the repository before shutting down the server.
-	t.Cleanup(func() { s.SetRepository(ctx, nil) })
+	t.Cleanup(func() {
+		s.SetRepository(ctx, nil)
+		s.Close(ctx)
+	})
 
 	if err != nil {
 		t.Fatal(err)
@@ -150,6 +167,7 @@ func TestServerUIAccessDeniedToRemoteUser(t *testing.T) {
 	// remote user calls them.
 	getUrls := map[string]int{
 		"mounts":          http.StatusOK,
+		"log-levels":      http.StatusOK,
 		"repo/algorithms": http.StatusOK,
 		"objects/abcd":    http.StatusNotFound,
 		"tasks-summary":   http.StatusOK,
@@ -179,6 +197,34 @@ func TestServerUIAccessDeniedToRemoteUser(t *testing.T) {
 	}
 }
 
+func TestServerLogLevels(t *testing.T) {
+	ctx := testlogging.Context(t)
+	si := startServer(ctx, t)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             si.BaseURL,
+		TrustedServerCertificateFingerprint: si.TrustedServerCertificateFingerprint,
+		Username:                            testUIUsername,
+		Password:                            testUIPassword,
+	})
+	require.NoError(t, err)
+
+	_, err = serverapi.SetLogLevel(ctx, cli, &serverapi.ModuleLogLevel{Module: "kopia/server", Level: "invalid"})
+	require.Error(t, err)
+
+	resp, err := serverapi.SetLogLevel(ctx, cli, &serverapi.ModuleLogLevel{Module: "kopia/server", Level: "debug"})
+	require.NoError(t, err)
+	require.Contains(t, resp.Levels, serverapi.ModuleLogLevel{Module: "kopia/server", Level: "debug"})
+
+	resp, err = serverapi.SetLogLevel(ctx, cli, &serverapi.ModuleLogLevel{Module: "kopia/server"})
+	require.NoError(t, err)
+	require.NotContains(t, resp.Levels, serverapi.ModuleLogLevel{Module: "kopia/server", Level: "debug"})
+
+	resp, err = serverapi.GetLogLevels(ctx, cli)
+	require.NoError(t, err)
+	require.Empty(t, resp.Levels)
+}
+
 // nolint:thelper
 func remoteRepositoryTest(ctx context.Context, t *testing.T, rep repo.Repository) {
 	mustListSnapshotCount(ctx, t, rep, 0)
@@ -353,3 +399,213 @@ func mustManifestNotFound(t *testing.T, err error) {
 		t.Fatalf("invalid error %v, wanted manifest not found", err)
 	}
 }
+
+func TestServerRetentionPreview(t *testing.T) {
+	ctx := testlogging.Context(t)
+	si := startServer(ctx, t)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             si.BaseURL,
+		TrustedServerCertificateFingerprint: si.TrustedServerCertificateFingerprint,
+		Username:                            testUIUsername,
+		Password:                            testUIPassword,
+	})
+	require.NoError(t, err)
+
+	src := snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: testPathname}
+
+	_, err = serverapi.PreviewRetention(ctx, cli, src, "no-such-template")
+	require.Error(t, err)
+
+	resp, err := serverapi.PreviewRetention(ctx, cli, src)
+	require.NoError(t, err)
+	require.Len(t, resp.Previews, len(policy.RetentionTemplateNames()))
+
+	resp, err = serverapi.PreviewRetention(ctx, cli, src, "gfs")
+	require.NoError(t, err)
+	require.Len(t, resp.Previews, 1)
+	require.Equal(t, "gfs", resp.Previews[0].Template)
+	require.Empty(t, resp.Previews[0].Snapshots)
+}
+
+func TestServerMaintenanceInfo(t *testing.T) {
+	ctx := testlogging.Context(t)
+	si := startServer(ctx, t)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             si.BaseURL,
+		TrustedServerCertificateFingerprint: si.TrustedServerCertificateFingerprint,
+		Username:                            testUIUsername,
+		Password:                            testUIPassword,
+	})
+	require.NoError(t, err)
+
+	resp, err := serverapi.MaintenanceInfo(ctx, cli)
+	require.NoError(t, err)
+	require.True(t, resp.QuickCycle.Enabled)
+	require.True(t, resp.FullCycle.Enabled)
+	require.False(t, resp.QuickCycle.Overdue)
+	require.NotNil(t, resp.Runs)
+	require.Empty(t, resp.FailedTasks)
+}
+
+func TestServerLegalHold_REST(t *testing.T) {
+	testServerLegalHold(t, true)
+}
+
+func TestServerLegalHold_GRPC(t *testing.T) {
+	testServerLegalHold(t, false)
+}
+
+// nolint:thelper
+func testServerLegalHold(t *testing.T, disableGRPC bool) {
+	ctx, env := repotesting.NewEnvironment(t)
+	apiServerInfo := startServerWithEnvironment(ctx, t, env)
+
+	apiServerInfo.DisableGRPC = disableGRPC
+
+	rep, err := repo.OpenAPIServer(ctx, apiServerInfo, repo.ClientOptions{
+		Username: testUsername,
+		Hostname: testHostname,
+	}, &content.CachingOptions{
+		CacheDirectory:    testutil.TempDirectory(t),
+		MaxCacheSizeBytes: maxCacheSizeBytes,
+	}, testPassword)
+	require.NoError(t, err)
+
+	defer rep.Close(ctx)
+
+	var snapID manifest.ID
+
+	require.NoError(t, repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(w repo.RepositoryWriter) error {
+		snapID, err = snapshot.SaveSnapshot(ctx, w, &snapshot.Manifest{
+			Source:    snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: testPathname},
+			StartTime: clock.Now(),
+			EndTime:   clock.Now(),
+			RootEntry: &snapshot.DirEntry{Type: snapshot.EntryTypeDirectory, ObjectID: "k1234"},
+		})
+
+		return err
+	}))
+
+	// owners of snapshots can't place legal holds without explicit ACLs.
+	require.Error(t, repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(w repo.RepositoryWriter) error {
+		_, err := snapshot.AddLegalHold(ctx, w, snapID, "some-reason")
+		return err
+	}))
+
+	h, err := snapshot.AddLegalHold(ctx, env.RepositoryWriter, snapID, "some-reason")
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	// legal holds are visible to remote users, so that retention policy keeps held snapshots.
+	held, err := snapshot.SnapshotsUnderLegalHold(ctx, rep)
+	require.NoError(t, err)
+	require.True(t, held[snapID])
+
+	zero := 0
+	one := 1
+
+	require.NoError(t, repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(w repo.RepositoryWriter) error {
+		if _, err := snapshot.SaveSnapshot(ctx, w, &snapshot.Manifest{
+			Source:    snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: testPathname},
+			StartTime: clock.Now().Add(time.Hour),
+			EndTime:   clock.Now().Add(time.Hour),
+			RootEntry: &snapshot.DirEntry{Type: snapshot.EntryTypeDirectory, ObjectID: "k1234"},
+		}); err != nil {
+			return err
+		}
+
+		return policy.SetPolicy(ctx, w, snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: testPathname}, &policy.Policy{
+			RetentionPolicy: policy.RetentionPolicy{
+				KeepLatest:  &one,
+				KeepHourly:  &zero,
+				KeepDaily:   &zero,
+				KeepWeekly:  &zero,
+				KeepMonthly: &zero,
+				KeepAnnual:  &zero,
+			},
+		})
+	}))
+
+	require.NoError(t, repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(w repo.RepositoryWriter) error {
+		deleted, err := policy.ApplyRetentionPolicy(ctx, w, snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: testPathname}, true)
+		require.Empty(t, deleted)
+
+		return err
+	}))
+
+	// server refuses to delete snapshots under legal hold, even though the user has full access to them.
+	require.ErrorIs(t, repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(w repo.RepositoryWriter) error {
+		return w.DeleteManifest(ctx, snapID)
+	}), snapshot.ErrSnapshotUnderLegalHold)
+
+	// owners of snapshots can't remove legal holds either.
+	require.Error(t, repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(w repo.RepositoryWriter) error {
+		return snapshot.RemoveLegalHold(ctx, w, h.ID)
+	}))
+
+	require.NoError(t, snapshot.RemoveLegalHold(ctx, env.RepositoryWriter, h.ID))
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	require.NoError(t, repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(w repo.RepositoryWriter) error {
+		return w.DeleteManifest(ctx, snapID)
+	}))
+}
+
+func TestServerAppendOnlyUser_REST(t *testing.T) {
+	testServerAppendOnlyUser(t, true)
+}
+
+func TestServerAppendOnlyUser_GRPC(t *testing.T) {
+	testServerAppendOnlyUser(t, false)
+}
+
+// nolint:thelper
+func testServerAppendOnlyUser(t *testing.T, disableGRPC bool) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	require.NoError(t, user.SetUserProfile(ctx, env.RepositoryWriter, &user.Profile{Username: testUsername + "@" + testHostname, AppendOnly: true}))
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	apiServerInfo := startServerWithAuthorizer(ctx, t, env, auth.DefaultAuthorizer())
+
+	apiServerInfo.DisableGRPC = disableGRPC
+
+	rep, err := repo.OpenAPIServer(ctx, apiServerInfo, repo.ClientOptions{
+		Username: testUsername,
+		Hostname: testHostname,
+	}, &content.CachingOptions{
+		CacheDirectory:    testutil.TempDirectory(t),
+		MaxCacheSizeBytes: maxCacheSizeBytes,
+	}, testPassword)
+	require.NoError(t, err)
+
+	defer rep.Close(ctx)
+
+	src := snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: testPathname}
+
+	require.NoError(t, repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(w repo.RepositoryWriter) error {
+		_, err := snapshot.SaveSnapshot(ctx, w, &snapshot.Manifest{
+			Source:    src,
+			StartTime: clock.Now(),
+			EndTime:   clock.Now(),
+			RootEntry: &snapshot.DirEntry{Type: snapshot.EntryTypeDirectory, ObjectID: "k1234"},
+		})
+
+		return err
+	}))
+
+	zero := 0
+
+	// append-only users can't shorten retention of their own snapshots by writing a newer policy.
+	require.Error(t, repo.WriteSession(ctx, rep, repo.WriteSessionOptions{}, func(w repo.RepositoryWriter) error {
+		return policy.SetPolicy(ctx, w, src, &policy.Policy{
+			RetentionPolicy: policy.RetentionPolicy{KeepLatest: &zero},
+		})
+	}))
+
+	// but can still read the effective policy.
+	_, _, err = policy.GetEffectivePolicy(ctx, rep, src)
+	require.NoError(t, err)
+}
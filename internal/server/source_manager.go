@@ -18,6 +18,7 @@
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/snapshothealth"
 )
 
 const (
@@ -55,6 +56,9 @@ type sourceManager struct {
 
 	progress    *snapshotfs.CountingUploadProgress
 	currentTask string
+
+	// number of consecutive snapshot attempts that failed without saving a manifest.
+	failedAttempts int
 }
 
 func (s *sourceManager) Status() *serverapi.SourceStatus {
@@ -86,6 +90,26 @@ func (s *sourceManager) setStatus(stat string) {
 	s.state = stat
 }
 
+func (s *sourceManager) getFailedAttempts() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.failedAttempts
+}
+
+func (s *sourceManager) setFailedAttempts(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failedAttempts = n
+}
+
+// health evaluates the health of the source, including failed attempts that did not produce snapshots.
+func (s *sourceManager) health(ctx context.Context, now time.Time, th snapshothealth.Thresholds) (*snapshothealth.SourceHealth, error) {
+	// nolint:wrapcheck
+	return snapshothealth.EvaluateSource(ctx, s.server.rep, s.src, s.getFailedAttempts(), now, th)
+}
+
 func (s *sourceManager) currentUploader() *snapshotfs.Uploader {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -151,8 +175,10 @@ func (s *sourceManager) runLocal(ctx context.Context) {
 			if err := s.snapshot(ctx); err != nil {
 				log(ctx).Errorf("snapshot error: %v", err)
 
+				s.setFailedAttempts(s.getFailedAttempts() + 1)
 				s.backoffBeforeNextSnapshot()
 			} else {
+				s.setFailedAttempts(0)
 				s.refreshStatus(ctx)
 			}
 		}
@@ -229,14 +255,68 @@ func (s *sourceManager) snapshot(ctx context.Context) error {
 	s.server.beginUpload(ctx, s.src)
 	defer s.server.endUpload(ctx, s.src)
 
-	// nolint:wrapcheck
-	return s.server.taskmgr.Run(ctx,
+	startTime := clock.Now()
+
+	var (
+		manifest *snapshot.Manifest
+		started  bool
+	)
+
+	err := s.server.taskmgr.Run(ctx,
 		"Snapshot",
-		fmt.Sprintf("%v at %v", s.src, clock.Now().Format(time.RFC3339)),
-		s.snapshotInternal)
+		fmt.Sprintf("%v at %v", s.src, startTime.Format(time.RFC3339)),
+		func(ctx context.Context, ctrl uitask.Controller) error {
+			return s.snapshotInternal(ctx, ctrl, &started, &manifest)
+		})
+
+	if started {
+		s.saveRunResult(ctx, startTime, manifest, err)
+	}
+
+	// nolint:wrapcheck
+	return err
+}
+
+// saveRunResult persists the result of a snapshot run in the repository, so that history of
+// runs including failed ones is available after server restarts.
+func (s *sourceManager) saveRunResult(ctx context.Context, startTime time.Time, man *snapshot.Manifest, runErr error) {
+	c := s.progress.Snapshot()
+
+	r := &snapshot.RunResult{
+		Source:            s.src,
+		StartTime:         startTime,
+		EndTime:           clock.Now(),
+		HashedFiles:       c.TotalHashedFiles,
+		CachedFiles:       c.TotalCachedFiles,
+		HashedBytes:       c.TotalHashedBytes,
+		CachedBytes:       c.TotalCachedBytes,
+		UploadedBytes:     c.TotalUploadedBytes,
+		IgnoredErrorCount: c.IgnoredErrorCount,
+		FatalErrorCount:   c.FatalErrorCount,
+	}
+
+	if man != nil {
+		r.SnapshotID = man.ID
+		r.IncompleteReason = man.IncompleteReason
+	}
+
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+
+	if err := repo.WriteSession(ctx, s.server.rep, repo.WriteSessionOptions{
+		Purpose: "Source Manager Run Result",
+	}, func(w repo.RepositoryWriter) error {
+		_, err := snapshot.SaveRunResult(ctx, w, r, 0)
+
+		// nolint:wrapcheck
+		return err
+	}); err != nil {
+		log(ctx).Errorf("unable to save run result for %v: %v", s.src, err)
+	}
 }
 
-func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Controller) error {
+func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Controller, started *bool, result **snapshot.Manifest) error {
 	s.setStatus("UPLOADING")
 
 	s.currentTask = ctrl.CurrentTaskID()
@@ -252,7 +332,12 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 	default:
 	}
 
-	localEntry, err := localfs.NewEntry(s.src.Path)
+	*started = true
+
+	// reset counters, so that they reflect this run even if it fails before upload starts.
+	s.progress.UploadStarted()
+
+	localEntry, err := localfs.NewMetadataCache(localfs.DefaultMetadataCacheSize).NewEntry(s.src.Path)
 	if err != nil {
 		return errors.Wrap(err, "unable to create local filesystem")
 	}
@@ -302,6 +387,8 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 			return errors.Wrap(err, "unable to save snapshot")
 		}
 
+		*result = manifest
+
 		if _, err := policy.ApplyRetentionPolicy(ctx, w, s.src, true); err != nil {
 			return errors.Wrap(err, "unable to apply retention policy")
 		}
@@ -312,29 +399,12 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 }
 
 func (s *sourceManager) findClosestNextSnapshotTime() *time.Time {
-	var nextSnapshotTime *time.Time
-
-	// compute next snapshot time based on interval
-	if interval := s.pol.IntervalSeconds; interval != 0 {
-		interval := time.Duration(interval) * time.Second
-		nt := s.lastSnapshot.StartTime.Add(interval).Truncate(interval)
-		nextSnapshotTime = &nt
-	}
-
-	for _, tod := range s.pol.TimesOfDay {
-		nowLocalTime := clock.Now().Local()
-		localSnapshotTime := time.Date(nowLocalTime.Year(), nowLocalTime.Month(), nowLocalTime.Day(), tod.Hour, tod.Minute, 0, 0, time.Local)
-
-		if tod.Hour < nowLocalTime.Hour() || (tod.Hour == nowLocalTime.Hour() && tod.Minute < nowLocalTime.Minute()) {
-			localSnapshotTime = localSnapshotTime.Add(oneDay)
-		}
-
-		if nextSnapshotTime == nil || localSnapshotTime.Before(*nextSnapshotTime) {
-			nextSnapshotTime = &localSnapshotTime
-		}
+	nt, ok := s.pol.NextSnapshotTime(s.lastSnapshot.StartTime, clock.Now())
+	if !ok {
+		return nil
 	}
 
-	return nextSnapshotTime
+	return &nt
 }
 
 func (s *sourceManager) refreshStatus(ctx context.Context) {
@@ -437,6 +507,12 @@ func (t *uitaskProgress) HashedBytes(numBytes int64) {
 	t.maybeReport()
 }
 
+// StreamedBytes is emitted while reading a streaming file.
+func (t *uitaskProgress) StreamedBytes(fname string, totalBytes int64) {
+	t.p.StreamedBytes(fname, totalBytes)
+	t.maybeReport()
+}
+
 // Error is emitted when an error is encountered.
 func (t *uitaskProgress) Error(path string, err error, isIgnored bool) {
 	t.p.Error(path, err, isIgnored)
@@ -2,6 +2,8 @@
 
 import (
 	"context"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -10,6 +12,7 @@
 	"github.com/kopia/kopia/internal/uitask"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshothealth"
 )
 
 // CreateSnapshotSource creates snapshot source with a given path.
@@ -52,6 +55,49 @@ func UploadSnapshots(ctx context.Context, c *apiclient.KopiaAPIClient, match *sn
 	return resp, nil
 }
 
+// SourcesHealth returns the health report of matching sources.
+func SourcesHealth(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*snapshothealth.Report, error) {
+	resp := &snapshothealth.Report{}
+	if err := c.Get(ctx, "sources/health"+matchSourceParameters(match), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "SourcesHealth")
+	}
+
+	return resp, nil
+}
+
+// DeletedEntries returns files and directories which are present in older snapshots of the provided source,
+// but not in the latest one.
+func DeletedEntries(ctx context.Context, c *apiclient.KopiaAPIClient, src snapshot.SourceInfo, maxResults int) (*DeletedEntriesResponse, error) {
+	q := url.Values{}
+	q.Set("host", src.Host)
+	q.Set("userName", src.UserName)
+	q.Set("path", src.Path)
+	q.Set("maxResults", strconv.Itoa(maxResults))
+
+	resp := &DeletedEntriesResponse{}
+	if err := c.Get(ctx, "sources/deleted?"+q.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "DeletedEntries")
+	}
+
+	return resp, nil
+}
+
+// SourceRuns returns results of recent snapshot runs of the provided source performed by the server, most recent first.
+func SourceRuns(ctx context.Context, c *apiclient.KopiaAPIClient, src snapshot.SourceInfo, maxResults int) (*SourceRunsResponse, error) {
+	q := url.Values{}
+	q.Set("host", src.Host)
+	q.Set("userName", src.UserName)
+	q.Set("path", src.Path)
+	q.Set("maxResults", strconv.Itoa(maxResults))
+
+	resp := &SourceRunsResponse{}
+	if err := c.Get(ctx, "sources/runs?"+q.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "SourceRuns")
+	}
+
+	return resp, nil
+}
+
 // CancelUpload cancels snapshot upload on matching snapshots.
 func CancelUpload(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*MultipleSourceActionResponse, error) {
 	resp := &MultipleSourceActionResponse{}
@@ -96,6 +142,76 @@ func Status(ctx context.Context, c *apiclient.KopiaAPIClient) (*StatusResponse,
 	return resp, nil
 }
 
+// RepoStats invokes the 'repo/stats' API.
+func RepoStats(ctx context.Context, c *apiclient.KopiaAPIClient) (*RepoStatsResponse, error) {
+	resp := &RepoStatsResponse{}
+	if err := c.Get(ctx, "repo/stats", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "RepoStats")
+	}
+
+	return resp, nil
+}
+
+// CheckStorage invokes the 'repo/check-storage' API.
+func CheckStorage(ctx context.Context, c *apiclient.KopiaAPIClient) (*CheckStorageResponse, error) {
+	resp := &CheckStorageResponse{}
+	if err := c.Post(ctx, "repo/check-storage", &Empty{}, resp); err != nil {
+		return nil, errors.Wrap(err, "CheckStorage")
+	}
+
+	return resp, nil
+}
+
+// CompareSnapshots invokes the 'snapshots/compare' API.
+func CompareSnapshots(ctx context.Context, c *apiclient.KopiaAPIClient, req *SnapshotCompareRequest) (*SnapshotCompareResponse, error) {
+	resp := &SnapshotCompareResponse{}
+	if err := c.Post(ctx, "snapshots/compare", req, resp); err != nil {
+		return nil, errors.Wrap(err, "CompareSnapshots")
+	}
+
+	return resp, nil
+}
+
+// MaintenanceInfo invokes the 'repo/maintenance' API.
+func MaintenanceInfo(ctx context.Context, c *apiclient.KopiaAPIClient) (*MaintenanceInfoResponse, error) {
+	resp := &MaintenanceInfoResponse{}
+	if err := c.Get(ctx, "repo/maintenance", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "MaintenanceInfo")
+	}
+
+	return resp, nil
+}
+
+// GetLogLevels returns log level overrides of the server.
+func GetLogLevels(ctx context.Context, c *apiclient.KopiaAPIClient) (*LogLevelsResponse, error) {
+	resp := &LogLevelsResponse{}
+	if err := c.Get(ctx, "log-levels", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetLogLevels")
+	}
+
+	return resp, nil
+}
+
+// SetLogLevel changes the log level of a single module of the server.
+func SetLogLevel(ctx context.Context, c *apiclient.KopiaAPIClient, req *ModuleLogLevel) (*LogLevelsResponse, error) {
+	resp := &LogLevelsResponse{}
+	if err := c.Put(ctx, "log-levels", req, resp); err != nil {
+		return nil, errors.Wrap(err, "SetLogLevel")
+	}
+
+	return resp, nil
+}
+
+// CheckACL evaluates access of a user to a target using ACL entries stored in the repository and candidate rules.
+func CheckACL(ctx context.Context, c *apiclient.KopiaAPIClient, req *ACLCheckRequest) (*ACLCheckResponse, error) {
+	resp := &ACLCheckResponse{}
+	if err := c.Post(ctx, "acl/check", req, resp); err != nil {
+		return nil, errors.Wrap(err, "CheckACL")
+	}
+
+	return resp, nil
+}
+
 // ListSources lists the snapshot sources managed by the server.
 func ListSources(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*SourcesResponse, error) {
 	resp := &SourcesResponse{}
@@ -126,6 +242,25 @@ func ListPolicies(ctx context.Context, c *apiclient.KopiaAPIClient, match *snaps
 	return resp, nil
 }
 
+// PreviewRetention previews which snapshots of a given source would be kept by retention templates.
+func PreviewRetention(ctx context.Context, c *apiclient.KopiaAPIClient, si snapshot.SourceInfo, templates ...string) (*RetentionPreviewResponse, error) {
+	q := url.Values{}
+	q.Set("host", si.Host)
+	q.Set("userName", si.UserName)
+	q.Set("path", si.Path)
+
+	for _, t := range templates {
+		q.Add("template", t)
+	}
+
+	resp := &RetentionPreviewResponse{}
+	if err := c.Get(ctx, "policy/retention-preview?"+q.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "PreviewRetention")
+	}
+
+	return resp, nil
+}
+
 // GetObject returns the object payload.
 func GetObject(ctx context.Context, c *apiclient.KopiaAPIClient, objectID string) ([]byte, error) {
 	var b []byte
@@ -5,9 +5,14 @@
 	"time"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/diff"
+	"github.com/kopia/kopia/internal/providervalidation"
 	"github.com/kopia/kopia/internal/uitask"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
@@ -27,9 +32,83 @@ type StatusResponse struct {
 	Storage      string `json:"storage,omitempty"`
 	APIServerURL string `json:"apiServerURL,omitempty"`
 
+	Labels map[string]string `json:"labels,omitempty"`
+
 	repo.ClientOptions
 }
 
+// RepoStatsResponse is the response of 'repo/stats' HTTP API command.
+type RepoStatsResponse struct {
+	// Stats is nil if statistics are not tracked for the repository.
+	Stats *repo.Stats `json:"stats,omitempty"`
+}
+
+// CheckStorageResponse is the response of 'repo/check-storage' HTTP API command.
+type CheckStorageResponse struct {
+	Storage string                           `json:"storage"`
+	Healthy bool                             `json:"healthy"`
+	Report  *providervalidation.HealthReport `json:"report"`
+}
+
+// MaintenanceCycleInfo describes the schedule of a single maintenance cycle (quick or full).
+type MaintenanceCycleInfo struct {
+	maintenance.CycleParams
+
+	NextRun time.Time `json:"nextRun"`
+
+	// Overdue is true when the cycle is enabled but has not run for more than one interval past its scheduled time.
+	Overdue bool `json:"overdue,omitempty"`
+}
+
+// MaintenanceInfoResponse is the response of 'repo/maintenance' HTTP API command.
+type MaintenanceInfoResponse struct {
+	Owner      string               `json:"owner"`
+	QuickCycle MaintenanceCycleInfo `json:"quick"`
+	FullCycle  MaintenanceCycleInfo `json:"full"`
+	Scrub      MaintenanceCycleInfo `json:"scrub"`
+
+	// SafetyProfile is the name of the safety profile used by maintenance and Safety are its parameters,
+	// which are not set if the profile is invalid.
+	SafetyProfile string                        `json:"safetyProfile"`
+	Safety        *maintenance.SafetyParameters `json:"safety,omitempty"`
+
+	// ScrubFindings lists pack blobs that failed verification during scrubbing, the most recent first.
+	ScrubFindings []maintenance.ScrubFinding `json:"scrubFindings,omitempty"`
+
+	// Runs contains recent runs of each maintenance task, the most recent first.
+	Runs map[maintenance.TaskType][]maintenance.RunInfo `json:"runs"`
+
+	// FailedTasks lists tasks whose most recent run has failed.
+	FailedTasks []maintenance.TaskType `json:"failedTasks,omitempty"`
+}
+
+// ModuleLogLevel is a log level override of a single logging module.
+type ModuleLogLevel struct {
+	Module string `json:"module"`
+	Level  string `json:"level"` // empty to revert to the default level
+}
+
+// LogLevelsResponse is the response of 'log-levels' HTTP API command.
+type LogLevelsResponse struct {
+	Levels []ModuleLogLevel `json:"levels"`
+}
+
+// ACLCheckRequest contains request to evaluate access of a user to a target using ACL entries stored in the repository
+// and optional candidate rules.
+type ACLCheckRequest struct {
+	User   string            `json:"user"`   // username@hostname
+	Target map[string]string `json:"target"` // manifest labels or type=content
+	Access string            `json:"access"` // READ, APPEND or FULL
+
+	CandidateRules     []*acl.Entry `json:"candidateRules,omitempty"`
+	OnlyCandidateRules bool         `json:"onlyCandidateRules,omitempty"` // ignore ACL entries stored in the repository
+}
+
+// ACLCheckResponse is the response of 'acl/check' HTTP API command.
+type ACLCheckResponse struct {
+	auth.ACLDecision
+}
+
 // SourcesResponse is the response of 'sources' HTTP API command.
 type SourcesResponse struct {
 	LocalUsername string `json:"localUsername"`
@@ -52,6 +131,13 @@ type SourceStatus struct {
 	CurrentTask      string                     `json:"currentTask,omitempty"`
 }
 
+// PolicyProvenanceResponse is the response of 'policy/provenance' HTTP API command.
+type PolicyProvenanceResponse struct {
+	Target     snapshot.SourceInfo      `json:"target"`
+	Effective  *policy.Policy           `json:"effective"`
+	Provenance []policy.FieldProvenance `json:"provenance"`
+}
+
 // PolicyListEntry describes single policy.
 type PolicyListEntry struct {
 	ID     string              `json:"id"`
@@ -64,6 +150,11 @@ type PoliciesResponse struct {
 	Policies []*PolicyListEntry `json:"policies"`
 }
 
+// RetentionPreviewResponse is the response of 'policy/retention-preview' HTTP API command.
+type RetentionPreviewResponse struct {
+	Previews []*policy.RetentionPreview `json:"previews"`
+}
+
 // Empty represents empty request/response.
 type Empty struct{}
 
@@ -84,6 +175,7 @@ type Empty struct{
 	ErrorPathNotFound       APIErrorCode = "PATH_NOT_FOUND"
 	ErrorStorageConnection  APIErrorCode = "STORAGE_CONNECTION"
 	ErrorAccessDenied       APIErrorCode = "ACCESS_DENIED"
+	ErrorLegalHold          APIErrorCode = "LEGAL_HOLD"
 )
 
 // ErrorResponse represents error response.
@@ -97,6 +189,18 @@ type SourceActionResponse struct {
 	Success bool `json:"success"`
 }
 
+// DeletedEntriesResponse is the response of 'sources/deleted' HTTP API command.
+type DeletedEntriesResponse struct {
+	Source  snapshot.SourceInfo        `json:"source"`
+	Entries []*snapshotfs.DeletedEntry `json:"entries"`
+}
+
+// SourceRunsResponse is the response of 'sources/runs' HTTP API command.
+type SourceRunsResponse struct {
+	Source snapshot.SourceInfo   `json:"source"`
+	Runs   []*snapshot.RunResult `json:"runs"`
+}
+
 // MultipleSourceActionResponse contains per-source responses for all sources targeted by API command.
 type MultipleSourceActionResponse struct {
 	Sources map[string]SourceActionResponse `json:"sources"`
@@ -166,7 +270,17 @@ type SnapshotsResponse struct {
 
 // MountSnapshotRequest contains request to mount a snapshot.
 type MountSnapshotRequest struct {
-	Root string `json:"root"`
+	Root    string        `json:"root"`
+	Options *MountOptions `json:"options,omitempty"`
+}
+
+// MountOptions contains optional FUSE tuning parameters for mounting a snapshot, zero values select defaults.
+type MountOptions struct {
+	AllowOther              bool `json:"allowOther,omitempty"`
+	AttrTimeoutSeconds      int  `json:"attrTimeoutSeconds,omitempty"`
+	EntryTimeoutSeconds     int  `json:"entryTimeoutSeconds,omitempty"`
+	MaxReadAhead            int  `json:"maxReadAhead,omitempty"`
+	NegativeLookupCacheSize int  `json:"negativeLookupCacheSize,omitempty"`
 }
 
 // UnmountSnapshotRequest contains request to unmount a snapshot.
@@ -214,6 +328,17 @@ type RestoreRequest struct {
 	Options restore.Options `json:"options"`
 }
 
+// SnapshotCompareRequest contains request to compare two snapshot roots (object IDs optionally followed by paths).
+type SnapshotCompareRequest struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// SnapshotCompareResponse is the response of 'snapshots/compare' HTTP API command.
+type SnapshotCompareResponse struct {
+	Changes []*diff.Change `json:"changes"`
+}
+
 // EstimateRequest contains request to estimate the size of the snapshot in a given root.
 type EstimateRequest struct {
 	Root                 string `json:"root"`
@@ -0,0 +1,68 @@
+// Package timestampmeta provides utilities for preserving blob timestamps with nanosecond precision
+// in object metadata of providers that don't allow setting modification times directly.
+package timestampmeta
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const nanosDigits = 9
+
+// ToMap returns a map containing single entry representing the provided time or nil map if the time is zero.
+func ToMap(t time.Time, mapKey string) map[string]string {
+	if t.IsZero() {
+		return nil
+	}
+
+	return map[string]string{
+		mapKey: ToValue(t),
+	}
+}
+
+// ToValue returns the provided time as a string of seconds since Unix epoch with exactly 9 fractional digits.
+func ToValue(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// FromValue attempts to convert the provided value stored in metadata into time.Time.
+func FromValue(v string) (t time.Time, ok bool) {
+	secStr, fracStr := v, ""
+	if p := strings.IndexByte(v, '.'); p >= 0 {
+		secStr, fracStr = v[0:p], v[p+1:]
+	}
+
+	sec, err := strconv.ParseInt(secStr, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if len(fracStr) > nanosDigits {
+		return time.Time{}, false
+	}
+
+	var nanos int64
+
+	if fracStr != "" {
+		nanos, err = strconv.ParseInt(fracStr+strings.Repeat("0", nanosDigits-len(fracStr)), 10, 64)
+		if err != nil || nanos < 0 {
+			return time.Time{}, false
+		}
+	}
+
+	return time.Unix(sec, nanos), true
+}
+
+// FromMap returns the time stored in the provided map under a given key, looked up case-insensitively
+// since some providers canonicalize metadata keys.
+func FromMap(m map[string]string, mapKey string) (t time.Time, ok bool) {
+	for k, v := range m {
+		if strings.EqualFold(k, mapKey) {
+			return FromValue(v)
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,47 @@
+package timestampmeta_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/timestampmeta"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []time.Time{
+		time.Unix(1609459200, 123456789),
+		time.Unix(1609459200, 0),
+		time.Unix(1609459200, 1),
+		time.Unix(-1, 999999999),
+	} {
+		m := timestampmeta.ToMap(tc, "mtime")
+
+		got, ok := timestampmeta.FromMap(m, "MTime")
+		require.True(t, ok, tc)
+		require.True(t, got.Equal(tc), "got %v, want %v", got, tc)
+	}
+
+	require.Nil(t, timestampmeta.ToMap(time.Time{}, "mtime"))
+}
+
+func TestFromValue(t *testing.T) {
+	cases := map[string]time.Time{
+		"1609459200":           time.Unix(1609459200, 0),
+		"1609459200.5":         time.Unix(1609459200, 500000000),
+		"1609459200.000001":    time.Unix(1609459200, 1000),
+		"1609459200.123456789": time.Unix(1609459200, 123456789),
+	}
+
+	for v, want := range cases {
+		got, ok := timestampmeta.FromValue(v)
+		require.True(t, ok, v)
+		require.True(t, got.Equal(want), "%v: got %v, want %v", v, got, want)
+	}
+
+	for _, v := range []string{"", "abc", "1.2.3", "1.1234567890", "1.-5", "1.x"} {
+		_, ok := timestampmeta.FromValue(v)
+		require.False(t, ok, v)
+	}
+}
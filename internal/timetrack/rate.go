@@ -0,0 +1,57 @@
+package timetrack
+
+import (
+	"math"
+	"time"
+)
+
+// SmoothedRate computes an exponentially-smoothed rate of change of a monotonically increasing counter.
+// It is not safe for concurrent use.
+type SmoothedRate struct {
+	// HalfLife is the time after which the weight of older samples drops by half.
+	HalfLife time.Duration
+
+	lastTime  time.Time
+	lastValue float64
+	rate      float64
+
+	hasSample bool
+	hasRate   bool
+}
+
+// Update records the value of the counter at a given time and returns the smoothed rate per second.
+func (r *SmoothedRate) Update(now time.Time, value float64) float64 {
+	if !r.hasSample {
+		r.lastTime = now
+		r.lastValue = value
+		r.hasSample = true
+
+		return r.rate
+	}
+
+	dt := now.Sub(r.lastTime)
+	if dt <= 0 {
+		return r.rate
+	}
+
+	instant := (value - r.lastValue) / dt.Seconds()
+
+	if r.hasRate && r.HalfLife > 0 {
+		// weight the new sample by the time elapsed since the previous one, so irregular sampling does not skew the rate.
+		alpha := 1 - math.Pow(0.5, dt.Seconds()/r.HalfLife.Seconds()) //nolint:gomnd
+		r.rate += alpha * (instant - r.rate)
+	} else {
+		r.rate = instant
+		r.hasRate = true
+	}
+
+	r.lastTime = now
+	r.lastValue = value
+
+	return r.rate
+}
+
+// Rate returns the most recently computed rate per second.
+func (r *SmoothedRate) Rate() float64 {
+	return r.rate
+}
@@ -11,6 +11,7 @@
 	"crypto/x509/pkix"
 	"encoding/hex"
 	"encoding/pem"
+	"io/ioutil"
 	"math/big"
 	"net"
 	"net/http"
@@ -30,43 +31,92 @@
 func GenerateServerCertificate(ctx context.Context, keySize int, certValid time.Duration, names []string) (*x509.Certificate, *rsa.PrivateKey, error) {
 	log(ctx).Debugf("generating new TLS certificate")
 
-	priv, err := rsa.GenerateKey(rand.Reader, keySize)
+	template, err := certificateTemplate(certValid)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "unable to generate RSA key")
+		return nil, nil, err
+	}
+
+	template.KeyUsage = x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	template.BasicConstraintsValid = true
+
+	for _, n := range names {
+		if ip := net.ParseIP(n); ip != nil {
+			log(ctx).Debugf("adding alternative IP to certificate: %v", ip)
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			log(ctx).Debugf("adding alternative DNS name to certificate: %v", n)
+			template.DNSNames = append(template.DNSNames, n)
+		}
+	}
+
+	return createCertificate(keySize, template, nil, nil)
+}
+
+// GenerateCertificateAuthority generates random certificate authority that can be used to issue client certificates.
+func GenerateCertificateAuthority(ctx context.Context, keySize int, certValid time.Duration) (*x509.Certificate, *rsa.PrivateKey, error) {
+	log(ctx).Debugf("generating new certificate authority")
+
+	template, err := certificateTemplate(certValid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template.Subject.CommonName = "Kopia Client CA"
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign
+	template.BasicConstraintsValid = true
+	template.IsCA = true
+
+	return createCertificate(keySize, template, nil, nil)
+}
+
+// GenerateClientCertificate generates TLS client certificate for the provided common name signed by the provided certificate authority.
+func GenerateClientCertificate(ctx context.Context, keySize int, certValid time.Duration, commonName string, ca *x509.Certificate, caKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey, error) {
+	log(ctx).Debugf("generating new TLS client certificate for %v", commonName)
+
+	template, err := certificateTemplate(certValid)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	template.Subject.CommonName = commonName
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	return createCertificate(keySize, template, ca, caKey)
+}
+
+func certificateTemplate(certValid time.Duration) (*x509.Certificate, error) {
 	notBefore := clock.Now()
 	notAfter := notBefore.Add(certValid)
 
 	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "unable to generate serial number")
+		return nil, errors.Wrap(err, "unable to generate serial number")
 	}
 
-	template := x509.Certificate{
+	return &x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject: pkix.Name{
 			Organization: []string{"Kopia"},
 		},
 		NotBefore: notBefore,
 		NotAfter:  notAfter,
+	}, nil
+}
 
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		BasicConstraintsValid: true,
+// createCertificate creates a certificate from the template signed by the parent or self-signed if parent is nil.
+func createCertificate(keySize int, template, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to generate RSA key")
 	}
 
-	for _, n := range names {
-		if ip := net.ParseIP(n); ip != nil {
-			log(ctx).Debugf("adding alternative IP to certificate: %v", ip)
-			template.IPAddresses = append(template.IPAddresses, ip)
-		} else {
-			log(ctx).Debugf("adding alternative DNS name to certificate: %v", n)
-			template.DNSNames = append(template.DNSNames, n)
-		}
+	if parent == nil {
+		parent, parentKey = template, priv
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, priv.Public(), priv)
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, parent, priv.Public(), parentKey)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "failed to create certificate")
 	}
@@ -114,6 +164,36 @@ func WriteCertificateToFile(fname string, cert *x509.Certificate) error {
 	return nil
 }
 
+// LoadClientCertificates loads TLS client certificate and key from the provided PEM files.
+// It returns no certificates if certFile is empty.
+func LoadClientCertificates(certFile, keyFile string) ([]tls.Certificate, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load client certificate")
+	}
+
+	return []tls.Certificate{cert}, nil
+}
+
+// LoadCertificatePool loads the pool of certificates from the provided PEM file.
+func LoadCertificatePool(fname string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(fname) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read certificates")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.Errorf("no certificates found in %v", fname)
+	}
+
+	return pool, nil
+}
+
 // TLSConfigTrustingSingleCertificate return tls.Config which trusts exactly one TLS certificate with
 // provided SHA256 fingerprint.
 func TLSConfigTrustingSingleCertificate(sha256Fingerprint string) *tls.Config {
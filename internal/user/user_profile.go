@@ -11,6 +11,13 @@ type Profile struct {
 	Username            string `json:"username"`
 	PasswordHashVersion int    `json:"passwordHashVersion"` // indicates how password is hashed
 	PasswordHash        []byte `json:"passwordHash"`
+
+	// AppendOnly restricts the user to creating snapshots and reading own data, the server rejects
+	// attempts to delete or replace manifests on behalf of such users.
+	AppendOnly bool `json:"appendOnly,omitempty"`
+
+	// EventWebhookURL receives server connection events of the user as JSON POST requests.
+	EventWebhookURL string `json:"eventWebhookURL,omitempty"`
 }
 
 // SetPassword changes the password for a user profile.
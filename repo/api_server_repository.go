@@ -5,6 +5,7 @@
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"time"
 
@@ -14,6 +15,7 @@
 	"github.com/kopia/kopia/internal/cache"
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/remoterepoapi"
+	"github.com/kopia/kopia/internal/tlsutil"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/hashing"
 	"github.com/kopia/kopia/repo/manifest"
@@ -25,6 +27,8 @@ type APIServerInfo struct {
 	BaseURL                             string `json:"url"`
 	TrustedServerCertificateFingerprint string `json:"serverCertFingerprint"`
 	DisableGRPC                         bool   `json:"disableGRPC,omitempty"`
+	ClientCertificateFile               string `json:"clientCertFile,omitempty"`
+	ClientKeyFile                       string `json:"clientKeyFile,omitempty"`
 }
 
 // remoteRepository is an implementation of Repository that connects to an instance of
@@ -121,7 +125,15 @@ func (r *apiServerRepository) FindManifests(ctx context.Context, labels map[stri
 }
 
 func (r *apiServerRepository) DeleteManifest(ctx context.Context, id manifest.ID) error {
-	return errors.Wrap(r.cli.Delete(ctx, "manifests/"+string(id), manifest.ErrNotFound, nil, nil), "DeleteManifest")
+	err := r.cli.Delete(ctx, "manifests/"+string(id), manifest.ErrNotFound, nil, nil)
+
+	// the server responds with 409 Conflict when the snapshot is under legal hold.
+	var se apiclient.HTTPStatusError
+	if errors.As(err, &se) && se.HTTPStatusCode == http.StatusConflict {
+		err = errors.Wrap(ErrUnderLegalHold, se.ErrorMessage)
+	}
+
+	return errors.Wrap(err, "DeleteManifest")
 }
 
 func (r *apiServerRepository) Time() time.Time {
@@ -232,15 +244,9 @@ func (r *apiServerRepository) Close(ctx context.Context) error {
 
 // openRestAPIRepository connects remote repository over Kopia API.
 func openRestAPIRepository(ctx context.Context, si *APIServerInfo, cliOpts ClientOptions, contentCache *cache.PersistentCache, password string) (Repository, error) {
-	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
-		BaseURL:                             si.BaseURL,
-		TrustedServerCertificateFingerprint: si.TrustedServerCertificateFingerprint,
-		Username:                            cliOpts.UsernameAtHost(),
-		Password:                            password,
-		LogRequests:                         true,
-	})
+	cli, err := newAPIServerClient(si, cliOpts, password)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to create API client")
+		return nil, err
 	}
 
 	rr := &apiServerRepository{
@@ -278,6 +284,44 @@ func openRestAPIRepository(ctx context.Context, si *APIServerInfo, cliOpts Clien
 	return rr, nil
 }
 
+func newAPIServerClient(si *APIServerInfo, cliOpts ClientOptions, password string) (*apiclient.KopiaAPIClient, error) {
+	clientCerts, err := tlsutil.LoadClientCertificates(si.ClientCertificateFile, si.ClientKeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load client certificate")
+	}
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             si.BaseURL,
+		TrustedServerCertificateFingerprint: si.TrustedServerCertificateFingerprint,
+		ClientCertificates:                  clientCerts,
+		Username:                            cliOpts.UsernameAtHost(),
+		Password:                            password,
+		LogRequests:                         true,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create API client")
+	}
+
+	return cli, nil
+}
+
+// getAPIServerClientDefaults returns client defaults published by the API server, regardless of the protocol
+// used to access the repository.
+func getAPIServerClientDefaults(ctx context.Context, si *APIServerInfo, cliOpts ClientOptions, password string) (*ClientDefaults, error) {
+	cli, err := newAPIServerClient(si, cliOpts, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var p remoterepoapi.Parameters
+
+	if err := cli.Get(ctx, "repo/parameters", nil, &p); err != nil {
+		return nil, errors.Wrap(err, "unable to get repository parameters")
+	}
+
+	return clientDefaultsFromParameters(&p)
+}
+
 // ConnectAPIServer sets up repository connection to a particular API server.
 func ConnectAPIServer(ctx context.Context, configFile string, si *APIServerInfo, password string, opt *ConnectOptions) error {
 	lc := LocalConfig{
@@ -293,5 +337,20 @@ func ConnectAPIServer(ctx context.Context, configFile string, si *APIServerInfo,
 		return errors.Wrap(err, "unable to write config file")
 	}
 
-	return verifyConnect(ctx, configFile, password)
+	if _, err := verifyConnect(ctx, configFile, password); err != nil {
+		return err
+	}
+
+	if opt.ClientDefaultsOverrides == nil {
+		return nil
+	}
+
+	defaults, err := getAPIServerClientDefaults(ctx, si, lc.ClientOptions, password)
+	if err != nil {
+		// older servers don't publish client defaults.
+		log(ctx).Debugf("unable to get client defaults: %v", err)
+		return nil
+	}
+
+	return errors.Wrap(applyClientDefaults(ctx, configFile, defaults, opt.ClientDefaultsOverrides), "unable to apply client defaults")
 }
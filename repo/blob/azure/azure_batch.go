@@ -0,0 +1,242 @@
+package azure
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// maxBatchDeleteSize is the maximum number of subrequests in a single Blob Batch request.
+const maxBatchDeleteSize = 256
+
+// DeleteBlobs implements blob.BatchDeleter using the Blob Batch API.
+func (az *azStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > maxBatchDeleteSize {
+			n = maxBatchDeleteSize
+		}
+
+		if err := az.deleteBatch(ctx, ids[0:n]); err != nil {
+			return err
+		}
+
+		ids = ids[n:]
+	}
+
+	return nil
+}
+
+func (az *azStorage) deleteBatch(ctx context.Context, ids []blob.ID) error {
+	cu, err := az.containerURL()
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+	boundary := "batch_" + mw.Boundary()
+
+	if err := mw.SetBoundary(boundary); err != nil {
+		return errors.Wrap(err, "unable to set boundary")
+	}
+
+	for i, id := range ids {
+		if err := az.writeDeleteSubrequest(mw, cu, i, id); err != nil {
+			return err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return errors.Wrap(err, "unable to write batch")
+	}
+
+	u := cu.URL()
+	u.RawQuery = "restype=container&comp=batch"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return errors.Wrap(err, "unable to create batch request")
+	}
+
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+boundary)
+	req.Header.Set("Content-Length", strconv.Itoa(body.Len()))
+	req.Header.Set("x-ms-version", azblob.ServiceVersion)
+	az.signRequest(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "batch request failed")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusAccepted {
+		return errors.Errorf("batch request failed: %v", resp.Status)
+	}
+
+	return checkBatchResponse(resp, ids)
+}
+
+func (az *azStorage) writeDeleteSubrequest(mw *multipart.Writer, cu *azblob.ContainerURL, i int, id blob.ID) error {
+	u := cu.NewBlobURL(az.getObjectNameString(id)).URL()
+
+	sub, err := http.NewRequest(http.MethodDelete, u.String(), nil) //nolint:noctx
+	if err != nil {
+		return errors.Wrap(err, "unable to create subrequest")
+	}
+
+	sub.Header.Set("Content-Length", "0")
+	az.signRequest(sub)
+
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/http"},
+		"Content-Transfer-Encoding": {"binary"},
+		"Content-Id":                {strconv.Itoa(i)},
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to create part")
+	}
+
+	fmt.Fprintf(pw, "DELETE %v HTTP/1.1\r\n", u.EscapedPath())
+	fmt.Fprintf(pw, "x-ms-date: %v\r\n", sub.Header.Get("x-ms-date"))
+	fmt.Fprintf(pw, "Authorization: %v\r\n", sub.Header.Get("Authorization"))
+	fmt.Fprintf(pw, "Content-Length: 0\r\n\r\n")
+
+	return nil
+}
+
+// checkBatchResponse returns the first error reported in the multipart response to the batch request,
+// deleting blobs which do not exist is not considered an error.
+func checkBatchResponse(resp *http.Response, ids []blob.ID) error {
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return errors.Wrap(err, "invalid batch response content type")
+	}
+
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+
+	for i := 0; ; i++ {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "invalid batch response")
+		}
+
+		// parts are returned in the order of subrequests, but prefer Content-ID if present.
+		n := i
+		if v, err := strconv.Atoi(part.Header.Get("Content-Id")); err == nil {
+			n = v
+		}
+
+		// the line terminating headers of the subresponse precedes the boundary, so it's consumed by the multipart reader.
+		sub, err := http.ReadResponse(bufio.NewReader(io.MultiReader(part, strings.NewReader("\r\n"))), nil)
+		if err != nil {
+			return errors.Wrap(err, "invalid batch subresponse")
+		}
+
+		sub.Body.Close() //nolint:errcheck
+
+		switch sub.StatusCode {
+		case http.StatusAccepted, http.StatusNotFound:
+		default:
+			id := blob.ID("")
+			if n >= 0 && n < len(ids) {
+				id = ids[n]
+			}
+
+			return errors.Errorf("unable to delete blob %q: %v", id, sub.Status)
+		}
+	}
+}
+
+// signRequest adds Shared Key authorization to the request.
+// See https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func (az *azStorage) signRequest(req *http.Request) {
+	req.Header.Set("x-ms-date", clock.Now().UTC().Format(http.TimeFormat))
+
+	h := req.Header
+
+	contentLength := h.Get("Content-Length")
+	if contentLength == "0" {
+		contentLength = ""
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		h.Get("Content-Encoding"),
+		h.Get("Content-Language"),
+		contentLength,
+		h.Get("Content-MD5"),
+		h.Get("Content-Type"),
+		"", // date is provided using x-ms-date
+		h.Get("If-Modified-Since"),
+		h.Get("If-Match"),
+		h.Get("If-None-Match"),
+		h.Get("If-Unmodified-Since"),
+		h.Get("Range"),
+		canonicalizedHeaders(h),
+		az.canonicalizedResource(req.URL),
+	}, "\n")
+
+	h.Set("Authorization", "SharedKey "+az.credential.AccountName()+":"+az.credential.ComputeHMACSHA256(stringToSign))
+}
+
+func canonicalizedHeaders(h http.Header) string {
+	var lines []string
+
+	for k, v := range h {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-ms-") {
+			lines = append(lines, k+":"+strings.Join(v, ","))
+		}
+	}
+
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
+
+func (az *azStorage) canonicalizedResource(u *url.URL) string {
+	var sb strings.Builder
+
+	sb.WriteString("/" + az.credential.AccountName() + u.EscapedPath())
+
+	q := u.Query()
+
+	var names []string
+
+	for k := range q {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	for _, k := range names {
+		v := q[k]
+		sort.Strings(v)
+		sb.WriteString("\n" + strings.ToLower(k) + ":" + strings.Join(v, ","))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,45 @@
+package azure
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func batchResponse(statuses ...string) *http.Response {
+	var sb strings.Builder
+
+	for i, st := range statuses {
+		sb.WriteString("--batchresponse_1\r\n")
+		sb.WriteString("Content-Type: application/http\r\n")
+		sb.WriteString("Content-ID: " + string(rune('0'+i)) + "\r\n\r\n")
+		sb.WriteString("HTTP/1.1 " + st + "\r\n")
+		sb.WriteString("x-ms-version: 2019-12-12\r\n\r\n")
+	}
+
+	sb.WriteString("--batchresponse_1--\r\n")
+
+	return &http.Response{
+		StatusCode: http.StatusAccepted,
+		Header:     http.Header{"Content-Type": {"multipart/mixed; boundary=batchresponse_1"}},
+		Body:       ioutil.NopCloser(strings.NewReader(sb.String())),
+	}
+}
+
+func TestCheckBatchResponse(t *testing.T) {
+	ids := []blob.ID{"a", "b", "c"}
+
+	require.NoError(t, checkBatchResponse(batchResponse("202 Accepted", "202 Accepted", "202 Accepted"), ids))
+
+	// deleting blobs that don't exist is not an error.
+	require.NoError(t, checkBatchResponse(batchResponse("202 Accepted", "404 The specified blob does not exist.", "202 Accepted"), ids))
+
+	err := checkBatchResponse(batchResponse("202 Accepted", "202 Accepted", "403 Forbidden"), ids)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `"c"`)
+}
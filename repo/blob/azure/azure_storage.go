@@ -24,6 +24,9 @@
 
 const (
 	azStorageType = "azureBlob"
+
+	// copyStatusPollInterval is the interval between checks of the status of pending copy operations.
+	copyStatusPollInterval = time.Second
 )
 
 type azStorage struct {
@@ -31,7 +34,8 @@ type azStorage struct {
 
 	ctx context.Context
 
-	bucket *gblob.Bucket
+	bucket     *gblob.Bucket
+	credential *azblob.SharedKeyCredential
 
 	downloadThrottler *iothrottler.IOThrottlerPool
 	uploadThrottler   *iothrottler.IOThrottlerPool
@@ -187,6 +191,152 @@ func (az *azStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback fun
 	return nil
 }
 
+// containerURL returns the URL of the container used for operations not exposed by the bucket.
+func (az *azStorage) containerURL() (*azblob.ContainerURL, error) {
+	var cu *azblob.ContainerURL
+
+	if !az.bucket.As(&cu) {
+		return nil, errors.New("unable to access container URL")
+	}
+
+	return cu, nil
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+// Blobs that have previous versions but no current version are reported along with the most recent version.
+// Azure does not report deletion time of blobs, so DeletedAt is not set.
+func (az *azStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	cu, err := az.containerURL()
+	if err != nil {
+		return err
+	}
+
+	var (
+		latest *azblob.BlobItemInternal
+		live   bool
+	)
+
+	// versions of each blob are returned together.
+	flush := func() error {
+		if latest == nil || live || latest.VersionID == nil {
+			return nil
+		}
+
+		dm := blob.DeletedMetadata{
+			Metadata: blob.Metadata{
+				BlobID:    blob.ID(latest.Name[len(az.Prefix):]),
+				Timestamp: latest.Properties.LastModified,
+			},
+			VersionID: *latest.VersionID,
+		}
+
+		if latest.Properties.ContentLength != nil {
+			dm.Length = *latest.Properties.ContentLength
+		}
+
+		return callback(dm)
+	}
+
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := cu.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Details: azblob.BlobListingDetails{Versions: true},
+			Prefix:  az.getObjectNameString(prefix),
+		})
+		if err != nil {
+			return errors.Wrap(translateError(err), "ListBlobsFlatSegment")
+		}
+
+		for i := range resp.Segment.BlobItems {
+			bi := &resp.Segment.BlobItems[i]
+
+			if latest == nil || bi.Name != latest.Name {
+				if err := flush(); err != nil {
+					return err
+				}
+
+				latest, live = bi, false
+			}
+
+			if bi.IsCurrentVersion != nil && *bi.IsCurrentVersion {
+				live = true
+			}
+
+			// version IDs are timestamps, which sort lexicographically.
+			if bi.VersionID != nil && (latest.VersionID == nil || *bi.VersionID > *latest.VersionID) {
+				latest = bi
+			}
+		}
+
+		marker = resp.NextMarker
+	}
+
+	return flush()
+}
+
+// UndeleteBlob implements blob.Undeleter by copying the provided version over the deleted blob.
+func (az *azStorage) UndeleteBlob(ctx context.Context, b blob.ID, versionID string) error {
+	cu, err := az.containerURL()
+	if err != nil {
+		return err
+	}
+
+	blobURL := cu.NewBlobURL(az.getObjectNameString(b))
+
+	resp, err := blobURL.StartCopyFromURL(ctx, blobURL.WithVersionID(versionID).URL(), nil, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil)
+	if err != nil {
+		return errors.Wrap(translateError(err), "StartCopyFromURL")
+	}
+
+	status := resp.CopyStatus()
+
+	for status == azblob.CopyStatusPending {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "waiting for copy")
+		case <-time.After(copyStatusPollInterval):
+		}
+
+		props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return errors.Wrap(translateError(err), "GetProperties")
+		}
+
+		status = props.CopyStatus()
+	}
+
+	if status != azblob.CopyStatusSuccess {
+		return errors.Errorf("unable to copy version %v of %v: %v", versionID, b, status)
+	}
+
+	return nil
+}
+
+// PresignedGetURL implements blob.URLPresigner using a service SAS granting read access to the blob.
+func (az *azStorage) PresignedGetURL(ctx context.Context, b blob.ID, expiration time.Duration) (string, error) {
+	cu, err := az.containerURL()
+	if err != nil {
+		return "", err
+	}
+
+	objectName := az.getObjectNameString(b)
+
+	sas, err := azblob.BlobSASSignatureValues{
+		Protocol:      azblob.SASProtocolHTTPS,
+		ExpiryTime:    clock.Now().UTC().Add(expiration),
+		ContainerName: az.Container,
+		BlobName:      objectName,
+		Permissions:   azblob.BlobSASPermissions{Read: true}.String(),
+	}.NewSASQueryParameters(az.credential)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to sign URL")
+	}
+
+	u := cu.NewBlobURL(objectName).URL()
+	u.RawQuery = sas.Encode()
+
+	return u.String(), nil
+}
+
 func (az *azStorage) ConnectionInfo() blob.ConnectionInfo {
 	return blob.ConnectionInfo{
 		Type:   azStorageType,
@@ -198,6 +348,16 @@ func (az *azStorage) DisplayName() string {
 	return fmt.Sprintf("Azure: %v", az.Options.Container)
 }
 
+func (az *azStorage) Capabilities() blob.Capabilities {
+	return blob.Capabilities{
+		Versioning:     true,
+		ConditionalPut: true,
+		ServerSideCopy: true,
+		BatchDelete:    true,
+		PresignedURLs:  true,
+	}
+}
+
 func (az *azStorage) Close(ctx context.Context) error {
 	return errors.Wrap(az.bucket.Close(), "error closing bucket")
 }
@@ -240,6 +400,7 @@ func New(ctx context.Context, opt *Options) (blob.Storage, error) {
 		Options:           *opt,
 		ctx:               ctx,
 		bucket:            bucket,
+		credential:        credential,
 		downloadThrottler: downloadThrottler,
 		uploadThrottler:   uploadThrottler,
 	})
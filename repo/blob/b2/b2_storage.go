@@ -224,6 +224,12 @@ func (s *b2Storage) DisplayName() string {
 	return fmt.Sprintf("B2: %v", s.BucketName)
 }
 
+func (s *b2Storage) Capabilities() blob.Capabilities {
+	return blob.Capabilities{
+		ServerSideCopy: true,
+	}
+}
+
 func (s *b2Storage) Close(ctx context.Context) error {
 	return nil
 }
@@ -0,0 +1,376 @@
+// Package dryrun implements a wrapper around blob.Storage that performs reads but only records mutations
+// in a journal, which allows answering what a command would change in the storage.
+package dryrun
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("dry-run")
+
+// Operation is the type of storage mutation recorded in the journal.
+type Operation string
+
+// Supported operations.
+const (
+	OperationPutBlob    Operation = "put"
+	OperationDeleteBlob Operation = "delete"
+	OperationSetTime    Operation = "set-time"
+	OperationUndelete   Operation = "undelete"
+)
+
+// JournalEntry describes a single storage mutation that was recorded but not performed.
+type JournalEntry struct {
+	Operation Operation `json:"op"`
+	BlobID    blob.ID   `json:"blobID"`
+	Length    int64     `json:"length,omitempty"`
+}
+
+// pendingBlob is a blob that would have been written to the storage.
+type pendingBlob struct {
+	data      []byte
+	timestamp time.Time
+}
+
+// Storage passes reads through to the underlying storage, but records mutations in a journal without performing them.
+// Mutations are visible to subsequent reads through the wrapper, so that repository operations can proceed as
+// if the storage was modified. Data of blobs that would have been written is kept in memory.
+type Storage struct {
+	base    blob.Storage
+	timeNow func() time.Time
+
+	mu      sync.Mutex
+	pending map[blob.ID]*pendingBlob
+	deleted map[blob.ID]bool
+	times   map[blob.ID]time.Time
+	journal []JournalEntry
+}
+
+// Journal returns the list of mutations that were recorded, in the order they were requested.
+func (s *Storage) Journal() []JournalEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]JournalEntry(nil), s.journal...)
+}
+
+func (s *Storage) record(op Operation, id blob.ID, length int64) {
+	s.journal = append(s.journal, JournalEntry{op, id, length})
+}
+
+// GetBlob implements blob.Storage.
+func (s *Storage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	s.mu.Lock()
+	pb := s.pending[id]
+	deleted := s.deleted[id]
+	s.mu.Unlock()
+
+	if pb == nil {
+		if deleted {
+			return nil, blob.ErrBlobNotFound
+		}
+
+		// nolint:wrapcheck
+		return s.base.GetBlob(ctx, id, offset, length)
+	}
+
+	data := pb.data
+
+	if length < 0 {
+		return append([]byte(nil), data...), nil
+	}
+
+	if offset < 0 || offset > int64(len(data)) {
+		return nil, errors.Wrapf(blob.ErrInvalidRange, "invalid offset: %v", offset)
+	}
+
+	if offset+length > int64(len(data)) {
+		return nil, errors.Wrapf(blob.ErrInvalidRange, "invalid length: %v", length)
+	}
+
+	return append([]byte(nil), data[offset:offset+length]...), nil
+}
+
+// GetMetadata implements blob.Storage.
+func (s *Storage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pb := s.pending[id]; pb != nil {
+		return blob.Metadata{BlobID: id, Length: int64(len(pb.data)), Timestamp: pb.timestamp}, nil
+	}
+
+	if s.deleted[id] {
+		return blob.Metadata{}, blob.ErrBlobNotFound
+	}
+
+	bm, err := s.base.GetMetadata(ctx, id)
+	if err != nil {
+		return bm, err // nolint:wrapcheck
+	}
+
+	return s.adjustTimeLocked(bm), nil
+}
+
+// GetRetention implements blob.RetentionReader.
+// Blobs that would have been written are reported as not locked.
+func (s *Storage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	s.mu.Lock()
+	pb := s.pending[id]
+	deleted := s.deleted[id]
+	s.mu.Unlock()
+
+	switch {
+	case pb != nil:
+		return blob.RetentionInfo{}, nil
+	case deleted:
+		return blob.RetentionInfo{}, blob.ErrBlobNotFound
+	default:
+		// nolint:wrapcheck
+		return blob.GetRetention(ctx, s.base, id)
+	}
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+// Blobs that would have been written are not reported as deleted.
+func (s *Storage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListDeletedBlobs(ctx, s.base, prefix, func(dm blob.DeletedMetadata) error {
+		s.mu.Lock()
+		pb := s.pending[dm.BlobID]
+		s.mu.Unlock()
+
+		if pb != nil {
+			return nil
+		}
+
+		return callback(dm)
+	})
+}
+
+// UndeleteBlob implements blob.Undeleter.
+// Since the contents of previous versions are not fetched, undeleted blobs remain unreadable through the wrapper.
+func (s *Storage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	if _, ok := s.base.(blob.Undeleter); !ok || !s.base.Capabilities().Versioning {
+		return blob.ErrUndeleteUnsupported
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.deleted, id)
+	s.record(OperationUndelete, id, 0)
+
+	return nil
+}
+
+// adjustTimeLocked returns the metadata with the timestamp that would have been set using SetTime.
+func (s *Storage) adjustTimeLocked(bm blob.Metadata) blob.Metadata {
+	if t, ok := s.times[bm.BlobID]; ok {
+		bm.Timestamp = t
+	}
+
+	return bm
+}
+
+// PutBlob implements blob.Storage.
+func (s *Storage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	var b bytes.Buffer
+
+	if _, err := data.WriteTo(&b); err != nil {
+		return errors.Wrap(err, "error reading blob data")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending[id] = &pendingBlob{b.Bytes(), s.timeNow()}
+	delete(s.deleted, id)
+	delete(s.times, id)
+	s.record(OperationPutBlob, id, int64(b.Len()))
+
+	return nil
+}
+
+// DeleteBlob implements blob.Storage.
+func (s *Storage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, id)
+	delete(s.times, id)
+	s.deleted[id] = true
+	s.record(OperationDeleteBlob, id, 0)
+
+	return nil
+}
+
+// DeleteBlobs implements blob.BatchDeleter.
+func (s *Storage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	for _, id := range ids {
+		s.DeleteBlob(ctx, id) // nolint:errcheck
+	}
+
+	return nil
+}
+
+// SetTime implements blob.Storage.
+func (s *Storage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pb := s.pending[id]; pb != nil {
+		pb.timestamp = t
+	} else {
+		s.times[id] = t
+	}
+
+	s.record(OperationSetTime, id, 0)
+
+	return nil
+}
+
+// ListBlobs implements blob.Storage.
+func (s *Storage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	reported := map[blob.ID]bool{}
+
+	if err := s.base.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+		s.mu.Lock()
+		pb := s.pending[bm.BlobID]
+		skip := s.deleted[bm.BlobID]
+		bm = s.adjustTimeLocked(bm)
+		s.mu.Unlock()
+
+		if skip {
+			return nil
+		}
+
+		if pb != nil {
+			bm = blob.Metadata{BlobID: bm.BlobID, Length: int64(len(pb.data)), Timestamp: pb.timestamp}
+			reported[bm.BlobID] = true
+		}
+
+		return callback(bm)
+	}); err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	s.mu.Lock()
+
+	var added []blob.Metadata
+
+	for id, pb := range s.pending {
+		if strings.HasPrefix(string(id), string(prefix)) && !reported[id] {
+			added = append(added, blob.Metadata{BlobID: id, Length: int64(len(pb.data)), Timestamp: pb.timestamp})
+		}
+	}
+
+	s.mu.Unlock()
+
+	sort.Slice(added, func(i, j int) bool {
+		return added[i].BlobID < added[j].BlobID
+	})
+
+	for _, bm := range added {
+		if err := callback(bm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close implements blob.Storage and logs the summary of recorded mutations.
+func (s *Storage) Close(ctx context.Context) error {
+	LogJournal(ctx, s.Journal())
+
+	// nolint:wrapcheck
+	return s.base.Close(ctx)
+}
+
+// ConnectionInfo implements blob.Storage.
+func (s *Storage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+// DisplayName implements blob.Storage.
+func (s *Storage) DisplayName() string {
+	return s.base.DisplayName()
+}
+
+// Capabilities implements blob.Storage.
+func (s *Storage) Capabilities() blob.Capabilities {
+	c := s.base.Capabilities()
+
+	// listing merges blobs that would have been written, so it can't be resumed.
+	c.OrderedListing = false
+
+	// blobs which would have been written can't be read using pre-signed URLs.
+	c.PresignedURLs = false
+
+	return c
+}
+
+// LogJournal logs the recorded mutations followed by their summary.
+func LogJournal(ctx context.Context, journal []JournalEntry) {
+	var (
+		puts, deletes, setTimes, undeletes int
+		putBytes                           int64
+	)
+
+	for _, e := range journal {
+		switch e.Operation {
+		case OperationPutBlob:
+			puts++
+			putBytes += e.Length
+
+			log(ctx).Infof("DRY RUN: would write blob %v (%v)", e.BlobID, units.BytesStringBase10(e.Length))
+
+		case OperationDeleteBlob:
+			deletes++
+
+			log(ctx).Infof("DRY RUN: would delete blob %v", e.BlobID)
+
+		case OperationSetTime:
+			setTimes++
+
+			log(ctx).Infof("DRY RUN: would set time of blob %v", e.BlobID)
+
+		case OperationUndelete:
+			undeletes++
+
+			log(ctx).Infof("DRY RUN: would undelete blob %v", e.BlobID)
+		}
+	}
+
+	log(ctx).Infof("DRY RUN: %v blobs would be written (%v), %v deleted, %v undeleted and %v touched.", puts, units.BytesStringBase10(putBytes), deletes, undeletes, setTimes)
+}
+
+// NewWrapper returns a Storage wrapper that records mutations of the underlying storage without performing them.
+func NewWrapper(wrapped blob.Storage, timeNow func() time.Time) *Storage {
+	if timeNow == nil {
+		timeNow = clock.Now
+	}
+
+	return &Storage{
+		base:    wrapped,
+		timeNow: timeNow,
+		pending: map[blob.ID]*pendingBlob{},
+		deleted: map[blob.ID]bool{},
+		times:   map[blob.ID]time.Time{},
+	}
+}
+
+var _ blob.Storage = (*Storage)(nil)
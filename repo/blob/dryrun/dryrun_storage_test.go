@@ -0,0 +1,72 @@
+package dryrun
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestDryRunStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	data := blobtesting.DataMap{}
+	underlying := blobtesting.NewMapStorage(data, nil, nil)
+
+	st := NewWrapper(underlying, nil)
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	// nothing was written to the underlying storage, but all mutations were recorded.
+	require.Empty(t, data)
+	require.NotEmpty(t, st.Journal())
+
+	require.NoError(t, st.Close(ctx))
+}
+
+func TestDryRunStorageOverlay(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	data := blobtesting.DataMap{}
+	keyTime := map[blob.ID]time.Time{}
+	underlying := blobtesting.NewMapStorage(data, keyTime, nil)
+
+	require.NoError(t, underlying.PutBlob(ctx, "a1", gather.FromSlice([]byte{1, 2, 3, 4})))
+	require.NoError(t, underlying.PutBlob(ctx, "a2", gather.FromSlice([]byte{5, 6, 7, 8})))
+
+	st := NewWrapper(underlying, nil)
+
+	require.NoError(t, st.PutBlob(ctx, "a3", gather.FromSlice([]byte{7, 8})))
+	require.NoError(t, st.PutBlob(ctx, "a1", gather.FromSlice([]byte{9})))
+	require.NoError(t, st.DeleteBlob(ctx, "a2"))
+
+	newTime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, st.SetTime(ctx, "a1", newTime))
+
+	blobtesting.AssertGetBlob(ctx, t, st, "a1", []byte{9})
+	blobtesting.AssertGetBlob(ctx, t, st, "a3", []byte{7, 8})
+	blobtesting.AssertGetBlobNotFound(ctx, t, st, "a2")
+
+	bm, err := st.GetMetadata(ctx, "a1")
+	require.NoError(t, err)
+	require.Equal(t, newTime, bm.Timestamp)
+
+	blobtesting.AssertListResults(ctx, t, st, "a", "a1", "a3")
+
+	// underlying storage is unchanged.
+	blobtesting.AssertGetBlob(ctx, t, underlying, "a1", []byte{1, 2, 3, 4})
+	blobtesting.AssertGetBlob(ctx, t, underlying, "a2", []byte{5, 6, 7, 8})
+	blobtesting.AssertGetBlobNotFound(ctx, t, underlying, "a3")
+	blobtesting.AssertListResults(ctx, t, underlying, "a", "a1", "a2")
+
+	require.Equal(t, []JournalEntry{
+		{OperationPutBlob, "a3", 2},
+		{OperationPutBlob, "a1", 1},
+		{OperationDeleteBlob, "a2", 0},
+		{OperationSetTime, "a1", 0},
+	}, st.Journal())
+}
@@ -0,0 +1,368 @@
+// Package faultinject implements wrapper around Storage that randomly injects errors, latency and partial writes.
+package faultinject
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("fault-inject")
+
+// ErrInjected is returned by operations that failed due to an injected fault.
+var ErrInjected = errors.New("injected storage fault")
+
+// Supported method names.
+const (
+	MethodGetBlob     = "GetBlob"
+	MethodGetMetadata = "GetMetadata"
+	MethodPutBlob     = "PutBlob"
+	MethodSetTime     = "SetTime"
+	MethodDeleteBlob  = "DeleteBlob"
+	MethodListBlobs   = "ListBlobs"
+
+	MethodGetRetention     = "GetRetention"
+	MethodListDeletedBlobs = "ListDeletedBlobs"
+	MethodUndeleteBlob     = "UndeleteBlob"
+	MethodDeleteBlobs      = "DeleteBlobs"
+)
+
+// ScriptedFault describes a deterministic fault triggered after a given number of calls to a method.
+type ScriptedFault struct {
+	Method        string `json:"method"`
+	Skip          int    `json:"skip,omitempty"`          // number of calls to let through before failing
+	Count         int    `json:"count,omitempty"`         // number of consecutive failed calls, defaults to 1
+	LatencyMillis int    `json:"latencyMillis,omitempty"` // delay before failing
+	PartialWrite  bool   `json:"partialWrite,omitempty"`  // for PutBlob, write truncated blob before failing
+}
+
+// Options specifies faults to be injected.
+type Options struct {
+	Seed int64 `json:"seed,omitempty"`
+
+	// Methods limits random faults to the provided methods, all methods are affected if empty.
+	Methods []string `json:"methods,omitempty"`
+
+	ErrorProbability        float64 `json:"errorProbability,omitempty"`
+	LatencyProbability      float64 `json:"latencyProbability,omitempty"`
+	MaxLatencyMillis        int     `json:"maxLatencyMillis,omitempty"`
+	PartialWriteProbability float64 `json:"partialWriteProbability,omitempty"`
+
+	// Script is a sequence of faults, applied in order, independently of random faults.
+	Script []ScriptedFault `json:"script,omitempty"`
+}
+
+type faultInjectingStorage struct {
+	base blob.Storage
+	opt  Options
+
+	mu         sync.Mutex // protects fields below
+	rnd        *rand.Rand
+	callCounts map[string]int
+	script     []ScriptedFault
+}
+
+// fault describes the outcome of a single call.
+type fault struct {
+	latency time.Duration
+	fail    bool
+	partial bool
+}
+
+func (s *faultInjectingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	if err := s.inject(ctx, MethodGetBlob, id).apply(ctx); err != nil {
+		return nil, err
+	}
+
+	// nolint:wrapcheck
+	return s.base.GetBlob(ctx, id, offset, length)
+}
+
+func (s *faultInjectingStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	if err := s.inject(ctx, MethodGetMetadata, id).apply(ctx); err != nil {
+		return blob.Metadata{}, err
+	}
+
+	// nolint:wrapcheck
+	return s.base.GetMetadata(ctx, id)
+}
+
+func (s *faultInjectingStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	if err := s.inject(ctx, MethodSetTime, id).apply(ctx); err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return s.base.SetTime(ctx, id, t)
+}
+
+func (s *faultInjectingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	f := s.inject(ctx, MethodPutBlob, id)
+
+	if f.partial && data.Length() > 0 {
+		if err := s.putPartial(ctx, id, data); err != nil {
+			log(ctx).Debugf("partial write of %v failed: %v", id, err)
+		}
+	}
+
+	if err := f.apply(ctx); err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return s.base.PutBlob(ctx, id, data)
+}
+
+func (s *faultInjectingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	if err := s.inject(ctx, MethodDeleteBlob, id).apply(ctx); err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return s.base.DeleteBlob(ctx, id)
+}
+
+func (s *faultInjectingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	if err := s.inject(ctx, MethodListBlobs, prefix).apply(ctx); err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return s.base.ListBlobs(ctx, prefix, callback)
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s *faultInjectingStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	if err := s.inject(ctx, MethodListBlobs, prefix).apply(ctx); err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return blob.ListBlobsAfter(ctx, s.base, prefix, startAfter, callback)
+}
+
+// GetRetention implements blob.RetentionReader.
+func (s *faultInjectingStorage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	if err := s.inject(ctx, MethodGetRetention, id).apply(ctx); err != nil {
+		return blob.RetentionInfo{}, err
+	}
+
+	// nolint:wrapcheck
+	return blob.GetRetention(ctx, s.base, id)
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s *faultInjectingStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	if err := s.inject(ctx, MethodListDeletedBlobs, prefix).apply(ctx); err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return blob.ListDeletedBlobs(ctx, s.base, prefix, callback)
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s *faultInjectingStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	if err := s.inject(ctx, MethodUndeleteBlob, id).apply(ctx); err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return blob.UndeleteBlob(ctx, s.base, id, versionID)
+}
+
+// DeleteBlobs implements blob.BatchDeleter.
+func (s *faultInjectingStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	if len(ids) > 0 {
+		if err := s.inject(ctx, MethodDeleteBlobs, ids[0]).apply(ctx); err != nil {
+			return err
+		}
+	}
+
+	// nolint:wrapcheck
+	return blob.DeleteBlobs(ctx, s.base, ids)
+}
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s *faultInjectingStorage) PresignedGetURL(ctx context.Context, id blob.ID, expiration time.Duration) (string, error) {
+	// nolint:wrapcheck
+	return blob.PresignedGetURL(ctx, s.base, id, expiration)
+}
+
+func (s *faultInjectingStorage) Close(ctx context.Context) error {
+	// nolint:wrapcheck
+	return s.base.Close(ctx)
+}
+
+func (s *faultInjectingStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *faultInjectingStorage) DisplayName() string {
+	return s.base.DisplayName()
+}
+
+func (s *faultInjectingStorage) Capabilities() blob.Capabilities {
+	return s.base.Capabilities()
+}
+
+// putPartial writes a random prefix of the provided data, simulating an interrupted upload.
+func (s *faultInjectingStorage) putPartial(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	s.mu.Lock()
+	n := s.rnd.Intn(data.Length())
+	s.mu.Unlock()
+
+	var buf bytes.Buffer
+
+	if _, err := io.CopyN(&buf, data.Reader(), int64(n)); err != nil {
+		return errors.Wrap(err, "error reading blob data")
+	}
+
+	// nolint:wrapcheck
+	return s.base.PutBlob(ctx, id, gather.FromSlice(buf.Bytes()))
+}
+
+// inject determines the fault to apply to the next call of the provided method.
+func (s *faultInjectingStorage) inject(ctx context.Context, method string, id blob.ID) fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	callNumber := s.callCounts[method]
+	s.callCounts[method]++
+
+	if f, ok := s.nextScriptedFaultLocked(method); ok {
+		log(ctx).Infof("injecting scripted fault in %v(%v) call #%v", method, id, callNumber)
+		return f
+	}
+
+	if !s.randomFaultsEnabled(method) {
+		return fault{}
+	}
+
+	var f fault
+
+	if s.rnd.Float64() < s.opt.LatencyProbability && s.opt.MaxLatencyMillis > 0 {
+		f.latency = time.Duration(s.rnd.Intn(s.opt.MaxLatencyMillis)+1) * time.Millisecond
+	}
+
+	if method == MethodPutBlob && s.rnd.Float64() < s.opt.PartialWriteProbability {
+		f.fail = true
+		f.partial = true
+	}
+
+	if s.rnd.Float64() < s.opt.ErrorProbability {
+		f.fail = true
+	}
+
+	if f.fail || f.latency > 0 {
+		log(ctx).Infof("injecting random fault in %v(%v) call #%v: %+v", method, id, callNumber, f)
+	}
+
+	return f
+}
+
+// nextScriptedFaultLocked returns the fault for the next call of a method if the head of the script matches it.
+func (s *faultInjectingStorage) nextScriptedFaultLocked(method string) (fault, bool) {
+	if len(s.script) == 0 || s.script[0].Method != method {
+		return fault{}, false
+	}
+
+	sf := &s.script[0]
+
+	if sf.Skip > 0 {
+		sf.Skip--
+		return fault{}, false
+	}
+
+	f := fault{
+		latency: time.Duration(sf.LatencyMillis) * time.Millisecond,
+		fail:    true,
+		partial: sf.PartialWrite && method == MethodPutBlob,
+	}
+
+	if sf.Count > 1 {
+		sf.Count--
+	} else {
+		s.script = s.script[1:]
+	}
+
+	return f, true
+}
+
+func (s *faultInjectingStorage) randomFaultsEnabled(method string) bool {
+	if len(s.opt.Methods) == 0 {
+		return true
+	}
+
+	for _, m := range s.opt.Methods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f fault) apply(ctx context.Context) error {
+	if f.latency > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(f.latency):
+		}
+	}
+
+	if f.fail {
+		return ErrInjected
+	}
+
+	return nil
+}
+
+// RemainingScriptedFaults returns the number of scripted faults that have not been triggered yet.
+func RemainingScriptedFaults(st blob.Storage) int {
+	s, ok := st.(*faultInjectingStorage)
+	if !ok {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.script)
+}
+
+// NewWrapper returns a Storage wrapper that injects faults described by the provided options.
+func NewWrapper(wrapped blob.Storage, opt Options) blob.Storage {
+	seed := opt.Seed
+	if seed == 0 {
+		seed = clock.Now().UnixNano()
+	}
+
+	return &faultInjectingStorage{
+		base:       wrapped,
+		opt:        opt,
+		rnd:        rand.New(rand.NewSource(seed)), //nolint:gosec
+		callCounts: map[string]int{},
+		script:     append([]ScriptedFault(nil), opt.Script...),
+	}
+}
+
+var (
+	_ blob.Storage         = (*faultInjectingStorage)(nil)
+	_ blob.RetentionReader = (*faultInjectingStorage)(nil)
+	_ blob.Undeleter       = (*faultInjectingStorage)(nil)
+	_ blob.BatchDeleter    = (*faultInjectingStorage)(nil)
+	_ blob.OrderedLister   = (*faultInjectingStorage)(nil)
+	_ blob.URLPresigner    = (*faultInjectingStorage)(nil)
+)
@@ -0,0 +1,158 @@
+package faultinject_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/faultinject"
+)
+
+func TestFaultInjectNoFaults(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	st := faultinject.NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), faultinject.Options{})
+	blobtesting.VerifyStorage(ctx, t, st)
+}
+
+func TestFaultInjectScript(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	data := blobtesting.DataMap{}
+	st := faultinject.NewWrapper(blobtesting.NewMapStorage(data, nil, nil), faultinject.Options{
+		Script: []faultinject.ScriptedFault{
+			{Method: faultinject.MethodPutBlob, Skip: 1, Count: 2},
+			{Method: faultinject.MethodGetBlob},
+		},
+	})
+
+	require.Equal(t, 2, faultinject.RemainingScriptedFaults(st))
+
+	// first call is let through.
+	require.NoError(t, st.PutBlob(ctx, "a", gather.FromSlice([]byte{1, 2, 3})))
+
+	// next two calls fail.
+	require.True(t, errors.Is(st.PutBlob(ctx, "b", gather.FromSlice([]byte{1})), faultinject.ErrInjected))
+	require.True(t, errors.Is(st.PutBlob(ctx, "b", gather.FromSlice([]byte{1})), faultinject.ErrInjected))
+	require.NotContains(t, data, blob.ID("b"))
+
+	// GetBlob fault is not reached until preceding script entries are exhausted.
+	require.Equal(t, 1, faultinject.RemainingScriptedFaults(st))
+	require.NoError(t, st.PutBlob(ctx, "b", gather.FromSlice([]byte{1})))
+
+	_, err := st.GetBlob(ctx, "a", 0, -1)
+	require.True(t, errors.Is(err, faultinject.ErrInjected))
+
+	v, err := st.GetBlob(ctx, "a", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, v)
+
+	require.Equal(t, 0, faultinject.RemainingScriptedFaults(st))
+}
+
+func TestFaultInjectPartialWrite(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	data := blobtesting.DataMap{}
+	st := faultinject.NewWrapper(blobtesting.NewMapStorage(data, nil, nil), faultinject.Options{
+		Script: []faultinject.ScriptedFault{
+			{Method: faultinject.MethodPutBlob, PartialWrite: true},
+		},
+	})
+
+	payload := []byte("some payload that will be truncated")
+
+	require.True(t, errors.Is(st.PutBlob(ctx, "a", gather.FromSlice(payload)), faultinject.ErrInjected))
+	require.Contains(t, data, blob.ID("a"))
+	require.Less(t, len(data["a"]), len(payload))
+	require.True(t, bytes.HasPrefix(payload, data["a"]))
+
+	// retry overwrites truncated blob.
+	require.NoError(t, st.PutBlob(ctx, "a", gather.FromSlice(payload)))
+	require.Equal(t, payload, data["a"])
+}
+
+func TestFaultInjectRandom(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	st := faultinject.NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), faultinject.Options{
+		Seed:             1,
+		Methods:          []string{faultinject.MethodGetMetadata},
+		ErrorProbability: 0.5,
+	})
+
+	require.NoError(t, st.PutBlob(ctx, "a", gather.FromSlice([]byte{1})))
+
+	const numCalls = 1000
+
+	failed := 0
+
+	for i := 0; i < numCalls; i++ {
+		if _, err := st.GetMetadata(ctx, "a"); err != nil {
+			require.True(t, errors.Is(err, faultinject.ErrInjected))
+
+			failed++
+		}
+
+		// methods not listed are never affected.
+		_, err := st.GetBlob(ctx, "a", 0, -1)
+		require.NoError(t, err)
+	}
+
+	require.Greater(t, failed, numCalls/4)
+	require.Less(t, failed, numCalls*3/4)
+}
+
+func TestFaultInjectOptionalMethods(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	base := blobtesting.NewVersionedMapStorage(blobtesting.DataMap{}, nil, nil)
+	st := faultinject.NewWrapper(base, faultinject.Options{
+		Script: []faultinject.ScriptedFault{
+			{Method: faultinject.MethodDeleteBlobs},
+			{Method: faultinject.MethodListDeletedBlobs},
+			{Method: faultinject.MethodUndeleteBlob},
+		},
+	})
+
+	require.Equal(t, base.Capabilities(), st.Capabilities())
+
+	require.NoError(t, st.PutBlob(ctx, "a", gather.FromSlice([]byte{1})))
+
+	// the underlying storage does not support batch deletes, but the wrapper still injects faults.
+	bd := st.(blob.BatchDeleter) //nolint:forcetypeassert
+	require.True(t, errors.Is(bd.DeleteBlobs(ctx, []blob.ID{"a"}), faultinject.ErrInjected))
+	require.NoError(t, bd.DeleteBlobs(ctx, []blob.ID{"a"}))
+
+	var deleted []blob.DeletedMetadata
+
+	listDeleted := func() error {
+		deleted = nil
+
+		return blob.ListDeletedBlobs(ctx, st, "", func(dm blob.DeletedMetadata) error {
+			deleted = append(deleted, dm)
+			return nil
+		})
+	}
+
+	require.True(t, errors.Is(listDeleted(), faultinject.ErrInjected))
+	require.NoError(t, listDeleted())
+	require.Len(t, deleted, 1)
+
+	require.True(t, errors.Is(blob.UndeleteBlob(ctx, st, "a", deleted[0].VersionID), faultinject.ErrInjected))
+	require.NoError(t, blob.UndeleteBlob(ctx, st, "a", deleted[0].VersionID))
+
+	v, err := st.GetBlob(ctx, "a", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, v)
+
+	// retention is not supported by the underlying storage.
+	_, err = blob.GetRetention(ctx, st, "a")
+	require.True(t, errors.Is(err, blob.ErrRetentionUnsupported))
+}
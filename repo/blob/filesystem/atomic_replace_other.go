@@ -0,0 +1,28 @@
+// +build !windows
+
+package filesystem
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// atomicReplace renames src to dst, replacing dst if it exists. On POSIX systems rename(2) replaces
+// the target atomically, so concurrent readers observe either the old or the new file.
+func atomicReplace(src, dst string) error {
+	// nolint:wrapcheck
+	return os.Rename(src, dst)
+}
+
+// syncDir flushes the directory entry of a renamed file to stable storage.
+func syncDir(dirname string) error {
+	d, err := os.Open(dirname) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "error opening directory")
+	}
+
+	defer d.Close() //nolint:errcheck
+
+	return errors.Wrap(d.Sync(), "error syncing directory")
+}
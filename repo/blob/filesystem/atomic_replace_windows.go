@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// Flags of FILE_RENAME_INFO used with FileRenameInfoEx.
+const (
+	fileRenameFlagReplaceIfExists = 0x1
+	fileRenameFlagPosixSemantics  = 0x2
+)
+
+// fileRenameInfo is FILE_RENAME_INFO structure followed by the variable-length file name.
+type fileRenameInfo struct {
+	Flags          uint32
+	RootDirectory  windows.Handle
+	FileNameLength uint32
+	FileName       [1]uint16
+}
+
+// atomicReplace renames src to dst, replacing dst if it exists. On Windows 10 1607 and newer the
+// rename uses POSIX semantics, which replace the target atomically even if it's open by readers,
+// otherwise it falls back to MoveFileEx(), which is not atomic.
+func atomicReplace(src, dst string) error {
+	err := posixRename(src, dst)
+	if err == nil {
+		return nil
+	}
+
+	// the information class or POSIX semantics are not supported by the OS or filesystem.
+	if errors.Is(err, windows.ERROR_INVALID_PARAMETER) || errors.Is(err, windows.ERROR_NOT_SUPPORTED) || errors.Is(err, windows.ERROR_INVALID_FUNCTION) {
+		// nolint:wrapcheck
+		return os.Rename(src, dst)
+	}
+
+	return &os.LinkError{Op: "rename", Old: src, New: dst, Err: err}
+}
+
+func posixRename(src, dst string) error {
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return errors.Wrap(err, "unable to get absolute path")
+	}
+
+	srcPtr, err := windows.UTF16PtrFromString(src)
+	if err != nil {
+		return errors.Wrap(err, "invalid source path")
+	}
+
+	dstName, err := windows.UTF16FromString(absDst)
+	if err != nil {
+		return errors.Wrap(err, "invalid target path")
+	}
+
+	if len(dstName) > windows.MAX_LONG_PATH {
+		return windows.ERROR_FILENAME_EXCED_RANGE
+	}
+
+	h, err := windows.CreateFile(srcPtr, windows.DELETE|windows.SYNCHRONIZE,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		// nolint:wrapcheck
+		return err
+	}
+
+	defer windows.CloseHandle(h) //nolint:errcheck
+
+	nameOffset := unsafe.Offsetof(fileRenameInfo{}.FileName)
+	nameBytes := uintptr(len(dstName)) * unsafe.Sizeof(dstName[0])
+
+	// allocate as []uint64 to ensure proper alignment of the structure.
+	buf := make([]uint64, (nameOffset+nameBytes+7)/8) //nolint:gomnd
+	info := (*fileRenameInfo)(unsafe.Pointer(&buf[0]))
+
+	info.Flags = fileRenameFlagReplaceIfExists | fileRenameFlagPosixSemantics
+	info.FileNameLength = uint32(nameBytes - unsafe.Sizeof(dstName[0])) // excluding terminating NUL
+
+	copy((*[windows.MAX_LONG_PATH]uint16)(unsafe.Pointer(&info.FileName[0]))[:len(dstName)], dstName)
+
+	// nolint:wrapcheck
+	return windows.SetFileInformationByHandle(h, windows.FileRenameInfoEx, (*byte)(unsafe.Pointer(info)), uint32(len(buf)*8)) //nolint:gomnd
+}
+
+// syncDir is a no-op on Windows, which does not support flushing directories.
+func syncDir(dirname string) error {
+	return nil
+}
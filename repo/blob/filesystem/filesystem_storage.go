@@ -163,11 +163,17 @@ func (fs *fsImpl) PutBlobInPath(ctx context.Context, dirPath, path string, data
 			return errors.Wrap(err, "can't write temporary file")
 		}
 
+		// make sure the contents are on stable storage before the file becomes visible under its final name,
+		// so that a crash can't leave a blob with partial contents.
+		if err = f.Sync(); err != nil {
+			return errors.Wrap(err, "can't sync temporary file")
+		}
+
 		if err = f.Close(); err != nil {
 			return errors.Wrap(err, "can't close temporary file")
 		}
 
-		err = os.Rename(tempFile, path)
+		err = atomicReplace(tempFile, path)
 		if err != nil {
 			if removeErr := os.Remove(tempFile); removeErr != nil {
 				log(ctx).Errorf("can't remove temp file: %v", removeErr)
@@ -177,6 +183,10 @@ func (fs *fsImpl) PutBlobInPath(ctx context.Context, dirPath, path string, data
 			return err
 		}
 
+		if syncErr := syncDir(dirPath); syncErr != nil {
+			log(ctx).Debugf("can't sync directory %v: %v", dirPath, syncErr)
+		}
+
 		if fs.FileUID != nil && fs.FileGID != nil && os.Geteuid() == 0 {
 			if chownErr := os.Chown(path, *fs.FileUID, *fs.FileGID); chownErr != nil {
 				log(ctx).Errorf("can't change file permissions: %v", chownErr)
@@ -273,6 +283,12 @@ func (fs *fsStorage) DisplayName() string {
 	return fmt.Sprintf("Filesystem: %v", fs.RootPath)
 }
 
+func (fs *fsStorage) Capabilities() blob.Capabilities {
+	return blob.Capabilities{
+		SetTime: true,
+	}
+}
+
 func (fs *fsStorage) Close(ctx context.Context) error {
 	return nil
 }
@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"io/ioutil"
 	"reflect"
 	"sort"
 	"testing"
@@ -124,6 +125,41 @@ func TestFileStorageConcurrency(t *testing.T) {
 	})
 }
 
+func TestFileStorageOverwrite(t *testing.T) {
+	t.Parallel()
+	testutil.ProviderTest(t)
+
+	ctx := testlogging.Context(t)
+
+	path := testutil.TempDirectory(t)
+
+	st, err := New(ctx, &Options{
+		Path:            path,
+		DirectoryShards: []int{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertNoError(t, st.PutBlob(ctx, t1, gather.FromSlice([]byte{1, 2, 3})))
+	assertNoError(t, st.PutBlob(ctx, t1, gather.FromSlice([]byte{4, 5})))
+
+	got, err := st.GetBlob(ctx, t1, 0, -1)
+	assertNoError(t, err)
+
+	if !reflect.DeepEqual(got, []byte{4, 5}) {
+		t.Errorf("unexpected contents after overwrite: %v", got)
+	}
+
+	// no temporary files are left behind.
+	entries, err := ioutil.ReadDir(path)
+	assertNoError(t, err)
+
+	if len(entries) != 1 {
+		t.Errorf("unexpected directory entries: %v", entries)
+	}
+}
+
 func verifyBlobTimestampOrder(t *testing.T, st blob.Storage, want ...blob.ID) {
 	t.Helper()
 
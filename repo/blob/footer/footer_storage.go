@@ -0,0 +1,261 @@
+// Package footer implements a wrapper around blob.Storage that appends an authenticated integrity footer
+// to every blob and validates it when the blob is read, so that blobs truncated or otherwise damaged
+// by storage backends or proxies are detected before decryption, with clearer errors.
+package footer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// The footer is appended after blob data and consists of the version (1 byte), data length (8 bytes, big endian),
+// HMAC-SHA256 of the version, data length, blob ID and data truncated to 16 bytes, followed by 4 magic bytes.
+const (
+	// Version is the version of the footer format written by the wrapper.
+	Version = 1
+
+	// Size is the number of bytes appended to each blob.
+	Size = 1 + lengthSize + macSize + magicSize
+
+	magic      = "KBIF"
+	magicSize  = 4
+	lengthSize = 8
+	macSize    = 16
+)
+
+// ErrInvalidFooter is returned when the integrity footer of a blob is missing or does not match its contents.
+var ErrInvalidFooter = errors.New("blob integrity footer verification failed")
+
+type footerStorage struct {
+	blob.Storage
+
+	key      []byte
+	excluded map[blob.ID]bool
+}
+
+func (s *footerStorage) computeMAC(id blob.ID, header []byte, data io.WriterTo) ([]byte, error) {
+	h := hmac.New(sha256.New, s.key)
+
+	h.Write(header)     // nolint:errcheck
+	h.Write([]byte(id)) // nolint:errcheck
+
+	if _, err := data.WriteTo(h); err != nil {
+		return nil, errors.Wrap(err, "error computing blob MAC")
+	}
+
+	return h.Sum(nil)[0:macSize], nil
+}
+
+func footerHeader(length int64) []byte {
+	var header [1 + lengthSize]byte
+
+	header[0] = Version
+	binary.BigEndian.PutUint64(header[1:], uint64(length))
+
+	return header[:]
+}
+
+// verifyAndStrip verifies the footer of a full blob and returns the data without it.
+func (s *footerStorage) verifyAndStrip(id blob.ID, b []byte) ([]byte, error) {
+	if len(b) < Size {
+		return nil, errors.Wrapf(ErrInvalidFooter, "blob %v is too short (%v bytes), it may have been truncated", id, len(b))
+	}
+
+	dataLength := len(b) - Size
+	footer := b[dataLength:]
+
+	if string(footer[Size-magicSize:]) != magic {
+		return nil, errors.Wrapf(ErrInvalidFooter, "blob %v does not end with an integrity footer, it may have been truncated", id)
+	}
+
+	if footer[0] != Version {
+		return nil, errors.Wrapf(ErrInvalidFooter, "blob %v has unsupported footer version %v", id, footer[0])
+	}
+
+	if l := binary.BigEndian.Uint64(footer[1 : 1+lengthSize]); l != uint64(dataLength) {
+		return nil, errors.Wrapf(ErrInvalidFooter, "blob %v should have %v bytes of data, but has %v", id, l, dataLength)
+	}
+
+	data := b[0:dataLength]
+
+	mac, err := s.computeMAC(id, footer[0:1+lengthSize], bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal(mac, footer[1+lengthSize:1+lengthSize+macSize]) {
+		return nil, errors.Wrapf(ErrInvalidFooter, "blob %v does not match its integrity footer, it may have been corrupted", id)
+	}
+
+	return data, nil
+}
+
+func (s *footerStorage) adjustMetadata(bm blob.Metadata) blob.Metadata {
+	if !s.excluded[bm.BlobID] && bm.Length >= Size {
+		bm.Length -= Size
+	}
+
+	return bm
+}
+
+// GetBlob implements blob.Storage.
+// Footers can only be verified when reading entire blobs. Reads of ranges include the number of bytes
+// of the footer past the end of the range, to ensure that the range does not extend into the footer.
+func (s *footerStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	if s.excluded[id] {
+		// nolint:wrapcheck
+		return s.Storage.GetBlob(ctx, id, offset, length)
+	}
+
+	if length >= 0 {
+		b, err := s.Storage.GetBlob(ctx, id, offset, length+Size)
+		if err != nil {
+			return nil, err // nolint:wrapcheck
+		}
+
+		// nolint:wrapcheck
+		return blob.EnsureLengthAndTruncate(b, length)
+	}
+
+	b, err := s.Storage.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+
+	return s.verifyAndStrip(id, b)
+}
+
+// GetMetadata implements blob.Storage.
+func (s *footerStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	bm, err := s.Storage.GetMetadata(ctx, id)
+	if err != nil {
+		return bm, err // nolint:wrapcheck
+	}
+
+	return s.adjustMetadata(bm), nil
+}
+
+// ListBlobs implements blob.Storage.
+func (s *footerStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return s.Storage.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+		return callback(s.adjustMetadata(bm))
+	})
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s *footerStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListBlobsAfter(ctx, s.Storage, prefix, startAfter, func(bm blob.Metadata) error {
+		return callback(s.adjustMetadata(bm))
+	})
+}
+
+// PutBlob implements blob.Storage.
+func (s *footerStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	if s.excluded[id] {
+		// nolint:wrapcheck
+		return s.Storage.PutBlob(ctx, id, data)
+	}
+
+	header := footerHeader(int64(data.Length()))
+
+	mac, err := s.computeMAC(id, header, data)
+	if err != nil {
+		return err
+	}
+
+	footer := make([]byte, 0, Size)
+	footer = append(footer, header...)
+	footer = append(footer, mac...)
+	footer = append(footer, magic...)
+
+	// nolint:wrapcheck
+	return s.Storage.PutBlob(ctx, id, withFooter{data, footer})
+}
+
+// GetRetention implements blob.RetentionReader.
+func (s *footerStorage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	// nolint:wrapcheck
+	return blob.GetRetention(ctx, s.Storage, id)
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s *footerStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListDeletedBlobs(ctx, s.Storage, prefix, func(dm blob.DeletedMetadata) error {
+		dm.Metadata = s.adjustMetadata(dm.Metadata)
+		return callback(dm)
+	})
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s *footerStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	// nolint:wrapcheck
+	return blob.UndeleteBlob(ctx, s.Storage, id, versionID)
+}
+
+// DeleteBlobs implements blob.BatchDeleter.
+func (s *footerStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	// nolint:wrapcheck
+	return blob.DeleteBlobs(ctx, s.Storage, ids)
+}
+
+// Capabilities implements blob.Storage.
+func (s *footerStorage) Capabilities() blob.Capabilities {
+	c := s.Storage.Capabilities()
+
+	// blobs read using pre-signed URLs would include footers and their lengths would not match listings.
+	c.PresignedURLs = false
+
+	return c
+}
+
+// withFooter implements blob.Bytes for data followed by the footer.
+type withFooter struct {
+	data   blob.Bytes
+	footer []byte
+}
+
+func (b withFooter) Length() int {
+	return b.data.Length() + len(b.footer)
+}
+
+func (b withFooter) Reader() io.Reader {
+	return io.MultiReader(b.data.Reader(), bytes.NewReader(b.footer))
+}
+
+func (b withFooter) WriteTo(w io.Writer) (int64, error) {
+	n, err := b.data.WriteTo(w)
+	if err != nil {
+		return n, err // nolint:wrapcheck
+	}
+
+	n2, err := w.Write(b.footer)
+
+	return n + int64(n2), err // nolint:wrapcheck
+}
+
+// NewWrapper returns a Storage wrapper that appends an integrity footer authenticated with the provided key
+// to all blobs other than the excluded ones and verifies it when entire blobs are read.
+func NewWrapper(wrapped blob.Storage, key []byte, excluded ...blob.ID) blob.Storage {
+	s := &footerStorage{
+		Storage:  wrapped,
+		key:      key,
+		excluded: map[blob.ID]bool{},
+	}
+
+	for _, id := range excluded {
+		s.excluded[id] = true
+	}
+
+	return s
+}
@@ -0,0 +1,89 @@
+package footer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/footer"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestFooterStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	blobtesting.VerifyStorage(ctx, t, footer.NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), testKey))
+}
+
+func TestFooterStorageDetectsDamage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	data := blobtesting.DataMap{}
+	st := footer.NewWrapper(blobtesting.NewMapStorage(data, nil, nil), testKey, "excluded")
+
+	payload := []byte("some blob data")
+
+	require.NoError(t, st.PutBlob(ctx, "b1", gather.FromSlice(payload)))
+	require.NoError(t, st.PutBlob(ctx, "excluded", gather.FromSlice(payload)))
+
+	// footer is stored, but invisible through the wrapper.
+	require.Len(t, data["b1"], len(payload)+footer.Size)
+	require.Len(t, data["excluded"], len(payload))
+	blobtesting.AssertGetBlob(ctx, t, st, "b1", payload)
+	blobtesting.AssertGetBlob(ctx, t, st, "excluded", payload)
+
+	bm, err := st.GetMetadata(ctx, "b1")
+	require.NoError(t, err)
+	require.Equal(t, int64(len(payload)), bm.Length)
+
+	original := append([]byte(nil), data["b1"]...)
+
+	cases := map[string][]byte{
+		"truncated":       original[0 : len(original)-1],
+		"too short":       original[0:3],
+		"corrupted data":  append([]byte{original[0] ^ 1}, original[1:]...),
+		"corrupted mac":   append(append([]byte(nil), original[0:len(original)-5]...), original[len(original)-5]^1, 'K', 'B', 'I', 'F'),
+		"extended":        append(append([]byte(nil), original...), 0),
+		"renamed blob id": nil,
+	}
+
+	for name, damaged := range cases {
+		if damaged == nil {
+			// footer authenticates blob ID, so blobs can't be swapped.
+			data["b2"] = original
+
+			_, err = st.GetBlob(ctx, "b2", 0, -1)
+			require.ErrorIs(t, err, footer.ErrInvalidFooter, name)
+
+			continue
+		}
+
+		data["b1"] = damaged
+
+		_, err = st.GetBlob(ctx, "b1", 0, -1)
+		require.ErrorIs(t, err, footer.ErrInvalidFooter, name)
+	}
+
+	// a different key does not verify.
+	data["b1"] = original
+
+	_, err = footer.NewWrapper(blobtesting.NewMapStorage(data, nil, nil), []byte("other-key")).GetBlob(ctx, "b1", 0, -1)
+	require.ErrorIs(t, err, footer.ErrInvalidFooter)
+
+	// reads of ranges are not verified.
+	got, err := st.GetBlob(ctx, "b1", 5, 4)
+	require.NoError(t, err)
+	require.Equal(t, payload[5:9], got)
+
+	blobtesting.AssertListResults(ctx, t, st, "b", "b1", "b2")
+
+	require.NoError(t, st.ListBlobs(ctx, "b1", func(bm blob.Metadata) error {
+		require.Equal(t, int64(len(payload)), bm.Length)
+		return nil
+	}))
+}
@@ -0,0 +1,182 @@
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	gcsclient "cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	// blobs with shorter IDs (such as 'kopia.repository') are never split into folders.
+	minShardedBlobIDLength = 20
+
+	// number of folders listed concurrently on buckets with hierarchical namespace.
+	parallelFolderListings = 16
+)
+
+// bucketMetadataURL is the JSON API endpoint returning bucket metadata, overridden in tests.
+var bucketMetadataURL = "https://storage.googleapis.com/storage/v1/b/"
+
+// detectHierarchicalNamespace determines whether the bucket has hierarchical namespace enabled.
+// The client library does not expose this setting, so bucket metadata is fetched using the JSON API.
+func detectHierarchicalNamespace(ctx context.Context, hc *http.Client, bucket string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bucketMetadataURL+url.PathEscape(bucket)+"?fields=hierarchicalNamespace", nil)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to create request")
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to get bucket metadata")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("unable to get bucket metadata: %v", resp.Status)
+	}
+
+	var md struct {
+		HierarchicalNamespace struct {
+			Enabled bool `json:"enabled"`
+		} `json:"hierarchicalNamespace"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&md); err != nil {
+		return false, errors.Wrap(err, "invalid bucket metadata")
+	}
+
+	return md.HierarchicalNamespace.Enabled, nil
+}
+
+// shardedName splits the provided blob ID (or its prefix) into folders according to DirectoryShards,
+// slashes are only inserted between non-empty components.
+func (gcs *gcsStorage) shardedName(b string, isPrefix bool) string {
+	if !isPrefix && len(b) < minShardedBlobIDLength {
+		return b
+	}
+
+	var sb strings.Builder
+
+	for _, size := range gcs.DirectoryShards {
+		if len(b) <= size {
+			break
+		}
+
+		sb.WriteString(b[0:size])
+		sb.WriteString("/")
+
+		b = b[size:]
+	}
+
+	sb.WriteString(b)
+
+	return sb.String()
+}
+
+// blobIDFromObjectName returns the blob ID of the object with the provided name.
+func (gcs *gcsStorage) blobIDFromObjectName(name string) blob.ID {
+	return blob.ID(strings.ReplaceAll(name[len(gcs.Prefix):], "/", ""))
+}
+
+// listShardedBlobs lists blobs stored in folders along with blobs too short to be sharded.
+func (gcs *gcsStorage) listShardedBlobs(ctx context.Context, prefix blob.ID, callback func(*gcsclient.ObjectAttrs) error) error {
+	if len(prefix) < minShardedBlobIDLength {
+		// short blob IDs are stored at the top level, list them without descending into folders.
+		if err := gcs.listObjects(ctx, &gcsclient.Query{
+			Prefix:    gcs.Prefix + string(prefix),
+			Delimiter: "/",
+		}, func(oa *gcsclient.ObjectAttrs) error {
+			if oa.Prefix != "" || len(oa.Name)-len(gcs.Prefix) >= minShardedBlobIDLength {
+				return nil
+			}
+
+			return callback(oa)
+		}); err != nil {
+			return err
+		}
+	}
+
+	shardedPrefix := gcs.Prefix + gcs.shardedName(string(prefix), true)
+
+	if !gcs.hierarchicalNamespace || strings.Contains(shardedPrefix[len(gcs.Prefix):], "/") {
+		return gcs.listObjects(ctx, &gcsclient.Query{Prefix: shardedPrefix}, func(oa *gcsclient.ObjectAttrs) error {
+			if !strings.Contains(oa.Name[len(gcs.Prefix):], "/") {
+				// already reported above
+				return nil
+			}
+
+			return callback(oa)
+		})
+	}
+
+	// on buckets with hierarchical namespace listing individual folders in parallel
+	// is much faster than listing all objects sequentially.
+	var folders []string
+
+	if err := gcs.listObjects(ctx, &gcsclient.Query{
+		Prefix:    shardedPrefix,
+		Delimiter: "/",
+	}, func(oa *gcsclient.ObjectAttrs) error {
+		if oa.Prefix != "" {
+			folders = append(folders, oa.Prefix)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+
+	eg, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, parallelFolderListings)
+
+	for _, f := range folders {
+		f := f
+
+		eg.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			return gcs.listObjects(ctx, &gcsclient.Query{Prefix: f}, func(oa *gcsclient.ObjectAttrs) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				return callback(oa)
+			})
+		})
+	}
+
+	// nolint:wrapcheck
+	return eg.Wait()
+}
+
+func (gcs *gcsStorage) listObjects(ctx context.Context, q *gcsclient.Query, callback func(*gcsclient.ObjectAttrs) error) error {
+	lst := gcs.bucket.Objects(ctx, q)
+
+	oa, err := lst.Next()
+	for err == nil {
+		if cberr := callback(oa); cberr != nil {
+			return cberr
+		}
+
+		oa, err = lst.Next()
+	}
+
+	if !errors.Is(err, iterator.Done) {
+		return errors.Wrap(err, "ListBlobs")
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package gcs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestShardedObjectNames(t *testing.T) {
+	gcs := &gcsStorage{Options: Options{Prefix: "pfx/", DirectoryShards: []int{1, 2}}}
+
+	cases := []struct {
+		blobID     blob.ID
+		objectName string
+	}{
+		{"kopia.repository", "pfx/kopia.repository"},
+		{"pabcdef0123456789abcdef", "pfx/p/ab/cdef0123456789abcdef"},
+		{"xn0_0123456789abcdef0123", "pfx/x/n0/_0123456789abcdef0123"},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.objectName, gcs.getObjectNameString(tc.blobID))
+		require.Equal(t, tc.blobID, gcs.blobIDFromObjectName(tc.objectName))
+	}
+
+	require.Equal(t, "pfx/", gcs.getListPrefix(""))
+	require.Equal(t, "pfx/p", gcs.getListPrefix("p"))
+	require.Equal(t, "pfx/k", gcs.getListPrefix("kopia"))
+	require.Equal(t, "pfx/p/ab/cdef0123456789abc", gcs.getListPrefix("pabcdef0123456789abc"))
+
+	flat := &gcsStorage{Options: Options{Prefix: "pfx/"}}
+	require.Equal(t, "pfx/pabcdef0123456789abcdef", flat.getObjectNameString("pabcdef0123456789abcdef"))
+	require.Equal(t, "pfx/pab", flat.getListPrefix("pab"))
+}
+
+func TestDetectHierarchicalNamespace(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hns-bucket":
+			w.Write([]byte(`{"hierarchicalNamespace":{"enabled":true}}`))
+		case "/flat-bucket":
+			w.Write([]byte(`{}`))
+		default:
+			http.Error(w, "forbidden", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	bucketMetadataURL = server.URL + "/"
+
+	hns, err := detectHierarchicalNamespace(ctx, server.Client(), "hns-bucket")
+	require.NoError(t, err)
+	require.True(t, hns)
+
+	hns, err = detectHierarchicalNamespace(ctx, server.Client(), "flat-bucket")
+	require.NoError(t, err)
+	require.False(t, hns)
+
+	_, err = detectHierarchicalNamespace(ctx, server.Client(), "other-bucket")
+	require.Error(t, err)
+}
@@ -22,4 +22,9 @@ type Options struct {
 	MaxUploadSpeedBytesPerSecond int `json:"maxUploadSpeedBytesPerSecond,omitempty"`
 
 	MaxDownloadSpeedBytesPerSecond int `json:"maxDownloadSpeedBytesPerSecond,omitempty"`
+
+	// DirectoryShards splits object names into folders of the given lengths, for example [1,2] stores
+	// blob 'pabcdef...' as 'p/ab/cdef...', which speeds up listing on buckets with hierarchical namespace.
+	// All clients of the repository must use the same value.
+	DirectoryShards []int `json:"dirShards,omitempty"`
 }
@@ -7,6 +7,8 @@
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	gcsclient "cloud.google.com/go/storage"
@@ -21,13 +23,20 @@
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/iocopy"
 	"github.com/kopia/kopia/internal/throttle"
+	"github.com/kopia/kopia/internal/timestampmeta"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/retrying"
+	"github.com/kopia/kopia/repo/logging"
 )
 
+var log = logging.GetContextLoggerFunc("gcs")
+
 const (
 	gcsStorageType  = "gcs"
 	writerChunkSize = 1 << 20
+
+	// object metadata key that stores the time set using SetTime.
+	timeMapKey = "kopia-mtime"
 )
 
 type gcsStorage struct {
@@ -37,6 +46,9 @@ type gcsStorage struct {
 	storageClient *gcsclient.Client
 	bucket        *gcsclient.BucketHandle
 
+	// hierarchicalNamespace is true when the bucket has hierarchical namespace enabled.
+	hierarchicalNamespace bool
+
 	downloadThrottler *iothrottler.IOThrottlerPool
 	uploadThrottler   *iothrottler.IOThrottlerPool
 }
@@ -75,10 +87,20 @@ func (gcs *gcsStorage) GetMetadata(ctx context.Context, b blob.ID) (blob.Metadat
 	return blob.Metadata{
 		BlobID:    b,
 		Length:    attrs.Size,
-		Timestamp: attrs.Created,
+		Timestamp: timestampFromAttrs(attrs),
 	}, nil
 }
 
+// timestampFromAttrs returns the time set using SetTime, which is preserved in object metadata
+// with full precision, or the object creation time.
+func timestampFromAttrs(attrs *gcsclient.ObjectAttrs) time.Time {
+	if t, ok := timestampmeta.FromMap(attrs.Metadata, timeMapKey); ok {
+		return t
+	}
+
+	return attrs.Created
+}
+
 func translateError(err error) error {
 	var ae *googleapi.Error
 
@@ -123,7 +145,11 @@ func (gcs *gcsStorage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes)
 }
 
 func (gcs *gcsStorage) SetTime(ctx context.Context, b blob.ID, t time.Time) error {
-	return blob.ErrSetTimeUnsupported
+	_, err := gcs.bucket.Object(gcs.getObjectNameString(b)).Update(ctx, gcsclient.ObjectAttrsToUpdate{
+		Metadata: timestampmeta.ToMap(t, timeMapKey),
+	})
+
+	return translateError(err)
 }
 
 func (gcs *gcsStorage) DeleteBlob(ctx context.Context, b blob.ID) error {
@@ -136,32 +162,112 @@ func (gcs *gcsStorage) DeleteBlob(ctx context.Context, b blob.ID) error {
 }
 
 func (gcs *gcsStorage) getObjectNameString(blobID blob.ID) string {
-	return gcs.Prefix + string(blobID)
+	return gcs.Prefix + gcs.shardedName(string(blobID), false)
+}
+
+// getListPrefix returns the prefix of names of all objects, whose blob IDs start with the provided prefix.
+func (gcs *gcsStorage) getListPrefix(prefix blob.ID) string {
+	if len(gcs.DirectoryShards) > 0 && len(prefix) < minShardedBlobIDLength && len(prefix) > gcs.DirectoryShards[0] {
+		// short blobs are not sharded, so only the first shard is common to all matching objects.
+		return gcs.Prefix + string(prefix[0:gcs.DirectoryShards[0]])
+	}
+
+	return gcs.Prefix + gcs.shardedName(string(prefix), true)
 }
 
 func (gcs *gcsStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
-	lst := gcs.bucket.Objects(gcs.ctx, &gcsclient.Query{
+	cb := func(oa *gcsclient.ObjectAttrs) error {
+		return callback(blob.Metadata{
+			BlobID:    gcs.blobIDFromObjectName(oa.Name),
+			Length:    oa.Size,
+			Timestamp: timestampFromAttrs(oa),
+		})
+	}
+
+	if len(gcs.DirectoryShards) > 0 {
+		return gcs.listShardedBlobs(gcs.ctx, prefix, cb)
+	}
+
+	return gcs.listObjects(gcs.ctx, &gcsclient.Query{
 		Prefix: gcs.getObjectNameString(prefix),
+	}, cb)
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+// Objects without a live generation are reported along with their most recent noncurrent generation.
+func (gcs *gcsStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	lst := gcs.bucket.Objects(gcs.ctx, &gcsclient.Query{
+		Prefix:   gcs.getListPrefix(prefix),
+		Versions: true,
 	})
 
+	var (
+		latest *gcsclient.ObjectAttrs
+		live   bool
+	)
+
+	// generations of each object are returned together.
+	flush := func() error {
+		if latest == nil || live {
+			return nil
+		}
+
+		blobID := gcs.blobIDFromObjectName(latest.Name)
+		if !strings.HasPrefix(string(blobID), string(prefix)) {
+			return nil
+		}
+
+		return callback(blob.DeletedMetadata{
+			Metadata: blob.Metadata{
+				BlobID:    blobID,
+				Length:    latest.Size,
+				Timestamp: latest.Created,
+			},
+			VersionID: strconv.FormatInt(latest.Generation, 10),
+			DeletedAt: latest.Deleted,
+		})
+	}
+
 	oa, err := lst.Next()
 	for err == nil {
-		if cberr := callback(blob.Metadata{
-			BlobID:    blob.ID(oa.Name[len(gcs.Prefix):]),
-			Length:    oa.Size,
-			Timestamp: oa.Created,
-		}); cberr != nil {
-			return cberr
+		if latest == nil || oa.Name != latest.Name {
+			if cberr := flush(); cberr != nil {
+				return cberr
+			}
+
+			latest, live = oa, false
+		}
+
+		if oa.Deleted.IsZero() {
+			live = true
+		}
+
+		if oa.Generation > latest.Generation {
+			latest = oa
 		}
 
 		oa, err = lst.Next()
 	}
 
 	if !errors.Is(err, iterator.Done) {
-		return errors.Wrap(err, "ListBlobs")
+		return errors.Wrap(err, "ListDeletedBlobs")
 	}
 
-	return nil
+	return flush()
+}
+
+// UndeleteBlob implements blob.Undeleter by copying the provided generation over the deleted object.
+func (gcs *gcsStorage) UndeleteBlob(ctx context.Context, b blob.ID, versionID string) error {
+	generation, err := strconv.ParseInt(versionID, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "invalid generation %q", versionID)
+	}
+
+	obj := gcs.bucket.Object(gcs.getObjectNameString(b))
+
+	_, err = obj.CopierFrom(obj.Generation(generation)).Run(ctx)
+
+	return translateError(err)
 }
 
 func (gcs *gcsStorage) ConnectionInfo() blob.ConnectionInfo {
@@ -175,6 +281,15 @@ func (gcs *gcsStorage) DisplayName() string {
 	return fmt.Sprintf("GCS: %v", gcs.BucketName)
 }
 
+func (gcs *gcsStorage) Capabilities() blob.Capabilities {
+	return blob.Capabilities{
+		SetTime:        true,
+		Versioning:     true,
+		ConditionalPut: true,
+		ServerSideCopy: true,
+	}
+}
+
 func (gcs *gcsStorage) Close(ctx context.Context) error {
 	return errors.Wrap(gcs.storageClient.Close(), "error closing GCS storage")
 }
@@ -262,6 +377,12 @@ func New(ctx context.Context, opt *Options) (blob.Storage, error) {
 		uploadThrottler:   uploadThrottler,
 	}
 
+	// failure to detect hierarchical namespace (e.g. due to missing 'storage.buckets.get' permission)
+	// only disables optimizations.
+	if gcs.hierarchicalNamespace, err = detectHierarchicalNamespace(ctx, hc, opt.BucketName); err != nil {
+		log(ctx).Debugf("unable to detect hierarchical namespace: %v", err)
+	}
+
 	// verify GCS connection is functional by listing blobs in a bucket, which will fail if the bucket
 	// does not exist. We list with a prefix that will not exist, to avoid iterating through any objects.
 	nonExistentPrefix := fmt.Sprintf("kopia-gcs-storage-initializing-%v", clock.Now().UnixNano())
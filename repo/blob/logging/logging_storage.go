@@ -43,6 +43,69 @@ func (s *loggingStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Meta
 	return result, err
 }
 
+// GetRetention implements blob.RetentionReader.
+func (s *loggingStorage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	t0 := clock.Now()
+	result, err := blob.GetRetention(ctx, s.base, id)
+	dt := clock.Since(t0)
+
+	s.printf(s.prefix+"GetRetention(%q)=(%v, %#v) took %v", id, result, err, dt)
+
+	// nolint:wrapcheck
+	return result, err
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s *loggingStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	t0 := clock.Now()
+	cnt := 0
+	err := blob.ListBlobsAfter(ctx, s.base, prefix, startAfter, func(bi blob.Metadata) error {
+		cnt++
+		return callback(bi)
+	})
+	s.printf(s.prefix+"ListBlobsAfter(%q,%q)=%v returned %v items and took %v", prefix, startAfter, err, cnt, clock.Since(t0))
+
+	// nolint:wrapcheck
+	return err
+}
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s *loggingStorage) PresignedGetURL(ctx context.Context, id blob.ID, expiration time.Duration) (string, error) {
+	t0 := clock.Now()
+	result, err := blob.PresignedGetURL(ctx, s.base, id, expiration)
+
+	// the URL itself is a credential, so it's not logged.
+	s.printf(s.prefix+"PresignedGetURL(%q,%v)=%#v took %v", id, expiration, err, clock.Since(t0))
+
+	// nolint:wrapcheck
+	return result, err
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s *loggingStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	t0 := clock.Now()
+	cnt := 0
+	err := blob.ListDeletedBlobs(ctx, s.base, prefix, func(dm blob.DeletedMetadata) error {
+		cnt++
+		return callback(dm)
+	})
+	s.printf(s.prefix+"ListDeletedBlobs(%q)=%v returned %v items and took %v", prefix, err, cnt, clock.Since(t0))
+
+	// nolint:wrapcheck
+	return err
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s *loggingStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	t0 := clock.Now()
+	err := blob.UndeleteBlob(ctx, s.base, id, versionID)
+	dt := clock.Since(t0)
+	s.printf(s.prefix+"UndeleteBlob(%q,%q)=%#v took %v", id, versionID, err, dt)
+
+	// nolint:wrapcheck
+	return err
+}
+
 func (s *loggingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
 	t0 := clock.Now()
 	err := s.base.PutBlob(ctx, id, data)
@@ -73,6 +136,17 @@ func (s *loggingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
 	return err
 }
 
+// DeleteBlobs implements blob.BatchDeleter.
+func (s *loggingStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	t0 := clock.Now()
+	err := blob.DeleteBlobs(ctx, s.base, ids)
+	dt := clock.Since(t0)
+	s.printf(s.prefix+"DeleteBlobs(%v blobs)=%#v took %v", len(ids), err, dt)
+
+	// nolint:wrapcheck
+	return err
+}
+
 func (s *loggingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
 	t0 := clock.Now()
 	cnt := 0
@@ -104,6 +178,10 @@ func (s *loggingStorage) DisplayName() string {
 	return s.base.DisplayName()
 }
 
+func (s *loggingStorage) Capabilities() blob.Capabilities {
+	return s.base.Capabilities()
+}
+
 // NewWrapper returns a Storage wrapper that logs all storage commands.
 func NewWrapper(wrapped blob.Storage, printf func(msg string, args ...interface{}), prefix string) blob.Storage {
 	return &loggingStorage{base: wrapped, printf: printf, prefix: prefix}
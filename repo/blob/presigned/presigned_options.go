@@ -0,0 +1,97 @@
+package presigned
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Blob describes a single blob along with the pre-signed URL used to read it.
+type Blob struct {
+	blob.Metadata
+
+	URL string `json:"url"`
+}
+
+// Options defines options for storage reading blobs using pre-signed URLs.
+type Options struct {
+	// Blobs contains all blobs accessible using the storage, which is a snapshot of repository contents
+	// at the time the URLs were generated, possibly limited to blobs needed to restore some snapshots.
+	Blobs []Blob `json:"blobs" kopia:"sensitive"`
+
+	// Expires is the time when the pre-signed URLs expire.
+	Expires time.Time `json:"expires"`
+
+	// Source is the name of the storage the URLs were generated for, for informational purposes.
+	Source string `json:"source,omitempty"`
+}
+
+// NewOptions generates pre-signed URLs for blobs in the provided storage selected by the include function
+// (all blobs if nil) using the provided number of parallel workers and returns options for accessing them
+// until the expiration.
+func NewOptions(ctx context.Context, st blob.Reader, expiration time.Duration, parallel int, include func(bm blob.Metadata) bool) (*Options, error) {
+	if parallel <= 0 {
+		parallel = 1
+	}
+
+	opt := &Options{
+		Expires: clock.Now().Add(expiration).UTC(),
+		Source:  st.DisplayName(),
+	}
+
+	// the token is a snapshot of blobs in the repository, blobs written later won't be accessible.
+	if err := st.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		if include == nil || include(bm) {
+			opt.Blobs = append(opt.Blobs, Blob{Metadata: bm})
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error listing blobs")
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	indexes := make(chan int)
+
+	eg.Go(func() error {
+		defer close(indexes)
+
+		for i := range opt.Blobs {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		return nil
+	})
+
+	for i := 0; i < parallel; i++ {
+		eg.Go(func() error {
+			for i := range indexes {
+				b := &opt.Blobs[i]
+
+				u, err := blob.PresignedGetURL(ctx, st, b.BlobID, expiration)
+				if err != nil {
+					return errors.Wrapf(err, "unable to generate URL for %v", b.BlobID)
+				}
+
+				b.URL = u
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, errors.Wrap(err, "error generating pre-signed URLs")
+	}
+
+	return opt, nil
+}
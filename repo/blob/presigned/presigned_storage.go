@@ -0,0 +1,234 @@
+// Package presigned implements read-only Storage which reads blobs using pre-signed URLs, such as
+// S3 pre-signed URLs or Azure SAS URLs, without requiring any storage credentials.
+package presigned
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/retry"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/readonly"
+)
+
+// StorageType is the type of storage reading blobs using pre-signed URLs.
+const StorageType = "presigned"
+
+// ErrExpired is returned when pre-signed URLs have expired.
+var ErrExpired = errors.New("pre-signed URLs have expired, new ones must be generated using 'kopia repository grant-restore-access'")
+
+// httpError is returned when the server responds with unexpected status.
+type httpError struct {
+	statusCode int
+	status     string
+}
+
+func (e httpError) Error() string {
+	return "unexpected response: " + e.status
+}
+
+type presignedStorage struct {
+	Options
+
+	client *http.Client
+	blobs  map[blob.ID]Blob
+	sorted []blob.ID
+}
+
+func (s *presignedStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	if offset < 0 {
+		return nil, errors.Wrap(blob.ErrInvalidRange, "invalid offset")
+	}
+
+	b, ok := s.blobs[id]
+	if !ok {
+		return nil, blob.ErrBlobNotFound
+	}
+
+	if length == 0 {
+		return []byte{}, nil
+	}
+
+	v, err := retry.WithExponentialBackoff(ctx, "GetBlob("+string(id)+")", func() (interface{}, error) {
+		return s.get(ctx, b.URL, offset, length)
+	}, isRetriable)
+	if err != nil {
+		return nil, s.translateError(err)
+	}
+
+	// nolint:wrapcheck
+	return blob.EnsureLengthExactly(v.([]byte), length)
+}
+
+func (s *presignedStorage) get(ctx context.Context, url string, offset, length int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create request")
+	}
+
+	if length > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", offset, offset+length-1))
+	} else if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%v-", offset))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if req.Header.Get("Range") != "" {
+			return nil, errors.New("server does not support range requests")
+		}
+
+	case http.StatusPartialContent:
+
+	default:
+		return nil, httpError{resp.StatusCode, resp.Status}
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+
+	return data, errors.Wrap(err, "error reading response")
+}
+
+func (s *presignedStorage) translateError(err error) error {
+	var he httpError
+
+	if !errors.As(err, &he) {
+		return err
+	}
+
+	switch he.statusCode {
+	case http.StatusNotFound:
+		return blob.ErrBlobNotFound
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		return blob.ErrInvalidRange
+
+	case http.StatusForbidden, http.StatusUnauthorized:
+		if clock.Now().After(s.Expires) {
+			return ErrExpired
+		}
+
+		return err
+
+	default:
+		return err
+	}
+}
+
+func isRetriable(err error) bool {
+	var he httpError
+
+	if errors.As(err, &he) {
+		return he.statusCode == http.StatusTooManyRequests || he.statusCode >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+func (s *presignedStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	b, ok := s.blobs[id]
+	if !ok {
+		return blob.Metadata{}, blob.ErrBlobNotFound
+	}
+
+	return b.Metadata, nil
+}
+
+func (s *presignedStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	for _, id := range s.sorted[sort.Search(len(s.sorted), func(i int) bool { return s.sorted[i] >= prefix }):] {
+		if !strings.HasPrefix(string(id), string(prefix)) {
+			break
+		}
+
+		if err := callback(s.blobs[id].Metadata); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *presignedStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	return readonly.ErrReadonly
+}
+
+func (s *presignedStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	return readonly.ErrReadonly
+}
+
+func (s *presignedStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	return readonly.ErrReadonly
+}
+
+func (s *presignedStorage) ConnectionInfo() blob.ConnectionInfo {
+	return blob.ConnectionInfo{
+		Type:   StorageType,
+		Config: &s.Options,
+	}
+}
+
+func (s *presignedStorage) DisplayName() string {
+	return fmt.Sprintf("Pre-signed URLs: %v (expires %v)", s.Source, s.Expires.Format(time.RFC3339))
+}
+
+func (s *presignedStorage) Capabilities() blob.Capabilities {
+	return blob.Capabilities{}
+}
+
+func (s *presignedStorage) Close(ctx context.Context) error {
+	return nil
+}
+
+// New creates new read-only storage reading blobs using the provided pre-signed URLs.
+func New(ctx context.Context, opt *Options) (blob.Storage, error) {
+	if len(opt.Blobs) == 0 {
+		return nil, errors.New("no blobs provided")
+	}
+
+	if clock.Now().After(opt.Expires) {
+		return nil, ErrExpired
+	}
+
+	s := &presignedStorage{
+		Options: *opt,
+		client:  &http.Client{},
+		blobs:   map[blob.ID]Blob{},
+	}
+
+	for _, b := range opt.Blobs {
+		s.blobs[b.BlobID] = b
+		s.sorted = append(s.sorted, b.BlobID)
+	}
+
+	sort.Slice(s.sorted, func(i, j int) bool {
+		return s.sorted[i] < s.sorted[j]
+	})
+
+	return s, nil
+}
+
+func init() {
+	blob.AddSupportedStorage(
+		StorageType,
+		func() interface{} {
+			return &Options{}
+		},
+		func(ctx context.Context, o interface{}) (blob.Storage, error) {
+			return New(ctx, o.(*Options))
+		})
+}
@@ -0,0 +1,139 @@
+package presigned
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/readonly"
+)
+
+func TestPresignedStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	contents := map[string][]byte{
+		"/p1": []byte("hello world"),
+		"/p2": []byte("foo"),
+		"/q1": []byte("bar"),
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		data, ok := contents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	opt := &Options{
+		Expires: clock.Now().Add(time.Hour),
+		Blobs: []Blob{
+			{Metadata: blob.Metadata{BlobID: "q1", Length: 3}, URL: srv.URL + "/q1?sig=secret"},
+			{Metadata: blob.Metadata{BlobID: "p1", Length: 11}, URL: srv.URL + "/p1?sig=secret"},
+			{Metadata: blob.Metadata{BlobID: "p2", Length: 3}, URL: srv.URL + "/p2?sig=bad"},
+			{Metadata: blob.Metadata{BlobID: "p3", Length: 3}, URL: srv.URL + "/p3?sig=secret"},
+		},
+	}
+
+	st, err := New(ctx, opt)
+	require.NoError(t, err)
+
+	v, err := st.GetBlob(ctx, "p1", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello world"), v)
+
+	v, err = st.GetBlob(ctx, "p1", 6, 5)
+	require.NoError(t, err)
+	require.Equal(t, []byte("world"), v)
+
+	_, err = st.GetBlob(ctx, "p2", 0, -1)
+	require.Error(t, err)
+
+	_, err = st.GetBlob(ctx, "p3", 0, -1)
+	require.True(t, errors.Is(err, blob.ErrBlobNotFound), err)
+
+	_, err = st.GetBlob(ctx, "p4", 0, -1)
+	require.True(t, errors.Is(err, blob.ErrBlobNotFound), err)
+
+	all, err := blob.ListAllBlobs(ctx, st, "p")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	require.Equal(t, blob.ID("p1"), all[0].BlobID)
+
+	require.ErrorIs(t, st.PutBlob(ctx, "p5", gather.FromSlice([]byte{1})), readonly.ErrReadonly)
+	require.ErrorIs(t, st.DeleteBlob(ctx, "p1"), readonly.ErrReadonly)
+
+	opt.Expires = clock.Now().Add(-time.Hour)
+
+	_, err = New(ctx, opt)
+	require.ErrorIs(t, err, ErrExpired)
+}
+
+type presigningStorage struct {
+	blob.Storage
+}
+
+func (s presigningStorage) Capabilities() blob.Capabilities {
+	return blob.Capabilities{PresignedURLs: true}
+}
+
+func (s presigningStorage) PresignedGetURL(ctx context.Context, blobID blob.ID, expiration time.Duration) (string, error) {
+	if blobID == "bad" {
+		return "", errors.New("some error")
+	}
+
+	return fmt.Sprintf("https://example.com/%v?expires=%v", blobID, expiration), nil
+}
+
+func TestNewOptions(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	data := blobtesting.DataMap{}
+	st := presigningStorage{blobtesting.NewMapStorage(data, nil, nil)}
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, st.PutBlob(ctx, blob.ID(fmt.Sprintf("p%03v", i)), gather.FromSlice([]byte{1, 2, 3})))
+	}
+
+	opt, err := NewOptions(ctx, st, time.Hour, 10, nil)
+	require.NoError(t, err)
+	require.Len(t, opt.Blobs, 100)
+	require.True(t, opt.Expires.After(clock.Now()))
+
+	for _, b := range opt.Blobs {
+		require.Equal(t, int64(3), b.Length)
+		require.Equal(t, fmt.Sprintf("https://example.com/%v?expires=1h0m0s", b.BlobID), b.URL)
+	}
+
+	// only selected blobs are included.
+	opt, err = NewOptions(ctx, st, time.Hour, 10, func(bm blob.Metadata) bool {
+		return bm.BlobID < "p010"
+	})
+	require.NoError(t, err)
+	require.Len(t, opt.Blobs, 10)
+
+	require.NoError(t, st.PutBlob(ctx, "bad", gather.FromSlice([]byte{1})))
+
+	_, err = NewOptions(ctx, st, time.Hour, 10, nil)
+	require.Error(t, err)
+}
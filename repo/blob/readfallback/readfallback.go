@@ -0,0 +1,226 @@
+// Package readfallback implements a wrapper around an ordered list of blob storages that
+// transparently fails over reads to replicas when the primary storage is unavailable.
+package readfallback
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("read-fallback")
+
+// fallbackStorage serves reads from the first storage that succeeds, starting with the one that
+// succeeded most recently, so that an unavailable primary does not delay every read.
+// All mutations are performed on the primary storage only.
+type fallbackStorage struct {
+	storages []blob.Storage
+
+	// index of the storage that served the most recent successful read.
+	preferred int32
+}
+
+// forEachStorage invokes the callback for storages in order of preference until it succeeds.
+// Blobs not found in the primary storage are not looked up in replicas, which may be stale,
+// but blobs not found in a replica are looked up in the remaining storages.
+// Returns the error from the first storage attempted if all storages fail.
+func (s *fallbackStorage) forEachStorage(ctx context.Context, desc string, cb func(st blob.Storage) error) error {
+	start := int(atomic.LoadInt32(&s.preferred))
+
+	var firstErr error
+
+	for i := range s.storages {
+		n := (start + i) % len(s.storages)
+
+		err := cb(s.storages[n])
+		if err == nil {
+			if n != start {
+				atomic.StoreInt32(&s.preferred, int32(n))
+			}
+
+			return nil
+		}
+
+		var rnp retryNotPossibleError
+		if errors.As(err, &rnp) {
+			return rnp.error
+		}
+
+		if ctx.Err() != nil {
+			// nolint:wrapcheck
+			return err
+		}
+
+		if n == 0 && errors.Is(err, blob.ErrBlobNotFound) {
+			if n != start {
+				// primary is available again.
+				atomic.StoreInt32(&s.preferred, 0)
+			}
+
+			// nolint:wrapcheck
+			return err
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+
+		if i+1 < len(s.storages) {
+			log(ctx).Debugf("%v failed on %v, trying next storage: %v", desc, s.storages[n].DisplayName(), err)
+		}
+	}
+
+	return firstErr
+}
+
+func (s *fallbackStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	var result []byte
+
+	err := s.forEachStorage(ctx, "GetBlob("+string(id)+")", func(st blob.Storage) error {
+		v, err := st.GetBlob(ctx, id, offset, length)
+		result = v
+
+		// nolint:wrapcheck
+		return err
+	})
+
+	return result, err
+}
+
+func (s *fallbackStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	var result blob.Metadata
+
+	err := s.forEachStorage(ctx, "GetMetadata("+string(id)+")", func(st blob.Storage) error {
+		v, err := st.GetMetadata(ctx, id)
+		result = v
+
+		// nolint:wrapcheck
+		return err
+	})
+
+	return result, err
+}
+
+func (s *fallbackStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	return s.forEachStorage(ctx, "ListBlobs("+string(prefix)+")", func(st blob.Storage) error {
+		var invoked bool
+
+		err := st.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+			invoked = true
+			return callback(bm)
+		})
+
+		if err != nil && invoked {
+			// the callback has already seen partial results, which can't be retried on another storage.
+			return retryNotPossibleError{err}
+		}
+
+		// nolint:wrapcheck
+		return err
+	})
+}
+
+// GetRetention implements blob.RetentionReader.
+func (s *fallbackStorage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	// nolint:wrapcheck
+	return blob.GetRetention(ctx, s.primary(), id)
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s *fallbackStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListBlobsAfter(ctx, s.primary(), prefix, startAfter, callback)
+}
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s *fallbackStorage) PresignedGetURL(ctx context.Context, id blob.ID, expiration time.Duration) (string, error) {
+	// nolint:wrapcheck
+	return blob.PresignedGetURL(ctx, s.primary(), id, expiration)
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s *fallbackStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListDeletedBlobs(ctx, s.primary(), prefix, callback)
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s *fallbackStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	// nolint:wrapcheck
+	return blob.UndeleteBlob(ctx, s.primary(), id, versionID)
+}
+
+func (s *fallbackStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	// nolint:wrapcheck
+	return s.primary().PutBlob(ctx, id, data)
+}
+
+func (s *fallbackStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	// nolint:wrapcheck
+	return s.primary().SetTime(ctx, id, t)
+}
+
+func (s *fallbackStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	// nolint:wrapcheck
+	return s.primary().DeleteBlob(ctx, id)
+}
+
+// DeleteBlobs implements blob.BatchDeleter.
+func (s *fallbackStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	// nolint:wrapcheck
+	return blob.DeleteBlobs(ctx, s.primary(), ids)
+}
+
+func (s *fallbackStorage) Close(ctx context.Context) error {
+	for _, st := range s.storages[1:] {
+		if err := st.Close(ctx); err != nil {
+			log(ctx).Errorf("error closing %v: %v", st.DisplayName(), err)
+		}
+	}
+
+	// nolint:wrapcheck
+	return s.primary().Close(ctx)
+}
+
+func (s *fallbackStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.primary().ConnectionInfo()
+}
+
+func (s *fallbackStorage) DisplayName() string {
+	return s.primary().DisplayName()
+}
+
+func (s *fallbackStorage) Capabilities() blob.Capabilities {
+	return s.primary().Capabilities()
+}
+
+func (s *fallbackStorage) primary() blob.Storage {
+	return s.storages[0]
+}
+
+// retryNotPossibleError wraps an error after which the operation can't be attempted on another storage.
+type retryNotPossibleError struct {
+	error
+}
+
+func (e retryNotPossibleError) Unwrap() error {
+	return e.error
+}
+
+// NewWrapper returns a Storage wrapper that performs mutations on the primary storage and fails over
+// reads to the provided replicas in order, when the primary or the most recently used replica fails.
+// Closing the wrapper closes all storages.
+func NewWrapper(primary blob.Storage, replicas ...blob.Storage) blob.Storage {
+	if len(replicas) == 0 {
+		return primary
+	}
+
+	return &fallbackStorage{
+		storages: append([]blob.Storage{primary}, replicas...),
+	}
+}
@@ -0,0 +1,100 @@
+package readfallback
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+var errUnavailable = errors.New("storage unavailable")
+
+// unavailableStorage fails all reads when down is set.
+type unavailableStorage struct {
+	blob.Storage
+
+	down bool
+}
+
+func (s *unavailableStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	if s.down {
+		return nil, errUnavailable
+	}
+
+	// nolint:wrapcheck
+	return s.Storage.GetBlob(ctx, id, offset, length)
+}
+
+func (s *unavailableStorage) ListBlobs(ctx context.Context, prefix blob.ID, cb func(blob.Metadata) error) error {
+	if s.down {
+		return errUnavailable
+	}
+
+	// nolint:wrapcheck
+	return s.Storage.ListBlobs(ctx, prefix, cb)
+}
+
+func TestReadFallbackStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	blobtesting.VerifyStorage(ctx, t, NewWrapper(
+		blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+		blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)))
+}
+
+func TestReadFallbackFailover(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	primary := &unavailableStorage{Storage: blobtesting.NewMapStorage(blobtesting.DataMap{
+		"a": {1, 2, 3},
+	}, nil, nil)}
+
+	replicaData := blobtesting.DataMap{
+		"a": {1, 2, 3},
+		"b": {4, 5, 6},
+	}
+
+	st := NewWrapper(primary, blobtesting.NewMapStorage(replicaData, nil, nil))
+
+	// blobs not found in the primary are not looked up in the replica.
+	_, err := st.GetBlob(ctx, "b", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	primary.down = true
+
+	v, err := st.GetBlob(ctx, "a", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, v)
+
+	v, err = st.GetBlob(ctx, "b", 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, []byte{5, 6}, v)
+
+	all, err := blob.ListAllBlobs(ctx, st, "")
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	// writes always go to the primary.
+	require.NoError(t, st.PutBlob(ctx, "c", gather.FromSlice([]byte{7})))
+	require.NotContains(t, replicaData, blob.ID("c"))
+
+	// blob missing in the replica is found in the primary once it is available.
+	primary.down = false
+
+	v, err = st.GetBlob(ctx, "c", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte{7}, v)
+
+	// all storages are down.
+	primary.down = true
+	delete(replicaData, "a")
+
+	_, err = st.GetBlob(ctx, "a", 0, -1)
+	require.ErrorIs(t, err, errUnavailable)
+}
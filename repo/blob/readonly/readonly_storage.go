@@ -28,6 +28,35 @@ func (s readonlyStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Meta
 	return s.base.GetMetadata(ctx, id)
 }
 
+// GetRetention implements blob.RetentionReader.
+func (s readonlyStorage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	// nolint:wrapcheck
+	return blob.GetRetention(ctx, s.base, id)
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s readonlyStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListBlobsAfter(ctx, s.base, prefix, startAfter, callback)
+}
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s readonlyStorage) PresignedGetURL(ctx context.Context, id blob.ID, expiration time.Duration) (string, error) {
+	// nolint:wrapcheck
+	return blob.PresignedGetURL(ctx, s.base, id, expiration)
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s readonlyStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListDeletedBlobs(ctx, s.base, prefix, callback)
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s readonlyStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	return ErrReadonly
+}
+
 func (s readonlyStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
 	return ErrReadonly
 }
@@ -40,6 +69,11 @@ func (s readonlyStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
 	return ErrReadonly
 }
 
+// DeleteBlobs implements blob.BatchDeleter.
+func (s readonlyStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	return ErrReadonly
+}
+
 func (s readonlyStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
 	// nolint:wrapcheck
 	return s.base.ListBlobs(ctx, prefix, callback)
@@ -58,6 +92,19 @@ func (s readonlyStorage) DisplayName() string {
 	return s.base.DisplayName()
 }
 
+// Capabilities implements blob.Storage.
+// Capabilities which modify blobs are not reported, previous versions can be listed but not restored.
+func (s readonlyStorage) Capabilities() blob.Capabilities {
+	c := s.base.Capabilities()
+
+	return blob.Capabilities{
+		Retention:      c.Retention,
+		Versioning:     c.Versioning,
+		OrderedListing: c.OrderedListing,
+		PresignedURLs:  c.PresignedURLs,
+	}
+}
+
 // NewWrapper returns a readonly Storage wrapper that prevents any mutations to the underlying storage.
 func NewWrapper(wrapped blob.Storage) blob.Storage {
 	return &readonlyStorage{base: wrapped}
@@ -0,0 +1,243 @@
+// Package replica implements a wrapper around blob.Storage that mirrors mutations of selected blobs
+// to a secondary storage location.
+package replica
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("replica")
+
+// replicaStorage writes to the primary storage and mirrors successful mutations of selected blobs
+// to the replica. All reads are served by the primary storage.
+type replicaStorage struct {
+	base    blob.Storage
+	replica blob.Storage
+
+	shouldReplicate func(id blob.ID) bool
+}
+
+func (s *replicaStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	// nolint:wrapcheck
+	return s.base.GetBlob(ctx, id, offset, length)
+}
+
+func (s *replicaStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	// nolint:wrapcheck
+	return s.base.GetMetadata(ctx, id)
+}
+
+// GetRetention implements blob.RetentionReader.
+func (s *replicaStorage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	// nolint:wrapcheck
+	return blob.GetRetention(ctx, s.base, id)
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s *replicaStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListBlobsAfter(ctx, s.base, prefix, startAfter, callback)
+}
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s *replicaStorage) PresignedGetURL(ctx context.Context, id blob.ID, expiration time.Duration) (string, error) {
+	// nolint:wrapcheck
+	return blob.PresignedGetURL(ctx, s.base, id, expiration)
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s *replicaStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListDeletedBlobs(ctx, s.base, prefix, callback)
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s *replicaStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	if err := blob.UndeleteBlob(ctx, s.base, id, versionID); err != nil {
+		// nolint:wrapcheck
+		return err
+	}
+
+	if s.shouldReplicate(id) {
+		s.copyToReplica(ctx, id)
+	}
+
+	return nil
+}
+
+func (s *replicaStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	if err := s.base.PutBlob(ctx, id, data); err != nil {
+		// nolint:wrapcheck
+		return err
+	}
+
+	if s.shouldReplicate(id) {
+		s.replicaError(ctx, "PutBlob", id, s.replica.PutBlob(ctx, id, data))
+	}
+
+	return nil
+}
+
+func (s *replicaStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	if err := s.base.SetTime(ctx, id, t); err != nil {
+		// nolint:wrapcheck
+		return err
+	}
+
+	if s.shouldReplicate(id) {
+		if err := s.replica.SetTime(ctx, id, t); err != nil && !errors.Is(err, blob.ErrSetTimeUnsupported) {
+			s.replicaError(ctx, "SetTime", id, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *replicaStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	if err := s.base.DeleteBlob(ctx, id); err != nil {
+		// nolint:wrapcheck
+		return err
+	}
+
+	if s.shouldReplicate(id) {
+		if err := s.replica.DeleteBlob(ctx, id); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			s.replicaError(ctx, "DeleteBlob", id, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteBlobs implements blob.BatchDeleter.
+func (s *replicaStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	if err := blob.DeleteBlobs(ctx, s.base, ids); err != nil {
+		// nolint:wrapcheck
+		return err
+	}
+
+	var replicated []blob.ID
+
+	for _, id := range ids {
+		if s.shouldReplicate(id) {
+			replicated = append(replicated, id)
+		}
+	}
+
+	if len(replicated) > 0 {
+		if err := blob.DeleteBlobs(ctx, s.replica, replicated); err != nil {
+			s.replicaError(ctx, "DeleteBlobs", replicated[0], err)
+		}
+	}
+
+	return nil
+}
+
+func (s *replicaStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return s.base.ListBlobs(ctx, prefix, callback)
+}
+
+func (s *replicaStorage) Close(ctx context.Context) error {
+	if err := s.replica.Close(ctx); err != nil {
+		log(ctx).Errorf("error closing replica storage: %v", err)
+	}
+
+	// nolint:wrapcheck
+	return s.base.Close(ctx)
+}
+
+func (s *replicaStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *replicaStorage) DisplayName() string {
+	return s.base.DisplayName()
+}
+
+func (s *replicaStorage) Capabilities() blob.Capabilities {
+	return s.base.Capabilities()
+}
+
+// copyToReplica copies the current contents of the provided primary blob to the replica.
+func (s *replicaStorage) copyToReplica(ctx context.Context, id blob.ID) {
+	data, err := s.base.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		s.replicaError(ctx, "GetBlob", id, err)
+		return
+	}
+
+	s.replicaError(ctx, "PutBlob", id, s.replica.PutBlob(ctx, id, gather.FromSlice(data)))
+}
+
+// replicaError logs failures to update the replica, which never fail the primary operation.
+// Blobs that are missing in the replica are repaired by Sync.
+func (s *replicaStorage) replicaError(ctx context.Context, op string, id blob.ID, err error) {
+	if err != nil {
+		log(ctx).Errorf("unable to replicate %v(%v) to %v: %v", op, id, s.replica.DisplayName(), err)
+	}
+}
+
+// Sync copies blobs accepted by the provided filter from src to dst if they are missing in dst
+// or have different length and returns the number of copied blobs.
+func Sync(ctx context.Context, src blob.Reader, dst blob.Storage, filter func(id blob.ID) bool) (int, error) {
+	existing := map[blob.ID]int64{}
+
+	if err := dst.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		existing[bm.BlobID] = bm.Length
+		return nil
+	}); err != nil {
+		return 0, errors.Wrap(err, "error listing destination blobs")
+	}
+
+	var toCopy []blob.ID
+
+	if err := src.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		if !filter(bm.BlobID) {
+			return nil
+		}
+
+		if l, ok := existing[bm.BlobID]; !ok || l != bm.Length {
+			toCopy = append(toCopy, bm.BlobID)
+		}
+
+		return nil
+	}); err != nil {
+		return 0, errors.Wrap(err, "error listing source blobs")
+	}
+
+	for i, id := range toCopy {
+		data, err := src.GetBlob(ctx, id, 0, -1)
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			// deleted since it was listed.
+			continue
+		}
+
+		if err != nil {
+			return i, errors.Wrapf(err, "error reading %v", id)
+		}
+
+		if err := dst.PutBlob(ctx, id, gather.FromSlice(data)); err != nil {
+			return i, errors.Wrapf(err, "error writing %v", id)
+		}
+	}
+
+	return len(toCopy), nil
+}
+
+// NewWrapper returns a Storage wrapper that mirrors writes, deletions and time changes of blobs
+// accepted by shouldReplicate to the replica storage. Failures to update the replica are logged
+// but don't fail the operation. Closing the wrapper closes both storages.
+func NewWrapper(wrapped, replica blob.Storage, shouldReplicate func(id blob.ID) bool) blob.Storage {
+	return &replicaStorage{
+		base:            wrapped,
+		replica:         replica,
+		shouldReplicate: shouldReplicate,
+	}
+}
@@ -0,0 +1,97 @@
+package replica
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/readonly"
+)
+
+func metadataOnly(id blob.ID) bool {
+	return !strings.HasPrefix(string(id), "p")
+}
+
+func TestReplicaStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	primaryData := blobtesting.DataMap{}
+	replicaData := blobtesting.DataMap{}
+
+	st := NewWrapper(
+		blobtesting.NewMapStorage(primaryData, nil, nil),
+		blobtesting.NewMapStorage(replicaData, nil, nil),
+		metadataOnly)
+
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	for id := range replicaData {
+		require.True(t, metadataOnly(id), id)
+	}
+
+	require.NoError(t, st.PutBlob(ctx, "n123", gather.FromSlice([]byte{1, 2, 3})))
+	require.NoError(t, st.PutBlob(ctx, "p123", gather.FromSlice([]byte{4, 5, 6})))
+
+	require.Equal(t, []byte{1, 2, 3}, replicaData["n123"])
+	require.NotContains(t, replicaData, blob.ID("p123"))
+
+	require.NoError(t, st.DeleteBlob(ctx, "n123"))
+	require.NotContains(t, replicaData, blob.ID("n123"))
+
+	require.NoError(t, st.Close(ctx))
+}
+
+func TestReplicaStorageFailureDoesNotFailPrimary(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	primaryData := blobtesting.DataMap{}
+
+	st := NewWrapper(
+		blobtesting.NewMapStorage(primaryData, nil, nil),
+		readonly.NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)),
+		metadataOnly)
+
+	require.NoError(t, st.PutBlob(ctx, "n1", gather.FromSlice([]byte{1})))
+	require.Equal(t, []byte{1}, primaryData["n1"])
+
+	require.NoError(t, st.DeleteBlob(ctx, "n1"))
+	require.NotContains(t, primaryData, blob.ID("n1"))
+}
+
+func TestSync(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	src := blobtesting.NewMapStorage(blobtesting.DataMap{
+		"n1":               {1, 2, 3},
+		"n2":               {4, 5},
+		"p1":               {6},
+		"kopia.repository": {7, 8},
+	}, nil, nil)
+
+	dstData := blobtesting.DataMap{
+		"n2":               {4, 5},
+		"n3":               {9},
+		"kopia.repository": {7},
+	}
+	dst := blobtesting.NewMapStorage(dstData, nil, nil)
+
+	n, err := Sync(ctx, src, dst, metadataOnly)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	require.Equal(t, blobtesting.DataMap{
+		"n1":               {1, 2, 3},
+		"n2":               {4, 5},
+		"n3":               {9},
+		"kopia.repository": {7, 8},
+	}, dstData)
+
+	n, err = Sync(ctx, src, dst, metadataOnly)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
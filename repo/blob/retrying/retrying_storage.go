@@ -67,6 +67,57 @@ func (s retryingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
 	return err // nolint:wrapcheck
 }
 
+// DeleteBlobs implements blob.BatchDeleter.
+func (s retryingStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	_, err := retry.WithExponentialBackoff(ctx, fmt.Sprintf("DeleteBlobs(%v blobs)", len(ids)), func() (interface{}, error) {
+		// nolint:wrapcheck
+		return true, blob.DeleteBlobs(ctx, s.Storage, ids)
+	}, isRetriable)
+
+	return err // nolint:wrapcheck
+}
+
+// GetRetention implements blob.RetentionReader.
+func (s retryingStorage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	v, err := retry.WithExponentialBackoff(ctx, "GetRetention("+string(id)+")", func() (interface{}, error) {
+		// nolint:wrapcheck
+		return blob.GetRetention(ctx, s.Storage, id)
+	}, isRetriable)
+	if err != nil {
+		return blob.RetentionInfo{}, err // nolint:wrapcheck
+	}
+
+	return v.(blob.RetentionInfo), nil
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s retryingStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListBlobsAfter(ctx, s.Storage, prefix, startAfter, callback)
+}
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s retryingStorage) PresignedGetURL(ctx context.Context, id blob.ID, expiration time.Duration) (string, error) {
+	// nolint:wrapcheck
+	return blob.PresignedGetURL(ctx, s.Storage, id, expiration)
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s retryingStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListDeletedBlobs(ctx, s.Storage, prefix, callback)
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s retryingStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	_, err := retry.WithExponentialBackoff(ctx, "UndeleteBlob("+string(id)+")", func() (interface{}, error) {
+		// nolint:wrapcheck
+		return true, blob.UndeleteBlob(ctx, s.Storage, id, versionID)
+	}, isRetriable)
+
+	return err // nolint:wrapcheck
+}
+
 // NewWrapper returns a Storage wrapper that adds retry loop around all operations of the underlying storage.
 func NewWrapper(wrapped blob.Storage) blob.Storage {
 	return &retryingStorage{Storage: wrapped}
@@ -83,6 +134,15 @@ func isRetriable(err error) bool {
 	case errors.Is(err, blob.ErrSetTimeUnsupported):
 		return false
 
+	case errors.Is(err, blob.ErrRetentionUnsupported):
+		return false
+
+	case errors.Is(err, blob.ErrUndeleteUnsupported):
+		return false
+
+	case errors.Is(err, blob.ErrListAfterUnsupported):
+		return false
+
 	default:
 		return true
 	}
@@ -18,12 +18,16 @@
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/timestampmeta"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/retrying"
 )
 
 const (
 	s3storageType = "s3"
+
+	// user metadata key that stores the time set using SetTime.
+	timeMapKey = "Kopia-Mtime"
 )
 
 type s3Storage struct {
@@ -107,10 +111,20 @@ func (s *s3Storage) GetMetadata(ctx context.Context, b blob.ID) (blob.Metadata,
 	return blob.Metadata{
 		BlobID:    b,
 		Length:    oi.Size,
-		Timestamp: oi.LastModified,
+		Timestamp: timestampFromObjectInfo(oi),
 	}, nil
 }
 
+// timestampFromObjectInfo returns the time set using SetTime, which is preserved in user metadata
+// with full precision, or the last modification time.
+func timestampFromObjectInfo(oi minio.ObjectInfo) time.Time {
+	if t, ok := timestampmeta.FromMap(oi.UserMetadata, timeMapKey); ok {
+		return t
+	}
+
+	return oi.LastModified
+}
+
 func (s *s3Storage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes) error {
 	throttled, err := s.uploadThrottler.AddReader(ioutil.NopCloser(data.Reader()))
 	if err != nil {
@@ -141,8 +155,21 @@ func (s *s3Storage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes) err
 	return err
 }
 
+// SetTime implements blob.Storage.
+// S3 does not allow changing modification times, so the time is stored in user metadata by copying
+// the object onto itself. Listings don't include user metadata and continue to report the time of the copy.
 func (s *s3Storage) SetTime(ctx context.Context, b blob.ID, t time.Time) error {
-	return blob.ErrSetTimeUnsupported
+	_, err := s.cli.CopyObject(ctx, minio.CopyDestOptions{
+		Bucket:          s.BucketName,
+		Object:          s.getObjectNameString(b),
+		UserMetadata:    timestampmeta.ToMap(t, timeMapKey),
+		ReplaceMetadata: true,
+	}, minio.CopySrcOptions{
+		Bucket: s.BucketName,
+		Object: s.getObjectNameString(b),
+	})
+
+	return errors.Wrap(translateError(err), "CopyObject")
 }
 
 func (s *s3Storage) DeleteBlob(ctx context.Context, b blob.ID) error {
@@ -154,18 +181,163 @@ func (s *s3Storage) DeleteBlob(ctx context.Context, b blob.ID) error {
 	return err
 }
 
+// GetRetention implements blob.RetentionReader.
+func (s *s3Storage) GetRetention(ctx context.Context, b blob.ID) (blob.RetentionInfo, error) {
+	mode, retainUntil, err := s.cli.GetObjectRetention(ctx, s.BucketName, s.getObjectNameString(b), "")
+	if err != nil {
+		if isMissingObjectLock(err) {
+			return blob.RetentionInfo{}, nil
+		}
+
+		return blob.RetentionInfo{}, errors.Wrap(translateError(err), "GetObjectRetention")
+	}
+
+	var ri blob.RetentionInfo
+
+	if mode != nil {
+		ri.Mode = string(*mode)
+	}
+
+	if retainUntil != nil {
+		ri.RetainUntil = *retainUntil
+	}
+
+	return ri, nil
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+// Objects whose latest version is a delete marker are reported along with the most recent version preceding it.
+func (s *s3Storage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	defer cancel()
+
+	var (
+		currentKey string
+		deletedAt  time.Time
+		isDeleted  bool
+		reported   bool
+	)
+
+	oi := s.cli.ListObjects(ctx, s.BucketName, minio.ListObjectsOptions{
+		Prefix:       s.getObjectNameString(prefix),
+		WithVersions: true,
+	})
+
+	// versions of each object are returned together, starting with the latest one.
+	for o := range oi {
+		if err := o.Err; err != nil {
+			return translateError(err)
+		}
+
+		if o.Key != currentKey {
+			currentKey = o.Key
+			isDeleted = o.IsLatest && o.IsDeleteMarker
+			deletedAt = o.LastModified
+			reported = false
+
+			continue
+		}
+
+		if !isDeleted || reported || o.IsDeleteMarker {
+			continue
+		}
+
+		reported = true
+
+		if err := callback(blob.DeletedMetadata{
+			Metadata: blob.Metadata{
+				BlobID:    blob.ID(o.Key[len(s.Prefix):]),
+				Length:    o.Size,
+				Timestamp: o.LastModified,
+			},
+			VersionID: o.VersionID,
+			DeletedAt: deletedAt,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UndeleteBlob implements blob.Undeleter by copying the provided version over the deleted object.
+func (s *s3Storage) UndeleteBlob(ctx context.Context, b blob.ID, versionID string) error {
+	objectName := s.getObjectNameString(b)
+
+	_, err := s.cli.CopyObject(ctx, minio.CopyDestOptions{
+		Bucket: s.BucketName,
+		Object: objectName,
+	}, minio.CopySrcOptions{
+		Bucket:    s.BucketName,
+		Object:    objectName,
+		VersionID: versionID,
+	})
+
+	return errors.Wrap(translateError(err), "CopyObject")
+}
+
+// isMissingObjectLock determines whether the error indicates that the object or bucket has no object lock configured.
+func isMissingObjectLock(err error) bool {
+	var me minio.ErrorResponse
+
+	if !errors.As(err, &me) {
+		return false
+	}
+
+	switch me.Code {
+	case "NoSuchObjectLockConfiguration", "ObjectLockConfigurationNotFoundError":
+		return true
+
+	case "InvalidRequest":
+		return strings.Contains(strings.ToLower(me.Message), "object lock")
+	}
+
+	return false
+}
+
+// maxPresignedURLExpiration is the maximum expiration of pre-signed URLs allowed by S3.
+const maxPresignedURLExpiration = 7 * 24 * time.Hour
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s *s3Storage) PresignedGetURL(ctx context.Context, b blob.ID, expiration time.Duration) (string, error) {
+	if expiration > maxPresignedURLExpiration {
+		return "", errors.Errorf("expiration of pre-signed URLs can't exceed %v", maxPresignedURLExpiration)
+	}
+
+	u, err := s.cli.PresignedGetObject(ctx, s.BucketName, s.getObjectNameString(b), expiration, nil)
+	if err != nil {
+		return "", errors.Wrap(translateError(err), "PresignedGetObject")
+	}
+
+	return u.String(), nil
+}
+
 func (s *s3Storage) getObjectNameString(b blob.ID) string {
 	return s.Prefix + string(b)
 }
 
 func (s *s3Storage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	return s.ListBlobsAfter(ctx, prefix, "", callback)
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+// Objects are always listed in lexicographical order, so listing can be resumed from the last reported blob
+// without keeping the continuation token, which is not exposed by the client library.
+func (s *s3Storage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
 	ctx, cancel := context.WithCancel(ctx)
 
 	defer cancel()
 
-	oi := s.cli.ListObjects(ctx, s.BucketName, minio.ListObjectsOptions{
+	opts := minio.ListObjectsOptions{
 		Prefix: s.getObjectNameString(prefix),
-	})
+	}
+
+	if startAfter != "" {
+		opts.StartAfter = s.getObjectNameString(startAfter)
+	}
+
+	oi := s.cli.ListObjects(ctx, s.BucketName, opts)
 	for o := range oi {
 		if err := o.Err; err != nil {
 			return err
@@ -204,6 +376,18 @@ func (s *s3Storage) DisplayName() string {
 	return fmt.Sprintf("S3: %v %v", s.Endpoint, s.BucketName)
 }
 
+func (s *s3Storage) Capabilities() blob.Capabilities {
+	return blob.Capabilities{
+		SetTime:        true,
+		Retention:      true,
+		Versioning:     true,
+		ServerSideCopy: true,
+		BatchDelete:    true,
+		OrderedListing: true,
+		PresignedURLs:  true,
+	}
+}
+
 func toBandwidth(bytesPerSecond int) iothrottler.Bandwidth {
 	if bytesPerSecond <= 0 {
 		return iothrottler.Unlimited
@@ -241,6 +241,38 @@ func TestS3StorageMinio(t *testing.T) {
 	}
 }
 
+func TestS3StorageUndelete(t *testing.T) {
+	t.Parallel()
+	testutil.ProviderTest(t)
+
+	options := getProviderOptionsAndCleanup(t, providerCreds["Wasabi-Versioned"])
+
+	ctx := testlogging.Context(t)
+
+	st, err := New(ctx, options)
+	noError(t, err, "could not create storage")
+
+	defer st.Close(ctx)
+
+	noError(t, st.PutBlob(ctx, "a1", gather.FromSlice([]byte{1, 2, 3})), "PutBlob")
+	noError(t, st.PutBlob(ctx, "a2", gather.FromSlice([]byte{4, 5, 6})), "PutBlob")
+	noError(t, st.DeleteBlob(ctx, "a1"), "DeleteBlob")
+
+	var deleted []blob.DeletedMetadata
+
+	noError(t, blob.ListDeletedBlobs(ctx, st, "a", func(dm blob.DeletedMetadata) error {
+		deleted = append(deleted, dm)
+		return nil
+	}), "ListDeletedBlobs")
+
+	if len(deleted) != 1 || deleted[0].BlobID != "a1" {
+		t.Fatalf("unexpected deleted blobs: %v", deleted)
+	}
+
+	noError(t, blob.UndeleteBlob(ctx, st, "a1", deleted[0].VersionID), "UndeleteBlob")
+	blobtesting.AssertGetBlob(ctx, t, st, "a1", []byte{1, 2, 3})
+}
+
 func TestInvalidCredsFailsFast(t *testing.T) {
 	t.Parallel()
 	testutil.ProviderTest(t)
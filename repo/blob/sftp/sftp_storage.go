@@ -200,6 +200,12 @@ func (s *sftpStorage) DisplayName() string {
 	return fmt.Sprintf("SFTP %v@%v", o.Username, o.Host)
 }
 
+func (s *sftpStorage) Capabilities() blob.Capabilities {
+	return blob.Capabilities{
+		SetTime: true,
+	}
+}
+
 func (s *sftpStorage) Close(ctx context.Context) error {
 	if err := s.Impl.(*sftpImpl).cli.Close(); err != nil {
 		return errors.Wrap(err, "closing SFTP client")
@@ -16,6 +16,19 @@
 // ErrInvalidRange is returned when the requested blob offset or length is invalid.
 var ErrInvalidRange = errors.Errorf("invalid blob offset or length")
 
+// ErrRetentionUnsupported is returned by GetRetention when the storage does not support reporting retention of blobs.
+var ErrRetentionUnsupported = errors.Errorf("blob retention is not supported")
+
+// ErrListAfterUnsupported is returned by ListBlobsAfter when the storage can't resume listing after a given blob.
+var ErrListAfterUnsupported = errors.Errorf("resuming listing is not supported")
+
+// ErrPresignUnsupported is returned by PresignedGetURL when the storage can't generate pre-signed URLs.
+var ErrPresignUnsupported = errors.Errorf("pre-signed URLs are not supported")
+
+// ErrUndeleteUnsupported is returned by ListDeletedBlobs and UndeleteBlob when the storage does not keep
+// previous versions of deleted blobs.
+var ErrUndeleteUnsupported = errors.Errorf("undeleting blobs is not supported")
+
 // Bytes encapsulates a sequence of bytes, possibly stored in a non-contiguous buffers,
 // which can be written sequentially or treated as a io.Reader.
 type Bytes interface {
@@ -46,6 +59,178 @@ type Reader interface {
 
 	// Name of the storage used for quick identification by humans.
 	DisplayName() string
+
+	// Capabilities returns optional features supported by the storage.
+	Capabilities() Capabilities
+}
+
+// Capabilities describes optional features supported by a storage provider, so that higher layers
+// can adjust their behavior upfront instead of failing at runtime.
+type Capabilities struct {
+	// SetTime is true if SetTime() can change modification times of blobs.
+	SetTime bool `json:"setTime"`
+
+	// Retention is true if retention (object lock) of blobs can be reported by GetRetention().
+	Retention bool `json:"retention"`
+
+	// Versioning is true if previous versions of deleted blobs can be listed and restored using
+	// ListDeletedBlobs() and UndeleteBlob(), provided that versioning is enabled on the bucket or container.
+	Versioning bool `json:"versioning"`
+
+	// ConditionalPut is true if the underlying service can atomically create a blob only if it does not exist.
+	ConditionalPut bool `json:"conditionalPut"`
+
+	// ServerSideCopy is true if the underlying service can copy blobs without transferring data through the client.
+	ServerSideCopy bool `json:"serverSideCopy"`
+
+	// BatchDelete is true if the underlying service can delete multiple blobs in a single request.
+	BatchDelete bool `json:"batchDelete"`
+
+	// OrderedListing is true if blobs are listed in lexicographical order and listing can be resumed
+	// after a given blob using ListBlobsAfter().
+	OrderedListing bool `json:"orderedListing"`
+
+	// PresignedURLs is true if the storage can generate URLs which allow reading blobs without credentials.
+	PresignedURLs bool `json:"presignedURLs"`
+}
+
+// RetentionInfo describes retention (object lock) of a single blob.
+type RetentionInfo struct {
+	// Mode is the provider-specific retention mode (e.g. GOVERNANCE or COMPLIANCE), empty if the blob is not locked.
+	Mode string `json:"mode,omitempty"`
+
+	// RetainUntil is the time until which the blob can't be deleted or overwritten.
+	RetainUntil time.Time `json:"retainUntil,omitempty"`
+}
+
+// IsLocked returns true if the blob is protected by retention at the provided time.
+func (r RetentionInfo) IsLocked(now time.Time) bool {
+	return r.RetainUntil.After(now)
+}
+
+// RetentionReader is implemented by storage providers that can report retention of blobs.
+type RetentionReader interface {
+	// GetRetention returns retention of the blob with a given ID or zero RetentionInfo if the blob is not locked.
+	GetRetention(ctx context.Context, blobID ID) (RetentionInfo, error)
+}
+
+// GetRetention returns retention of the blob with a given ID if supported by the storage,
+// ErrRetentionUnsupported otherwise.
+func GetRetention(ctx context.Context, st Reader, blobID ID) (RetentionInfo, error) {
+	rr, ok := st.(RetentionReader)
+	if !ok || !st.Capabilities().Retention {
+		return RetentionInfo{}, ErrRetentionUnsupported
+	}
+
+	// nolint:wrapcheck
+	return rr.GetRetention(ctx, blobID)
+}
+
+// DeletedMetadata describes a deleted blob along with the previous version of it that can be restored.
+type DeletedMetadata struct {
+	Metadata
+
+	// VersionID is the provider-specific identifier of the version that would be restored.
+	VersionID string `json:"versionID"`
+
+	// DeletedAt is the time the blob was deleted or zero time if the provider does not report it.
+	DeletedAt time.Time `json:"deletedAt,omitempty"`
+}
+
+// Undeleter is implemented by storage providers that keep previous versions of deleted blobs
+// (such as GCS buckets with object versioning, versioned S3 buckets or Azure containers with blob versioning).
+type Undeleter interface {
+	// ListDeletedBlobs invokes the provided callback for each blob that is currently deleted, but whose
+	// previous version can still be restored.
+	ListDeletedBlobs(ctx context.Context, blobIDPrefix ID, cb func(dm DeletedMetadata) error) error
+
+	// UndeleteBlob restores the provided version of a deleted blob, making it the current one.
+	UndeleteBlob(ctx context.Context, blobID ID, versionID string) error
+}
+
+// ListDeletedBlobs invokes the provided callback for each deleted blob that can be restored if supported by
+// the storage, returns ErrUndeleteUnsupported otherwise.
+func ListDeletedBlobs(ctx context.Context, st Reader, prefix ID, cb func(dm DeletedMetadata) error) error {
+	u, ok := st.(Undeleter)
+	if !ok || !st.Capabilities().Versioning {
+		return ErrUndeleteUnsupported
+	}
+
+	// nolint:wrapcheck
+	return u.ListDeletedBlobs(ctx, prefix, cb)
+}
+
+// UndeleteBlob restores the provided version of a deleted blob if supported by the storage,
+// returns ErrUndeleteUnsupported otherwise.
+func UndeleteBlob(ctx context.Context, st Storage, blobID ID, versionID string) error {
+	u, ok := st.(Undeleter)
+	if !ok || !st.Capabilities().Versioning {
+		return ErrUndeleteUnsupported
+	}
+
+	// nolint:wrapcheck
+	return u.UndeleteBlob(ctx, blobID, versionID)
+}
+
+// OrderedLister is implemented by storage providers that list blobs in lexicographical order and
+// can start listing after a given blob, which allows interrupted listings to be resumed.
+type OrderedLister interface {
+	// ListBlobsAfter is like ListBlobs, but only reports blobs whose IDs sort after startAfter.
+	ListBlobsAfter(ctx context.Context, blobIDPrefix, startAfter ID, cb func(bm Metadata) error) error
+}
+
+// ListBlobsAfter invokes the provided callback for each blob with the provided prefix whose ID sorts after
+// startAfter if supported by the storage, returns ErrListAfterUnsupported otherwise.
+func ListBlobsAfter(ctx context.Context, st Reader, prefix, startAfter ID, cb func(bm Metadata) error) error {
+	ol, ok := st.(OrderedLister)
+	if !ok || !st.Capabilities().OrderedListing {
+		return ErrListAfterUnsupported
+	}
+
+	// nolint:wrapcheck
+	return ol.ListBlobsAfter(ctx, prefix, startAfter, cb)
+}
+
+// URLPresigner is implemented by storage providers that can generate URLs granting time-limited
+// read access to blobs without credentials (such as S3 pre-signed URLs or Azure SAS URLs).
+type URLPresigner interface {
+	// PresignedGetURL returns URL which can be used to read the provided blob until it expires.
+	PresignedGetURL(ctx context.Context, blobID ID, expiration time.Duration) (string, error)
+}
+
+// PresignedGetURL returns URL granting read access to the provided blob for the provided duration if
+// supported by the storage, returns ErrPresignUnsupported otherwise.
+func PresignedGetURL(ctx context.Context, st Reader, blobID ID, expiration time.Duration) (string, error) {
+	p, ok := st.(URLPresigner)
+	if !ok || !st.Capabilities().PresignedURLs {
+		return "", ErrPresignUnsupported
+	}
+
+	// nolint:wrapcheck
+	return p.PresignedGetURL(ctx, blobID, expiration)
+}
+
+// BatchDeleter is implemented by storage providers that can delete multiple blobs in a single request.
+type BatchDeleter interface {
+	// DeleteBlobs removes the provided blobs from storage, blobs which don't exist are ignored.
+	DeleteBlobs(ctx context.Context, blobIDs []ID) error
+}
+
+// DeleteBlobs removes the provided blobs from storage using batch requests if supported by the storage,
+// or by deleting them one by one otherwise.
+func DeleteBlobs(ctx context.Context, st Storage, blobIDs []ID) error {
+	if bd, ok := st.(BatchDeleter); ok && st.Capabilities().BatchDelete {
+		// nolint:wrapcheck
+		return bd.DeleteBlobs(ctx, blobIDs)
+	}
+
+	for _, id := range blobIDs {
+		if err := st.DeleteBlob(ctx, id); err != nil && !errors.Is(err, ErrBlobNotFound) {
+			return errors.Wrapf(err, "unable to delete blob %q", id)
+		}
+	}
+
+	return nil
 }
 
 // Storage encapsulates API for connecting to blob storage.
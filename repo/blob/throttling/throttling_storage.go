@@ -0,0 +1,133 @@
+// Package throttling implements wrapper around blob.Storage that limits upload and download bandwidth.
+package throttling
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"time"
+
+	"github.com/efarrer/iothrottler"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Limits specifies bandwidth limits enforced by a Throttler, zero means unlimited.
+type Limits struct {
+	MaxDownloadSpeedBytesPerSecond int `json:"maxDownloadSpeedBytesPerSecond,omitempty"`
+	MaxUploadSpeedBytesPerSecond   int `json:"maxUploadSpeedBytesPerSecond,omitempty"`
+}
+
+// Throttler limits the combined bandwidth of all storages wrapped with it,
+// which allows multiple repositories opened in the same process to share a single limit.
+type Throttler struct {
+	downloadPool *iothrottler.IOThrottlerPool
+	uploadPool   *iothrottler.IOThrottlerPool
+}
+
+// Close releases resources associated with the throttler, it must not be used afterwards.
+func (t *Throttler) Close() {
+	t.downloadPool.ReleasePool()
+	t.uploadPool.ReleasePool()
+}
+
+// NewThrottler returns new Throttler enforcing the provided limits.
+func NewThrottler(l Limits) *Throttler {
+	return &Throttler{
+		downloadPool: iothrottler.NewIOThrottlerPool(toBandwidth(l.MaxDownloadSpeedBytesPerSecond)),
+		uploadPool:   iothrottler.NewIOThrottlerPool(toBandwidth(l.MaxUploadSpeedBytesPerSecond)),
+	}
+}
+
+func toBandwidth(bytesPerSecond int) iothrottler.Bandwidth {
+	if bytesPerSecond <= 0 {
+		return iothrottler.Unlimited
+	}
+
+	return iothrottler.Bandwidth(bytesPerSecond) * iothrottler.BytesPerSecond
+}
+
+// throttlingStorage passes all data transferred to and from the underlying storage through the throttler.
+type throttlingStorage struct {
+	blob.Storage
+
+	throttler *Throttler
+}
+
+func (s *throttlingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	v, err := s.Storage.GetBlob(ctx, id, offset, length)
+	if err != nil {
+		// nolint:wrapcheck
+		return nil, err
+	}
+
+	throttled, err := s.throttler.downloadPool.AddReader(ioutil.NopCloser(bytes.NewReader(v)))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to add reader to download throttler")
+	}
+
+	defer throttled.Close() //nolint:errcheck
+
+	// nolint:wrapcheck
+	return ioutil.ReadAll(throttled)
+}
+
+func (s *throttlingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	throttled, err := s.throttler.uploadPool.AddReader(ioutil.NopCloser(data.Reader()))
+	if err != nil {
+		return errors.Wrap(err, "unable to add reader to upload throttler")
+	}
+
+	defer throttled.Close() //nolint:errcheck
+
+	v, err := ioutil.ReadAll(throttled)
+	if err != nil {
+		return errors.Wrap(err, "error reading data")
+	}
+
+	// nolint:wrapcheck
+	return s.Storage.PutBlob(ctx, id, gather.FromSlice(v))
+}
+
+// GetRetention implements blob.RetentionReader.
+func (s *throttlingStorage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	// nolint:wrapcheck
+	return blob.GetRetention(ctx, s.Storage, id)
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s *throttlingStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListBlobsAfter(ctx, s.Storage, prefix, startAfter, callback)
+}
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s *throttlingStorage) PresignedGetURL(ctx context.Context, id blob.ID, expiration time.Duration) (string, error) {
+	// nolint:wrapcheck
+	return blob.PresignedGetURL(ctx, s.Storage, id, expiration)
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s *throttlingStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListDeletedBlobs(ctx, s.Storage, prefix, callback)
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s *throttlingStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	// nolint:wrapcheck
+	return blob.UndeleteBlob(ctx, s.Storage, id, versionID)
+}
+
+// DeleteBlobs implements blob.BatchDeleter.
+func (s *throttlingStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	// nolint:wrapcheck
+	return blob.DeleteBlobs(ctx, s.Storage, ids)
+}
+
+// NewWrapper returns a Storage wrapper that throttles transfers using the provided throttler.
+func NewWrapper(wrapped blob.Storage, t *Throttler) blob.Storage {
+	return &throttlingStorage{Storage: wrapped, throttler: t}
+}
@@ -0,0 +1,59 @@
+package throttling_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob/throttling"
+)
+
+func TestThrottlingStorage(t *testing.T) {
+	th := throttling.NewThrottler(throttling.Limits{})
+	defer th.Close()
+
+	r := throttling.NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), th)
+
+	blobtesting.VerifyStorage(testlogging.Context(t), t, r)
+}
+
+func TestThrottlingStorageSharedLimit(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	const (
+		bytesPerSecond = 100000
+		blobSize       = 50000
+	)
+
+	th := throttling.NewThrottler(throttling.Limits{
+		MaxUploadSpeedBytesPerSecond: bytesPerSecond,
+	})
+	defer th.Close()
+
+	st1 := throttling.NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), th)
+	st2 := throttling.NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), th)
+
+	data := bytes.Repeat([]byte{1}, blobSize)
+	t0 := clock.Now()
+
+	errs := make(chan error, 2)
+
+	go func() { errs <- st1.PutBlob(ctx, "a", gather.FromSlice(data)) }()
+	go func() { errs <- st2.PutBlob(ctx, "b", gather.FromSlice(data)) }()
+
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+
+	// both uploads together consume 1 second worth of the shared limit.
+	require.GreaterOrEqual(t, clock.Since(t0), 900*time.Millisecond)
+
+	got, err := st1.GetBlob(ctx, "a", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+)
+
+// PEMBlockType is the type of PEM block written by RingBuffer.WritePEM.
+const PEMBlockType = "KOPIA STORAGE TRACE"
+
+// Entry is a trace record along with the time it was captured.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Record Record    `json:"record"`
+}
+
+// RingBuffer keeps a fixed number of most recent trace records in memory, so that they can be
+// dumped for post-mortem analysis. It is safe for concurrent use.
+type RingBuffer struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// Add adds the provided record to the buffer, overwriting the oldest one if the buffer is full.
+// The signature matches Options.Output.
+func (b *RingBuffer) Add(ctx context.Context, r Record) {
+	now := clock.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.entries) == 0 {
+		return
+	}
+
+	b.entries[b.next] = Entry{now, r}
+	b.next++
+
+	if b.next == len(b.entries) {
+		b.next = 0
+		b.full = true
+	}
+}
+
+// Entries returns the records currently in the buffer, oldest first.
+func (b *RingBuffer) Entries() []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]Entry(nil), b.entries[0:b.next]...)
+	}
+
+	result := append([]Entry(nil), b.entries[b.next:]...)
+
+	return append(result, b.entries[0:b.next]...)
+}
+
+// WriteTo writes the buffered entries to the provided writer as JSON lines, oldest first.
+func (b *RingBuffer) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+
+	for _, e := range b.Entries() {
+		if err := enc.Encode(e); err != nil {
+			return 0, errors.Wrap(err, "error encoding trace entry")
+		}
+	}
+
+	n, err := w.Write(buf.Bytes())
+
+	return int64(n), errors.Wrap(err, "error writing trace entries")
+}
+
+// WritePEM writes the buffered entries to the provided writer as a single PEM block of JSON lines,
+// which is suitable for including in text output such as logs or terminal output.
+func (b *RingBuffer) WritePEM(w io.Writer) error {
+	var buf bytes.Buffer
+
+	if _, err := b.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	return errors.Wrap(pem.Encode(w, &pem.Block{
+		Type:  PEMBlockType,
+		Bytes: buf.Bytes(),
+	}), "error writing PEM")
+}
+
+// NewRingBuffer returns a RingBuffer that retains the provided number of most recent records.
+func NewRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{entries: make([]Entry, size)}
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestRingBuffer(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	b := NewRingBuffer(3)
+	require.Empty(t, b.Entries())
+
+	b.Add(ctx, Record{Operation: "op1"})
+	b.Add(ctx, Record{Operation: "op2"})
+	require.Equal(t, []string{"op1", "op2"}, entryOperations(b.Entries()))
+
+	b.Add(ctx, Record{Operation: "op3"})
+	b.Add(ctx, Record{Operation: "op4"})
+	b.Add(ctx, Record{Operation: "op5"})
+	require.Equal(t, []string{"op3", "op4", "op5"}, entryOperations(b.Entries()))
+
+	var buf bytes.Buffer
+
+	require.NoError(t, b.WritePEM(&buf))
+
+	blk, rest := pem.Decode(buf.Bytes())
+	require.NotNil(t, blk)
+	require.Empty(t, rest)
+	require.Equal(t, PEMBlockType, blk.Type)
+
+	var decoded []Entry
+
+	s := bufio.NewScanner(bytes.NewReader(blk.Bytes))
+	for s.Scan() {
+		var e Entry
+
+		require.NoError(t, json.Unmarshal(s.Bytes(), &e))
+
+		decoded = append(decoded, e)
+	}
+
+	require.Equal(t, []string{"op3", "op4", "op5"}, entryOperations(decoded))
+
+	// zero-sized buffer ignores all records.
+	b = NewRingBuffer(0)
+	b.Add(ctx, Record{Operation: "op1"})
+	require.Empty(t, b.Entries())
+}
+
+func entryOperations(entries []Entry) []string {
+	var result []string
+
+	for _, e := range entries {
+		result = append(result, e.Record.Operation)
+	}
+
+	return result
+}
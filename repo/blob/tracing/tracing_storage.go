@@ -0,0 +1,226 @@
+// Package tracing implements wrapper around Storage that emits a structured record for each blob operation.
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// minHashSuffixLength is the minimum length of hexadecimal suffix of a blob ID that is removed to determine its prefix.
+const minHashSuffixLength = 16
+
+// Record describes a single blob operation.
+type Record struct {
+	Operation    string        `json:"op"`
+	BlobIDPrefix blob.ID       `json:"prefix"`
+	Length       int64         `json:"length,omitempty"`
+	Count        int           `json:"count,omitempty"`
+	Latency      time.Duration `json:"latencyNs"`
+	Error        string        `json:"error,omitempty"`
+}
+
+func (r Record) String() string {
+	b, _ := json.Marshal(r)
+
+	return string(b)
+}
+
+// Options specifies which operations are traced and where records are emitted.
+type Options struct {
+	// Output receives trace records, it must be safe for concurrent use.
+	Output func(ctx context.Context, r Record)
+
+	// SampleEvery causes only every N-th successful operation to be traced (0 or 1 traces all operations).
+	SampleEvery int
+
+	// SlowThreshold causes operations slower than the provided duration to always be traced (0 disables).
+	SlowThreshold time.Duration
+}
+
+type tracingStorage struct {
+	counter int64 // must be first for atomic access on 32-bit platforms
+
+	base    blob.Storage
+	options Options
+}
+
+// BlobIDPrefix returns the prefix of the provided blob ID, which is the ID without trailing content hash, if any.
+func BlobIDPrefix(id blob.ID) blob.ID {
+	n := len(id)
+
+	for n > 0 && isHexDigit(id[n-1]) {
+		n--
+	}
+
+	if len(id)-n < minHashSuffixLength {
+		return id
+	}
+
+	return id[0:n]
+}
+
+func isHexDigit(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f')
+}
+
+func (s *tracingStorage) emit(ctx context.Context, r Record, err error) {
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	isSampled := s.options.SampleEvery <= 1 || atomic.AddInt64(&s.counter, 1)%int64(s.options.SampleEvery) == 0
+	isSlow := s.options.SlowThreshold > 0 && r.Latency >= s.options.SlowThreshold
+
+	if err != nil || isSampled || isSlow {
+		s.options.Output(ctx, r)
+	}
+}
+
+func (s *tracingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	t0 := clock.Now()
+	result, err := s.base.GetBlob(ctx, id, offset, length)
+	s.emit(ctx, Record{Operation: "GetBlob", BlobIDPrefix: BlobIDPrefix(id), Length: int64(len(result)), Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return result, err
+}
+
+func (s *tracingStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	t0 := clock.Now()
+	result, err := s.base.GetMetadata(ctx, id)
+	s.emit(ctx, Record{Operation: "GetMetadata", BlobIDPrefix: BlobIDPrefix(id), Length: result.Length, Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return result, err
+}
+
+// GetRetention implements blob.RetentionReader.
+func (s *tracingStorage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	t0 := clock.Now()
+	result, err := blob.GetRetention(ctx, s.base, id)
+	s.emit(ctx, Record{Operation: "GetRetention", BlobIDPrefix: BlobIDPrefix(id), Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return result, err
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s *tracingStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	t0 := clock.Now()
+	cnt := 0
+	err := blob.ListBlobsAfter(ctx, s.base, prefix, startAfter, func(bi blob.Metadata) error {
+		cnt++
+		return callback(bi)
+	})
+	s.emit(ctx, Record{Operation: "ListBlobsAfter", BlobIDPrefix: prefix, Count: cnt, Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s *tracingStorage) PresignedGetURL(ctx context.Context, id blob.ID, expiration time.Duration) (string, error) {
+	t0 := clock.Now()
+	result, err := blob.PresignedGetURL(ctx, s.base, id, expiration)
+	s.emit(ctx, Record{Operation: "PresignedGetURL", BlobIDPrefix: BlobIDPrefix(id), Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return result, err
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s *tracingStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	t0 := clock.Now()
+	err := blob.ListDeletedBlobs(ctx, s.base, prefix, callback)
+	s.emit(ctx, Record{Operation: "ListDeletedBlobs", BlobIDPrefix: BlobIDPrefix(prefix), Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s *tracingStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	t0 := clock.Now()
+	err := blob.UndeleteBlob(ctx, s.base, id, versionID)
+	s.emit(ctx, Record{Operation: "UndeleteBlob", BlobIDPrefix: BlobIDPrefix(id), Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+func (s *tracingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	t0 := clock.Now()
+	err := s.base.PutBlob(ctx, id, data)
+	s.emit(ctx, Record{Operation: "PutBlob", BlobIDPrefix: BlobIDPrefix(id), Length: int64(data.Length()), Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+func (s *tracingStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	t0 := clock.Now()
+	err := s.base.SetTime(ctx, id, t)
+	s.emit(ctx, Record{Operation: "SetTime", BlobIDPrefix: BlobIDPrefix(id), Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+func (s *tracingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	t0 := clock.Now()
+	err := s.base.DeleteBlob(ctx, id)
+	s.emit(ctx, Record{Operation: "DeleteBlob", BlobIDPrefix: BlobIDPrefix(id), Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+// DeleteBlobs implements blob.BatchDeleter.
+func (s *tracingStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	t0 := clock.Now()
+	err := blob.DeleteBlobs(ctx, s.base, ids)
+	s.emit(ctx, Record{Operation: "DeleteBlobs", Count: len(ids), Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+func (s *tracingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	t0 := clock.Now()
+	cnt := 0
+	err := s.base.ListBlobs(ctx, prefix, func(bi blob.Metadata) error {
+		cnt++
+		return callback(bi)
+	})
+	s.emit(ctx, Record{Operation: "ListBlobs", BlobIDPrefix: prefix, Count: cnt, Latency: clock.Since(t0)}, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+func (s *tracingStorage) Close(ctx context.Context) error {
+	// nolint:wrapcheck
+	return s.base.Close(ctx)
+}
+
+func (s *tracingStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *tracingStorage) DisplayName() string {
+	return s.base.DisplayName()
+}
+
+func (s *tracingStorage) Capabilities() blob.Capabilities {
+	return s.base.Capabilities()
+}
+
+// NewWrapper returns a Storage wrapper that emits a trace record for blob operations according to the provided options.
+func NewWrapper(wrapped blob.Storage, options Options) blob.Storage {
+	return &tracingStorage{base: wrapped, options: options}
+}
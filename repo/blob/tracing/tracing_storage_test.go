@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type recordCollector struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func (c *recordCollector) output(ctx context.Context, r Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.records = append(c.records, r)
+}
+
+func TestTracingStorage(t *testing.T) {
+	var c recordCollector
+
+	st := NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), Options{Output: c.output})
+
+	ctx := testlogging.Context(t)
+	blobtesting.VerifyStorage(ctx, t, st)
+	require.NotEmpty(t, c.records)
+
+	c.records = nil
+
+	require.NoError(t, st.PutBlob(ctx, "p0123456789abcdef0123", gather.FromSlice([]byte{1, 2, 3})))
+	_, err := st.GetBlob(ctx, "nosuchblob", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	require.Len(t, c.records, 2)
+	require.Equal(t, "PutBlob", c.records[0].Operation)
+	require.Equal(t, blob.ID("p"), c.records[0].BlobIDPrefix)
+	require.Equal(t, int64(3), c.records[0].Length)
+	require.Empty(t, c.records[0].Error)
+	require.Equal(t, "GetBlob", c.records[1].Operation)
+	require.NotEmpty(t, c.records[1].Error)
+}
+
+func TestTracingStorageSampling(t *testing.T) {
+	var c recordCollector
+
+	st := NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), Options{Output: c.output, SampleEvery: 10})
+	ctx := testlogging.Context(t)
+
+	require.NoError(t, st.PutBlob(ctx, "someblob", gather.FromSlice([]byte{1, 2, 3})))
+
+	for i := 0; i < 99; i++ {
+		_, err := st.GetBlob(ctx, "someblob", 0, -1)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, c.records, 10)
+
+	// errors are always traced.
+	for i := 0; i < 5; i++ {
+		_, err := st.GetBlob(ctx, "nosuchblob", 0, -1)
+		require.ErrorIs(t, err, blob.ErrBlobNotFound)
+	}
+
+	require.Len(t, c.records, 15)
+}
+
+func TestBlobIDPrefix(t *testing.T) {
+	cases := map[blob.ID]blob.ID{
+		"p0123456789abcdef0123":      "p",
+		"xn0_0123456789abcdef0123":   "xn0_",
+		"kopia.repository":           "kopia.repository",
+		"q0123":                      "q0123",
+		"0123456789abcdef0123456789": "",
+	}
+
+	for id, want := range cases {
+		require.Equal(t, want, BlobIDPrefix(id), "prefix of %v", id)
+	}
+}
@@ -7,6 +7,13 @@ type Options struct {
 	Username                            string `json:"username,omitempty"`
 	Password                            string `json:"password,omitempty" kopia:"sensitive"`
 	TrustedServerCertificateFingerprint string `json:"trustedServerCertificateFingerprint,omitempty"`
+
+	// ParallelRangeReads is the number of concurrent HTTP range requests used to read a single blob,
+	// values less than 2 disable range reads.
+	ParallelRangeReads int `json:"parallelRangeReads,omitempty"`
+
+	// MaxConcurrentRangeReads limits the number of concurrent range requests to a single server.
+	MaxConcurrentRangeReads int `json:"maxConcurrentRangeReads,omitempty"`
 }
 
 func (fso *Options) shards() []int {
@@ -0,0 +1,230 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	// minRangeReadSize is the minimum size of a single range request, smaller blobs are read using a single request.
+	minRangeReadSize = 4 << 20
+
+	defaultMaxConcurrentRangeReads = 8
+)
+
+// errRangesUnsupported indicates that the server responded in a way that does not allow reading ranges,
+// in which case the blob is read using WebDAV client.
+var errRangesUnsupported = errors.New("range requests not supported")
+
+var (
+	serverLimitsMutex sync.Mutex
+	serverLimits      = map[string]chan struct{}{} // semaphores limiting concurrent range reads, keyed by server host
+)
+
+// serverSemaphore returns the semaphore limiting concurrent range requests to the server, which is
+// shared by all storage instances pointing at the same host.
+func serverSemaphore(host string, limit int) chan struct{} {
+	serverLimitsMutex.Lock()
+	defer serverLimitsMutex.Unlock()
+
+	s := serverLimits[host]
+	if s == nil {
+		s = make(chan struct{}, limit)
+		serverLimits[host] = s
+	}
+
+	return s
+}
+
+// rangeReader reads blobs using multiple concurrent HTTP range requests.
+type rangeReader struct {
+	client   *http.Client
+	baseURL  string
+	username string
+	password string
+	parallel int
+	sem      chan struct{}
+}
+
+func newRangeReader(opts *Options, transport http.RoundTripper) (*rangeReader, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid URL")
+	}
+
+	limit := opts.MaxConcurrentRangeReads
+	if limit <= 0 {
+		limit = defaultMaxConcurrentRangeReads
+	}
+
+	return &rangeReader{
+		client:   &http.Client{Transport: transport},
+		baseURL:  strings.TrimSuffix(opts.URL, "/"),
+		username: opts.Username,
+		password: opts.Password,
+		parallel: opts.ParallelRangeReads,
+		sem:      serverSemaphore(u.Host, limit),
+	}, nil
+}
+
+func (r *rangeReader) blobURL(path string) string {
+	var parts []string
+
+	for _, p := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		parts = append(parts, url.PathEscape(p))
+	}
+
+	return r.baseURL + "/" + strings.Join(parts, "/")
+}
+
+// read reads the provided range of the blob, length < 0 means until the end of the blob.
+func (r *rangeReader) read(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	if offset < 0 {
+		return nil, errors.Wrap(blob.ErrInvalidRange, "invalid offset")
+	}
+
+	partSize := int64(minRangeReadSize)
+	if length >= 0 && length/int64(r.parallel) > partSize {
+		partSize = (length + int64(r.parallel) - 1) / int64(r.parallel)
+	}
+
+	firstLength := partSize
+	if length >= 0 && length < firstLength {
+		firstLength = length
+	}
+
+	// the first request also determines the total size of the blob.
+	first, total, err := r.getRange(ctx, path, offset, firstLength)
+	if err != nil {
+		return nil, err
+	}
+
+	if length < 0 {
+		length = total - offset
+	}
+
+	if offset+length > total {
+		return nil, errors.Wrap(blob.ErrInvalidRange, "invalid length")
+	}
+
+	if int64(len(first)) == length {
+		return first, nil
+	}
+
+	result := make([]byte, length)
+	copy(result, first)
+
+	rest := length - int64(len(first))
+	if rest/int64(r.parallel) > partSize {
+		partSize = (rest + int64(r.parallel) - 1) / int64(r.parallel)
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+
+	for pos := int64(len(first)); pos < length; pos += partSize {
+		pos := pos
+
+		n := partSize
+		if pos+n > length {
+			n = length - pos
+		}
+
+		eg.Go(func() error {
+			data, _, err := r.getRange(ctx, path, offset+pos, n)
+			if err != nil {
+				return err
+			}
+
+			if int64(len(data)) != n {
+				return errors.Errorf("unexpected range length %v, expected %v", len(data), n)
+			}
+
+			copy(result[pos:], data)
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+
+	return result, nil
+}
+
+// getRange reads a single range of the blob and returns its data along with the total size of the blob.
+func (r *rangeReader) getRange(ctx context.Context, path string, offset, length int64) ([]byte, int64, error) {
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, 0, errors.Wrap(ctx.Err(), "canceled while waiting for range request")
+	}
+
+	defer func() { <-r.sem }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.blobURL(path), nil)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "unable to create request")
+	}
+
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", offset, offset+length-1))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "range request failed")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		total, err := parseContentRangeTotal(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		data, err := ioutil.ReadAll(io.LimitReader(resp.Body, length))
+
+		return data, total, errors.Wrap(err, "error reading range")
+
+	case http.StatusOK, http.StatusRequestedRangeNotSatisfiable, http.StatusUnauthorized:
+		// server ignored the range, the blob is empty or authentication is not basic.
+		return nil, 0, errRangesUnsupported
+
+	default:
+		// same as errors returned by WebDAV client, so they are translated and retried consistently.
+		return nil, 0, &os.PathError{Op: "GET", Path: path, Err: errors.Errorf("%v %v", resp.StatusCode, http.StatusText(resp.StatusCode))}
+	}
+}
+
+// parseContentRangeTotal returns the total size from 'Content-Range: bytes start-end/total' header.
+func parseContentRangeTotal(v string) (int64, error) {
+	p := strings.LastIndex(v, "/")
+	if !strings.HasPrefix(v, "bytes ") || p < 0 {
+		return 0, errors.Errorf("invalid Content-Range: %q", v)
+	}
+
+	total, err := strconv.ParseInt(v[p+1:], 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid Content-Range: %q", v)
+	}
+
+	return total, nil
+}
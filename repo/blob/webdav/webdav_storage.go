@@ -43,10 +43,18 @@ type davStorage struct {
 type davStorageImpl struct {
 	Options
 
-	cli *gowebdav.Client
+	cli    *gowebdav.Client
+	ranges *rangeReader // nil when parallel range reads are disabled
 }
 
 func (d *davStorageImpl) GetBlobFromPath(ctx context.Context, dirPath, path string, offset, length int64) ([]byte, error) {
+	if d.ranges != nil && length != 0 {
+		data, err := d.ranges.read(ctx, path, offset, length)
+		if !errors.Is(err, errRangesUnsupported) {
+			return data, d.translateError(err)
+		}
+	}
+
 	data, err := d.cli.Read(path)
 	if err != nil {
 		return nil, d.translateError(err)
@@ -173,6 +181,12 @@ func (d *davStorage) DisplayName() string {
 	return fmt.Sprintf("WebDAV: %v", o.URL)
 }
 
+func (d *davStorage) Capabilities() blob.Capabilities {
+	return blob.Capabilities{
+		ServerSideCopy: true,
+	}
+}
+
 func (d *davStorage) Close(ctx context.Context) error {
 	return nil
 }
@@ -196,9 +210,22 @@ func isRetriable(err error) bool {
 // New creates new WebDAV-backed storage in a specified URL.
 func New(ctx context.Context, opts *Options) (blob.Storage, error) {
 	cli := gowebdav.NewClient(opts.URL, opts.Username, opts.Password)
+	transport := http.DefaultTransport
 
 	if opts.TrustedServerCertificateFingerprint != "" {
-		cli.SetTransport(tlsutil.TransportTrustingSingleCertificate(opts.TrustedServerCertificateFingerprint))
+		transport = tlsutil.TransportTrustingSingleCertificate(opts.TrustedServerCertificateFingerprint)
+		cli.SetTransport(transport)
+	}
+
+	var ranges *rangeReader
+
+	if opts.ParallelRangeReads > 1 {
+		rr, err := newRangeReader(opts, transport)
+		if err != nil {
+			return nil, err
+		}
+
+		ranges = rr
 	}
 
 	s := retrying.NewWrapper(&davStorage{
@@ -206,6 +233,7 @@ func New(ctx context.Context, opts *Options) (blob.Storage, error) {
 			Impl: &davStorageImpl{
 				Options: *opts,
 				cli:     cli,
+				ranges:  ranges,
 			},
 			RootPath: "",
 			Suffix:   fsStorageChunkSuffix,
@@ -7,11 +7,14 @@
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
 
+	"github.com/stretchr/testify/require"
 	"golang.org/x/net/webdav"
 
 	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo/blob"
@@ -110,6 +113,66 @@ func TestWebDAVStorageBuiltInServerWithMissingAsForbidden(t *testing.T) {
 	verifyWebDAVStorage(t, server.URL, "user", "password", []int{1})
 }
 
+func TestWebDAVStorageParallelRangeReads(t *testing.T) {
+	t.Parallel()
+	testutil.ProviderTest(t)
+
+	ctx := testlogging.Context(t)
+	tmpDir := testutil.TempDirectory(t)
+
+	var rangeRequests int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", basicAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.Header.Get("Range") != "" {
+			atomic.AddInt32(&rangeRequests, 1)
+		}
+
+		(&webdav.Handler{
+			FileSystem: webdav.Dir(tmpDir),
+			LockSystem: webdav.NewMemLS(),
+		}).ServeHTTP(w, r)
+	})))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	st, err := New(ctx, &Options{
+		URL:                     server.URL,
+		Username:                "user",
+		Password:                "password",
+		ParallelRangeReads:      4,
+		MaxConcurrentRangeReads: 2,
+	})
+	require.NoError(t, err)
+
+	defer st.Close(ctx)
+
+	data := make([]byte, 3*minRangeReadSize+12345)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	require.NoError(t, st.PutBlob(ctx, "blob1", gather.FromSlice(data)))
+
+	v, err := st.GetBlob(ctx, "blob1", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, data, v)
+	require.Equal(t, int32(4), atomic.LoadInt32(&rangeRequests))
+
+	v, err = st.GetBlob(ctx, "blob1", 100, minRangeReadSize+5)
+	require.NoError(t, err)
+	require.Equal(t, data[100:100+minRangeReadSize+5], v)
+
+	_, err = st.GetBlob(ctx, "no-such-blob", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	_, err = st.GetBlob(ctx, "blob1", int64(len(data))-5, 10)
+	require.ErrorIs(t, err, blob.ErrInvalidRange)
+
+	blobtesting.VerifyStorage(ctx, t, st)
+}
+
 // transformMissingPUTs changes not found responses into forbidden responses.
 func transformMissingPUTs(next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,35 @@
+package repo
+
+import (
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/footer"
+)
+
+// MinFormatVersionBlobIntegrityFooter is the minimum repository format version that supports blob integrity footers,
+// which ensures older clients that would not strip them refuse to open the repository.
+const MinFormatVersionBlobIntegrityFooter = 5
+
+const blobIntegrityKeySize = 32
+
+var blobIntegrityKeyPurpose = []byte("blob integrity footer")
+
+// WrapBlobStorage wraps the provided storage so that blobs are stored in the same way as in the provided repository,
+// which is required when writing blobs read from the repository into another storage.
+func WrapBlobStorage(rep DirectRepository, st blob.Storage) blob.Storage {
+	if !rep.BlobIntegrityFooter() {
+		return st
+	}
+
+	return newBlobIntegrityFooterStorage(st, rep.DeriveKey(blobIntegrityKeyPurpose, blobIntegrityKeySize))
+}
+
+// newBlobIntegrityFooterStorage returns a storage that appends integrity footers to all blobs except the format blob,
+// which must be readable before the key is known.
+func newBlobIntegrityFooterStorage(st blob.Storage, key []byte) blob.Storage {
+	return footer.NewWrapper(st, key, FormatBlobID)
+}
+
+// BlobIntegrityFooter returns true if blobs in the repository have integrity footers.
+func (r *directRepository) BlobIntegrityFooter() bool {
+	return r.blobIntegrityFooter
+}
@@ -0,0 +1,80 @@
+package repo_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/footer"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+)
+
+func TestBlobIntegrityFooter(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		NewRepositoryOptions: func(o *repo.NewRepositoryOptions) {
+			o.BlobIntegrityFooter = true
+		},
+	})
+
+	require.True(t, env.RepositoryWriter.BlobIntegrityFooter())
+	require.Equal(t, repo.MinFormatVersionBlobIntegrityFooter, env.RepositoryWriter.ContentReader().ContentFormat().Version)
+
+	// the footer must not enable other format features implied by the format version.
+	f := env.RepositoryWriter.ContentReader().ContentFormat()
+	require.Equal(t, content.FormatFeatures{}, f.EnabledFeatures())
+
+	ow := env.RepositoryWriter.NewObjectWriter(ctx, object.WriterOptions{})
+	ow.Write([]byte("hello world"))
+
+	oid, err := ow.Result()
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	env.MustReopen(t)
+	require.True(t, env.RepositoryWriter.BlobIntegrityFooter())
+
+	r, err := env.RepositoryWriter.OpenObject(ctx, oid)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	var buf bytes.Buffer
+
+	_, err = buf.ReadFrom(r)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", buf.String())
+}
+
+func TestBlobIntegrityFooterStoredInBlobs(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	require.Error(t, repo.Initialize(ctx, st, &repo.NewRepositoryOptions{
+		BlockFormat:         content.FormattingOptions{Version: 4},
+		BlobIntegrityFooter: true,
+	}, "password"), "format version too old")
+
+	require.NoError(t, repo.Initialize(ctx, st, &repo.NewRepositoryOptions{
+		BlobIntegrityFooter: true,
+	}, "password"))
+
+	require.NotEmpty(t, data[repo.FormatBlobID])
+	require.False(t, bytes.HasSuffix(data[repo.FormatBlobID], []byte("KBIF")), "format blob must not have footer")
+
+	for id, b := range data {
+		if id == repo.FormatBlobID {
+			continue
+		}
+
+		require.GreaterOrEqual(t, len(b), footer.Size, id)
+		require.True(t, bytes.HasSuffix(b, []byte("KBIF")), "blob %v does not have footer", id)
+	}
+}
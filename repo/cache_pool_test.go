@@ -0,0 +1,79 @@
+package repo_test
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/throttling"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+)
+
+func TestSharedCachePool(t *testing.T) {
+	ctx := testlogging.Context(t)
+	cacheDir := testutil.TempDirectory(t)
+
+	pool, err := content.NewCachePool(ctx, &content.CachePoolOptions{
+		CacheDirectory:    cacheDir,
+		MaxCacheSizeBytes: 100 << 20,
+	})
+	require.NoError(t, err)
+
+	th := throttling.NewThrottler(throttling.Limits{})
+
+	// cleanups run in reverse order, so the pool is closed after all repositories.
+	t.Cleanup(func() {
+		th.Close()
+		pool.Close(ctx)
+	})
+
+	withSharedResources := func(o *repo.Options) {
+		o.CachePool = pool
+		o.Throttler = th
+	}
+
+	for i := 0; i < 2; i++ {
+		_, env := repotesting.NewEnvironment(t, repotesting.Options{OpenOptions: withSharedResources})
+
+		w := env.RepositoryWriter.NewObjectWriter(ctx, object.WriterOptions{})
+		w.Write([]byte("hello world"))
+
+		oid, err := w.Result()
+		require.NoError(t, err)
+		require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+		// reopen, so that contents are read from the storage through the cache.
+		env.MustReopen(t, withSharedResources)
+		verify(ctx, t, env.RepositoryWriter, oid, []byte("hello world"), "shared-cache")
+
+		ns := hex.EncodeToString(env.RepositoryWriter.UniqueID())
+
+		require.True(t, cacheDirContains(t, filepath.Join(cacheDir, "contents"), ns), "contents of repository %v not cached", i)
+		require.True(t, cacheDirContains(t, filepath.Join(cacheDir, "metadata"), ns), "metadata of repository %v not cached", i)
+	}
+}
+
+func cacheDirContains(t *testing.T, dir, namespace string) bool {
+	t.Helper()
+
+	found := false
+
+	require.NoError(t, filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() && strings.Contains(fi.Name(), namespace) {
+			found = true
+		}
+
+		return err
+	}))
+
+	return found
+}
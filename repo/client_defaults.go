@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/remoterepoapi"
+)
+
+// ClientDefaults contains recommended client settings published by the repository, which clients
+// apply when connecting unless they are overridden locally.
+type ClientDefaults struct {
+	MaxCacheSizeBytes         int64         `json:"maxCacheSize,omitempty"`
+	MaxMetadataCacheSizeBytes int64         `json:"maxMetadataCacheSize,omitempty"`
+	UploadParallelism         int           `json:"uploadParallelism,omitempty"`
+	CheckpointInterval        time.Duration `json:"checkpointInterval,omitempty"`
+}
+
+// IsEmpty returns true if no defaults are provided.
+func (d *ClientDefaults) IsEmpty() bool {
+	return d == nil || *d == ClientDefaults{}
+}
+
+// ClientDefaultsOverrides indicates which settings have been explicitly provided when connecting
+// and must not be replaced with client defaults published by the repository.
+type ClientDefaultsOverrides struct {
+	MaxCacheSize         bool
+	MaxMetadataCacheSize bool
+	UploadParallelism    bool
+	CheckpointInterval   bool
+}
+
+// ClientDefaults returns client defaults published by the repository.
+func (r *directRepository) ClientDefaults() *ClientDefaults {
+	if r.clientDefaults == nil {
+		return nil
+	}
+
+	d := *r.clientDefaults
+
+	return &d
+}
+
+// SetClientDefaults replaces client defaults published by the repository in the format blob.
+// Clients apply the defaults when they connect to the repository.
+func (r *directRepository) SetClientDefaults(ctx context.Context, d *ClientDefaults) error {
+	repoConfig, err := r.formatBlob.decryptFormatBytes(r.masterKey)
+	if err != nil {
+		return errors.Wrap(err, "unable to decrypt repository config")
+	}
+
+	if d.IsEmpty() {
+		d = nil
+	}
+
+	repoConfig.ClientDefaults = d
+
+	if err := r.writeRepositoryConfig(ctx, repoConfig, r.masterKey); err != nil {
+		return err
+	}
+
+	r.clientDefaults = d
+
+	return nil
+}
+
+// applyClientDefaults updates settings in the provided configuration file, which have not been overridden,
+// with client defaults published by the repository.
+func applyClientDefaults(ctx context.Context, configFile string, d *ClientDefaults, o *ClientDefaultsOverrides) error {
+	if d.IsEmpty() || o == nil {
+		return nil
+	}
+
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	// caching may have been disabled, in which case there's no cache to resize.
+	cachingEnabled := lc.Caching != nil && lc.Caching.CacheDirectory != ""
+
+	if d.MaxCacheSizeBytes > 0 && !o.MaxCacheSize && cachingEnabled {
+		log(ctx).Infof("Using content cache size recommended by the repository: %v MB", d.MaxCacheSizeBytes>>20) //nolint:gomnd
+		lc.Caching.MaxCacheSizeBytes = d.MaxCacheSizeBytes
+	}
+
+	if d.MaxMetadataCacheSizeBytes > 0 && !o.MaxMetadataCacheSize && cachingEnabled {
+		log(ctx).Infof("Using metadata cache size recommended by the repository: %v MB", d.MaxMetadataCacheSizeBytes>>20) //nolint:gomnd
+		lc.Caching.MaxMetadataCacheSizeBytes = d.MaxMetadataCacheSizeBytes
+	}
+
+	if d.UploadParallelism > 0 && !o.UploadParallelism {
+		log(ctx).Infof("Using upload parallelism recommended by the repository: %v", d.UploadParallelism)
+		lc.UploadParallelism = d.UploadParallelism
+	}
+
+	if d.CheckpointInterval > 0 && !o.CheckpointInterval {
+		log(ctx).Infof("Using checkpoint interval recommended by the repository: %v", d.CheckpointInterval)
+		lc.CheckpointInterval = d.CheckpointInterval
+	}
+
+	return lc.writeToFile(configFile)
+}
+
+// clientDefaultsFromParameters decodes client defaults published by the API server.
+func clientDefaultsFromParameters(p *remoterepoapi.Parameters) (*ClientDefaults, error) {
+	if len(p.ClientDefaults) == 0 {
+		return nil, nil
+	}
+
+	var d ClientDefaults
+
+	if err := json.Unmarshal(p.ClientDefaults, &d); err != nil {
+		return nil, errors.Wrap(err, "invalid client defaults")
+	}
+
+	return &d, nil
+}
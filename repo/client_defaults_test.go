@@ -0,0 +1,58 @@
+package repo_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestClientDefaults(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{})
+
+	require.Nil(t, env.RepositoryWriter.ClientDefaults())
+
+	d := &repo.ClientDefaults{
+		MaxCacheSizeBytes:  100 << 20,
+		UploadParallelism:  7,
+		CheckpointInterval: 10 * time.Minute,
+	}
+
+	require.NoError(t, env.RepositoryWriter.SetClientDefaults(ctx, d))
+
+	env.MustReopen(t)
+	require.Equal(t, d, env.RepositoryWriter.ClientDefaults())
+
+	// upload parallelism is overridden locally, other defaults are applied.
+	configFile := filepath.Join(testutil.TempDirectory(t), "kopia.config")
+
+	require.NoError(t, repo.Connect(ctx, configFile, env.RepositoryWriter.BlobStorage(), "foobarbazfoobarbaz", &repo.ConnectOptions{
+		ClientOptions: repo.ClientOptions{
+			UploadParallelism: 3,
+		},
+		CachingOptions: content.CachingOptions{
+			CacheDirectory:    testutil.TempDirectory(t),
+			MaxCacheSizeBytes: 5000 << 20,
+		},
+		ClientDefaultsOverrides: &repo.ClientDefaultsOverrides{
+			UploadParallelism: true,
+		},
+	}))
+
+	lc, err := repo.LoadConfigFromFile(configFile)
+	require.NoError(t, err)
+	require.Equal(t, int64(100<<20), lc.Caching.MaxCacheSizeBytes)
+	require.Equal(t, 3, lc.UploadParallelism)
+	require.Equal(t, 10*time.Minute, lc.CheckpointInterval)
+
+	require.NoError(t, env.RepositoryWriter.SetClientDefaults(ctx, &repo.ClientDefaults{}))
+
+	env.MustReopen(t)
+	require.Nil(t, env.RepositoryWriter.ClientDefaults())
+}
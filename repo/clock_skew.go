@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// ClockSkewBlobIDPrefix is the prefix of short-lived BLOBs written to measure clock skew.
+const ClockSkewBlobIDPrefix = "kopia.clockcheck."
+
+// MaxClockSkew is the maximum difference between local clock and blob storage timestamps
+// above which repository maintenance is not safe.
+const MaxClockSkew = 5 * time.Minute
+
+const clockSkewBlobRandomLength = 8
+
+// ErrClockSkew is returned when local clock differs too much from blob storage timestamps.
+var ErrClockSkew = errors.New("excessive clock skew between local clock and storage")
+
+// MeasureClockSkew writes a small BLOB to the provided storage and returns the difference between
+// its timestamp as reported by the storage and local time, positive when the storage clock is ahead.
+func MeasureClockSkew(ctx context.Context, st blob.Storage, now func() time.Time) (time.Duration, error) {
+	var rnd [clockSkewBlobRandomLength]byte
+
+	if _, err := rand.Read(rnd[:]); err != nil {
+		return 0, errors.Wrap(err, "error generating random blob ID")
+	}
+
+	id := blob.ID(fmt.Sprintf("%v%x", ClockSkewBlobIDPrefix, rnd))
+
+	t0 := now()
+
+	if err := st.PutBlob(ctx, id, gather.FromSlice(rnd[:])); err != nil {
+		return 0, errors.Wrap(err, "error writing clock check blob")
+	}
+
+	t1 := now()
+
+	defer func() {
+		if err := st.DeleteBlob(ctx, id); err != nil {
+			log(ctx).Debugf("unable to delete clock check blob %v: %v", id, err)
+		}
+	}()
+
+	bm, err := st.GetMetadata(ctx, id)
+	if err != nil {
+		return 0, errors.Wrap(err, "error reading clock check blob")
+	}
+
+	// storage timestamp between local times before and after the write means no measurable skew.
+	switch {
+	case bm.Timestamp.Before(t0):
+		return bm.Timestamp.Sub(t0), nil
+	case bm.Timestamp.After(t1):
+		return bm.Timestamp.Sub(t1), nil
+	default:
+		return 0, nil
+	}
+}
+
+// CheckClockSkew returns ErrClockSkew if the absolute value of skew exceeds the provided maximum.
+func CheckClockSkew(skew, maxSkew time.Duration) error {
+	if skew > maxSkew || -skew > maxSkew {
+		return errors.Wrapf(ErrClockSkew, "storage clock differs by %v, which exceeds %v", skew, maxSkew)
+	}
+
+	return nil
+}
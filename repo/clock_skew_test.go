@@ -0,0 +1,46 @@
+package repo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+)
+
+func TestMeasureClockSkew(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	localTime := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		storageOffset time.Duration
+		wantSkew      time.Duration
+		wantErr       bool
+	}{
+		{0, 0, false},
+		{time.Minute, time.Minute, false},
+		{-time.Minute, -time.Minute, false},
+		{10 * time.Minute, 10 * time.Minute, true},
+		{-10 * time.Minute, -10 * time.Minute, true},
+	}
+
+	for _, tc := range cases {
+		data := blobtesting.DataMap{}
+		st := blobtesting.NewMapStorage(data, nil, func() time.Time { return localTime.Add(tc.storageOffset) })
+
+		skew, err := repo.MeasureClockSkew(ctx, st, func() time.Time { return localTime })
+		require.NoError(t, err)
+		require.Equal(t, tc.wantSkew, skew)
+
+		// clock check blob is removed.
+		require.Empty(t, data)
+
+		err = repo.CheckClockSkew(skew, repo.MaxClockSkew)
+		require.Equal(t, tc.wantErr, errors.Is(err, repo.ErrClockSkew), "unexpected error %v for skew %v", err, skew)
+	}
+}
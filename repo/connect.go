@@ -16,6 +16,10 @@ type ConnectOptions struct {
 	ClientOptions
 
 	content.CachingOptions
+
+	// ClientDefaultsOverrides, when set, causes client defaults published by the repository to be applied
+	// to all settings, except the ones that have been overridden.
+	ClientDefaultsOverrides *ClientDefaultsOverrides `json:"-"`
 }
 
 // ErrRepositoryNotInitialized is returned when attempting to connect to repository that has not
@@ -56,10 +60,16 @@ func Connect(ctx context.Context, configFile string, st blob.Storage, password s
 		return errors.Wrap(err, "unable to write config file")
 	}
 
-	return verifyConnect(ctx, configFile, password)
+	defaults, err := verifyConnect(ctx, configFile, password)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(applyClientDefaults(ctx, configFile, defaults, opt.ClientDefaultsOverrides), "unable to apply client defaults")
 }
 
-func verifyConnect(ctx context.Context, configFile, password string) error {
+// verifyConnect verifies that the repository can be opened and returns client defaults published by it.
+func verifyConnect(ctx context.Context, configFile, password string) (*ClientDefaults, error) {
 	// now verify that the repository can be opened with the provided config file.
 	r, err := Open(ctx, configFile, password, nil)
 	if err != nil {
@@ -69,10 +79,16 @@ func verifyConnect(ctx context.Context, configFile, password string) error {
 			log(ctx).Errorf("unable to disconnect after unsuccessful opening: %v", derr)
 		}
 
-		return err
+		return nil, err
+	}
+
+	var defaults *ClientDefaults
+
+	if dr, ok := r.(DirectRepository); ok {
+		defaults = dr.ClientDefaults()
 	}
 
-	return errors.Wrap(r.Close(ctx), "error closing repository")
+	return defaults, errors.Wrap(r.Close(ctx), "error closing repository")
 }
 
 // Disconnect removes the specified configuration file and any local cache directories.
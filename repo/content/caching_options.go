@@ -1,11 +1,16 @@
 package content
 
+import "time"
+
 // CachingOptions specifies configuration of local cache.
 type CachingOptions struct {
 	CacheDirectory            string `json:"cacheDirectory,omitempty"`
 	MaxCacheSizeBytes         int64  `json:"maxCacheSize,omitempty"`
 	MaxMetadataCacheSizeBytes int64  `json:"maxMetadataCacheSize,omitempty"`
 	MaxListCacheDurationSec   int    `json:"maxListCacheDuration,omitempty"`
+	FreeSpacePercent          int    `json:"freeSpacePercent,omitempty"`       // further limits size of each cache to percentage of free space
+	IntegrityCheckIntervalSec int    `json:"integrityCheckInterval,omitempty"` // 0 - default, negative - disabled
+	WriteJournal              bool   `json:"writeJournal,omitempty"`           // journal pending contents locally, so they can be recovered after a crash
 	HMACSecret                []byte `json:"-"`
 }
 
@@ -19,3 +24,11 @@ func (c *CachingOptions) CloneOrDefault() *CachingOptions {
 
 	return &c2
 }
+
+func (c *CachingOptions) integrityCheckInterval() time.Duration {
+	if c.IntegrityCheckIntervalSec == 0 {
+		return DefaultCacheIntegrityCheckInterval
+	}
+
+	return time.Duration(c.IntegrityCheckIntervalSec) * time.Second
+}
@@ -3,11 +3,12 @@
 import (
 	"context"
 	"os"
-	"sync"
+	"path/filepath"
 	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/kopia/kopia/internal/buf"
 	"github.com/kopia/kopia/internal/cache"
@@ -41,8 +42,15 @@ type SharedManager struct {
 	paddingUnit             int
 	repositoryFormatBytes   []byte
 	indexVersion            int
+	indexFetchParallelism   int
 
 	encryptionBufferPool *buf.Pool
+
+	// stops the background cache integrity checks, nil if not running.
+	stopCacheIntegrityChecks func()
+
+	// directory where write managers journal their pending contents, empty if disabled.
+	writeJournalDir string
 }
 
 func (sm *SharedManager) readPackFileLocalIndex(ctx context.Context, packFile blob.ID, packFileLength int64) ([]byte, error) {
@@ -137,38 +145,34 @@ func (sm *SharedManager) tryLoadPackIndexBlobsUnlocked(ctx context.Context, inde
 		return nil
 	}
 
-	log(ctx).Debugf("downloading %v new index blobs (%v bytes)...", len(ch), unprocessedIndexesSize)
-
-	var wg sync.WaitGroup
-
-	errch := make(chan error, parallelFetches)
-
-	for i := 0; i < parallelFetches; i++ {
-		wg.Add(1)
+	log(ctx).Debugf("downloading %v new index blobs (%v bytes) using %v parallel fetches...", len(ch), unprocessedIndexesSize, sm.indexFetchParallelism)
 
-		go func() {
-			defer wg.Done()
+	eg, ctx := errgroup.WithContext(ctx)
 
+	for i := 0; i < sm.indexFetchParallelism; i++ {
+		eg.Go(func() error {
 			for indexBlobID := range ch {
+				if err := ctx.Err(); err != nil {
+					// nolint:wrapcheck
+					return err
+				}
+
 				data, err := sm.indexBlobManager.getIndexBlob(ctx, indexBlobID)
 				if err != nil {
-					errch <- err
-					return
+					return err
 				}
 
 				if err := sm.committedContents.addContent(ctx, indexBlobID, data, false); err != nil {
-					errch <- errors.Wrap(err, "unable to add to committed content cache")
-					return
+					return errors.Wrap(err, "unable to add to committed content cache")
 				}
 			}
-		}()
-	}
 
-	wg.Wait()
-	close(errch)
+			return nil
+		})
+	}
 
-	// Propagate async errors, if any.
-	for err := range errch {
+	if err := eg.Wait(); err != nil {
+		// nolint:wrapcheck
 		return err
 	}
 
@@ -240,36 +244,51 @@ func (sm *SharedManager) decryptAndVerify(encrypted, iv []byte) ([]byte, error)
 	return decrypted, nil
 }
 
+// VerifyPackBlob reads the provided pack blob directly from the storage, bypassing caches,
+// and verifies that each of the provided contents stored in it can be decrypted.
+func (sm *SharedManager) VerifyPackBlob(ctx context.Context, packID blob.ID, infos []Info) error {
+	data, err := sm.st.GetBlob(ctx, packID, 0, -1)
+	if err != nil {
+		return errors.Wrapf(err, "error reading pack blob %v", packID)
+	}
+
+	for _, bi := range infos {
+		if bi.GetPackBlobID() != packID {
+			return errors.Errorf("content %v is not stored in %v", bi.GetContentID(), packID)
+		}
+
+		start, end := int64(bi.GetPackOffset()), int64(bi.GetPackOffset())+int64(bi.GetPackedLength())
+		if end > int64(len(data)) {
+			return errors.Errorf("content %v out of bounds of its pack blob %v", bi.GetContentID(), packID)
+		}
+
+		if _, err := sm.decryptContentAndVerify(data[start:end], bi); err != nil {
+			return errors.Wrapf(err, "content %v is corrupted", bi.GetContentID())
+		}
+	}
+
+	return nil
+}
+
 // IndexBlobs returns the list of active index blobs.
 func (sm *SharedManager) IndexBlobs(ctx context.Context, includeInactive bool) ([]IndexBlobInfo, error) {
 	// nolint:wrapcheck
 	return sm.indexBlobManager.listIndexBlobs(ctx, includeInactive)
 }
 
-func (sm *SharedManager) setupReadManagerCaches(ctx context.Context, caching *CachingOptions) error {
-	dataCacheStorage, err := cache.NewStorageOrNil(ctx, caching.CacheDirectory, caching.MaxCacheSizeBytes, "contents")
-	if err != nil {
-		return errors.Wrap(err, "unable to initialize data cache storage")
-	}
-
-	dataCache, err := newContentCacheForData(ctx, sm.st, dataCacheStorage, caching.MaxCacheSizeBytes, caching.HMACSecret)
-	if err != nil {
-		return errors.Wrap(err, "unable to initialize content cache")
-	}
-
-	metadataCacheSize := caching.MaxMetadataCacheSizeBytes
-	if metadataCacheSize == 0 && caching.MaxCacheSizeBytes > 0 {
-		metadataCacheSize = caching.MaxCacheSizeBytes
-	}
+func (sm *SharedManager) setupReadManagerCaches(ctx context.Context, caching *CachingOptions, opts *ManagerOptions) error {
+	var dataCache, metadataCache contentCache
 
-	metadataCacheStorage, err := cache.NewStorageOrNil(ctx, caching.CacheDirectory, metadataCacheSize, "metadata")
-	if err != nil {
-		return errors.Wrap(err, "unable to initialize data cache storage")
-	}
+	if opts.CachePool != nil {
+		dataCache = opts.CachePool.contentCacheForData(sm.st, "-"+opts.CachePoolNamespace)
+		metadataCache = opts.CachePool.contentCacheForMetadata(sm.st, "-"+opts.CachePoolNamespace)
+	} else {
+		var err error
 
-	metadataCache, err := newContentCacheForMetadata(ctx, sm.st, metadataCacheStorage, metadataCacheSize)
-	if err != nil {
-		return errors.Wrap(err, "unable to initialize metadata cache")
+		dataCache, metadataCache, err = sm.newContentAndMetadataCaches(ctx, caching)
+		if err != nil {
+			return err
+		}
 	}
 
 	listCache, err := newListCache(sm.st, caching)
@@ -303,6 +322,43 @@ func (sm *SharedManager) setupReadManagerCaches(ctx context.Context, caching *Ca
 	return nil
 }
 
+func (sm *SharedManager) newContentAndMetadataCaches(ctx context.Context, caching *CachingOptions) (dataCache, metadataCache contentCache, err error) {
+	dataCacheStorage, err := cache.NewStorageOrNil(ctx, caching.CacheDirectory, caching.MaxCacheSizeBytes, "contents")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to initialize data cache storage")
+	}
+
+	dataCache, err = newContentCacheForData(ctx, sm.st, dataCacheStorage, cache.SizeLimit{
+		MaxSizeBytes:       caching.MaxCacheSizeBytes,
+		FreeSpacePercent:   caching.FreeSpacePercent,
+		FreeSpaceDirectory: caching.CacheDirectory,
+	}, caching.HMACSecret)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to initialize content cache")
+	}
+
+	metadataCacheSize := caching.MaxMetadataCacheSizeBytes
+	if metadataCacheSize == 0 && caching.MaxCacheSizeBytes > 0 {
+		metadataCacheSize = caching.MaxCacheSizeBytes
+	}
+
+	metadataCacheStorage, err := cache.NewStorageOrNil(ctx, caching.CacheDirectory, metadataCacheSize, "metadata")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to initialize data cache storage")
+	}
+
+	metadataCache, err = newContentCacheForMetadata(ctx, sm.st, metadataCacheStorage, cache.SizeLimit{
+		MaxSizeBytes:       metadataCacheSize,
+		FreeSpacePercent:   caching.FreeSpacePercent,
+		FreeSpaceDirectory: caching.CacheDirectory,
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to initialize metadata cache")
+	}
+
+	return dataCache, metadataCache, nil
+}
+
 // AddRef adds a reference to shared manager to prevents its closing on Release().
 func (sm *SharedManager) addRef() {
 	if atomic.LoadInt32(&sm.closed) != 0 {
@@ -324,6 +380,10 @@ func (sm *SharedManager) release(ctx context.Context) error {
 
 	log(ctx).Debugf("closing shared manager")
 
+	if sm.stopCacheIntegrityChecks != nil {
+		sm.stopCacheIntegrityChecks()
+	}
+
 	if err := sm.committedContents.close(); err != nil {
 		return errors.Wrap(err, "error closing committed content index")
 	}
@@ -342,6 +402,10 @@ func NewSharedManager(ctx context.Context, st blob.Storage, f *FormattingOptions
 		opts.TimeNow = clock.Now
 	}
 
+	if opts.IndexFetchParallelism <= 0 {
+		opts.IndexFetchParallelism = defaultIndexFetchParallelism
+	}
+
 	if f.Version < minSupportedReadVersion || f.Version > currentWriteVersion {
 		return nil, errors.Errorf("can't handle repositories created using version %v (min supported %v, max supported %v)", f.Version, minSupportedReadVersion, maxSupportedReadVersion)
 	}
@@ -350,6 +414,18 @@ func NewSharedManager(ctx context.Context, st blob.Storage, f *FormattingOptions
 		return nil, errors.Errorf("can't handle repositories created using version %v (min supported %v, max supported %v)", f.Version, minSupportedWriteVersion, maxSupportedWriteVersion)
 	}
 
+	if v := encryption.MinFormatVersion(f.Encryption); f.Version < v {
+		return nil, errors.Errorf("encryption %v requires format version %v or newer, repository uses %v", f.Encryption, v, f.Version)
+	}
+
+	if v := f.EnabledFeatures().MinFormatVersion(); f.Version < v {
+		return nil, errors.Errorf("enabled format features require format version %v or newer, repository uses %v", v, f.Version)
+	}
+
+	if opts.CachePool != nil && opts.CachePoolNamespace == "" {
+		return nil, errors.Errorf("cache pool namespace must be provided when using cache pool")
+	}
+
 	hasher, encryptor, err := CreateHashAndEncryptor(f)
 	if err != nil {
 		return nil, err
@@ -371,11 +447,12 @@ func NewSharedManager(ctx context.Context, st blob.Storage, f *FormattingOptions
 		writeFormatVersion:      int32(f.Version),
 		encryptionBufferPool:    buf.NewPool(ctx, defaultEncryptionBufferPoolSegmentSize+encryptor.Overhead(), "content-manager-encryption"),
 		indexVersion:            v1IndexVersion,
+		indexFetchParallelism:   opts.IndexFetchParallelism,
 	}
 
 	caching = caching.CloneOrDefault()
 
-	if err := sm.setupReadManagerCaches(ctx, caching); err != nil {
+	if err := sm.setupReadManagerCaches(ctx, caching, opts); err != nil {
 		return nil, errors.Wrap(err, "error setting up read manager caches")
 	}
 
@@ -383,5 +460,11 @@ func NewSharedManager(ctx context.Context, st blob.Storage, f *FormattingOptions
 		return nil, errors.Wrap(err, "error loading indexes")
 	}
 
+	if caching.WriteJournal && caching.CacheDirectory != "" {
+		sm.writeJournalDir = filepath.Join(caching.CacheDirectory, writeJournalDirName)
+	}
+
+	sm.startCacheIntegrityChecks(ctx, caching)
+
 	return sm, nil
 }
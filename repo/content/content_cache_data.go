@@ -12,6 +12,9 @@
 type contentCacheForData struct {
 	pc *cache.PersistentCache
 	st blob.Storage
+
+	namespace string // appended to cache keys to separate repositories sharing the cache
+	shared    bool   // the cache is owned by a CachePool and must not be closed
 }
 
 func adjustCacheKey(cacheKey cacheKey) cacheKey {
@@ -28,22 +31,26 @@ func (c *contentCacheForData) getContent(ctx context.Context, cacheKey cacheKey,
 	cacheKey = adjustCacheKey(cacheKey)
 
 	// nolint:wrapcheck
-	return c.pc.GetOrLoad(ctx, string(cacheKey), func() ([]byte, error) {
+	return c.pc.GetOrLoad(ctx, string(cacheKey)+c.namespace, func() ([]byte, error) {
 		// nolint:wrapcheck
 		return c.st.GetBlob(ctx, blobID, offset, length)
 	})
 }
 
 func (c *contentCacheForData) close(ctx context.Context) {
+	if c.shared {
+		return
+	}
+
 	c.pc.Close(ctx)
 }
 
-func newContentCacheForData(ctx context.Context, st blob.Storage, cacheStorage cache.Storage, maxSizeBytes int64, hmacSecret []byte) (contentCache, error) {
+func newContentCacheForData(ctx context.Context, st blob.Storage, cacheStorage cache.Storage, sizeLimit cache.SizeLimit, hmacSecret []byte) (contentCache, error) {
 	if cacheStorage == nil {
 		return passthroughContentCache{st}, nil
 	}
 
-	pc, err := cache.NewPersistentCache(ctx, "content cache", cacheStorage, cache.ChecksumProtection(hmacSecret), maxSizeBytes, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
+	pc, err := cache.NewPersistentCache(ctx, "content cache", cacheStorage, cache.ChecksumProtection(hmacSecret), sizeLimit, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create base cache")
 	}
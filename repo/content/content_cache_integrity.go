@@ -0,0 +1,146 @@
+package content
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/cache"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/ctxutil"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	// DefaultCacheIntegrityCheckInterval is the default interval between integrity checks of local caches.
+	DefaultCacheIntegrityCheckInterval = 24 * time.Hour
+
+	// cacheIntegrityCheckMarkerFile is a file in the cache directory whose modification time
+	// indicates the completion time of the last integrity check.
+	cacheIntegrityCheckMarkerFile = "last-integrity-check"
+)
+
+// VerifyCacheIntegrity verifies all items in local content and metadata caches owned by the manager
+// and evicts items that are corrupt or don't match blobs in the storage.
+func (sm *SharedManager) VerifyCacheIntegrity(ctx context.Context) (cache.IntegrityStats, error) {
+	var total cache.IntegrityStats
+
+	if dc, ok := sm.contentCache.(*contentCacheForData); ok && !dc.shared {
+		st, err := dc.pc.VerifyIntegrity(ctx, nil)
+		total.Add(st)
+
+		if err != nil {
+			return total, errors.Wrap(err, "error verifying content cache")
+		}
+	}
+
+	if mc, ok := sm.metadataCache.(*contentCacheForMetadata); ok && !mc.shared {
+		st, err := mc.verifyIntegrity(ctx)
+		total.Add(st)
+
+		if err != nil {
+			return total, errors.Wrap(err, "error verifying metadata cache")
+		}
+	}
+
+	return total, nil
+}
+
+// startCacheIntegrityChecks starts a goroutine that periodically verifies local caches. A check runs
+// at startup if the previous one completed more than the configured interval ago.
+func (sm *SharedManager) startCacheIntegrityChecks(ctx context.Context, caching *CachingOptions) {
+	interval := caching.integrityCheckInterval()
+	if interval <= 0 || caching.CacheDirectory == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctxutil.Detach(ctx))
+	done := make(chan struct{})
+
+	sm.stopCacheIntegrityChecks = func() {
+		cancel()
+		<-done
+	}
+
+	markerFile := filepath.Join(caching.CacheDirectory, cacheIntegrityCheckMarkerFile)
+
+	go func() {
+		defer close(done)
+
+		for {
+			st, err := os.Stat(markerFile)
+			if err != nil {
+				// new cache directory, schedule the first check one interval from now.
+				if err := touchFile(markerFile); err != nil {
+					log(ctx).Errorf("unable to create %v: %v", markerFile, err)
+					return
+				}
+
+				continue
+			}
+
+			next := st.ModTime().Add(interval)
+
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-time.After(clock.Until(next)):
+				sm.runCacheIntegrityCheck(ctx, markerFile)
+			}
+		}
+	}()
+}
+
+func (sm *SharedManager) runCacheIntegrityCheck(ctx context.Context, markerFile string) {
+	st, err := sm.VerifyCacheIntegrity(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log(ctx).Errorf("error verifying cache integrity: %v", err)
+		}
+
+		return
+	}
+
+	if st.EvictedCount > 0 {
+		log(ctx).Infof("evicted %v corrupt cache entries (%v bytes)", st.EvictedCount, st.EvictedBytes)
+	}
+
+	if err := touchFile(markerFile); err != nil {
+		log(ctx).Errorf("unable to update %v: %v", markerFile, err)
+	}
+}
+
+func touchFile(fname string) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return errors.Wrap(err, "error creating file")
+	}
+
+	return errors.Wrap(f.Close(), "error closing file")
+}
+
+// verifyIntegrity verifies the metadata cache and evicts items whose length does not match the blob in the storage.
+func (c *contentCacheForMetadata) verifyIntegrity(ctx context.Context) (cache.IntegrityStats, error) {
+	blobLengths := map[string]int64{}
+
+	if err := c.st.ListBlobs(ctx, PackBlobIDPrefixSpecial, func(bm blob.Metadata) error {
+		blobLengths[string(bm.BlobID)+c.namespace] = bm.Length
+		return nil
+	}); err != nil {
+		return cache.IntegrityStats{}, errors.Wrap(err, "error listing blobs")
+	}
+
+	// nolint:wrapcheck
+	return c.pc.VerifyIntegrity(ctx, func(key string, data []byte) error {
+		// items for blobs that are no longer in the storage will be eventually evicted by the regular sweep.
+		if l, ok := blobLengths[key]; ok && l != int64(len(data)) {
+			return errors.Errorf("invalid length %v, expected %v", len(data), l)
+		}
+
+		return nil
+	})
+}
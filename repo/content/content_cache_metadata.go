@@ -24,6 +24,9 @@ type contentCacheForMetadata struct {
 
 	st             blob.Storage
 	shardedMutexes [metadataCacheMutexShards]sync.Mutex
+
+	namespace string // appended to cache keys to separate repositories sharing the cache
+	shared    bool   // the cache is owned by a CachePool and must not be closed
 }
 
 // sync synchronizes metadata cache with all blobs found in the storage.
@@ -70,7 +73,7 @@ func (c *contentCacheForMetadata) getContent(ctx context.Context, cacheKey cache
 	m.Lock()
 	defer m.Unlock()
 
-	if v := c.pc.Get(ctx, string(blobID), offset, length); v != nil {
+	if v := c.pc.Get(ctx, string(blobID)+c.namespace, offset, length); v != nil {
 		return v, nil
 	}
 
@@ -95,7 +98,7 @@ func (c *contentCacheForMetadata) getContent(ctx context.Context, cacheKey cache
 	}
 
 	// store the whole blob in the cache.
-	c.pc.Put(ctx, string(blobID), blobData)
+	c.pc.Put(ctx, string(blobID)+c.namespace, blobData)
 
 	if offset == 0 && length == -1 {
 		return blobData, nil
@@ -109,15 +112,19 @@ func (c *contentCacheForMetadata) getContent(ctx context.Context, cacheKey cache
 }
 
 func (c *contentCacheForMetadata) close(ctx context.Context) {
+	if c.shared {
+		return
+	}
+
 	c.pc.Close(ctx)
 }
 
-func newContentCacheForMetadata(ctx context.Context, st blob.Storage, cacheStorage cache.Storage, maxSizeBytes int64) (contentCache, error) {
+func newContentCacheForMetadata(ctx context.Context, st blob.Storage, cacheStorage cache.Storage, sizeLimit cache.SizeLimit) (contentCache, error) {
 	if cacheStorage == nil {
 		return passthroughContentCache{st}, nil
 	}
 
-	pc, err := cache.NewPersistentCache(ctx, "metadata cache", cacheStorage, cache.NoProtection(), maxSizeBytes, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
+	pc, err := cache.NewPersistentCache(ctx, "metadata cache", cacheStorage, cache.NoProtection(), sizeLimit, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create base cache")
 	}
@@ -0,0 +1,114 @@
+package content
+
+import (
+	"context"
+	"crypto/rand"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/cache"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const cachePoolHMACSecretLength = 32
+
+// CachePoolOptions specifies configuration of a CachePool.
+type CachePoolOptions struct {
+	CacheDirectory            string
+	MaxCacheSizeBytes         int64
+	MaxMetadataCacheSizeBytes int64
+	FreeSpacePercent          int    // further limits size of each cache to percentage of free space, if non-zero
+	HMACSecret                []byte // protects integrity of cached contents, random if not provided
+}
+
+// CachePool is a bounded content and metadata cache that can be shared by multiple
+// repositories opened in the same process, so that the total size of cached data
+// does not grow with the number of repositories.
+type CachePool struct {
+	dataCache     *cache.PersistentCache
+	metadataCache *cache.PersistentCache
+}
+
+// Close closes the cache pool, it must be called after all repositories using it have been closed.
+func (p *CachePool) Close(ctx context.Context) {
+	if p.dataCache != nil {
+		p.dataCache.Close(ctx)
+	}
+
+	if p.metadataCache != nil {
+		p.metadataCache.Close(ctx)
+	}
+}
+
+// contentCacheForData returns the data cache for a repository identified by namespace.
+func (p *CachePool) contentCacheForData(st blob.Storage, namespace string) contentCache {
+	if p.dataCache == nil {
+		return passthroughContentCache{st}
+	}
+
+	return &contentCacheForData{st: st, pc: p.dataCache, namespace: namespace, shared: true}
+}
+
+// contentCacheForMetadata returns the metadata cache for a repository identified by namespace.
+func (p *CachePool) contentCacheForMetadata(st blob.Storage, namespace string) contentCache {
+	if p.metadataCache == nil {
+		return passthroughContentCache{st}
+	}
+
+	return &contentCacheForMetadata{st: st, pc: p.metadataCache, namespace: namespace, shared: true}
+}
+
+// NewCachePool creates a new CachePool with the provided options.
+func NewCachePool(ctx context.Context, opt *CachePoolOptions) (*CachePool, error) {
+	hmacSecret := opt.HMACSecret
+	if len(hmacSecret) == 0 {
+		hmacSecret = make([]byte, cachePoolHMACSecretLength)
+
+		if _, err := rand.Read(hmacSecret); err != nil {
+			return nil, errors.Wrap(err, "unable to generate HMAC secret")
+		}
+	}
+
+	p := &CachePool{}
+
+	dataCacheStorage, err := cache.NewStorageOrNil(ctx, opt.CacheDirectory, opt.MaxCacheSizeBytes, "contents")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize data cache storage")
+	}
+
+	if dataCacheStorage != nil {
+		p.dataCache, err = cache.NewPersistentCache(ctx, "shared content cache", dataCacheStorage, cache.ChecksumProtection(hmacSecret), cache.SizeLimit{
+			MaxSizeBytes:       opt.MaxCacheSizeBytes,
+			FreeSpacePercent:   opt.FreeSpacePercent,
+			FreeSpaceDirectory: opt.CacheDirectory,
+		}, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create shared content cache")
+		}
+	}
+
+	metadataCacheSize := opt.MaxMetadataCacheSizeBytes
+	if metadataCacheSize == 0 && opt.MaxCacheSizeBytes > 0 {
+		metadataCacheSize = opt.MaxCacheSizeBytes
+	}
+
+	metadataCacheStorage, err := cache.NewStorageOrNil(ctx, opt.CacheDirectory, metadataCacheSize, "metadata")
+	if err != nil {
+		p.Close(ctx)
+		return nil, errors.Wrap(err, "unable to initialize metadata cache storage")
+	}
+
+	if metadataCacheStorage != nil {
+		p.metadataCache, err = cache.NewPersistentCache(ctx, "shared metadata cache", metadataCacheStorage, cache.NoProtection(), cache.SizeLimit{
+			MaxSizeBytes:       metadataCacheSize,
+			FreeSpacePercent:   opt.FreeSpacePercent,
+			FreeSpaceDirectory: opt.CacheDirectory,
+		}, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
+		if err != nil {
+			p.Close(ctx)
+			return nil, errors.Wrap(err, "unable to create shared metadata cache")
+		}
+	}
+
+	return p, nil
+}
@@ -104,7 +104,7 @@ func verifyEndToEndFormatter(ctx context.Context, t *testing.T, hashAlgo, encryp
 		HMACSecret:  hmacSecret,
 		MaxPackSize: maxPackSize,
 		MasterKey:   make([]byte, 32), // zero key, does not matter
-		Version:     1,
+		Version:     encryption.MinFormatVersion(encryptionAlgo),
 	}, nil, nil)
 	if err != nil {
 		t.Errorf("can't create content manager with hash %v and encryption %v: %v", hashAlgo, encryptionAlgo, err.Error())
@@ -155,3 +155,19 @@ func verifyEndToEndFormatter(ctx context.Context, t *testing.T, hashAlgo, encryp
 		}
 	}
 }
+
+func TestFormatVersionGating(t *testing.T) {
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+
+	_, err := NewManager(testlogging.Context(t), st, &FormattingOptions{
+		Hash:        hashing.DefaultAlgorithm,
+		Encryption:  "XCHACHA20-POLY1305-HMAC-SHA256",
+		HMACSecret:  hmacSecret,
+		MaxPackSize: maxPackSize,
+		MasterKey:   make([]byte, 32),
+		Version:     1,
+	}, nil, nil)
+	if err == nil {
+		t.Fatalf("expected error when using XChaCha20 with format version 1")
+	}
+}
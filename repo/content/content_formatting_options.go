@@ -8,6 +8,10 @@ type FormattingOptions struct {
 	HMACSecret  []byte `json:"secret,omitempty"`      // HMAC secret used to generate encryption keys
 	MasterKey   []byte `json:"masterKey,omitempty"`   // master encryption key (SIV-mode encryption only)
 	MaxPackSize int    `json:"maxPackSize,omitempty"` // maximum size of a pack object
+
+	// Features lists optional format features enabled in the repository, when nil all features
+	// supported by Version are enabled.
+	Features *FormatFeatures `json:"features,omitempty"`
 }
 
 // GetEncryptionAlgorithm implements encryption.Parameters.
@@ -37,6 +37,17 @@
 	defaultEncryptionBufferPoolSegmentSize = 8 << 20 // 8 MB
 )
 
+// IndexBlobPrefix is the prefix of index blobs.
+const IndexBlobPrefix blob.ID = indexBlobPrefix
+
+// IndexBlobPrefixes contains prefixes of all blobs that are needed to load the index:
+// index blobs, compaction logs and cleanup markers.
+var IndexBlobPrefixes = []blob.ID{
+	indexBlobPrefix,
+	compactionLogBlobPrefix,
+	cleanupBlobPrefix,
+}
+
 // PackBlobIDPrefixes contains all possible prefixes for pack blobs.
 var PackBlobIDPrefixes = []blob.ID{
 	PackBlobIDPrefixRegular,
@@ -44,14 +55,16 @@
 }
 
 const (
-	parallelFetches          = 5                // number of parallel reads goroutines
 	flushPackIndexTimeout    = 10 * time.Minute // time after which all pending indexes are flushes
 	indexBlobPrefix          = "n"
 	defaultMinPreambleLength = 32
 	defaultMaxPreambleLength = 32
 	defaultPaddingUnit       = 4096
 
-	currentWriteVersion = 1
+	// default number of index blobs downloaded and decrypted concurrently when opening a repository.
+	defaultIndexFetchParallelism = 16
+
+	currentWriteVersion = 6
 
 	minSupportedWriteVersion = 1
 	maxSupportedWriteVersion = currentWriteVersion
@@ -93,7 +106,14 @@ type WriteManager struct {
 	disableIndexFlushCount int
 	flushPackIndexesAfter  time.Time // time when those indexes should be flushed
 
-	onUpload func(int64)
+	onUpload     func(int64)
+	onIndexFlush func(context.Context, *Totals)
+
+	logicalBytes int64 // bytes passed to WriteContent since the last index flush, accessed atomically
+
+	journal         *writeJournal   // journal of pending contents, opened on first write after each index flush
+	rotatedJournals []*writeJournal // journals rotated by index flushes, which still have records of uncommitted packs
+	journalDisabled bool            // set after journal failure
 
 	*SharedManager
 }
@@ -104,6 +124,8 @@ type pendingPackInfo struct {
 	currentPackItems map[ID]Info         // contents that are in the pack content currently being built (all inline)
 	currentPackData  *gather.WriteBuffer // total length of all items in the current pack content
 	finalized        bool                // indicates whether currentPackData has local index appended to it
+	reservedMemory   int64               // number of bytes of gather memory budget reserved by contents of this pack
+	journal          *writeJournal       // journal with the most recent records of contents of this pack
 }
 
 // Revision returns data revision number that changes on each write or refresh.
@@ -216,6 +238,13 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 
 	prefix := packPrefixForContentID(contentID)
 
+	// reserve memory before taking the lock, so that waiting for the budget never blocks
+	// goroutines that are writing packs and releasing memory.
+	reserved, err := bm.reserveMemory(ctx, len(data))
+	if err != nil {
+		return err
+	}
+
 	bm.lock()
 
 	atomic.AddInt64(&bm.revision, 1)
@@ -237,6 +266,8 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 
 		if err := bm.writePackAndAddToIndex(ctx, pp, true); err != nil {
 			bm.unlock()
+			gather.ReleaseMemory(reserved)
+
 			return errors.Wrap(err, "error writing previously failed pack")
 		}
 	}
@@ -244,9 +275,14 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 	pp, err := bm.getOrCreatePendingPackInfoLocked(ctx, prefix)
 	if err != nil {
 		bm.unlock()
+		gather.ReleaseMemory(reserved)
+
 		return errors.Wrap(err, "unable to create pending pack")
 	}
 
+	// from now on the reservation is owned by the pack and will be released after it's written.
+	pp.reservedMemory += reserved
+
 	info := &InfoStruct{
 		Deleted:          isDeleted,
 		ContentID:        contentID,
@@ -265,6 +301,8 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 
 	pp.currentPackItems[contentID] = info
 
+	journalToWrite := bm.journalContentLocked(ctx, pp, info)
+
 	shouldWrite := pp.currentPackData.Length() >= bm.maxPackSize
 	if shouldWrite {
 		// we're about to write to storage without holding a lock
@@ -275,6 +313,11 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 
 	bm.unlock()
 
+	// journal records are written and synced before writing the pack.
+	if journalToWrite != nil && !shouldWrite {
+		writeJournalUnlocked(ctx, journalToWrite, false)
+	}
+
 	// at this point we're unlocked so different goroutines can encrypt and
 	// save to storage in parallel.
 	if shouldWrite {
@@ -376,13 +419,23 @@ func (bm *WriteManager) flushPackIndexesLocked(ctx context.Context) error {
 			return errors.Wrap(err, "unable to commit session")
 		}
 
+		before := bm.committedInfosLocked()
+
 		// if we managed to commit the session marker blobs, the index is now fully committed
 		// and will be visible to others, including blob GC.
 		if err := bm.committedContents.addContent(ctx, indexBlobMD.BlobID, dataCopy, true); err != nil {
 			return errors.Wrap(err, "unable to add committed content")
 		}
 
+		delta := bm.indexDeltaLocked(before)
+
 		bm.packIndexBuilder = make(packIndexBuilder)
+
+		bm.rotateJournalsLocked(ctx)
+
+		bm.reportIndexFlush(ctx, delta)
+	} else if atomic.LoadInt64(&bm.logicalBytes) > 0 {
+		bm.reportIndexFlush(ctx, &Totals{})
 	}
 
 	bm.flushPackIndexesAfter = bm.timeNow().Add(flushPackIndexTimeout)
@@ -390,6 +443,45 @@ func (bm *WriteManager) flushPackIndexesLocked(ctx context.Context) error {
 	return nil
 }
 
+// committedInfosLocked returns committed infos of all contents in the index being built.
+func (bm *WriteManager) committedInfosLocked() map[ID]Info {
+	result := map[ID]Info{}
+
+	for id := range bm.packIndexBuilder {
+		if i, err := bm.committedContents.getContent(id); err == nil && !i.GetDeleted() {
+			result[id] = i
+		}
+	}
+
+	return result
+}
+
+// indexDeltaLocked computes the change in totals resulting from committing the current index,
+// given the committed state of each of its contents before the commit.
+func (bm *WriteManager) indexDeltaLocked(before map[ID]Info) *Totals {
+	delta := &Totals{}
+
+	for id := range bm.packIndexBuilder {
+		if prev, ok := before[id]; ok {
+			delta.add(prev, -1)
+		}
+
+		if i, err := bm.committedContents.getContent(id); err == nil && !i.GetDeleted() {
+			delta.add(i, 1)
+		}
+	}
+
+	return delta
+}
+
+func (bm *WriteManager) reportIndexFlush(ctx context.Context, delta *Totals) {
+	delta.LogicalBytes = atomic.SwapInt64(&bm.logicalBytes, 0)
+
+	if bm.onIndexFlush != nil {
+		bm.onIndexFlush(ctx, delta)
+	}
+}
+
 func (bm *WriteManager) finishAllPacksLocked(ctx context.Context) error {
 	for prefix, pp := range bm.pendingPacks {
 		delete(bm.pendingPacks, prefix)
@@ -404,6 +496,11 @@ func (bm *WriteManager) finishAllPacksLocked(ctx context.Context) error {
 }
 
 func (bm *WriteManager) writePackAndAddToIndex(ctx context.Context, pp *pendingPackInfo, holdingLock bool) error {
+	// the pack is no longer pending, so its journal can't change.
+	if pp.journal != nil {
+		writeJournalUnlocked(ctx, pp.journal, true)
+	}
+
 	packFileIndex, err := bm.prepareAndWritePackInternal(ctx, pp)
 
 	if !holdingLock {
@@ -426,6 +523,8 @@ func (bm *WriteManager) writePackAndAddToIndex(ctx context.Context, pp *pendingP
 		}
 
 		pp.currentPackData.Close()
+		gather.ReleaseMemory(pp.reservedMemory)
+		pp.reservedMemory = 0
 
 		return nil
 	}
@@ -473,6 +572,10 @@ func (bm *WriteManager) ContentFormat() FormattingOptions {
 
 // Close closes the content manager.
 func (bm *WriteManager) Close(ctx context.Context) error {
+	bm.lock()
+	bm.closeJournalsLocked(ctx)
+	bm.unlock()
+
 	return bm.SharedManager.release(ctx)
 }
 
@@ -623,6 +726,8 @@ func (bm *WriteManager) WriteContent(ctx context.Context, data []byte, prefix ID
 		return "", err
 	}
 
+	atomic.AddInt64(&bm.logicalBytes, int64(len(data)))
+
 	var hashOutput [maxHashSize]byte
 
 	contentID := prefix + ID(hex.EncodeToString(bm.hashData(hashOutput[:0], data)))
@@ -764,6 +869,14 @@ type ManagerOptions struct {
 	RepositoryFormatBytes []byte
 	TimeNow               func() time.Time // Time provider
 
+	// CachePool, if set, replaces content and metadata caches configured in CachingOptions.
+	// CachePoolNamespace must uniquely identify the repository among all users of the pool.
+	CachePool          *CachePool
+	CachePoolNamespace string
+
+	// IndexFetchParallelism is the maximum number of index blobs downloaded concurrently (0 == default).
+	IndexFetchParallelism int
+
 	ownWritesCache ownWritesCache // test hook to allow overriding own-writes cache
 }
 
@@ -798,6 +911,9 @@ type SessionOptions struct {
 	SessionUser string
 	SessionHost string
 	OnUpload    func(int64)
+
+	// OnIndexFlush is invoked after each successful index flush with the change in content totals.
+	OnIndexFlush func(context.Context, *Totals)
 }
 
 // NewWriteManager returns a session write manager.
@@ -822,5 +938,6 @@ func NewWriteManager(sm *SharedManager, options SessionOptions) *WriteManager {
 		sessionUser:           options.SessionUser,
 		sessionHost:           options.SessionHost,
 		onUpload:              options.OnUpload,
+		onIndexFlush:          options.OnIndexFlush,
 	}
 }
@@ -8,6 +8,7 @@
 	"sync/atomic"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/kopia/kopia/repo/blob"
 )
@@ -223,8 +224,37 @@ func(ci Info) error {
 
 // IterateUnreferencedBlobs returns the list of unreferenced storage blobs.
 func (bm *WriteManager) IterateUnreferencedBlobs(ctx context.Context, blobPrefixes []blob.ID, parallellism int, callback func(blob.Metadata) error) error {
+	return bm.IterateUnreferencedBlobsWithOptions(ctx, IterateUnreferencedBlobsOptions{
+		Prefixes: blobPrefixes,
+		Parallel: parallellism,
+	}, func(_ blob.ID, bm blob.Metadata) error {
+		return callback(bm)
+	})
+}
+
+// IterateUnreferencedBlobsOptions contains the options used for iterating over unreferenced blobs.
+type IterateUnreferencedBlobsOptions struct {
+	Prefixes []blob.ID
+	Parallel int
+
+	// StartAfter maps listed prefixes to blob IDs after which listing of the prefix is resumed,
+	// which requires storage supporting blob.ListBlobsAfter().
+	StartAfter map[blob.ID]blob.ID
+
+	// Completed contains listed prefixes which are skipped.
+	Completed map[blob.ID]bool
+
+	// PrefixCompleted, if set, is invoked after all blobs with the provided listed prefix have been reported.
+	PrefixCompleted func(listedPrefix blob.ID)
+}
+
+// IterateUnreferencedBlobsWithOptions invokes the provided callback for each unreferenced storage blob
+// along with the prefix being listed, which is one of the provided prefixes optionally followed by a hex digit.
+func (bm *WriteManager) IterateUnreferencedBlobsWithOptions(ctx context.Context, opt IterateUnreferencedBlobsOptions, callback func(listedPrefix blob.ID, bm blob.Metadata) error) error {
 	var usedPacks sync.Map
 
+	blobPrefixes := opt.Prefixes
+
 	log(ctx).Debugf("determining blobs in use")
 	// find packs in use
 	if err := bm.IteratePacks(
@@ -248,6 +278,11 @@ func(pi PackInfo) error {
 		blobPrefixes = PackBlobIDPrefixes
 	}
 
+	parallellism := opt.Parallel
+	if parallellism <= 0 {
+		parallellism = 1
+	}
+
 	var prefixes []blob.ID
 
 	if parallellism <= len(blobPrefixes) {
@@ -263,16 +298,53 @@ func(pi PackInfo) error {
 
 	log(ctx).Debugf("scanning prefixes %v", prefixes)
 
-	if err := blob.IterateAllPrefixesInParallel(ctx, parallellism, bm.st, prefixes,
-		func(bm blob.Metadata) error {
-			if _, ok := usedPacks.Load(bm.BlobID); ok {
-				return nil
+	eg, ctx := errgroup.WithContext(ctx)
+	semaphore := make(chan struct{}, parallellism)
+
+	for _, prefix := range prefixes {
+		prefix := prefix
+
+		if opt.Completed[prefix] {
+			log(ctx).Debugf("skipping completed prefix %v", prefix)
+			continue
+		}
+
+		eg.Go(func() error {
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			cb := func(md blob.Metadata) error {
+				if _, ok := usedPacks.Load(md.BlobID); ok {
+					return nil
+				}
+
+				atomic.AddInt32(unusedCount, 1)
+
+				return callback(prefix, md)
 			}
 
-			atomic.AddInt32(unusedCount, 1)
+			var err error
 
-			return callback(bm)
-		}); err != nil {
+			if startAfter := opt.StartAfter[prefix]; startAfter != "" {
+				log(ctx).Debugf("resuming listing of %v after %v", prefix, startAfter)
+				err = blob.ListBlobsAfter(ctx, bm.st, prefix, startAfter, cb)
+			} else {
+				err = bm.st.ListBlobs(ctx, prefix, cb)
+			}
+
+			if err != nil {
+				return err // nolint:wrapcheck
+			}
+
+			if opt.PrefixCompleted != nil {
+				opt.PrefixCompleted(prefix)
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
 		return errors.Wrap(err, "error iterating blobs")
 	}
 
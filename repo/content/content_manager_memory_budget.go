@@ -0,0 +1,77 @@
+package content
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+)
+
+// DefaultMaxPackSize is the default maximum size of a pack blob.
+const DefaultMaxPackSize = 20 << 20 // 20 MB
+
+// MinMemoryBudget returns the smallest gather memory budget that can accommodate a full pack of the
+// provided size. When the budget is exhausted, the largest pending pack is written early to release
+// its reservation, so smaller budgets would cause most packs to be written before they fill up.
+func MinMemoryBudget(maxPackSize int) int64 {
+	return int64(maxPackSize)
+}
+
+// reserveMemory reserves the gather memory budget for a content of a given length, blocking
+// until enough memory is released by packs being written.
+// It must not be called while holding the manager lock.
+func (bm *WriteManager) reserveMemory(ctx context.Context, length int) (int64, error) {
+	budget := gather.MemoryBudget()
+	if budget == 0 {
+		return 0, nil
+	}
+
+	if minBudget := MinMemoryBudget(bm.maxPackSize); budget < minBudget {
+		return 0, errors.Errorf("memory budget of %v bytes is too small for pack size %v, must be at least %v", budget, bm.maxPackSize, minBudget)
+	}
+
+	if gather.TryAcquireMemory(ctx, int64(length)) {
+		return int64(length), nil
+	}
+
+	// pending packs hold their reservations until they fill up, which may never happen when
+	// there are no other writers, so write the largest one early instead of waiting for it.
+	if err := bm.writeLargestPendingPack(ctx); err != nil {
+		return 0, err
+	}
+
+	if err := gather.AcquireMemory(ctx, int64(length)); err != nil {
+		return 0, errors.Wrap(err, "unable to reserve memory for content")
+	}
+
+	return int64(length), nil
+}
+
+// writeLargestPendingPack writes the pending pack holding the largest memory reservation.
+func (bm *WriteManager) writeLargestPendingPack(ctx context.Context) error {
+	bm.lock()
+
+	var largest *pendingPackInfo
+
+	for _, pp := range bm.pendingPacks {
+		if largest == nil || pp.reservedMemory > largest.reservedMemory {
+			largest = pp
+		}
+	}
+
+	// packs are about to be written by the flush anyway.
+	if largest == nil || largest.reservedMemory == 0 || bm.flushing {
+		bm.unlock()
+		return nil
+	}
+
+	delete(bm.pendingPacks, largest.prefix)
+	bm.writingPacks = append(bm.writingPacks, largest)
+
+	bm.unlock()
+
+	formatLog(ctx).Debugf("write-pack-early %v reserved:%v", largest.packBlobID, largest.reservedMemory)
+
+	return errors.Wrap(bm.writePackAndAddToIndex(ctx, largest, false), "unable to write pending pack")
+}
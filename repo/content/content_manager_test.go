@@ -22,6 +22,7 @@
 
 	"github.com/kopia/kopia/internal/blobtesting"
 	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo/blob"
@@ -1716,6 +1717,48 @@ func verifyUnreferencedBlobsCount(ctx context.Context, t *testing.T, bm *WriteMa
 	}
 }
 
+func TestIterateUnreferencedBlobsWithStartAfter(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	keyTime := map[blob.ID]time.Time{}
+
+	bm := newTestContentManager(t, data, keyTime, faketime.Frozen(fakeTime))
+	defer bm.Close(ctx)
+
+	for _, id := range []blob.ID{"pdeadbeef1", "pdeadbeef2", "pdeadbeef3", "qdeadbeef1"} {
+		data[id] = []byte{1, 2, 3}
+	}
+
+	var (
+		mu        sync.Mutex
+		got       []blob.ID
+		completed []blob.ID
+	)
+
+	require.NoError(t, bm.IterateUnreferencedBlobsWithOptions(ctx, IterateUnreferencedBlobsOptions{
+		Parallel:   1,
+		StartAfter: map[blob.ID]blob.ID{"p": "pdeadbeef1"},
+		Completed:  map[blob.ID]bool{"q": true},
+		PrefixCompleted: func(listedPrefix blob.ID) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			completed = append(completed, listedPrefix)
+		},
+	}, func(listedPrefix blob.ID, bm blob.Metadata) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		require.Equal(t, blob.ID("p"), listedPrefix)
+		got = append(got, bm.BlobID)
+
+		return nil
+	}))
+
+	require.Equal(t, []blob.ID{"pdeadbeef2", "pdeadbeef3"}, got)
+	require.Equal(t, []blob.ID{"p"}, completed)
+}
+
 func TestContentWriteAliasing(t *testing.T) {
 	ctx := testlogging.Context(t)
 	data := blobtesting.DataMap{}
@@ -1927,6 +1970,109 @@ func verifyReadsOwnWrites(t *testing.T, st blob.Storage, timeNow func() time.Tim
 	}
 }
 
+func TestContentManagerMemoryBudget(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	bm := newTestContentManager(t, data, nil, nil)
+
+	defer bm.Close(ctx)
+
+	require.NoError(t, gather.SetMemoryBudget(1<<20))
+
+	defer gather.SetMemoryBudget(0) // nolint:errcheck
+
+	const (
+		numWorkers = 8
+		numWrites  = 500
+	)
+
+	var wg sync.WaitGroup
+
+	// total amount of data written exceeds the budget, which forces writers to wait for
+	// packs written by other goroutines.
+	for i := 0; i < numWorkers; i++ {
+		i := i
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < numWrites; j++ {
+				writeContentAndVerify(ctx, t, bm, seededRandomData(i*numWrites+j, 500))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.NoError(t, bm.Flush(ctx))
+	require.Equal(t, int64(0), gather.ReservedMemory())
+}
+
+func TestContentManagerMemoryBudgetWritesPendingPackEarly(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	bm := newTestContentManager(t, data, nil, nil)
+
+	defer bm.Close(ctx)
+
+	require.NoError(t, gather.SetMemoryBudget(1<<20))
+
+	defer gather.SetMemoryBudget(0) // nolint:errcheck
+
+	bm.maxPackSize = 1 << 20
+
+	// contents go to pending packs with different prefixes, neither of which fills up,
+	// so the first one must be written early to make room for the second.
+	id1 := writeContentAndVerify(ctx, t, bm, seededRandomData(1, 600<<10))
+	require.Equal(t, 0, countPackBlobs(data))
+
+	id2, err := bm.WriteContent(ctx, seededRandomData(2, 600<<10), "k")
+	require.NoError(t, err)
+	require.Equal(t, 1, countPackBlobs(data))
+	require.Equal(t, int64(600<<10), gather.ReservedMemory())
+
+	require.NoError(t, bm.Flush(ctx))
+	require.Equal(t, int64(0), gather.ReservedMemory())
+
+	verifyContent(ctx, t, bm, id1, seededRandomData(1, 600<<10))
+	verifyContent(ctx, t, bm, id2, seededRandomData(2, 600<<10))
+}
+
+func countPackBlobs(data blobtesting.DataMap) int {
+	n := 0
+
+	for id := range data {
+		for _, prefix := range PackBlobIDPrefixes {
+			if strings.HasPrefix(string(id), string(prefix)) {
+				n++
+			}
+		}
+	}
+
+	return n
+}
+
+func TestContentManagerMemoryBudgetTooSmall(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	bm := newTestContentManager(t, data, nil, nil)
+
+	defer bm.Close(ctx)
+
+	require.NoError(t, gather.SetMemoryBudget(1<<20))
+
+	defer gather.SetMemoryBudget(0) // nolint:errcheck
+
+	// the budget can't hold a single full pack.
+	bm.maxPackSize = 2 << 20
+
+	_, err := bm.WriteContent(ctx, seededRandomData(1, 100), "")
+	require.Error(t, err)
+	require.Equal(t, int64(0), gather.ReservedMemory())
+}
+
 func verifyContentManagerDataSet(ctx context.Context, t *testing.T, mgr *WriteManager, dataSet map[ID][]byte) {
 	t.Helper()
 
@@ -2169,3 +2315,70 @@ func verifyBlobCount(t *testing.T, data blobtesting.DataMap, want map[blob.ID]in
 		t.Fatalf("unexpected blob count %v, want %v", got, want)
 	}
 }
+
+// concurrencyTrackingStorage records the maximum number of concurrent GetBlob() calls for index blobs.
+type concurrencyTrackingStorage struct {
+	blob.Storage
+
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (s *concurrencyTrackingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	if strings.HasPrefix(string(id), indexBlobPrefix) {
+		s.mu.Lock()
+		s.current++
+		if s.current > s.max {
+			s.max = s.current
+		}
+		s.mu.Unlock()
+
+		defer func() {
+			s.mu.Lock()
+			s.current--
+			s.mu.Unlock()
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// nolint:wrapcheck
+	return s.Storage.GetBlob(ctx, id, offset, length)
+}
+
+func TestIndexFetchParallelism(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	bm := newTestContentManagerWithStorage(t, st, nil)
+
+	for i := 0; i < 30; i++ {
+		writeContentAndVerify(ctx, t, bm, seededRandomData(i, 100))
+		require.NoError(t, bm.Flush(ctx))
+	}
+
+	require.NoError(t, bm.Close(ctx))
+
+	for _, parallelism := range []int{1, 4} {
+		cst := &concurrencyTrackingStorage{Storage: st}
+
+		bm2 := newTestContentManagerWithStorageAndOptions(t, cst, nil, &ManagerOptions{
+			TimeNow:               faketime.AutoAdvance(fakeTime, 1*time.Second),
+			IndexFetchParallelism: parallelism,
+		})
+
+		require.Equal(t, parallelism, cst.max)
+
+		cnt := 0
+
+		require.NoError(t, bm2.IterateContents(ctx, IterateOptions{}, func(Info) error {
+			cnt++
+			return nil
+		}))
+
+		require.Equal(t, 30, cnt)
+		require.NoError(t, bm2.Close(ctx))
+	}
+}
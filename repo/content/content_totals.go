@@ -0,0 +1,98 @@
+package content
+
+import (
+	"context"
+	"sync"
+)
+
+// PrefixTotals holds aggregate statistics of contents with a single prefix.
+type PrefixTotals struct {
+	Count         int64 `json:"count"`
+	OriginalBytes int64 `json:"originalBytes"`
+	PackedBytes   int64 `json:"packedBytes"`
+}
+
+// Totals holds aggregate statistics about contents in the repository.
+type Totals struct {
+	// LogicalBytes is the number of bytes written to the repository, including
+	// contents that were deduplicated.
+	LogicalBytes int64 `json:"logicalBytes"`
+
+	ByPrefix map[ID]*PrefixTotals `json:"byPrefix"`
+}
+
+// Add adds the provided totals.
+func (t *Totals) Add(other *Totals) {
+	t.LogicalBytes += other.LogicalBytes
+
+	for prefix, pt := range other.ByPrefix {
+		t.addInfo(prefix, pt.Count, pt.OriginalBytes, pt.PackedBytes)
+	}
+}
+
+// Sum returns the totals across all prefixes.
+func (t *Totals) Sum() PrefixTotals {
+	var s PrefixTotals
+
+	for _, pt := range t.ByPrefix {
+		s.Count += pt.Count
+		s.OriginalBytes += pt.OriginalBytes
+		s.PackedBytes += pt.PackedBytes
+	}
+
+	return s
+}
+
+// DedupRatio returns the ratio of logical bytes to original bytes of unique contents.
+func (t *Totals) DedupRatio() float64 {
+	s := t.Sum()
+	if s.OriginalBytes == 0 {
+		return 0
+	}
+
+	return float64(t.LogicalBytes) / float64(s.OriginalBytes)
+}
+
+func (t *Totals) addInfo(prefix ID, count, original, packed int64) {
+	if t.ByPrefix == nil {
+		t.ByPrefix = map[ID]*PrefixTotals{}
+	}
+
+	pt := t.ByPrefix[prefix]
+	if pt == nil {
+		pt = &PrefixTotals{}
+		t.ByPrefix[prefix] = pt
+	}
+
+	pt.Count += count
+	pt.OriginalBytes += original
+	pt.PackedBytes += packed
+
+	if *pt == (PrefixTotals{}) {
+		delete(t.ByPrefix, prefix)
+	}
+}
+
+func (t *Totals) add(i Info, sign int64) {
+	t.addInfo(i.GetContentID().Prefix(), sign, sign*int64(i.GetOriginalLength()), sign*int64(i.GetPackedLength()))
+}
+
+// ComputeTotals computes totals of all non-deleted contents by iterating the index.
+// The returned LogicalBytes is always zero since it can't be determined from the index.
+func ComputeTotals(ctx context.Context, r Reader) (*Totals, error) {
+	var (
+		mu     sync.Mutex
+		totals = &Totals{}
+	)
+
+	err := r.IterateContents(ctx, IterateOptions{}, func(ci Info) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		totals.add(ci, 1)
+
+		return nil
+	})
+
+	return totals, err
+}
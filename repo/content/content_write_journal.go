@@ -0,0 +1,483 @@
+package content
+
+import (
+	"bufio"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gofrs/flock"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	// writeJournalDirName is the subdirectory of the cache directory where write journals are stored.
+	writeJournalDirName = "journal"
+
+	writeJournalSuffix     = ".wal"
+	writeJournalLockSuffix = ".lock"
+
+	writeJournalFlagDeleted = 1
+
+	// maxWriteJournalRecordLength guards against allocating huge buffers when reading corrupt journals.
+	maxWriteJournalRecordLength = 1 << 28
+
+	// writeJournalBufferSize is the number of buffered bytes after which records are written to the journal.
+	writeJournalBufferSize = 1 << 20
+)
+
+// writeJournal is a local append-only file recording encrypted contents added to pending packs
+// of a single WriteManager. Journals are rotated after each index flush and removed once all packs
+// they have records of are committed, so that after a crash they contain the contents that were never
+// committed to the repository and can be replayed on the next open instead of being uploaded again.
+//
+// Records are buffered in memory and written outside of the WriteManager lock when the buffer fills up
+// and before each pack is written, at which point the journal is also synced to stable storage.
+//
+// Each record consists of big-endian uint32 length of the body, the body (flags, content ID length,
+// content ID and encrypted payload) and CRC32 of the body. Reading stops at the first incomplete
+// or invalid record, which can be caused by a crash in the middle of a write.
+//
+// The journal is held locked while in use, journals that are not locked have been abandoned.
+type writeJournal struct {
+	path string
+	f    *os.File
+	lock *flock.Flock
+
+	// packs having records in the journal, guarded by the WriteManager lock.
+	packs map[blob.ID]bool
+
+	bufMutex sync.Mutex
+	buf      []byte // records not written to the file yet
+	failed   bool   // set when writing to the file failed
+
+	writeMutex sync.Mutex // serializes writes to the file
+	dirty      bool       // set when records were written since the last sync
+}
+
+func openWriteJournal(dir string) (*writeJournal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "unable to create journal directory")
+	}
+
+	var rnd [16]byte
+
+	if _, err := cryptorand.Read(rnd[:]); err != nil {
+		return nil, errors.Wrap(err, "unable to read crypto bytes")
+	}
+
+	path := filepath.Join(dir, hex.EncodeToString(rnd[:])+writeJournalSuffix)
+
+	l := flock.New(path + writeJournalLockSuffix)
+
+	ok, err := l.TryLock()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to lock journal")
+	}
+
+	if !ok {
+		return nil, errors.Errorf("journal %v is unexpectedly locked", path)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:gosec
+	if err != nil {
+		l.Unlock() //nolint:errcheck
+
+		return nil, errors.Wrap(err, "unable to create journal")
+	}
+
+	return &writeJournal{path: path, f: f, lock: l, packs: map[blob.ID]bool{}}, nil
+}
+
+// append buffers the record and returns the number of buffered bytes.
+func (j *writeJournal) append(contentID ID, deleted bool, payload []byte) int {
+	j.bufMutex.Lock()
+	defer j.bufMutex.Unlock()
+
+	var flags byte
+	if deleted {
+		flags |= writeJournalFlagDeleted
+	}
+
+	bodyLength := 2 + len(contentID) + len(payload) //nolint:gomnd
+	start := len(j.buf)
+
+	var hdr [4]byte
+
+	binary.BigEndian.PutUint32(hdr[:], uint32(bodyLength))
+	j.buf = append(j.buf, hdr[:]...)
+	j.buf = append(j.buf, flags, byte(len(contentID)))
+	j.buf = append(j.buf, contentID...)
+	j.buf = append(j.buf, payload...)
+
+	var sum [4]byte
+
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(j.buf[start+4:])) //nolint:gomnd
+	j.buf = append(j.buf, sum[:]...)
+
+	return len(j.buf)
+}
+
+// hasFailed returns true if writing to the journal has failed.
+func (j *writeJournal) hasFailed() bool {
+	j.bufMutex.Lock()
+	defer j.bufMutex.Unlock()
+
+	return j.failed
+}
+
+// write writes buffered records to the file and optionally syncs it to stable storage.
+func (j *writeJournal) write(sync bool) error {
+	j.writeMutex.Lock()
+	defer j.writeMutex.Unlock()
+
+	j.bufMutex.Lock()
+	buf := j.buf
+	j.buf = nil
+	j.bufMutex.Unlock()
+
+	err := j.writeAndMaybeSync(buf, sync)
+	if err != nil {
+		j.bufMutex.Lock()
+		j.failed = true
+		j.bufMutex.Unlock()
+	}
+
+	return err
+}
+
+func (j *writeJournal) writeAndMaybeSync(buf []byte, sync bool) error {
+	if len(buf) > 0 {
+		if _, err := j.f.Write(buf); err != nil {
+			return errors.Wrap(err, "error writing journal")
+		}
+
+		j.dirty = true
+	}
+
+	if !sync || !j.dirty {
+		return nil
+	}
+
+	if err := j.f.Sync(); err != nil {
+		return errors.Wrap(err, "error syncing journal")
+	}
+
+	j.dirty = false
+
+	return nil
+}
+
+// closeAndRemove closes and removes the journal, discarding its records.
+func (j *writeJournal) closeAndRemove() error {
+	j.writeMutex.Lock()
+	defer j.writeMutex.Unlock()
+
+	j.bufMutex.Lock()
+	j.buf = nil
+	j.bufMutex.Unlock()
+
+	j.dirty = false
+	j.f.Close() //nolint:errcheck,gosec
+
+	return removeWriteJournal(j.path, j.lock)
+}
+
+func removeWriteJournal(path string, l *flock.Flock) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "unable to remove journal")
+	}
+
+	l.Unlock() //nolint:errcheck
+
+	if err := os.Remove(path + writeJournalLockSuffix); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "unable to remove journal lock")
+	}
+
+	return nil
+}
+
+// readWriteJournal invokes the callback for each valid record in the provided journal file.
+func readWriteJournal(path string, cb func(contentID ID, deleted bool, payload []byte) error) error {
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "unable to open journal")
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	r := bufio.NewReader(f)
+
+	for {
+		var hdr [4]byte
+
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			// end of journal or incomplete header
+			return nil
+		}
+
+		n := binary.BigEndian.Uint32(hdr[:])
+		if n < 2 || n > maxWriteJournalRecordLength { //nolint:gomnd
+			return nil
+		}
+
+		rec := make([]byte, n+4) //nolint:gomnd
+		if _, err := io.ReadFull(r, rec); err != nil {
+			return nil
+		}
+
+		body := rec[0:n]
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(rec[n:]) {
+			return nil
+		}
+
+		flags, idLen := body[0], int(body[1])
+		if 2+idLen > len(body) { //nolint:gomnd
+			return nil
+		}
+
+		if err := cb(ID(body[2:2+idLen]), flags&writeJournalFlagDeleted != 0, body[2+idLen:]); err != nil {
+			return err
+		}
+	}
+}
+
+// journalContentLocked records the content that was just added to the pending pack in the write journal
+// and returns the journal if its buffered records should be written.
+// Journal failures are not fatal, they only disable the journal for the remainder of the session.
+func (bm *WriteManager) journalContentLocked(ctx context.Context, pp *pendingPackInfo, info *InfoStruct) *writeJournal {
+	if bm.writeJournalDir == "" || bm.journalDisabled {
+		return nil
+	}
+
+	if bm.journal != nil && bm.journal.hasFailed() {
+		log(ctx).Errorf("error writing journal, disabling")
+
+		bm.journalDisabled = true
+		bm.closeJournalsLocked(ctx)
+
+		return nil
+	}
+
+	if bm.journal == nil {
+		j, err := openWriteJournal(bm.writeJournalDir)
+		if err != nil {
+			log(ctx).Errorf("unable to open write journal: %v", err)
+
+			bm.journalDisabled = true
+
+			return nil
+		}
+
+		bm.journal = j
+	}
+
+	payload := pp.currentPackData.AppendSectionTo(nil, int(info.PackOffset), int(info.PackedLength))
+
+	bm.journal.packs[pp.packBlobID] = true
+	pp.journal = bm.journal
+
+	if bm.journal.append(info.ContentID, info.Deleted, payload) < writeJournalBufferSize {
+		return nil
+	}
+
+	return bm.journal
+}
+
+// writeJournalUnlocked writes buffered journal records without holding the lock.
+func writeJournalUnlocked(ctx context.Context, j *writeJournal, sync bool) {
+	if err := j.write(sync); err != nil {
+		log(ctx).Errorf("error writing journal: %v", err)
+	}
+}
+
+// rotateJournalsLocked is called after the index has been flushed, it starts a new journal for subsequent
+// contents and removes journals whose packs have all been committed.
+func (bm *WriteManager) rotateJournalsLocked(ctx context.Context) {
+	if bm.journal != nil {
+		bm.rotatedJournals = append(bm.rotatedJournals, bm.journal)
+		bm.journal = nil
+	}
+
+	uncommitted := map[blob.ID]bool{}
+
+	for _, pp := range bm.pendingPacks {
+		uncommitted[pp.packBlobID] = true
+	}
+
+	for _, pp := range bm.writingPacks {
+		uncommitted[pp.packBlobID] = true
+	}
+
+	for _, pp := range bm.failedPacks {
+		uncommitted[pp.packBlobID] = true
+	}
+
+	var remaining []*writeJournal
+
+	for _, j := range bm.rotatedJournals {
+		if !hasAnyPack(j.packs, uncommitted) {
+			if err := j.closeAndRemove(); err != nil {
+				log(ctx).Errorf("error removing write journal: %v", err)
+			}
+
+			continue
+		}
+
+		// records of packs that are still pending are not synced by subsequent journals.
+		if err := j.write(true); err != nil {
+			log(ctx).Errorf("error writing journal: %v", err)
+		}
+
+		remaining = append(remaining, j)
+	}
+
+	bm.rotatedJournals = remaining
+}
+
+func hasAnyPack(packs, uncommitted map[blob.ID]bool) bool {
+	for p := range packs {
+		if uncommitted[p] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (bm *WriteManager) closeJournalsLocked(ctx context.Context) {
+	if bm.journal != nil {
+		bm.rotatedJournals = append(bm.rotatedJournals, bm.journal)
+		bm.journal = nil
+	}
+
+	for _, j := range bm.rotatedJournals {
+		if err := j.closeAndRemove(); err != nil {
+			log(ctx).Errorf("error removing write journal: %v", err)
+		}
+	}
+
+	bm.rotatedJournals = nil
+}
+
+// ReplayWriteJournals adds contents recorded in write journals that were abandoned by sessions that
+// did not finish cleanly (for example because of a crash) and flushes them, so that they don't need to be
+// uploaded again. Journals of sessions that are still active are skipped. Returns the number of replayed contents.
+func (bm *WriteManager) ReplayWriteJournals(ctx context.Context) (int, error) {
+	if bm.writeJournalDir == "" {
+		return 0, nil
+	}
+
+	entries, err := ioutil.ReadDir(bm.writeJournalDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to list journals")
+	}
+
+	var (
+		replayed         int
+		replayedJournals []*flock.Flock
+	)
+
+	defer func() {
+		for _, l := range replayedJournals {
+			l.Unlock() //nolint:errcheck
+		}
+	}()
+
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), writeJournalSuffix) {
+			continue
+		}
+
+		path := filepath.Join(bm.writeJournalDir, e.Name())
+
+		l := flock.New(path + writeJournalLockSuffix)
+
+		ok, err := l.TryLock()
+		if err != nil {
+			return replayed, errors.Wrap(err, "unable to lock journal")
+		}
+
+		if !ok {
+			log(ctx).Debugf("journal %v is in use", path)
+			continue
+		}
+
+		replayedJournals = append(replayedJournals, l)
+
+		n, err := bm.replayWriteJournal(ctx, path)
+		replayed += n
+
+		if err != nil {
+			return replayed, errors.Wrapf(err, "error replaying journal %v", path)
+		}
+	}
+
+	if len(replayedJournals) == 0 {
+		return 0, nil
+	}
+
+	if err := bm.Flush(ctx); err != nil {
+		return replayed, errors.Wrap(err, "error flushing replayed contents")
+	}
+
+	for _, l := range replayedJournals {
+		if err := removeWriteJournal(strings.TrimSuffix(l.Path(), writeJournalLockSuffix), l); err != nil {
+			return replayed, err
+		}
+	}
+
+	if replayed > 0 {
+		log(ctx).Infof("Recovered %v contents from write journals of unfinished sessions.", replayed)
+	}
+
+	return replayed, nil
+}
+
+func (bm *WriteManager) replayWriteJournal(ctx context.Context, path string) (int, error) {
+	replayed := 0
+
+	err := readWriteJournal(path, func(contentID ID, deleted bool, payload []byte) error {
+		data, err := bm.decryptContentAndVerify(payload, &InfoStruct{ContentID: contentID})
+		if err != nil {
+			log(ctx).Debugf("skipping invalid journal entry %v: %v", contentID, err)
+			return nil
+		}
+
+		var hashOutput [maxHashSize]byte
+
+		if want := hex.EncodeToString(bm.hashData(hashOutput[:0], data)); !strings.HasSuffix(string(contentID), want) {
+			log(ctx).Debugf("skipping journal entry %v with mismatched hash", contentID)
+			return nil
+		}
+
+		if _, bi, err := bm.getContentInfo(contentID); err == nil && bi.GetDeleted() == deleted {
+			// already committed or replayed from another journal.
+			return nil
+		}
+
+		if err := bm.addToPackUnlocked(ctx, contentID, data, deleted); err != nil {
+			return err
+		}
+
+		replayed++
+
+		return nil
+	})
+
+	return replayed, err
+}
@@ -0,0 +1,183 @@
+package content
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestWriteJournalReplay(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	co := &CachingOptions{CacheDirectory: t.TempDir(), WriteJournal: true}
+
+	bm := newTestContentManagerWithStorageAndCaching(t, st, co, nil)
+
+	committedID := writeContentAndVerify(ctx, t, bm, seededRandomData(1, 100))
+	require.NoError(t, bm.Flush(ctx))
+
+	// journal is removed after flush.
+	require.Nil(t, bm.journal)
+	require.Empty(t, bm.rotatedJournals)
+
+	dataSet := map[ID][]byte{}
+
+	for i := 0; i < 10; i++ {
+		b := seededRandomData(i+100, 1000)
+		dataSet[writeContentAndVerify(ctx, t, bm, b)] = b
+	}
+
+	// every other content completes a pack, which makes the records durable.
+	crashedJournal := bm.journal.path
+	require.Empty(t, bm.pendingPacks)
+
+	// simulate crash before flush - release journal without removing it.
+	bm.journal.f.Close()
+	require.NoError(t, bm.journal.lock.Unlock())
+	bm.journal = nil
+
+	bm2 := newTestContentManagerWithStorageAndCaching(t, st, co, nil)
+
+	for cid := range dataSet {
+		verifyContentNotFound(ctx, t, bm2, cid)
+	}
+
+	n, err := bm2.ReplayWriteJournals(ctx)
+	require.NoError(t, err)
+	require.Equal(t, len(dataSet), n)
+
+	verifyContentManagerDataSet(ctx, t, bm2, dataSet)
+
+	// replayed contents are visible to new managers.
+	bm3 := newTestContentManagerWithStorageAndCaching(t, st, co, nil)
+	verifyContentManagerDataSet(ctx, t, bm3, dataSet)
+	verifyContent(ctx, t, bm3, committedID, seededRandomData(1, 100))
+
+	// abandoned journal has been removed.
+	_, err = os.Stat(crashedJournal)
+	require.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(crashedJournal + writeJournalLockSuffix)
+	require.True(t, os.IsNotExist(err))
+
+	n, err = bm3.ReplayWriteJournals(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestWriteJournalRotatedAfterIndexFlush(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	co := &CachingOptions{CacheDirectory: t.TempDir(), WriteJournal: true}
+
+	bm := newTestContentManagerWithStorageAndCaching(t, st, co, nil)
+
+	// two contents complete a pack, the third one remains pending.
+	writeContentAndVerify(ctx, t, bm, seededRandomData(1, 1000))
+	writeContentAndVerify(ctx, t, bm, seededRandomData(2, 1000))
+	pendingID := writeContentAndVerify(ctx, t, bm, seededRandomData(3, 100))
+	require.Len(t, bm.pendingPacks, 1)
+
+	first := bm.journal
+
+	// records of the written pack were synced before writing it, the pending content is only buffered.
+	fi, err := os.Stat(first.path)
+	require.NoError(t, err)
+
+	syncedSize := fi.Size()
+	require.NotZero(t, syncedSize)
+
+	// flush the index of the written pack without writing the pending one, as automatic flushes may do.
+	bm.lock()
+	require.NoError(t, bm.flushPackIndexesLocked(ctx))
+	bm.unlock()
+
+	// the journal has been rotated and retained with the record of the pending content synced.
+	require.Nil(t, bm.journal)
+	require.Equal(t, []*writeJournal{first}, bm.rotatedJournals)
+
+	fi, err = os.Stat(first.path)
+	require.NoError(t, err)
+	require.Greater(t, fi.Size(), syncedSize)
+
+	var ids []ID
+
+	require.NoError(t, readWriteJournal(first.path, func(contentID ID, isDeleted bool, payload []byte) error {
+		ids = append(ids, contentID)
+		return nil
+	}))
+	require.Contains(t, ids, pendingID)
+
+	// subsequent contents go to a new journal.
+	writeContentAndVerify(ctx, t, bm, seededRandomData(4, 100))
+	require.NotNil(t, bm.journal)
+	require.NotEqual(t, first, bm.journal)
+
+	second := bm.journal
+
+	// all journals are removed once their packs are committed.
+	require.NoError(t, bm.Flush(ctx))
+	require.Nil(t, bm.journal)
+	require.Empty(t, bm.rotatedJournals)
+
+	for _, p := range []string{first.path, second.path} {
+		_, err = os.Stat(p)
+		require.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestWriteJournalSkipsActiveJournals(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	co := &CachingOptions{CacheDirectory: t.TempDir(), WriteJournal: true}
+
+	bm := newTestContentManagerWithStorageAndCaching(t, st, co, nil)
+	cid := writeContentAndVerify(ctx, t, bm, seededRandomData(1, 100))
+
+	bm2 := newTestContentManagerWithStorageAndCaching(t, st, co, nil)
+
+	n, err := bm2.ReplayWriteJournals(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	verifyContentNotFound(ctx, t, bm2, cid)
+}
+
+func TestReadWriteJournalTruncated(t *testing.T) {
+	dir := t.TempDir()
+
+	j, err := openWriteJournal(dir)
+	require.NoError(t, err)
+
+	defer j.closeAndRemove() //nolint:errcheck
+
+	j.append("abcd", false, []byte{1, 2, 3})
+	j.append("k1234", true, []byte{4, 5})
+	j.append("efgh", false, []byte{6, 7, 8, 9})
+	require.NoError(t, j.write(true))
+
+	fi, err := j.f.Stat()
+	require.NoError(t, err)
+
+	// simulate crash in the middle of writing the last record.
+	require.NoError(t, j.f.Truncate(fi.Size()-3))
+
+	var (
+		ids     []ID
+		deleted []bool
+	)
+
+	require.NoError(t, readWriteJournal(j.path, func(contentID ID, isDeleted bool, payload []byte) error {
+		ids = append(ids, contentID)
+		deleted = append(deleted, isDeleted)
+
+		return nil
+	}))
+
+	require.Equal(t, []ID{"abcd", "k1234"}, ids)
+	require.Equal(t, []bool{false, true}, deleted)
+}
@@ -0,0 +1,49 @@
+package content
+
+// Minimum repository format versions that must be understood by clients writing to repositories
+// with the corresponding format feature enabled.
+const (
+	MinFormatVersionStreamedDirectories = 4
+	MinFormatVersionCompressedManifests = 6
+)
+
+// FormatFeatures describes optional repository format features, each of which is enabled independently
+// of the others, so that enabling one feature does not force the repository to use all features
+// introduced in earlier format versions.
+type FormatFeatures struct {
+	StreamedDirectories bool `json:"streamedDirectories,omitempty"` // write directory manifests in the streamed format
+	CompressedManifests bool `json:"compressedManifests,omitempty"` // compress manifest contents with zstd instead of gzip
+}
+
+// FormatFeaturesForVersion returns the features implied by the provided format version, which is
+// how repositories created before features were tracked separately behave.
+func FormatFeaturesForVersion(formatVersion int) FormatFeatures {
+	return FormatFeatures{
+		StreamedDirectories: formatVersion >= MinFormatVersionStreamedDirectories,
+		CompressedManifests: formatVersion >= MinFormatVersionCompressedManifests,
+	}
+}
+
+// MinFormatVersion returns the minimum format version required by the enabled features.
+func (ff FormatFeatures) MinFormatVersion() int {
+	v := 0
+
+	if ff.StreamedDirectories && v < MinFormatVersionStreamedDirectories {
+		v = MinFormatVersionStreamedDirectories
+	}
+
+	if ff.CompressedManifests && v < MinFormatVersionCompressedManifests {
+		v = MinFormatVersionCompressedManifests
+	}
+
+	return v
+}
+
+// EnabledFeatures returns the format features enabled in the repository.
+func (f *FormattingOptions) EnabledFeatures() FormatFeatures {
+	if f.Features != nil {
+		return *f.Features
+	}
+
+	return FormatFeaturesForVersion(f.Version)
+}
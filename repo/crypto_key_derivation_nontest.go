@@ -10,6 +10,11 @@
 // defaultKeyDerivationAlgorithm is the key derivation algorithm for new configurations.
 const defaultKeyDerivationAlgorithm = "scrypt-65536-8-1"
 
+// SupportedKeyDerivationAlgorithms returns the names of supported password-based key derivation algorithms.
+func SupportedKeyDerivationAlgorithms() []string {
+	return []string{"scrypt-65536-8-1", PBKDF2KeyDerivationAlgorithm}
+}
+
 func (f *formatBlob) deriveMasterKeyFromPassword(password string) ([]byte, error) {
 	const masterKeySize = 32
 
@@ -18,6 +23,9 @@ func (f *formatBlob) deriveMasterKeyFromPassword(password string) ([]byte, error
 		// nolint:wrapcheck
 		return scrypt.Key([]byte(password), f.UniqueID, 65536, 8, 1, masterKeySize)
 
+	case PBKDF2KeyDerivationAlgorithm:
+		return derivePBKDF2Key(password, f.UniqueID, masterKeySize), nil
+
 	default:
 		return nil, errors.Errorf("unsupported key algorithm: %v", f.KeyDerivationAlgorithm)
 	}
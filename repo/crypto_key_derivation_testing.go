@@ -11,6 +11,11 @@
 // defaultKeyDerivationAlgorithm is the key derivation algorithm for new configurations.
 const defaultKeyDerivationAlgorithm = "testing-only-insecure"
 
+// SupportedKeyDerivationAlgorithms returns the names of supported password-based key derivation algorithms.
+func SupportedKeyDerivationAlgorithms() []string {
+	return []string{defaultKeyDerivationAlgorithm, PBKDF2KeyDerivationAlgorithm}
+}
+
 func (f *formatBlob) deriveMasterKeyFromPassword(password string) ([]byte, error) {
 	const masterKeySize = 32
 
@@ -23,6 +28,9 @@ func (f *formatBlob) deriveMasterKeyFromPassword(password string) ([]byte, error
 
 		return h.Sum(nil), nil
 
+	case PBKDF2KeyDerivationAlgorithm:
+		return derivePBKDF2Key(password, f.UniqueID, masterKeySize), nil
+
 	default:
 		return nil, errors.Errorf("unsupported key algorithm: %v", f.KeyDerivationAlgorithm)
 	}
@@ -73,17 +73,32 @@ func SupportedAlgorithms(includeDeprecated bool) []string {
 
 // Register registers new encryption algorithm.
 func Register(name, description string, deprecated bool, newEncryptor EncryptorFactory) {
+	register(name, description, deprecated, 1, newEncryptor)
+}
+
+func register(name, description string, deprecated bool, minFormatVersion int, newEncryptor EncryptorFactory) {
 	encryptors[name] = &encryptorInfo{
 		description,
 		deprecated,
+		minFormatVersion,
 		newEncryptor,
 	}
 }
 
+// MinFormatVersion returns the minimum repository format version required to use a given encryption algorithm.
+func MinFormatVersion(name string) int {
+	if e := encryptors[name]; e != nil {
+		return e.minFormatVersion
+	}
+
+	return 1
+}
+
 type encryptorInfo struct {
-	description  string
-	deprecated   bool
-	newEncryptor EncryptorFactory
+	description      string
+	deprecated       bool
+	minFormatVersion int
+	newEncryptor     EncryptorFactory
 }
 
 var encryptors = map[string]*encryptorInfo{}
@@ -120,8 +120,9 @@ func TestCiphertextSamples(t *testing.T) {
 
 			// samples of base16-encoded ciphertexts of payload encrypted with masterKey & contentID
 			samples: map[string]string{
-				"AES256-GCM-HMAC-SHA256":        "e43ba07f85a6d70c5f1102ca06cf19c597e5f91e527b21f00fb76e8bec3fd1",
-				"CHACHA20-POLY1305-HMAC-SHA256": "118359f3d4d589d939efbbc3168ae4c77c51bcebce6845fe6ef5d11342faa6",
+				"AES256-GCM-HMAC-SHA256":         "e43ba07f85a6d70c5f1102ca06cf19c597e5f91e527b21f00fb76e8bec3fd1",
+				"CHACHA20-POLY1305-HMAC-SHA256":  "118359f3d4d589d939efbbc3168ae4c77c51bcebce6845fe6ef5d11342faa6",
+				"XCHACHA20-POLY1305-HMAC-SHA256": "592562be3c72d4abce6b48f5253b3cfa4d0395962448e5aa7609e2ab1fc1d7b934392919a5d184dd389910",
 			},
 		},
 		{
@@ -131,8 +132,9 @@ func TestCiphertextSamples(t *testing.T) {
 
 			// samples of base16-encoded ciphertexts of payload encrypted with masterKey & contentID
 			samples: map[string]string{
-				"AES256-GCM-HMAC-SHA256":        "eaad755a238f1daa4052db2e5ccddd934790b6cca415b3ccfd46ac5746af33d9d30f4400ffa9eb3a64fb1ce21b888c12c043bf6787d4a5c15ad10f21f6a6027ee3afe0",
-				"CHACHA20-POLY1305-HMAC-SHA256": "836d2ba87892711077adbdbe1452d3b2c590bbfdf6fd3387dc6810220a32ec19de862e1a4f865575e328424b5f178afac1b7eeff11494f719d119b7ebb924d1d0846a3",
+				"AES256-GCM-HMAC-SHA256":         "eaad755a238f1daa4052db2e5ccddd934790b6cca415b3ccfd46ac5746af33d9d30f4400ffa9eb3a64fb1ce21b888c12c043bf6787d4a5c15ad10f21f6a6027ee3afe0",
+				"CHACHA20-POLY1305-HMAC-SHA256":  "836d2ba87892711077adbdbe1452d3b2c590bbfdf6fd3387dc6810220a32ec19de862e1a4f865575e328424b5f178afac1b7eeff11494f719d119b7ebb924d1d0846a3",
+				"XCHACHA20-POLY1305-HMAC-SHA256": "1dc6042dad64bf586df06b140003d6919b952503de22243f9f36c8cd7e28e60d05b5f9206fd94ffc52f63fd93abff1f2aff53ef30323b6cc58edb755e2c4206ab676f013d97f74cb12f0729649c1de",
 			},
 		},
 	}
@@ -178,3 +180,13 @@ func verifyCiphertextSamples(t *testing.T, masterKey, contentID, payload []byte,
 		}
 	}
 }
+
+func TestMinFormatVersion(t *testing.T) {
+	if got, want := encryption.MinFormatVersion(encryption.DefaultAlgorithm), 1; got != want {
+		t.Errorf("invalid min format version for default algorithm: %v, want %v", got, want)
+	}
+
+	if got, want := encryption.MinFormatVersion("XCHACHA20-POLY1305-HMAC-SHA256"), 2; got != want {
+		t.Errorf("invalid min format version for XChaCha20: %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+// PBKDF2KeyDerivationAlgorithm is the FIPS-approved password-based key derivation algorithm.
+const PBKDF2KeyDerivationAlgorithm = "pbkdf2-sha256-600000"
+
+const pbkdf2Iterations = 600000
+
+// DefaultFIPSHashAlgorithm is the default hash algorithm of repositories created in FIPS mode.
+const DefaultFIPSHashAlgorithm = "HMAC-SHA256-128"
+
+// FIPS-approved algorithms that may be used by repositories in FIPS mode.
+var (
+	fipsKeyDerivationAlgorithms = map[string]bool{
+		PBKDF2KeyDerivationAlgorithm: true,
+	}
+
+	fipsFormatEncryptionAlgorithms = map[string]bool{
+		"AES256_GCM": true,
+	}
+
+	fipsEncryptionAlgorithms = map[string]bool{
+		"AES256-GCM-HMAC-SHA256": true,
+	}
+
+	fipsHashAlgorithms = map[string]bool{
+		"HMAC-SHA256":     true,
+		"HMAC-SHA256-128": true,
+		"HMAC-SHA224":     true,
+	}
+)
+
+// FIPSStatus describes compliance of the repository with FIPS-approved algorithms.
+type FIPSStatus struct {
+	// Enabled is true when FIPS mode is required by the repository or by the build.
+	Enabled bool `json:"enabled"`
+
+	// Violations lists repository parameters that use algorithms which are not FIPS-approved.
+	Violations []string `json:"violations,omitempty"`
+}
+
+// Compliant returns true if all repository parameters use FIPS-approved algorithms.
+func (s FIPSStatus) Compliant() bool {
+	return len(s.Violations) == 0
+}
+
+// FIPSBuild returns true if the binary was built with FIPS mode enforced for all repositories.
+func FIPSBuild() bool {
+	return fipsBuild
+}
+
+// FIPSStatus returns the FIPS compliance status of the repository.
+func (r *directRepository) FIPSStatus() FIPSStatus {
+	return r.fipsStatus
+}
+
+func fipsViolations(keyDerivationAlgorithm, formatEncryption string, f *content.FormattingOptions) []string {
+	var result []string
+
+	if !fipsKeyDerivationAlgorithms[keyDerivationAlgorithm] {
+		result = append(result, "key derivation: "+keyDerivationAlgorithm)
+	}
+
+	if !fipsFormatEncryptionAlgorithms[formatEncryption] {
+		result = append(result, "format encryption: "+formatEncryption)
+	}
+
+	if !fipsEncryptionAlgorithms[f.Encryption] {
+		result = append(result, "encryption: "+f.Encryption)
+	}
+
+	if !fipsHashAlgorithms[f.Hash] {
+		result = append(result, "hash: "+f.Hash)
+	}
+
+	return result
+}
+
+// validateFIPS returns an error if FIPS mode is enabled and the provided status has violations.
+func validateFIPS(s FIPSStatus) error {
+	if !s.Enabled || s.Compliant() {
+		return nil
+	}
+
+	return errors.Errorf("repository parameters are not FIPS-approved: %v", strings.Join(s.Violations, ", "))
+}
+
+func derivePBKDF2Key(password string, salt []byte, keySize int) []byte {
+	return pbkdf2.Key([]byte(password), salt, pbkdf2Iterations, keySize, sha256.New)
+}
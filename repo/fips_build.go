@@ -0,0 +1,6 @@
+// +build fips
+
+package repo
+
+// fipsBuild indicates that FIPS mode is enforced for all repositories, regardless of their configuration.
+const fipsBuild = true
@@ -0,0 +1,47 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestFIPSMode(t *testing.T) {
+	_, env := repotesting.NewEnvironment(t, repotesting.Options{
+		NewRepositoryOptions: func(o *repo.NewRepositoryOptions) {
+			o.FIPS = true
+		},
+	})
+
+	st := env.RepositoryWriter.FIPSStatus()
+	require.True(t, st.Enabled)
+	require.True(t, st.Compliant(), "violations: %v", st.Violations)
+
+	env.MustReopen(t)
+	require.True(t, env.RepositoryWriter.FIPSStatus().Enabled)
+}
+
+func TestFIPSModeRejectsUnapprovedAlgorithms(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+
+	require.Error(t, repo.Initialize(ctx, st, &repo.NewRepositoryOptions{
+		BlockFormat: content.FormattingOptions{Hash: "BLAKE2B-256-128"},
+		FIPS:        true,
+	}, "password"))
+
+	require.Error(t, repo.Initialize(ctx, st, &repo.NewRepositoryOptions{
+		BlockFormat: content.FormattingOptions{Encryption: "CHACHA20-POLY1305-HMAC-SHA256"},
+		FIPS:        true,
+	}, "password"))
+
+	// defaults are adjusted to FIPS-approved algorithms.
+	require.NoError(t, repo.Initialize(ctx, st, &repo.NewRepositoryOptions{FIPS: true}, "password"))
+}
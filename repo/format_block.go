@@ -9,6 +9,8 @@
 	"crypto/sha256"
 	"encoding/json"
 	"io"
+	"os"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 
@@ -172,6 +174,28 @@ func writeFormatBlob(ctx context.Context, st blob.Storage, f *formatBlob) error
 	return nil
 }
 
+// writeRepositoryConfig encrypts the provided repository config with the given key, writes the format blob
+// and invalidates its locally cached copy.
+func (r *directRepository) writeRepositoryConfig(ctx context.Context, repoConfig *repositoryObjectFormat, masterKey []byte) error {
+	f := r.formatBlob
+
+	if err := encryptFormatBytes(f, repoConfig, masterKey, f.UniqueID); err != nil {
+		return errors.Errorf("unable to encrypt format bytes")
+	}
+
+	if err := writeFormatBlob(ctx, r.blobs, f); err != nil {
+		return err
+	}
+
+	if cd := r.cachingOptions.CacheDirectory; cd != "" {
+		if err := os.Remove(filepath.Join(cd, FormatBlobID)); err != nil && !os.IsNotExist(err) {
+			log(ctx).Errorf("unable to remove cached format blob: %v", err)
+		}
+	}
+
+	return nil
+}
+
 func (f *formatBlob) decryptFormatBytes(masterKey []byte) (*repositoryObjectFormat, error) {
 	switch f.EncryptionAlgorithm {
 	case "NONE": // do nothing
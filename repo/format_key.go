@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Format key age enforcement actions.
+const (
+	FormatKeyActionWarn  = "warn"
+	FormatKeyActionBlock = "block"
+)
+
+const formatKeyIDLength = 8
+
+var formatKeyIDPurpose = []byte("format key id")
+
+// ErrFormatKeyExpired is returned when attempting to write to a repository whose format key
+// is older than allowed by the format key policy with FormatKeyActionBlock.
+var ErrFormatKeyExpired = errors.New("repository format key has expired, change repository password to rotate it")
+
+// FormatKeyInfo describes a single format encryption key, which is derived from the repository password.
+type FormatKeyInfo struct {
+	KeyID     string    `json:"keyID"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+}
+
+// FormatKeyPolicy specifies the maximum age of the format key and the action taken when it's exceeded.
+type FormatKeyPolicy struct {
+	MaxAge time.Duration `json:"maxAge,omitempty"`
+	Action string        `json:"action,omitempty"`
+}
+
+// FormatKeyStatus describes the format key history and policy of the repository.
+type FormatKeyStatus struct {
+	// History of format keys, oldest first. Empty for repositories created before
+	// the history was recorded, until the password is changed.
+	History []FormatKeyInfo `json:"history,omitempty"`
+	Policy  FormatKeyPolicy `json:"policy"`
+}
+
+// Current returns information about the current format key or nil if not known.
+func (s FormatKeyStatus) Current() *FormatKeyInfo {
+	if len(s.History) == 0 {
+		return nil
+	}
+
+	return &s.History[len(s.History)-1]
+}
+
+// Expired returns true if the current format key is older than allowed by the policy at the provided time.
+func (s FormatKeyStatus) Expired(now time.Time) bool {
+	cur := s.Current()
+	if cur == nil || s.Policy.MaxAge <= 0 {
+		return false
+	}
+
+	return now.Sub(cur.CreatedAt) > s.Policy.MaxAge
+}
+
+// ValidateFormatKeyPolicy ensures that the provided format key policy is valid.
+func ValidateFormatKeyPolicy(p FormatKeyPolicy) error {
+	if p.MaxAge < 0 {
+		return errors.Errorf("max format key age must not be negative")
+	}
+
+	switch p.Action {
+	case "", FormatKeyActionWarn, FormatKeyActionBlock:
+		return nil
+	default:
+		return errors.Errorf("invalid format key action %q", p.Action)
+	}
+}
+
+// formatKeyID returns an identifier of the format key that does not reveal the key itself.
+func formatKeyID(masterKey, uniqueID []byte) string {
+	return hex.EncodeToString(deriveKeyFromMasterKey(masterKey, uniqueID, formatKeyIDPurpose, formatKeyIDLength))
+}
+
+// FormatKeyStatus returns the format key history and policy of the repository.
+func (r *directRepository) FormatKeyStatus() FormatKeyStatus {
+	return FormatKeyStatus{
+		History: append([]FormatKeyInfo(nil), r.formatKeyStatus.History...),
+		Policy:  r.formatKeyStatus.Policy,
+	}
+}
+
+// SetFormatKeyPolicy sets the policy enforcing the maximum age of the format key.
+func (r *directRepository) SetFormatKeyPolicy(ctx context.Context, p FormatKeyPolicy) error {
+	if err := ValidateFormatKeyPolicy(p); err != nil {
+		return err
+	}
+
+	repoConfig, err := r.formatBlob.decryptFormatBytes(r.masterKey)
+	if err != nil {
+		return errors.Wrap(err, "unable to decrypt repository config")
+	}
+
+	repoConfig.FormatKeyPolicy = &p
+
+	if err := r.writeRepositoryConfig(ctx, repoConfig, r.masterKey); err != nil {
+		return err
+	}
+
+	r.formatKeyStatus.Policy = p
+
+	return nil
+}
+
+// ChangePassword changes the repository password, which rotates the format encryption key,
+// and records the rotation in the format key history. Contents are not re-encrypted.
+// Changing the password is allowed even if writes are blocked due to an expired format key.
+func (r *directRepository) ChangePassword(ctx context.Context, newPassword string) error {
+	f := r.formatBlob
+
+	repoConfig, err := f.decryptFormatBytes(r.masterKey)
+	if err != nil {
+		return errors.Wrap(err, "unable to decrypt repository config")
+	}
+
+	newMasterKey, err := f.deriveMasterKeyFromPassword(newPassword)
+	if err != nil {
+		return errors.Wrap(err, "unable to derive master key")
+	}
+
+	if formatKeyID(newMasterKey, f.UniqueID) == formatKeyID(r.masterKey, f.UniqueID) {
+		return errors.Errorf("new password must be different from the current one")
+	}
+
+	// keep deriving keys of auxiliary blobs (such as maintenance schedule) from the original key.
+	if repoConfig.KeyDerivationSecret == nil {
+		repoConfig.KeyDerivationSecret = r.derivationKey
+	}
+
+	repoConfig.FormatKeyHistory = append(repoConfig.FormatKeyHistory, FormatKeyInfo{
+		KeyID:     formatKeyID(newMasterKey, f.UniqueID),
+		CreatedAt: r.Time(),
+		CreatedBy: r.ClientOptions().UsernameAtHost(),
+	})
+
+	if err := r.writeRepositoryConfig(ctx, repoConfig, newMasterKey); err != nil {
+		return err
+	}
+
+	r.masterKey = newMasterKey
+	r.formatKeyStatus.History = repoConfig.FormatKeyHistory
+
+	return nil
+}
+
+// checkFormatKeyAge returns an error if writes are blocked because the format key is too old.
+// With FormatKeyActionWarn the warning is only logged when opening the repository.
+func (r *directRepository) checkFormatKeyAge() error {
+	if r.formatKeyStatus.Policy.Action == FormatKeyActionBlock && r.formatKeyStatus.Expired(r.Time()) {
+		return ErrFormatKeyExpired
+	}
+
+	return nil
+}
+
+func (r *directRepository) warnFormatKeyExpired(ctx context.Context) {
+	log(ctx).Errorf("WARNING: repository format key is older than %v, change repository password to rotate it.", r.formatKeyStatus.Policy.MaxAge)
+}
@@ -0,0 +1,91 @@
+package repo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+func TestChangePassword(t *testing.T) {
+	ta := faketime.NewTimeAdvance(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), 0)
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.TimeNowFunc = ta.NowFunc()
+		},
+	})
+
+	ks := env.RepositoryWriter.FormatKeyStatus()
+	require.Len(t, ks.History, 1)
+
+	oldKeyID := ks.Current().KeyID
+
+	require.NoError(t, maintenance.SetSchedule(ctx, env.RepositoryWriter, &maintenance.Schedule{NextFullMaintenanceTime: ta.NowFunc()()}))
+
+	require.Error(t, env.RepositoryWriter.ChangePassword(ctx, "foobarbazfoobarbaz"), "same password")
+
+	ta.Advance(time.Hour)
+	require.NoError(t, env.RepositoryWriter.ChangePassword(ctx, "new-password"))
+
+	ks = env.RepositoryWriter.FormatKeyStatus()
+	require.Len(t, ks.History, 2)
+	require.NotEqual(t, oldKeyID, ks.Current().KeyID)
+	require.Equal(t, env.RepositoryWriter.ClientOptions().UsernameAtHost(), ks.Current().CreatedBy)
+	require.Equal(t, ta.NowFunc()(), ks.Current().CreatedAt)
+
+	_, err := repo.Open(ctx, env.ConfigFile(), "foobarbazfoobarbaz", &repo.Options{})
+	require.ErrorIs(t, err, repo.ErrInvalidPassword)
+
+	rep, err := repo.Open(ctx, env.ConfigFile(), "new-password", &repo.Options{})
+	require.NoError(t, err)
+
+	defer rep.Close(ctx)
+
+	dr := rep.(repo.DirectRepository)
+	require.Equal(t, ks.History, dr.FormatKeyStatus().History)
+
+	// keys derived for auxiliary data are not affected by the password change.
+	_, err = maintenance.GetSchedule(ctx, dr)
+	require.NoError(t, err)
+
+	s, err := repo.GetStats(ctx, dr)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+}
+
+func TestFormatKeyPolicy(t *testing.T) {
+	ta := faketime.NewTimeAdvance(time.Now(), 0)
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.TimeNowFunc = ta.NowFunc()
+		},
+	})
+
+	require.Error(t, env.RepositoryWriter.SetFormatKeyPolicy(ctx, repo.FormatKeyPolicy{Action: "invalid"}))
+	require.NoError(t, env.RepositoryWriter.SetFormatKeyPolicy(ctx, repo.FormatKeyPolicy{
+		MaxAge: 24 * time.Hour,
+		Action: repo.FormatKeyActionBlock,
+	}))
+
+	env.MustReopen(t, func(o *repo.Options) { o.TimeNowFunc = ta.NowFunc() })
+	require.False(t, env.RepositoryWriter.FormatKeyStatus().Expired(ta.NowFunc()()))
+
+	ta.Advance(48 * time.Hour)
+
+	_, err := env.RepositoryWriter.NewDirectWriter(ctx, repo.WriteSessionOptions{})
+	require.ErrorIs(t, err, repo.ErrFormatKeyExpired)
+
+	// changing password rotates the key and unblocks writes.
+	require.NoError(t, env.RepositoryWriter.ChangePassword(ctx, "new-password"))
+
+	w, err := env.RepositoryWriter.NewDirectWriter(ctx, repo.WriteSessionOptions{})
+	require.NoError(t, err)
+	require.NoError(t, w.Close(ctx))
+}
@@ -0,0 +1,225 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// FreezeBlobID is the identifier of a BLOB whose presence marks the repository as frozen.
+const FreezeBlobID = "kopia.freeze"
+
+// freezeCheckInterval is the maximum age of the cached freeze state after which
+// the freeze marker is read again before a mutation.
+const freezeCheckInterval = 1 * time.Minute
+
+// ErrRepositoryFrozen is returned when attempting to modify a frozen repository.
+var ErrRepositoryFrozen = errors.New("repository is frozen")
+
+// FreezeInfo describes a repository freeze.
+type FreezeInfo struct {
+	FrozenAt time.Time `json:"frozenAt"`
+	FrozenBy string    `json:"frozenBy"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// GetFreezeInfo returns information about repository freeze or nil if the repository is not frozen.
+func GetFreezeInfo(ctx context.Context, st blob.Reader) (*FreezeInfo, error) {
+	b, err := st.GetBlob(ctx, FreezeBlobID, 0, -1)
+	if errors.Is(err, blob.ErrBlobNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading freeze marker")
+	}
+
+	fi := &FreezeInfo{}
+	if err := json.Unmarshal(b, fi); err != nil {
+		return nil, errors.Wrap(err, "invalid freeze marker")
+	}
+
+	return fi, nil
+}
+
+// Freeze marks the repository as frozen, which blocks all writes and maintenance,
+// by all clients, until Unfreeze is called.
+func Freeze(ctx context.Context, rep DirectRepositoryWriter, reason string) error {
+	fi := &FreezeInfo{
+		FrozenAt: rep.Time(),
+		FrozenBy: rep.ClientOptions().UsernameAtHost(),
+		Reason:   reason,
+	}
+
+	b, err := json.Marshal(fi)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal freeze marker")
+	}
+
+	return errors.Wrap(rep.BlobStorage().PutBlob(ctx, FreezeBlobID, gather.FromSlice(b)), "error writing freeze marker")
+}
+
+// Unfreeze removes the freeze marker from the repository.
+func Unfreeze(ctx context.Context, rep DirectRepositoryWriter) error {
+	err := rep.BlobStorage().DeleteBlob(ctx, FreezeBlobID)
+	if err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+		return errors.Wrap(err, "error removing freeze marker")
+	}
+
+	return nil
+}
+
+// freezeGuardStorage rejects mutations other than of the freeze marker when
+// the repository is frozen.
+type freezeGuardStorage struct {
+	blob.Storage
+
+	timeNow func() time.Time
+
+	mu          sync.Mutex
+	frozen      bool
+	lastChecked time.Time
+}
+
+// checkNotFrozen returns ErrRepositoryFrozen if the repository is frozen, reading
+// the freeze marker if the cached state is too old.
+func (s *freezeGuardStorage) checkNotFrozen(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.timeNow()
+
+	if s.lastChecked.IsZero() || now.Sub(s.lastChecked) >= freezeCheckInterval {
+		fi, err := GetFreezeInfo(ctx, s.Storage)
+		if err != nil {
+			return err
+		}
+
+		s.frozen = fi != nil
+		s.lastChecked = now
+	}
+
+	if s.frozen {
+		return ErrRepositoryFrozen
+	}
+
+	return nil
+}
+
+func (s *freezeGuardStorage) setFrozen(frozen bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.frozen = frozen
+	s.lastChecked = s.timeNow()
+}
+
+func (s *freezeGuardStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	if id == FreezeBlobID {
+		if err := s.Storage.PutBlob(ctx, id, data); err != nil {
+			return err // nolint:wrapcheck
+		}
+
+		s.setFrozen(true)
+
+		return nil
+	}
+
+	if err := s.checkNotFrozen(ctx); err != nil {
+		return err
+	}
+
+	return s.Storage.PutBlob(ctx, id, data) // nolint:wrapcheck
+}
+
+func (s *freezeGuardStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	if id == FreezeBlobID {
+		if err := s.Storage.DeleteBlob(ctx, id); err != nil {
+			return err // nolint:wrapcheck
+		}
+
+		s.setFrozen(false)
+
+		return nil
+	}
+
+	if err := s.checkNotFrozen(ctx); err != nil {
+		return err
+	}
+
+	return s.Storage.DeleteBlob(ctx, id) // nolint:wrapcheck
+}
+
+// DeleteBlobs implements blob.BatchDeleter.
+func (s *freezeGuardStorage) DeleteBlobs(ctx context.Context, ids []blob.ID) error {
+	for _, id := range ids {
+		if id == FreezeBlobID {
+			// deleting the freeze marker changes the state of the guard, so delete blobs one by one.
+			for _, id := range ids {
+				if err := s.DeleteBlob(ctx, id); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+	}
+
+	if err := s.checkNotFrozen(ctx); err != nil {
+		return err
+	}
+
+	return blob.DeleteBlobs(ctx, s.Storage, ids) // nolint:wrapcheck
+}
+
+func (s *freezeGuardStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	if err := s.checkNotFrozen(ctx); err != nil {
+		return err
+	}
+
+	return s.Storage.SetTime(ctx, id, t) // nolint:wrapcheck
+}
+
+// GetRetention implements blob.RetentionReader.
+func (s *freezeGuardStorage) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	// nolint:wrapcheck
+	return blob.GetRetention(ctx, s.Storage, id)
+}
+
+// ListBlobsAfter implements blob.OrderedLister.
+func (s *freezeGuardStorage) ListBlobsAfter(ctx context.Context, prefix, startAfter blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListBlobsAfter(ctx, s.Storage, prefix, startAfter, callback)
+}
+
+// PresignedGetURL implements blob.URLPresigner.
+func (s *freezeGuardStorage) PresignedGetURL(ctx context.Context, id blob.ID, expiration time.Duration) (string, error) {
+	// nolint:wrapcheck
+	return blob.PresignedGetURL(ctx, s.Storage, id, expiration)
+}
+
+// ListDeletedBlobs implements blob.Undeleter.
+func (s *freezeGuardStorage) ListDeletedBlobs(ctx context.Context, prefix blob.ID, callback func(blob.DeletedMetadata) error) error {
+	// nolint:wrapcheck
+	return blob.ListDeletedBlobs(ctx, s.Storage, prefix, callback)
+}
+
+// UndeleteBlob implements blob.Undeleter.
+func (s *freezeGuardStorage) UndeleteBlob(ctx context.Context, id blob.ID, versionID string) error {
+	if err := s.checkNotFrozen(ctx); err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return blob.UndeleteBlob(ctx, s.Storage, id, versionID)
+}
+
+func newFreezeGuardStorage(st blob.Storage, timeNow func() time.Time) blob.Storage {
+	return &freezeGuardStorage{Storage: st, timeNow: timeNow}
+}
@@ -0,0 +1,66 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/object"
+)
+
+func TestFreeze(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	writeObjectAndFlush := func(w repo.RepositoryWriter, data string) error {
+		ow := w.NewObjectWriter(ctx, object.WriterOptions{})
+		ow.Write([]byte(data))
+
+		if _, err := ow.Result(); err != nil {
+			return err
+		}
+
+		return w.Flush(ctx)
+	}
+
+	require.NoError(t, writeObjectAndFlush(env.RepositoryWriter, "before freeze"))
+
+	fi, err := repo.GetFreezeInfo(ctx, env.RepositoryWriter.BlobReader())
+	require.NoError(t, err)
+	require.Nil(t, fi)
+
+	require.NoError(t, repo.Freeze(ctx, env.RepositoryWriter, "investigation"))
+
+	fi, err = repo.GetFreezeInfo(ctx, env.RepositoryWriter.BlobReader())
+	require.NoError(t, err)
+	require.NotNil(t, fi)
+	require.Equal(t, "investigation", fi.Reason)
+	require.Equal(t, env.RepositoryWriter.ClientOptions().UsernameAtHost(), fi.FrozenBy)
+
+	err = writeObjectAndFlush(env.RepositoryWriter, "while frozen")
+	require.True(t, errors.Is(err, repo.ErrRepositoryFrozen), "unexpected error %v", err)
+
+	// newly-opened repository sees the freeze immediately.
+	another := env.MustOpenAnother(t)
+	defer another.Close(ctx)
+
+	err = writeObjectAndFlush(another, "while frozen in another repository")
+	require.True(t, errors.Is(err, repo.ErrRepositoryFrozen), "unexpected error %v", err)
+
+	err = maintenance.RunExclusive(ctx, env.RepositoryWriter, maintenance.ModeFull, true, func(runParams maintenance.RunParameters) error {
+		t.Fatal("maintenance should not run")
+		return nil
+	})
+	require.True(t, errors.Is(err, repo.ErrRepositoryFrozen), "unexpected error %v", err)
+
+	require.NoError(t, repo.Unfreeze(ctx, env.RepositoryWriter))
+
+	fi, err = repo.GetFreezeInfo(ctx, env.RepositoryWriter.BlobReader())
+	require.NoError(t, err)
+	require.Nil(t, fi)
+
+	require.NoError(t, writeObjectAndFlush(env.RepositoryWriter, "after unfreeze"))
+}
@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"io"
@@ -35,6 +36,14 @@
 
 var errShouldRetry = errors.New("should retry")
 
+// ErrAccessDenied is returned when the repository server does not allow the user to perform the operation,
+// for example when an append-only user attempts to delete a manifest.
+var ErrAccessDenied = errors.New("access denied")
+
+// ErrUnderLegalHold is returned when the repository server refuses to delete a snapshot manifest
+// because the snapshot is under legal hold.
+var ErrUnderLegalHold = errors.New("snapshot is under legal hold")
+
 func errNoSessionResponse() error {
 	return errors.New("did not receive response from the server")
 }
@@ -486,6 +495,10 @@ func errorFromSessionResponse(rr *apipb.ErrorResponse) error {
 		return content.ErrContentNotFound
 	case apipb.ErrorResponse_STREAM_BROKEN:
 		return errors.Wrap(io.EOF, rr.Message)
+	case apipb.ErrorResponse_ACCESS_DENIED:
+		return ErrAccessDenied
+	case apipb.ErrorResponse_LEGAL_HOLD:
+		return errors.Wrap(ErrUnderLegalHold, rr.Message)
 	default:
 		return errors.New(rr.Message)
 	}
@@ -646,11 +659,23 @@ func (c grpcCreds) RequireTransportSecurity() bool {
 // OpenGRPCAPIRepository opens the Repository based on remote GRPC server.
 // The APIServerInfo must have the address of the repository as 'https://host:port'
 func OpenGRPCAPIRepository(ctx context.Context, si *APIServerInfo, cliOpts ClientOptions, contentCache *cache.PersistentCache, password string) (Repository, error) {
+	clientCerts, err := tlsutil.LoadClientCertificates(si.ClientCertificateFile, si.ClientKeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load client certificate")
+	}
+
 	var transportCreds credentials.TransportCredentials
 
-	if si.TrustedServerCertificateFingerprint != "" {
-		transportCreds = credentials.NewTLS(tlsutil.TLSConfigTrustingSingleCertificate(si.TrustedServerCertificateFingerprint))
-	} else {
+	switch {
+	case si.TrustedServerCertificateFingerprint != "":
+		tc := tlsutil.TLSConfigTrustingSingleCertificate(si.TrustedServerCertificateFingerprint)
+		tc.Certificates = clientCerts
+		transportCreds = credentials.NewTLS(tc)
+
+	case len(clientCerts) > 0:
+		transportCreds = credentials.NewTLS(&tls.Config{Certificates: clientCerts}) //nolint:gosec
+
+	default:
 		transportCreds = credentials.NewClientTLSFromCert(nil, "")
 	}
 
@@ -54,3 +54,53 @@ func TestRoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestBlake3ShortKey(t *testing.T) {
+	data := make([]byte, 100)
+	rand.Read(data)
+
+	for _, hashingAlgo := range []string{"BLAKE3-256", "BLAKE3-256-128"} {
+		// keys shorter than 32 bytes are stretched, different keys must produce different hashes.
+		f1, err := hashing.CreateHashFunc(parameters{hashingAlgo, []byte{1, 2, 3}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f2, err := hashing.CreateHashFunc(parameters{hashingAlgo, []byte{1, 2, 4}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Equal(f1(nil, data), f2(nil, data)) {
+			t.Fatalf("%v: different keys produced the same hash", hashingAlgo)
+		}
+	}
+}
+
+func BenchmarkHashing(b *testing.B) {
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+
+	hmacSecret := make([]byte, 32)
+	rand.Read(hmacSecret)
+
+	for _, hashingAlgo := range hashing.SupportedAlgorithms() {
+		hashingAlgo := hashingAlgo
+
+		b.Run(hashingAlgo, func(b *testing.B) {
+			f, err := hashing.CreateHashFunc(parameters{hashingAlgo, hmacSecret})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var output [64]byte
+
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				f(output[:0], data)
+			}
+		})
+	}
+}
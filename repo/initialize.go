@@ -7,6 +7,7 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/encryption"
@@ -34,7 +35,20 @@ type NewRepositoryOptions struct {
 	UniqueID     []byte                    `json:"uniqueID"` // force the use of particular unique ID
 	BlockFormat  content.FormattingOptions `json:"blockFormat"`
 	DisableHMAC  bool                      `json:"disableHMAC"`
-	ObjectFormat object.Format             `json:"objectFormat"` // object format
+	ObjectFormat object.Format             `json:"objectFormat"`     // object format
+	Labels       map[string]string         `json:"labels,omitempty"` // user-defined repository labels
+
+	// BlobIntegrityFooter enables authenticated integrity footers appended to all blobs, which
+	// requires format version MinFormatVersionBlobIntegrityFooter or newer, but does not enable
+	// any other format features.
+	BlobIntegrityFooter bool `json:"blobIntegrityFooter,omitempty"`
+
+	// FIPS restricts the repository to FIPS-approved algorithms and changes defaults accordingly.
+	// It is always enabled in binaries built with the 'fips' tag.
+	FIPS bool `json:"fips,omitempty"`
+
+	// KeyDerivationAlgorithm overrides the algorithm used to derive the master key from the password.
+	KeyDerivationAlgorithm string `json:"keyDerivationAlgorithm,omitempty"`
 }
 
 // ErrAlreadyInitialized indicates that repository has already been initialized.
@@ -57,13 +71,38 @@ func Initialize(ctx context.Context, st blob.Storage, opt *NewRepositoryOptions,
 	}
 
 	format := formatBlobFromOptions(opt)
+	repoConfig := repositoryObjectFormatFromOptions(opt)
+
+	if err := validateFIPS(FIPSStatus{
+		Enabled:    repoConfig.FIPS,
+		Violations: fipsViolations(format.KeyDerivationAlgorithm, format.EncryptionAlgorithm, &repoConfig.FormattingOptions),
+	}); err != nil {
+		return err
+	}
 
 	masterKey, err := format.deriveMasterKeyFromPassword(password)
 	if err != nil {
 		return errors.Wrap(err, "unable to derive master key")
 	}
 
-	if err := encryptFormatBytes(format, repositoryObjectFormatFromOptions(opt), masterKey, format.UniqueID); err != nil {
+	if err := ValidateLabels(opt.Labels); err != nil {
+		return err
+	}
+
+	if repoConfig.BlobIntegrityFooter {
+		if repoConfig.Version < MinFormatVersionBlobIntegrityFooter {
+			return errors.Errorf("blob integrity footer requires format version %v or newer", MinFormatVersionBlobIntegrityFooter)
+		}
+
+		st = newBlobIntegrityFooterStorage(st, deriveKeyFromMasterKey(masterKey, format.UniqueID, blobIntegrityKeyPurpose, blobIntegrityKeySize))
+	}
+
+	repoConfig.FormatKeyHistory = []FormatKeyInfo{{
+		KeyID:     formatKeyID(masterKey, format.UniqueID),
+		CreatedAt: clock.Now(),
+	}}
+
+	if err := encryptFormatBytes(format, repoConfig, masterKey, format.UniqueID); err != nil {
 		return errors.Wrap(err, "unable to encrypt format bytes")
 	}
 
@@ -71,15 +110,25 @@ func Initialize(ctx context.Context, st blob.Storage, opt *NewRepositoryOptions,
 		return errors.Wrap(err, "unable to write format blob")
 	}
 
+	// new repository is empty, which provides the baseline for incremental stats.
+	if err := writeStats(ctx, st, deriveKeyFromMasterKey(masterKey, format.UniqueID, statsKeyPurpose, statsKeySize), &Stats{}); err != nil {
+		return errors.Wrap(err, "unable to write stats blob")
+	}
+
 	return nil
 }
 
 func formatBlobFromOptions(opt *NewRepositoryOptions) *formatBlob {
+	keyAlgo := defaultKeyDerivationAlgorithm
+	if opt.FIPS || fipsBuild {
+		keyAlgo = PBKDF2KeyDerivationAlgorithm
+	}
+
 	return &formatBlob{
 		Tool:                   "https://github.com/kopia/kopia",
 		BuildInfo:              BuildInfo,
 		BuildVersion:           BuildVersion,
-		KeyDerivationAlgorithm: defaultKeyDerivationAlgorithm,
+		KeyDerivationAlgorithm: applyDefaultString(opt.KeyDerivationAlgorithm, keyAlgo),
 		UniqueID:               applyDefaultRandomBytes(opt.UniqueID, uniqueIDLength),
 		Version:                "1",
 		EncryptionAlgorithm:    defaultFormatEncryption,
@@ -87,18 +136,49 @@ func formatBlobFromOptions(opt *NewRepositoryOptions) *formatBlob {
 }
 
 func repositoryObjectFormatFromOptions(opt *NewRepositoryOptions) *repositoryObjectFormat {
+	enc := applyDefaultString(opt.BlockFormat.Encryption, encryption.DefaultAlgorithm)
+
+	// format features are enabled independently, when not specified explicitly they are
+	// implied by the requested format version.
+	features := content.FormatFeaturesForVersion(opt.BlockFormat.Version)
+	if opt.BlockFormat.Features != nil {
+		features = *opt.BlockFormat.Features
+	}
+
+	minVersion := encryption.MinFormatVersion(enc)
+	if v := features.MinFormatVersion(); minVersion < v {
+		minVersion = v
+	}
+
+	if opt.BlobIntegrityFooter && minVersion < MinFormatVersionBlobIntegrityFooter {
+		minVersion = MinFormatVersionBlobIntegrityFooter
+	}
+
+	fips := opt.FIPS || fipsBuild
+
+	defaultHash := hashing.DefaultAlgorithm
+	if fips {
+		defaultHash = DefaultFIPSHashAlgorithm
+	}
+
 	f := &repositoryObjectFormat{
 		FormattingOptions: content.FormattingOptions{
-			Version:     1,
-			Hash:        applyDefaultString(opt.BlockFormat.Hash, hashing.DefaultAlgorithm),
-			Encryption:  applyDefaultString(opt.BlockFormat.Encryption, encryption.DefaultAlgorithm),
+			// use the oldest format version that supports the selected algorithms
+			// to keep the repository accessible by older clients, unless newer one was requested.
+			Version:     applyDefaultInt(opt.BlockFormat.Version, minVersion),
+			Hash:        applyDefaultString(opt.BlockFormat.Hash, defaultHash),
+			Encryption:  enc,
 			HMACSecret:  applyDefaultRandomBytes(opt.BlockFormat.HMACSecret, hmacSecretLength),
 			MasterKey:   applyDefaultRandomBytes(opt.BlockFormat.MasterKey, masterKeyLength),
-			MaxPackSize: applyDefaultInt(opt.BlockFormat.MaxPackSize, 20<<20), //nolint:gomnd
+			MaxPackSize: applyDefaultInt(opt.BlockFormat.MaxPackSize, content.DefaultMaxPackSize),
+			Features:    &features,
 		},
 		Format: object.Format{
 			Splitter: applyDefaultString(opt.ObjectFormat.Splitter, splitter.DefaultAlgorithm),
 		},
+		Labels:              opt.Labels,
+		BlobIntegrityFooter: opt.BlobIntegrityFooter,
+		FIPS:                fips,
 	}
 
 	if opt.DisableHMAC {
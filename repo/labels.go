@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateLabels ensures that the provided user-defined repository labels are valid.
+func ValidateLabels(labels map[string]string) error {
+	for k := range labels {
+		if k == "" {
+			return errors.Errorf("label key must not be empty")
+		}
+
+		if strings.ContainsAny(k, "=\n") {
+			return errors.Errorf("invalid label key %q", k)
+		}
+	}
+
+	return nil
+}
+
+// Labels returns user-defined labels of the repository.
+func (r *directRepository) Labels() map[string]string {
+	return cloneLabels(r.labels)
+}
+
+// SetLabels replaces user-defined labels of the repository stored in the format blob.
+// Other clients observe the change after they reconnect to the repository.
+func (r *directRepository) SetLabels(ctx context.Context, labels map[string]string) error {
+	if err := ValidateLabels(labels); err != nil {
+		return err
+	}
+
+	repoConfig, err := r.formatBlob.decryptFormatBytes(r.masterKey)
+	if err != nil {
+		return errors.Wrap(err, "unable to decrypt repository config")
+	}
+
+	repoConfig.Labels = cloneLabels(labels)
+
+	if err := r.writeRepositoryConfig(ctx, repoConfig, r.masterKey); err != nil {
+		return err
+	}
+
+	r.labels = repoConfig.Labels
+
+	return nil
+}
+
+func cloneLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	result := map[string]string{}
+	for k, v := range labels {
+		result[k] = v
+	}
+
+	return result
+}
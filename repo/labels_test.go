@@ -0,0 +1,38 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+)
+
+func TestLabels(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+			nro.Labels = map[string]string{"env": "prod", "owner": "team-a"}
+		},
+	})
+
+	require.Equal(t, map[string]string{"env": "prod", "owner": "team-a"}, env.RepositoryWriter.Labels())
+
+	// returned labels are a copy.
+	env.RepositoryWriter.Labels()["env"] = "modified"
+	require.Equal(t, "prod", env.RepositoryWriter.Labels()["env"])
+
+	require.Error(t, env.RepositoryWriter.SetLabels(ctx, map[string]string{"": "x"}))
+	require.Error(t, env.RepositoryWriter.SetLabels(ctx, map[string]string{"a=b": "x"}))
+
+	require.NoError(t, env.RepositoryWriter.SetLabels(ctx, map[string]string{"env": "dev", "cost-center": "123"}))
+	require.Equal(t, map[string]string{"env": "dev", "cost-center": "123"}, env.RepositoryWriter.Labels())
+
+	env.MustReopen(t)
+	require.Equal(t, map[string]string{"env": "dev", "cost-center": "123"}, env.RepositoryWriter.Labels())
+
+	require.NoError(t, env.RepositoryWriter.SetLabels(ctx, nil))
+
+	env.MustReopen(t)
+	require.Nil(t, env.RepositoryWriter.Labels())
+}
@@ -6,6 +6,7 @@
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -22,10 +23,19 @@ type ClientOptions struct {
 
 	ReadOnly bool `json:"readonly,omitempty"`
 
+	// DryRun causes changes to the repository storage to be recorded and reported instead of being performed.
+	DryRun bool `json:"dryRun,omitempty"`
+
 	// Description is human-readable description of the repository to use in the UI.
 	Description string `json:"description,omitempty"`
 
 	EnableActions bool `json:"enableActions"`
+
+	// UploadParallelism is the number of files uploaded in parallel by snapshot creation, unless overridden.
+	UploadParallelism int `json:"uploadParallelism,omitempty"`
+
+	// CheckpointInterval is the frequency of checkpoints during snapshot creation, unless overridden.
+	CheckpointInterval time.Duration `json:"checkpointInterval,omitempty"`
 }
 
 // ApplyDefaults returns a copy of ClientOptions with defaults filled out.
@@ -63,6 +73,18 @@ func (o ClientOptions) Override(other ClientOptions) ClientOptions {
 		o.ReadOnly = other.ReadOnly
 	}
 
+	if other.DryRun {
+		o.DryRun = other.DryRun
+	}
+
+	if other.UploadParallelism != 0 {
+		o.UploadParallelism = other.UploadParallelism
+	}
+
+	if other.CheckpointInterval != 0 {
+		o.CheckpointInterval = other.CheckpointInterval
+	}
+
 	return o
 }
 
@@ -79,6 +101,14 @@ type LocalConfig struct {
 	// Storage is only provided for direct repository access.
 	Storage *blob.ConnectionInfo `json:"storage,omitempty"`
 
+	// MetadataReplica is the optional secondary storage location to which repository metadata
+	// is mirrored, only provided for direct repository access.
+	MetadataReplica *blob.ConnectionInfo `json:"metadataReplica,omitempty"`
+
+	// ReadReplicas are storage locations holding copies of the repository, which are used in order
+	// for reads that fail in the primary storage. Only provided for direct repository access.
+	ReadReplicas []blob.ConnectionInfo `json:"readReplicas,omitempty"`
+
 	Caching *content.CachingOptions `json:"caching,omitempty"`
 
 	ClientOptions
@@ -88,6 +118,23 @@ type LocalConfig struct {
 type repositoryObjectFormat struct {
 	content.FormattingOptions
 	object.Format
+
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// KeyDerivationSecret is the original password-derived key, which is preserved
+	// across password changes to derive keys for auxiliary data.
+	KeyDerivationSecret []byte           `json:"keyDerivationSecret,omitempty"`
+	FormatKeyHistory    []FormatKeyInfo  `json:"formatKeyHistory,omitempty"`
+	FormatKeyPolicy     *FormatKeyPolicy `json:"formatKeyPolicy,omitempty"`
+
+	// BlobIntegrityFooter enables authenticated integrity footers appended to all blobs.
+	BlobIntegrityFooter bool `json:"blobIntegrityFooter,omitempty"`
+
+	// FIPS restricts the repository to FIPS-approved algorithms, which is validated when connecting.
+	FIPS bool `json:"fips,omitempty"`
+
+	// ClientDefaults contains recommended client settings applied by clients when connecting.
+	ClientDefaults *ClientDefaults `json:"clientDefaults,omitempty"`
 }
 
 // writeToFile writes the config to a given file.
@@ -21,13 +21,24 @@ type DeleteUnreferencedBlobsOptions struct {
 }
 
 // DeleteUnreferencedBlobs deletes old blobs that are no longer referenced by index entries.
-// nolint:gocyclo
 func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWriter, opt DeleteUnreferencedBlobsOptions, safety SafetyParameters) (int, error) {
+	return deleteUnreferencedBlobs(ctx, rep, opt, safety, nil)
+}
+
+// deleteUnreferencedBlobs deletes unreferenced blobs, when the schedule is provided the progress of listing
+// is periodically saved in it, so that interrupted garbage collection can be resumed.
+// nolint:gocyclo,funlen
+func deleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWriter, opt DeleteUnreferencedBlobsOptions, safety SafetyParameters, s *Schedule) (int, error) {
 	if opt.Parallel == 0 {
 		opt.Parallel = 16
 	}
 
-	const deleteQueueSize = 100
+	const (
+		deleteQueueSize = 100
+
+		// maximum number of blobs deleted in a single request on storage supporting batch deletes.
+		deleteBatchSize = 256
+	)
 
 	var unreferenced, deleted stats.CountSum
 
@@ -35,21 +46,69 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 
 	unused := make(chan blob.Metadata, deleteQueueSize)
 
+	st := rep.BlobStorage()
+
+	batchSize := 1
+	if st.Capabilities().BatchDelete {
+		batchSize = deleteBatchSize
+	}
+
+	cp := newBlobGCCheckpointer(ctx, rep, opt, s)
+
+	deleteBatch := func(batch []blob.Metadata) error {
+		defer cp.pending.Add(-len(batch))
+
+		var ids []blob.ID
+
+		for _, bm := range batch {
+			ids = append(ids, bm.BlobID)
+		}
+
+		if err := blob.DeleteBlobs(ctx, st, ids); err != nil {
+			// must happen before the blobs are no longer pending, so that no checkpoint is saved past them.
+			cp.deletionFailed()
+
+			return errors.Wrapf(err, "unable to delete %v blobs starting with %q", len(ids), ids[0])
+		}
+
+		for _, bm := range batch {
+			cnt, del := deleted.Add(bm.Length)
+			if cnt%100 == 0 {
+				log(ctx).Infof("  deleted %v unreferenced blobs (%v)", cnt, units.BytesStringBase10(del))
+			}
+		}
+
+		return nil
+	}
+
 	if !opt.DryRun {
 		// start goroutines to delete blobs as they come.
 		for i := 0; i < opt.Parallel; i++ {
 			eg.Go(func() error {
+				var (
+					batch    []blob.Metadata
+					firstErr error
+				)
+
 				for bm := range unused {
-					if err := rep.BlobStorage().DeleteBlob(ctx, bm.BlobID); err != nil {
-						return errors.Wrapf(err, "unable to delete blob %q", bm.BlobID)
+					batch = append(batch, bm)
+
+					// flush partial batches when the queue is empty, so that checkpoints don't wait for more blobs.
+					if len(batch) < batchSize && len(unused) > 0 {
+						continue
 					}
-					cnt, del := deleted.Add(bm.Length)
-					if cnt%100 == 0 {
-						log(ctx).Infof("  deleted %v unreferenced blobs (%v)", cnt, units.BytesStringBase10(del))
+
+					if firstErr != nil {
+						// keep draining the queue, so that listing is not blocked.
+						cp.pending.Add(-len(batch))
+					} else if err := deleteBatch(batch); err != nil {
+						firstErr = err
 					}
+
+					batch = nil
 				}
 
-				return nil
+				return firstErr
 			})
 		}
 	}
@@ -69,9 +128,17 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 		return 0, errors.Wrap(err, "unable to load active sessions")
 	}
 
+	startAfter, completed := cp.iterateOptions()
+
 	// iterate all pack blobs + session blobs and keep ones that are too young or
 	// belong to alive sessions.
-	if err := rep.ContentManager().IterateUnreferencedBlobs(ctx, prefixes, opt.Parallel, func(bm blob.Metadata) error {
+	iterErr := rep.ContentManager().IterateUnreferencedBlobsWithOptions(ctx, content.IterateUnreferencedBlobsOptions{
+		Prefixes:        prefixes,
+		Parallel:        opt.Parallel,
+		StartAfter:      startAfter,
+		Completed:       completed,
+		PrefixCompleted: cp.prefixCompleted,
+	}, func(listedPrefix blob.ID, bm blob.Metadata) error {
 		if age := rep.Time().Sub(bm.Timestamp); age < safety.BlobDeleteMinAge {
 			log(ctx).Debugf("  preserving %v because it's too new (age: %v<%v)", bm.BlobID, age, safety.BlobDeleteMinAge)
 			return nil
@@ -88,13 +155,14 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 		unreferenced.Add(bm.Length)
 
 		if !opt.DryRun {
-			unused <- bm
+			cp.visit(ctx, listedPrefix, bm.BlobID, func() {
+				cp.pending.Add(1)
+				unused <- bm
+			})
 		}
 
 		return nil
-	}); err != nil {
-		return 0, errors.Wrap(err, "error looking for unreferenced blobs")
-	}
+	})
 
 	close(unused)
 
@@ -102,8 +170,16 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 	log(ctx).Debugf("Found %v blobs to delete (%v)", unreferencedCount, units.BytesStringBase10(unreferencedSize))
 
 	// wait for all delete workers to finish.
-	if err := eg.Wait(); err != nil {
-		return 0, errors.Wrap(err, "worker error")
+	workerErr := eg.Wait()
+
+	cp.finish(iterErr == nil && workerErr == nil)
+
+	if iterErr != nil {
+		return 0, errors.Wrap(iterErr, "error looking for unreferenced blobs")
+	}
+
+	if workerErr != nil {
+		return 0, errors.Wrap(workerErr, "worker error")
 	}
 
 	if opt.DryRun {
@@ -0,0 +1,202 @@
+package maintenance
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	// blobGCCheckpointInterval is the minimum interval between checkpoints persisted during blob garbage collection.
+	blobGCCheckpointInterval = 5 * time.Minute
+
+	// maxBlobGCCheckpointAge is the maximum age of a checkpoint that can be used to resume blob garbage collection.
+	maxBlobGCCheckpointAge = 7 * 24 * time.Hour
+)
+
+// BlobGCCheckpoint records progress of blob garbage collection, which allows interrupted
+// listing of very large repositories to be resumed instead of restarted.
+type BlobGCCheckpoint struct {
+	Time time.Time `json:"time"`
+
+	// Prefix is the blob prefix garbage collection was invoked with.
+	Prefix blob.ID `json:"prefix,omitempty"`
+
+	// StartAfter maps listed prefixes to the last blob ID which has been fully processed.
+	StartAfter map[blob.ID]blob.ID `json:"startAfter,omitempty"`
+
+	// Completed contains listed prefixes which have been fully processed.
+	Completed []blob.ID `json:"completed,omitempty"`
+}
+
+// blobGCCheckpointer tracks listing progress and periodically persists it in the maintenance schedule.
+//
+// Listing callbacks hold listMu for reading while handing over blobs for deletion, to save a checkpoint
+// listMu is locked for writing and all pending deletions are awaited, so that recorded positions never
+// point past a blob which has not been deleted yet.
+type blobGCCheckpointer struct {
+	rep      repo.DirectRepositoryWriter
+	schedule *Schedule // nil when checkpoints are not persisted
+	prefix   blob.ID
+
+	listMu  sync.RWMutex
+	pending sync.WaitGroup
+
+	mu         sync.Mutex
+	startAfter map[blob.ID]blob.ID
+	completed  []blob.ID
+	lastSaved  time.Time
+	failed     bool
+}
+
+func newBlobGCCheckpointer(ctx context.Context, rep repo.DirectRepositoryWriter, opt DeleteUnreferencedBlobsOptions, s *Schedule) *blobGCCheckpointer {
+	c := &blobGCCheckpointer{
+		rep:        rep,
+		prefix:     opt.Prefix,
+		startAfter: map[blob.ID]blob.ID{},
+		lastSaved:  rep.Time(),
+	}
+
+	if s == nil || opt.DryRun || !rep.BlobStorage().Capabilities().OrderedListing {
+		return c
+	}
+
+	c.schedule = s
+
+	cp := s.BlobGCCheckpoint
+	if cp == nil || cp.Prefix != opt.Prefix || rep.Time().Sub(cp.Time) > maxBlobGCCheckpointAge {
+		return c
+	}
+
+	log(ctx).Infof("Resuming blob garbage collection from checkpoint created at %v", cp.Time.Format(time.RFC3339))
+
+	for k, v := range cp.StartAfter {
+		c.startAfter[k] = v
+	}
+
+	c.completed = append(c.completed, cp.Completed...)
+
+	return c
+}
+
+// iterateOptions returns the options to resume listing from the checkpoint.
+func (c *blobGCCheckpointer) iterateOptions() (startAfter map[blob.ID]blob.ID, completed map[blob.ID]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	startAfter = map[blob.ID]blob.ID{}
+	for k, v := range c.startAfter {
+		startAfter[k] = v
+	}
+
+	completed = map[blob.ID]bool{}
+	for _, p := range c.completed {
+		completed[p] = true
+	}
+
+	return startAfter, completed
+}
+
+// visit invokes the provided function which processes the blob and records the blob as processed.
+func (c *blobGCCheckpointer) visit(ctx context.Context, listedPrefix, id blob.ID, process func()) {
+	c.maybeSave(ctx)
+
+	c.listMu.RLock()
+	defer c.listMu.RUnlock()
+
+	process()
+
+	c.mu.Lock()
+	c.startAfter[listedPrefix] = id
+	c.mu.Unlock()
+}
+
+func (c *blobGCCheckpointer) prefixCompleted(listedPrefix blob.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.completed = append(c.completed, listedPrefix)
+	delete(c.startAfter, listedPrefix)
+}
+
+// deletionFailed prevents further checkpoints from being saved, since they could point past blobs which were not deleted.
+func (c *blobGCCheckpointer) deletionFailed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failed = true
+}
+
+func (c *blobGCCheckpointer) maybeSave(ctx context.Context) {
+	if c.schedule == nil {
+		return
+	}
+
+	c.mu.Lock()
+	now := c.rep.Time()
+	due := now.Sub(c.lastSaved) >= blobGCCheckpointInterval
+
+	if due {
+		c.lastSaved = now
+	}
+	c.mu.Unlock()
+
+	if !due {
+		return
+	}
+
+	c.listMu.Lock()
+	defer c.listMu.Unlock()
+
+	c.pending.Wait()
+
+	if !c.record() {
+		return
+	}
+
+	if err := SetSchedule(ctx, c.rep, c.schedule); err != nil {
+		log(ctx).Errorf("unable to save blob garbage collection checkpoint: %v", err)
+	}
+}
+
+// record stores the current checkpoint in the schedule, it must be invoked when no deletions are pending.
+func (c *blobGCCheckpointer) record() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.schedule == nil || c.failed {
+		return false
+	}
+
+	cp := &BlobGCCheckpoint{
+		Time:       c.rep.Time(),
+		Prefix:     c.prefix,
+		StartAfter: map[blob.ID]blob.ID{},
+		Completed:  append([]blob.ID(nil), c.completed...),
+	}
+
+	for k, v := range c.startAfter {
+		cp.StartAfter[k] = v
+	}
+
+	c.schedule.BlobGCCheckpoint = cp
+
+	return true
+}
+
+// finish clears the checkpoint after successful garbage collection or records the final one after failure.
+func (c *blobGCCheckpointer) finish(success bool) {
+	if c.schedule == nil {
+		return
+	}
+
+	if success {
+		c.schedule.BlobGCCheckpoint = nil
+		return
+	}
+
+	c.record()
+}
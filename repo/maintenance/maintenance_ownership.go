@@ -0,0 +1,210 @@
+package maintenance
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// ownershipFenceValidity is the time after waiting for the previous owner during which the fence remains in effect,
+// so that a takeover interrupted half-way doesn't block the previous owner forever.
+const ownershipFenceValidity = 5 * time.Minute
+
+// ErrPreviousOwnerActive is returned when maintenance ownership can't be taken over because the previous owner is still active.
+var ErrPreviousOwnerActive = errors.New("previous maintenance owner is active")
+
+// OwnershipFence is stored in the schedule while maintenance ownership is being taken over,
+// it prevents the previous owner from starting new maintenance runs.
+type OwnershipFence struct {
+	ID            string    `json:"id"`
+	PreviousOwner string    `json:"previousOwner"`
+	NewOwner      string    `json:"newOwner"`
+	Time          time.Time `json:"time"`
+	Expires       time.Time `json:"expires"`
+}
+
+func (f *OwnershipFence) isInEffect(now time.Time) bool {
+	return f != nil && now.Before(f.Expires)
+}
+
+// checkOwnershipFence returns NotOwnedError when ownership is being taken over by another user.
+func checkOwnershipFence(ctx context.Context, rep repo.DirectRepository) error {
+	s, err := GetSchedule(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get schedule")
+	}
+
+	if f := s.OwnershipFence; f.isInEffect(rep.Time()) && f.NewOwner != rep.ClientOptions().UsernameAtHost() {
+		return NotOwnedError{f.NewOwner}
+	}
+
+	return nil
+}
+
+// verifyPreviousOwnerInactive verifies that the previous owner has not modified the schedule, reported maintenance runs
+// or had active sessions since the fence was written, allowing for clock skew.
+func verifyPreviousOwnerInactive(fence *OwnershipFence, s *Schedule, sessions map[content.SessionID]*content.SessionInfo, maxClockSkew time.Duration) error {
+	if s.OwnershipFence == nil || s.OwnershipFence.ID != fence.ID {
+		return errors.Wrap(ErrPreviousOwnerActive, "maintenance schedule was modified during takeover")
+	}
+
+	cutoff := fence.Time.Add(-maxClockSkew)
+
+	for taskType, runs := range s.Runs {
+		for _, r := range runs {
+			if r.End.After(cutoff) {
+				return errors.Wrapf(ErrPreviousOwnerActive, "%v finished at %v", taskType, r.End)
+			}
+		}
+	}
+
+	for _, si := range sessions {
+		if si.User+"@"+si.Host != fence.PreviousOwner {
+			continue
+		}
+
+		lastActive := si.CheckpointTime
+		if lastActive.IsZero() {
+			lastActive = si.StartTime
+		}
+
+		if lastActive.After(cutoff) {
+			return errors.Wrapf(ErrPreviousOwnerActive, "session %v was active at %v", si.ID, lastActive)
+		}
+	}
+
+	return nil
+}
+
+func newOwnershipFenceID() (string, error) {
+	var b [8]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", errors.Wrap(err, "unable to generate fence ID")
+	}
+
+	return hex.EncodeToString(b[:]), nil
+}
+
+func sleepInterruptibly(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "takeover interrupted")
+	case <-t.C:
+		return nil
+	}
+}
+
+// TakeOwnership transfers maintenance ownership to the provided user when the previous owner is gone.
+// It writes a fence preventing the previous owner from starting maintenance, waits out the clock skew margin
+// and verifies that the previous owner has not been active in the meantime before changing the owner.
+func TakeOwnership(ctx context.Context, rep repo.DirectRepositoryWriter, newOwner string, maxClockSkew time.Duration) error {
+	p, err := GetParams(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get maintenance params")
+	}
+
+	if p.Owner == newOwner {
+		return errors.Errorf("maintenance is already owned by %v", newOwner)
+	}
+
+	s, err := GetSchedule(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get schedule")
+	}
+
+	if f := s.OwnershipFence; f.isInEffect(rep.Time()) {
+		return errors.Errorf("maintenance ownership is already being taken over by %v", f.NewOwner)
+	}
+
+	id, err := newOwnershipFenceID()
+	if err != nil {
+		return err
+	}
+
+	fence := &OwnershipFence{
+		ID:            id,
+		PreviousOwner: p.Owner,
+		NewOwner:      newOwner,
+		Time:          rep.Time(),
+		Expires:       rep.Time().Add(maxClockSkew + ownershipFenceValidity),
+	}
+
+	s.OwnershipFence = fence
+
+	if err = SetSchedule(ctx, rep, s); err != nil {
+		return errors.Wrap(err, "unable to write ownership fence")
+	}
+
+	log(ctx).Infof("Waiting %v to make sure the previous owner %v is not active...", maxClockSkew, p.Owner)
+
+	if err = sleepInterruptibly(ctx, maxClockSkew); err != nil {
+		return err
+	}
+
+	if err = verifyTakeover(ctx, rep, fence, maxClockSkew); err != nil {
+		return err
+	}
+
+	p, err = GetParams(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get maintenance params")
+	}
+
+	p.Owner = newOwner
+
+	if err = SetParams(ctx, rep, p); err != nil {
+		return errors.Wrap(err, "unable to set maintenance owner")
+	}
+
+	return removeOwnershipFence(ctx, rep, fence)
+}
+
+// verifyTakeover verifies that the previous owner is not active and removes the fence if it is.
+func verifyTakeover(ctx context.Context, rep repo.DirectRepositoryWriter, fence *OwnershipFence, maxClockSkew time.Duration) error {
+	s, err := GetSchedule(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get schedule")
+	}
+
+	sessions, err := rep.ContentReader().ListActiveSessions(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to list active sessions")
+	}
+
+	verr := verifyPreviousOwnerInactive(fence, s, sessions, maxClockSkew)
+	if verr == nil {
+		return nil
+	}
+
+	// let the previous owner continue.
+	if err := removeOwnershipFence(ctx, rep, fence); err != nil {
+		log(ctx).Errorf("unable to remove ownership fence: %v", err)
+	}
+
+	return verr
+}
+
+func removeOwnershipFence(ctx context.Context, rep repo.DirectRepositoryWriter, fence *OwnershipFence) error {
+	s, err := GetSchedule(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get schedule")
+	}
+
+	if s.OwnershipFence == nil || s.OwnershipFence.ID != fence.ID {
+		return nil
+	}
+
+	s.OwnershipFence = nil
+
+	return errors.Wrap(SetSchedule(ctx, rep, s), "unable to remove ownership fence")
+}
@@ -0,0 +1,88 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestVerifyPreviousOwnerInactive(t *testing.T) {
+	fence := &OwnershipFence{
+		ID:            "fence1",
+		PreviousOwner: "old@host",
+		NewOwner:      "new@host",
+		Time:          t0900,
+	}
+
+	const skew = 5 * time.Minute
+
+	cases := []struct {
+		desc     string
+		schedule *Schedule
+		sessions map[content.SessionID]*content.SessionInfo
+		wantErr  bool
+	}{
+		{
+			desc:     "inactive",
+			schedule: &Schedule{OwnershipFence: fence, Runs: map[TaskType][]RunInfo{TaskSnapshotGarbageCollection: {{Start: t0700, End: t0715}}}},
+			sessions: map[content.SessionID]*content.SessionInfo{
+				"s1": {ID: "s1", User: "old", Host: "host", StartTime: t0700, CheckpointTime: t0715},
+				"s2": {ID: "s2", User: "other", Host: "host", StartTime: t0900},
+			},
+		},
+		{
+			desc:     "fence removed",
+			schedule: &Schedule{},
+			wantErr:  true,
+		},
+		{
+			desc:     "fence replaced",
+			schedule: &Schedule{OwnershipFence: &OwnershipFence{ID: "fence2"}},
+			wantErr:  true,
+		},
+		{
+			desc:     "recent run",
+			schedule: &Schedule{OwnershipFence: fence, Runs: map[TaskType][]RunInfo{TaskSnapshotGarbageCollection: {{Start: t0700, End: t0900.Add(-time.Minute)}}}},
+			wantErr:  true,
+		},
+		{
+			desc:     "recent session checkpoint",
+			schedule: &Schedule{OwnershipFence: fence},
+			sessions: map[content.SessionID]*content.SessionInfo{
+				"s1": {ID: "s1", User: "old", Host: "host", StartTime: t0700, CheckpointTime: t0915},
+			},
+			wantErr: true,
+		},
+		{
+			desc:     "recent session start",
+			schedule: &Schedule{OwnershipFence: fence},
+			sessions: map[content.SessionID]*content.SessionInfo{
+				"s1": {ID: "s1", User: "old", Host: "host", StartTime: t0900},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		err := verifyPreviousOwnerInactive(fence, tc.schedule, tc.sessions, skew)
+		if tc.wantErr {
+			require.True(t, errors.Is(err, ErrPreviousOwnerActive), tc.desc)
+		} else {
+			require.NoError(t, err, tc.desc)
+		}
+	}
+}
+
+func TestOwnershipFenceIsInEffect(t *testing.T) {
+	var nilFence *OwnershipFence
+
+	require.False(t, nilFence.isInEffect(t0900))
+
+	f := &OwnershipFence{Expires: t0915}
+	require.True(t, f.isInEffect(t0900))
+	require.False(t, f.isInEffect(t0915))
+}
@@ -6,6 +6,7 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/repodiag"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
 )
@@ -20,6 +21,22 @@ type Params struct {
 
 	QuickCycle CycleParams `json:"quick"`
 	FullCycle  CycleParams `json:"full"`
+
+	// Scrub specifies periodic verification of pack blobs, performed as part of quick or full cycles.
+	Scrub ScrubParams `json:"scrub"`
+
+	// RewriteCompressionDuplicates enables rewriting of snapshots during full maintenance to collapse
+	// identical data stored multiple times using different compression settings.
+	RewriteCompressionDuplicates bool `json:"rewriteCompressionDuplicates,omitempty"`
+
+	// LogRetention specifies retention of diagnostic logs uploaded to the repository, defaults apply if empty.
+	LogRetention repodiag.LogRetentionOptions `json:"logRetention"`
+
+	// SafetyProfile is the name of the safety profile used by maintenance, SafetyProfileDefault if empty.
+	SafetyProfile string `json:"safetyProfile,omitempty"`
+
+	// CustomSafety specifies safety parameters used when SafetyProfile is SafetyProfileCustom.
+	CustomSafety *SafetyParameters `json:"customSafety,omitempty"`
 }
 
 func (p *Params) isOwnedByByThisUser(rep repo.Repository) bool {
@@ -37,6 +54,14 @@ func DefaultParams() Params {
 			Enabled:  true,
 			Interval: 1 * time.Hour,
 		},
+		Scrub: ScrubParams{
+			CycleParams: CycleParams{
+				Enabled:  false,
+				Interval: defaultScrubInterval,
+			},
+			Percent: defaultScrubPercent,
+		},
+		LogRetention: repodiag.DefaultLogRetention(),
 	}
 }
 
@@ -10,6 +10,7 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/repodiag"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/logging"
@@ -40,6 +41,11 @@
 	TaskRewriteContentsFull       = "full-rewrite-contents"
 	TaskDropDeletedContentsFull   = "full-drop-deleted-content"
 	TaskIndexCompaction           = "index-compaction"
+	TaskRecomputeStats            = "recompute-stats"
+	TaskCleanupLogs               = "cleanup-logs"
+	TaskScrub                     = "scrub"
+
+	TaskRewriteCompressionDuplicates = "rewrite-compression-duplicates"
 )
 
 // shouldRun returns Mode if repository is due for periodic maintenance.
@@ -144,6 +150,21 @@ func RunExclusive(ctx context.Context, rep repo.DirectRepositoryWriter, mode Mod
 		return NotOwnedError{p.Owner}
 	}
 
+	if !force {
+		if err := checkOwnershipFence(ctx, rep); err != nil {
+			return err
+		}
+	}
+
+	fi, err := repo.GetFreezeInfo(ctx, rep.BlobReader())
+	if err != nil {
+		return errors.Wrap(err, "unable to determine if repository is frozen")
+	}
+
+	if fi != nil {
+		return errors.Wrapf(repo.ErrRepositoryFrozen, "maintenance is blocked since %v", fi.FrozenAt)
+	}
+
 	if mode == ModeAuto {
 		mode, err = shouldRun(ctx, rep, p)
 		if err != nil {
@@ -190,6 +211,10 @@ func RunExclusive(ctx context.Context, rep repo.DirectRepositoryWriter, mode Mod
 
 // Run performs maintenance activities for a repository.
 func Run(ctx context.Context, runParams RunParameters, safety SafetyParameters) error {
+	if err := checkClockSkew(ctx, runParams, safety); err != nil {
+		return err
+	}
+
 	switch runParams.Mode {
 	case ModeQuick:
 		return runQuickMaintenance(ctx, runParams, safety)
@@ -202,6 +227,24 @@ func Run(ctx context.Context, runParams RunParameters, safety SafetyParameters)
 	}
 }
 
+// checkClockSkew refuses to run maintenance when local clock differs too much from storage timestamps,
+// since maintenance decisions based on blob and content ages would be incorrect.
+func checkClockSkew(ctx context.Context, runParams RunParameters, safety SafetyParameters) error {
+	if safety.MaxClockSkew == 0 {
+		return nil
+	}
+
+	skew, err := repo.MeasureClockSkew(ctx, runParams.rep.BlobStorage(), clock.Now)
+	if err != nil {
+		log(ctx).Errorf("unable to measure clock skew: %v", err)
+		return nil
+	}
+
+	log(ctx).Debugf("clock skew: %v", skew)
+
+	return errors.Wrap(repo.CheckClockSkew(skew, safety.MaxClockSkew), "maintenance is not safe")
+}
+
 func runQuickMaintenance(ctx context.Context, runParams RunParameters, safety SafetyParameters) error {
 	s, err := GetSchedule(ctx, runParams.rep)
 	if err != nil {
@@ -245,6 +288,17 @@ func runQuickMaintenance(ctx context.Context, runParams RunParameters, safety Sa
 		return errors.Wrap(err, "error performing index compaction")
 	}
 
+	if err := runTaskCleanupLogs(ctx, runParams, s); err != nil {
+		return errors.Wrap(err, "error cleaning up logs")
+	}
+
+	// verify a portion of pack blobs to detect corruption early.
+	if shouldScrub(runParams, s) {
+		if err := runTaskScrub(ctx, runParams, s); err != nil {
+			return errors.Wrap(err, "error scrubbing pack blobs")
+		}
+	}
+
 	return nil
 }
 
@@ -306,16 +360,16 @@ func runTaskRewriteContentsFull(ctx context.Context, runParams RunParameters, s
 
 func runTaskDeleteOrphanedBlobsFull(ctx context.Context, runParams RunParameters, s *Schedule, safety SafetyParameters) error {
 	return ReportRun(ctx, runParams.rep, TaskDeleteOrphanedBlobsFull, s, func() error {
-		_, err := DeleteUnreferencedBlobs(ctx, runParams.rep, DeleteUnreferencedBlobsOptions{}, safety)
+		_, err := deleteUnreferencedBlobs(ctx, runParams.rep, DeleteUnreferencedBlobsOptions{}, safety, s)
 		return err
 	})
 }
 
 func runTaskDeleteOrphanedBlobsQuick(ctx context.Context, runParams RunParameters, s *Schedule, safety SafetyParameters) error {
 	return ReportRun(ctx, runParams.rep, TaskDeleteOrphanedBlobsQuick, s, func() error {
-		_, err := DeleteUnreferencedBlobs(ctx, runParams.rep, DeleteUnreferencedBlobsOptions{
+		_, err := deleteUnreferencedBlobs(ctx, runParams.rep, DeleteUnreferencedBlobsOptions{
 			Prefix: content.PackBlobIDPrefixSpecial,
-		}, safety)
+		}, safety, s)
 		return err
 	})
 }
@@ -351,9 +405,44 @@ func runFullMaintenance(ctx context.Context, runParams RunParameters, safety Saf
 		notDeletingOrphanedBlobs(ctx, s, safety)
 	}
 
+	// correct any drift in running repository statistics.
+	if err := runTaskRecomputeStats(ctx, runParams, s); err != nil {
+		return errors.Wrap(err, "error recomputing repository stats")
+	}
+
+	// verify a portion of pack blobs to detect corruption early.
+	if shouldScrub(runParams, s) {
+		if err := runTaskScrub(ctx, runParams, s); err != nil {
+			return errors.Wrap(err, "error scrubbing pack blobs")
+		}
+	}
+
 	return nil
 }
 
+func runTaskCleanupLogs(ctx context.Context, runParams RunParameters, s *Schedule) error {
+	return ReportRun(ctx, runParams.rep, TaskCleanupLogs, s, func() error {
+		deleted, err := repodiag.CleanupLogs(ctx, runParams.rep, runParams.Params.LogRetention.OrDefault())
+		if err != nil {
+			return errors.Wrap(err, "error deleting logs")
+		}
+
+		log(ctx).Infof("Cleaned up %v log blobs.", len(deleted))
+
+		return nil
+	})
+}
+
+func runTaskRecomputeStats(ctx context.Context, runParams RunParameters, s *Schedule) error {
+	return ReportRun(ctx, runParams.rep, TaskRecomputeStats, s, func() error {
+		log(ctx).Infof("Recomputing repository stats...")
+
+		_, err := repo.RecomputeStats(ctx, runParams.rep)
+
+		return errors.Wrap(err, "error recomputing stats")
+	})
+}
+
 // shouldRewriteContents returns true if it's currently ok to rewrite contents.
 // since each content rewrite will require deleting of orphaned blobs after some time passes,
 // we don't want to starve blob deletion by constantly doing rewrites.
@@ -1,38 +1,48 @@
 package maintenance
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
 
 // SafetyParameters specifies timing parameters that affect safety of maintenance.
 type SafetyParameters struct {
 	// Do not rewrite contents younger than this age.
-	RewriteMinAge time.Duration
+	RewriteMinAge time.Duration `json:"rewriteMinAge"`
 
 	// Snapshot GC: MinContentAgeSubjectToGC is the minimum age of content to be subject to garbage collection.
-	MinContentAgeSubjectToGC time.Duration
+	MinContentAgeSubjectToGC time.Duration `json:"minContentAgeSubjectToGC"`
 
 	// MarginBetweenSnapshotGC is the minimal amount of time that must pass between snapshot
 	// GC cycles to allow all in-flight snapshots during earlier GC to be flushed and
 	// visible to a following GC. The uploader will automatically create a checkpoint every 45 minutes,
 	// so ~1 hour should be enough but we're setting this to a higher conservative value for extra safety.
-	MarginBetweenSnapshotGC time.Duration
+	MarginBetweenSnapshotGC time.Duration `json:"marginBetweenSnapshotGC"`
 
 	// RequireTwoGCCycles indicates that two GC cycles are required.
-	RequireTwoGCCycles bool
+	RequireTwoGCCycles bool `json:"requireTwoGCCycles"`
 
 	// DisableEventualConsistencySafety disables wait time to allow settling of eventually-consistent writes in blob stores.
-	DisableEventualConsistencySafety bool
+	DisableEventualConsistencySafety bool `json:"disableEventualConsistencySafety"`
 
 	// DropContentFromIndexExtraMargin is the amount of margin time before dropping deleted contents from indices.
-	DropContentFromIndexExtraMargin time.Duration
+	DropContentFromIndexExtraMargin time.Duration `json:"dropContentFromIndexExtraMargin"`
 
 	// Blob GC: Delete unused blobs above this age.
-	BlobDeleteMinAge time.Duration
+	BlobDeleteMinAge time.Duration `json:"blobDeleteMinAge"`
 
 	// Blob GC: Drop incomplete session blobs above this age.
-	SessionExpirationAge time.Duration
+	SessionExpirationAge time.Duration `json:"sessionExpirationAge"`
 
 	// Minimum time that must pass after content rewrite before we delete orphaned blobs.
-	MinRewriteToOrphanDeletionDelay time.Duration
+	MinRewriteToOrphanDeletionDelay time.Duration `json:"minRewriteToOrphanDeletionDelay"`
+
+	// Maximum allowed difference between local clock and blob storage timestamps, zero disables the check.
+	MaxClockSkew time.Duration `json:"maxClockSkew"`
 }
 
 // Supported safety levels.
@@ -62,5 +72,110 @@ type SafetyParameters struct {
 		SessionExpirationAge:            96 * time.Hour, //nolint:gomnd
 		RequireTwoGCCycles:              true,
 		MinRewriteToOrphanDeletionDelay: time.Hour,
+		MaxClockSkew:                    repo.MaxClockSkew,
 	}
+
+	// SafetyFast has shorter safety margins than SafetyFull, which allow space to be reclaimed sooner.
+	// It is safe with concurrent Kopia clients as long as snapshots complete or checkpoint regularly
+	// and client clocks are accurate.
+	SafetyFast = SafetyParameters{
+		BlobDeleteMinAge:                time.Hour,
+		DropContentFromIndexExtraMargin: 30 * time.Minute, //nolint:gomnd
+		MarginBetweenSnapshotGC:         time.Hour,
+		MinContentAgeSubjectToGC:        4 * time.Hour, //nolint:gomnd
+		RewriteMinAge:                   time.Hour,
+		SessionExpirationAge:            48 * time.Hour, //nolint:gomnd
+		RequireTwoGCCycles:              true,
+		MinRewriteToOrphanDeletionDelay: 30 * time.Minute, //nolint:gomnd
+		MaxClockSkew:                    repo.MaxClockSkew,
+	}
+
+	// SafetyParanoid has safety margins much larger than SafetyFull, suitable for storage with
+	// very slow eventual consistency or clients that can be offline for a long time in the middle of a snapshot.
+	SafetyParanoid = SafetyParameters{
+		BlobDeleteMinAge:                24 * time.Hour,     //nolint:gomnd
+		DropContentFromIndexExtraMargin: 6 * time.Hour,      //nolint:gomnd
+		MarginBetweenSnapshotGC:         12 * time.Hour,     //nolint:gomnd
+		MinContentAgeSubjectToGC:        72 * time.Hour,     //nolint:gomnd
+		RewriteMinAge:                   24 * time.Hour,     //nolint:gomnd
+		SessionExpirationAge:            7 * 24 * time.Hour, //nolint:gomnd
+		RequireTwoGCCycles:              true,
+		MinRewriteToOrphanDeletionDelay: 6 * time.Hour, //nolint:gomnd
+		MaxClockSkew:                    repo.MaxClockSkew,
+	}
+)
+
+// Names of safety profiles that can be configured in maintenance parameters.
+const (
+	SafetyProfileFast     = "fast"
+	SafetyProfileDefault  = "default"
+	SafetyProfileParanoid = "paranoid"
+	SafetyProfileCustom   = "custom"
 )
+
+// SafetyProfiles maps names of predefined safety profiles to their parameters.
+// nolint:gochecknoglobals
+var SafetyProfiles = map[string]SafetyParameters{
+	SafetyProfileFast:     SafetyFast,
+	SafetyProfileDefault:  SafetyFull,
+	SafetyProfileParanoid: SafetyParanoid,
+}
+
+// SafetyProfileOrDefault returns the name of the configured safety profile or SafetyProfileDefault if not set.
+func (p *Params) SafetyProfileOrDefault() string {
+	if p.SafetyProfile == "" {
+		return SafetyProfileDefault
+	}
+
+	return p.SafetyProfile
+}
+
+// Safety returns the safety parameters selected by the maintenance parameters.
+func (p *Params) Safety() (SafetyParameters, error) {
+	name := p.SafetyProfileOrDefault()
+
+	if name == SafetyProfileCustom {
+		if p.CustomSafety == nil {
+			return SafetyParameters{}, errors.Errorf("custom safety profile selected, but custom safety parameters are not set")
+		}
+
+		return *p.CustomSafety, ValidateSafetyParameters(*p.CustomSafety)
+	}
+
+	sp, ok := SafetyProfiles[name]
+	if !ok {
+		return SafetyParameters{}, errors.Errorf("unknown safety profile %q", name)
+	}
+
+	return sp, nil
+}
+
+// ValidateSafetyParameters ensures that custom safety parameters are valid.
+func ValidateSafetyParameters(sp SafetyParameters) error {
+	for name, d := range map[string]time.Duration{
+		"rewrite min age":                      sp.RewriteMinAge,
+		"min content age subject to GC":        sp.MinContentAgeSubjectToGC,
+		"margin between snapshot GC":           sp.MarginBetweenSnapshotGC,
+		"drop content from index extra margin": sp.DropContentFromIndexExtraMargin,
+		"blob delete min age":                  sp.BlobDeleteMinAge,
+		"session expiration age":               sp.SessionExpirationAge,
+		"min rewrite to orphan deletion delay": sp.MinRewriteToOrphanDeletionDelay,
+		"max clock skew":                       sp.MaxClockSkew,
+	} {
+		if d < 0 {
+			return errors.Errorf("%v must not be negative", name)
+		}
+	}
+
+	return nil
+}
+
+// GetSafetyParameters returns the safety parameters configured for the repository.
+func GetSafetyParameters(ctx context.Context, rep repo.Repository) (SafetyParameters, error) {
+	p, err := GetParams(ctx, rep)
+	if err != nil {
+		return SafetyParameters{}, errors.Wrap(err, "unable to get maintenance params")
+	}
+
+	return p.Safety()
+}
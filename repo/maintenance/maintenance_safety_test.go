@@ -140,3 +140,45 @@ func verifyObjectNotFound(ctx context.Context, t *testing.T, rep repo.Repository
 		return nil
 	}))
 }
+
+func TestSafetyProfiles(t *testing.T) {
+	var p maintenance.Params
+
+	// default profile is used when not set.
+	require.Equal(t, maintenance.SafetyProfileDefault, p.SafetyProfileOrDefault())
+
+	sp, err := p.Safety()
+	require.NoError(t, err)
+	require.Equal(t, maintenance.SafetyFull, sp)
+
+	p.SafetyProfile = maintenance.SafetyProfileFast
+	sp, err = p.Safety()
+	require.NoError(t, err)
+	require.Equal(t, maintenance.SafetyFast, sp)
+
+	// profiles are ordered from least to most conservative.
+	require.Less(t, maintenance.SafetyFast.MinContentAgeSubjectToGC, maintenance.SafetyFull.MinContentAgeSubjectToGC)
+	require.Less(t, maintenance.SafetyFull.MinContentAgeSubjectToGC, maintenance.SafetyParanoid.MinContentAgeSubjectToGC)
+	require.Less(t, maintenance.SafetyFast.BlobDeleteMinAge, maintenance.SafetyFull.BlobDeleteMinAge)
+	require.Less(t, maintenance.SafetyFull.BlobDeleteMinAge, maintenance.SafetyParanoid.BlobDeleteMinAge)
+
+	p.SafetyProfile = maintenance.SafetyProfileCustom
+	_, err = p.Safety()
+	require.Error(t, err)
+
+	custom := maintenance.SafetyFull
+	custom.BlobDeleteMinAge = 3 * time.Hour
+	p.CustomSafety = &custom
+
+	sp, err = p.Safety()
+	require.NoError(t, err)
+	require.Equal(t, custom, sp)
+
+	custom.RewriteMinAge = -time.Hour
+	_, err = p.Safety()
+	require.Error(t, err)
+
+	p.SafetyProfile = "no-such-profile"
+	_, err = p.Safety()
+	require.Error(t, err)
+}
@@ -40,6 +40,19 @@ type RunInfo struct {
 type Schedule struct {
 	NextFullMaintenanceTime  time.Time `json:"nextFullMaintenance"`
 	NextQuickMaintenanceTime time.Time `json:"nextQuickMaintenance"`
+	NextScrubTime            time.Time `json:"nextScrub,omitempty"`
+
+	// ScrubCursor is the ID of the last pack blob verified by scrubbing.
+	ScrubCursor blob.ID `json:"scrubCursor,omitempty"`
+
+	// ScrubFindings contains pack blobs that failed verification during scrubbing, the most recent first.
+	ScrubFindings []ScrubFinding `json:"scrubFindings,omitempty"`
+
+	// BlobGCCheckpoint contains progress of interrupted blob garbage collection.
+	BlobGCCheckpoint *BlobGCCheckpoint `json:"blobGCCheckpoint,omitempty"`
+
+	// OwnershipFence is present while maintenance ownership is being taken over.
+	OwnershipFence *OwnershipFence `json:"ownershipFence,omitempty"`
 
 	Runs map[TaskType][]RunInfo `json:"runs"`
 }
@@ -60,6 +73,20 @@ func (s *Schedule) ReportRun(taskType TaskType, info RunInfo) {
 	s.Runs[taskType] = history
 }
 
+// reportScrubFindings adds the provided scrub findings to the schedule and discards oldest entries.
+func (s *Schedule) reportScrubFindings(findings []ScrubFinding) {
+	if len(findings) == 0 {
+		return
+	}
+
+	// insert as first items
+	s.ScrubFindings = append(append([]ScrubFinding(nil), findings...), s.ScrubFindings...)
+
+	if len(s.ScrubFindings) > maxRetainedScrubFindings {
+		s.ScrubFindings = s.ScrubFindings[0:maxRetainedScrubFindings]
+	}
+}
+
 func getAES256GCM(rep repo.DirectRepository) (cipher.AEAD, error) {
 	c, err := aes.NewCipher(rep.DeriveKey(maintenanceScheduleKeyPurpose, maintenanceScheduleKeySize))
 	if err != nil {
@@ -0,0 +1,198 @@
+package maintenance
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+const (
+	defaultScrubPercent  = 10
+	defaultScrubInterval = 24 * time.Hour
+
+	// maxRetainedScrubFindings is the maximum number of scrub findings retained in the schedule.
+	maxRetainedScrubFindings = 100
+)
+
+// ErrCorruptionFound is returned when scrubbing finds pack blobs that can't be read or verified.
+var ErrCorruptionFound = errors.New("scrub found corrupted pack blobs")
+
+// ScrubParams specifies parameters for periodic scrubbing of pack blobs.
+type ScrubParams struct {
+	CycleParams
+
+	// Percent of pack blobs to read and verify in each scrub cycle, defaults apply if zero.
+	Percent float64 `json:"percent,omitempty"`
+}
+
+// PercentOrDefault returns the percentage of pack blobs to verify in each scrub cycle.
+func (p ScrubParams) PercentOrDefault() float64 {
+	if p.Percent <= 0 {
+		return defaultScrubPercent
+	}
+
+	if p.Percent > 100 { //nolint:gomnd
+		return 100 //nolint:gomnd
+	}
+
+	return p.Percent
+}
+
+// IntervalOrDefault returns the interval between scrub cycles.
+func (p ScrubParams) IntervalOrDefault() time.Duration {
+	if p.Interval <= 0 {
+		return defaultScrubInterval
+	}
+
+	return p.Interval
+}
+
+// ScrubFinding describes a pack blob that failed verification during scrubbing.
+type ScrubFinding struct {
+	BlobID blob.ID   `json:"blobID"`
+	Time   time.Time `json:"time"`
+	Error  string    `json:"error"`
+}
+
+// ScrubResult is the result of a single scrub cycle.
+type ScrubResult struct {
+	PacksChecked int            `json:"packsChecked"`
+	BytesChecked int64          `json:"bytesChecked"`
+	Cursor       blob.ID        `json:"cursor"`
+	Findings     []ScrubFinding `json:"findings"`
+}
+
+// Scrub reads and verifies the given percentage of pack blobs directly from the storage, starting
+// with the first pack blob after the provided cursor and wrapping around after the last one.
+// Subsequent cycles should pass the returned cursor, so that all pack blobs are eventually verified.
+func Scrub(ctx context.Context, rep repo.DirectRepositoryWriter, cursor blob.ID, percent float64) (*ScrubResult, error) {
+	var packs []content.PackInfo
+
+	if err := rep.ContentReader().IteratePacks(ctx, content.IteratePackOptions{
+		IncludePacksWithOnlyDeletedContent: true,
+		IncludeContentInfos:                true,
+	}, func(pi content.PackInfo) error {
+		packs = append(packs, pi)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating packs")
+	}
+
+	result := &ScrubResult{
+		Cursor:   cursor,
+		Findings: []ScrubFinding{},
+	}
+
+	if len(packs) == 0 {
+		return result, nil
+	}
+
+	sort.Slice(packs, func(i, j int) bool {
+		return packs[i].PackID < packs[j].PackID
+	})
+
+	count := int(math.Ceil(float64(len(packs)) * percent / 100)) //nolint:gomnd
+	if count > len(packs) {
+		count = len(packs)
+	}
+
+	start := sort.Search(len(packs), func(i int) bool {
+		return packs[i].PackID > cursor
+	})
+
+	log(ctx).Infof("Scrubbing %v of %v pack blobs...", count, len(packs))
+
+	for i := 0; i < count; i++ {
+		pi := packs[(start+i)%len(packs)]
+
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrap(err, "scrub interrupted")
+		}
+
+		if err := rep.ContentManager().VerifyPackBlob(ctx, pi.PackID, pi.ContentInfos); err != nil {
+			log(ctx).Errorf("Pack blob %v failed verification: %v", pi.PackID, err)
+
+			result.Findings = append(result.Findings, ScrubFinding{
+				BlobID: pi.PackID,
+				Time:   rep.Time(),
+				Error:  err.Error(),
+			})
+		}
+
+		result.PacksChecked++
+		result.BytesChecked += pi.TotalSize
+		result.Cursor = pi.PackID
+	}
+
+	log(ctx).Infof("Scrubbed %v pack blobs, found %v corrupted.", result.PacksChecked, len(result.Findings))
+
+	return result, nil
+}
+
+// RunScrub runs a single scrub cycle continuing from the cursor persisted in the maintenance schedule,
+// records the findings and reports the run as TaskScrub.
+func RunScrub(ctx context.Context, rep repo.DirectRepositoryWriter, percent float64) (*ScrubResult, error) {
+	s, err := GetSchedule(ctx, rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get schedule")
+	}
+
+	var result *ScrubResult
+
+	err = ReportRun(ctx, rep, TaskScrub, s, func() error {
+		var err error
+
+		result, err = scrubAndRecord(ctx, rep, s, percent)
+
+		return err
+	})
+
+	return result, err
+}
+
+func scrubAndRecord(ctx context.Context, rep repo.DirectRepositoryWriter, s *Schedule, percent float64) (*ScrubResult, error) {
+	result, err := Scrub(ctx, rep, s.ScrubCursor, percent)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ScrubCursor = result.Cursor
+	s.reportScrubFindings(result.Findings)
+
+	if len(result.Findings) > 0 {
+		return result, errors.Wrapf(ErrCorruptionFound, "%v of %v pack blobs failed verification", len(result.Findings), result.PacksChecked)
+	}
+
+	return result, nil
+}
+
+func shouldScrub(runParams RunParameters, s *Schedule) bool {
+	return runParams.Params.Scrub.Enabled && runParams.rep.Time().After(s.NextScrubTime)
+}
+
+func runTaskScrub(ctx context.Context, runParams RunParameters, s *Schedule) error {
+	p := runParams.Params.Scrub
+
+	s.NextScrubTime = runParams.rep.Time().Add(p.IntervalOrDefault())
+	log(ctx).Debugf("scheduling next scrub at %v", s.NextScrubTime)
+
+	err := ReportRun(ctx, runParams.rep, TaskScrub, s, func() error {
+		_, err := scrubAndRecord(ctx, runParams.rep, s, p.PercentOrDefault())
+		return err
+	})
+
+	if errors.Is(err, ErrCorruptionFound) {
+		// corruption is reported as a failed task and in the schedule, it does not prevent other maintenance tasks.
+		log(ctx).Errorf("%v, see 'kopia maintenance info' for details.", err)
+		return nil
+	}
+
+	return err
+}
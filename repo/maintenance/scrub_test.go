@@ -0,0 +1,123 @@
+package maintenance_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+func TestScrub(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	for i := 0; i < 4; i++ {
+		_, err := env.RepositoryWriter.ContentManager().WriteContent(ctx, []byte(fmt.Sprintf("content-%v", i)), "")
+		require.NoError(t, err)
+		require.NoError(t, env.RepositoryWriter.Flush(ctx))
+	}
+
+	var (
+		packs    []blob.ID
+		packInfo = map[blob.ID]content.PackInfo{}
+	)
+
+	require.NoError(t, env.RepositoryWriter.ContentReader().IteratePacks(ctx, content.IteratePackOptions{IncludeContentInfos: true}, func(pi content.PackInfo) error {
+		packs = append(packs, pi.PackID)
+		packInfo[pi.PackID] = pi
+		return nil
+	}))
+	require.Len(t, packs, 4)
+
+	// two cycles of 50% verify all packs.
+	r1, err := maintenance.RunScrub(ctx, env.RepositoryWriter, 50)
+	require.NoError(t, err)
+	require.Equal(t, 2, r1.PacksChecked)
+	require.Empty(t, r1.Findings)
+
+	r2, err := maintenance.RunScrub(ctx, env.RepositoryWriter, 50)
+	require.NoError(t, err)
+	require.Equal(t, 2, r2.PacksChecked)
+	require.Greater(t, string(r2.Cursor), string(r1.Cursor))
+
+	// third cycle wraps around.
+	r3, err := maintenance.RunScrub(ctx, env.RepositoryWriter, 50)
+	require.NoError(t, err)
+	require.Equal(t, r1.Cursor, r3.Cursor)
+
+	s, err := maintenance.GetSchedule(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Equal(t, r3.Cursor, s.ScrubCursor)
+	require.True(t, s.Runs[maintenance.TaskScrub][0].Success)
+
+	// corrupt the content stored in one pack blob in the storage.
+	st := env.RepositoryWriter.BlobStorage()
+	data, err := st.GetBlob(ctx, packs[1], 0, -1)
+	require.NoError(t, err)
+
+	data[packInfo[packs[1]].ContentInfos[0].GetPackOffset()] ^= 0xff
+	require.NoError(t, st.PutBlob(ctx, packs[1], gather.FromSlice(data)))
+
+	r4, err := maintenance.RunScrub(ctx, env.RepositoryWriter, 100)
+	require.True(t, errors.Is(err, maintenance.ErrCorruptionFound), "unexpected error %v", err)
+	require.Equal(t, 4, r4.PacksChecked)
+	require.Len(t, r4.Findings, 1)
+	require.Equal(t, packs[1], r4.Findings[0].BlobID)
+
+	s, err = maintenance.GetSchedule(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, s.ScrubFindings, 1)
+	require.Equal(t, packs[1], s.ScrubFindings[0].BlobID)
+	require.False(t, s.Runs[maintenance.TaskScrub][0].Success)
+}
+
+func TestScrubParamsDefaults(t *testing.T) {
+	var p maintenance.ScrubParams
+
+	require.Greater(t, p.PercentOrDefault(), 0.0)
+	require.Greater(t, int64(p.IntervalOrDefault()), int64(0))
+
+	p.Percent = 250
+	require.Equal(t, 100.0, p.PercentOrDefault())
+}
+
+func TestScrubRunsDuringMaintenance(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	_, err := env.RepositoryWriter.ContentManager().WriteContent(ctx, []byte("hello"), "")
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	p := maintenance.DefaultParams()
+	p.Owner = env.RepositoryWriter.ClientOptions().UsernameAtHost()
+	p.Scrub.Enabled = true
+	p.Scrub.Percent = 100
+	require.NoError(t, maintenance.SetParams(ctx, env.RepositoryWriter, &p))
+
+	runQuick := func() {
+		require.NoError(t, maintenance.RunExclusive(ctx, env.RepositoryWriter, maintenance.ModeQuick, false, func(runParams maintenance.RunParameters) error {
+			return maintenance.Run(ctx, runParams, maintenance.SafetyNone)
+		}))
+	}
+
+	runQuick()
+
+	s, err := maintenance.GetSchedule(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, s.Runs[maintenance.TaskScrub], 1)
+	require.NotEmpty(t, s.ScrubCursor)
+	require.True(t, s.NextScrubTime.After(env.RepositoryWriter.Time()))
+
+	// not due for another scrub yet.
+	runQuick()
+
+	s, err = maintenance.GetSchedule(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, s.Runs[maintenance.TaskScrub], 1)
+}
@@ -5,13 +5,25 @@
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"io"
 	"sync"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo/content"
 )
 
+// MinFormatVersionCompressedManifests is the minimum repository format version that writes
+// manifest contents compressed with zstd instead of gzip.
+const MinFormatVersionCompressedManifests = content.MinFormatVersionCompressedManifests
+
+// maxBatchedManifestEntries is the maximum number of entries in manifest contents written by this manager
+// that will be combined with pending entries on subsequent commits.
+const maxBatchedManifestEntries = 100
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
 // committedManifestManager manages committed manifest entries stored in 'm' contents.
 type committedManifestManager struct {
 	b contentManager
@@ -21,6 +33,10 @@ type committedManifestManager struct {
 	locked              bool
 	committedEntries    map[ID]*manifestEntry
 	committedContentIDs map[content.ID]bool
+
+	// small manifest contents written by this manager and their entries,
+	// which will be combined with entries written by the next commit.
+	batchedContents map[content.ID][]*manifestEntry
 }
 
 func (m *committedManifestManager) getCommittedEntryOrNil(ctx context.Context, id ID) (*manifestEntry, error) {
@@ -45,11 +61,85 @@ func (m *committedManifestManager) findCommittedEntries(ctx context.Context, lab
 	return findEntriesMatchingLabels(m.committedEntries, labels), nil
 }
 
+// commitEntries writes pending entries together with entries of small manifest contents previously written by this
+// manager, which are then deleted, so that frequent small commits don't result in large number of manifest contents.
 func (m *committedManifestManager) commitEntries(ctx context.Context, entries map[ID]*manifestEntry) (map[content.ID]bool, error) {
 	m.lock()
 	defer m.unlock()
 
-	return m.writeEntriesLocked(ctx, entries)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	toWrite := map[ID]*manifestEntry{}
+	for k, v := range entries {
+		toWrite[k] = v
+	}
+
+	var folded []content.ID
+
+	for contentID, batched := range m.batchedContents {
+		if !m.committedContentIDs[contentID] {
+			// content was removed by compaction.
+			delete(m.batchedContents, contentID)
+			continue
+		}
+
+		if len(toWrite)+len(batched) > maxBatchedManifestEntries {
+			continue
+		}
+
+		// entries are rewritten as-is, when merging the newest entry with a given ID wins,
+		// so this is safe even if they have been superseded by entries in other contents.
+		for _, e := range batched {
+			if prev := toWrite[e.ID]; prev == nil || e.ModTime.After(prev.ModTime) {
+				toWrite[e.ID] = e
+			}
+		}
+
+		folded = append(folded, contentID)
+	}
+
+	if len(folded) > 0 {
+		// writing combined content and deleting folded ones needs to be atomic.
+		m.b.DisableIndexFlush(ctx)
+		defer m.b.EnableIndexFlush(ctx)
+	}
+
+	written := make([]*manifestEntry, 0, len(toWrite))
+	for _, e := range toWrite {
+		written = append(written, e)
+	}
+
+	writtenContentIDs, err := m.writeEntriesLocked(ctx, toWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	for id := range entries {
+		delete(entries, id)
+	}
+
+	for _, contentID := range folded {
+		if writtenContentIDs[contentID] {
+			continue
+		}
+
+		if err := m.b.DeleteContent(ctx, contentID); err != nil {
+			return nil, errors.Wrapf(err, "unable to delete batched content %q", contentID)
+		}
+
+		delete(m.committedContentIDs, contentID)
+		delete(m.batchedContents, contentID)
+	}
+
+	if len(written) <= maxBatchedManifestEntries {
+		for contentID := range writtenContentIDs {
+			m.batchedContents[contentID] = written
+		}
+	}
+
+	return writtenContentIDs, nil
 }
 
 // writeEntriesLocked writes entries in the provided map as manifest contents
@@ -70,19 +160,13 @@ func (m *committedManifestManager) writeEntriesLocked(ctx context.Context, entri
 		man.Entries = append(man.Entries, e)
 	}
 
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	mustSucceed(json.NewEncoder(gz).Encode(man))
-	mustSucceed(gz.Flush())
-	mustSucceed(gz.Close())
-
-	contentID, err := m.b.WriteContent(ctx, buf.Bytes(), ContentPrefix)
+	contentID, err := m.b.WriteContent(ctx, m.encodeManifest(man), ContentPrefix)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to write content")
 	}
 
 	for _, e := range entries {
-		m.committedEntries[e.ID] = e
+		m.mergeEntryLocked(e)
 		delete(entries, e.ID)
 	}
 
@@ -91,6 +175,28 @@ func (m *committedManifestManager) writeEntriesLocked(ctx context.Context, entri
 	return map[content.ID]bool{contentID: true}, nil
 }
 
+// encodeManifest serializes the manifest to JSON and compresses it using zstd if supported by the repository format,
+// gzip otherwise.
+func (m *committedManifestManager) encodeManifest(man manifest) []byte {
+	var buf bytes.Buffer
+
+	if f := m.b.ContentFormat(); f.EnabledFeatures().CompressedManifests {
+		zw, err := zstd.NewWriter(&buf)
+		mustSucceed(err)
+		mustSucceed(json.NewEncoder(zw).Encode(man))
+		mustSucceed(zw.Close())
+
+		return buf.Bytes()
+	}
+
+	gz := gzip.NewWriter(&buf)
+	mustSucceed(json.NewEncoder(gz).Encode(man))
+	mustSucceed(gz.Flush())
+	mustSucceed(gz.Close())
+
+	return buf.Bytes()
+}
+
 func (m *committedManifestManager) loadCommittedContentsLocked(ctx context.Context) error {
 	m.verifyLocked()
 
@@ -288,12 +394,27 @@ func loadManifestContent(ctx context.Context, b contentManager, contentID conten
 		return man, errors.Wrap(err, "error loading manifest content")
 	}
 
-	gz, err := gzip.NewReader(bytes.NewReader(blk))
-	if err != nil {
-		return man, errors.Wrapf(err, "unable to unpack manifest data %q", contentID)
+	var r io.Reader
+
+	if bytes.HasPrefix(blk, zstdMagic) {
+		zr, err := zstd.NewReader(bytes.NewReader(blk))
+		if err != nil {
+			return man, errors.Wrapf(err, "unable to unpack manifest data %q", contentID)
+		}
+
+		defer zr.Close()
+
+		r = zr
+	} else {
+		gz, err := gzip.NewReader(bytes.NewReader(blk))
+		if err != nil {
+			return man, errors.Wrapf(err, "unable to unpack manifest data %q", contentID)
+		}
+
+		r = gz
 	}
 
-	if err := json.NewDecoder(gz).Decode(&man); err != nil {
+	if err := json.NewDecoder(r).Decode(&man); err != nil {
 		return man, errors.Wrapf(err, "unable to parse manifest %q", contentID)
 	}
 
@@ -305,5 +426,6 @@ func newCommittedManager(b contentManager) *committedManifestManager {
 		b:                   b,
 		committedEntries:    map[ID]*manifestEntry{},
 		committedContentIDs: map[content.ID]bool{},
+		batchedContents:     map[content.ID][]*manifestEntry{},
 	}
 }
@@ -42,6 +42,7 @@ type contentManager interface {
 	DisableIndexFlush(ctx context.Context)
 	EnableIndexFlush(ctx context.Context)
 	Flush(ctx context.Context) error
+	ContentFormat() content.FormattingOptions
 }
 
 // ID is a unique identifier of a single manifest.
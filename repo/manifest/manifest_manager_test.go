@@ -1,6 +1,7 @@
 package manifest
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"reflect"
@@ -300,13 +301,19 @@ func sortIDs(s []ID) {
 func newManagerForTesting(ctx context.Context, t *testing.T, data blobtesting.DataMap) *Manager {
 	t.Helper()
 
+	return newManagerForTestingWithFormatVersion(ctx, t, data, 1)
+}
+
+func newManagerForTestingWithFormatVersion(ctx context.Context, t *testing.T, data blobtesting.DataMap, formatVersion int) *Manager {
+	t.Helper()
+
 	st := blobtesting.NewMapStorage(data, nil, nil)
 
 	bm, err := content.NewManager(ctx, st, &content.FormattingOptions{
 		Hash:        hashing.DefaultAlgorithm,
 		Encryption:  encryption.DefaultAlgorithm,
 		MaxPackSize: 100000,
-		Version:     1,
+		Version:     formatVersion,
 	}, nil, nil)
 	if err != nil {
 		t.Fatalf("can't create content manager: %v", err)
@@ -370,3 +377,86 @@ func TestManifestAutoCompaction(t *testing.T) {
 		require.NoError(t, mgr.b.Flush(ctx))
 	}
 }
+
+func manifestContentIDs(ctx context.Context, t *testing.T, mgr *Manager) []content.ID {
+	t.Helper()
+
+	var result []content.ID
+
+	require.NoError(t, mgr.b.IterateContents(ctx, content.IterateOptions{
+		Range: content.PrefixRange(ContentPrefix),
+	}, func(ci content.Info) error {
+		result = append(result, ci.GetContentID())
+		return nil
+	}))
+
+	return result
+}
+
+func TestManifestBatching(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+
+	mgr := newManagerForTesting(ctx, t, data)
+	labels := map[string]string{"type": "item"}
+
+	var ids []ID
+
+	for i := 0; i < 10; i++ {
+		ids = append(ids, addAndVerify(ctx, t, mgr, labels, map[string]int{"i": i}))
+
+		require.NoError(t, mgr.Flush(ctx))
+
+		// small contents written by previous flushes are combined into one.
+		require.Len(t, manifestContentIDs(ctx, t, mgr), 1)
+	}
+
+	require.NoError(t, mgr.b.Flush(ctx))
+
+	require.NoError(t, mgr.Delete(ctx, ids[0]))
+	require.NoError(t, mgr.Flush(ctx))
+	require.NoError(t, mgr.b.Flush(ctx))
+
+	mgr2 := newManagerForTesting(ctx, t, data)
+
+	verifyItemNotFound(ctx, t, mgr2, ids[0])
+
+	for i, id := range ids[1:] {
+		verifyItem(ctx, t, mgr2, id, labels, map[string]int{"i": i + 1})
+	}
+
+	verifyMatches(ctx, t, mgr2, labels, ids[1:])
+}
+
+func TestManifestCompressionByFormatVersion(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	cases := []struct {
+		formatVersion int
+		wantPrefix    []byte
+	}{
+		{1, []byte{0x1f, 0x8b}},
+		{MinFormatVersionCompressedManifests - 1, []byte{0x1f, 0x8b}},
+		{MinFormatVersionCompressedManifests, zstdMagic},
+	}
+
+	for _, tc := range cases {
+		data := blobtesting.DataMap{}
+		mgr := newManagerForTestingWithFormatVersion(ctx, t, data, tc.formatVersion)
+		labels := map[string]string{"type": "item"}
+
+		id := addAndVerify(ctx, t, mgr, labels, map[string]int{"foo": 1})
+		require.NoError(t, mgr.Flush(ctx))
+		require.NoError(t, mgr.b.Flush(ctx))
+
+		cids := manifestContentIDs(ctx, t, mgr)
+		require.Len(t, cids, 1)
+
+		b, err := mgr.b.GetContent(ctx, cids[0])
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(b, tc.wantPrefix), "format version %v", tc.formatVersion)
+
+		mgr2 := newManagerForTestingWithFormatVersion(ctx, t, data, tc.formatVersion)
+		verifyItem(ctx, t, mgr2, id, labels, map[string]int{"foo": 1})
+	}
+}
@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/replica"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// metadataBlobIDPrefix is the common prefix of format, maintenance and other fixed repository blobs.
+const metadataBlobIDPrefix = "kopia."
+
+// IsMetadataBlob determines whether the provided blob is repository metadata, which is mirrored to
+// the metadata replica. Metadata blobs include format and other fixed blobs, indexes, sessions
+// and packs holding metadata contents, which together are sufficient to recover the repository
+// without rebuilding the index from data packs.
+func IsMetadataBlob(id blob.ID) bool {
+	s := string(id)
+
+	if strings.HasPrefix(s, metadataBlobIDPrefix) {
+		// clock skew probes are short-lived and don't need to be preserved.
+		return !strings.HasPrefix(s, ClockSkewBlobIDPrefix)
+	}
+
+	for _, prefix := range append([]blob.ID{
+		content.PackBlobIDPrefixSpecial,
+		content.BlobIDPrefixSession,
+	}, content.IndexBlobPrefixes...) {
+		if strings.HasPrefix(s, string(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SyncMetadataReplica copies metadata blobs that are missing or different in dst from src
+// and returns the number of copied blobs.
+func SyncMetadataReplica(ctx context.Context, src blob.Reader, dst blob.Storage) (int, error) {
+	n, err := replica.Sync(ctx, src, dst, IsMetadataBlob)
+
+	return n, errors.Wrap(err, "error synchronizing metadata")
+}
+
+// SetMetadataReplica changes the location of the metadata replica of the repository connected
+// using the provided config file. Passing nil disables the replica.
+func SetMetadataReplica(ctx context.Context, configFile string, ci *blob.ConnectionInfo) error {
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	if lc.Storage == nil {
+		return errors.Errorf("metadata replica is only supported for directly-connected repositories")
+	}
+
+	lc.MetadataReplica = ci
+
+	return lc.writeToFile(configFile)
+}
+
+// wrapWithMetadataReplica returns storage which mirrors metadata blobs to the replica location configured in lc, if any.
+func wrapWithMetadataReplica(ctx context.Context, st blob.Storage, lc *LocalConfig) (blob.Storage, error) {
+	if lc.MetadataReplica == nil {
+		return st, nil
+	}
+
+	rs, err := blob.NewStorage(ctx, *lc.MetadataReplica)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open metadata replica storage")
+	}
+
+	return replica.NewWrapper(st, rs, IsMetadataBlob), nil
+}
@@ -0,0 +1,30 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestIsMetadataBlob(t *testing.T) {
+	cases := map[blob.ID]bool{
+		repo.FormatBlobID:                   true,
+		repo.FreezeBlobID:                   true,
+		"kopia.maintenance":                 true,
+		repo.ClockSkewBlobIDPrefix + "1234": false,
+		"n1234":                             true,
+		"m1234":                             true,
+		"l1234":                             true,
+		"s1234-c1":                          true,
+		"q1234":                             true,
+		"p1234":                             false,
+		"xn1234":                            false,
+	}
+
+	for id, want := range cases {
+		require.Equal(t, want, repo.IsMetadataBlob(id), id)
+	}
+}
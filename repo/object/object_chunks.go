@@ -0,0 +1,115 @@
+package object
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+// ChunkCallback is invoked by IterateChunks for each direct object that stores a portion of data,
+// along with the length of that portion or -1 if it is not known.
+type ChunkCallback func(chunk ID, length int64) error
+
+// WriterFactory creates object writers.
+type WriterFactory func(ctx context.Context, opt WriterOptions) Writer
+
+// IterateChunks invokes the callback for each direct object that stores a portion of the provided object, in order.
+// Lengths are only unknown for objects that are not indirect, in which case it's the length of the entire object.
+func IterateChunks(ctx context.Context, cr contentReader, oid ID, callback ChunkCallback) error {
+	return iterateChunks(ctx, cr, oid, -1, callback)
+}
+
+func iterateChunks(ctx context.Context, cr contentReader, oid ID, length int64, callback ChunkCallback) error {
+	indexObjectID, ok := oid.IndexObjectID()
+	if !ok {
+		if _, _, ok := oid.ContentID(); !ok {
+			return errors.Errorf("unrecognized object type: %v", oid)
+		}
+
+		return callback(oid, length)
+	}
+
+	seekTable, err := loadSeekTable(ctx, cr, indexObjectID)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range seekTable {
+		if err := iterateChunks(ctx, cr, e.Object, e.Length, callback); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReplaceChunks returns the ID of an object storing the same data as the provided object, in which direct
+// objects storing portions of data are replaced according to the provided function, which must only return
+// objects storing identical data. New index objects are written using the provided writer factory.
+// When no chunks are replaced, the original object ID is returned along with false.
+func ReplaceChunks(ctx context.Context, cr contentReader, newWriter WriterFactory, oid ID, replace func(chunk ID) (ID, bool)) (ID, bool, error) {
+	indexObjectID, ok := oid.IndexObjectID()
+	if !ok {
+		if r, ok := replace(oid); ok && r != oid {
+			return r, true, nil
+		}
+
+		return oid, false, nil
+	}
+
+	seekTable, err := loadSeekTable(ctx, cr, indexObjectID)
+	if err != nil {
+		return "", false, err
+	}
+
+	changed := false
+
+	for i := range seekTable {
+		r, ok, err := ReplaceChunks(ctx, cr, newWriter, seekTable[i].Object, replace)
+		if err != nil {
+			return "", false, err
+		}
+
+		if ok {
+			seekTable[i].Object = r
+			changed = true
+		}
+	}
+
+	if !changed {
+		return oid, false, nil
+	}
+
+	w := newWriter(ctx, WriterOptions{
+		Description: "LIST(" + string(oid) + ")",
+		Prefix:      indexObjectPrefix(indexObjectID),
+	})
+
+	defer w.Close() //nolint:errcheck
+
+	if err := writeIndirectObject(w, seekTable); err != nil {
+		return "", false, err
+	}
+
+	newIndexObjectID, err := w.Result()
+	if err != nil {
+		return "", false, errors.Wrap(err, "unable to write index object")
+	}
+
+	return IndirectObjectID(newIndexObjectID), true, nil
+}
+
+// indexObjectPrefix returns the content ID prefix used by the provided index object.
+func indexObjectPrefix(indexObjectID ID) content.ID {
+	if nested, ok := indexObjectID.IndexObjectID(); ok {
+		return indexObjectPrefix(nested)
+	}
+
+	if cid, _, ok := indexObjectID.ContentID(); ok && cid.HasPrefix() {
+		return cid.Prefix()
+	}
+
+	return indirectContentPrefix
+}
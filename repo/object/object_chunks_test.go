@@ -0,0 +1,89 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestIterateAndReplaceChunks(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, om := setupTest(t)
+
+	data := bytes.Repeat([]byte("hello world "), 250000)
+
+	uncompressed := mustWriteObject(t, om, data, "")
+	compressed := mustWriteObject(t, om, data, "gzip")
+
+	require.NotEqual(t, uncompressed, compressed)
+
+	uncompressedChunks := mustListChunks(ctx, t, om, uncompressed)
+	compressedChunks := mustListChunks(ctx, t, om, compressed)
+
+	require.Len(t, compressedChunks, len(uncompressedChunks))
+	require.Greater(t, len(compressedChunks), 1)
+
+	replacements := map[ID]ID{}
+
+	for i, c := range compressedChunks {
+		_, isCompressed, ok := c.ContentID()
+		require.True(t, ok)
+		require.True(t, isCompressed)
+
+		replacements[c] = uncompressedChunks[i]
+	}
+
+	replace := func(chunk ID) (ID, bool) {
+		r, ok := replacements[chunk]
+		return r, ok
+	}
+
+	// replacing all chunks of the compressed object produces the uncompressed object.
+	oid, changed, err := ReplaceChunks(ctx, om.contentMgr, om.NewWriter, compressed, replace)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, uncompressed, oid)
+	verify(ctx, t, om.contentMgr, oid, data, "replaced")
+
+	// nothing to replace in the uncompressed object.
+	oid, changed, err = ReplaceChunks(ctx, om.contentMgr, om.NewWriter, uncompressed, replace)
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.Equal(t, uncompressed, oid)
+
+	// direct objects are replaced as a whole.
+	small := mustWriteObject(t, om, []byte("hello world"), "")
+	require.NoError(t, IterateChunks(ctx, om.contentMgr, small, func(chunk ID, length int64) error {
+		require.Equal(t, small, chunk)
+		require.Equal(t, int64(-1), length)
+
+		return nil
+	}))
+
+	oid, changed, err = ReplaceChunks(ctx, om.contentMgr, om.NewWriter, small, func(chunk ID) (ID, bool) {
+		return uncompressedChunks[0], true
+	})
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, uncompressedChunks[0], oid)
+}
+
+func mustListChunks(ctx context.Context, t *testing.T, om *Manager, oid ID) []ID {
+	t.Helper()
+
+	var result []ID
+
+	require.NoError(t, IterateChunks(ctx, om.contentMgr, oid, func(chunk ID, length int64) error {
+		require.Greater(t, length, int64(0))
+
+		result = append(result, chunk)
+
+		return nil
+	}))
+
+	return result
+}
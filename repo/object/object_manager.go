@@ -4,6 +4,7 @@
 import (
 	"context"
 	"io"
+	"runtime"
 
 	"github.com/pkg/errors"
 
@@ -49,6 +50,10 @@ type Manager struct {
 	contentMgr  contentManager
 	newSplitter splitter.Factory
 	bufferPool  *buf.Pool
+
+	// semaphore shared by all writers that compress their chunks in parallel,
+	// which bounds the total number of concurrent compressions.
+	compressionPool chan struct{}
 }
 
 // NewWriter creates an ObjectWriter for writing to the repository.
@@ -65,8 +70,19 @@ func (om *Manager) NewWriter(ctx context.Context, opt WriterOptions) Writer {
 	// point the slice at the embedded array, so that we avoid allocations most of the time
 	w.indirectIndex = w.indirectIndexBuf[:0]
 
-	if opt.AsyncWrites > 0 {
-		w.asyncWritesSemaphore = make(chan struct{}, opt.AsyncWrites)
+	asyncWrites := opt.AsyncWrites
+
+	if w.compressor != nil && opt.ParallelCompression > 1 {
+		// chunks are compressed asynchronously, so we need at least as many async writes.
+		w.useCompressionPool = true
+
+		if opt.ParallelCompression > asyncWrites {
+			asyncWrites = opt.ParallelCompression
+		}
+	}
+
+	if asyncWrites > 0 {
+		w.asyncWritesSemaphore = make(chan struct{}, asyncWrites)
 	}
 
 	w.initBuffer()
@@ -176,8 +192,9 @@ func appendIndexEntries(indexEntries []indirectObjectEntry, startingLength int64
 // NewObjectManager creates an ObjectManager with the specified content manager and format.
 func NewObjectManager(ctx context.Context, bm contentManager, f Format) (*Manager, error) {
 	om := &Manager{
-		contentMgr: bm,
-		Format:     f,
+		contentMgr:      bm,
+		Format:          f,
+		compressionPool: make(chan struct{}, runtime.NumCPU()),
 	}
 
 	splitterID := f.Splitter
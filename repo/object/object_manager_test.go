@@ -710,6 +710,49 @@ func TestEndToEndReadAndSeekWithCompression(t *testing.T) {
 	}
 }
 
+func TestParallelCompression(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, om := setupTest(t)
+
+	inputData := makeMaybeCompressibleData(5012434, true)
+
+	var oids []ID
+
+	for _, parallelCompression := range []int{0, 1, 4, 16} {
+		writer := om.NewWriter(ctx, WriterOptions{
+			Compressor:          "gzip",
+			ParallelCompression: parallelCompression,
+		})
+
+		if _, err := writer.Write(inputData); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+
+		objectID, err := writer.Result()
+
+		writer.Close()
+
+		if err != nil {
+			t.Fatalf("cannot get writer result: %v", err)
+		}
+
+		verify(ctx, t, om.contentMgr, objectID, inputData, fmt.Sprintf("%v parallel %v", objectID, parallelCompression))
+
+		oids = append(oids, objectID)
+	}
+
+	// compressing in parallel must produce exactly the same object.
+	for _, oid := range oids {
+		if oid != oids[0] {
+			t.Errorf("object IDs differ: %v", oids)
+		}
+	}
+
+	if got := len(om.compressionPool); got != 0 {
+		t.Errorf("compression pool not released: %v", got)
+	}
+}
+
 func makeMaybeCompressibleData(size int, compressible bool) []byte {
 	if compressible {
 		phrase := []byte("quick brown fox")
@@ -66,7 +66,8 @@ type objectWriter struct {
 	ctx context.Context
 	om  *Manager
 
-	compressor compression.Compressor
+	compressor         compression.Compressor
+	useCompressionPool bool // compress using shared compression pool of the object manager
 
 	prefix      content.ID
 	buf         buf.Buf
@@ -190,7 +191,7 @@ func (w *objectWriter) prepareAndWriteContentChunk(chunkID int, data []byte) err
 	defer b.Release()
 
 	// contentBytes is what we're going to write to the content manager, it potentially uses bytes from b
-	contentBytes, isCompressed, err := maybeCompressedContentBytes(w.compressor, bytes.NewBuffer(b.Data[:0]), data)
+	contentBytes, isCompressed, err := w.compressChunk(bytes.NewBuffer(b.Data[:0]), data)
 	if err != nil {
 		return errors.Wrap(err, "unable to prepare content bytes")
 	}
@@ -208,6 +209,15 @@ func (w *objectWriter) prepareAndWriteContentChunk(chunkID int, data []byte) err
 	return nil
 }
 
+func (w *objectWriter) compressChunk(output *bytes.Buffer, data []byte) ([]byte, bool, error) {
+	if w.useCompressionPool {
+		w.om.compressionPool <- struct{}{}
+		defer func() { <-w.om.compressionPool }()
+	}
+
+	return maybeCompressedContentBytes(w.compressor, output, data)
+}
+
 func (w *objectWriter) saveError(err error) error {
 	if err != nil {
 		// store write error so that we fail at Result() later.
@@ -332,4 +342,8 @@ type WriterOptions struct {
 	Prefix      content.ID // empty string or a single-character ('g'..'z')
 	Compressor  compression.Name
 	AsyncWrites int // allow up to N content writes to be asynchronous
+
+	// ParallelCompression allows up to N chunks of the object to be compressed in parallel
+	// using the compression pool shared by all writers of the object manager.
+	ParallelCompression int
 }
@@ -3,6 +3,7 @@
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -14,8 +15,11 @@
 	"github.com/kopia/kopia/internal/atomicfile"
 	"github.com/kopia/kopia/internal/cache"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/dryrun"
 	loggingwrapper "github.com/kopia/kopia/repo/blob/logging"
 	"github.com/kopia/kopia/repo/blob/readonly"
+	"github.com/kopia/kopia/repo/blob/throttling"
+	"github.com/kopia/kopia/repo/blob/tracing"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/manifest"
@@ -49,6 +53,19 @@
 type Options struct {
 	TraceStorage func(f string, args ...interface{}) // Logs all storage access using provided Printf-style function
 	TimeNowFunc  func() time.Time                    // Time provider
+
+	StorageTracing *tracing.Options // Emits structured record for blob operations
+
+	// WrapStorage, when set, wraps the storage of the repository, used by tests to inject storage faults.
+	WrapStorage func(st blob.Storage) blob.Storage
+
+	// CachePool and Throttler allow multiple repositories opened by the same process to share
+	// bounded content/metadata caches and bandwidth limits. The caller is responsible for
+	// closing them after all repositories using them have been closed.
+	CachePool *content.CachePool
+	Throttler *throttling.Throttler
+
+	IndexFetchParallelism int // Maximum number of index blobs fetched concurrently (0 == default)
 }
 
 // ErrInvalidPassword is returned when repository password is invalid.
@@ -92,12 +109,12 @@ func getContentCacheOrNil(ctx context.Context, opt *content.CachingOptions, pass
 		return nil, errors.Wrap(err, "error opening storage")
 	}
 
-	// derive content cache key from the password & HMAC secret using scrypt.
+	// derive content cache key from the password & HMAC secret.
 	salt := append([]byte("content-cache-protection"), opt.HMACSecret...)
 
-	cacheEncryptionKey, err := scrypt.Key([]byte(password), salt, 65536, 8, 1, 32)
+	cacheEncryptionKey, err := deriveCacheEncryptionKey(password, salt)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to derive cache encryption key from password")
+		return nil, err
 	}
 
 	prot, err := cache.AuthenticatedEncryptionProtection(cacheEncryptionKey)
@@ -105,7 +122,11 @@ func getContentCacheOrNil(ctx context.Context, opt *content.CachingOptions, pass
 		return nil, errors.Wrap(err, "unable to initialize protection")
 	}
 
-	pc, err := cache.NewPersistentCache(ctx, "cache-storage", cs, prot, opt.MaxCacheSizeBytes, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
+	pc, err := cache.NewPersistentCache(ctx, "cache-storage", cs, prot, cache.SizeLimit{
+		MaxSizeBytes:       opt.MaxCacheSizeBytes,
+		FreeSpacePercent:   opt.FreeSpacePercent,
+		FreeSpaceDirectory: opt.CacheDirectory,
+	}, cache.DefaultTouchThreshold, cache.DefaultSweepFrequency)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to open persistent cache")
 	}
@@ -113,6 +134,21 @@ func getContentCacheOrNil(ctx context.Context, opt *content.CachingOptions, pass
 	return pc, nil
 }
 
+func deriveCacheEncryptionKey(password string, salt []byte) ([]byte, error) {
+	const keySize = 32
+
+	if fipsBuild {
+		return derivePBKDF2Key(password, salt, keySize), nil
+	}
+
+	k, err := scrypt.Key([]byte(password), salt, 65536, 8, 1, keySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to derive cache encryption key from password")
+	}
+
+	return k, nil
+}
+
 // OpenAPIServer connects remote repository over Kopia API.
 func OpenAPIServer(ctx context.Context, si *APIServerInfo, cliOpts ClientOptions, cachingOptions *content.CachingOptions, password string) (Repository, error) {
 	contentCache, err := getContentCacheOrNil(ctx, cachingOptions, password)
@@ -138,15 +174,52 @@ func openDirect(ctx context.Context, configFile string, lc *LocalConfig, passwor
 		return nil, errors.Wrap(err, "cannot open storage")
 	}
 
+	fst, err := wrapWithReadReplicas(ctx, st, lc)
+	if err != nil {
+		st.Close(ctx) //nolint:errcheck
+		return nil, err
+	}
+
+	st = fst
+
+	// replicate blobs as stored, including integrity footers added by openWithConfig.
+	rst, err := wrapWithMetadataReplica(ctx, st, lc)
+	if err != nil {
+		st.Close(ctx) //nolint:errcheck
+		return nil, err
+	}
+
+	st = rst
+
+	if options.WrapStorage != nil {
+		st = options.WrapStorage(st)
+	}
+
+	if options.Throttler != nil {
+		st = throttling.NewWrapper(st, options.Throttler)
+	}
+
 	if options.TraceStorage != nil {
 		st = loggingwrapper.NewWrapper(st, options.TraceStorage, "[STORAGE] ")
 	}
 
+	if options.StorageTracing != nil {
+		st = tracing.NewWrapper(st, *options.StorageTracing)
+	}
+
+	caching := lc.Caching
+
+	if lc.DryRun {
+		// local caches are not used, so that blobs that were never written don't leak into subsequent commands.
+		st = dryrun.NewWrapper(st, options.TimeNowFunc)
+		caching = &content.CachingOptions{}
+	}
+
 	if lc.ReadOnly {
 		st = readonly.NewWrapper(st)
 	}
 
-	r, err := openWithConfig(ctx, st, lc, password, options, lc.Caching, configFile)
+	r, err := openWithConfig(ctx, st, lc, password, options, caching, configFile)
 	if err != nil {
 		st.Close(ctx) //nolint:errcheck
 		return nil, err
@@ -179,6 +252,10 @@ func openWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 		return nil, errors.Errorf("unable to add checksum")
 	}
 
+	if fipsBuild && !fipsKeyDerivationAlgorithms[f.KeyDerivationAlgorithm] {
+		return nil, errors.Errorf("key derivation algorithm %v is not FIPS-approved", f.KeyDerivationAlgorithm)
+	}
+
 	masterKey, err := f.deriveMasterKeyFromPassword(password)
 	if err != nil {
 		return nil, err
@@ -189,30 +266,65 @@ func openWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 		return nil, ErrInvalidPassword
 	}
 
-	caching.HMACSecret = deriveKeyFromMasterKey(masterKey, f.UniqueID, []byte("local-cache-integrity"), 16)
+	fipsStatus := FIPSStatus{
+		Enabled:    repoConfig.FIPS || fipsBuild,
+		Violations: fipsViolations(f.KeyDerivationAlgorithm, f.EncryptionAlgorithm, &repoConfig.FormattingOptions),
+	}
+
+	if err := validateFIPS(fipsStatus); err != nil {
+		return nil, err
+	}
+
+	// keys of auxiliary data are derived from the original key, which survives password changes.
+	derivationKey := masterKey
+	if repoConfig.KeyDerivationSecret != nil {
+		derivationKey = repoConfig.KeyDerivationSecret
+	}
+
+	caching.HMACSecret = deriveKeyFromMasterKey(derivationKey, f.UniqueID, []byte("local-cache-integrity"), 16)
 
 	fo := &repoConfig.FormattingOptions
 
 	if fo.MaxPackSize == 0 {
 		// legacy only, apply default
-		fo.MaxPackSize = 20 << 20 // nolint:gomnd
+		fo.MaxPackSize = content.DefaultMaxPackSize
 	}
 
 	cmOpts := &content.ManagerOptions{
 		RepositoryFormatBytes: fb,
 		TimeNow:               defaultTime(options.TimeNowFunc),
+		CachePool:             options.CachePool,
+		CachePoolNamespace:    hex.EncodeToString(f.UniqueID),
+		IndexFetchParallelism: options.IndexFetchParallelism,
+	}
+
+	if repoConfig.BlobIntegrityFooter {
+		st = newBlobIntegrityFooterStorage(st, deriveKeyFromMasterKey(derivationKey, f.UniqueID, blobIntegrityKeyPurpose, blobIntegrityKeySize))
 	}
 
+	// reject all writes while the repository is frozen.
+	st = newFreezeGuardStorage(st, cmOpts.TimeNow)
+
 	scm, err := content.NewSharedManager(ctx, st, fo, caching, cmOpts)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create shared content manager")
 	}
 
+	su := newStatsUpdater(st, derivationKey, f.UniqueID, cmOpts.TimeNow)
+
 	cm := content.NewWriteManager(scm, content.SessionOptions{
-		SessionUser: lc.Username,
-		SessionHost: lc.Hostname,
+		SessionUser:  lc.Username,
+		SessionHost:  lc.Hostname,
+		OnIndexFlush: su.onIndexFlush,
 	})
 
+	if !lc.ReadOnly {
+		// recover contents that were written but not flushed by sessions that crashed.
+		if _, err := cm.ReplayWriteJournals(ctx); err != nil {
+			log(ctx).Errorf("unable to replay write journals: %v", err)
+		}
+	}
+
 	om, err := object.NewObjectManager(ctx, cm, repoConfig.Format)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to open object manager")
@@ -234,13 +346,31 @@ func openWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 			cachingOptions: *caching,
 			formatBlob:     f,
 			masterKey:      masterKey,
+			derivationKey:  derivationKey,
+			stats:          su,
+			labels:         repoConfig.Labels,
+			clientDefaults: repoConfig.ClientDefaults,
 			timeNow:        cmOpts.TimeNow,
 			cliOpts:        lc.ClientOptions.ApplyDefaults(ctx, "Repository in "+st.DisplayName()),
 			configFile:     configFile,
+
+			blobIntegrityFooter: repoConfig.BlobIntegrityFooter,
+			fipsStatus:          fipsStatus,
+			formatKeyStatus: FormatKeyStatus{
+				History: repoConfig.FormatKeyHistory,
+			},
 		},
 		closed: make(chan struct{}),
 	}
 
+	if repoConfig.FormatKeyPolicy != nil {
+		dr.formatKeyStatus.Policy = *repoConfig.FormatKeyPolicy
+	}
+
+	if dr.formatKeyStatus.Expired(dr.Time()) {
+		dr.warnFormatKeyExpired(ctx)
+	}
+
 	go dr.RefreshPeriodically(ctx, backgroundRefreshInterval)
 
 	return dr, nil
@@ -253,8 +383,8 @@ func writeCacheMarker(cacheDir string) error {
 
 	markerFile := filepath.Join(cacheDir, CacheDirMarkerFile)
 
-	st, err := os.Stat(markerFile)
-	if err == nil && st.Size() >= int64(len(cacheDirMarkerContents)) {
+	b, err := ioutil.ReadFile(markerFile) //nolint:gosec
+	if err == nil && bytes.HasPrefix(b, []byte(CacheDirMarkerHeader)) && len(b) >= len(cacheDirMarkerContents) {
 		// ok
 		return nil
 	}
@@ -263,6 +393,8 @@ func writeCacheMarker(cacheDir string) error {
 		return errors.Wrap(err, "unexpected cache marker error")
 	}
 
+	// the marker is missing or corrupt, (re-)create it.
+
 	f, err := os.Create(markerFile)
 	if err != nil {
 		return errors.Wrap(err, "error creating cache marker")
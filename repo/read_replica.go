@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/readfallback"
+)
+
+// AddReadReplica adds a storage location holding a copy of the repository (such as one created using
+// 'kopia repository sync-to') that is used for reads when the primary storage is unavailable.
+func AddReadReplica(ctx context.Context, configFile string, ci blob.ConnectionInfo) error {
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	if lc.Storage == nil {
+		return errors.Errorf("read replicas are only supported for directly-connected repositories")
+	}
+
+	lc.ReadReplicas = append(lc.ReadReplicas, ci)
+
+	return lc.writeToFile(configFile)
+}
+
+// ClearReadReplicas removes all read replicas from the configuration of the connected repository.
+func ClearReadReplicas(ctx context.Context, configFile string) error {
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	lc.ReadReplicas = nil
+
+	return lc.writeToFile(configFile)
+}
+
+// wrapWithReadReplicas returns storage which fails over reads to read replicas and the metadata replica
+// configured in lc, in that order.
+func wrapWithReadReplicas(ctx context.Context, st blob.Storage, lc *LocalConfig) (blob.Storage, error) {
+	cis := append([]blob.ConnectionInfo(nil), lc.ReadReplicas...)
+	if lc.MetadataReplica != nil {
+		cis = append(cis, *lc.MetadataReplica)
+	}
+
+	var replicas []blob.Storage
+
+	for _, ci := range cis {
+		rs, err := blob.NewStorage(ctx, ci)
+		if err != nil {
+			for _, r := range replicas {
+				r.Close(ctx) //nolint:errcheck
+			}
+
+			return nil, errors.Wrap(err, "cannot open read replica storage")
+		}
+
+		replicas = append(replicas, rs)
+	}
+
+	return readfallback.NewWrapper(st, replicas...), nil
+}
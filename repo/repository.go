@@ -56,6 +56,12 @@ type DirectRepository interface {
 
 	// misc
 	UniqueID() []byte
+	Labels() map[string]string
+	ClientDefaults() *ClientDefaults
+	FormatKeyStatus() FormatKeyStatus
+	BlobIntegrityFooter() bool
+	FIPSStatus() FIPSStatus
+	ChangePassword(ctx context.Context, newPassword string) error
 	ConfigFilename() string
 	DeriveKey(purpose []byte, keyLength int) []byte
 	Token(password string) (string, error)
@@ -69,6 +75,9 @@ type DirectRepositoryWriter interface {
 	BlobStorage() blob.Storage
 	ContentManager() *content.WriteManager
 	Upgrade(ctx context.Context) error
+	SetLabels(ctx context.Context, labels map[string]string) error
+	SetClientDefaults(ctx context.Context, d *ClientDefaults) error
+	SetFormatKeyPolicy(ctx context.Context, p FormatKeyPolicy) error
 }
 
 type directRepositoryParameters struct {
@@ -79,6 +88,14 @@ type directRepositoryParameters struct {
 	timeNow        func() time.Time
 	formatBlob     *formatBlob
 	masterKey      []byte
+	derivationKey  []byte
+	stats          *statsUpdater
+	labels         map[string]string
+	clientDefaults *ClientDefaults
+
+	formatKeyStatus     FormatKeyStatus
+	blobIntegrityFooter bool
+	fipsStatus          FIPSStatus
 }
 
 // directRepository is an implementation of repository that directly manipulates underlying storage.
@@ -96,7 +113,7 @@ type directRepository struct {
 
 // DeriveKey derives encryption key of the provided length from the master key.
 func (r *directRepository) DeriveKey(purpose []byte, keyLength int) []byte {
-	return deriveKeyFromMasterKey(r.masterKey, r.uniqueID, purpose, keyLength)
+	return deriveKeyFromMasterKey(r.derivationKey, r.uniqueID, purpose, keyLength)
 }
 
 // ClientOptions returns client options.
@@ -178,10 +195,15 @@ func (r *directRepository) NewWriter(ctx context.Context, opt WriteSessionOption
 
 // NewDirectWriter returns new DirectRepositoryWriter session for repository.
 func (r *directRepository) NewDirectWriter(ctx context.Context, opt WriteSessionOptions) (DirectRepositoryWriter, error) {
+	if err := r.checkFormatKeyAge(); err != nil {
+		return nil, err
+	}
+
 	cmgr := content.NewWriteManager(r.sm, content.SessionOptions{
-		SessionUser: r.cliOpts.Username,
-		SessionHost: r.cliOpts.Hostname,
-		OnUpload:    opt.OnUpload,
+		SessionUser:  r.cliOpts.Username,
+		SessionHost:  r.cliOpts.Hostname,
+		OnUpload:     opt.OnUpload,
+		OnIndexFlush: r.stats.onIndexFlush,
 	})
 
 	mmgr, err := manifest.NewManager(ctx, cmgr, manifest.ManagerOptions{
@@ -14,6 +14,8 @@
 	"github.com/kopia/kopia/internal/repotesting"
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/faultinject"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/object"
 )
@@ -113,7 +115,7 @@ func TestPackingSimple(t *testing.T) {
 		t.Errorf("oid3a(%q) != oid3b(%q)", got, want)
 	}
 
-	env.VerifyBlobCount(t, 3)
+	env.VerifyBlobCount(t, 4)
 
 	env.MustReopen(t)
 
@@ -168,6 +170,29 @@ func TestUpgrade(t *testing.T) {
 	}
 }
 
+func TestFormatFeaturesEnabledIndependently(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		NewRepositoryOptions: func(n *repo.NewRepositoryOptions) {
+			n.BlockFormat.Features = &content.FormatFeatures{StreamedDirectories: true}
+		},
+	})
+
+	f := env.RepositoryWriter.ContentReader().ContentFormat()
+	require.Equal(t, content.MinFormatVersionStreamedDirectories, f.Version)
+	require.Equal(t, content.FormatFeatures{StreamedDirectories: true}, f.EnabledFeatures())
+
+	data := bytes.Repeat([]byte{1, 2, 3}, 1000)
+	oid := writeObject(ctx, t, env.RepositoryWriter, data, "features")
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	env.MustReopen(t)
+
+	f = env.RepositoryWriter.ContentReader().ContentFormat()
+	require.Equal(t, content.FormatFeatures{StreamedDirectories: true}, f.EnabledFeatures())
+
+	verify(ctx, t, env.RepositoryWriter, oid, data, "features")
+}
+
 func TestReaderStoredBlockNotFound(t *testing.T) {
 	ctx, env := repotesting.NewEnvironment(t)
 
@@ -458,3 +483,32 @@ func verifyNotFound(ctx context.Context, t *testing.T, rep repo.Repository, obje
 		return
 	}
 }
+
+func TestWrapStorageFaultsAreNotRetried(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.WrapStorage = func(st blob.Storage) blob.Storage {
+				return faultinject.NewWrapper(st, faultinject.Options{
+					Script: []faultinject.ScriptedFault{
+						{Method: faultinject.MethodPutBlob, PartialWrite: true},
+					},
+				})
+			}
+		},
+	})
+
+	data := bytes.Repeat([]byte{1, 2, 3}, 1000)
+
+	w := env.RepositoryWriter.NewObjectWriter(ctx, object.WriterOptions{})
+	w.Write(data)
+
+	// injected fault surfaces to the caller instead of being retried.
+	_, err := w.Result()
+	require.True(t, errors.Is(err, faultinject.ErrInjected))
+
+	// subsequent writes succeed, partially-written blob is overwritten.
+	oid := writeObject(ctx, t, env.RepositoryWriter, data, "after-fault")
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	verify(ctx, t, env.RepositoryWriter, oid, data, "after-fault")
+}
@@ -0,0 +1,190 @@
+package repo
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// StatsBlobID is the identifier of a BLOB that holds running repository statistics.
+const StatsBlobID = "kopia.stats"
+
+const statsKeySize = 32
+
+var (
+	statsKeyPurpose    = []byte("repository stats")
+	statsAEADExtraData = []byte("stats")
+)
+
+// statsMutex serializes read-modify-write cycles of the stats blob within the process.
+var statsMutex sync.Mutex // nolint:gochecknoglobals
+
+// Stats holds running totals of repository contents, which are updated incrementally
+// on each index flush and recomputed from scratch during full maintenance.
+// Concurrent updates by multiple clients may cause the totals to temporarily drift.
+type Stats struct {
+	content.Totals
+
+	UpdatedAt    time.Time `json:"updatedAt"`
+	RecomputedAt time.Time `json:"recomputedAt,omitempty"`
+}
+
+// GetStats returns repository statistics or nil if they are not being tracked for the repository.
+func GetStats(ctx context.Context, rep DirectRepository) (*Stats, error) {
+	return readStats(ctx, rep.BlobReader(), rep.DeriveKey(statsKeyPurpose, statsKeySize))
+}
+
+// RecomputeStats recomputes repository statistics by iterating all contents and persists them.
+// The number of logical bytes written is preserved since it can't be determined from the index.
+func RecomputeStats(ctx context.Context, rep DirectRepositoryWriter) (*Stats, error) {
+	// flush pending contents first, so that they are not counted again when their index is flushed.
+	if err := rep.Flush(ctx); err != nil {
+		return nil, errors.Wrap(err, "error flushing repository")
+	}
+
+	totals, err := content.ComputeTotals(ctx, rep.ContentManager())
+	if err != nil {
+		return nil, errors.Wrap(err, "error computing totals")
+	}
+
+	key := rep.DeriveKey(statsKeyPurpose, statsKeySize)
+
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	s, err := readStats(ctx, rep.BlobStorage(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	if s != nil {
+		totals.LogicalBytes = s.LogicalBytes
+	}
+
+	s = &Stats{
+		Totals:       *totals,
+		UpdatedAt:    rep.Time(),
+		RecomputedAt: rep.Time(),
+	}
+
+	if err := writeStats(ctx, rep.BlobStorage(), key, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// statsUpdater applies changes in content totals to the stats blob.
+type statsUpdater struct {
+	st      blob.Storage
+	key     []byte
+	timeNow func() time.Time
+}
+
+func (u *statsUpdater) onIndexFlush(ctx context.Context, delta *content.Totals) {
+	if err := u.update(ctx, delta); err != nil {
+		log(ctx).Errorf("unable to update repository stats: %v", err)
+	}
+}
+
+func (u *statsUpdater) update(ctx context.Context, delta *content.Totals) error {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	s, err := readStats(ctx, u.st, u.key)
+	if err != nil {
+		return err
+	}
+
+	if s == nil {
+		// stats are not tracked until the baseline is computed.
+		return nil
+	}
+
+	s.Add(delta)
+	s.UpdatedAt = u.timeNow()
+
+	return writeStats(ctx, u.st, u.key, s)
+}
+
+func newStatsUpdater(st blob.Storage, masterKey, uniqueID []byte, timeNow func() time.Time) *statsUpdater {
+	return &statsUpdater{
+		st:      st,
+		key:     deriveKeyFromMasterKey(masterKey, uniqueID, statsKeyPurpose, statsKeySize),
+		timeNow: timeNow,
+	}
+}
+
+func getStatsAES256GCM(key []byte) (cipher.AEAD, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AES-256 cipher")
+	}
+
+	// nolint:wrapcheck
+	return cipher.NewGCM(c)
+}
+
+func readStats(ctx context.Context, st blob.Reader, key []byte) (*Stats, error) {
+	v, err := st.GetBlob(ctx, StatsBlobID, 0, -1)
+	if errors.Is(err, blob.ErrBlobNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading stats blob")
+	}
+
+	c, err := getStatsAES256GCM(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get cipher")
+	}
+
+	if len(v) < c.NonceSize() {
+		return nil, errors.Errorf("invalid stats blob")
+	}
+
+	j, err := c.Open(nil, v[0:c.NonceSize()], v[c.NonceSize():], statsAEADExtraData)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt stats blob")
+	}
+
+	s := &Stats{}
+	if err := json.Unmarshal(j, s); err != nil {
+		return nil, errors.Wrap(err, "malformed stats blob")
+	}
+
+	return s, nil
+}
+
+func writeStats(ctx context.Context, st blob.Storage, key []byte, s *Stats) error {
+	v, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize JSON")
+	}
+
+	c, err := getStatsAES256GCM(key)
+	if err != nil {
+		return errors.Wrap(err, "unable to get cipher")
+	}
+
+	nonce := make([]byte, c.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "unable to initialize nonce")
+	}
+
+	result := append([]byte(nil), nonce...)
+	ciphertext := c.Seal(result, nonce, v, statsAEADExtraData)
+
+	return errors.Wrap(st.PutBlob(ctx, StatsBlobID, gather.FromSlice(ciphertext)), "error writing stats blob")
+}
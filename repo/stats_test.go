@@ -0,0 +1,105 @@
+package repo_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestStats(t *testing.T) {
+	ta := faketime.NewTimeAdvance(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), 0)
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.TimeNowFunc = ta.NowFunc()
+		},
+	})
+
+	s, err := repo.GetStats(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	require.Zero(t, s.Sum().Count)
+
+	cm := env.RepositoryWriter.ContentManager()
+
+	_, err = cm.WriteContent(ctx, bytes.Repeat([]byte{1}, 1000), "")
+	require.NoError(t, err)
+
+	// duplicate content only counts towards logical bytes.
+	_, err = cm.WriteContent(ctx, bytes.Repeat([]byte{1}, 1000), "")
+	require.NoError(t, err)
+
+	cid, err := cm.WriteContent(ctx, bytes.Repeat([]byte{2}, 500), "k")
+	require.NoError(t, err)
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	s, err = repo.GetStats(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.EqualValues(t, 2500, s.LogicalBytes)
+	require.EqualValues(t, 1, s.ByPrefix[""].Count)
+	require.EqualValues(t, 1000, s.ByPrefix[""].OriginalBytes)
+	require.EqualValues(t, 1, s.ByPrefix["k"].Count)
+	require.EqualValues(t, 500, s.ByPrefix["k"].OriginalBytes)
+	require.InDelta(t, 2500.0/1500, s.DedupRatio(), 0.001)
+
+	verifyStatsMatchIndex(t, env, s)
+
+	ta.Advance(time.Minute)
+
+	require.NoError(t, cm.DeleteContent(ctx, cid))
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	s, err = repo.GetStats(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Nil(t, s.ByPrefix["k"])
+
+	verifyStatsMatchIndex(t, env, s)
+
+	// recomputing preserves logical bytes.
+	s2, err := repo.RecomputeStats(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Equal(t, s.Totals, s2.Totals)
+	require.False(t, s2.RecomputedAt.IsZero())
+}
+
+func TestStatsNotTracked(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	require.NoError(t, env.RepositoryWriter.BlobStorage().DeleteBlob(ctx, repo.StatsBlobID))
+
+	_, err := env.RepositoryWriter.ContentManager().WriteContent(ctx, []byte{1, 2, 3}, "")
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	// incremental updates don't start without a baseline.
+	s, err := repo.GetStats(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Nil(t, s)
+
+	s, err = repo.RecomputeStats(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Zero(t, s.LogicalBytes)
+
+	verifyStatsMatchIndex(t, env, s)
+}
+
+func verifyStatsMatchIndex(t *testing.T, env *repotesting.Environment, s *repo.Stats) {
+	t.Helper()
+
+	want, err := content.ComputeTotals(testlogging.Context(t), env.RepositoryWriter.ContentReader())
+	require.NoError(t, err)
+	require.Equal(t, len(want.ByPrefix), len(s.ByPrefix))
+
+	for prefix, pt := range want.ByPrefix {
+		require.Equal(t, pt, s.ByPrefix[prefix], "prefix %q", prefix)
+	}
+}
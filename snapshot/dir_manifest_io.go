@@ -0,0 +1,175 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// MinFormatVersionStreamedDirectories is the minimum repository format version that writes
+// directory manifests in the streamed format.
+const MinFormatVersionStreamedDirectories = content.MinFormatVersionStreamedDirectories
+
+const (
+	// DirManifestStreamType is the stream type of directory manifests stored as a single JSON object.
+	DirManifestStreamType = "kopia:directory"
+
+	// DirManifestStreamTypeStreamed is the stream type of directory manifests stored as a header
+	// followed by a sequence of JSON-encoded entries.
+	DirManifestStreamTypeStreamed = "kopia:directory-stream"
+)
+
+// streamedDirManifestHeader is the first JSON value of a streamed directory manifest.
+// It is followed by one JSON value for each directory entry, in the same order as in DirManifest.
+type streamedDirManifestHeader struct {
+	StreamType string               `json:"stream"`
+	Summary    *fs.DirectorySummary `json:"summary"`
+}
+
+// DirManifestEntries enumerates entries of a directory manifest in order by invoking the callback for each of them.
+type DirManifestEntries func(callback func(e *DirEntry) error) error
+
+// WriteDirManifest writes the provided directory manifest to the writer.
+func WriteDirManifest(w io.Writer, m *DirManifest, streamed bool) error {
+	m.StreamType = DirManifestStreamType
+	if streamed {
+		m.StreamType = DirManifestStreamTypeStreamed
+	}
+
+	return WriteDirManifestEntries(w, m.Summary, func(callback func(e *DirEntry) error) error {
+		for _, e := range m.Entries {
+			if err := callback(e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, streamed)
+}
+
+// WriteDirManifestEntries writes directory manifest with the provided summary to the writer, encoding entries
+// one at a time as they are produced, so that entries of the directory don't need to be held in memory.
+// When streamed is true, the manifest is written in the streamed format, which also allows it to be read
+// incrementally.
+func WriteDirManifestEntries(w io.Writer, summary *fs.DirectorySummary, entries DirManifestEntries, streamed bool) error {
+	if streamed {
+		enc := json.NewEncoder(w)
+
+		if err := enc.Encode(streamedDirManifestHeader{DirManifestStreamTypeStreamed, summary}); err != nil {
+			return errors.Wrap(err, "unable to encode directory header")
+		}
+
+		return entries(func(e *DirEntry) error {
+			return errors.Wrapf(enc.Encode(e), "unable to encode directory entry %v", e.Name)
+		})
+	}
+
+	// produce the same output as encoding DirManifest using json.Encoder, without building its Entries.
+	const legacyPrefix = `{"stream":"` + DirManifestStreamType + `","entries":`
+
+	bw := bufio.NewWriter(w)
+	count := 0
+
+	if err := entries(func(e *DirEntry) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return errors.Wrapf(err, "unable to encode directory entry %v", e.Name)
+		}
+
+		if count == 0 {
+			bw.WriteString(legacyPrefix + "[") //nolint:errcheck
+		} else {
+			bw.WriteByte(',') //nolint:errcheck
+		}
+
+		count++
+
+		_, err = bw.Write(b)
+
+		return errors.Wrap(err, "unable to write directory entry")
+	}); err != nil {
+		return err
+	}
+
+	if count == 0 {
+		// DirManifest without entries has them encoded as null.
+		bw.WriteString(legacyPrefix + "null") //nolint:errcheck
+	} else {
+		bw.WriteByte(']') //nolint:errcheck
+	}
+
+	b, err := json.Marshal(summary)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode directory summary")
+	}
+
+	bw.WriteString(`,"summary":`) //nolint:errcheck
+	bw.Write(b)                   //nolint:errcheck
+	bw.WriteString("}\n")         //nolint:errcheck
+
+	return errors.Wrap(bw.Flush(), "unable to write directory JSON")
+}
+
+// ReadDirManifest reads directory manifest in any supported format from the provided reader,
+// invokes the callback for each entry and returns directory summary.
+// When the callback is nil, entries of streamed manifests are not read at all.
+func ReadDirManifest(r io.Reader, callback func(e *DirEntry) error) (*fs.DirectorySummary, error) {
+	dec := json.NewDecoder(r)
+
+	// the first value is either the entire legacy manifest or the header of streamed manifest.
+	var hdr struct {
+		StreamType string               `json:"stream"`
+		Entries    json.RawMessage      `json:"entries"`
+		Summary    *fs.DirectorySummary `json:"summary"`
+	}
+
+	if err := dec.Decode(&hdr); err != nil {
+		return nil, errors.Wrap(err, "unable to parse directory object")
+	}
+
+	switch hdr.StreamType {
+	case DirManifestStreamType:
+		if callback == nil {
+			return hdr.Summary, nil
+		}
+
+		var entries []*DirEntry
+
+		if len(hdr.Entries) > 0 {
+			if err := json.Unmarshal(hdr.Entries, &entries); err != nil {
+				return nil, errors.Wrap(err, "unable to parse directory entries")
+			}
+		}
+
+		for _, e := range entries {
+			if err := callback(e); err != nil {
+				return nil, err
+			}
+		}
+
+		return hdr.Summary, nil
+
+	case DirManifestStreamTypeStreamed:
+		for callback != nil && dec.More() {
+			e := &DirEntry{}
+
+			if err := dec.Decode(e); err != nil {
+				return nil, errors.Wrap(err, "unable to parse directory entry")
+			}
+
+			if err := callback(e); err != nil {
+				return nil, err
+			}
+		}
+
+		return hdr.Summary, nil
+
+	default:
+		return nil, errors.Errorf("invalid directory stream type")
+	}
+}
@@ -0,0 +1,91 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestDirManifestRoundTrip(t *testing.T) {
+	for _, streamed := range []bool{false, true} {
+		m := &snapshot.DirManifest{
+			Entries: []*snapshot.DirEntry{
+				{Name: "d1", Type: snapshot.EntryTypeDirectory, ObjectID: "k123"},
+				{Name: "f1", Type: snapshot.EntryTypeFile, ObjectID: "456", FileSize: 3},
+			},
+			Summary: &fs.DirectorySummary{TotalFileCount: 1, TotalFileSize: 3},
+		}
+
+		var buf bytes.Buffer
+
+		require.NoError(t, snapshot.WriteDirManifest(&buf, m, streamed))
+
+		var names []string
+
+		summ, err := snapshot.ReadDirManifest(bytes.NewReader(buf.Bytes()), func(e *snapshot.DirEntry) error {
+			names = append(names, e.Name)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"d1", "f1"}, names)
+		require.Equal(t, m.Summary, summ)
+
+		// reading summary only does not invoke the callback.
+		summ, err = snapshot.ReadDirManifest(bytes.NewReader(buf.Bytes()), nil)
+		require.NoError(t, err)
+		require.Equal(t, m.Summary, summ)
+
+		if streamed {
+			require.Equal(t, snapshot.DirManifestStreamTypeStreamed, m.StreamType)
+			require.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 3)
+		} else {
+			require.Equal(t, snapshot.DirManifestStreamType, m.StreamType)
+		}
+	}
+}
+
+func TestReadDirManifestInvalidStreamType(t *testing.T) {
+	_, err := snapshot.ReadDirManifest(strings.NewReader(`{"stream":"foo","entries":[]}`), nil)
+	require.Error(t, err)
+
+	_, err = snapshot.ReadDirManifest(strings.NewReader(`{"stream":"kopia:directory-stream"}{"name":`), func(e *snapshot.DirEntry) error { return nil })
+	require.Error(t, err)
+}
+
+func TestWriteDirManifestEntriesLegacyFormatUnchanged(t *testing.T) {
+	for _, entries := range [][]*snapshot.DirEntry{
+		nil,
+		{
+			{Name: "d1<>", Type: snapshot.EntryTypeDirectory, ObjectID: "k123"},
+			{Name: "f1", Type: snapshot.EntryTypeFile, ObjectID: "456", FileSize: 3},
+		},
+	} {
+		m := &snapshot.DirManifest{
+			StreamType: snapshot.DirManifestStreamType,
+			Entries:    entries,
+			Summary:    &fs.DirectorySummary{TotalFileCount: 1, TotalFileSize: 3},
+		}
+
+		var want, got bytes.Buffer
+
+		require.NoError(t, json.NewEncoder(&want).Encode(m))
+		require.NoError(t, snapshot.WriteDirManifestEntries(&got, m.Summary, func(callback func(e *snapshot.DirEntry) error) error {
+			for _, e := range entries {
+				if err := callback(e); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}, false))
+
+		// directories written without streaming must not change, so that they are deduplicated with existing ones.
+		require.Equal(t, want.String(), got.String())
+	}
+}
@@ -0,0 +1,144 @@
+package snapshot
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+// LegalHoldManifestType is the value of the "type" label for legal hold manifests.
+const LegalHoldManifestType = "legal-hold"
+
+// LegalHoldSnapshotIDLabel is the label identifying the snapshot a legal hold applies to.
+//
+// Legal hold manifests intentionally don't carry username and hostname labels of the snapshot,
+// so that owners of snapshots can't add or remove holds unless explicitly granted access to
+// legal hold manifests using ACLs.
+const LegalHoldSnapshotIDLabel = "snapshotID"
+
+// ErrSnapshotUnderLegalHold is returned when attempting to delete a snapshot with active legal holds.
+// It's the same error that remote repositories return when the server refuses the deletion.
+var ErrSnapshotUnderLegalHold = repo.ErrUnderLegalHold
+
+// LegalHold prevents a snapshot from being deleted, either explicitly or by retention policy,
+// until the hold is removed.
+type LegalHold struct {
+	ID         manifest.ID `json:"-"`
+	SnapshotID manifest.ID `json:"snapshotID"`
+	Reason     string      `json:"reason,omitempty"`
+	CreatedBy  string      `json:"createdBy,omitempty"`
+	CreatedAt  time.Time   `json:"createdAt"`
+}
+
+// AddLegalHold places a legal hold on the snapshot with a given ID.
+func AddLegalHold(ctx context.Context, rep repo.RepositoryWriter, snapshotID manifest.ID, reason string) (*LegalHold, error) {
+	if _, err := LoadSnapshot(ctx, rep, snapshotID); err != nil {
+		return nil, errors.Wrapf(err, "unable to load snapshot %v", snapshotID)
+	}
+
+	h := &LegalHold{
+		SnapshotID: snapshotID,
+		Reason:     reason,
+		CreatedBy:  rep.ClientOptions().UsernameAtHost(),
+		CreatedAt:  rep.Time(),
+	}
+
+	id, err := rep.PutManifest(ctx, map[string]string{
+		typeKey:                  LegalHoldManifestType,
+		LegalHoldSnapshotIDLabel: string(snapshotID),
+	}, h)
+	if err != nil {
+		return nil, errors.Wrap(err, "error putting legal hold manifest")
+	}
+
+	h.ID = id
+
+	return h, nil
+}
+
+// ListLegalHolds returns legal holds placed on the snapshot with a given ID or all legal holds if the ID is empty.
+func ListLegalHolds(ctx context.Context, rep repo.Repository, snapshotID manifest.ID) ([]*LegalHold, error) {
+	labels := map[string]string{
+		typeKey: LegalHoldManifestType,
+	}
+
+	if snapshotID != "" {
+		labels[LegalHoldSnapshotIDLabel] = string(snapshotID)
+	}
+
+	entries, err := rep.FindManifests(ctx, labels)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find legal hold manifests")
+	}
+
+	var result []*LegalHold
+
+	for _, e := range entries {
+		h := &LegalHold{}
+
+		if _, err := rep.GetManifest(ctx, e.ID, h); err != nil {
+			return nil, errors.Wrapf(err, "unable to load legal hold %v", e.ID)
+		}
+
+		h.ID = e.ID
+		result = append(result, h)
+	}
+
+	return result, nil
+}
+
+// RemoveLegalHold removes the legal hold with a given ID.
+func RemoveLegalHold(ctx context.Context, rep repo.RepositoryWriter, holdID manifest.ID) error {
+	var h LegalHold
+
+	em, err := rep.GetManifest(ctx, holdID, &h)
+	if err != nil {
+		return errors.Wrapf(err, "unable to load legal hold %v", holdID)
+	}
+
+	if em.Labels[typeKey] != LegalHoldManifestType {
+		return errors.Errorf("manifest %v is not a legal hold", holdID)
+	}
+
+	return errors.Wrap(rep.DeleteManifest(ctx, holdID), "error deleting legal hold manifest")
+}
+
+// SnapshotsUnderLegalHold returns the set of IDs of snapshots with active legal holds.
+func SnapshotsUnderLegalHold(ctx context.Context, rep repo.Repository) (map[manifest.ID]bool, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{
+		typeKey: LegalHoldManifestType,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find legal hold manifests")
+	}
+
+	result := map[manifest.ID]bool{}
+
+	for _, e := range entries {
+		result[manifest.ID(e.Labels[LegalHoldSnapshotIDLabel])] = true
+	}
+
+	return result, nil
+}
+
+// VerifyNotUnderLegalHold returns ErrSnapshotUnderLegalHold if the manifest with a given ID
+// is a snapshot with active legal holds.
+func VerifyNotUnderLegalHold(ctx context.Context, rep repo.Repository, manifestID manifest.ID) error {
+	entries, err := rep.FindManifests(ctx, map[string]string{
+		typeKey:                  LegalHoldManifestType,
+		LegalHoldSnapshotIDLabel: string(manifestID),
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to find legal hold manifests")
+	}
+
+	if len(entries) > 0 {
+		return errors.Wrapf(ErrSnapshotUnderLegalHold, "%v has %v legal hold(s)", manifestID, len(entries))
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package snapshot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestLegalHolds(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	si := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/foo"}
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var ids []manifest.ID
+
+	for i := 0; i < 3; i++ {
+		ids = append(ids, mustSaveSnapshot(t, env.RepositoryWriter, &snapshot.Manifest{
+			Source:    si,
+			StartTime: t0.Add(time.Duration(i) * time.Hour),
+			EndTime:   t0.Add(time.Duration(i) * time.Hour).Add(time.Minute),
+			RootEntry: &snapshot.DirEntry{Type: snapshot.EntryTypeDirectory, ObjectID: "k1234"},
+		}))
+	}
+
+	_, err := snapshot.AddLegalHold(ctx, env.RepositoryWriter, "no-such-snapshot", "")
+	require.Error(t, err)
+
+	h, err := snapshot.AddLegalHold(ctx, env.RepositoryWriter, ids[0], "litigation")
+	require.NoError(t, err)
+	require.Equal(t, ids[0], h.SnapshotID)
+
+	holds, err := snapshot.ListLegalHolds(ctx, env.RepositoryWriter, ids[0])
+	require.NoError(t, err)
+	require.Len(t, holds, 1)
+	require.Equal(t, h.ID, holds[0].ID)
+	require.Equal(t, "litigation", holds[0].Reason)
+
+	holds, err = snapshot.ListLegalHolds(ctx, env.RepositoryWriter, ids[1])
+	require.NoError(t, err)
+	require.Empty(t, holds)
+
+	err = snapshot.VerifyNotUnderLegalHold(ctx, env.RepositoryWriter, ids[0])
+	require.True(t, errors.Is(err, snapshot.ErrSnapshotUnderLegalHold), "unexpected error: %v", err)
+	require.NoError(t, snapshot.VerifyNotUnderLegalHold(ctx, env.RepositoryWriter, ids[1]))
+
+	// retain only the latest snapshot, the one under legal hold is retained as well.
+	require.NoError(t, policy.SetPolicy(ctx, env.RepositoryWriter, si, &policy.Policy{
+		RetentionPolicy: policy.RetentionPolicy{
+			KeepLatest:  intPtr(1),
+			KeepHourly:  intPtr(0),
+			KeepDaily:   intPtr(0),
+			KeepWeekly:  intPtr(0),
+			KeepMonthly: intPtr(0),
+			KeepAnnual:  intPtr(0),
+		},
+	}))
+
+	deleted, err := policy.ApplyRetentionPolicy(ctx, env.RepositoryWriter, si, true)
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.Equal(t, ids[1], deleted[0].ID)
+
+	// legal holds can't be removed using IDs of other manifests.
+	require.Error(t, snapshot.RemoveLegalHold(ctx, env.RepositoryWriter, ids[0]))
+	require.NoError(t, snapshot.RemoveLegalHold(ctx, env.RepositoryWriter, h.ID))
+	require.NoError(t, snapshot.VerifyNotUnderLegalHold(ctx, env.RepositoryWriter, ids[0]))
+
+	deleted, err = policy.ApplyRetentionPolicy(ctx, env.RepositoryWriter, si, true)
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.Equal(t, ids[0], deleted[0].ID)
+}
+
+func intPtr(n int) *int {
+	return &n
+}
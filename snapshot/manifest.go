@@ -27,11 +27,18 @@ type Manifest struct {
 
 	RootEntry *DirEntry `json:"rootEntry"`
 
+	// SkippedFiles lists files that could not be read and were skipped according to the error handling policy,
+	// up to MaxSkippedFilesPerManifest entries.
+	SkippedFiles []*fs.EntryWithError `json:"skippedFiles,omitempty"`
+
 	RetentionReasons []string `json:"-"`
 
 	Tags map[string]string `json:"tags,omitempty"`
 }
 
+// MaxSkippedFilesPerManifest is the maximum number of skipped files recorded in a snapshot manifest.
+const MaxSkippedFilesPerManifest = 1000
+
 // EntryType is a type of a filesystem entry.
 type EntryType string
 
@@ -4,6 +4,8 @@
 	"path/filepath"
 	"sort"
 
+	"github.com/pkg/errors"
+
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/repo/compression"
 )
@@ -15,6 +17,10 @@ type CompressionPolicy struct {
 	NeverCompress  []string         `json:"neverCompress,omitempty"`
 	MinSize        int64            `json:"minSize,omitempty"`
 	MaxSize        int64            `json:"maxSize,omitempty"`
+
+	// ParallelCompression is the maximum number of chunks of a single file compressed in parallel,
+	// defaults to the number of CPUs, which is also the size of the shared compression pool.
+	ParallelCompression *int `json:"parallelCompression,omitempty"`
 }
 
 // CompressorForFile returns compression name to be used for compressing a given file according to policy, using attributes such as name or size.
@@ -45,6 +51,24 @@ func (p *CompressionPolicy) CompressorForFile(e fs.File) compression.Name {
 	return p.CompressorName
 }
 
+// ParallelCompressionOrDefault returns the number of chunks of a single file that can be compressed in parallel.
+func (p *CompressionPolicy) ParallelCompressionOrDefault(def int) int {
+	if p.ParallelCompression == nil {
+		return def
+	}
+
+	return *p.ParallelCompression
+}
+
+// ValidateCompressionPolicy returns an error if the compression policy is invalid.
+func ValidateCompressionPolicy(p CompressionPolicy) error {
+	if v := p.ParallelCompression; v != nil && *v < 1 {
+		return errors.Errorf("invalid compression policy: number of chunks compressed in parallel must be at least 1, got %v", *v)
+	}
+
+	return nil
+}
+
 // Merge applies default values from the provided policy.
 func (p *CompressionPolicy) Merge(src CompressionPolicy) {
 	if p.CompressorName == "" {
@@ -59,6 +83,10 @@ func (p *CompressionPolicy) Merge(src CompressionPolicy) {
 		p.MaxSize = src.MaxSize
 	}
 
+	if p.ParallelCompression == nil {
+		p.ParallelCompression = src.ParallelCompression
+	}
+
 	p.OnlyCompress = mergeStrings(p.OnlyCompress, src.OnlyCompress)
 	p.NeverCompress = mergeStrings(p.NeverCompress, src.NeverCompress)
 }
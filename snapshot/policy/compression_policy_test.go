@@ -0,0 +1,18 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateCompressionPolicy(t *testing.T) {
+	require.NoError(t, ValidateCompressionPolicy(CompressionPolicy{}))
+	require.NoError(t, ValidateCompressionPolicy(CompressionPolicy{ParallelCompression: intPtr(1)}))
+	require.NoError(t, ValidateCompressionPolicy(CompressionPolicy{ParallelCompression: intPtr(8)}))
+
+	for _, v := range []int{0, -1, -100} {
+		require.Error(t, ValidateCompressionPolicy(CompressionPolicy{ParallelCompression: intPtr(v)}), v)
+		require.Error(t, ValidatePolicy(&Policy{CompressionPolicy: CompressionPolicy{ParallelCompression: intPtr(v)}}), v)
+	}
+}
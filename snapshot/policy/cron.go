@@ -0,0 +1,227 @@
+package policy
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maximum number of days to look ahead when searching for the next time matching cron expression.
+const cronMaxLookaheadDays = 5 * 366
+
+// cronTimeZonePrefixes are prefixes that specify time zone of a cron expression, for example "CRON_TZ=Europe/Berlin 30 2 * * *".
+var cronTimeZonePrefixes = []string{"CRON_TZ=", "TZ="}
+
+// cronField describes the valid range and names of values of a single cron expression field.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // optional names of values, starting at min
+}
+
+// nolint:gomnd
+var (
+	cronMinute     = cronField{name: "minute", min: 0, max: 59}
+	cronHour       = cronField{name: "hour", min: 0, max: 23}
+	cronDayOfMonth = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth      = cronField{name: "month", min: 1, max: 12, names: []string{
+		"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec",
+	}}
+	cronDayOfWeek = cronField{name: "day of week", min: 0, max: 7, names: []string{
+		"sun", "mon", "tue", "wed", "thu", "fri", "sat",
+	}}
+)
+
+// cronSchedule is a parsed cron expression, each field is a bit mask of matching values.
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+
+	// when both day of month and day of week are restricted, a day matches if either of them matches.
+	dayOfMonthStar, dayOfWeekStar bool
+
+	location *time.Location
+}
+
+// parseCronExpression parses the standard 5-field cron expression (minute, hour, day of month, month, day of week)
+// optionally prefixed with "CRON_TZ=<zone>" which specifies time zone, local time zone is used otherwise.
+func parseCronExpression(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+
+	s := &cronSchedule{location: time.Local}
+
+	if len(fields) > 0 {
+		for _, prefix := range cronTimeZonePrefixes {
+			if !strings.HasPrefix(fields[0], prefix) {
+				continue
+			}
+
+			loc, err := time.LoadLocation(strings.TrimPrefix(fields[0], prefix))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid time zone in %q", expr)
+			}
+
+			s.location = loc
+			fields = fields[1:]
+
+			break
+		}
+	}
+
+	if len(fields) != 5 { //nolint:gomnd
+		return nil, errors.Errorf("invalid cron expression %q, must have 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+
+	var err error
+
+	if s.minute, _, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, err
+	}
+
+	if s.hour, _, err = cronHour.parse(fields[1]); err != nil {
+		return nil, err
+	}
+
+	if s.dayOfMonth, s.dayOfMonthStar, err = cronDayOfMonth.parse(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if s.month, _, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, err
+	}
+
+	if s.dayOfWeek, s.dayOfWeekStar, err = cronDayOfWeek.parse(fields[4]); err != nil {
+		return nil, err
+	}
+
+	// both 0 and 7 mean Sunday.
+	if s.dayOfWeek&(1<<7) != 0 { //nolint:gomnd
+		s.dayOfWeek |= 1
+	}
+
+	return s, nil
+}
+
+// parse parses comma-separated list of values, ranges and steps and returns the bit mask of matching values
+// and whether the field matches all values.
+func (f cronField) parse(s string) (mask uint64, star bool, err error) {
+	for _, part := range strings.Split(s, ",") {
+		rangePart, step := part, 1
+
+		if p := strings.Index(part, "/"); p >= 0 {
+			rangePart = part[0:p]
+
+			step, err = strconv.Atoi(part[p+1:])
+			if err != nil || step <= 0 {
+				return 0, false, errors.Errorf("invalid step in %v %q", f.name, part)
+			}
+		}
+
+		lo, hi := f.min, f.max
+
+		switch {
+		case rangePart == "*":
+			star = star || step == 1
+
+		case strings.Contains(rangePart, "-"):
+			p := strings.Index(rangePart, "-")
+
+			if lo, err = f.parseValue(rangePart[0:p]); err != nil {
+				return 0, false, err
+			}
+
+			if hi, err = f.parseValue(rangePart[p+1:]); err != nil {
+				return 0, false, err
+			}
+
+			if lo > hi {
+				return 0, false, errors.Errorf("invalid range in %v %q", f.name, part)
+			}
+
+		default:
+			if lo, err = f.parseValue(rangePart); err != nil {
+				return 0, false, err
+			}
+
+			if step == 1 {
+				hi = lo
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+
+	return mask, star, nil
+}
+
+func (f cronField) parseValue(s string) (int, error) {
+	for i, n := range f.names {
+		if strings.EqualFold(s, n) {
+			return f.min + i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, errors.Errorf("invalid %v %q, must be between %v and %v", f.name, s, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	if s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := s.dayOfMonth&(1<<uint(t.Day())) != 0
+	dowMatch := s.dayOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if s.dayOfMonthStar || s.dayOfWeekStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// next returns the earliest time matching the schedule that is strictly after the provided time.
+func (s *cronSchedule) next(after time.Time) (time.Time, bool) {
+	after = after.In(s.location)
+	day := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, s.location)
+
+	for i := 0; i < cronMaxLookaheadDays; i++ {
+		d := day.AddDate(0, 0, i)
+
+		if !s.matchesDay(d) {
+			continue
+		}
+
+		for h := 0; h < 24; h++ {
+			if s.hour&(1<<uint(h)) == 0 {
+				continue
+			}
+
+			for m := 0; m < 60; m++ {
+				if s.minute&(1<<uint(m)) == 0 {
+					continue
+				}
+
+				if t := time.Date(d.Year(), d.Month(), d.Day(), h, m, 0, 0, s.location); t.After(after) {
+					return t, true
+				}
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// ValidateCronExpression returns an error if the provided cron expression is invalid.
+func ValidateCronExpression(expr string) error {
+	_, err := parseCronExpression(expr)
+
+	return err
+}
@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronExpressionErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * * foo",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"CRON_TZ=No/SuchZone * * * * *",
+	} {
+		require.Error(t, ValidateCronExpression(expr), expr)
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// Friday.
+	base := time.Date(2021, 3, 5, 10, 15, 0, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"CRON_TZ=UTC * * * * *", time.Date(2021, 3, 5, 10, 16, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC 15 10 * * *", time.Date(2021, 3, 6, 10, 15, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC */20 * * * *", time.Date(2021, 3, 5, 10, 20, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC 30 2 * * 1-5", time.Date(2021, 3, 8, 2, 30, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC 0 12 * * sat", time.Date(2021, 3, 6, 12, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC 0 0 * * 7", time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC 0 0 1 jan,jul *", time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)},
+		// day of month and day of week are OR-ed when both are restricted.
+		{"CRON_TZ=UTC 0 0 20 * mon", time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=UTC 0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 10:15 UTC is 05:15 in New York.
+		{"TZ=America/New_York 0 9 * * *", time.Date(2021, 3, 5, 9, 0, 0, 0, ny)},
+	}
+
+	for _, tc := range cases {
+		cs, err := parseCronExpression(tc.expr)
+		require.NoError(t, err, tc.expr)
+
+		got, ok := cs.next(base)
+		require.True(t, ok, tc.expr)
+		require.True(t, tc.want.Equal(got), "%v: got %v, want %v", tc.expr, got, tc.want)
+	}
+
+	cs, err := parseCronExpression("0 0 31 2 *")
+	require.NoError(t, err)
+
+	_, ok := cs.next(base)
+	require.False(t, ok)
+}
+
+func TestSchedulingPolicyNextSnapshotTime(t *testing.T) {
+	previous := time.Date(2021, 3, 5, 10, 15, 0, 0, time.UTC)
+	now := previous.Add(time.Minute)
+
+	_, ok := (&SchedulingPolicy{}).NextSnapshotTime(previous, now)
+	require.False(t, ok)
+
+	p := &SchedulingPolicy{
+		Cron: []string{"CRON_TZ=UTC 30 2 * * 1-5", "CRON_TZ=UTC 0 12 * * sat"},
+	}
+
+	got, ok := p.NextSnapshotTime(previous, now)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2021, 3, 6, 12, 0, 0, 0, time.UTC), got.UTC())
+
+	// earliest of all schedules is used.
+	p.SetInterval(time.Hour)
+
+	got, ok = p.NextSnapshotTime(previous, now)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2021, 3, 5, 11, 0, 0, 0, time.UTC), got.UTC())
+
+	merged := MergePolicies([]*Policy{
+		{SchedulingPolicy: SchedulingPolicy{Cron: []string{"0 12 * * sat"}}},
+		{SchedulingPolicy: SchedulingPolicy{Cron: []string{"0 12 * * sat", "30 2 * * 1-5"}}},
+	})
+	require.Equal(t, []string{"0 12 * * sat", "30 2 * * 1-5"}, merged.SchedulingPolicy.Cron)
+}
@@ -1,10 +1,35 @@
 package policy
 
+// FileErrorAction specifies what happens to the snapshot when a file can't be read.
+type FileErrorAction string
+
+// Supported file error actions.
+const (
+	// FileErrorActionFail reports the error, which causes the snapshot to fail.
+	FileErrorActionFail FileErrorAction = "fail"
+
+	// FileErrorActionSkip skips the file and records it in the snapshot manifest.
+	FileErrorActionSkip FileErrorAction = "skip"
+)
+
+// SupportedFileErrorActions is a list of supported file error actions.
+var SupportedFileErrorActions = []FileErrorAction{
+	FileErrorActionFail,
+	FileErrorActionSkip,
+}
+
 // ErrorHandlingPolicy controls error hadnling behavior when taking snapshots.
 type ErrorHandlingPolicy struct {
 	// IgnoreFileErrors controls whether or not snapshot operation should fail when a file throws an error on being read
 	IgnoreFileErrors *bool `json:"ignoreFileErrors,omitempty"`
 
+	// FileErrorAction controls what happens when a file can't be read after all retries have been exhausted.
+	// When set, it takes precedence over IgnoreFileErrors.
+	FileErrorAction FileErrorAction `json:"fileErrorAction,omitempty"`
+
+	// FileErrorRetries is the number of times reading a file is retried with exponential backoff before FileErrorAction is applied.
+	FileErrorRetries *int `json:"fileErrorRetries,omitempty"`
+
 	// IgnoreDirectoryErrors controls whether or not snapshot operation should fail when a directory throws an error on being read or opened
 	IgnoreDirectoryErrors *bool `json:"ignoreDirectoryErrors,omitempty"`
 
@@ -14,8 +39,18 @@ type ErrorHandlingPolicy struct {
 
 // Merge applies default values from the provided policy.
 func (p *ErrorHandlingPolicy) Merge(src ErrorHandlingPolicy) {
-	if p.IgnoreFileErrors == nil && src.IgnoreFileErrors != nil {
-		p.IgnoreFileErrors = newBool(*src.IgnoreFileErrors)
+	// FileErrorAction and IgnoreFileErrors both define the action, so they are inherited together.
+	if p.FileErrorAction == "" && p.IgnoreFileErrors == nil {
+		p.FileErrorAction = src.FileErrorAction
+
+		if src.IgnoreFileErrors != nil {
+			p.IgnoreFileErrors = newBool(*src.IgnoreFileErrors)
+		}
+	}
+
+	if p.FileErrorRetries == nil && src.FileErrorRetries != nil {
+		v := *src.FileErrorRetries
+		p.FileErrorRetries = &v
 	}
 
 	if p.IgnoreDirectoryErrors == nil && src.IgnoreDirectoryErrors != nil {
@@ -37,6 +72,34 @@ func (p *ErrorHandlingPolicy) IgnoreFileErrorsOrDefault(def bool) bool {
 	return *p.IgnoreFileErrors
 }
 
+// FileErrorActionOrDefault returns the action to take when a file can't be read, which is
+// derived from IgnoreFileErrors if FileErrorAction is not set, and returns the passed default if neither is set.
+func (p *ErrorHandlingPolicy) FileErrorActionOrDefault(def FileErrorAction) FileErrorAction {
+	if p.FileErrorAction != "" {
+		return p.FileErrorAction
+	}
+
+	if p.IgnoreFileErrors == nil {
+		return def
+	}
+
+	if *p.IgnoreFileErrors {
+		return FileErrorActionSkip
+	}
+
+	return FileErrorActionFail
+}
+
+// FileErrorRetriesOrDefault returns the number of file read retries if it is set,
+// and returns the passed default if not.
+func (p *ErrorHandlingPolicy) FileErrorRetriesOrDefault(def int) int {
+	if p.FileErrorRetries == nil {
+		return def
+	}
+
+	return *p.FileErrorRetries
+}
+
 // IgnoreDirectoryErrorsOrDefault returns the ignore-directory-error setting if it is set,
 // and returns the passed default if not.
 func (p *ErrorHandlingPolicy) IgnoreDirectoryErrorsOrDefault(def bool) bool {
@@ -60,6 +123,7 @@ func (p *ErrorHandlingPolicy) IgnoreUnknownTypesOrDefault(def bool) bool {
 // defaultErrorHandlingPolicy is the default error handling policy.
 var defaultErrorHandlingPolicy = ErrorHandlingPolicy{
 	IgnoreFileErrors:      newBool(false),
+	FileErrorRetries:      newInt(0),
 	IgnoreDirectoryErrors: newBool(false),
 	IgnoreUnknownTypes:    newBool(true),
 }
@@ -67,3 +131,7 @@ func (p *ErrorHandlingPolicy) IgnoreUnknownTypesOrDefault(def bool) bool {
 func newBool(b bool) *bool {
 	return &b
 }
+
+func newInt(v int) *int {
+	return &v
+}
@@ -194,6 +194,61 @@ func TestErrorHandlingPolicy_IgnoreFileErrorsOrDefault(t *testing.T) {
 	}
 }
 
+func TestErrorHandlingPolicy_FileErrorActionOrDefault(t *testing.T) {
+	for _, tt := range []struct {
+		name             string
+		fileErrorAction  FileErrorAction
+		ignoreFileErrors *bool
+		want             FileErrorAction
+	}{
+		{
+			name: "nothing set, returns default",
+			want: FileErrorActionFail,
+		},
+		{
+			name:             "ignoreFileErrors is true",
+			ignoreFileErrors: newBool(true),
+			want:             FileErrorActionSkip,
+		},
+		{
+			name:             "ignoreFileErrors is false",
+			ignoreFileErrors: newBool(false),
+			want:             FileErrorActionFail,
+		},
+		{
+			name:             "fileErrorAction takes precedence",
+			fileErrorAction:  FileErrorActionFail,
+			ignoreFileErrors: newBool(true),
+			want:             FileErrorActionFail,
+		},
+	} {
+		t.Log(tt.name)
+
+		p := &ErrorHandlingPolicy{
+			FileErrorAction:  tt.fileErrorAction,
+			IgnoreFileErrors: tt.ignoreFileErrors,
+		}
+
+		if got := p.FileErrorActionOrDefault(FileErrorActionFail); got != tt.want {
+			t.Errorf("ErrorHandlingPolicy.FileErrorActionOrDefault() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestErrorHandlingPolicyMerge_FileErrorAction(t *testing.T) {
+	// child sets the legacy flag, so action defined by the parent must not be inherited.
+	p := ErrorHandlingPolicy{IgnoreFileErrors: newBool(true)}
+	p.Merge(ErrorHandlingPolicy{FileErrorAction: FileErrorActionFail, FileErrorRetries: newInt(3)})
+
+	if got, want := p.FileErrorActionOrDefault(FileErrorActionFail), FileErrorActionSkip; got != want {
+		t.Errorf("unexpected file error action %v, want %v", got, want)
+	}
+
+	if got, want := p.FileErrorRetriesOrDefault(0), 3; got != want {
+		t.Errorf("unexpected file error retries %v, want %v", got, want)
+	}
+}
+
 func TestErrorHandlingPolicy_IgnoreDirectoryErrorsOrDefault(t *testing.T) {
 	for _, tt := range []struct {
 		name            string
@@ -7,9 +7,13 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
 )
 
+// legalHoldRetentionReason is the retention reason of snapshots under legal hold.
+const legalHoldRetentionReason = "legal-hold"
+
 // ApplyRetentionPolicy applies retention policy to a given source by deleting expired snapshots.
 func ApplyRetentionPolicy(ctx context.Context, rep repo.RepositoryWriter, sourceInfo snapshot.SourceInfo, reallyDelete bool) ([]*snapshot.Manifest, error) {
 	snapshots, err := snapshot.ListSnapshots(ctx, rep, sourceInfo)
@@ -22,22 +26,39 @@ func ApplyRetentionPolicy(ctx context.Context, rep repo.RepositoryWriter, source
 		return nil, errors.Wrap(err, "unable to compute snapshots to delete")
 	}
 
-	if reallyDelete {
-		for _, it := range toDelete {
-			if err := rep.DeleteManifest(ctx, it.ID); err != nil {
-				return toDelete, errors.Wrapf(err, "error deleting manifest %v", it.ID)
+	if !reallyDelete {
+		return toDelete, nil
+	}
+
+	var deleted []*snapshot.Manifest
+
+	for _, it := range toDelete {
+		if err := rep.DeleteManifest(ctx, it.ID); err != nil {
+			// holds may not be visible to the user, in which case the server refuses to delete held snapshots.
+			if errors.Is(err, snapshot.ErrSnapshotUnderLegalHold) {
+				log(ctx).Debugf("  not deleting %v: %v", it.StartTime, err)
+				continue
 			}
+
+			return deleted, errors.Wrapf(err, "error deleting manifest %v", it.ID)
 		}
+
+		deleted = append(deleted, it)
 	}
 
-	return toDelete, nil
+	return deleted, nil
 }
 
 func getExpiredSnapshots(ctx context.Context, rep repo.Repository, snapshots []*snapshot.Manifest) ([]*snapshot.Manifest, error) {
 	var toDelete []*snapshot.Manifest
 
+	held, err := snapshot.SnapshotsUnderLegalHold(ctx, rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list legal holds")
+	}
+
 	for _, snapshotGroup := range snapshot.GroupBySource(snapshots) {
-		td, err := getExpiredSnapshotsForSource(ctx, rep, snapshotGroup)
+		td, err := getExpiredSnapshotsForSource(ctx, rep, snapshotGroup, held)
 		if err != nil {
 			return nil, err
 		}
@@ -48,7 +69,7 @@ func getExpiredSnapshots(ctx context.Context, rep repo.Repository, snapshots []*
 	return toDelete, nil
 }
 
-func getExpiredSnapshotsForSource(ctx context.Context, rep repo.Repository, snapshots []*snapshot.Manifest) ([]*snapshot.Manifest, error) {
+func getExpiredSnapshotsForSource(ctx context.Context, rep repo.Repository, snapshots []*snapshot.Manifest, held map[manifest.ID]bool) ([]*snapshot.Manifest, error) {
 	src := snapshots[0].Source
 
 	pol, _, err := GetEffectivePolicy(ctx, rep, src)
@@ -58,6 +79,14 @@ func getExpiredSnapshotsForSource(ctx context.Context, rep repo.Repository, snap
 
 	pol.RetentionPolicy.ComputeRetentionReasons(snapshots)
 
+	if maxSize := pol.RetentionPolicy.MaxRetainedSize; maxSize > 0 {
+		if err := applyMaxRetainedSize(ctx, rep, snapshots, maxSize); err != nil {
+			return nil, err
+		}
+	}
+
+	retainSnapshotsUnderLegalHold(snapshots, held)
+
 	var toDelete []*snapshot.Manifest
 
 	for _, s := range snapshots {
@@ -71,3 +100,13 @@ func getExpiredSnapshotsForSource(ctx context.Context, rep repo.Repository, snap
 
 	return toDelete, nil
 }
+
+// retainSnapshotsUnderLegalHold adds retention reason to snapshots under legal hold,
+// which are never deleted, regardless of the policy.
+func retainSnapshotsUnderLegalHold(snapshots []*snapshot.Manifest, held map[manifest.ID]bool) {
+	for _, s := range snapshots {
+		if held[s.ID] {
+			s.RetentionReasons = append(s.RetentionReasons, legalHoldRetentionReason)
+		}
+	}
+}
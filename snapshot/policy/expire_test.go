@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// repositoryWithHiddenLegalHolds simulates a remote user that can't read legal holds,
+// for whom the server refuses to delete held snapshots.
+type repositoryWithHiddenLegalHolds struct {
+	repo.RepositoryWriter
+}
+
+func (r repositoryWithHiddenLegalHolds) FindManifests(ctx context.Context, labels map[string]string) ([]*manifest.EntryMetadata, error) {
+	if labels[manifest.TypeLabelKey] == snapshot.LegalHoldManifestType {
+		return nil, nil
+	}
+
+	// nolint:wrapcheck
+	return r.RepositoryWriter.FindManifests(ctx, labels)
+}
+
+func (r repositoryWithHiddenLegalHolds) DeleteManifest(ctx context.Context, id manifest.ID) error {
+	if err := snapshot.VerifyNotUnderLegalHold(ctx, r.RepositoryWriter, id); err != nil {
+		return err
+	}
+
+	// nolint:wrapcheck
+	return r.RepositoryWriter.DeleteManifest(ctx, id)
+}
+
+func TestApplyRetentionPolicySkipsRefusedLegalHolds(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	si := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/foo"}
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fileA := writeRandomFile(ctx, t, env.RepositoryWriter, "a")
+
+	writeTestSnapshot(ctx, t, env.RepositoryWriter, si, t0, fileA)
+	writeTestSnapshot(ctx, t, env.RepositoryWriter, si, t0.AddDate(0, 0, 1), fileA)
+	writeTestSnapshot(ctx, t, env.RepositoryWriter, si, t0.AddDate(0, 0, 2), fileA)
+
+	snapshots, err := snapshot.ListSnapshots(ctx, env.RepositoryWriter, si)
+	require.NoError(t, err)
+
+	snapshots = snapshot.SortByTime(snapshots, false)
+
+	_, err = snapshot.AddLegalHold(ctx, env.RepositoryWriter, snapshots[0].ID, "some-reason")
+	require.NoError(t, err)
+
+	// only the latest snapshot is retained by policy.
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, si, &Policy{
+		RetentionPolicy: RetentionPolicy{MaxRetainedSize: 1},
+	}))
+
+	deleted, err := ApplyRetentionPolicy(ctx, repositoryWithHiddenLegalHolds{env.RepositoryWriter}, si, true)
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.Equal(t, snapshots[1].ID, deleted[0].ID)
+
+	remaining, err := snapshot.ListSnapshots(ctx, env.RepositoryWriter, si)
+	require.NoError(t, err)
+	require.Len(t, remaining, 2)
+}
@@ -27,6 +27,7 @@ type Policy struct {
 	ErrorHandlingPolicy ErrorHandlingPolicy `json:"errorHandling,omitempty"`
 	SchedulingPolicy    SchedulingPolicy    `json:"scheduling,omitempty"`
 	CompressionPolicy   CompressionPolicy   `json:"compression,omitempty"`
+	UploadPolicy        UploadPolicy        `json:"upload,omitempty"`
 	Actions             ActionsPolicy       `json:"actions"`
 	NoParent            bool                `json:"noParent,omitempty"`
 }
@@ -72,6 +73,7 @@ func MergePolicies(policies []*Policy) *Policy {
 		merged.ErrorHandlingPolicy.Merge(p.ErrorHandlingPolicy)
 		merged.SchedulingPolicy.Merge(p.SchedulingPolicy)
 		merged.CompressionPolicy.Merge(p.CompressionPolicy)
+		merged.UploadPolicy.Merge(p.UploadPolicy)
 		merged.Actions.Merge(p.Actions)
 	}
 
@@ -81,6 +83,7 @@ func MergePolicies(policies []*Policy) *Policy {
 	merged.ErrorHandlingPolicy.Merge(defaultErrorHandlingPolicy)
 	merged.SchedulingPolicy.Merge(defaultSchedulingPolicy)
 	merged.CompressionPolicy.Merge(defaultCompressionPolicy)
+	merged.UploadPolicy.Merge(defaultUploadPolicy)
 	merged.Actions.Merge(defaultActionsPolicy)
 
 	if len(policies) > 0 {
@@ -91,8 +94,16 @@ func MergePolicies(policies []*Policy) *Policy {
 }
 
 // ValidatePolicy returns error if the given policy is invalid.
-// Currently, only SchedulingPolicy is validated.
+// Currently, only SchedulingPolicy, RetentionPolicy and CompressionPolicy are validated.
 func ValidatePolicy(pol *Policy) error {
+	if err := ValidateRetentionPolicy(pol.RetentionPolicy); err != nil {
+		return err
+	}
+
+	if err := ValidateCompressionPolicy(pol.CompressionPolicy); err != nil {
+		return err
+	}
+
 	return ValidateSchedulingPolicy(pol.SchedulingPolicy)
 }
 
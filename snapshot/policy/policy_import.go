@@ -0,0 +1,257 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// Document contains policies of multiple targets, it is used to export and import policies in bulk.
+type Document struct {
+	Policies []*TargetPolicy `json:"policies"`
+}
+
+// TargetPolicy is a policy along with the target it is defined on.
+type TargetPolicy struct {
+	Target snapshot.SourceInfo `json:"target"`
+	Policy *Policy             `json:"policy"`
+}
+
+// ImportAction describes the change to a single target made by ImportPolicies.
+type ImportAction string
+
+// Supported import actions.
+const (
+	ImportActionCreate    ImportAction = "create"
+	ImportActionUpdate    ImportAction = "update"
+	ImportActionDelete    ImportAction = "delete"
+	ImportActionUnchanged ImportAction = "unchanged"
+)
+
+// ImportChange describes the change to the policy of a single target.
+type ImportChange struct {
+	Target snapshot.SourceInfo `json:"target"`
+	Action ImportAction        `json:"action"`
+
+	// Differences lists changed policy fields in the form 'field: old -> new'.
+	Differences []string `json:"differences,omitempty"`
+}
+
+// ImportOptions controls the behavior of ImportPolicies.
+type ImportOptions struct {
+	// DeleteOthers causes policies of targets not present in the document to be deleted.
+	DeleteOthers bool
+
+	// DryRun computes changes without applying them.
+	DryRun bool
+}
+
+// ExportPolicies returns a document containing all policies defined in the repository, sorted by target.
+func ExportPolicies(ctx context.Context, rep repo.Repository) (*Document, error) {
+	policies, err := ListPolicies(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+
+	for _, pol := range policies {
+		doc.Policies = append(doc.Policies, &TargetPolicy{
+			Target: pol.Target(),
+			Policy: pol,
+		})
+	}
+
+	sort.Slice(doc.Policies, func(i, j int) bool {
+		return doc.Policies[i].Target.String() < doc.Policies[j].Target.String()
+	})
+
+	return doc, nil
+}
+
+// ValidateDocument ensures that all policies in the document are valid and each target is specified at most once.
+func ValidateDocument(doc *Document) error {
+	seen := map[snapshot.SourceInfo]bool{}
+
+	for _, tp := range doc.Policies {
+		if tp.Policy == nil {
+			return errors.Errorf("missing policy for %v", tp.Target)
+		}
+
+		if seen[tp.Target] {
+			return errors.Errorf("duplicate policy for %v", tp.Target)
+		}
+
+		seen[tp.Target] = true
+
+		if tp.Target.Path != "" {
+			if err := validatePolicyPath(tp.Target.Path); err != nil {
+				return errors.Wrapf(err, "invalid policy path for %v", tp.Target)
+			}
+		}
+
+		if err := ValidatePolicy(tp.Policy); err != nil {
+			return errors.Wrapf(err, "invalid policy for %v", tp.Target)
+		}
+	}
+
+	return nil
+}
+
+// ImportPolicies applies policies from the provided document to the repository and returns the list
+// of changes sorted by target. The document is validated before any changes are made.
+func ImportPolicies(ctx context.Context, rep repo.RepositoryWriter, doc *Document, opt ImportOptions) ([]ImportChange, error) {
+	if err := ValidateDocument(doc); err != nil {
+		return nil, err
+	}
+
+	existing, err := ListPolicies(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	existingByTarget := map[snapshot.SourceInfo]*Policy{}
+	for _, pol := range existing {
+		existingByTarget[pol.Target()] = pol
+	}
+
+	var changes []ImportChange
+
+	for _, tp := range doc.Policies {
+		ch := ImportChange{Target: tp.Target}
+
+		old := existingByTarget[tp.Target]
+		delete(existingByTarget, tp.Target)
+
+		if old == nil {
+			ch.Action = ImportActionCreate
+			ch.Differences = Diff(&Policy{}, tp.Policy)
+		} else {
+			ch.Differences = Diff(old, tp.Policy)
+			ch.Action = ImportActionUpdate
+
+			if len(ch.Differences) == 0 {
+				ch.Action = ImportActionUnchanged
+			}
+		}
+
+		if ch.Action != ImportActionUnchanged && !opt.DryRun {
+			if err := SetPolicy(ctx, rep, tp.Target, tp.Policy); err != nil {
+				return changes, errors.Wrapf(err, "unable to set policy for %v", tp.Target)
+			}
+		}
+
+		changes = append(changes, ch)
+	}
+
+	if opt.DeleteOthers {
+		for target := range existingByTarget {
+			if !opt.DryRun {
+				if err := RemovePolicy(ctx, rep, target); err != nil {
+					return changes, errors.Wrapf(err, "unable to remove policy for %v", target)
+				}
+			}
+
+			changes = append(changes, ImportChange{Target: target, Action: ImportActionDelete})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Target.String() < changes[j].Target.String()
+	})
+
+	return changes, nil
+}
+
+// Diff returns the list of fields that differ between the two policies in the form 'field: old -> new',
+// sorted by field name.
+func Diff(oldPolicy, newPolicy *Policy) []string {
+	oldFields := flattenPolicy(oldPolicy)
+	newFields := flattenPolicy(newPolicy)
+
+	keys := map[string]bool{}
+
+	for k := range oldFields {
+		keys[k] = true
+	}
+
+	for k := range newFields {
+		keys[k] = true
+	}
+
+	var result []string
+
+	for k := range keys {
+		o, hasOld := oldFields[k]
+		n, hasNew := newFields[k]
+
+		if hasOld && hasNew && o == n {
+			continue
+		}
+
+		if !hasOld {
+			o = "(unset)"
+		}
+
+		if !hasNew {
+			n = "(unset)"
+		}
+
+		result = append(result, fmt.Sprintf("%v: %v -> %v", k, o, n))
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// flattenPolicy returns JSON representations of leaf values of the policy keyed by their dotted JSON paths.
+func flattenPolicy(p *Policy) map[string]string {
+	result := map[string]string{}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return result
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return result
+	}
+
+	flattenJSON("", v, result)
+
+	return result
+}
+
+func flattenJSON(prefix string, v interface{}, result map[string]string) {
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, mv := range m {
+			name := k
+			if prefix != "" {
+				name = prefix + "." + k
+			}
+
+			flattenJSON(name, mv, result)
+		}
+
+		return
+	}
+
+	if v == nil || prefix == "" {
+		return
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	result[prefix] = string(b)
+}
@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestExportImportPolicies(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	hostA := snapshot.SourceInfo{Host: "host-a"}
+	pathB := snapshot.SourceInfo{Host: "host-b", UserName: "user", Path: "/some/path"}
+
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, hostA, &Policy{
+		RetentionPolicy: RetentionPolicy{KeepDaily: intPtr(10)},
+	}))
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, pathB, &Policy{
+		RetentionPolicy: RetentionPolicy{KeepLatest: intPtr(3)},
+	}))
+
+	doc, err := ExportPolicies(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, doc.Policies, 2)
+	require.Equal(t, hostA, doc.Policies[0].Target)
+	require.Equal(t, pathB, doc.Policies[1].Target)
+
+	// importing exported policies does not change anything.
+	changes, err := ImportPolicies(ctx, env.RepositoryWriter, doc, ImportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []ImportChange{
+		{Target: hostA, Action: ImportActionUnchanged},
+		{Target: pathB, Action: ImportActionUnchanged},
+	}, changes)
+
+	global := snapshot.SourceInfo{}
+
+	newDoc := &Document{
+		Policies: []*TargetPolicy{
+			{Target: hostA, Policy: &Policy{RetentionPolicy: RetentionPolicy{KeepDaily: intPtr(20)}}},
+			{Target: global, Policy: &Policy{RetentionPolicy: RetentionPolicy{KeepHourly: intPtr(5)}}},
+		},
+	}
+
+	want := []ImportChange{
+		{Target: global, Action: ImportActionCreate, Differences: []string{"retention.keepHourly: (unset) -> 5"}},
+		{Target: hostA, Action: ImportActionUpdate, Differences: []string{"retention.keepDaily: 10 -> 20"}},
+		{Target: pathB, Action: ImportActionDelete},
+	}
+
+	// dry run reports changes without making them.
+	changes, err = ImportPolicies(ctx, env.RepositoryWriter, newDoc, ImportOptions{DeleteOthers: true, DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, want, changes)
+
+	p, err := GetDefinedPolicy(ctx, env.RepositoryWriter, hostA)
+	require.NoError(t, err)
+	require.Equal(t, 10, *p.RetentionPolicy.KeepDaily)
+
+	changes, err = ImportPolicies(ctx, env.RepositoryWriter, newDoc, ImportOptions{DeleteOthers: true})
+	require.NoError(t, err)
+	require.Equal(t, want, changes)
+
+	p, err = GetDefinedPolicy(ctx, env.RepositoryWriter, hostA)
+	require.NoError(t, err)
+	require.Equal(t, 20, *p.RetentionPolicy.KeepDaily)
+
+	_, err = GetDefinedPolicy(ctx, env.RepositoryWriter, pathB)
+	require.ErrorIs(t, err, ErrPolicyNotFound)
+
+	p, err = GetDefinedPolicy(ctx, env.RepositoryWriter, global)
+	require.NoError(t, err)
+	require.Equal(t, 5, *p.RetentionPolicy.KeepHourly)
+}
+
+func TestImportPoliciesValidation(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	hostA := snapshot.SourceInfo{Host: "host-a"}
+
+	for _, doc := range []*Document{
+		{Policies: []*TargetPolicy{{Target: hostA}}},
+		{Policies: []*TargetPolicy{{Target: hostA, Policy: &Policy{}}, {Target: hostA, Policy: &Policy{}}}},
+		{Policies: []*TargetPolicy{{Target: snapshot.SourceInfo{Host: "h", UserName: "u", Path: "/some/path/"}, Policy: &Policy{}}}},
+		{Policies: []*TargetPolicy{{Target: hostA, Policy: &Policy{SchedulingPolicy: SchedulingPolicy{Cron: []string{"invalid"}}}}}},
+	} {
+		_, err := ImportPolicies(ctx, env.RepositoryWriter, doc, ImportOptions{})
+		require.Error(t, err)
+	}
+
+	// nothing was written.
+	doc, err := ExportPolicies(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Empty(t, doc.Policies)
+}
@@ -25,6 +25,9 @@
 	PolicyTypeHost   = "host"
 	PolicyTypeUser   = "user"
 
+	// PolicyTypeUserAllHosts is the type of policies applying to all sources of a user on any host.
+	PolicyTypeUserAllHosts = "user-all-hosts"
+
 	PathLabel     = snapshot.PathLabel
 	UsernameLabel = snapshot.UsernameLabel
 	HostnameLabel = snapshot.HostnameLabel
@@ -39,6 +42,8 @@
 
 // GetEffectivePolicy calculates effective snapshot policy for a given source by combining the source-specifc policy (if any)
 // with parent policies. The source must contain a path.
+// Policies are applied in the following order: path policies from the most specific path, 'user@host', '@host',
+// 'user@' (user on all hosts) and global policy.
 // Returns the effective policies and all source policies that contributed to that (most specific first).
 func GetEffectivePolicy(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo) (effective *Policy, sources []*Policy, e error) {
 	var md []*manifest.EntryMetadata
@@ -76,6 +81,16 @@ func GetEffectivePolicy(ctx context.Context, rep repo.Repository, si snapshot.So
 
 	md = append(md, hostManifests...)
 
+	// Try user-level policy applying to all hosts.
+	if si.UserName != "" {
+		userManifests, err := rep.FindManifests(ctx, labelsForSource(snapshot.SourceInfo{UserName: si.UserName}))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to find user-level manifest")
+		}
+
+		md = append(md, userManifests...)
+	}
+
 	// Global policy.
 	globalManifests, err := rep.FindManifests(ctx, labelsForSource(GlobalPolicySourceInfo))
 	if err != nil {
@@ -300,6 +315,12 @@ func labelsForSource(si snapshot.SourceInfo) map[string]string {
 			HostnameLabel:   si.Host,
 			PathLabel:       si.Path,
 		}
+	case si.UserName != "" && si.Host == "":
+		return map[string]string{
+			typeKey:         ManifestType,
+			PolicyTypeLabel: PolicyTypeUserAllHosts,
+			UsernameLabel:   si.UserName,
+		}
 	case si.UserName != "":
 		return map[string]string{
 			typeKey:         ManifestType,
@@ -134,6 +134,48 @@ func TestPolicyManagerInheritanceTest(t *testing.T) {
 	}
 }
 
+func TestPolicyManagerUserLevelPolicy(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	userLevel := snapshot.SourceInfo{UserName: "myuser"}
+
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, userLevel, &Policy{
+		RetentionPolicy: RetentionPolicy{
+			KeepDaily:  intPtr(33),
+			KeepHourly: intPtr(11),
+		},
+	}))
+
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, snapshot.SourceInfo{Host: "host-a"}, &Policy{
+		RetentionPolicy: RetentionPolicy{
+			KeepDaily: intPtr(44),
+		},
+	}))
+
+	pol, err := GetDefinedPolicy(ctx, env.RepositoryWriter, userLevel)
+	require.NoError(t, err)
+	require.Equal(t, userLevel, pol.Target())
+	require.Equal(t, PolicyTypeUserAllHosts, pol.Labels[PolicyTypeLabel])
+
+	// user-level policy applies on any host, below the host-level policy.
+	pol, src, err := GetEffectivePolicy(ctx, env.RepositoryWriter, snapshot.SourceInfo{UserName: "myuser", Host: "host-a", Path: "/some/path"})
+	require.NoError(t, err)
+	require.Equal(t, 44, *pol.RetentionPolicy.KeepDaily)
+	require.Equal(t, 11, *pol.RetentionPolicy.KeepHourly)
+	require.Len(t, src, 2)
+	require.Equal(t, snapshot.SourceInfo{Host: "host-a"}, src[0].Target())
+	require.Equal(t, userLevel, src[1].Target())
+
+	pol, _, err = GetEffectivePolicy(ctx, env.RepositoryWriter, snapshot.SourceInfo{UserName: "myuser", Host: "host-b", Path: "/some/path"})
+	require.NoError(t, err)
+	require.Equal(t, 33, *pol.RetentionPolicy.KeepDaily)
+
+	// other users are not affected.
+	pol, _, err = GetEffectivePolicy(ctx, env.RepositoryWriter, snapshot.SourceInfo{UserName: "otheruser", Host: "host-b", Path: "/some/path"})
+	require.NoError(t, err)
+	require.Equal(t, *DefaultPolicy.RetentionPolicy.KeepDaily, *pol.RetentionPolicy.KeepDaily)
+}
+
 func clonePolicy(t *testing.T, p *Policy) *Policy {
 	t.Helper()
 
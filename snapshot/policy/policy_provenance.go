@@ -0,0 +1,72 @@
+package policy
+
+import (
+	"context"
+	"sort"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// FieldProvenance describes where a single field of the effective policy was defined.
+type FieldProvenance struct {
+	// Field is the dotted JSON path of the field, such as 'retention.keepDaily'.
+	Field string `json:"field"`
+
+	// Value is the JSON representation of the effective value.
+	Value string `json:"value"`
+
+	// Target is the target whose policy defined the field or nil if the default value is used.
+	Target *snapshot.SourceInfo `json:"target,omitempty"`
+}
+
+// Provenance returns, for every field of the effective policy, the target in the inheritance chain that defined it.
+// The sources must be ordered from the most specific, as returned by GetEffectivePolicy.
+func Provenance(effective *Policy, sources []*Policy) []FieldProvenance {
+	var sourceFields []map[string]string
+
+	for _, p := range sources {
+		sourceFields = append(sourceFields, flattenPolicy(p))
+
+		if p.NoParent {
+			break
+		}
+	}
+
+	var result []FieldProvenance
+
+	for field, value := range flattenPolicy(effective) {
+		fp := FieldProvenance{
+			Field: field,
+			Value: value,
+		}
+
+		for i, sf := range sourceFields {
+			if _, ok := sf[field]; ok {
+				target := sources[i].Target()
+				fp.Target = &target
+
+				break
+			}
+		}
+
+		result = append(result, fp)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Field < result[j].Field
+	})
+
+	return result
+}
+
+// GetEffectivePolicyWithProvenance calculates effective snapshot policy for a given source along with
+// provenance of each of its fields.
+func GetEffectivePolicyWithProvenance(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo) (*Policy, []FieldProvenance, error) {
+	effective, sources, err := GetEffectivePolicy(ctx, rep, si)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return effective, Provenance(effective, sources), nil
+}
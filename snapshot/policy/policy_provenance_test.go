@@ -0,0 +1,59 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestPolicyProvenance(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	hostA := snapshot.SourceInfo{Host: "host-a"}
+	target := snapshot.SourceInfo{Host: "host-a", UserName: "user", Path: "/some/path"}
+
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, GlobalPolicySourceInfo, &Policy{
+		RetentionPolicy: RetentionPolicy{KeepDaily: intPtr(7), KeepHourly: intPtr(24)},
+	}))
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, hostA, &Policy{
+		RetentionPolicy: RetentionPolicy{KeepDaily: intPtr(14)},
+	}))
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, target, &Policy{
+		RetentionPolicy: RetentionPolicy{KeepLatest: intPtr(3)},
+	}))
+
+	_, prov, err := GetEffectivePolicyWithProvenance(ctx, env.RepositoryWriter, target)
+	require.NoError(t, err)
+
+	byField := map[string]FieldProvenance{}
+	for _, fp := range prov {
+		byField[fp.Field] = fp
+	}
+
+	require.Equal(t, "14", byField["retention.keepDaily"].Value)
+	require.Equal(t, &hostA, byField["retention.keepDaily"].Target)
+	require.Equal(t, &GlobalPolicySourceInfo, byField["retention.keepHourly"].Target)
+	require.Equal(t, &target, byField["retention.keepLatest"].Target)
+
+	// fields not defined by any policy come from defaults.
+	require.Contains(t, byField, "retention.keepMonthly")
+	require.Nil(t, byField["retention.keepMonthly"].Target)
+
+	// noParent stops inheritance.
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, target, &Policy{
+		RetentionPolicy: RetentionPolicy{KeepLatest: intPtr(3)},
+		NoParent:        true,
+	}))
+
+	_, prov, err = GetEffectivePolicyWithProvenance(ctx, env.RepositoryWriter, target)
+	require.NoError(t, err)
+
+	for _, fp := range prov {
+		if fp.Target != nil {
+			require.Equal(t, target, *fp.Target, fp.Field)
+		}
+	}
+}
@@ -11,6 +11,7 @@
 	CompressionPolicy:   defaultCompressionPolicy,
 	ErrorHandlingPolicy: defaultErrorHandlingPolicy,
 	SchedulingPolicy:    defaultSchedulingPolicy,
+	UploadPolicy:        defaultUploadPolicy,
 	Actions:             defaultActionsPolicy,
 }
 
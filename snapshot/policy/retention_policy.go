@@ -17,12 +17,20 @@
 
 // RetentionPolicy describes snapshot retention policy.
 type RetentionPolicy struct {
+	// Template is the name of retention template that provides values for counts
+	// not explicitly specified in this policy.
+	Template string `json:"template,omitempty"`
+
 	KeepLatest  *int `json:"keepLatest,omitempty"`
 	KeepHourly  *int `json:"keepHourly,omitempty"`
 	KeepDaily   *int `json:"keepDaily,omitempty"`
 	KeepWeekly  *int `json:"keepWeekly,omitempty"`
 	KeepMonthly *int `json:"keepMonthly,omitempty"`
 	KeepAnnual  *int `json:"keepAnnual,omitempty"`
+
+	// MaxRetainedSize limits the total storage used by retained snapshots of a source,
+	// older snapshots that don't fit within the limit are expired.
+	MaxRetainedSize int64 `json:"maxRetainedSize,omitempty"`
 }
 
 // ComputeRetentionReasons computes the reasons why each snapshot is retained, based on
@@ -194,6 +202,15 @@ func hoursAgo(base time.Time, n int) time.Time {
 
 // Merge applies default values from the provided policy.
 func (r *RetentionPolicy) Merge(src RetentionPolicy) {
+	if t, ok := retentionTemplates[src.Template]; ok {
+		// values from the template are treated as if they were defined in the source policy.
+		src.Merge(t)
+	}
+
+	if r.Template == "" {
+		r.Template = src.Template
+	}
+
 	if r.KeepLatest == nil {
 		r.KeepLatest = src.KeepLatest
 	}
@@ -217,4 +234,8 @@ func (r *RetentionPolicy) Merge(src RetentionPolicy) {
 	if r.KeepAnnual == nil {
 		r.KeepAnnual = src.KeepAnnual
 	}
+
+	if r.MaxRetainedSize == 0 {
+		r.MaxRetainedSize = src.MaxRetainedSize
+	}
 }
@@ -0,0 +1,170 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// retainedSizeAccountant computes the storage used by a set of snapshots, counting data shared
+// between snapshots only once.
+//
+// When connected directly to the repository, sizes are computed as the total packed (compressed and encrypted)
+// length of unique contents, otherwise the total size of unique files and directories is used.
+type retainedSizeAccountant struct {
+	rep         repo.Repository
+	direct      repo.DirectRepository
+	seenObjects map[object.ID]bool
+	seenContent map[content.ID]bool
+}
+
+func newRetainedSizeAccountant(rep repo.Repository) *retainedSizeAccountant {
+	dr, _ := rep.(repo.DirectRepository)
+
+	return &retainedSizeAccountant{
+		rep:         rep,
+		direct:      dr,
+		seenObjects: map[object.ID]bool{},
+		seenContent: map[content.ID]bool{},
+	}
+}
+
+// addSnapshot returns the number of bytes that a given snapshot adds on top of the snapshots added previously.
+func (a *retainedSizeAccountant) addSnapshot(ctx context.Context, m *snapshot.Manifest) (int64, error) {
+	if m.RootEntry == nil {
+		return 0, nil
+	}
+
+	return a.addEntry(ctx, m.RootEntry)
+}
+
+func (a *retainedSizeAccountant) addEntry(ctx context.Context, e *snapshot.DirEntry) (int64, error) {
+	if e.ObjectID == "" || a.seenObjects[e.ObjectID] {
+		return 0, nil
+	}
+
+	a.seenObjects[e.ObjectID] = true
+
+	total, err := a.objectSize(ctx, e)
+	if err != nil {
+		return 0, err
+	}
+
+	if e.Type != snapshot.EntryTypeDirectory {
+		return total, nil
+	}
+
+	entries, err := a.readDirectory(ctx, e.ObjectID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, child := range entries {
+		n, err := a.addEntry(ctx, child)
+		if err != nil {
+			return 0, err
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+func (a *retainedSizeAccountant) objectSize(ctx context.Context, e *snapshot.DirEntry) (int64, error) {
+	if a.direct == nil {
+		return e.FileSize, nil
+	}
+
+	contentIDs, err := a.rep.VerifyObject(ctx, e.ObjectID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error verifying %v", e.ObjectID)
+	}
+
+	var total int64
+
+	for _, cid := range contentIDs {
+		if a.seenContent[cid] {
+			continue
+		}
+
+		a.seenContent[cid] = true
+
+		ci, err := a.direct.ContentReader().ContentInfo(ctx, cid)
+		if err != nil {
+			return 0, errors.Wrapf(err, "error getting content info for %v", cid)
+		}
+
+		total += int64(ci.GetPackedLength())
+	}
+
+	return total, nil
+}
+
+func (a *retainedSizeAccountant) readDirectory(ctx context.Context, oid object.ID) ([]*snapshot.DirEntry, error) {
+	r, err := a.rep.OpenObject(ctx, oid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open directory %v", oid)
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	var entries []*snapshot.DirEntry
+
+	if _, err := snapshot.ReadDirManifest(r, func(e *snapshot.DirEntry) error {
+		entries = append(entries, e)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse directory %v", oid)
+	}
+
+	return entries, nil
+}
+
+// applyMaxRetainedSize removes retention reasons from complete snapshots that don't fit within the
+// provided size budget, starting with the newest snapshot. The most recent complete snapshot is always retained.
+func applyMaxRetainedSize(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest, maxSize int64) error {
+	a := newRetainedSizeAccountant(rep)
+
+	var (
+		total       int64
+		exceeded    bool
+		foundLatest bool
+	)
+
+	for _, s := range snapshot.SortByTime(manifests, true) {
+		if s.IncompleteReason != "" || len(s.RetentionReasons) == 0 {
+			continue
+		}
+
+		if exceeded {
+			s.RetentionReasons = nil
+			continue
+		}
+
+		n, err := a.addSnapshot(ctx, s)
+		if err != nil {
+			return errors.Wrapf(err, "unable to compute size of snapshot %v", s.ID)
+		}
+
+		total += n
+
+		if total > maxSize && foundLatest {
+			log(ctx).Debugf("  snapshot %v exceeds retained size limit (%v > %v)", s.StartTime, total, maxSize)
+
+			exceeded = true
+			s.RetentionReasons = nil
+
+			continue
+		}
+
+		foundLatest = true
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+const retentionTestFileSize = 100000
+
+func TestMaxRetainedSize(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	si := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/foo"}
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fileA := writeRandomFile(ctx, t, env.RepositoryWriter, "a")
+	fileB := writeRandomFile(ctx, t, env.RepositoryWriter, "b")
+	fileC := writeRandomFile(ctx, t, env.RepositoryWriter, "c")
+	fileD := writeRandomFile(ctx, t, env.RepositoryWriter, "d")
+
+	writeTestSnapshot(ctx, t, env.RepositoryWriter, si, t0, fileA)
+	writeTestSnapshot(ctx, t, env.RepositoryWriter, si, t0.AddDate(0, 0, 1), fileA, fileB)
+	writeTestSnapshot(ctx, t, env.RepositoryWriter, si, t0.AddDate(0, 0, 2), fileA, fileB, fileC)
+
+	cases := []struct {
+		maxSize     int64
+		wantExpired int
+	}{
+		{0, 0},
+		// contents shared between snapshots are counted once.
+		{3.5 * retentionTestFileSize, 0},
+		{2.5 * retentionTestFileSize, 2},
+		// latest snapshot is always retained.
+		{1, 2},
+	}
+
+	for _, tc := range cases {
+		require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, si, &Policy{
+			RetentionPolicy: RetentionPolicy{MaxRetainedSize: tc.maxSize},
+		}))
+
+		expired, err := ApplyRetentionPolicy(ctx, env.RepositoryWriter, si, false)
+		require.NoError(t, err)
+		require.Len(t, expired, tc.wantExpired, "maxSize: %v", tc.maxSize)
+	}
+
+	// newest snapshot with no shared contents, older snapshots don't fit anymore.
+	writeTestSnapshot(ctx, t, env.RepositoryWriter, si, t0.AddDate(0, 0, 3), fileD)
+
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, si, &Policy{
+		RetentionPolicy: RetentionPolicy{MaxRetainedSize: 3.5 * retentionTestFileSize},
+	}))
+
+	expired, err := ApplyRetentionPolicy(ctx, env.RepositoryWriter, si, true)
+	require.NoError(t, err)
+	require.Len(t, expired, 3)
+
+	remaining, err := snapshot.ListSnapshots(ctx, env.RepositoryWriter, si)
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	require.Equal(t, t0.AddDate(0, 0, 3), remaining[0].StartTime.UTC())
+}
+
+func writeRandomFile(ctx context.Context, t *testing.T, rep repo.RepositoryWriter, name string) *snapshot.DirEntry {
+	t.Helper()
+
+	data := make([]byte, retentionTestFileSize)
+
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	return &snapshot.DirEntry{
+		Name:     name,
+		Type:     snapshot.EntryTypeFile,
+		FileSize: int64(len(data)),
+		ObjectID: writeTestObject(ctx, t, rep, data, ""),
+	}
+}
+
+func writeTestSnapshot(ctx context.Context, t *testing.T, rep repo.RepositoryWriter, si snapshot.SourceInfo, startTime time.Time, entries ...*snapshot.DirEntry) {
+	t.Helper()
+
+	b, err := json.Marshal(&snapshot.DirManifest{StreamType: "kopia:directory", Entries: entries})
+	require.NoError(t, err)
+
+	_, err = snapshot.SaveSnapshot(ctx, rep, &snapshot.Manifest{
+		Source:    si,
+		StartTime: startTime,
+		EndTime:   startTime.Add(time.Minute),
+		RootEntry: &snapshot.DirEntry{
+			Type:     snapshot.EntryTypeDirectory,
+			ObjectID: writeTestObject(ctx, t, rep, b, "k"),
+		},
+	})
+	require.NoError(t, err)
+}
+
+func writeTestObject(ctx context.Context, t *testing.T, rep repo.RepositoryWriter, data []byte, prefix content.ID) object.ID {
+	t.Helper()
+
+	w := rep.NewObjectWriter(ctx, object.WriterOptions{Prefix: prefix})
+	defer w.Close()
+
+	_, err := w.Write(data)
+	require.NoError(t, err)
+
+	oid, err := w.Result()
+	require.NoError(t, err)
+
+	return oid
+}
@@ -0,0 +1,170 @@
+package policy
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// retentionTemplates are named retention presets that can be selected using RetentionPolicy.Template.
+// Templates specify all retention counts, so that no values are inherited from parent policies.
+var retentionTemplates = map[string]RetentionPolicy{
+	// the built-in default retention.
+	"default": defaultRetentionPolicy,
+
+	// grandfather-father-son: daily, weekly and monthly backups, with few annual ones.
+	"gfs": {
+		KeepLatest:  intPtr(1),
+		KeepHourly:  intPtr(0),
+		KeepDaily:   intPtr(7),  //nolint:gomnd
+		KeepWeekly:  intPtr(4),  //nolint:gomnd
+		KeepMonthly: intPtr(12), //nolint:gomnd
+		KeepAnnual:  intPtr(3),  //nolint:gomnd
+	},
+
+	// grandfather-father-son with longer history.
+	"gfs-extended": {
+		KeepLatest:  intPtr(3),  //nolint:gomnd
+		KeepHourly:  intPtr(24), //nolint:gomnd
+		KeepDaily:   intPtr(14), //nolint:gomnd
+		KeepWeekly:  intPtr(8),  //nolint:gomnd
+		KeepMonthly: intPtr(24), //nolint:gomnd
+		KeepAnnual:  intPtr(10), //nolint:gomnd
+	},
+
+	// only a few most recent snapshots.
+	"minimal": {
+		KeepLatest:  intPtr(3), //nolint:gomnd
+		KeepHourly:  intPtr(0),
+		KeepDaily:   intPtr(0),
+		KeepWeekly:  intPtr(0),
+		KeepMonthly: intPtr(0),
+		KeepAnnual:  intPtr(0),
+	},
+}
+
+// RetentionTemplateNames returns sorted names of all retention templates.
+func RetentionTemplateNames() []string {
+	var result []string
+
+	for k := range retentionTemplates {
+		result = append(result, k)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// RetentionTemplate returns the retention policy defined by the template with the provided name.
+func RetentionTemplate(name string) (RetentionPolicy, bool) {
+	rp, ok := retentionTemplates[name]
+
+	return rp, ok
+}
+
+// ValidateRetentionPolicy returns an error if the retention policy refers to an unknown template.
+func ValidateRetentionPolicy(p RetentionPolicy) error {
+	if p.Template == "" {
+		return nil
+	}
+
+	if _, ok := retentionTemplates[p.Template]; !ok {
+		return errors.Errorf("unknown retention template %q, must be one of %v", p.Template, RetentionTemplateNames())
+	}
+
+	return nil
+}
+
+// RetentionPreviewEntry describes whether a single snapshot would be kept by a retention policy.
+type RetentionPreviewEntry struct {
+	ID        manifest.ID `json:"id"`
+	StartTime time.Time   `json:"startTime"`
+	Keep      bool        `json:"keep"`
+	Reasons   []string    `json:"reasons,omitempty"`
+}
+
+// RetentionPreview describes the outcome of applying a retention template to existing snapshots of a source.
+type RetentionPreview struct {
+	Template    string                   `json:"template"`
+	Retention   RetentionPolicy          `json:"retention"`
+	KeepCount   int                      `json:"keep"`
+	DeleteCount int                      `json:"delete"`
+	Snapshots   []*RetentionPreviewEntry `json:"snapshots"`
+}
+
+// PreviewRetentionTemplates computes which existing snapshots of the provided source would be kept
+// and deleted by each of the provided retention templates (all templates if none are provided).
+// Snapshots are not modified.
+func PreviewRetentionTemplates(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo, templates ...string) ([]*RetentionPreview, error) {
+	if len(templates) == 0 {
+		templates = RetentionTemplateNames()
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, rep, si)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing snapshots")
+	}
+
+	held, err := snapshot.SnapshotsUnderLegalHold(ctx, rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list legal holds")
+	}
+
+	var result []*RetentionPreview
+
+	for _, name := range templates {
+		rp, ok := retentionTemplates[name]
+		if !ok {
+			return nil, errors.Errorf("unknown retention template %q", name)
+		}
+
+		result = append(result, previewRetention(name, rp, snapshots, held))
+	}
+
+	return result, nil
+}
+
+func previewRetention(name string, rp RetentionPolicy, snapshots []*snapshot.Manifest, held map[manifest.ID]bool) *RetentionPreview {
+	// compute retention reasons on copies, so that the original manifests are not modified.
+	var copies []*snapshot.Manifest
+
+	for _, m := range snapshots {
+		c := *m
+		copies = append(copies, &c)
+	}
+
+	rp.ComputeRetentionReasons(copies)
+	retainSnapshotsUnderLegalHold(copies, held)
+
+	p := &RetentionPreview{
+		Template:  name,
+		Retention: rp,
+		Snapshots: []*RetentionPreviewEntry{},
+	}
+
+	for _, m := range snapshot.SortByTime(copies, true) {
+		e := &RetentionPreviewEntry{
+			ID:        m.ID,
+			StartTime: m.StartTime,
+			Keep:      len(m.RetentionReasons) > 0,
+			Reasons:   m.RetentionReasons,
+		}
+
+		if e.Keep {
+			p.KeepCount++
+		} else {
+			p.DeleteCount++
+		}
+
+		p.Snapshots = append(p.Snapshots, e)
+	}
+
+	return p
+}
@@ -0,0 +1,87 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestRetentionTemplateMerge(t *testing.T) {
+	gfs, ok := RetentionTemplate("gfs")
+	require.True(t, ok)
+
+	// template provides values for counts that are not explicitly set.
+	merged := MergePolicies([]*Policy{
+		{RetentionPolicy: RetentionPolicy{Template: "gfs", KeepDaily: intPtr(30)}},
+		{RetentionPolicy: RetentionPolicy{KeepMonthly: intPtr(99), KeepHourly: intPtr(5)}},
+	})
+
+	require.Equal(t, "gfs", merged.RetentionPolicy.Template)
+	require.Equal(t, 30, *merged.RetentionPolicy.KeepDaily)
+	require.Equal(t, *gfs.KeepMonthly, *merged.RetentionPolicy.KeepMonthly)
+	require.Equal(t, *gfs.KeepHourly, *merged.RetentionPolicy.KeepHourly)
+
+	// template defined in a parent policy doesn't override values defined in a child.
+	merged = MergePolicies([]*Policy{
+		{RetentionPolicy: RetentionPolicy{KeepMonthly: intPtr(99)}},
+		{RetentionPolicy: RetentionPolicy{Template: "minimal"}},
+	})
+
+	require.Equal(t, 99, *merged.RetentionPolicy.KeepMonthly)
+	require.Equal(t, 0, *merged.RetentionPolicy.KeepDaily)
+
+	require.NoError(t, ValidatePolicy(&Policy{RetentionPolicy: RetentionPolicy{Template: "gfs-extended"}}))
+	require.Error(t, ValidatePolicy(&Policy{RetentionPolicy: RetentionPolicy{Template: "no-such-template"}}))
+}
+
+func TestPreviewRetentionTemplates(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	si := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/foo"}
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 10 daily snapshots.
+	for i := 0; i < 10; i++ {
+		_, err := snapshot.SaveSnapshot(ctx, env.RepositoryWriter, &snapshot.Manifest{
+			Source:    si,
+			StartTime: t0.AddDate(0, 0, i),
+			EndTime:   t0.AddDate(0, 0, i).Add(time.Minute),
+			RootEntry: &snapshot.DirEntry{Type: snapshot.EntryTypeDirectory, ObjectID: "k1234"},
+		})
+		require.NoError(t, err)
+	}
+
+	previews, err := PreviewRetentionTemplates(ctx, env.RepositoryWriter, si, "minimal", "gfs", "default")
+	require.NoError(t, err)
+	require.Len(t, previews, 3)
+
+	cases := map[string]int{
+		"minimal": 3,
+		// weekly, monthly and annual snapshots are among the 7 daily ones.
+		"gfs":     7,
+		"default": 10,
+	}
+
+	for _, p := range previews {
+		require.Equal(t, cases[p.Template], p.KeepCount, p.Template)
+		require.Equal(t, 10-cases[p.Template], p.DeleteCount, p.Template)
+		require.Len(t, p.Snapshots, 10)
+		require.True(t, p.Snapshots[0].Keep)
+		require.Equal(t, t0.AddDate(0, 0, 9), p.Snapshots[0].StartTime.UTC())
+	}
+
+	// previews don't modify snapshots.
+	snapshots, err := snapshot.ListSnapshots(ctx, env.RepositoryWriter, si)
+	require.NoError(t, err)
+
+	for _, s := range snapshots {
+		require.Empty(t, s.RetentionReasons)
+	}
+
+	_, err = PreviewRetentionTemplates(ctx, env.RepositoryWriter, si, "no-such-template")
+	require.Error(t, err)
+}
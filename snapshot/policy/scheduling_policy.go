@@ -57,6 +57,7 @@ func SortAndDedupeTimesOfDay(tod []TimeOfDay) []TimeOfDay {
 type SchedulingPolicy struct {
 	IntervalSeconds int64       `json:"intervalSeconds,omitempty"`
 	TimesOfDay      []TimeOfDay `json:"timeOfDay,omitempty"`
+	Cron            []string    `json:"cron,omitempty"`
 	Manual          bool        `json:"manual,omitempty"`
 }
 
@@ -70,6 +71,68 @@ func (p *SchedulingPolicy) SetInterval(d time.Duration) {
 	p.IntervalSeconds = int64(d.Seconds())
 }
 
+// NextSnapshotTime computes the time of the next scheduled snapshot given the start time of the
+// previous snapshot and the current time. Returns false if snapshots are not scheduled.
+func (p *SchedulingPolicy) NextSnapshotTime(previousSnapshotTime, now time.Time) (time.Time, bool) {
+	var (
+		nextSnapshotTime time.Time
+		ok               bool
+	)
+
+	consider := func(t time.Time) {
+		if !ok || t.Before(nextSnapshotTime) {
+			nextSnapshotTime = t
+			ok = true
+		}
+	}
+
+	// compute next snapshot time based on interval
+	if interval := p.Interval(); interval != 0 {
+		consider(previousSnapshotTime.Add(interval).Truncate(interval))
+	}
+
+	for _, tod := range p.TimesOfDay {
+		nowLocalTime := now.Local()
+		localSnapshotTime := time.Date(nowLocalTime.Year(), nowLocalTime.Month(), nowLocalTime.Day(), tod.Hour, tod.Minute, 0, 0, time.Local)
+
+		if tod.Hour < nowLocalTime.Hour() || (tod.Hour == nowLocalTime.Hour() && tod.Minute < nowLocalTime.Minute()) {
+			localSnapshotTime = localSnapshotTime.AddDate(0, 0, 1)
+		}
+
+		consider(localSnapshotTime)
+	}
+
+	// cron-based schedules are computed relative to the previous snapshot, so that a missed
+	// scheduled snapshot is taken as soon as possible.
+	for _, expr := range p.Cron {
+		cs, err := parseCronExpression(expr)
+		if err != nil {
+			continue
+		}
+
+		if t, found := cs.next(previousSnapshotTime); found {
+			consider(t)
+		}
+	}
+
+	return nextSnapshotTime, ok
+}
+
+// SortAndDedupeCronExpressions sorts the slice of cron expressions and removes duplicates.
+func SortAndDedupeCronExpressions(exprs []string) []string {
+	var result []string
+
+	sort.Strings(exprs)
+
+	for i, e := range exprs {
+		if i == 0 || exprs[i-1] != e {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
 // Merge applies default values from the provided policy.
 func (p *SchedulingPolicy) Merge(src SchedulingPolicy) {
 	if p.IntervalSeconds == 0 {
@@ -79,6 +142,9 @@ func (p *SchedulingPolicy) Merge(src SchedulingPolicy) {
 	p.TimesOfDay = SortAndDedupeTimesOfDay(
 		append(append([]TimeOfDay(nil), src.TimesOfDay...), p.TimesOfDay...))
 
+	p.Cron = SortAndDedupeCronExpressions(
+		append(append([]string(nil), src.Cron...), p.Cron...))
+
 	if !p.Manual {
 		p.Manual = src.Manual
 	}
@@ -110,12 +176,19 @@ func SetManual(ctx context.Context, rep repo.RepositoryWriter, sourceInfo snapsh
 	return nil
 }
 
-// ValidateSchedulingPolicy returns an error if manual field is set along with scheduling fields.
+// ValidateSchedulingPolicy returns an error if manual field is set along with scheduling fields
+// or when cron expressions are invalid.
 func ValidateSchedulingPolicy(p SchedulingPolicy) error {
 	if p.Manual && !reflect.DeepEqual(p, SchedulingPolicy{Manual: true}) {
 		return errors.New("invalid scheduling policy: manual cannot be combined with other scheduling policies")
 	}
 
+	for _, expr := range p.Cron {
+		if err := ValidateCronExpression(expr); err != nil {
+			return errors.Wrap(err, "invalid scheduling policy")
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,17 @@
+package policy
+
+// UploadPolicy describes policy to apply when uploading snapshot data.
+type UploadPolicy struct {
+	// MaxUploadSpeedBytesPerSecond limits the rate at which file contents are read and uploaded,
+	// the limit is shared by all files in the directory tree where it applies.
+	MaxUploadSpeedBytesPerSecond int64 `json:"maxUploadSpeedBytesPerSecond,omitempty"`
+}
+
+// Merge applies default values from the provided policy.
+func (p *UploadPolicy) Merge(src UploadPolicy) {
+	if p.MaxUploadSpeedBytesPerSecond == 0 {
+		p.MaxUploadSpeedBytesPerSecond = src.MaxUploadSpeedBytesPerSecond
+	}
+}
+
+var defaultUploadPolicy = UploadPolicy{}
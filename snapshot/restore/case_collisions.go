@@ -0,0 +1,179 @@
+package restore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// CaseCollisionAction specifies how to handle entries in the same directory whose names differ
+// only by case and would overwrite each other when restored to a case-insensitive filesystem.
+type CaseCollisionAction string
+
+// Supported case collision actions.
+const (
+	// CaseCollisionNone disables case collision detection.
+	CaseCollisionNone CaseCollisionAction = ""
+
+	// CaseCollisionRename restores colliding entries under unique names, e.g. "readme (2).txt".
+	CaseCollisionRename CaseCollisionAction = "rename"
+
+	// CaseCollisionSkip restores only the first of the colliding entries.
+	CaseCollisionSkip CaseCollisionAction = "skip"
+
+	// CaseCollisionFail fails the restore of a directory that contains colliding entries.
+	CaseCollisionFail CaseCollisionAction = "fail"
+)
+
+// foldCase returns the name used to compare entry names on case-insensitive filesystems.
+func foldCase(name string) string {
+	return strings.ToLower(name)
+}
+
+// CaseCollisions returns groups of names of the provided entries that differ only by case.
+// Names in each group are in the order of the provided entries.
+func CaseCollisions(entries fs.Entries) [][]string {
+	byFolded := map[string][]string{}
+
+	var order []string
+
+	for _, e := range entries {
+		k := foldCase(e.Name())
+		if byFolded[k] == nil {
+			order = append(order, k)
+		}
+
+		byFolded[k] = append(byFolded[k], e.Name())
+	}
+
+	var result [][]string
+
+	for _, k := range order {
+		if names := byFolded[k]; len(names) > 1 {
+			result = append(result, names)
+		}
+	}
+
+	return result
+}
+
+// restoredEntry is a directory entry with the name under which it will be restored.
+type restoredEntry struct {
+	entry fs.Entry
+	name  string
+}
+
+// resolveCaseCollisions returns entries to restore along with their target names, after applying the provided
+// case collision action to the entries whose names differ only by case.
+// The first of the colliding entries is always restored under its original name.
+func resolveCaseCollisions(entries fs.Entries, action CaseCollisionAction) (result []restoredEntry, collisions int, err error) {
+	used := map[string]bool{}
+
+	if action != CaseCollisionNone {
+		for _, e := range entries {
+			used[foldCase(e.Name())] = true
+		}
+	}
+
+	restored := map[string]bool{}
+
+	for _, e := range entries {
+		k := foldCase(e.Name())
+
+		if action == CaseCollisionNone || !restored[k] {
+			restored[k] = true
+
+			result = append(result, restoredEntry{e, e.Name()})
+
+			continue
+		}
+
+		collisions++
+
+		switch action {
+		case CaseCollisionRename:
+			n := uniqueCaseInsensitiveName(e.Name(), used)
+			used[foldCase(n)] = true
+
+			result = append(result, restoredEntry{e, n})
+
+		case CaseCollisionSkip:
+			continue
+
+		case CaseCollisionFail:
+			return nil, collisions, errors.Errorf("%q collides with another entry on case-insensitive filesystems", e.Name())
+
+		default:
+			return nil, collisions, errors.Errorf("unsupported case collision action %q", action)
+		}
+	}
+
+	return result, collisions, nil
+}
+
+// uniqueCaseInsensitiveName returns name in the form "base (N).ext" which is not among the used names.
+func uniqueCaseInsensitiveName(name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 2; ; i++ {
+		n := fmt.Sprintf("%v (%v)%v", base, i, ext)
+		if !used[foldCase(n)] {
+			return n
+		}
+	}
+}
+
+// IsCaseInsensitiveFilesystem returns true if the filesystem at the provided local path (or its nearest existing parent)
+// treats names that differ only by case as the same. When this cannot be determined, the default for the current
+// operating system is returned.
+func IsCaseInsensitiveFilesystem(path string) bool {
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		if result, ok := probeCaseInsensitive(p); ok {
+			return result
+		}
+
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+
+	return runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+}
+
+// probeCaseInsensitive checks whether the existing path can also be accessed using its name with swapped case.
+func probeCaseInsensitive(path string) (result, ok bool) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return false, false
+	}
+
+	dir, name := filepath.Split(path)
+
+	swapped := strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+
+		return unicode.ToUpper(r)
+	}, name)
+
+	if swapped == name {
+		// name has no letters, can't tell.
+		return false, false
+	}
+
+	st2, err := os.Stat(filepath.Join(dir, swapped))
+	if err != nil {
+		return false, true
+	}
+
+	return os.SameFile(st, st2), true
+}
@@ -0,0 +1,60 @@
+package restore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+)
+
+func namedEntries(names ...string) fs.Entries {
+	var result fs.Entries
+
+	for _, n := range names {
+		result = append(result, virtualfs.StreamingFileFromReader(n, nil))
+	}
+
+	return result
+}
+
+func TestCaseCollisions(t *testing.T) {
+	require.Empty(t, CaseCollisions(namedEntries("a", "b", "c")))
+	require.Equal(t, [][]string{{"A.txt", "a.txt", "a.TXT"}, {"B", "b"}},
+		CaseCollisions(namedEntries("A.txt", "B", "a.txt", "a.TXT", "b", "c")))
+}
+
+func TestResolveCaseCollisions(t *testing.T) {
+	entries := namedEntries("A.txt", "a (2).txt", "a.txt", "a.TXT", "b")
+
+	cases := []struct {
+		action         CaseCollisionAction
+		wantNames      []string
+		wantCollisions int
+		wantErr        bool
+	}{
+		{CaseCollisionNone, []string{"A.txt", "a (2).txt", "a.txt", "a.TXT", "b"}, 0, false},
+		{CaseCollisionRename, []string{"A.txt", "a (2).txt", "a (3).txt", "a (4).TXT", "b"}, 2, false},
+		{CaseCollisionSkip, []string{"A.txt", "a (2).txt", "b"}, 2, false},
+		{CaseCollisionFail, nil, 1, true},
+	}
+
+	for _, tc := range cases {
+		result, collisions, err := resolveCaseCollisions(entries, tc.action)
+		if tc.wantErr {
+			require.Error(t, err, tc.action)
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, tc.wantCollisions, collisions, tc.action)
+
+		var names []string
+		for _, r := range result {
+			names = append(names, r.name)
+		}
+
+		require.Equal(t, tc.wantNames, names, tc.action)
+	}
+}
@@ -0,0 +1,166 @@
+package restore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// minCloneFileSize is the minimum size of files restored by cloning, smaller files are copied
+// because the savings would not be worth the extra staging write.
+const minCloneFileSize = 64 << 10
+
+// cloneStagingDirName is the directory under the restore target where contents of cloned files are staged.
+// It must be on the same filesystem as restored files, so it is placed inside the target and removed on Close.
+const cloneStagingDirName = ".kopia-restore-staging"
+
+// stagedFile is a staging copy of an object, from which all restored files with the same object ID are cloned.
+type stagedFile struct {
+	mu    sync.Mutex
+	path  string
+	ready bool
+}
+
+// fileCloner keeps track of staged objects of a single restore.
+type fileCloner struct {
+	stagingDir string
+
+	mu       sync.Mutex
+	staged   map[object.ID]*stagedFile
+	disabled bool
+}
+
+func (c *fileCloner) stagedFileFor(oid object.ID) *stagedFile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sf := c.staged[oid]
+	if sf == nil {
+		sf = &stagedFile{path: filepath.Join(c.stagingDir, string(oid))}
+		c.staged[oid] = sf
+	}
+
+	return sf
+}
+
+func (c *fileCloner) isDisabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.disabled
+}
+
+func (c *fileCloner) disable() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.disabled = true
+}
+
+func (o *FilesystemOutput) fileCloner() *fileCloner {
+	o.clonerMutex.Lock()
+	defer o.clonerMutex.Unlock()
+
+	if o.cloner == nil {
+		o.cloner = &fileCloner{
+			stagingDir: filepath.Join(o.TargetPath, cloneStagingDirName),
+			staged:     map[object.ID]*stagedFile{},
+		}
+	}
+
+	return o.cloner
+}
+
+// cloneFileContent restores the file by cloning a staging copy of its object, so that restored files
+// sharing the same object share their extents on the target filesystem. Returns false if the file
+// was not restored and must be copied instead, which is also the case when the filesystem does not
+// support cloning.
+func (o *FilesystemOutput) cloneFileContent(ctx context.Context, targetPath string, f fs.File) (bool, error) {
+	h, ok := f.(object.HasObjectID)
+	if !ok || f.Size() < minCloneFileSize {
+		return false, nil
+	}
+
+	c := o.fileCloner()
+	if c.isDisabled() {
+		return false, nil
+	}
+
+	sf := c.stagedFileFor(h.ObjectID())
+
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if !sf.ready {
+		if err := o.stageFile(ctx, c.stagingDir, sf.path, f); err != nil {
+			return false, err
+		}
+
+		sf.ready = true
+	}
+
+	tmpPath := atomicfile.MaybePrefixLongFilenameOnWindows(targetPath + ".kopia-clone")
+
+	if err := cloneFile(atomicfile.MaybePrefixLongFilenameOnWindows(sf.path), tmpPath); err != nil {
+		os.Remove(tmpPath) //nolint:errcheck
+
+		log(ctx).Debugf("file cloning is not available, copying files instead: %v", err)
+		c.disable()
+
+		return false, nil
+	}
+
+	if err := os.Rename(tmpPath, atomicfile.MaybePrefixLongFilenameOnWindows(targetPath)); err != nil {
+		os.Remove(tmpPath) //nolint:errcheck
+
+		return false, errors.Wrap(err, "error renaming cloned file")
+	}
+
+	return true, nil
+}
+
+func (o *FilesystemOutput) stageFile(ctx context.Context, stagingDir, stagingPath string, f fs.File) error {
+	if err := os.MkdirAll(atomicfile.MaybePrefixLongFilenameOnWindows(stagingDir), 0o700); err != nil {
+		return errors.Wrap(err, "error creating staging directory")
+	}
+
+	r, err := f.Open(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to open snapshot file for "+stagingPath)
+	}
+	defer r.Close() //nolint:errcheck
+
+	// nolint:wrapcheck
+	return atomicfile.Write(stagingPath, r)
+}
+
+// removeCloneStaging removes staged files, preserving the modification time of the restore target.
+func (o *FilesystemOutput) removeCloneStaging() error {
+	o.clonerMutex.Lock()
+	c := o.cloner
+	o.clonerMutex.Unlock()
+
+	if c == nil {
+		return nil
+	}
+
+	st, statErr := os.Stat(atomicfile.MaybePrefixLongFilenameOnWindows(o.TargetPath))
+
+	if err := os.RemoveAll(atomicfile.MaybePrefixLongFilenameOnWindows(c.stagingDir)); err != nil {
+		return errors.Wrap(err, "error removing staging directory")
+	}
+
+	if statErr == nil && !o.SkipTimes {
+		// nolint:wrapcheck
+		return os.Chtimes(atomicfile.MaybePrefixLongFilenameOnWindows(o.TargetPath), st.ModTime(), st.ModTime())
+	}
+
+	return nil
+}
@@ -0,0 +1,11 @@
+package restore
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst sharing extents with src using clonefile(2) supported by APFS.
+func cloneFile(src, dst string) error {
+	return errors.Wrap(unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW), "clonefile failed")
+}
@@ -0,0 +1,30 @@
+package restore
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst sharing extents with src using the FICLONE ioctl supported by btrfs and XFS.
+func cloneFile(src, dst string) error {
+	s, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "error opening source file")
+	}
+	defer s.Close() //nolint:errcheck
+
+	d, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "error creating target file")
+	}
+
+	if err := unix.IoctlFileClone(int(d.Fd()), int(s.Fd())); err != nil {
+		d.Close() //nolint:errcheck,gosec
+
+		return errors.Wrap(err, "FICLONE failed")
+	}
+
+	return errors.Wrap(d.Close(), "error closing target file")
+}
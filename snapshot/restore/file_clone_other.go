@@ -0,0 +1,9 @@
+// +build !linux,!darwin,!windows
+
+package restore
+
+import "github.com/pkg/errors"
+
+func cloneFile(src, dst string) error {
+	return errors.New("file cloning is not supported on this platform")
+}
@@ -0,0 +1,59 @@
+package restore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/object"
+)
+
+type fileWithObjectID struct {
+	*mockfs.File
+	oid object.ID
+}
+
+func (f fileWithObjectID) ObjectID() object.ID {
+	return f.oid
+}
+
+func TestCloneFiles(t *testing.T) {
+	ctx := testlogging.Context(t)
+	target := testutil.TempDirectory(t)
+
+	content := bytes.Repeat([]byte{1, 2, 3, 4}, minCloneFileSize)
+
+	root := mockfs.NewDirectory()
+	f := fileWithObjectID{root.AddFile("f", content, 0o644), "k0123456789abcdef"}
+
+	o := &FilesystemOutput{
+		TargetPath: target,
+		SkipOwners: true,
+		CloneFiles: true,
+	}
+
+	// cloning may not be supported by the filesystem, in which case files are copied.
+	require.NoError(t, o.WriteFile(ctx, "a", f))
+	require.NoError(t, o.WriteFile(ctx, "b", f))
+	require.NoError(t, o.Close(ctx))
+
+	for _, fname := range []string{"a", "b"} {
+		got, err := ioutil.ReadFile(filepath.Join(target, fname))
+		require.NoError(t, err)
+		require.Equal(t, content, got)
+	}
+
+	_, err := os.Stat(filepath.Join(target, cloneStagingDirName))
+	require.True(t, os.IsNotExist(err), "staging directory not removed: %v", err)
+
+	entries, err := ioutil.ReadDir(target)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
@@ -0,0 +1,72 @@
+package restore
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// fsctlDuplicateExtentsToFile is FSCTL_DUPLICATE_EXTENTS_TO_FILE control code.
+const fsctlDuplicateExtentsToFile = 0x00098344
+
+// duplicateExtentsAlignment is the alignment of cloned ranges, which must be a multiple of the cluster size.
+// ReFS uses either 4 KiB or 64 KiB clusters, so 64 KiB works with both.
+const duplicateExtentsAlignment = 64 << 10
+
+// duplicateExtentsData is DUPLICATE_EXTENTS_DATA structure.
+type duplicateExtentsData struct {
+	FileHandle windows.Handle
+	_          [8 - unsafe.Sizeof(windows.Handle(0))]byte // offsets are 8-byte aligned on all platforms
+
+	SourceFileOffset int64
+	TargetFileOffset int64
+	ByteCount        int64
+}
+
+// cloneFile creates dst sharing extents with src using block cloning supported by ReFS.
+func cloneFile(src, dst string) error {
+	s, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "error opening source file")
+	}
+	defer s.Close() //nolint:errcheck
+
+	st, err := s.Stat()
+	if err != nil {
+		return errors.Wrap(err, "error getting source file size")
+	}
+
+	d, err := os.OpenFile(dst, os.O_CREATE|os.O_RDWR|os.O_EXCL, 0o600) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "error creating target file")
+	}
+
+	if err := duplicateExtents(s, d, st.Size()); err != nil {
+		d.Close() //nolint:errcheck,gosec
+
+		return err
+	}
+
+	return errors.Wrap(d.Close(), "error closing target file")
+}
+
+func duplicateExtents(s, d *os.File, size int64) error {
+	// the target must be large enough to hold the cloned range.
+	if err := d.Truncate(size); err != nil {
+		return errors.Wrap(err, "error setting target file size")
+	}
+
+	req := duplicateExtentsData{
+		FileHandle: windows.Handle(s.Fd()),
+		ByteCount:  (size + duplicateExtentsAlignment - 1) / duplicateExtentsAlignment * duplicateExtentsAlignment,
+	}
+
+	var bytesReturned uint32
+
+	return errors.Wrap(windows.DeviceIoControl(
+		windows.Handle(d.Fd()), fsctlDuplicateExtentsToFile,
+		(*byte)(unsafe.Pointer(&req)), uint32(unsafe.Sizeof(req)),
+		nil, 0, &bytesReturned, nil), "FSCTL_DUPLICATE_EXTENTS_TO_FILE failed")
+}
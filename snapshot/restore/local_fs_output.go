@@ -7,6 +7,7 @@
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -49,6 +50,17 @@ type FilesystemOutput struct {
 
 	// SkipTimes when set to true causes restore to skip restoring modification times.
 	SkipTimes bool `json:"skipTimes"`
+
+	// WindowsJunctions when set to true causes symbolic links to existing directories to be restored
+	// as junctions on Windows, which unlike directory symbolic links do not require elevated privileges.
+	WindowsJunctions bool `json:"windowsJunctions,omitempty"`
+
+	// CloneFiles when set to true causes files sharing the same contents to be restored by cloning
+	// (reflinks) on filesystems that support it, so they occupy deduplicated space on the target.
+	CloneFiles bool `json:"cloneFiles,omitempty"`
+
+	clonerMutex sync.Mutex
+	cloner      *fileCloner
 }
 
 // Parallelizable implements restore.Output interface.
@@ -79,7 +91,7 @@ func (o *FilesystemOutput) FinishDirectory(ctx context.Context, relativePath str
 
 // Close implements restore.Output interface.
 func (o *FilesystemOutput) Close(ctx context.Context) error {
-	return nil
+	return o.removeCloneStaging()
 }
 
 // WriteFile implements restore.Output interface.
@@ -100,7 +112,7 @@ func (o *FilesystemOutput) WriteFile(ctx context.Context, relativePath string, f
 
 // FileExists implements restore.Output interface.
 func (o *FilesystemOutput) FileExists(ctx context.Context, relativePath string, e fs.File) bool {
-	st, err := os.Lstat(filepath.Join(o.TargetPath, relativePath))
+	st, err := os.Lstat(atomicfile.MaybePrefixLongFilenameOnWindows(filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))))
 	if err != nil {
 		return false
 	}
@@ -134,7 +146,7 @@ func (o *FilesystemOutput) CreateSymlink(ctx context.Context, relativePath strin
 
 	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
 
-	switch stat, err := os.Lstat(path); {
+	switch stat, err := os.Lstat(atomicfile.MaybePrefixLongFilenameOnWindows(path)); {
 	case os.IsNotExist(err): // Proceed to symlink creation
 	case err != nil:
 		return errors.Wrap(err, "lstat error at symlink path")
@@ -145,14 +157,14 @@ func (o *FilesystemOutput) CreateSymlink(ctx context.Context, relativePath strin
 		}
 
 		// Remove the existing symlink before symlink creation
-		if err := os.Remove(path); err != nil {
+		if err := os.Remove(atomicfile.MaybePrefixLongFilenameOnWindows(path)); err != nil {
 			return errors.Wrap(err, "removing existing symlink")
 		}
 	default:
 		return errors.Errorf("unable to create symlink, %q already exists and is not a symlink", path)
 	}
 
-	if err := os.Symlink(targetPath, path); err != nil {
+	if err := o.createLink(path, targetPath); err != nil {
 		return errors.Wrap(err, "error creating symlink")
 	}
 
@@ -163,13 +175,30 @@ func (o *FilesystemOutput) CreateSymlink(ctx context.Context, relativePath strin
 	return nil
 }
 
+// createLink creates a symbolic link or a junction at the provided path.
+func (o *FilesystemOutput) createLink(path, targetPath string) error {
+	if o.WindowsJunctions && isWindows() {
+		absTarget := targetPath
+		if !filepath.IsAbs(absTarget) {
+			absTarget = filepath.Join(filepath.Dir(path), absTarget)
+		}
+
+		if st, err := os.Stat(atomicfile.MaybePrefixLongFilenameOnWindows(absTarget)); err == nil && st.IsDir() {
+			return createJunction(atomicfile.MaybePrefixLongFilenameOnWindows(path), filepath.Clean(absTarget))
+		}
+	}
+
+	// nolint:wrapcheck
+	return os.Symlink(targetPath, atomicfile.MaybePrefixLongFilenameOnWindows(path))
+}
+
 func fileIsSymlink(stat os.FileInfo) bool {
 	return stat.Mode()&os.ModeSymlink != 0
 }
 
 // SymlinkExists implements restore.Output interface.
 func (o *FilesystemOutput) SymlinkExists(ctx context.Context, relativePath string, e fs.Symlink) bool {
-	st, err := os.Lstat(filepath.Join(o.TargetPath, relativePath))
+	st, err := os.Lstat(atomicfile.MaybePrefixLongFilenameOnWindows(filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))))
 	if err != nil {
 		return false
 	}
@@ -184,6 +213,8 @@ func (o *FilesystemOutput) setAttributes(targetPath string, e fs.Entry) error {
 		return errors.Wrap(err, "could not create local FS entry for "+targetPath)
 	}
 
+	osPath := atomicfile.MaybePrefixLongFilenameOnWindows(targetPath)
+
 	var (
 		osChmod   = os.Chmod
 		osChown   = os.Chown
@@ -200,20 +231,20 @@ func (o *FilesystemOutput) setAttributes(targetPath string, e fs.Entry) error {
 	// On Windows Chown is not supported. fs.OwnerInfo collected on Windows will always
 	// be zero-value for UID and GID, so the Chown operation is not performed.
 	if o.shouldUpdateOwner(le, e) {
-		if err = o.maybeIgnorePermissionError(osChown(targetPath, int(e.Owner().UserID), int(e.Owner().GroupID))); err != nil {
+		if err = o.maybeIgnorePermissionError(osChown(osPath, int(e.Owner().UserID), int(e.Owner().GroupID))); err != nil {
 			return errors.Wrap(err, "could not change owner/group for "+targetPath)
 		}
 	}
 
 	// Set file permissions from e
 	if o.shouldUpdatePermissions(le, e) {
-		if err = o.maybeIgnorePermissionError(osChmod(targetPath, e.Mode()&modBits)); err != nil {
+		if err = o.maybeIgnorePermissionError(osChmod(osPath, e.Mode()&modBits)); err != nil {
 			return errors.Wrap(err, "could not change permissions on "+targetPath)
 		}
 	}
 
 	if o.shouldUpdateTimes(le, e) {
-		if err = o.maybeIgnorePermissionError(osChtimes(targetPath, e.ModTime(), e.ModTime())); err != nil {
+		if err = o.maybeIgnorePermissionError(osChtimes(osPath, e.ModTime(), e.ModTime())); err != nil {
 			return errors.Wrap(err, "could not change mod time on "+targetPath)
 		}
 	}
@@ -267,10 +298,10 @@ func isWindows() bool {
 }
 
 func (o *FilesystemOutput) createDirectory(ctx context.Context, path string) error {
-	switch stat, err := os.Stat(path); {
+	switch stat, err := os.Stat(atomicfile.MaybePrefixLongFilenameOnWindows(path)); {
 	case os.IsNotExist(err):
 		// nolint:wrapcheck
-		return os.MkdirAll(path, 0o700)
+		return os.MkdirAll(atomicfile.MaybePrefixLongFilenameOnWindows(path), 0o700)
 	case err != nil:
 		return errors.Wrap(err, "failed to stat path "+path)
 	case stat.Mode().IsDir():
@@ -289,7 +320,7 @@ func (o *FilesystemOutput) createDirectory(ctx context.Context, path string) err
 }
 
 func (o *FilesystemOutput) copyFileContent(ctx context.Context, targetPath string, f fs.File) error {
-	switch _, err := os.Stat(targetPath); {
+	switch _, err := os.Stat(atomicfile.MaybePrefixLongFilenameOnWindows(targetPath)); {
 	case os.IsNotExist(err): // copy file below
 	case err == nil:
 		if !o.OverwriteFiles {
@@ -301,6 +332,18 @@ func (o *FilesystemOutput) copyFileContent(ctx context.Context, targetPath strin
 		return errors.Wrap(err, "failed to stat "+targetPath)
 	}
 
+	if o.CloneFiles {
+		cloned, err := o.cloneFileContent(ctx, targetPath, f)
+		if err != nil {
+			return err
+		}
+
+		if cloned {
+			log(ctx).Debugf("cloned file contents to: %v", targetPath)
+			return nil
+		}
+	}
+
 	r, err := f.Open(ctx)
 	if err != nil {
 		return errors.Wrap(err, "unable to open snapshot file for "+targetPath)
@@ -314,7 +357,7 @@ func (o *FilesystemOutput) copyFileContent(ctx context.Context, targetPath strin
 }
 
 func isEmptyDirectory(name string) (bool, error) {
-	f, err := os.Open(name) //nolint:gosec
+	f, err := os.Open(atomicfile.MaybePrefixLongFilenameOnWindows(name)) //nolint:gosec
 	if err != nil {
 		return false, errors.Wrap(err, "error opening directory")
 	}
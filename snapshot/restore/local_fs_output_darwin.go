@@ -4,6 +4,7 @@
 	"os"
 	"time"
 
+	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
 )
 
@@ -24,3 +25,7 @@ func symlinkChtimes(linkPath string, atime, mtime time.Time) error {
 		unix.NsecToTimeval(mtime.UnixNano()),
 	})
 }
+
+func createJunction(linkPath, target string) error {
+	return errors.New("junctions are only supported on Windows")
+}
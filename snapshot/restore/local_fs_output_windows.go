@@ -1,8 +1,11 @@
 package restore
 
 import (
+	"bytes"
+	"encoding/binary"
 	"os"
 	"time"
+	"unicode/utf16"
 
 	"github.com/pkg/errors"
 	"golang.org/x/sys/windows"
@@ -43,3 +46,72 @@ func symlinkChtimes(linkPath string, atime, mtime time.Time) error {
 	// nolint:wrapcheck
 	return windows.SetFileTime(h, &ftw, &fta, &ftw)
 }
+
+// fsctlSetReparsePoint is FSCTL_SET_REPARSE_POINT control code.
+const fsctlSetReparsePoint = 0x000900A4
+
+// mountPointReparseHeader is the header of REPARSE_DATA_BUFFER for IO_REPARSE_TAG_MOUNT_POINT.
+type mountPointReparseHeader struct {
+	ReparseTag           uint32
+	ReparseDataLength    uint16
+	Reserved             uint16
+	SubstituteNameOffset uint16
+	SubstituteNameLength uint16
+	PrintNameOffset      uint16
+	PrintNameLength      uint16
+}
+
+// createJunction creates a directory junction at linkPath pointing at the provided absolute target directory.
+func createJunction(linkPath, target string) error {
+	substituteName := utf16.Encode([]rune(`\??\` + target))
+	printName := utf16.Encode([]rune(target))
+
+	// both names are NUL-terminated, but the lengths do not include the terminator.
+	pathBuffer := append(append(append(substituteName, 0), printName...), 0)
+
+	hdr := mountPointReparseHeader{
+		ReparseTag:           windows.IO_REPARSE_TAG_MOUNT_POINT,
+		SubstituteNameLength: uint16(2 * len(substituteName)),
+		PrintNameOffset:      uint16(2 * (len(substituteName) + 1)),
+		PrintNameLength:      uint16(2 * len(printName)),
+	}
+
+	hdr.ReparseDataLength = uint16(8 + 2*len(pathBuffer)) // nolint:gomnd
+
+	var buf bytes.Buffer
+
+	binary.Write(&buf, binary.LittleEndian, hdr)        // nolint:errcheck
+	binary.Write(&buf, binary.LittleEndian, pathBuffer) // nolint:errcheck
+
+	if err := os.Mkdir(linkPath, 0o700); err != nil {
+		return errors.Wrap(err, "unable to create junction directory")
+	}
+
+	if err := setReparsePoint(linkPath, buf.Bytes()); err != nil {
+		os.Remove(linkPath) //nolint:errcheck
+
+		return err
+	}
+
+	return nil
+}
+
+func setReparsePoint(path string, data []byte) error {
+	fn, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return errors.Wrap(err, "UTF16PtrFromString")
+	}
+
+	h, err := windows.CreateFile(
+		fn, windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OPEN_REPARSE_POINT, 0)
+	if err != nil {
+		return errors.Wrapf(err, "CreateFile error on %v", path)
+	}
+
+	defer windows.CloseHandle(h) //nolint:errcheck
+
+	var bytesReturned uint32
+
+	return errors.Wrap(windows.DeviceIoControl(h, fsctlSetReparsePoint, &data[0], uint32(len(data)), nil, 0, &bytesReturned, nil), "unable to set reparse point")
+}
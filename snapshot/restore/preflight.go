@@ -0,0 +1,303 @@
+package restore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/diskspace"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo/object"
+)
+
+const (
+	// preflightBlockSize is the allocation unit assumed when estimating space used by restored files.
+	preflightBlockSize = 4096
+
+	// maxPreflightIssues limits the number of issues included in the preflight report.
+	maxPreflightIssues = 100
+
+	// maxNameLength is the maximum length of a single path component on common filesystems
+	// (in bytes on Unix, in UTF-16 code units on Windows).
+	maxNameLength = 255
+
+	// maxUnixPathLength is PATH_MAX on Linux including the terminating NUL.
+	maxUnixPathLength = 4096
+
+	// maxWindowsPathLength is the maximum length of extended-length paths, which restore uses
+	// for long filenames on Windows.
+	maxWindowsPathLength = 32767
+)
+
+// PreflightIssue describes a problem which would prevent restore from completing.
+type PreflightIssue struct {
+	Path    string `json:"path"`
+	Problem string `json:"problem"`
+}
+
+// PreflightReport contains results of checking whether a snapshot can be restored to the local filesystem.
+type PreflightReport struct {
+	FileCount     int32 `json:"fileCount"`
+	DirCount      int32 `json:"dirCount"`
+	SymlinkCount  int32 `json:"symlinkCount"`
+	TotalFileSize int64 `json:"totalFileSize"`
+
+	// RequiredBytes is the estimated amount of space that needs to be allocated on the target volume.
+	RequiredBytes int64 `json:"requiredBytes"`
+
+	// AvailableBytes is the space available on the target volume, -1 if unknown.
+	AvailableBytes int64 `json:"availableBytes"`
+
+	Issues []PreflightIssue `json:"issues,omitempty"`
+
+	// OmittedIssues is the number of issues found beyond the reported ones.
+	OmittedIssues int `json:"omittedIssues,omitempty"`
+}
+
+// OK returns true if no issues were found.
+func (r *PreflightReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+func (r *PreflightReport) addIssue(p, problem string) {
+	if len(r.Issues) >= maxPreflightIssues {
+		r.OmittedIssues++
+		return
+	}
+
+	r.Issues = append(r.Issues, PreflightIssue{p, problem})
+}
+
+// Preflight checks whether the provided tree can be restored to the target path without writing anything
+// to it. It estimates the required disk space, verifies that the target is writable and that none of the
+// restored paths exceeds limits of the target platform.
+//
+// Space is estimated by rounding file sizes up to whole blocks, excluding files that would be
+// skipped by incremental restore and counting files restored by cloning only once.
+// Restore does not write sparse files, so holes are not taken into account.
+func (o *FilesystemOutput) Preflight(ctx context.Context, rootEntry fs.Entry, options Options) (*PreflightReport, error) {
+	rep := &PreflightReport{AvailableBytes: -1}
+
+	existing, err := o.checkPreflightTarget(rootEntry, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != "" {
+		if free, err := diskspace.AvailableBytes(existing); err != nil {
+			log(ctx).Debugf("unable to determine free space: %v", err)
+		} else {
+			rep.AvailableBytes = free
+		}
+	}
+
+	pw := &preflightWalker{
+		output:  o,
+		options: options,
+		report:  rep,
+		cloned:  map[object.ID]bool{},
+	}
+
+	if err := pw.walk(ctx, rootEntry, ""); err != nil {
+		return nil, err
+	}
+
+	if rep.AvailableBytes >= 0 && rep.RequiredBytes > rep.AvailableBytes {
+		rep.addIssue(o.TargetPath, "not enough free space: "+units.BytesStringBase10(rep.RequiredBytes)+
+			" required, "+units.BytesStringBase10(rep.AvailableBytes)+" available")
+	}
+
+	return rep, nil
+}
+
+// checkPreflightTarget verifies that the target path can be created or written to and returns the path
+// of its nearest existing ancestor (or the target itself), which determines the volume used.
+func (o *FilesystemOutput) checkPreflightTarget(rootEntry fs.Entry, rep *PreflightReport) (string, error) {
+	target, err := filepath.Abs(o.TargetPath)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to determine absolute target path")
+	}
+
+	existing := target
+
+	if _, isDir := rootEntry.(fs.Directory); !isDir {
+		// single file is restored into the parent directory of the target.
+		existing = filepath.Dir(target)
+	}
+
+	for {
+		st, err := os.Stat(existing)
+
+		switch {
+		case err == nil && !st.IsDir():
+			rep.addIssue(existing, "not a directory")
+			return "", nil
+
+		case err == nil:
+			if !isWritableDirectory(existing) {
+				rep.addIssue(existing, "directory is not writable")
+			}
+
+			return existing, nil
+		}
+
+		// the path does not exist or can't be accessed, check its parent.
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			rep.addIssue(o.TargetPath, "no existing parent directory")
+			return "", nil
+		}
+
+		existing = parent
+	}
+}
+
+// isWritableDirectory determines whether files can be created in the provided directory by creating
+// and immediately removing a temporary file.
+func isWritableDirectory(dir string) bool {
+	f, err := ioutil.TempFile(dir, ".kopia-preflight-")
+	if err != nil {
+		return false
+	}
+
+	f.Close()           //nolint:errcheck
+	os.Remove(f.Name()) //nolint:errcheck
+
+	return true
+}
+
+type preflightWalker struct {
+	output  *FilesystemOutput
+	options Options
+	report  *PreflightReport
+
+	// object IDs of files restored by cloning, which take up space only once.
+	cloned map[object.ID]bool
+}
+
+func (w *preflightWalker) walk(ctx context.Context, e fs.Entry, relativePath string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "preflight canceled")
+	}
+
+	if relativePath != "" {
+		w.checkPathLength(e.Name(), relativePath)
+	}
+
+	switch e := e.(type) {
+	case fs.Directory:
+		w.report.DirCount++
+		w.checkExistingDirectory(relativePath)
+
+		// nolint:wrapcheck
+		return fs.IterateEntries(ctx, e, func(ctx context.Context, child fs.Entry) error {
+			return w.walk(ctx, child, path.Join(relativePath, child.Name()))
+		})
+
+	case fs.Symlink:
+		w.report.SymlinkCount++
+
+		if !w.output.OverwriteSymlinks && w.options.Symlinks != SymlinkSkip {
+			targetPath := filepath.Join(w.output.TargetPath, filepath.FromSlash(relativePath))
+
+			if _, err := os.Lstat(atomicfile.MaybePrefixLongFilenameOnWindows(targetPath)); err == nil {
+				w.report.addIssue(targetPath, "symbolic link already exists and overwriting symbolic links is disabled")
+			}
+		}
+
+	case fs.File:
+		w.visitFile(ctx, e, relativePath)
+	}
+
+	return nil
+}
+
+func (w *preflightWalker) checkExistingDirectory(relativePath string) {
+	targetPath := filepath.Join(w.output.TargetPath, filepath.FromSlash(relativePath))
+
+	st, err := os.Stat(atomicfile.MaybePrefixLongFilenameOnWindows(targetPath))
+	if err != nil {
+		return
+	}
+
+	switch {
+	case !st.IsDir():
+		if relativePath != "" {
+			w.report.addIssue(targetPath, "already exists and it is not a directory")
+		}
+
+	case !w.output.OverwriteDirectories:
+		if empty, _ := isEmptyDirectory(targetPath); !empty {
+			w.report.addIssue(targetPath, "non-empty directory already exists and overwriting directories is disabled")
+		}
+	}
+}
+
+func (w *preflightWalker) visitFile(ctx context.Context, f fs.File, relativePath string) {
+	rep := w.report
+
+	rep.FileCount++
+	rep.TotalFileSize += f.Size()
+
+	if w.options.Incremental && w.output.FileExists(ctx, relativePath, f) {
+		return
+	}
+
+	targetPath := filepath.Join(w.output.TargetPath, filepath.FromSlash(relativePath))
+
+	if st, err := os.Lstat(atomicfile.MaybePrefixLongFilenameOnWindows(targetPath)); err == nil {
+		switch {
+		case st.IsDir():
+			rep.addIssue(targetPath, "directory already exists in place of the file")
+		case !w.output.OverwriteFiles:
+			rep.addIssue(targetPath, "file already exists and overwriting files is disabled")
+		}
+	}
+
+	if h, ok := f.(object.HasObjectID); ok && w.output.CloneFiles && f.Size() >= minCloneFileSize {
+		if w.cloned[h.ObjectID()] {
+			return
+		}
+
+		w.cloned[h.ObjectID()] = true
+	}
+
+	rep.RequiredBytes += (f.Size() + preflightBlockSize - 1) / preflightBlockSize * preflightBlockSize
+}
+
+func (w *preflightWalker) checkPathLength(name, relativePath string) {
+	targetPath := filepath.Join(w.output.TargetPath, filepath.FromSlash(relativePath))
+
+	if pathLength(name) > maxNameLength {
+		w.report.addIssue(targetPath, "file name is too long")
+		return
+	}
+
+	maxLen := maxUnixPathLength - 1
+
+	if runtime.GOOS == "windows" {
+		maxLen = maxWindowsPathLength
+	}
+
+	if abs, err := filepath.Abs(targetPath); err == nil && pathLength(abs) > maxLen {
+		w.report.addIssue(targetPath, "path is too long")
+	}
+}
+
+// pathLength returns the length of the path as counted by the target platform.
+func pathLength(p string) int {
+	if runtime.GOOS == "windows" {
+		return len(utf16.Encode([]rune(p)))
+	}
+
+	return len(p)
+}
@@ -0,0 +1,101 @@
+package restore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestPreflight(t *testing.T) {
+	ctx := testlogging.Context(t)
+	target := filepath.Join(testutil.TempDirectory(t), "out")
+
+	root := mockfs.NewDirectory()
+	root.AddFile("f1", []byte{1, 2, 3}, 0o644)
+	root.AddDir("d1", 0o755).AddFile("f2", bytes.Repeat([]byte{1}, 5000), 0o644)
+	root.AddSymlink("s1", "f1", 0o777)
+
+	o := &FilesystemOutput{TargetPath: target, OverwriteDirectories: true}
+
+	rep, err := o.Preflight(ctx, root, Options{})
+	require.NoError(t, err)
+	require.True(t, rep.OK(), "%v", rep.Issues)
+	require.Equal(t, int32(2), rep.FileCount)
+	require.Equal(t, int32(2), rep.DirCount)
+	require.Equal(t, int32(1), rep.SymlinkCount)
+	require.Equal(t, int64(5003), rep.TotalFileSize)
+	require.Equal(t, int64(3*preflightBlockSize), rep.RequiredBytes)
+
+	// nothing was written.
+	_, err = os.Stat(target)
+	require.True(t, os.IsNotExist(err))
+
+	// existing files are reported when not overwriting them.
+	require.NoError(t, os.MkdirAll(target, 0o755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(target, "f1"), []byte{1}, 0o600))
+
+	rep, err = o.Preflight(ctx, root, Options{})
+	require.NoError(t, err)
+	require.False(t, rep.OK())
+	require.Equal(t, []PreflightIssue{
+		{filepath.Join(target, "f1"), "file already exists and overwriting files is disabled"},
+	}, rep.Issues)
+
+	o.OverwriteFiles = true
+
+	rep, err = o.Preflight(ctx, root, Options{})
+	require.NoError(t, err)
+	require.True(t, rep.OK(), "%v", rep.Issues)
+
+	// overly long names are reported.
+	root.AddFile(strings.Repeat("x", maxNameLength+1), []byte{1}, 0o644)
+
+	rep, err = o.Preflight(ctx, root, Options{})
+	require.NoError(t, err)
+	require.Len(t, rep.Issues, 1)
+	require.Equal(t, "file name is too long", rep.Issues[0].Problem)
+
+	// target can't be created under a file.
+	o.TargetPath = filepath.Join(target, "f1", "sub")
+
+	rep, err = o.Preflight(ctx, root, Options{})
+	require.NoError(t, err)
+	require.Equal(t, PreflightIssue{filepath.Join(target, "f1"), "not a directory"}, rep.Issues[0])
+	require.Equal(t, int64(-1), rep.AvailableBytes)
+}
+
+func TestPreflightCloneFiles(t *testing.T) {
+	ctx := testlogging.Context(t)
+	content := bytes.Repeat([]byte{1}, minCloneFileSize)
+
+	mroot := mockfs.NewDirectory()
+
+	root := virtualfs.NewStaticDirectory("root", fs.Entries{
+		fileWithObjectID{mroot.AddFile("a", content, 0o644), "k0123456789abcdef"},
+		fileWithObjectID{mroot.AddFile("b", content, 0o644), "k0123456789abcdef"},
+	})
+
+	o := &FilesystemOutput{TargetPath: testutil.TempDirectory(t)}
+
+	rep, err := o.Preflight(ctx, root, Options{})
+	require.NoError(t, err)
+	require.Equal(t, int64(2*minCloneFileSize), rep.RequiredBytes)
+
+	o.CloneFiles = true
+
+	rep, err = o.Preflight(ctx, root, Options{})
+	require.NoError(t, err)
+	require.Equal(t, int64(minCloneFileSize), rep.RequiredBytes)
+	require.Equal(t, int64(2*minCloneFileSize), rep.TotalFileSize)
+}
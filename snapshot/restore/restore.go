@@ -42,6 +42,7 @@ type Stats struct {
 	EnqueuedSymlinkCount int32
 	SkippedCount         int32
 	IgnoredErrorCount    int32
+	CaseCollisionCount   int32
 }
 
 func (s *Stats) clone() Stats {
@@ -58,6 +59,7 @@ func (s *Stats) clone() Stats {
 		EnqueuedSymlinkCount: atomic.LoadInt32(&s.EnqueuedSymlinkCount),
 		SkippedCount:         atomic.LoadInt32(&s.SkippedCount),
 		IgnoredErrorCount:    atomic.LoadInt32(&s.IgnoredErrorCount),
+		CaseCollisionCount:   atomic.LoadInt32(&s.CaseCollisionCount),
 	}
 }
 
@@ -67,18 +69,39 @@ type Options struct {
 	Incremental  bool `json:"incremental"`
 	IgnoreErrors bool `json:"ignoreErrors"`
 
+	// CaseCollisions specifies how to handle entries whose names differ only by case.
+	CaseCollisions CaseCollisionAction `json:"caseCollisions,omitempty"`
+
+	// Symlinks specifies how symbolic links are restored.
+	Symlinks SymlinkAction `json:"symlinks,omitempty"`
+
 	ProgressCallback func(ctx context.Context, s Stats)
 	Cancel           chan struct{} // channel that can be externally closed to signal cancelation
 }
 
 // Entry walks a snapshot root with given root entry and restores it to the provided output.
 func Entry(ctx context.Context, rep repo.Repository, output Output, rootEntry fs.Entry, options Options) (Stats, error) {
+	switch options.CaseCollisions {
+	case CaseCollisionNone, CaseCollisionRename, CaseCollisionSkip, CaseCollisionFail:
+	default:
+		return Stats{}, errors.Errorf("unsupported case collision action %q", options.CaseCollisions)
+	}
+
+	switch options.Symlinks {
+	case SymlinkRestore, SymlinkSkip, SymlinkFollow, SymlinkRewriteAbsolute:
+	default:
+		return Stats{}, errors.Errorf("unsupported symlink action %q", options.Symlinks)
+	}
+
 	c := copier{
-		output:       output,
-		q:            parallelwork.NewQueue(),
-		incremental:  options.Incremental,
-		ignoreErrors: options.IgnoreErrors,
-		cancel:       options.Cancel,
+		output:         output,
+		q:              parallelwork.NewQueue(),
+		incremental:    options.Incremental,
+		ignoreErrors:   options.IgnoreErrors,
+		caseCollisions: options.CaseCollisions,
+		symlinks:       options.Symlinks,
+		root:           rootEntry,
+		cancel:         options.Cancel,
 	}
 
 	c.q.ProgressCallback = func(ctx context.Context, enqueued, active, completed int64) {
@@ -88,7 +111,7 @@ func Entry(ctx context.Context, rep repo.Repository, output Output, rootEntry fs
 	}
 
 	c.q.EnqueueFront(ctx, func() error {
-		return errors.Wrap(c.copyEntry(ctx, rootEntry, "", func() error { return nil }), "error copying")
+		return errors.Wrap(c.copyEntry(ctx, rootEntry, entryLocation{treePath: "."}, "", func() error { return nil }), "error copying")
 	})
 
 	numWorkers := options.Parallel
@@ -112,15 +135,59 @@ func Entry(ctx context.Context, rep repo.Repository, output Output, rootEntry fs
 }
 
 type copier struct {
-	stats        Stats
-	output       Output
-	q            *parallelwork.Queue
-	incremental  bool
-	ignoreErrors bool
-	cancel       chan struct{}
+	stats          Stats
+	output         Output
+	q              *parallelwork.Queue
+	incremental    bool
+	ignoreErrors   bool
+	caseCollisions CaseCollisionAction
+	symlinks       SymlinkAction
+	root           fs.Entry
+	cancel         chan struct{}
 }
 
-func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string, onCompletion func() error) error {
+// applySymlinkAction returns the entry that should be restored in place of the provided one according to
+// the symlink action, or nil if nothing should be restored.
+func (c *copier) applySymlinkAction(ctx context.Context, e fs.Entry, loc entryLocation, targetPath string) (fs.Entry, entryLocation, error) {
+	sl, ok := e.(fs.Symlink)
+	if !ok {
+		return e, loc, nil
+	}
+
+	switch c.symlinks {
+	case SymlinkSkip:
+		log(ctx).Debugf("skipping symlink %v", targetPath)
+		return nil, loc, nil
+
+	case SymlinkFollow:
+		te, tloc, err := followSymlink(ctx, c.root, sl, loc)
+
+		switch {
+		case errors.Is(err, errSymlinkOutsideTree), errors.Is(err, errSymlinkLoop), errors.Is(err, errTooManySymlinks), errors.Is(err, fs.ErrEntryNotFound):
+			log(ctx).Debugf("not following symlink %v: %v", targetPath, err)
+			return nil, loc, nil
+
+		case err != nil:
+			return nil, loc, errors.Wrapf(err, "unable to follow symlink %v", targetPath)
+
+		default:
+			return te, tloc, nil
+		}
+
+	case SymlinkRewriteAbsolute:
+		target, err := sl.Readlink(ctx)
+		if err != nil {
+			return nil, loc, errors.Wrap(err, "error reading link target")
+		}
+
+		return rewrittenSymlink{sl, RewriteAbsoluteSymlinkTarget(targetPath, target)}, loc, nil
+
+	default:
+		return e, loc, nil
+	}
+}
+
+func (c *copier) copyEntry(ctx context.Context, e fs.Entry, loc entryLocation, targetPath string, onCompletion func() error) error {
 	if c.cancel != nil {
 		select {
 		case <-c.cancel:
@@ -130,6 +197,16 @@ func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string, o
 		}
 	}
 
+	e, loc, err := c.applySymlinkAction(ctx, e, loc, targetPath)
+	if err != nil {
+		return c.maybeIgnoreError(ctx, err, targetPath)
+	}
+
+	if e == nil {
+		atomic.AddInt32(&c.stats.SkippedCount, 1)
+		return onCompletion()
+	}
+
 	if c.incremental {
 		// in incremental mode, do not copy if the output already exists
 		switch e := e.(type) {
@@ -152,7 +229,10 @@ func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string, o
 		}
 	}
 
-	err := c.copyEntryInternal(ctx, e, targetPath, onCompletion)
+	return c.maybeIgnoreError(ctx, c.copyEntryInternal(ctx, e, loc, targetPath, onCompletion), targetPath)
+}
+
+func (c *copier) maybeIgnoreError(ctx context.Context, err error, targetPath string) error {
 	if err == nil {
 		return nil
 	}
@@ -167,11 +247,11 @@ func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string, o
 	return err
 }
 
-func (c *copier) copyEntryInternal(ctx context.Context, e fs.Entry, targetPath string, onCompletion func() error) error {
+func (c *copier) copyEntryInternal(ctx context.Context, e fs.Entry, loc entryLocation, targetPath string, onCompletion func() error) error {
 	switch e := e.(type) {
 	case fs.Directory:
 		log(ctx).Debugf("dir: '%v'", targetPath)
-		return c.copyDirectory(ctx, e, targetPath, onCompletion)
+		return c.copyDirectory(ctx, e, loc, targetPath, onCompletion)
 	case fs.File:
 		log(ctx).Debugf("file: '%v'", targetPath)
 
@@ -199,14 +279,14 @@ func (c *copier) copyEntryInternal(ctx context.Context, e fs.Entry, targetPath s
 	}
 }
 
-func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, targetPath string, onCompletion parallelwork.CallbackFunc) error {
+func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, loc entryLocation, targetPath string, onCompletion parallelwork.CallbackFunc) error {
 	atomic.AddInt32(&c.stats.RestoredDirCount, 1)
 
 	if err := c.output.BeginDirectory(ctx, targetPath, d); err != nil {
 		return errors.Wrap(err, "create directory")
 	}
 
-	return errors.Wrap(c.copyDirectoryContent(ctx, d, targetPath, func() error {
+	return errors.Wrap(c.copyDirectoryContent(ctx, d, loc, targetPath, func() error {
 		if err := c.output.FinishDirectory(ctx, targetPath, d); err != nil {
 			return errors.Wrap(err, "finish directory")
 		}
@@ -215,7 +295,7 @@ func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, targetPath s
 	}), "copy directory contents")
 }
 
-func (c *copier) copyDirectoryContent(ctx context.Context, d fs.Directory, targetPath string, onCompletion parallelwork.CallbackFunc) error {
+func (c *copier) copyDirectoryContent(ctx context.Context, d fs.Directory, loc entryLocation, targetPath string, onCompletion parallelwork.CallbackFunc) error {
 	entries, err := d.Readdir(ctx)
 	if err != nil {
 		return errors.Wrap(err, "error reading directory")
@@ -225,16 +305,28 @@ func (c *copier) copyDirectoryContent(ctx context.Context, d fs.Directory, targe
 		return onCompletion()
 	}
 
-	onItemCompletion := parallelwork.OnNthCompletion(len(entries), onCompletion)
+	restored, collisions, err := resolveCaseCollisions(entries, c.caseCollisions)
+	if err != nil {
+		return errors.Wrapf(err, "case collision in %q", targetPath)
+	}
+
+	if collisions > 0 {
+		atomic.AddInt32(&c.stats.CaseCollisionCount, int32(collisions))
+		log(ctx).Infof("found %v entries in %q with names differing only by case (%v)", collisions, targetPath, c.caseCollisions)
+	}
+
+	onItemCompletion := parallelwork.OnNthCompletion(len(restored), onCompletion)
 
-	for _, e := range entries {
-		e := e
+	for _, re := range restored {
+		e := re.entry
+		entryPath := path.Join(targetPath, re.name)
+		entryLoc := loc.child(e.Name())
 
 		if e.IsDir() {
 			atomic.AddInt32(&c.stats.EnqueuedDirCount, 1)
 			// enqueue directories first, so that we quickly determine the total number and size of items.
 			c.q.EnqueueFront(ctx, func() error {
-				return c.copyEntry(ctx, e, path.Join(targetPath, e.Name()), onItemCompletion)
+				return c.copyEntry(ctx, e, entryLoc, entryPath, onItemCompletion)
 			})
 		} else {
 			if isSymlink(e) {
@@ -246,7 +338,7 @@ func (c *copier) copyDirectoryContent(ctx context.Context, d fs.Directory, targe
 			atomic.AddInt64(&c.stats.EnqueuedTotalFileSize, e.Size())
 
 			c.q.EnqueueBack(ctx, func() error {
-				return c.copyEntry(ctx, e, path.Join(targetPath, e.Name()), onItemCompletion)
+				return c.copyEntry(ctx, e, entryLoc, entryPath, onItemCompletion)
 			})
 		}
 	}
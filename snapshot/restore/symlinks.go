@@ -0,0 +1,213 @@
+package restore
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// SymlinkAction specifies how symbolic links are restored.
+type SymlinkAction string
+
+// Supported symbolic link actions.
+const (
+	// SymlinkRestore restores symbolic links with their original targets.
+	SymlinkRestore SymlinkAction = ""
+
+	// SymlinkSkip does not restore symbolic links.
+	SymlinkSkip SymlinkAction = "skip"
+
+	// SymlinkFollow restores the entries that symbolic links point to in place of the links.
+	// Links whose targets are outside of the restored tree are skipped.
+	SymlinkFollow SymlinkAction = "follow"
+
+	// SymlinkRewriteAbsolute restores symbolic links, but absolute targets are interpreted relative
+	// to the restore root, so that the links never point outside of the restored tree.
+	SymlinkRewriteAbsolute SymlinkAction = "rewrite-absolute"
+)
+
+// maxSymlinkHops is the maximum number of symbolic links followed when resolving a single link.
+const maxSymlinkHops = 40
+
+var (
+	errSymlinkOutsideTree = errors.New("target is outside of the restored tree")
+	errSymlinkLoop        = errors.New("target is a directory that contains the link")
+	errTooManySymlinks    = errors.New("too many levels of symbolic links")
+)
+
+// entryLocation describes location of the restored entry in the restored tree, which is different from
+// output path when the entry was renamed or reached by following a symbolic link.
+type entryLocation struct {
+	// path relative to the root of the restored tree.
+	treePath string
+
+	// tree paths of directories entered by following symbolic links.
+	followed []string
+}
+
+func (l entryLocation) child(name string) entryLocation {
+	return entryLocation{path.Join(l.treePath, name), l.followed}
+}
+
+// isAbsoluteSymlinkTarget determines whether the provided symbolic link target is absolute on any supported OS.
+func isAbsoluteSymlinkTarget(target string) bool {
+	if strings.HasPrefix(target, "/") || strings.HasPrefix(target, `\`) {
+		return true
+	}
+
+	return len(target) >= 3 && isDriveLetter(target[0]) && target[1] == ':' && (target[2] == '/' || target[2] == '\\')
+}
+
+func isDriveLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// RewriteAbsoluteSymlinkTarget returns the target of a symbolic link at the provided slash-separated path relative
+// to the restore root, such that absolute target is interpreted relative to the restore root.
+// Relative targets are returned unchanged.
+func RewriteAbsoluteSymlinkTarget(linkPath, target string) string {
+	if !isAbsoluteSymlinkTarget(target) {
+		return target
+	}
+
+	if target[0] != '/' && target[0] != '\\' {
+		// strip drive letter
+		target = target[2:]
+	}
+
+	target = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(target, `\`, "/")), "/")
+
+	var up []string
+
+	if dir := path.Dir(linkPath); dir != "." && dir != "" {
+		for range strings.Split(dir, "/") {
+			up = append(up, "..")
+		}
+	}
+
+	if result := path.Join(path.Join(up...), target); result != "" {
+		return result
+	}
+
+	return "."
+}
+
+// rewrittenSymlink is a symbolic link with a modified target.
+type rewrittenSymlink struct {
+	fs.Symlink
+	target string
+}
+
+func (s rewrittenSymlink) Readlink(ctx context.Context) (string, error) {
+	return s.target, nil
+}
+
+// lookupTreePath returns the entry at the provided slash-separated path relative to the root of the tree,
+// following symbolic links in intermediate components.
+func lookupTreePath(ctx context.Context, root fs.Entry, treePath string, hops *int) (fs.Entry, string, error) {
+	var (
+		current     = root
+		currentPath = "."
+	)
+
+	for _, name := range strings.Split(treePath, "/") {
+		if name == "" || name == "." {
+			continue
+		}
+
+		if sl, ok := current.(fs.Symlink); ok {
+			var err error
+
+			if current, currentPath, err = resolveSymlinkTarget(ctx, root, currentPath, sl, hops); err != nil {
+				return nil, "", err
+			}
+		}
+
+		dir, ok := current.(fs.Directory)
+		if !ok {
+			return nil, "", errors.Wrapf(fs.ErrEntryNotFound, "%q is not a directory", currentPath)
+		}
+
+		child, err := dir.Child(ctx, name)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "unable to find %q", path.Join(currentPath, name))
+		}
+
+		current = child
+		currentPath = path.Join(currentPath, name)
+	}
+
+	return current, currentPath, nil
+}
+
+// resolveSymlinkTarget returns the entry that the symbolic link at the provided tree path ultimately points to
+// along with its tree path.
+func resolveSymlinkTarget(ctx context.Context, root fs.Entry, linkPath string, sl fs.Symlink, hops *int) (fs.Entry, string, error) {
+	for {
+		if *hops++; *hops > maxSymlinkHops {
+			return nil, "", errTooManySymlinks
+		}
+
+		target, err := sl.Readlink(ctx)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "error reading link target")
+		}
+
+		if isAbsoluteSymlinkTarget(target) {
+			return nil, "", errSymlinkOutsideTree
+		}
+
+		targetPath := path.Join(path.Dir(linkPath), target)
+		if targetPath == ".." || strings.HasPrefix(targetPath, "../") {
+			return nil, "", errSymlinkOutsideTree
+		}
+
+		e, resolvedPath, err := lookupTreePath(ctx, root, targetPath, hops)
+		if err != nil {
+			return nil, "", err
+		}
+
+		next, ok := e.(fs.Symlink)
+		if !ok {
+			return e, resolvedPath, nil
+		}
+
+		sl, linkPath = next, resolvedPath
+	}
+}
+
+// isSameOrAncestor returns true if the provided tree path is the same as or an ancestor of the other path.
+func isSameOrAncestor(treePath, other string) bool {
+	return treePath == "." || treePath == other || strings.HasPrefix(other, treePath+"/")
+}
+
+// followSymlink returns the entry that should be restored in place of the provided symbolic link
+// and its location.
+func followSymlink(ctx context.Context, root fs.Entry, sl fs.Symlink, loc entryLocation) (fs.Entry, entryLocation, error) {
+	hops := 0
+
+	e, resolvedPath, err := resolveSymlinkTarget(ctx, root, loc.treePath, sl, &hops)
+	if err != nil {
+		return nil, loc, err
+	}
+
+	if _, ok := e.(fs.Directory); !ok {
+		return e, entryLocation{resolvedPath, loc.followed}, nil
+	}
+
+	if isSameOrAncestor(resolvedPath, loc.treePath) {
+		return nil, loc, errSymlinkLoop
+	}
+
+	for _, f := range loc.followed {
+		if isSameOrAncestor(resolvedPath, f) {
+			return nil, loc, errSymlinkLoop
+		}
+	}
+
+	return e, entryLocation{resolvedPath, append(append([]string(nil), loc.followed...), resolvedPath)}, nil
+}
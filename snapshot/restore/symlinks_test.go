@@ -0,0 +1,163 @@
+package restore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestRewriteAbsoluteSymlinkTarget(t *testing.T) {
+	cases := []struct {
+		linkPath, target, want string
+	}{
+		{"lnk", "relative/target", "relative/target"},
+		{"lnk", "/etc/passwd", "etc/passwd"},
+		{"a/b/lnk", "/etc/passwd", "../../etc/passwd"},
+		{"a/lnk", "/../../x", "../x"},
+		{"a/lnk", "/", ".."},
+		{"lnk", "/", "."},
+		{"a/lnk", `C:\Users\foo`, "../Users/foo"},
+		{"a/lnk", `\\server\share`, "../server/share"},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.want, RewriteAbsoluteSymlinkTarget(tc.linkPath, tc.target), "%v -> %v", tc.linkPath, tc.target)
+	}
+}
+
+func symlinkTestTree() *mockfs.Directory {
+	root := mockfs.NewDirectory()
+	root.AddDir("d", 0o755)
+	root.AddFile("d/f", []byte("hello"), 0o644)
+	root.AddDir("d/sub", 0o755)
+	root.AddFile("d/sub/g", []byte("world"), 0o644)
+	root.AddSymlink("d/sub/up", "../..", 0o777)
+	root.AddSymlink("lnk-rel", "d/f", 0o777)
+	root.AddSymlink("lnk-chain", "lnk-rel", 0o777)
+	root.AddSymlink("lnk-abs", "/etc/passwd", 0o777)
+	root.AddSymlink("lnk-dir", "d/sub", 0o777)
+	root.AddSymlink("lnk-missing", "no-such-file", 0o777)
+	root.AddSymlink("lnk-outside", "../outside", 0o777)
+
+	return root
+}
+
+func restoreSymlinkTestTree(t *testing.T, action SymlinkAction) (string, Stats) {
+	t.Helper()
+
+	ctx := testlogging.Context(t)
+	target := testutil.TempDirectory(t)
+
+	st, err := Entry(ctx, nil, &FilesystemOutput{
+		TargetPath:        target,
+		OverwriteSymlinks: true,
+		SkipOwners:        true,
+	}, symlinkTestTree(), Options{Symlinks: action})
+	require.NoError(t, err)
+
+	return target, st
+}
+
+func skipOnWindows(t *testing.T) {
+	t.Helper()
+
+	if isWindows() {
+		t.Skip("creating symlinks requires elevated privileges on Windows")
+	}
+}
+
+func requireSymlink(t *testing.T, fname, wantTarget string) {
+	t.Helper()
+
+	got, err := os.Readlink(fname)
+	require.NoError(t, err)
+	require.Equal(t, wantTarget, got)
+}
+
+func requireFileContents(t *testing.T, fname, want string) {
+	t.Helper()
+
+	st, err := os.Lstat(fname)
+	require.NoError(t, err)
+	require.True(t, st.Mode().IsRegular(), "%v is not a regular file", fname)
+
+	got, err := os.ReadFile(fname)
+	require.NoError(t, err)
+	require.Equal(t, want, string(got))
+}
+
+func TestRestoreSymlinks(t *testing.T) {
+	skipOnWindows(t)
+
+	target, st := restoreSymlinkTestTree(t, SymlinkRestore)
+	require.EqualValues(t, 7, st.RestoredSymlinkCount)
+	requireSymlink(t, filepath.Join(target, "lnk-abs"), "/etc/passwd")
+	requireSymlink(t, filepath.Join(target, "lnk-rel"), "d/f")
+}
+
+func TestRestoreSymlinksSkip(t *testing.T) {
+	target, st := restoreSymlinkTestTree(t, SymlinkSkip)
+	require.EqualValues(t, 0, st.RestoredSymlinkCount)
+	require.EqualValues(t, 7, st.SkippedCount)
+
+	_, err := os.Lstat(filepath.Join(target, "lnk-rel"))
+	require.True(t, os.IsNotExist(err))
+	requireFileContents(t, filepath.Join(target, "d", "f"), "hello")
+}
+
+func TestRestoreSymlinksRewriteAbsolute(t *testing.T) {
+	skipOnWindows(t)
+
+	target, _ := restoreSymlinkTestTree(t, SymlinkRewriteAbsolute)
+	requireSymlink(t, filepath.Join(target, "lnk-abs"), "etc/passwd")
+	requireSymlink(t, filepath.Join(target, "lnk-rel"), "d/f")
+	requireSymlink(t, filepath.Join(target, "d", "sub", "up"), "../..")
+}
+
+func TestRestoreSymlinksFollow(t *testing.T) {
+	target, st := restoreSymlinkTestTree(t, SymlinkFollow)
+
+	require.EqualValues(t, 0, st.RestoredSymlinkCount)
+
+	requireFileContents(t, filepath.Join(target, "lnk-rel"), "hello")
+	requireFileContents(t, filepath.Join(target, "lnk-chain"), "hello")
+	requireFileContents(t, filepath.Join(target, "lnk-dir", "g"), "world")
+
+	// links pointing outside of the tree, to missing entries and to ancestor directories are skipped.
+	for _, fname := range []string{"lnk-abs", "lnk-missing", "lnk-outside", "d/sub/up", "lnk-dir/up"} {
+		_, err := os.Lstat(filepath.Join(target, filepath.FromSlash(fname)))
+		require.True(t, os.IsNotExist(err), "%v should not exist", fname)
+	}
+
+	// 5 links at top level and 2 instances of d/sub/up
+	require.EqualValues(t, 5, st.SkippedCount)
+}
+
+func TestRestoreSymlinksFollowLoop(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	root := mockfs.NewDirectory()
+	root.AddDir("a", 0o755)
+	root.AddDir("b", 0o755)
+	root.AddSymlink("a/to-b", "../b", 0o777)
+	root.AddSymlink("b/to-a", "../a", 0o777)
+
+	target := testutil.TempDirectory(t)
+
+	_, err := Entry(ctx, nil, &FilesystemOutput{TargetPath: target, SkipOwners: true}, root, Options{Symlinks: SymlinkFollow})
+	require.NoError(t, err)
+
+	require.DirExists(t, filepath.Join(target, "a", "to-b", "to-a"))
+	require.NoDirExists(t, filepath.Join(target, "a", "to-b", "to-a", "to-b"))
+}
+
+func TestRestoreInvalidSymlinkAction(t *testing.T) {
+	_, err := Entry(testlogging.Context(t), nil, &FilesystemOutput{TargetPath: testutil.TempDirectory(t)}, mockfs.NewDirectory(), Options{Symlinks: "foo"})
+	require.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package snapshot
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+// RunResultManifestType is the value of the "type" label for manifests recording results of snapshot runs.
+const RunResultManifestType = "snapshot-run"
+
+// DefaultMaxRunResultsPerSource is the default number of most recent run results retained for each source.
+const DefaultMaxRunResultsPerSource = 100
+
+// RunResult records the outcome of a single attempt to snapshot a source, including failed attempts
+// that did not produce a snapshot manifest.
+type RunResult struct {
+	ID     manifest.ID `json:"id,omitempty"`
+	Source SourceInfo  `json:"source"`
+
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+
+	// SnapshotID is the ID of the snapshot manifest created by the run, if any.
+	SnapshotID       manifest.ID `json:"snapshotID,omitempty"`
+	IncompleteReason string      `json:"incomplete,omitempty"`
+
+	HashedFiles   int32 `json:"hashedFiles"`
+	CachedFiles   int32 `json:"cachedFiles"`
+	HashedBytes   int64 `json:"hashedBytes"`
+	CachedBytes   int64 `json:"cachedBytes"`
+	UploadedBytes int64 `json:"uploadedBytes"`
+
+	IgnoredErrorCount int32 `json:"ignoredErrors"`
+	FatalErrorCount   int32 `json:"errors"`
+
+	// Error is the error which caused the run to fail.
+	Error string `json:"error,omitempty"`
+}
+
+// Duration returns the duration of the run.
+func (r *RunResult) Duration() time.Duration {
+	return r.EndTime.Sub(r.StartTime)
+}
+
+func runResultLabels(si SourceInfo) map[string]string {
+	m := sourceInfoToLabels(si)
+	m[typeKey] = RunResultManifestType
+
+	return m
+}
+
+// SaveRunResult saves the result of a snapshot run and removes the oldest results of the same source
+// beyond maxPerSource (0 == DefaultMaxRunResultsPerSource).
+func SaveRunResult(ctx context.Context, rep repo.RepositoryWriter, r *RunResult, maxPerSource int) (manifest.ID, error) {
+	if r.Source.Host == "" {
+		return "", errors.New("missing host")
+	}
+
+	if maxPerSource <= 0 {
+		maxPerSource = DefaultMaxRunResultsPerSource
+	}
+
+	id, err := rep.PutManifest(ctx, runResultLabels(r.Source), r)
+	if err != nil {
+		return "", errors.Wrap(err, "error putting run result manifest")
+	}
+
+	results, err := ListRunResults(ctx, rep, r.Source)
+	if err != nil {
+		return id, err
+	}
+
+	for _, old := range results {
+		if maxPerSource > 0 {
+			maxPerSource--
+			continue
+		}
+
+		if err := rep.DeleteManifest(ctx, old.ID); err != nil {
+			return id, errors.Wrapf(err, "error deleting old run result %v", old.ID)
+		}
+	}
+
+	return id, nil
+}
+
+// ListRunResults returns recorded results of snapshot runs of a given source, most recent first.
+func ListRunResults(ctx context.Context, rep repo.Repository, si SourceInfo) ([]*RunResult, error) {
+	entries, err := rep.FindManifests(ctx, runResultLabels(si))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find run result manifests")
+	}
+
+	var result []*RunResult
+
+	for _, e := range entries {
+		r := &RunResult{}
+
+		if _, err := rep.GetManifest(ctx, e.ID, r); err != nil {
+			return nil, errors.Wrapf(err, "unable to load run result %v", e.ID)
+		}
+
+		r.ID = e.ID
+		result = append(result, r)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.After(result[j].StartTime)
+	})
+
+	return result, nil
+}
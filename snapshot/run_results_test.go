@@ -0,0 +1,60 @@
+package snapshot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestRunResults(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	si := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/foo"}
+	other := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/bar"}
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		r := &snapshot.RunResult{
+			Source:      si,
+			StartTime:   t0.Add(time.Duration(i) * time.Hour),
+			EndTime:     t0.Add(time.Duration(i) * time.Hour).Add(time.Minute),
+			HashedBytes: int64(i),
+		}
+
+		if i == 3 {
+			r.Error = "some error"
+		}
+
+		_, err := snapshot.SaveRunResult(ctx, env.RepositoryWriter, r, 3)
+		require.NoError(t, err)
+	}
+
+	_, err := snapshot.SaveRunResult(ctx, env.RepositoryWriter, &snapshot.RunResult{Source: other, StartTime: t0}, 3)
+	require.NoError(t, err)
+
+	_, err = snapshot.SaveRunResult(ctx, env.RepositoryWriter, &snapshot.RunResult{}, 3)
+	require.Error(t, err)
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	// results survive reopening the repository.
+	rep := env.MustOpenAnother(t)
+
+	results, err := snapshot.ListRunResults(ctx, rep, si)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	require.Equal(t, int64(4), results[0].HashedBytes)
+	require.Equal(t, "some error", results[1].Error)
+	require.Equal(t, int64(2), results[2].HashedBytes)
+	require.Equal(t, time.Minute, results[0].Duration())
+	require.NotEmpty(t, results[0].ID)
+
+	results, err = snapshot.ListRunResults(ctx, rep, other)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+}
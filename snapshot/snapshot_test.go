@@ -212,6 +212,7 @@ func TestParseSourceInfo(t *testing.T) {
 		{"/some/path/../other-path", snapshot.SourceInfo{UserName: "default-user", Host: "default-host", Path: mustAbs(t, "/some/other-path")}},
 		{"@some-host", snapshot.SourceInfo{Host: "some-host"}},
 		{"some-user@some-host", snapshot.SourceInfo{UserName: "some-user", Host: "some-host"}},
+		{"some-user@", snapshot.SourceInfo{UserName: "some-user"}},
 	}
 
 	for _, tc := range cases {
@@ -0,0 +1,24 @@
+// Package snapshotarchive implements portable, self-contained and encrypted archives of snapshots,
+// which can be used to transfer snapshots between repositories without network connectivity.
+package snapshotarchive
+
+import (
+	"github.com/kopia/kopia/internal/encryptedarchive"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("snapshotarchive")
+
+// Archives use the encryptedarchive format. Contents and manifests are stored in records following each other,
+// the last record is always recordTypeEnd.
+const (
+	archiveMagic   = "KOPIAARC"
+	archiveVersion = 1
+
+	recordTypeContent  = 1
+	recordTypeManifest = 2
+	recordTypeEnd      = 3
+)
+
+// ErrInvalidPassword is returned when archive password is invalid.
+var ErrInvalidPassword = encryptedarchive.ErrInvalidPassword
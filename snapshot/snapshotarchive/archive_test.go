@@ -0,0 +1,125 @@
+package snapshotarchive_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotarchive"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+const archivePassword = "archive-password"
+
+func TestExportImport(t *testing.T) {
+	ctx, srcEnv := repotesting.NewEnvironment(t)
+	_, dstEnv := repotesting.NewEnvironment(t)
+
+	si := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/foo"}
+
+	dir := mockfs.NewDirectory()
+	dir.AddDir("d1", 0o755)
+	dir.AddFile("d1/f1", []byte{1, 2, 3, 4}, 0o644)
+	dir.AddFile("f2", bytes.Repeat([]byte("hello"), 100000), 0o644)
+
+	m1 := createSnapshot(ctx, t, srcEnv.RepositoryWriter, dir, si, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	dir.AddFile("f3", []byte("new file"), 0o644)
+
+	m2 := createSnapshot(ctx, t, srcEnv.RepositoryWriter, dir, si, time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	var buf bytes.Buffer
+
+	st, err := snapshotarchive.Export(ctx, srcEnv.RepositoryWriter, []*snapshot.Manifest{m1, m2}, &buf, archivePassword)
+	require.NoError(t, err)
+	require.Equal(t, 2, st.Snapshots)
+	require.NotZero(t, st.Contents)
+
+	data := buf.Bytes()
+
+	_, err = snapshotarchive.Open(ctx, bytes.NewReader(data), "wrong-password")
+	require.ErrorIs(t, err, snapshotarchive.ErrInvalidPassword)
+
+	_, err = snapshotarchive.Open(ctx, bytes.NewReader(data[0:len(data)-10]), archivePassword)
+	require.Error(t, err)
+
+	a, err := snapshotarchive.Open(ctx, bytes.NewReader(data), archivePassword)
+	require.NoError(t, err)
+	require.Equal(t, *st, a.Stats())
+
+	archived, err := a.Snapshots(ctx)
+	require.NoError(t, err)
+	require.Len(t, archived, 2)
+	require.Equal(t, m1.RootObjectID(), archived[0].RootObjectID())
+	require.Equal(t, m2.RootObjectID(), archived[1].RootObjectID())
+
+	ist, err := a.Import(ctx, snapshotfs.NewUploader(dstEnv.RepositoryWriter), dstEnv.RepositoryWriter)
+	require.NoError(t, err)
+	require.Equal(t, snapshotarchive.ImportStats{Imported: 2}, *ist)
+	require.NoError(t, dstEnv.RepositoryWriter.Flush(ctx))
+
+	imported, err := snapshot.ListSnapshots(ctx, dstEnv.RepositoryWriter, si)
+	require.NoError(t, err)
+	require.Len(t, imported, 2)
+
+	for _, m := range imported {
+		require.Equal(t, "exported", m.Description)
+
+		if m.StartTime.Equal(m2.StartTime) {
+			verifyFileContents(ctx, t, dstEnv.RepositoryWriter, m, "f3", []byte("new file"))
+		}
+
+		verifyFileContents(ctx, t, dstEnv.RepositoryWriter, m, "d1/f1", []byte{1, 2, 3, 4})
+		verifyFileContents(ctx, t, dstEnv.RepositoryWriter, m, "f2", bytes.Repeat([]byte("hello"), 100000))
+	}
+
+	// importing again skips existing snapshots.
+	ist, err = a.Import(ctx, snapshotfs.NewUploader(dstEnv.RepositoryWriter), dstEnv.RepositoryWriter)
+	require.NoError(t, err)
+	require.Equal(t, snapshotarchive.ImportStats{Skipped: 2}, *ist)
+}
+
+func createSnapshot(ctx context.Context, t *testing.T, rep repo.RepositoryWriter, e fs.Entry, si snapshot.SourceInfo, startTime time.Time) *snapshot.Manifest {
+	t.Helper()
+
+	man, err := snapshotfs.NewUploader(rep).Upload(ctx, e, nil, si)
+	require.NoError(t, err)
+
+	man.StartTime = startTime
+	man.EndTime = startTime.Add(time.Minute)
+	man.Description = "exported"
+
+	_, err = snapshot.SaveSnapshot(ctx, rep, man)
+	require.NoError(t, err)
+
+	return man
+}
+
+func verifyFileContents(ctx context.Context, t *testing.T, rep repo.Repository, m *snapshot.Manifest, path string, want []byte) {
+	t.Helper()
+
+	root, err := snapshotfs.SnapshotRoot(rep, m)
+	require.NoError(t, err)
+
+	e, err := snapshotfs.GetNestedEntry(ctx, root, strings.Split(path, "/"))
+	require.NoError(t, err)
+
+	r, err := e.(fs.File).Open(ctx)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	got, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
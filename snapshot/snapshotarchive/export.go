@@ -0,0 +1,147 @@
+package snapshotarchive
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/encryptedarchive"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// Stats describes the contents of an archive.
+type Stats struct {
+	Snapshots int   `json:"snapshots"`
+	Contents  int   `json:"contents"`
+	Bytes     int64 `json:"bytes"`
+}
+
+// archivedManifest is the payload of manifest record.
+type archivedManifest struct {
+	Metadata *manifest.EntryMetadata `json:"metadata"`
+	Payload  json.RawMessage         `json:"payload"`
+}
+
+// Export writes an encrypted archive containing provided snapshot manifests and all contents
+// referenced by them to the provided output.
+func Export(ctx context.Context, rep repo.DirectRepository, manifests []*snapshot.Manifest, output io.Writer, password string) (*Stats, error) {
+	w, err := encryptedarchive.NewWriter(output, archiveMagic, archiveVersion, password)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Stats{}
+
+	for _, m := range manifests {
+		var payload json.RawMessage
+
+		md, err := rep.GetManifest(ctx, m.ID, &payload)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading manifest %v", m.ID)
+		}
+
+		b, err := json.Marshal(archivedManifest{md, payload})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to serialize manifest")
+		}
+
+		if err := w.WriteRecord(recordTypeManifest, b); err != nil {
+			return nil, err
+		}
+
+		st.Snapshots++
+	}
+
+	contentIDs, err := findReferencedContentIDs(ctx, rep, manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	log(ctx).Infof("Exporting %v contents of %v snapshots...", len(contentIDs), len(manifests))
+
+	for _, cid := range contentIDs {
+		if len(cid) > 255 { // nolint:gomnd
+			return nil, errors.Errorf("invalid content ID %v", cid)
+		}
+
+		data, err := rep.ContentReader().GetContent(ctx, cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading content %v", cid)
+		}
+
+		if err := w.WriteRecord(recordTypeContent, []byte{byte(len(cid))}, []byte(cid), data); err != nil {
+			return nil, err
+		}
+
+		st.Contents++
+		st.Bytes += int64(len(data))
+	}
+
+	b, err := json.Marshal(st)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to serialize archive stats")
+	}
+
+	if err := w.WriteRecord(recordTypeEnd, b); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// findReferencedContentIDs returns sorted IDs of all contents referenced by the provided snapshots.
+func findReferencedContentIDs(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest) ([]content.ID, error) {
+	var used sync.Map
+
+	w := snapshotfs.NewTreeWalker()
+	w.EntryID = func(e fs.Entry) interface{} { return e.(object.HasObjectID).ObjectID() }
+
+	for _, m := range manifests {
+		root, err := snapshotfs.SnapshotRoot(rep, m)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get snapshot root")
+		}
+
+		w.RootEntries = append(w.RootEntries, root)
+	}
+
+	w.ObjectCallback = func(entry fs.Entry) error {
+		oid := entry.(object.HasObjectID).ObjectID()
+
+		contentIDs, err := rep.VerifyObject(ctx, oid)
+		if err != nil {
+			return errors.Wrapf(err, "error verifying %v", oid)
+		}
+
+		for _, cid := range contentIDs {
+			used.Store(cid, nil)
+		}
+
+		return nil
+	}
+
+	if err := w.Run(ctx); err != nil {
+		return nil, errors.Wrap(err, "error walking snapshot tree")
+	}
+
+	var result []content.ID
+
+	used.Range(func(k, _ interface{}) bool {
+		result = append(result, k.(content.ID))
+		return true
+	})
+
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+
+	return result, nil
+}
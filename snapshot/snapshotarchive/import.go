@@ -0,0 +1,284 @@
+package snapshotarchive
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/encryptedarchive"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/snapshotreplication"
+)
+
+// Archive is a read-only view of an opened snapshot archive, which implements repo.Repository
+// so that archived snapshots can be browsed and read using regular snapshot APIs.
+type Archive struct {
+	r         io.ReaderAt
+	rr        *encryptedarchive.Reader
+	contents  map[content.ID]encryptedarchive.Location
+	manifests map[manifest.ID]*archivedManifest
+	stats     Stats
+}
+
+// Open opens the archive stored in the provided reader and verifies its integrity.
+func Open(ctx context.Context, r io.ReaderAt, password string) (*Archive, error) {
+	rr, err := encryptedarchive.NewReader(r, archiveMagic, archiveVersion, password)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{
+		r:         r,
+		rr:        rr,
+		contents:  map[content.ID]encryptedarchive.Location{},
+		manifests: map[manifest.ID]*archivedManifest{},
+	}
+
+	loc := rr.FirstRecord()
+
+	for {
+		recordType, payload, next, err := rr.ReadRecord(loc)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("archive is truncated")
+			}
+
+			return nil, err
+		}
+
+		switch recordType {
+		case recordTypeContent:
+			if len(payload) == 0 || len(payload) < 1+int(payload[0]) {
+				return nil, errors.New("invalid content record")
+			}
+
+			a.contents[content.ID(payload[1:1+payload[0]])] = loc
+
+		case recordTypeManifest:
+			am := &archivedManifest{}
+			if err := json.Unmarshal(payload, am); err != nil || am.Metadata == nil {
+				return nil, errors.New("invalid manifest record")
+			}
+
+			a.manifests[am.Metadata.ID] = am
+
+		case recordTypeEnd:
+			if err := json.Unmarshal(payload, &a.stats); err != nil {
+				return nil, errors.New("invalid end record")
+			}
+
+			if a.stats.Contents != len(a.contents) || a.stats.Snapshots != len(a.manifests) {
+				return nil, errors.New("archive is incomplete")
+			}
+
+			log(ctx).Debugf("opened archive with %v snapshots and %v contents", len(a.manifests), len(a.contents))
+
+			return a, nil
+
+		default:
+			return nil, errors.Errorf("unsupported record type %v", recordType)
+		}
+
+		loc = next
+	}
+}
+
+// Stats returns statistics of the archive.
+func (a *Archive) Stats() Stats {
+	return a.stats
+}
+
+// Snapshots returns all snapshot manifests in the archive sorted by source and start time.
+func (a *Archive) Snapshots(ctx context.Context) ([]*snapshot.Manifest, error) {
+	var ids []manifest.ID
+
+	for id := range a.manifests {
+		ids = append(ids, id)
+	}
+
+	result, err := snapshot.LoadSnapshots(ctx, a, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load snapshots")
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if si, sj := result[i].Source.String(), result[j].Source.String(); si != sj {
+			return si < sj
+		}
+
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+
+	return result, nil
+}
+
+// ImportStats describes the result of Import.
+type ImportStats struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// Import uploads all snapshots from the archive to the provided repository using the provided uploader.
+// Snapshots of the same source with the same start time that already exist in the repository are skipped.
+func (a *Archive) Import(ctx context.Context, uploader *snapshotfs.Uploader, rep repo.RepositoryWriter) (*ImportStats, error) {
+	snapshots, err := a.Snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &ImportStats{}
+
+	for _, m := range snapshots {
+		if uploader.IsCanceled() {
+			break
+		}
+
+		imported, err := a.importSnapshot(ctx, uploader, rep, m)
+		if err != nil {
+			return nil, err
+		}
+
+		if imported {
+			st.Imported++
+		} else {
+			st.Skipped++
+		}
+	}
+
+	return st, nil
+}
+
+func (a *Archive) importSnapshot(ctx context.Context, uploader *snapshotfs.Uploader, rep repo.RepositoryWriter, m *snapshot.Manifest) (bool, error) {
+	if m.IncompleteReason != "" {
+		return false, nil
+	}
+
+	// nolint:wrapcheck
+	return snapshotreplication.CopySnapshot(ctx, uploader, a, rep, m)
+}
+
+// ContentInfo implements object content reader.
+func (a *Archive) ContentInfo(ctx context.Context, contentID content.ID) (content.Info, error) {
+	data, err := a.GetContent(ctx, contentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &content.InfoStruct{
+		ContentID:      contentID,
+		OriginalLength: uint32(len(data)),
+	}, nil
+}
+
+// GetContent implements object content reader.
+func (a *Archive) GetContent(ctx context.Context, contentID content.ID) ([]byte, error) {
+	loc, ok := a.contents[contentID]
+	if !ok {
+		return nil, content.ErrContentNotFound
+	}
+
+	recordType, payload, _, err := a.rr.ReadRecord(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	if recordType != recordTypeContent || len(payload) < 1+int(payload[0]) || content.ID(payload[1:1+payload[0]]) != contentID {
+		return nil, errors.Errorf("invalid content record for %v", contentID)
+	}
+
+	return payload[1+payload[0]:], nil
+}
+
+// OpenObject implements repo.Repository.
+func (a *Archive) OpenObject(ctx context.Context, id object.ID) (object.Reader, error) {
+	// nolint:wrapcheck
+	return object.Open(ctx, a, id)
+}
+
+// VerifyObject implements repo.Repository.
+func (a *Archive) VerifyObject(ctx context.Context, id object.ID) ([]content.ID, error) {
+	// nolint:wrapcheck
+	return object.VerifyObject(ctx, a, id)
+}
+
+// GetManifest implements repo.Repository.
+func (a *Archive) GetManifest(ctx context.Context, id manifest.ID, data interface{}) (*manifest.EntryMetadata, error) {
+	am := a.manifests[id]
+	if am == nil {
+		return nil, manifest.ErrNotFound
+	}
+
+	if err := json.Unmarshal(am.Payload, data); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal manifest %v", id)
+	}
+
+	return am.Metadata, nil
+}
+
+// FindManifests implements repo.Repository.
+func (a *Archive) FindManifests(ctx context.Context, labels map[string]string) ([]*manifest.EntryMetadata, error) {
+	var result []*manifest.EntryMetadata
+
+	for _, am := range a.manifests {
+		if matchesLabels(am.Metadata.Labels, labels) {
+			result = append(result, am.Metadata)
+		}
+	}
+
+	return result, nil
+}
+
+func matchesLabels(actual, want map[string]string) bool {
+	for k, v := range want {
+		if actual[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Time implements repo.Repository.
+func (a *Archive) Time() time.Time {
+	return clock.Now()
+}
+
+// ClientOptions implements repo.Repository.
+func (a *Archive) ClientOptions() repo.ClientOptions {
+	return repo.ClientOptions{}
+}
+
+// NewWriter implements repo.Repository, archives are read-only.
+func (a *Archive) NewWriter(ctx context.Context, opt repo.WriteSessionOptions) (repo.RepositoryWriter, error) {
+	return nil, errors.New("snapshot archive is read-only")
+}
+
+// UpdateDescription implements repo.Repository.
+func (a *Archive) UpdateDescription(d string) {
+}
+
+// Refresh implements repo.Repository.
+func (a *Archive) Refresh(ctx context.Context) error {
+	return nil
+}
+
+// Close implements repo.Repository and closes the underlying reader, if possible.
+func (a *Archive) Close(ctx context.Context) error {
+	if c, ok := a.r.(io.Closer); ok {
+		return errors.Wrap(c.Close(), "error closing archive")
+	}
+
+	return nil
+}
+
+var _ repo.Repository = (*Archive)(nil)
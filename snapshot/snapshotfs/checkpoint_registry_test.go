@@ -63,25 +63,42 @@ func TestCheckpointRegistry(t *testing.T) {
 		t.Fatalf("error running checkpoints: %v", err)
 	}
 
-	dm := dmb.Build(clock.Now(), "checkpoint")
-	if got, want := len(dm.Entries), 4; got != want {
-		t.Fatalf("got %v entries, wanted %v (%+#v)", got, want, dm.Entries)
+	defer dmb.release()
+
+	dm, err := dmb.Build(clock.Now(), "checkpoint")
+	if err != nil {
+		t.Fatalf("error building directory manifest: %v", err)
+	}
+
+	defer dm.release()
+
+	var entries []*snapshot.DirEntry
+
+	if err := dm.iterate(func(e *snapshot.DirEntry) error {
+		entries = append(entries, e)
+		return nil
+	}); err != nil {
+		t.Fatalf("error iterating entries: %v", err)
+	}
+
+	if got, want := len(entries), 4; got != want {
+		t.Fatalf("got %v entries, wanted %v (%+#v)", got, want, entries)
 	}
 
 	// directory names don't get mangled
-	if dm.Entries[0].Name != "dir1" {
-		t.Errorf("invalid entry %v", dm.Entries[0])
+	if entries[0].Name != "dir1" {
+		t.Errorf("invalid entry %v", entries[0])
 	}
 
-	if !strings.HasPrefix(dm.Entries[1].Name, ".checkpointed.f1.") {
-		t.Errorf("invalid entry %v", dm.Entries[1])
+	if !strings.HasPrefix(entries[1].Name, ".checkpointed.f1.") {
+		t.Errorf("invalid entry %v", entries[1])
 	}
 
-	if !strings.HasPrefix(dm.Entries[2].Name, ".checkpointed.f2.") {
-		t.Errorf("invalid entry %v", dm.Entries[2])
+	if !strings.HasPrefix(entries[2].Name, ".checkpointed.f2.") {
+		t.Errorf("invalid entry %v", entries[2])
 	}
 
-	if dm.Entries[3].Name != "pre-existing" {
-		t.Errorf("invalid entry %v", dm.Entries[3])
+	if entries[3].Name != "pre-existing" {
+		t.Errorf("invalid entry %v", entries[3])
 	}
 }
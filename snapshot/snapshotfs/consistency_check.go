@@ -0,0 +1,228 @@
+package snapshotfs
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/iocopy"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// ConsistencyCheckOptions provides options for CheckNewSnapshotData.
+type ConsistencyCheckOptions struct {
+	// FilesPercent is the percentage of new files whose contents are read in full [0..100],
+	// contents of remaining new files are only verified to exist.
+	FilesPercent int
+
+	// MaxBytes limits the total amount of data read (0 == unlimited).
+	MaxBytes int64
+}
+
+// ConsistencyError describes an entry of the snapshot that failed verification.
+type ConsistencyError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// ConsistencyCheckResult contains results of CheckNewSnapshotData.
+type ConsistencyCheckResult struct {
+	DirCount  int                `json:"dirCount"`
+	FileCount int                `json:"fileCount"`
+	ReadCount int                `json:"readCount"`
+	ReadBytes int64              `json:"readBytes"`
+	Errors    []ConsistencyError `json:"errors,omitempty"`
+}
+
+// CheckNewSnapshotData verifies data written by the snapshot, which is not shared with any of the
+// previous snapshots of the same source. Directories whose object IDs are unchanged since previous
+// snapshots are not examined, all new directories are read and contents of new files are verified to
+// exist in the repository, while a random sample of new files is read in full.
+func CheckNewSnapshotData(ctx context.Context, rep repo.Repository, m *snapshot.Manifest, previous []*snapshot.Manifest, opt ConsistencyCheckOptions) (*ConsistencyCheckResult, error) {
+	cc := &consistencyChecker{
+		rep:    rep,
+		opt:    opt,
+		result: &ConsistencyCheckResult{},
+	}
+
+	root, err := SnapshotRoot(rep, m)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get snapshot root")
+	}
+
+	dir, ok := root.(fs.Directory)
+	if !ok {
+		cc.checkFile(ctx, root, ".")
+		return cc.result, nil
+	}
+
+	var prevDirs []fs.Directory
+
+	for _, pm := range previous {
+		pd, err := snapshotRootDirectory(rep, pm)
+		if err != nil {
+			return nil, err
+		}
+
+		if pd == nil {
+			continue
+		}
+
+		if pm.RootObjectID() == m.RootObjectID() {
+			// nothing changed since previous snapshot.
+			return cc.result, nil
+		}
+
+		prevDirs = append(prevDirs, pd)
+	}
+
+	if err := cc.checkDirectory(ctx, dir, prevDirs, "."); err != nil {
+		return nil, err
+	}
+
+	return cc.result, nil
+}
+
+type consistencyChecker struct {
+	rep    repo.Repository
+	opt    ConsistencyCheckOptions
+	result *ConsistencyCheckResult
+}
+
+func (cc *consistencyChecker) reportError(p string, err error) {
+	cc.result.Errors = append(cc.result.Errors, ConsistencyError{p, err.Error()})
+}
+
+// previousEntries returns entries of previous versions of a directory indexed by name,
+// directories which can't be read are ignored and their entries treated as new.
+func previousEntries(ctx context.Context, prevDirs []fs.Directory) []map[string]fs.Entry {
+	var result []map[string]fs.Entry
+
+	for _, pd := range prevDirs {
+		entries, err := pd.Readdir(ctx)
+		if err != nil {
+			log(ctx).Debugf("unable to read previous directory %v: %v", pd.Name(), err)
+			continue
+		}
+
+		m := map[string]fs.Entry{}
+
+		for _, e := range entries {
+			m[e.Name()] = e
+		}
+
+		result = append(result, m)
+	}
+
+	return result
+}
+
+func (cc *consistencyChecker) checkDirectory(ctx context.Context, dir fs.Directory, prevDirs []fs.Directory, dirPath string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "consistency check canceled")
+	}
+
+	cc.result.DirCount++
+
+	entries, err := dir.Readdir(ctx)
+	if err != nil {
+		cc.reportError(dirPath, err)
+		return nil
+	}
+
+	prev := previousEntries(ctx, prevDirs)
+
+nextEntry:
+	for _, e := range entries {
+		p := path.Join(dirPath, e.Name())
+		oid := e.(object.HasObjectID).ObjectID()
+
+		var prevSubdirs []fs.Directory
+
+		for _, pe := range prev {
+			old, ok := pe[e.Name()]
+			if !ok {
+				continue
+			}
+
+			if old.(object.HasObjectID).ObjectID() == oid {
+				// unchanged since previous snapshot.
+				continue nextEntry
+			}
+
+			if od, ok := old.(fs.Directory); ok {
+				prevSubdirs = append(prevSubdirs, od)
+			}
+		}
+
+		if sd, ok := e.(fs.Directory); ok {
+			if err := cc.checkDirectory(ctx, sd, prevSubdirs, p); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		cc.checkFile(ctx, e, p)
+	}
+
+	return nil
+}
+
+func (cc *consistencyChecker) checkFile(ctx context.Context, e fs.Entry, p string) {
+	h, ok := e.(object.HasObjectID)
+	if !ok {
+		return
+	}
+
+	if _, isSymlink := e.(fs.Symlink); !isSymlink {
+		cc.result.FileCount++
+	}
+
+	oid := h.ObjectID()
+
+	if _, err := cc.rep.VerifyObject(ctx, oid); err != nil {
+		cc.reportError(p, errors.Wrapf(err, "error verifying %v", oid))
+		return
+	}
+
+	if !cc.shouldRead(e.Size()) {
+		return
+	}
+
+	n, err := cc.readObject(ctx, oid)
+	cc.result.ReadCount++
+	cc.result.ReadBytes += n
+
+	if err != nil {
+		cc.reportError(p, err)
+	}
+}
+
+func (cc *consistencyChecker) shouldRead(size int64) bool {
+	if cc.opt.MaxBytes > 0 && cc.result.ReadBytes+size > cc.opt.MaxBytes {
+		return false
+	}
+
+	//nolint:gomnd,gosec
+	return rand.Intn(100) < cc.opt.FilesPercent
+}
+
+func (cc *consistencyChecker) readObject(ctx context.Context, oid object.ID) (int64, error) {
+	r, err := cc.rep.OpenObject(ctx, oid)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to open object %v", oid)
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	n, err := iocopy.Copy(ioutil.Discard, r)
+
+	return n, errors.Wrapf(err, "error reading object %v", oid)
+}
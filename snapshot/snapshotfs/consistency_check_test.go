@@ -0,0 +1,68 @@
+package snapshotfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestCheckNewSnapshotData(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/a"}
+
+	s1, err := u.Upload(ctx, th.sourceDir, policyTree, src)
+	require.NoError(t, err)
+
+	th.sourceDir.AddFile("d1/d2/f4", []byte{1, 2, 3, 4, 5, 6}, defaultPermissions)
+
+	s2, err := u.Upload(ctx, th.sourceDir, policyTree, src, s1)
+	require.NoError(t, err)
+
+	// everything is new in the first snapshot.
+	res, err := CheckNewSnapshotData(ctx, th.repo, s1, nil, ConsistencyCheckOptions{})
+	require.NoError(t, err)
+	require.Empty(t, res.Errors)
+	require.Equal(t, 6, res.DirCount)
+	require.Equal(t, 10, res.FileCount)
+	require.Equal(t, 0, res.ReadCount)
+
+	// only the added file and its parent directories are checked.
+	res, err = CheckNewSnapshotData(ctx, th.repo, s2, []*snapshot.Manifest{s1}, ConsistencyCheckOptions{FilesPercent: 100})
+	require.NoError(t, err)
+	require.Empty(t, res.Errors)
+	require.Equal(t, 3, res.DirCount)
+	require.Equal(t, 1, res.FileCount)
+	require.Equal(t, 1, res.ReadCount)
+	require.Equal(t, int64(6), res.ReadBytes)
+
+	// reads are bounded.
+	res, err = CheckNewSnapshotData(ctx, th.repo, s1, nil, ConsistencyCheckOptions{FilesPercent: 100, MaxBytes: 8})
+	require.NoError(t, err)
+	require.Empty(t, res.Errors)
+	require.LessOrEqual(t, res.ReadBytes, int64(8))
+	require.Less(t, res.ReadCount, res.FileCount)
+
+	// missing data is reported.
+	bad := &snapshot.Manifest{
+		Source: src,
+		RootEntry: &snapshot.DirEntry{
+			Name:     "bad",
+			Type:     snapshot.EntryTypeFile,
+			ObjectID: "k0123456789abcdef0123456789abcdef",
+		},
+	}
+
+	res, err = CheckNewSnapshotData(ctx, th.repo, bad, []*snapshot.Manifest{s1}, ConsistencyCheckOptions{})
+	require.NoError(t, err)
+	require.Len(t, res.Errors, 1)
+}
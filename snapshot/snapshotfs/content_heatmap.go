@@ -0,0 +1,227 @@
+package snapshotfs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// Supported heatmap granularities.
+const (
+	HeatmapGranularityDay   = "day"
+	HeatmapGranularityMonth = "month"
+	HeatmapGranularityYear  = "year"
+)
+
+// HeatmapGranularities lists supported heatmap granularities.
+var HeatmapGranularities = []string{
+	HeatmapGranularityDay,
+	HeatmapGranularityMonth,
+	HeatmapGranularityYear,
+}
+
+var heatmapBucketFormats = map[string]string{
+	HeatmapGranularityDay:   "2006-01-02",
+	HeatmapGranularityMonth: "2006-01",
+	HeatmapGranularityYear:  "2006",
+}
+
+// ContentHeatmapCell aggregates contents created in the same time bucket and last referenced
+// by snapshots started in the same time bucket.
+type ContentHeatmapCell struct {
+	Created string `json:"created"`
+	// LastReferenced is empty for contents not referenced by any of the analyzed snapshots.
+	LastReferenced string `json:"lastReferenced,omitempty"`
+	Count          int64  `json:"count"`
+	PackedBytes    int64  `json:"packedBytes"`
+}
+
+// ContentHeatmap describes the distribution of contents by their creation time and by time
+// of the most recent snapshot referencing them, which shows how much of the stored data is still
+// in active use (hot) and how much is only retained by old snapshots (cold).
+type ContentHeatmap struct {
+	Granularity string                `json:"granularity"`
+	Cells       []*ContentHeatmapCell `json:"cells"`
+}
+
+// CreatedBuckets returns sorted list of distinct creation time buckets.
+func (h *ContentHeatmap) CreatedBuckets() []string {
+	return h.distinctBuckets(func(c *ContentHeatmapCell) string { return c.Created })
+}
+
+// LastReferencedBuckets returns sorted list of distinct last reference buckets, the empty bucket
+// of unreferenced contents comes first.
+func (h *ContentHeatmap) LastReferencedBuckets() []string {
+	return h.distinctBuckets(func(c *ContentHeatmapCell) string { return c.LastReferenced })
+}
+
+func (h *ContentHeatmap) distinctBuckets(key func(c *ContentHeatmapCell) string) []string {
+	found := map[string]bool{}
+
+	var result []string
+
+	for _, c := range h.Cells {
+		k := key(c)
+		if !found[k] {
+			found[k] = true
+
+			result = append(result, k)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// Cell returns the cell for the provided buckets or nil if there are no such contents.
+func (h *ContentHeatmap) Cell(created, lastReferenced string) *ContentHeatmapCell {
+	for _, c := range h.Cells {
+		if c.Created == created && c.LastReferenced == lastReferenced {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// ContentHeatmapOptions provides options for BuildContentHeatmap.
+type ContentHeatmapOptions struct {
+	// Granularity of time buckets, defaults to HeatmapGranularityMonth.
+	Granularity string
+
+	// Parallel is the number of goroutines used to walk snapshots, defaults to walker default.
+	Parallel int
+}
+
+func heatmapBucket(format string, t time.Time) string {
+	return t.UTC().Format(format)
+}
+
+// BuildContentHeatmap buckets all contents of the repository (except manifests) by their creation time and by
+// the start time of the most recent of the provided snapshots that references them.
+func BuildContentHeatmap(ctx context.Context, rep repo.DirectRepository, manifests []*snapshot.Manifest, opt ContentHeatmapOptions) (*ContentHeatmap, error) {
+	if opt.Granularity == "" {
+		opt.Granularity = HeatmapGranularityMonth
+	}
+
+	format, ok := heatmapBucketFormats[opt.Granularity]
+	if !ok {
+		return nil, errors.Errorf("unsupported granularity: %v", opt.Granularity)
+	}
+
+	lastReferenced, err := findLastReferencingSnapshotBuckets(ctx, rep, manifests, format, opt.Parallel)
+	if err != nil {
+		return nil, err
+	}
+
+	type cellKey struct {
+		created, lastReferenced string
+	}
+
+	var mu sync.Mutex
+
+	cells := map[cellKey]*ContentHeatmapCell{}
+
+	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
+		if ci.GetContentID().Prefix() == manifest.ContentPrefix {
+			return nil
+		}
+
+		k := cellKey{created: heatmapBucket(format, ci.Timestamp())}
+
+		if v, ok := lastReferenced.Load(ci.GetContentID()); ok {
+			k.lastReferenced = v.(string)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		c := cells[k]
+		if c == nil {
+			c = &ContentHeatmapCell{Created: k.created, LastReferenced: k.lastReferenced}
+			cells[k] = c
+		}
+
+		c.Count++
+		c.PackedBytes += int64(ci.GetPackedLength())
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating contents")
+	}
+
+	result := &ContentHeatmap{Granularity: opt.Granularity}
+
+	for _, c := range cells {
+		result.Cells = append(result.Cells, c)
+	}
+
+	sort.Slice(result.Cells, func(i, j int) bool {
+		if a, b := result.Cells[i].Created, result.Cells[j].Created; a != b {
+			return a < b
+		}
+
+		return result.Cells[i].LastReferenced < result.Cells[j].LastReferenced
+	})
+
+	return result, nil
+}
+
+// findLastReferencingSnapshotBuckets returns a map of content ID to the time bucket of the most recent
+// snapshot referencing it.
+func findLastReferencingSnapshotBuckets(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest, format string, parallel int) (*sync.Map, error) {
+	var lastReferenced sync.Map
+
+	// walk snapshots from the newest, the walker skips objects already seen in newer snapshots
+	// and the first bucket recorded for each content is the most recent one.
+	var currentBucket string
+
+	w := NewTreeWalker()
+	w.EntryID = func(e fs.Entry) interface{} { return e.(object.HasObjectID).ObjectID() }
+
+	if parallel > 0 {
+		w.Parallelism = parallel
+	}
+
+	w.ObjectCallback = func(entry fs.Entry) error {
+		oid := entry.(object.HasObjectID).ObjectID()
+
+		contentIDs, err := rep.VerifyObject(ctx, oid)
+		if err != nil {
+			return errors.Wrapf(err, "error verifying %v", oid)
+		}
+
+		for _, cid := range contentIDs {
+			lastReferenced.LoadOrStore(cid, currentBucket)
+		}
+
+		return nil
+	}
+
+	for _, m := range snapshot.SortByTime(manifests, true) {
+		root, err := SnapshotRoot(rep, m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get root of snapshot %v", m.ID)
+		}
+
+		currentBucket = heatmapBucket(format, m.StartTime)
+		w.RootEntries = []fs.Entry{root}
+
+		if err := w.Run(ctx); err != nil {
+			return nil, errors.Wrap(err, "error walking snapshot tree")
+		}
+	}
+
+	return &lastReferenced, nil
+}
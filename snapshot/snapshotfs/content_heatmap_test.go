@@ -0,0 +1,63 @@
+package snapshotfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestBuildContentHeatmap(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/a"}
+
+	s1, err := u.Upload(ctx, th.sourceDir, policyTree, src)
+	require.NoError(t, err)
+
+	th.sourceDir.AddFile("f4", []byte{4, 4, 4, 4, 4, 4}, defaultPermissions)
+
+	s2, err := u.Upload(ctx, th.sourceDir, policyTree, src, s1)
+	require.NoError(t, err)
+
+	// s3 is not analyzed, so its new file and root directory are not referenced.
+	th.sourceDir.AddFile("f5", []byte{5, 5, 5, 5, 5, 5, 5}, defaultPermissions)
+
+	_, err = u.Upload(ctx, th.sourceDir, policyTree, src, s2)
+	require.NoError(t, err)
+
+	require.NoError(t, th.repo.Flush(ctx))
+
+	// all contents are created at the same (fake) time, pretend the first snapshot was taken a month before.
+	s1.StartTime = s2.StartTime.AddDate(0, -1, 0)
+
+	_, err = BuildContentHeatmap(ctx, th.repo.(repo.DirectRepository), []*snapshot.Manifest{s1, s2}, ContentHeatmapOptions{Granularity: "week"})
+	require.Error(t, err)
+
+	hm, err := BuildContentHeatmap(ctx, th.repo.(repo.DirectRepository), []*snapshot.Manifest{s1, s2}, ContentHeatmapOptions{})
+	require.NoError(t, err)
+
+	require.Equal(t, HeatmapGranularityMonth, hm.Granularity)
+	require.Equal(t, []string{"2018-02"}, hm.CreatedBuckets())
+	require.Equal(t, []string{"", "2018-01", "2018-02"}, hm.LastReferencedBuckets())
+
+	// root directory of the first snapshot is the only content not referenced by the second one.
+	require.Equal(t, int64(1), hm.Cell("2018-02", "2018-01").Count)
+	require.Equal(t, int64(2), hm.Cell("2018-02", "").Count)
+	require.Nil(t, hm.Cell("2018-01", ""))
+
+	hm, err = BuildContentHeatmap(ctx, th.repo.(repo.DirectRepository), []*snapshot.Manifest{s1, s2}, ContentHeatmapOptions{Granularity: HeatmapGranularityYear})
+	require.NoError(t, err)
+	require.Equal(t, []string{"2018"}, hm.CreatedBuckets())
+	require.Equal(t, []string{"", "2018"}, hm.LastReferencedBuckets())
+	require.Equal(t, int64(2), hm.Cell("2018", "").Count)
+}
@@ -0,0 +1,168 @@
+package snapshotfs
+
+import (
+	"context"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// DeletedEntry describes a file or directory found in older snapshots of a source,
+// which is absent in its latest snapshot.
+type DeletedEntry struct {
+	Path     string    `json:"path"`
+	IsDir    bool      `json:"isDir,omitempty"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	ObjectID object.ID `json:"obj"`
+
+	// most recent snapshot which contains the entry.
+	LastSnapshotID   manifest.ID `json:"lastSnapshotID"`
+	LastSnapshotTime time.Time   `json:"lastSnapshotTime"`
+
+	// RestoreRoot identifies the entry for 'kopia restore' and the restore API.
+	RestoreRoot string `json:"restoreRoot"`
+}
+
+// DeletedEntriesOptions provides options for FindDeletedEntries.
+type DeletedEntriesOptions struct {
+	// MaxResults limits the number of returned entries (0 == unlimited).
+	MaxResults int
+}
+
+// FindDeletedEntries returns files and directories present in older snapshots of a single source,
+// which are absent in the most recent complete snapshot, ordered from the most recently deleted.
+// Contents of deleted directories are not reported separately.
+func FindDeletedEntries(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest, opt DeletedEntriesOptions) ([]*DeletedEntry, error) {
+	var complete []*snapshot.Manifest
+
+	for _, m := range manifests {
+		if m.IncompleteReason == "" {
+			complete = append(complete, m)
+		}
+	}
+
+	if len(complete) < 2 { //nolint:gomnd
+		return nil, nil
+	}
+
+	complete = snapshot.SortByTime(complete, true)
+
+	latestRoot, err := snapshotRootDirectory(rep, complete[0])
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]*DeletedEntry{}
+
+	for _, m := range complete[1:] {
+		root, err := snapshotRootDirectory(rep, m)
+		if err != nil {
+			return nil, err
+		}
+
+		if root == nil || latestRoot == nil {
+			continue
+		}
+
+		if err := findDeletedInDirectory(ctx, root, latestRoot, "", func(p string, e fs.Entry) {
+			// older snapshots are processed later, keep the most recent version.
+			if found[p] != nil {
+				return
+			}
+
+			_, isDir := e.(fs.Directory)
+
+			found[p] = &DeletedEntry{
+				Path:             p,
+				IsDir:            isDir,
+				Size:             e.Size(),
+				ModTime:          e.ModTime(),
+				ObjectID:         e.(object.HasObjectID).ObjectID(),
+				LastSnapshotID:   m.ID,
+				LastSnapshotTime: m.StartTime,
+				RestoreRoot:      m.RootObjectID().String() + "/" + p,
+			}
+		}); err != nil {
+			return nil, errors.Wrapf(err, "error comparing snapshot %v", m.ID)
+		}
+	}
+
+	var result []*DeletedEntry
+
+	for _, e := range found {
+		result = append(result, e)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if a, b := result[i].LastSnapshotTime, result[j].LastSnapshotTime; !a.Equal(b) {
+			return a.After(b)
+		}
+
+		return result[i].Path < result[j].Path
+	})
+
+	if opt.MaxResults > 0 && len(result) > opt.MaxResults {
+		result = result[0:opt.MaxResults]
+	}
+
+	return result, nil
+}
+
+// snapshotRootDirectory returns the root directory of the snapshot or nil if the snapshot is of a single file.
+func snapshotRootDirectory(rep repo.Repository, m *snapshot.Manifest) (fs.Directory, error) {
+	root, err := SnapshotRoot(rep, m)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get root of snapshot %v", m.ID)
+	}
+
+	dir, _ := root.(fs.Directory)
+
+	return dir, nil
+}
+
+// findDeletedInDirectory reports entries of the old directory which are missing in the current one,
+// descending into subdirectories that have changed.
+func findDeletedInDirectory(ctx context.Context, old, current fs.Directory, prefix string, report func(p string, e fs.Entry)) error {
+	currentEntries := map[string]fs.Entry{}
+
+	if err := fs.IterateEntries(ctx, current, func(ctx context.Context, e fs.Entry) error {
+		currentEntries[e.Name()] = e
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "error reading %q", prefix)
+	}
+
+	// nolint:wrapcheck
+	return fs.IterateEntries(ctx, old, func(ctx context.Context, e fs.Entry) error {
+		p := path.Join(prefix, e.Name())
+
+		c, ok := currentEntries[e.Name()]
+		if !ok {
+			report(p, e)
+			return nil
+		}
+
+		oldDir, ok1 := e.(fs.Directory)
+		currentDir, ok2 := c.(fs.Directory)
+
+		if !ok1 || !ok2 {
+			return nil
+		}
+
+		if e.(object.HasObjectID).ObjectID() == c.(object.HasObjectID).ObjectID() {
+			// identical directories.
+			return nil
+		}
+
+		return findDeletedInDirectory(ctx, oldDir, currentDir, p, report)
+	})
+}
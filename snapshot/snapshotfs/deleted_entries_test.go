@@ -0,0 +1,66 @@
+package snapshotfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestFindDeletedEntries(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/a"}
+
+	s1, err := u.Upload(ctx, th.sourceDir, policyTree, src)
+	require.NoError(t, err)
+
+	th.sourceDir.Subdir("d1", "d2").Remove("f1")
+
+	s2, err := u.Upload(ctx, th.sourceDir, policyTree, src, s1)
+	require.NoError(t, err)
+
+	th.sourceDir.Remove("f3")
+	th.sourceDir.Remove("d2")
+
+	s3, err := u.Upload(ctx, th.sourceDir, policyTree, src, s2)
+	require.NoError(t, err)
+
+	// the repository clock does not advance, order snapshots explicitly.
+	s1.StartTime = s3.StartTime.Add(-2 * time.Hour)
+	s2.StartTime = s3.StartTime.Add(-1 * time.Hour)
+
+	entries, err := FindDeletedEntries(ctx, th.repo, []*snapshot.Manifest{s3}, DeletedEntriesOptions{})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	entries, err = FindDeletedEntries(ctx, th.repo, []*snapshot.Manifest{s1, s3, s2}, DeletedEntriesOptions{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	require.Equal(t, "d2", entries[0].Path)
+	require.True(t, entries[0].IsDir)
+	require.Equal(t, s2.ID, entries[0].LastSnapshotID)
+
+	require.Equal(t, "f3", entries[1].Path)
+	require.False(t, entries[1].IsDir)
+	require.Equal(t, int64(5), entries[1].Size)
+	require.Equal(t, s2.RootObjectID().String()+"/f3", entries[1].RestoreRoot)
+
+	require.Equal(t, "d1/d2/f1", entries[2].Path)
+	require.Equal(t, s1.ID, entries[2].LastSnapshotID)
+
+	entries, err = FindDeletedEntries(ctx, th.repo, []*snapshot.Manifest{s1, s3, s2}, DeletedEntriesOptions{MaxResults: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "d2", entries[0].Path)
+}
@@ -0,0 +1,297 @@
+package snapshotfs
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// maxInMemoryDirEntries is the maximum number of entries of a single directory kept in memory by the uploader,
+// entries of larger directories are sorted in runs written to temporary files, which are merged when
+// the directory manifest is written.
+var maxInMemoryDirEntries = 50000 //nolint:gochecknoglobals
+
+// maxSortedRuns is the maximum number of runs merged at once, which bounds the number of open files
+// and read buffers, when it's reached all runs are merged into one.
+var maxSortedRuns = 32 //nolint:gochecknoglobals
+
+// dirEntryLess determines the order of entries in directory manifests, directories first, then non-directories,
+// ordered by name.
+func dirEntryLess(a, b *snapshot.DirEntry) bool {
+	if leftDir, rightDir := isDir(a), isDir(b); leftDir != rightDir {
+		// directories get sorted before non-directories
+		return leftDir
+	}
+
+	return a.Name < b.Name
+}
+
+func sortDirEntries(entries []*snapshot.DirEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return dirEntryLess(entries[i], entries[j])
+	})
+}
+
+// sortedRun is a temporary file with sorted directory entries, shared by clones of dirEntrySorter.
+type sortedRun struct {
+	fname string
+	refs  int32
+}
+
+func (r *sortedRun) addRef() {
+	atomic.AddInt32(&r.refs, 1)
+}
+
+func (r *sortedRun) release() {
+	if atomic.AddInt32(&r.refs, -1) == 0 {
+		os.Remove(r.fname) //nolint:errcheck
+	}
+}
+
+// writeSortedRun writes entries produced by the provided function, which must be sorted, to a new run.
+func writeSortedRun(produce func(callback func(e *snapshot.DirEntry) error) error) (*sortedRun, error) {
+	f, err := ioutil.TempFile("", "kopia-dir-entries-")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create temporary file")
+	}
+
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+
+	err = produce(func(e *snapshot.DirEntry) error {
+		return enc.Encode(e)
+	})
+
+	if err == nil {
+		err = bw.Flush()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(f.Name()) //nolint:errcheck
+
+		return nil, errors.Wrap(err, "unable to write directory entries")
+	}
+
+	return &sortedRun{f.Name(), 1}, nil
+}
+
+// dirEntrySorter collects directory entries and enumerates them in the order of dirEntryLess, keeping
+// at most maxInMemoryDirEntries entries in memory. The zero value is ready to use.
+type dirEntrySorter struct {
+	pending []*snapshot.DirEntry
+	runs    []*sortedRun
+}
+
+func (s *dirEntrySorter) add(de *snapshot.DirEntry) error {
+	s.pending = append(s.pending, de)
+
+	if len(s.pending) < maxInMemoryDirEntries {
+		return nil
+	}
+
+	return s.spill()
+}
+
+// spill writes sorted pending entries to a new run.
+func (s *dirEntrySorter) spill() error {
+	sortDirEntries(s.pending)
+
+	r, err := writeSortedRun(func(callback func(e *snapshot.DirEntry) error) error {
+		for _, e := range s.pending {
+			if err := callback(e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.runs = append(s.runs, r)
+	s.pending = nil
+
+	if len(s.runs) < maxSortedRuns {
+		return nil
+	}
+
+	// merge all runs into one.
+	merged, err := writeSortedRun(func(callback func(e *snapshot.DirEntry) error) error {
+		return mergeSortedRuns(nil, s.runs, callback)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range s.runs {
+		r.release()
+	}
+
+	s.runs = []*sortedRun{merged}
+
+	return nil
+}
+
+// clone returns a copy of the sorter, which shares runs with the original.
+func (s *dirEntrySorter) clone() dirEntrySorter {
+	for _, r := range s.runs {
+		r.addRef()
+	}
+
+	return dirEntrySorter{
+		pending: append([]*snapshot.DirEntry(nil), s.pending...),
+		runs:    append([]*sortedRun(nil), s.runs...),
+	}
+}
+
+// release removes runs that are no longer used by any clone.
+func (s *dirEntrySorter) release() {
+	for _, r := range s.runs {
+		r.release()
+	}
+
+	s.runs = nil
+	s.pending = nil
+}
+
+// iterate invokes the callback for all entries in order.
+func (s *dirEntrySorter) iterate(callback func(e *snapshot.DirEntry) error) error {
+	sortDirEntries(s.pending)
+
+	return mergeSortedRuns(s.pending, s.runs, callback)
+}
+
+// mergeSortedRuns invokes the callback for entries of sorted in-memory entries and sorted runs in order.
+func mergeSortedRuns(pending []*snapshot.DirEntry, runs []*sortedRun, callback func(e *snapshot.DirEntry) error) error {
+	var h mergeHeap
+
+	defer func() {
+		for _, c := range h {
+			c.close()
+		}
+	}()
+
+	if len(pending) > 0 {
+		h = append(h, &mergeCursor{current: pending[0], pending: pending[1:]})
+	}
+
+	for _, r := range runs {
+		f, err := os.Open(r.fname)
+		if err != nil {
+			return errors.Wrap(err, "unable to open directory entries")
+		}
+
+		c := &mergeCursor{f: f, dec: json.NewDecoder(bufio.NewReader(f))}
+
+		ok, err := c.next()
+		if err != nil {
+			c.close()
+			return err
+		}
+
+		if ok {
+			h = append(h, c)
+		} else {
+			c.close()
+		}
+	}
+
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		c := h[0]
+
+		if err := callback(c.current); err != nil {
+			return err
+		}
+
+		ok, err := c.next()
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+			c.close()
+		}
+	}
+
+	return nil
+}
+
+// mergeCursor reads entries of a single run, either in memory or from a file.
+type mergeCursor struct {
+	current *snapshot.DirEntry
+	pending []*snapshot.DirEntry
+
+	f   *os.File
+	dec *json.Decoder
+}
+
+func (c *mergeCursor) next() (bool, error) {
+	if c.dec == nil {
+		if len(c.pending) == 0 {
+			return false, nil
+		}
+
+		c.current, c.pending = c.pending[0], c.pending[1:]
+
+		return true, nil
+	}
+
+	e := &snapshot.DirEntry{}
+
+	if err := c.dec.Decode(e); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+
+		return false, errors.Wrap(err, "unable to read directory entries")
+	}
+
+	c.current = e
+
+	return true, nil
+}
+
+func (c *mergeCursor) close() {
+	if c.f != nil {
+		c.f.Close() //nolint:errcheck
+		c.f = nil
+	}
+}
+
+type mergeHeap []*mergeCursor
+
+func (h mergeHeap) Len() int           { return len(h) }
+func (h mergeHeap) Less(i, j int) bool { return dirEntryLess(h[i].current, h[j].current) }
+func (h mergeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*mergeCursor)) //nolint:forcetypeassert
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+
+	return x
+}
@@ -0,0 +1,173 @@
+package snapshotfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+func withMaxInMemoryDirEntries(t *testing.T, maxEntries, maxRuns int) {
+	t.Helper()
+
+	oldMaxEntries, oldMaxRuns := maxInMemoryDirEntries, maxSortedRuns
+
+	t.Cleanup(func() {
+		maxInMemoryDirEntries, maxSortedRuns = oldMaxEntries, oldMaxRuns
+	})
+
+	maxInMemoryDirEntries, maxSortedRuns = maxEntries, maxRuns
+}
+
+func testDirEntry(i int) *snapshot.DirEntry {
+	de := &snapshot.DirEntry{
+		Name:     fmt.Sprintf("entry-%08d", i),
+		Type:     snapshot.EntryTypeFile,
+		FileSize: int64(i),
+	}
+
+	if i%7 == 0 {
+		de.Type = snapshot.EntryTypeDirectory
+	}
+
+	return de
+}
+
+func iteratedEntries(t *testing.T, c *dirManifestContents) []*snapshot.DirEntry {
+	t.Helper()
+
+	var result []*snapshot.DirEntry
+
+	require.NoError(t, c.iterate(func(e *snapshot.DirEntry) error {
+		result = append(result, e)
+		return nil
+	}))
+
+	return result
+}
+
+func TestDirManifestBuilderSortsSpilledEntries(t *testing.T) {
+	withMaxInMemoryDirEntries(t, 10, 4)
+
+	const numEntries = 1000
+
+	var dmb dirManifestBuilder
+	defer dmb.release()
+
+	for _, i := range rand.Perm(numEntries) {
+		dmb.addEntry(testDirEntry(i))
+
+		if i == numEntries/2 {
+			// checkpoints share runs with the builder, which can be released independently.
+			cp := dmb.Clone()
+			cp.release()
+		}
+	}
+
+	dm, err := dmb.Build(testDirEntry(0).ModTime, "")
+	require.NoError(t, err)
+
+	defer dm.release()
+
+	entries := iteratedEntries(t, dm)
+	require.Len(t, entries, numEntries)
+	require.Equal(t, numEntries, dm.numEntries)
+
+	for i := 1; i < len(entries); i++ {
+		require.True(t, dirEntryLess(entries[i-1], entries[i]), "%v >= %v", entries[i-1].Name, entries[i].Name)
+	}
+
+	require.True(t, isDir(entries[0]))
+	require.False(t, isDir(entries[numEntries-1]))
+
+	// the builder can be used after building the manifest.
+	dmb.addEntry(testDirEntry(numEntries))
+
+	dm2, err := dmb.Build(testDirEntry(0).ModTime, "")
+	require.NoError(t, err)
+
+	defer dm2.release()
+
+	require.Len(t, iteratedEntries(t, dm2), numEntries+1)
+	require.Len(t, iteratedEntries(t, dm), numEntries)
+}
+
+// peakHeapWritingDirManifest returns the peak heap usage observed while adding the provided number
+// of entries to dirManifestBuilder and writing them as a directory manifest.
+func peakHeapWritingDirManifest(t *testing.T, numEntries int) uint64 {
+	t.Helper()
+
+	var peak uint64
+
+	sample := func() {
+		var ms runtime.MemStats
+
+		runtime.GC()
+		runtime.ReadMemStats(&ms)
+
+		if ms.HeapAlloc > peak {
+			peak = ms.HeapAlloc
+		}
+	}
+
+	var dmb dirManifestBuilder
+	defer dmb.release()
+
+	for i := 0; i < numEntries; i++ {
+		dmb.addEntry(testDirEntry(numEntries - i))
+	}
+
+	sample()
+
+	dm, err := dmb.Build(testDirEntry(0).ModTime, "")
+	require.NoError(t, err)
+
+	defer dm.release()
+
+	var written int
+
+	require.NoError(t, snapshot.WriteDirManifestEntries(ioutil.Discard, dm.Summary, func(callback func(e *snapshot.DirEntry) error) error {
+		return dm.iterate(func(e *snapshot.DirEntry) error {
+			written++
+
+			if written == numEntries/2 {
+				sample()
+			}
+
+			return callback(e)
+		})
+	}, true))
+
+	require.Equal(t, numEntries, written)
+
+	return peak
+}
+
+func TestDirManifestMemoryDoesNotGrowWithEntries(t *testing.T) {
+	withMaxInMemoryDirEntries(t, 100, 8)
+
+	const (
+		smallDir = 5000
+		largeDir = 10 * smallDir
+
+		// holding entries of the large directory in memory would take several megabytes.
+		maxGrowth = 1 << 20
+	)
+
+	// warm up
+	peakHeapWritingDirManifest(t, smallDir)
+
+	small := peakHeapWritingDirManifest(t, smallDir)
+	large := peakHeapWritingDirManifest(t, largeDir)
+
+	t.Logf("peak heap: %v entries: %v, %v entries: %v", smallDir, small, largeDir, large)
+
+	if large > small {
+		require.Less(t, large-small, uint64(maxGrowth))
+	}
+}
@@ -0,0 +1,170 @@
+package snapshotfs
+
+import (
+	"context"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// FileLocation identifies a file in a snapshot source.
+type FileLocation struct {
+	Source snapshot.SourceInfo `json:"source"`
+	Path   string              `json:"path"`
+}
+
+func (l FileLocation) String() string {
+	return l.Source.String() + "/" + l.Path
+}
+
+// DuplicateFile describes identical file contents (same object ID) found in multiple locations.
+type DuplicateFile struct {
+	ObjectID  object.ID      `json:"objectID"`
+	Size      int64          `json:"size"`
+	Locations []FileLocation `json:"locations"`
+}
+
+// LogicalSavings returns the number of bytes saved by storing the file once instead of once per location.
+func (d *DuplicateFile) LogicalSavings() int64 {
+	return d.Size * int64(len(d.Locations)-1)
+}
+
+// DuplicateFilesOptions provides options for FindDuplicateFiles.
+type DuplicateFilesOptions struct {
+	// MinSize excludes files smaller than the provided size.
+	MinSize int64
+
+	// MinLocations excludes files found in fewer locations (defaults to 2).
+	MinLocations int
+}
+
+type fileAtLocation struct {
+	oid object.ID
+	loc FileLocation
+}
+
+type duplicateFinder struct {
+	opt     DuplicateFilesOptions
+	files   map[object.ID]*DuplicateFile
+	found   map[fileAtLocation]bool
+	visited map[fileAtLocation]bool
+}
+
+func (f *duplicateFinder) add(oid object.ID, size int64, loc FileLocation) {
+	if size < f.opt.MinSize {
+		return
+	}
+
+	d := f.files[oid]
+	if d == nil {
+		d = &DuplicateFile{ObjectID: oid, Size: size}
+		f.files[oid] = d
+	}
+
+	k := fileAtLocation{oid, loc}
+	if f.found[k] {
+		return
+	}
+
+	f.found[k] = true
+	d.Locations = append(d.Locations, loc)
+}
+
+// shouldVisitDirectory returns false for directories that were already visited at the same location,
+// which is common when analyzing multiple snapshots of the same source.
+func (f *duplicateFinder) shouldVisitDirectory(oid object.ID, loc FileLocation) bool {
+	k := fileAtLocation{oid, loc}
+	if f.visited[k] {
+		return false
+	}
+
+	f.visited[k] = true
+
+	return true
+}
+
+func (f *duplicateFinder) walk(ctx context.Context, e fs.Entry, loc FileLocation) error {
+	h, ok := e.(object.HasObjectID)
+	if !ok {
+		return nil
+	}
+
+	switch e := e.(type) {
+	case fs.Directory:
+		if !f.shouldVisitDirectory(h.ObjectID(), loc) {
+			return nil
+		}
+
+		entries, err := e.Readdir(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "error reading directory %v", loc)
+		}
+
+		for _, child := range entries {
+			if err := f.walk(ctx, child, FileLocation{loc.Source, path.Join(loc.Path, child.Name())}); err != nil {
+				return err
+			}
+		}
+
+	case fs.File:
+		if loc.Path == "" {
+			// snapshot of a single file.
+			loc.Path = e.Name()
+		}
+
+		f.add(h.ObjectID(), e.Size(), loc)
+	}
+
+	return nil
+}
+
+// FindDuplicateFiles returns files with identical contents found in multiple locations in the provided snapshots,
+// sorted by logical savings in descending order.
+// Locations are unique, so a file found at the same path in multiple snapshots of the same source is counted once.
+func FindDuplicateFiles(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest, opt DuplicateFilesOptions) ([]*DuplicateFile, error) {
+	if opt.MinLocations < 2 { // nolint:gomnd
+		opt.MinLocations = 2
+	}
+
+	f := &duplicateFinder{
+		opt:     opt,
+		files:   map[object.ID]*DuplicateFile{},
+		found:   map[fileAtLocation]bool{},
+		visited: map[fileAtLocation]bool{},
+	}
+
+	for _, man := range manifests {
+		root, err := SnapshotRoot(rep, man)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get root of snapshot %v", man.ID)
+		}
+
+		if err := f.walk(ctx, root, FileLocation{Source: man.Source}); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []*DuplicateFile
+
+	for _, d := range f.files {
+		if len(d.Locations) >= opt.MinLocations {
+			result = append(result, d)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if si, sj := result[i].LogicalSavings(), result[j].LogicalSavings(); si != sj {
+			return si > sj
+		}
+
+		return result[i].ObjectID < result[j].ObjectID
+	})
+
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package snapshotfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestFindDuplicateFiles(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	srcA := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/a"}
+	srcB := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/b"}
+
+	a1, err := u.Upload(ctx, th.sourceDir, policyTree, srcA)
+	require.NoError(t, err)
+
+	a2, err := u.Upload(ctx, th.sourceDir, policyTree, srcA, a1)
+	require.NoError(t, err)
+
+	dups, err := FindDuplicateFiles(ctx, th.repo, []*snapshot.Manifest{a1, a2}, DuplicateFilesOptions{})
+	require.NoError(t, err)
+
+	// f2 is stored in 5 locations and f1 in 4, the same locations in multiple snapshots are counted once.
+	require.Len(t, dups, 2)
+	require.Equal(t, int64(4), dups[0].Size)
+	require.Len(t, dups[0].Locations, 5)
+	require.Equal(t, int64(16), dups[0].LogicalSavings())
+	require.Equal(t, int64(3), dups[1].Size)
+	require.Len(t, dups[1].Locations, 4)
+	require.Equal(t, int64(9), dups[1].LogicalSavings())
+	require.Contains(t, dups[1].Locations, FileLocation{srcA, "d1/d2/f1"})
+
+	b1, err := u.Upload(ctx, th.sourceDir, policyTree, srcB)
+	require.NoError(t, err)
+
+	dups, err = FindDuplicateFiles(ctx, th.repo, []*snapshot.Manifest{a1, b1}, DuplicateFilesOptions{MinLocations: 9})
+	require.NoError(t, err)
+	require.Len(t, dups, 1)
+	require.Len(t, dups[0].Locations, 10)
+	require.Contains(t, dups[0].Locations, FileLocation{srcB, "f2"})
+
+	// f3 is unique within each source, but not across sources.
+	dups, err = FindDuplicateFiles(ctx, th.repo, []*snapshot.Manifest{a1, b1}, DuplicateFilesOptions{MinSize: 5})
+	require.NoError(t, err)
+	require.Len(t, dups, 1)
+	require.Equal(t, []FileLocation{{srcA, "f3"}, {srcB, "f3"}}, dups[0].Locations)
+}
@@ -0,0 +1,162 @@
+package snapshotfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+const hashCacheDirMode = 0o700
+
+// HashCache is a local cache mapping files of a single source to object IDs of their contents,
+// which is persisted across runs. It allows the uploader to avoid re-hashing unchanged files
+// when previous snapshot manifests can't be used, e.g. after the repository was reconnected.
+type HashCache struct {
+	mu       sync.Mutex
+	previous map[string]hashCacheEntry
+	current  map[string]hashCacheEntry
+}
+
+// hashCacheEntry identifies contents of a file by its size, modification time and inode number.
+type hashCacheEntry struct {
+	Size     int64     `json:"s"`
+	ModTime  int64     `json:"m"`
+	Inode    uint64    `json:"i,omitempty"`
+	ObjectID object.ID `json:"o"`
+}
+
+// hashCacheContents is the persistent representation of HashCache.
+type hashCacheContents struct {
+	Entries map[string]hashCacheEntry `json:"entries"`
+}
+
+// NewHashCache returns a new empty hash cache.
+func NewHashCache() *HashCache {
+	return &HashCache{
+		previous: map[string]hashCacheEntry{},
+		current:  map[string]hashCacheEntry{},
+	}
+}
+
+// HashCacheFileName returns the name of the file in the provided directory holding the hash cache
+// of a given source in the repository identified by repoID.
+func HashCacheFileName(dir string, repoID []byte, si snapshot.SourceInfo) string {
+	h := sha256.New()
+	h.Write(repoID)              //nolint:errcheck
+	h.Write([]byte{0})           //nolint:errcheck
+	h.Write([]byte(si.String())) //nolint:errcheck
+
+	return filepath.Join(dir, hex.EncodeToString(h.Sum(nil))[0:32]+".json.gz")
+}
+
+// LoadHashCache loads the hash cache from the provided file, a missing file results in an empty cache.
+func LoadHashCache(fname string) (*HashCache, error) {
+	c := NewHashCache()
+
+	f, err := os.Open(fname) //nolint:gosec
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open hash cache")
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open hash cache")
+	}
+
+	var hc hashCacheContents
+
+	if err := json.NewDecoder(zr).Decode(&hc); err != nil {
+		return nil, errors.Wrap(err, "unable to decode hash cache")
+	}
+
+	if hc.Entries != nil {
+		c.previous = hc.Entries
+	}
+
+	return c, nil
+}
+
+// Save writes entries recorded since the cache was loaded to the provided file, so entries of
+// files which no longer exist are dropped.
+func (c *HashCache) Save(fname string) error {
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+
+	c.mu.Lock()
+	err := json.NewEncoder(zw).Encode(hashCacheContents{c.current})
+	c.mu.Unlock()
+
+	if err != nil {
+		return errors.Wrap(err, "unable to encode hash cache")
+	}
+
+	if err := zw.Close(); err != nil {
+		return errors.Wrap(err, "unable to compress hash cache")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fname), hashCacheDirMode); err != nil {
+		return errors.Wrap(err, "unable to create hash cache directory")
+	}
+
+	return errors.Wrap(atomicfile.Write(fname, &buf), "unable to write hash cache")
+}
+
+func newHashCacheEntry(e fs.Entry, oid object.ID) hashCacheEntry {
+	he := hashCacheEntry{
+		Size:     e.Size(),
+		ModTime:  e.ModTime().UnixNano(),
+		ObjectID: oid,
+	}
+
+	if hi, ok := e.(fs.HasInode); ok {
+		he.Inode = hi.Inode()
+	}
+
+	return he
+}
+
+// lookup returns the object ID recorded for the file at the provided path in a previous run,
+// if the file has not changed since.
+func (c *HashCache) lookup(relativePath string, e fs.Entry) (object.ID, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, ok := c.previous[relativePath]
+	if !ok {
+		return "", false
+	}
+
+	want := newHashCacheEntry(e, prev.ObjectID)
+	if want != prev {
+		return "", false
+	}
+
+	return prev.ObjectID, true
+}
+
+// record records the object ID of the file at the provided path.
+func (c *HashCache) record(relativePath string, e fs.Entry, oid object.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.current[relativePath] = newHashCacheEntry(e, oid)
+}
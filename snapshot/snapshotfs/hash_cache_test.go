@@ -0,0 +1,68 @@
+package snapshotfs
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestUploadWithHashCache(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/a"}
+	fname := HashCacheFileName(testutil.TempDirectory(t), []byte("repo"), src)
+
+	hc, err := LoadHashCache(fname)
+	require.NoError(t, err)
+
+	u := NewUploader(th.repo)
+	u.HashCache = hc
+
+	s1, err := u.Upload(ctx, th.sourceDir, policyTree, src)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), s1.Stats.CachedFiles)
+	require.NoError(t, hc.Save(fname))
+
+	th.sourceDir.AddFile("d1/d2/f4", []byte{1, 2, 3, 4, 5, 6, 7}, defaultPermissions)
+
+	// without previous manifests, unchanged files are found in the hash cache.
+	hc, err = LoadHashCache(fname)
+	require.NoError(t, err)
+
+	u = NewUploader(th.repo)
+	u.HashCache = hc
+
+	s2, err := u.Upload(ctx, th.sourceDir, policyTree, src)
+	require.NoError(t, err)
+	require.Equal(t, s1.Stats.NonCachedFiles, s2.Stats.CachedFiles)
+	require.Equal(t, int32(1), s2.Stats.NonCachedFiles)
+
+	// files whose contents are missing from the repository are hashed again.
+	other := newUploadTestHarness(ctx, t)
+	defer other.cleanup()
+
+	hc, err = LoadHashCache(fname)
+	require.NoError(t, err)
+
+	u = NewUploader(other.repo)
+	u.HashCache = hc
+
+	s3, err := u.Upload(ctx, th.sourceDir, policyTree, src)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), s3.Stats.CachedFiles)
+
+	// missing cache file results in an empty cache.
+	hc, err = LoadHashCache(filepath.Join(testutil.TempDirectory(t), "no-such-file"))
+	require.NoError(t, err)
+	require.Empty(t, hc.previous)
+}
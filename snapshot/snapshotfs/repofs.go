@@ -15,6 +15,9 @@
 	"github.com/kopia/kopia/snapshot"
 )
 
+// errStopIteration is used to stop iteration of directory entries early.
+var errStopIteration = errors.New("stop iteration")
+
 // Well-known object ID prefixes.
 const (
 	objectIDPrefixDirectory = "k"
@@ -112,17 +115,50 @@ func (rd *repositoryDirectory) Summary(ctx context.Context) (*fs.DirectorySummar
 	}
 	defer r.Close() //nolint:errcheck
 
-	_, summ, err := readDirEntries(r)
+	summ, err := snapshot.ReadDirManifest(r, nil)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "unable to read directory: %v", rd.metadata.ObjectID)
 	}
 
 	return summ, nil
 }
 
 func (rd *repositoryDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	var result fs.Entry
+
+	if err := rd.IterateEntries(ctx, func(ctx context.Context, e fs.Entry) error {
+		if e.Name() == name {
+			result = e
+			return errStopIteration
+		}
+
+		return nil
+	}); err != nil && !errors.Is(err, errStopIteration) {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, fs.ErrEntryNotFound
+	}
+
+	return result, nil
+}
+
+// IterateEntries implements fs.DirectoryIterator by decoding entries one at a time, which does not require
+// holding all entries of directories written in the streamed format in memory.
+func (rd *repositoryDirectory) IterateEntries(ctx context.Context, callback func(ctx context.Context, e fs.Entry) error) error {
+	r, err := rd.repo.OpenObject(ctx, rd.metadata.ObjectID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open object: %v", rd.metadata.ObjectID)
+	}
+	defer r.Close() //nolint:errcheck
+
+	_, err = snapshot.ReadDirManifest(r, func(de *snapshot.DirEntry) error {
+		return callback(ctx, EntryFromDirEntry(rd.repo, de))
+	})
+
 	// nolint:wrapcheck
-	return fs.ReadDirAndFindChild(ctx, rd, name)
+	return err
 }
 
 func (rd *repositoryDirectory) Readdir(ctx context.Context) (fs.Entries, error) {
@@ -0,0 +1,63 @@
+package snapshotfs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// PackBlobsForSnapshots returns IDs of pack blobs holding contents of the provided snapshots, which along with
+// index and manifest blobs are sufficient to restore them.
+func PackBlobsForSnapshots(ctx context.Context, rep repo.DirectRepository, manifests []*snapshot.Manifest) (map[blob.ID]bool, error) {
+	var (
+		mu    sync.Mutex
+		packs = map[blob.ID]bool{}
+	)
+
+	w := NewTreeWalker()
+	w.EntryID = func(e fs.Entry) interface{} { return e.(object.HasObjectID).ObjectID() }
+
+	w.ObjectCallback = func(entry fs.Entry) error {
+		oid := entry.(object.HasObjectID).ObjectID()
+
+		contentIDs, err := rep.VerifyObject(ctx, oid)
+		if err != nil {
+			return errors.Wrapf(err, "error verifying %v", oid)
+		}
+
+		for _, cid := range contentIDs {
+			ci, err := rep.ContentReader().ContentInfo(ctx, cid)
+			if err != nil {
+				return errors.Wrapf(err, "unable to get info for content %v", cid)
+			}
+
+			mu.Lock()
+			packs[ci.GetPackBlobID()] = true
+			mu.Unlock()
+		}
+
+		return nil
+	}
+
+	for _, m := range manifests {
+		root, err := SnapshotRoot(rep, m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get root of snapshot %v", m.ID)
+		}
+
+		w.RootEntries = append(w.RootEntries, root)
+	}
+
+	if err := w.Run(ctx); err != nil {
+		return nil, errors.Wrap(err, "error walking snapshot tree")
+	}
+
+	return packs, nil
+}
@@ -0,0 +1,60 @@
+package snapshotfs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestPackBlobsForSnapshots(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	upload := func(contents byte) *snapshot.Manifest {
+		t.Helper()
+
+		dir := mockfs.NewDirectory()
+		dir.AddDir("d1", defaultPermissions).AddFile("f1", bytes.Repeat([]byte{contents}, 1000), defaultPermissions)
+
+		man, err := NewUploader(env.RepositoryWriter).Upload(ctx, dir, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{})
+		require.NoError(t, err)
+
+		// flush after each snapshot, so that they are stored in separate packs.
+		require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+		return man
+	}
+
+	m1 := upload(1)
+	m2 := upload(2)
+
+	packs1, err := PackBlobsForSnapshots(ctx, env.RepositoryWriter, []*snapshot.Manifest{m1})
+	require.NoError(t, err)
+	require.NotEmpty(t, packs1)
+
+	packs2, err := PackBlobsForSnapshots(ctx, env.RepositoryWriter, []*snapshot.Manifest{m2})
+	require.NoError(t, err)
+
+	both, err := PackBlobsForSnapshots(ctx, env.RepositoryWriter, []*snapshot.Manifest{m1, m2})
+	require.NoError(t, err)
+
+	for id := range packs1 {
+		require.False(t, packs2[id], "pack %v shared by both snapshots", id)
+	}
+
+	all := map[blob.ID]bool{}
+
+	for _, packs := range []map[blob.ID]bool{packs1, packs2} {
+		for id := range packs {
+			all[id] = true
+		}
+	}
+
+	require.Equal(t, all, both)
+}
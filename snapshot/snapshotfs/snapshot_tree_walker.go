@@ -43,13 +43,11 @@ func (w *TreeWalker) processEntry(ctx context.Context, entry fs.Entry) error {
 	}
 
 	if dir, ok := entry.(fs.Directory); ok {
-		entries, err := dir.Readdir(ctx)
-		if err != nil {
-			return errors.Wrap(err, "error reading directory")
-		}
-
-		for _, ent := range entries {
+		if err := fs.IterateEntries(ctx, dir, func(ctx context.Context, ent fs.Entry) error {
 			w.enqueueEntry(ctx, ent)
+			return nil
+		}); err != nil {
+			return errors.Wrap(err, "error reading directory")
 		}
 	}
 
@@ -3,7 +3,6 @@
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"io"
 	"math/rand"
 	"os"
@@ -33,6 +32,12 @@
 
 const copyBufferSize = 128 * 1024
 
+// delays between retries of files that can't be read, doubled after each attempt.
+const (
+	defaultFileErrorRetryDelay = 1 * time.Second
+	maxFileErrorRetryDelay     = 30 * time.Second
+)
+
 var log = logging.GetContextLoggerFunc("snapshotfs")
 
 var errCanceled = errors.New("canceled")
@@ -71,6 +76,10 @@ type Uploader struct {
 	// How frequently to create checkpoint snapshot entries.
 	CheckpointInterval time.Duration
 
+	// HashCache, when set, is consulted for files not found in previous snapshots and updated with
+	// object IDs of all files in the snapshot.
+	HashCache *HashCache
+
 	repo repo.RepositoryWriter
 
 	// stats must be allocated on heap to enforce 64-bit alignment due to atomic access on ARM.
@@ -86,6 +95,12 @@ type Uploader struct {
 
 	// disable snapshot size estimation
 	disableEstimation bool
+
+	// initial delay between retries of files that can't be read
+	fileErrorRetryDelay time.Duration
+
+	skippedFilesMutex sync.Mutex
+	skippedFiles      []*fs.EntryWithError
 }
 
 // IsCanceled returns true if the upload is canceled.
@@ -118,9 +133,10 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, parentCheckpointRegis
 	defer file.Close() //nolint:errcheck
 
 	writer := u.repo.NewObjectWriter(ctx, object.WriterOptions{
-		Description: "FILE:" + f.Name(),
-		Compressor:  pol.CompressionPolicy.CompressorForFile(f),
-		AsyncWrites: asyncWrites,
+		Description:         "FILE:" + f.Name(),
+		Compressor:          pol.CompressionPolicy.CompressorForFile(f),
+		AsyncWrites:         asyncWrites,
+		ParallelCompression: pol.CompressionPolicy.ParallelCompressionOrDefault(runtime.NumCPU()),
 	})
 	defer writer.Close() //nolint:errcheck
 
@@ -140,7 +156,13 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, parentCheckpointRegis
 
 	defer parentCheckpointRegistry.removeCheckpointCallback(f)
 
-	written, err := u.copyWithProgress(writer, file, 0, f.Size())
+	throttled, err := throttledReader(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	defer throttled.Close() //nolint:errcheck
+
+	written, err := u.copyWithProgress(writer, throttled, 0, f.Size())
 	if err != nil {
 		return nil, err
 	}
@@ -208,6 +230,10 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 		return nil, errors.Wrap(err, "unable to get streaming file reader")
 	}
 
+	if c, ok := reader.(io.Closer); ok {
+		defer c.Close() //nolint:errcheck
+	}
+
 	var streamSize int64
 
 	u.Progress.HashingFile(relativePath)
@@ -221,7 +247,7 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 	})
 	defer writer.Close() //nolint:errcheck
 
-	written, err := u.copyWithProgress(writer, reader, 0, f.Size())
+	written, err := u.copyWithProgress(writer, &streamProgressReader{Reader: reader, path: relativePath, progress: u.Progress}, 0, f.Size())
 	if err != nil {
 		return nil, err
 	}
@@ -246,6 +272,25 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 	return de, nil
 }
 
+// streamProgressReader reports the number of bytes read so far from a streaming file.
+type streamProgressReader struct {
+	io.Reader
+
+	path      string
+	totalRead int64
+	progress  UploadProgress
+}
+
+func (r *streamProgressReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if n > 0 {
+		r.totalRead += int64(n)
+		r.progress.StreamedBytes(r.path, r.totalRead)
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
 func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader, completed, length int64) (int64, error) {
 	// nolint:forcetypeassert
 	uploadBufPtr := u.uploadBufPool.Get().(*[]byte)
@@ -364,21 +409,36 @@ func (u *Uploader) uploadFileWithCheckpointing(ctx context.Context, relativePath
 // saves it in an incomplete snapshot manifest.
 func (u *Uploader) checkpointRoot(ctx context.Context, cp *checkpointRegistry, prototypeManifest *snapshot.Manifest) error {
 	var dmbCheckpoint dirManifestBuilder
+	defer dmbCheckpoint.release()
+
 	if err := cp.runCheckpoints(&dmbCheckpoint); err != nil {
 		return errors.Wrap(err, "running checkpointers")
 	}
 
-	checkpointManifest := dmbCheckpoint.Build(u.repo.Time(), "dummy")
-	if len(checkpointManifest.Entries) == 0 {
+	checkpointManifest, err := dmbCheckpoint.Build(u.repo.Time(), "dummy")
+	if err != nil {
+		return errors.Wrap(err, "unable to build checkpoint")
+	}
+
+	defer checkpointManifest.release()
+
+	if checkpointManifest.numEntries == 0 {
 		// did not produce a checkpoint, that's ok
 		return nil
 	}
 
-	if len(checkpointManifest.Entries) > 1 {
-		return errors.Errorf("produced more than one checkpoint: %v", len(checkpointManifest.Entries))
+	if checkpointManifest.numEntries > 1 {
+		return errors.Errorf("produced more than one checkpoint: %v", checkpointManifest.numEntries)
 	}
 
-	rootEntry := checkpointManifest.Entries[0]
+	var rootEntry *snapshot.DirEntry
+
+	if err := checkpointManifest.iterate(func(e *snapshot.DirEntry) error {
+		rootEntry = e
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "unable to read checkpoint")
+	}
 
 	log(ctx).Debugf("checkpointed root %v", rootEntry.ObjectID)
 
@@ -392,7 +452,7 @@ func (u *Uploader) checkpointRoot(ctx context.Context, cp *checkpointRegistry, p
 		return errors.Wrap(err, "error saving checkpoint snapshot")
 	}
 
-	if _, err := policy.ApplyRetentionPolicy(ctx, u.repo, man.Source, true); err != nil {
+	if _, err := policy.ApplyRetentionPolicy(ctx, u.repo, man.Source, true); err != nil && !errors.Is(err, repo.ErrAccessDenied) {
 		return errors.Wrap(err, "unable to apply retention policy")
 	}
 
@@ -443,6 +503,8 @@ func (u *Uploader) uploadDirWithCheckpointing(ctx context.Context, rootDir fs.Di
 		cp  checkpointRegistry
 	)
 
+	defer dmb.release()
+
 	cancelCheckpointer := u.periodicallyCheckpoint(ctx, &cp, &snapshot.Manifest{Source: sourceInfo})
 	defer cancelCheckpointer()
 
@@ -527,8 +589,30 @@ func rootCauseError(err error) error {
 type dirManifestBuilder struct {
 	mu sync.Mutex
 
-	summary fs.DirectorySummary
-	entries []*snapshot.DirEntry
+	summary    fs.DirectorySummary
+	numEntries int
+	entries    dirEntrySorter
+
+	// first error saving entries, reported when the manifest is built.
+	err error
+}
+
+// dirManifestContents is the summary and sorted entries of a directory manifest produced by dirManifestBuilder.
+type dirManifestContents struct {
+	Summary *fs.DirectorySummary
+
+	numEntries int
+	entries    dirEntrySorter
+}
+
+// iterate invokes the callback for all entries in the order they are stored in directory manifests.
+func (c *dirManifestContents) iterate(callback func(e *snapshot.DirEntry) error) error {
+	return c.entries.iterate(callback)
+}
+
+// release releases resources used to store entries.
+func (c *dirManifestContents) release() {
+	c.entries.release()
 }
 
 // Clone clones the current state of dirManifestBuilder.
@@ -537,8 +621,10 @@ func (b *dirManifestBuilder) Clone() *dirManifestBuilder {
 	defer b.mu.Unlock()
 
 	return &dirManifestBuilder{
-		summary: b.summary.Clone(),
-		entries: append([]*snapshot.DirEntry(nil), b.entries...),
+		summary:    b.summary.Clone(),
+		numEntries: b.numEntries,
+		entries:    b.entries.clone(),
+		err:        b.err,
 	}
 }
 
@@ -546,7 +632,11 @@ func (b *dirManifestBuilder) addEntry(de *snapshot.DirEntry) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.entries = append(b.entries, de)
+	b.numEntries++
+
+	if err := b.entries.add(de); err != nil && b.err == nil {
+		b.err = err
+	}
 
 	if de.ModTime.After(b.summary.MaxModTime) {
 		b.summary.MaxModTime = de.ModTime
@@ -593,14 +683,20 @@ func (b *dirManifestBuilder) addFailedEntry(relPath string, isIgnoredError bool,
 	})
 }
 
-func (b *dirManifestBuilder) Build(dirModTime time.Time, incompleteReason string) *snapshot.DirManifest {
+// Build returns the contents of directory manifest, which must be released after use.
+// The builder can still be used after that.
+func (b *dirManifestBuilder) Build(dirModTime time.Time, incompleteReason string) (*dirManifestContents, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if b.err != nil {
+		return nil, b.err
+	}
+
 	s := b.summary
 	s.TotalDirCount++
 
-	if len(b.entries) == 0 {
+	if b.numEntries == 0 {
 		s.MaxModTime = dirModTime
 	}
 
@@ -608,21 +704,19 @@ func (b *dirManifestBuilder) Build(dirModTime time.Time, incompleteReason string
 
 	b.summary.FailedEntries = sortedTopFailures(b.summary.FailedEntries)
 
-	// sort the result, directories first, then non-directories, ordered by name
-	sort.Slice(b.entries, func(i, j int) bool {
-		if leftDir, rightDir := isDir(b.entries[i]), isDir(b.entries[j]); leftDir != rightDir {
-			// directories get sorted before non-directories
-			return leftDir
-		}
+	return &dirManifestContents{
+		Summary:    &s,
+		numEntries: b.numEntries,
+		entries:    b.entries.clone(),
+	}, nil
+}
 
-		return b.entries[i].Name < b.entries[j].Name
-	})
+// release releases resources used to store entries.
+func (b *dirManifestBuilder) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	return &snapshot.DirManifest{
-		StreamType: directoryStreamType,
-		Summary:    &s,
-		Entries:    b.entries,
-	}
+	b.entries.release()
 }
 
 func sortedTopFailures(entries []*fs.EntryWithError) []*fs.EntryWithError {
@@ -641,23 +735,49 @@ func isDir(e *snapshot.DirEntry) bool {
 	return e.Type == snapshot.EntryTypeDirectory
 }
 
+// iterateEntriesFunc enumerates entries by invoking the provided callback for each of them.
+type iterateEntriesFunc func(ctx context.Context, callback func(ctx context.Context, e fs.Entry) error) error
+
+// processChildren uploads non-directories as the directory is being enumerated, so that entries of large
+// directories are not held in memory, and then processes subdirectories.
 func (u *Uploader) processChildren(
 	ctx context.Context,
 	parentDirCheckpointRegistry *checkpointRegistry,
 	parentDirBuilder *dirManifestBuilder,
 	localDirPathOrEmpty, relativePath string,
-	entries fs.Entries,
+	directory fs.Directory,
 	policyTree *policy.Tree,
 	previousEntries []fs.Entries,
 ) error {
-	if err := u.processSubdirectories(ctx, parentDirCheckpointRegistry, parentDirBuilder, localDirPathOrEmpty, relativePath, entries, policyTree, previousEntries); err != nil {
-		return errors.Wrap(err, "processing subdirectories")
-	}
+	var subdirs fs.Entries
 
-	if err := u.processNonDirectories(ctx, parentDirCheckpointRegistry, parentDirBuilder, relativePath, entries, policyTree, previousEntries); err != nil {
+	t0 := u.repo.Time()
+
+	if err := u.processNonDirectories(ctx, parentDirCheckpointRegistry, parentDirBuilder, relativePath, func(ctx context.Context, callback func(ctx context.Context, e fs.Entry) error) error {
+		if err := fs.IterateEntries(ctx, directory, func(ctx context.Context, e fs.Entry) error {
+			if _, ok := e.(fs.Directory); ok {
+				subdirs = append(subdirs, e)
+				return nil
+			}
+
+			return callback(ctx, e)
+		}); err != nil {
+			return dirReadError{err}
+		}
+
+		return nil
+	}, policyTree, previousEntries); err != nil {
 		return errors.Wrap(err, "processing non-directories")
 	}
 
+	log(ctx).Debugf("finished reading directory %v in %v", relativePath, u.repo.Time().Sub(t0))
+
+	subdirs.Sort()
+
+	if err := u.processSubdirectories(ctx, parentDirCheckpointRegistry, parentDirBuilder, localDirPathOrEmpty, relativePath, subdirs, policyTree, previousEntries); err != nil {
+		return errors.Wrap(err, "processing subdirectories")
+	}
+
 	return nil
 }
 
@@ -691,6 +811,7 @@ func (u *Uploader) processSubdirectories(
 		previousDirs = uniqueDirectories(previousDirs)
 
 		childDirBuilder := &dirManifestBuilder{}
+		defer childDirBuilder.release()
 
 		childLocalDirPathOrEmpty := ""
 		if localDirPathOrEmpty != "" {
@@ -775,6 +896,87 @@ func (u *Uploader) maybeIgnoreCachedEntry(ctx context.Context, ent fs.Entry) fs.
 	return nil
 }
 
+// findInHashCache returns the object ID of an unchanged file recorded in the hash cache, as long as
+// its contents are still present in the repository.
+func (u *Uploader) findInHashCache(ctx context.Context, relativePath string, entry fs.Entry) (object.ID, bool) {
+	if u.HashCache == nil {
+		return "", false
+	}
+
+	if _, ok := entry.(fs.File); !ok {
+		return "", false
+	}
+
+	oid, ok := u.HashCache.lookup(relativePath, entry)
+	if !ok {
+		return "", false
+	}
+
+	if rand.Intn(100) < u.ForceHashPercentage { // nolint:gomnd,gosec
+		log(ctx).Debugf("re-hashing object from hash cache: %v", oid)
+		return "", false
+	}
+
+	if _, err := u.repo.VerifyObject(ctx, oid); err != nil {
+		log(ctx).Debugf("ignoring hash cache entry for %v: %v", relativePath, err)
+		return "", false
+	}
+
+	return oid, true
+}
+
+func (u *Uploader) recordInHashCache(relativePath string, entry fs.Entry, oid object.ID) {
+	if u.HashCache == nil {
+		return
+	}
+
+	if _, ok := entry.(fs.File); !ok {
+		return
+	}
+
+	u.HashCache.record(relativePath, entry, oid)
+}
+
+// findHardLinkedFile returns the object ID of a file already uploaded through another hard link during this upload.
+func findHardLinkedFile(entry fs.Entry) (object.ID, bool) {
+	hf, ok := entry.(fs.HardLinkedFile)
+	if !ok {
+		return "", false
+	}
+
+	v, ok := hf.SharedValue()
+	if !ok {
+		return "", false
+	}
+
+	oid, ok := v.(object.ID)
+
+	return oid, ok
+}
+
+func recordHardLinkedFile(entry fs.Entry, oid object.ID) {
+	if hf, ok := entry.(fs.HardLinkedFile); ok {
+		hf.SetSharedValue(oid)
+	}
+}
+
+func (u *Uploader) addCachedEntry(parentDirBuilder *dirManifestBuilder, dirRelativePath, entryRelativePath string, entry fs.Entry, oid object.ID) error {
+	atomic.AddInt32(&u.stats.CachedFiles, 1)
+	atomic.AddInt64(&u.stats.TotalFileSize, entry.Size())
+	u.Progress.CachedFile(filepath.Join(dirRelativePath, entry.Name()), entry.Size())
+
+	cachedDirEntry, err := newDirEntry(entry, oid)
+	if err != nil {
+		return errors.Wrap(err, "unable to create dir entry")
+	}
+
+	u.recordInHashCache(entryRelativePath, entry, oid)
+	recordHardLinkedFile(entry, oid)
+	parentDirBuilder.addEntry(cachedDirEntry)
+
+	return nil
+}
+
 func (u *Uploader) effectiveParallelUploads() int {
 	p := u.ParallelUploads
 	if p == 0 {
@@ -784,52 +986,85 @@ func (u *Uploader) effectiveParallelUploads() int {
 	return p
 }
 
-func (u *Uploader) processNonDirectories(ctx context.Context, parentCheckpointRegistry *checkpointRegistry, parentDirBuilder *dirManifestBuilder, dirRelativePath string, entries fs.Entries, policyTree *policy.Tree, prevEntries []fs.Entries) error {
+func (u *Uploader) processNonDirectories(ctx context.Context, parentCheckpointRegistry *checkpointRegistry, parentDirBuilder *dirManifestBuilder, dirRelativePath string, iterate iterateEntriesFunc, policyTree *policy.Tree, prevEntries []fs.Entries) error {
 	workerCount := u.effectiveParallelUploads()
 
+	ch := make(chan fs.Entry)
+	eg, ctx := errgroup.WithContext(ctx)
+
+	// one goroutine to pump entries into channel as they are enumerated, until ctx is closed.
+	eg.Go(func() error {
+		defer close(ch)
+
+		return iterate(ctx, func(ctx context.Context, e fs.Entry) error {
+			select {
+			case ch <- e: // sent to channel
+				return nil
+			case <-ctx.Done(): // context closed
+				return ctx.Err()
+			}
+		})
+	})
+
+	// buffer the first entries to determine whether there are fewer of them than workers,
+	// in which case each file is written using multiple parallel writes.
+	var first fs.Entries
+
+	for len(first) < workerCount {
+		e, ok := <-ch
+		if !ok {
+			break
+		}
+
+		first = append(first, e)
+	}
+
 	var asyncWritesPerFile int
 
-	if len(entries) < workerCount {
-		if len(entries) > 0 {
-			asyncWritesPerFile = workerCount / len(entries)
+	if len(first) < workerCount {
+		if len(first) > 0 {
+			asyncWritesPerFile = workerCount / len(first)
 			if asyncWritesPerFile == 1 {
 				asyncWritesPerFile = 0
 			}
 		}
 
-		workerCount = len(entries)
+		workerCount = len(first)
+	}
+
+	firstCh := make(chan fs.Entry, len(first))
+	for _, e := range first {
+		firstCh <- e
 	}
 
-	return u.foreachEntryUnlessCanceled(ctx, workerCount, dirRelativePath, entries, func(ctx context.Context, entry fs.Entry, entryRelativePath string) error {
+	close(firstCh)
+
+	ehp := &policyTree.EffectivePolicy().ErrorHandlingPolicy
+
+	processEntry := func(ctx context.Context, entry fs.Entry, entryRelativePath string) error {
 		// note this function runs in parallel and updates 'u.stats', which must be done using atomic operations.
-		if _, ok := entry.(fs.Directory); ok {
-			// skip directories
-			return nil
-		}
 
 		// See if we had this name during either of previous passes.
 		if cachedEntry := u.maybeIgnoreCachedEntry(ctx, findCachedEntry(ctx, entry, prevEntries)); cachedEntry != nil {
-			atomic.AddInt32(&u.stats.CachedFiles, 1)
-			atomic.AddInt64(&u.stats.TotalFileSize, entry.Size())
-			u.Progress.CachedFile(filepath.Join(dirRelativePath, entry.Name()), entry.Size())
-
 			// compute entryResult now, cachedEntry is short-lived
-			cachedDirEntry, err := newDirEntry(entry, cachedEntry.(object.HasObjectID).ObjectID())
-			if err != nil {
-				return errors.Wrap(err, "unable to create dir entry")
-			}
+			return u.addCachedEntry(parentDirBuilder, dirRelativePath, entryRelativePath, entry, cachedEntry.(object.HasObjectID).ObjectID())
+		}
 
-			parentDirBuilder.addEntry(cachedDirEntry)
-			return nil
+		if oid, ok := u.findInHashCache(ctx, entryRelativePath, entry); ok {
+			return u.addCachedEntry(parentDirBuilder, dirRelativePath, entryRelativePath, entry, oid)
+		}
+
+		if oid, ok := findHardLinkedFile(entry); ok {
+			return u.addCachedEntry(parentDirBuilder, dirRelativePath, entryRelativePath, entry, oid)
 		}
 
 		switch entry := entry.(type) {
 		case fs.Symlink:
-			de, err := u.uploadSymlinkInternal(ctx, entryRelativePath, entry)
+			de, err := u.uploadWithRetries(ctx, entryRelativePath, ehp, func() (*snapshot.DirEntry, error) {
+				return u.uploadSymlinkInternal(ctx, entryRelativePath, entry)
+			})
 			if err != nil {
-				isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrorsOrDefault(false)
-
-				u.reportErrorAndMaybeCancel(err, isIgnoredError, parentDirBuilder, entryRelativePath)
+				u.reportFileErrorAndMaybeCancel(err, ehp, parentDirBuilder, entryRelativePath)
 			} else {
 				parentDirBuilder.addEntry(de)
 			}
@@ -839,37 +1074,36 @@ func (u *Uploader) processNonDirectories(ctx context.Context, parentCheckpointRe
 		case fs.File:
 			atomic.AddInt32(&u.stats.NonCachedFiles, 1)
 
-			de, err := u.uploadFileInternal(ctx, parentCheckpointRegistry, entryRelativePath, entry, policyTree.Child(entry.Name()).EffectivePolicy(), asyncWritesPerFile)
+			de, err := u.uploadWithRetries(ctx, entryRelativePath, ehp, func() (*snapshot.DirEntry, error) {
+				return u.uploadFileInternal(ctx, parentCheckpointRegistry, entryRelativePath, entry, policyTree.Child(entry.Name()).EffectivePolicy(), asyncWritesPerFile)
+			})
 			if err != nil {
-				isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrorsOrDefault(false)
-
-				u.reportErrorAndMaybeCancel(err, isIgnoredError, parentDirBuilder, entryRelativePath)
+				u.reportFileErrorAndMaybeCancel(err, ehp, parentDirBuilder, entryRelativePath)
 			} else {
+				u.recordInHashCache(entryRelativePath, entry, de.ObjectID)
+				recordHardLinkedFile(entry, de.ObjectID)
 				parentDirBuilder.addEntry(de)
 			}
 
 			return nil
 
 		case fs.ErrorEntry:
-			var isIgnoredError bool
 			if errors.Is(entry.ErrorInfo(), fs.ErrUnknown) {
-				isIgnoredError = policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreUnknownTypesOrDefault(true)
+				u.reportErrorAndMaybeCancel(entry.ErrorInfo(), ehp.IgnoreUnknownTypesOrDefault(true), parentDirBuilder, entryRelativePath)
 			} else {
-				isIgnoredError = policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrorsOrDefault(false)
+				u.reportFileErrorAndMaybeCancel(entry.ErrorInfo(), ehp, parentDirBuilder, entryRelativePath)
 			}
 
-			u.reportErrorAndMaybeCancel(entry.ErrorInfo(), isIgnoredError, parentDirBuilder, entryRelativePath)
-
 			return nil
 
 		case fs.StreamingFile:
 			atomic.AddInt32(&u.stats.NonCachedFiles, 1)
 
-			de, err := u.uploadStreamingFileInternal(ctx, entryRelativePath, entry)
+			de, err := u.uploadWithRetries(ctx, entryRelativePath, ehp, func() (*snapshot.DirEntry, error) {
+				return u.uploadStreamingFileInternal(ctx, entryRelativePath, entry)
+			})
 			if err != nil {
-				isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrorsOrDefault(false)
-
-				u.reportErrorAndMaybeCancel(err, isIgnoredError, parentDirBuilder, entryRelativePath)
+				u.reportFileErrorAndMaybeCancel(err, ehp, parentDirBuilder, entryRelativePath)
 			} else {
 				parentDirBuilder.addEntry(de)
 			}
@@ -879,7 +1113,29 @@ func (u *Uploader) processNonDirectories(ctx context.Context, parentCheckpointRe
 		default:
 			return errors.Errorf("unexpected entry type: %T %v", entry, entry.Mode())
 		}
-	})
+	}
+
+	// launch N workers in parallel, buffered entries are processed first.
+	for i := 0; i < workerCount; i++ {
+		eg.Go(func() error {
+			for _, entries := range []<-chan fs.Entry{firstCh, ch} {
+				for entry := range entries {
+					if u.IsCanceled() {
+						return errCanceled
+					}
+
+					if err := processEntry(ctx, entry, path.Join(dirRelativePath, entry.Name())); err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		})
+	}
+
+	// nolint:wrapcheck
+	return eg.Wait()
 }
 
 func maybeReadDirectoryEntries(ctx context.Context, dir fs.Directory) fs.Entries {
@@ -938,6 +1194,9 @@ func uploadDirInternal(
 	u.Progress.StartedDirectory(dirRelativePath)
 	defer u.Progress.FinishedDirectory(dirRelativePath)
 
+	ctx, releaseThrottler := withUploadThrottler(ctx, policyTree.EffectivePolicy())
+	defer releaseThrottler()
+
 	var definedActions policy.ActionsPolicy
 
 	if p := policyTree.DefinedPolicy(); p != nil {
@@ -958,14 +1217,6 @@ func uploadDirInternal(
 		directory = overrideDir
 	}
 
-	t0 := u.repo.Time()
-	entries, direrr := directory.Readdir(ctx)
-	log(ctx).Debugf("finished reading directory %v in %v", dirRelativePath, u.repo.Time().Sub(t0))
-
-	if direrr != nil {
-		return nil, dirReadError{direrr}
-	}
-
 	var prevEntries []fs.Entries
 
 	for _, d := range uniqueDirectories(previousDirs) {
@@ -986,7 +1237,15 @@ func uploadDirInternal(
 			return nil, errors.Wrapf(err, "error checkpointing children")
 		}
 
-		checkpointManifest := thisCheckpointBuilder.Build(directory.ModTime(), IncompleteReasonCheckpoint)
+		defer thisCheckpointBuilder.release()
+
+		checkpointManifest, err := thisCheckpointBuilder.Build(directory.ModTime(), IncompleteReasonCheckpoint)
+		if err != nil {
+			return nil, errors.Wrap(err, "error building checkpoint")
+		}
+
+		defer checkpointManifest.release()
+
 		oid, err := u.writeDirManifest(ctx, dirRelativePath, checkpointManifest)
 		if err != nil {
 			return nil, errors.Wrap(err, "error writing dir manifest")
@@ -996,11 +1255,21 @@ func uploadDirInternal(
 	})
 	defer thisCheckpointRegistry.removeCheckpointCallback(directory)
 
-	if err := u.processChildren(ctx, childCheckpointRegistry, thisDirBuilder, localDirPathOrEmpty, dirRelativePath, entries, policyTree, prevEntries); err != nil && !errors.Is(err, errCanceled) {
+	if err := u.processChildren(ctx, childCheckpointRegistry, thisDirBuilder, localDirPathOrEmpty, dirRelativePath, directory, policyTree, prevEntries); err != nil && !errors.Is(err, errCanceled) {
+		var dre dirReadError
+		if errors.As(err, &dre) {
+			return nil, dre
+		}
+
 		return nil, err
 	}
 
-	dirManifest := thisDirBuilder.Build(directory.ModTime(), u.incompleteReason())
+	dirManifest, err := thisDirBuilder.Build(directory.ModTime(), u.incompleteReason())
+	if err != nil {
+		return nil, errors.Wrapf(err, "error building dir manifest: %v", directory.Name())
+	}
+
+	defer dirManifest.release()
 
 	oid, err := u.writeDirManifest(ctx, dirRelativePath, dirManifest)
 	if err != nil {
@@ -1010,7 +1279,7 @@ func uploadDirInternal(
 	return newDirEntryWithSummary(directory, oid, dirManifest.Summary)
 }
 
-func (u *Uploader) writeDirManifest(ctx context.Context, dirRelativePath string, dirManifest *snapshot.DirManifest) (object.ID, error) {
+func (u *Uploader) writeDirManifest(ctx context.Context, dirRelativePath string, dirManifest *dirManifestContents) (object.ID, error) {
 	writer := u.repo.NewObjectWriter(ctx, object.WriterOptions{
 		Description: "DIR:" + dirRelativePath,
 		Prefix:      objectIDPrefixDirectory,
@@ -1018,8 +1287,8 @@ func (u *Uploader) writeDirManifest(ctx context.Context, dirRelativePath string,
 
 	defer writer.Close() //nolint:errcheck
 
-	if err := json.NewEncoder(writer).Encode(dirManifest); err != nil {
-		return "", errors.Wrap(err, "unable to encode directory JSON")
+	if err := snapshot.WriteDirManifestEntries(writer, dirManifest.Summary, dirManifest.iterate, u.useStreamedDirManifests()); err != nil {
+		return "", errors.Wrap(err, "unable to write directory manifest")
 	}
 
 	oid, err := writer.Result()
@@ -1030,6 +1299,18 @@ func (u *Uploader) writeDirManifest(ctx context.Context, dirRelativePath string,
 	return oid, nil
 }
 
+// useStreamedDirManifests returns true if the repository format supports streamed directory manifests.
+func (u *Uploader) useStreamedDirManifests() bool {
+	dr, ok := u.repo.(repo.DirectRepository)
+	if !ok {
+		return false
+	}
+
+	f := dr.ContentReader().ContentFormat()
+
+	return f.EnabledFeatures().StreamedDirectories
+}
+
 func (u *Uploader) reportErrorAndMaybeCancel(err error, isIgnored bool, dmb *dirManifestBuilder, entryRelativePath string) {
 	if isIgnored {
 		atomic.AddInt32(&u.stats.IgnoredErrorCount, 1)
@@ -1046,6 +1327,51 @@ func (u *Uploader) reportErrorAndMaybeCancel(err error, isIgnored bool, dmb *dir
 	}
 }
 
+// reportFileErrorAndMaybeCancel reports an error reading a file, which is skipped and recorded in the manifest
+// or fails the snapshot, depending on the error handling policy.
+func (u *Uploader) reportFileErrorAndMaybeCancel(err error, ehp *policy.ErrorHandlingPolicy, dmb *dirManifestBuilder, entryRelativePath string) {
+	isSkipped := ehp.FileErrorActionOrDefault(policy.FileErrorActionFail) == policy.FileErrorActionSkip
+
+	if isSkipped {
+		u.skippedFilesMutex.Lock()
+		if len(u.skippedFiles) < snapshot.MaxSkippedFilesPerManifest {
+			u.skippedFiles = append(u.skippedFiles, &fs.EntryWithError{
+				EntryPath: entryRelativePath,
+				Error:     rootCauseError(err).Error(),
+			})
+		}
+		u.skippedFilesMutex.Unlock()
+	}
+
+	u.reportErrorAndMaybeCancel(err, isSkipped, dmb, entryRelativePath)
+}
+
+// uploadWithRetries invokes the provided upload function, retrying it with exponential backoff
+// as many times as the error handling policy allows.
+func (u *Uploader) uploadWithRetries(ctx context.Context, relativePath string, ehp *policy.ErrorHandlingPolicy, upload func() (*snapshot.DirEntry, error)) (*snapshot.DirEntry, error) {
+	maxRetries := ehp.FileErrorRetriesOrDefault(0)
+	delay := u.fileErrorRetryDelay
+
+	for attempt := 0; ; attempt++ {
+		de, err := upload()
+		if err == nil || attempt >= maxRetries || u.IsCanceled() {
+			return de, err
+		}
+
+		log(ctx).Debugf("unable to read %v (attempt %v/%v), retrying in %v: %v", relativePath, attempt+1, maxRetries+1, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "canceled while retrying")
+		case <-time.After(delay):
+		}
+
+		if delay *= 2; delay > maxFileErrorRetryDelay {
+			delay = maxFileErrorRetryDelay
+		}
+	}
+}
+
 // NewUploader creates new Uploader object for a given repository.
 func NewUploader(r repo.RepositoryWriter) *Uploader {
 	return &Uploader{
@@ -1055,6 +1381,8 @@ func NewUploader(r repo.RepositoryWriter) *Uploader {
 		EnableActions:      r.ClientOptions().EnableActions,
 		CheckpointInterval: DefaultCheckpointInterval,
 		getTicker:          time.Tick,
+
+		fileErrorRetryDelay: defaultFileErrorRetryDelay,
 		uploadBufPool: sync.Pool{
 			New: func() interface{} {
 				p := make([]byte, copyBufferSize)
@@ -1107,6 +1435,7 @@ func (u *Uploader) Upload(
 
 	u.stats = &snapshot.Stats{}
 	u.totalWrittenBytes = 0
+	u.skippedFiles = nil
 
 	var err error
 
@@ -1152,7 +1481,11 @@ func (u *Uploader) Upload(
 
 	case fs.File:
 		u.Progress.EstimatedDataSize(1, entry.Size())
-		s.RootEntry, err = u.uploadFileWithCheckpointing(ctx, entry.Name(), entry, policyTree.EffectivePolicy(), sourceInfo)
+
+		fileCtx, releaseThrottler := withUploadThrottler(ctx, policyTree.EffectivePolicy())
+		s.RootEntry, err = u.uploadFileWithCheckpointing(fileCtx, entry.Name(), entry, policyTree.EffectivePolicy(), sourceInfo)
+
+		releaseThrottler()
 
 	default:
 		return nil, errors.Errorf("unsupported source: %v", s.Source)
@@ -1168,6 +1501,13 @@ func (u *Uploader) Upload(
 	s.IncompleteReason = u.incompleteReason()
 	s.EndTime = u.repo.Time()
 	s.Stats = *u.stats
+	s.SkippedFiles = u.skippedFiles
+
+	sort.Slice(s.SkippedFiles, func(i, j int) bool {
+		return s.SkippedFiles[i].EntryPath < s.SkippedFiles[j].EntryPath
+	})
+
+	recordSnapshotMetrics(ctx, s)
 
 	return s, nil
 }
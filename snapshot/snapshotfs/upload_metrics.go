@@ -0,0 +1,82 @@
+package snapshotfs
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// snapshot upload metrics.
+var (
+	metricSnapshotCount = stats.Int64(
+		"kopia/snapshot/count",
+		"Number of snapshots created",
+		stats.UnitDimensionless,
+	)
+
+	metricSnapshotDuration = stats.Int64(
+		"kopia/snapshot/duration_ms",
+		"Total duration of snapshots",
+		stats.UnitMilliseconds,
+	)
+
+	metricSnapshotFileCount = stats.Int64(
+		"kopia/snapshot/file_count",
+		"Number of files in snapshots",
+		stats.UnitDimensionless,
+	)
+
+	metricSnapshotCachedFileCount = stats.Int64(
+		"kopia/snapshot/cached_file_count",
+		"Number of files in snapshots that were unchanged since previous snapshot",
+		stats.UnitDimensionless,
+	)
+
+	metricSnapshotTotalFileSize = stats.Int64(
+		"kopia/snapshot/total_file_size",
+		"Total size of files in snapshots",
+		stats.UnitBytes,
+	)
+
+	metricSnapshotErrorCount = stats.Int64(
+		"kopia/snapshot/error_count",
+		"Number of errors encountered while creating snapshots",
+		stats.UnitDimensionless,
+	)
+)
+
+func recordSnapshotMetrics(ctx context.Context, s *snapshot.Manifest) {
+	stats.Record(ctx,
+		metricSnapshotCount.M(1),
+		metricSnapshotDuration.M(s.EndTime.Sub(s.StartTime).Milliseconds()),
+		metricSnapshotFileCount.M(int64(s.Stats.TotalFileCount)),
+		metricSnapshotCachedFileCount.M(int64(s.Stats.CachedFiles)),
+		metricSnapshotTotalFileSize.M(s.Stats.TotalFileSize),
+		metricSnapshotErrorCount.M(int64(s.Stats.ErrorCount)),
+	)
+}
+
+func sumView(m stats.Measure) *view.View {
+	return &view.View{
+		Name:        m.Name(),
+		Aggregation: view.Sum(),
+		Description: m.Description(),
+		Measure:     m,
+	}
+}
+
+func init() {
+	if err := view.Register(
+		sumView(metricSnapshotCount),
+		sumView(metricSnapshotDuration),
+		sumView(metricSnapshotFileCount),
+		sumView(metricSnapshotCachedFileCount),
+		sumView(metricSnapshotTotalFileSize),
+		sumView(metricSnapshotErrorCount),
+	); err != nil {
+		panic("unable to register opencensus views: " + err.Error())
+	}
+}
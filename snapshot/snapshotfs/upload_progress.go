@@ -4,6 +4,7 @@
 	"sync"
 	"sync/atomic"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/uitask"
 )
 
@@ -33,6 +34,9 @@ type UploadProgress interface {
 	// HashedBytes is emitted while hashing any blocks of bytes.
 	HashedBytes(numBytes int64)
 
+	// StreamedBytes is emitted while reading a streaming file with the total number of bytes read from it so far.
+	StreamedBytes(fname string, totalBytes int64)
+
 	// Error is emitted when an error is encountered.
 	Error(path string, err error, isIgnored bool)
 
@@ -64,6 +68,9 @@ func (p *NullUploadProgress) UploadFinished() {}
 // HashedBytes implements UploadProgress.
 func (p *NullUploadProgress) HashedBytes(numBytes int64) {}
 
+// StreamedBytes implements UploadProgress.
+func (p *NullUploadProgress) StreamedBytes(fname string, totalBytes int64) {}
+
 // ExcludedFile implements UploadProgress.
 func (p *NullUploadProgress) ExcludedFile(fname string, numBytes int64) {}
 
@@ -115,6 +122,8 @@ type UploadCounters struct {
 
 	LastErrorPath string `json:"lastErrorPath"`
 	LastError     string `json:"lastError"`
+
+	UploadThroughputEstimate
 }
 
 // CountingUploadProgress is an implementation of UploadProgress that accumulates counters.
@@ -123,13 +132,18 @@ type CountingUploadProgress struct {
 
 	mu sync.Mutex
 
-	counters UploadCounters
+	counters   UploadCounters
+	throughput UploadThroughput // protected by mu
 }
 
 // UploadStarted implements UploadProgress.
 func (p *CountingUploadProgress) UploadStarted() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// reset counters to all-zero values.
 	p.counters = UploadCounters{}
+	p.throughput = UploadThroughput{}
 }
 
 // UploadedBytes implements UploadProgress.
@@ -197,17 +211,22 @@ func (p *CountingUploadProgress) Snapshot() UploadCounters {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return UploadCounters{
-		TotalCachedFiles: atomic.LoadInt32(&p.counters.TotalCachedFiles),
-		TotalHashedFiles: atomic.LoadInt32(&p.counters.TotalHashedFiles),
-		TotalCachedBytes: atomic.LoadInt64(&p.counters.TotalCachedBytes),
-		TotalHashedBytes: atomic.LoadInt64(&p.counters.TotalHashedBytes),
-		EstimatedBytes:   atomic.LoadInt64(&p.counters.EstimatedBytes),
-		EstimatedFiles:   atomic.LoadInt32(&p.counters.EstimatedFiles),
-		CurrentDirectory: p.counters.CurrentDirectory,
-		LastErrorPath:    p.counters.LastErrorPath,
-		LastError:        p.counters.LastError,
+	c := UploadCounters{
+		TotalCachedFiles:   atomic.LoadInt32(&p.counters.TotalCachedFiles),
+		TotalHashedFiles:   atomic.LoadInt32(&p.counters.TotalHashedFiles),
+		TotalCachedBytes:   atomic.LoadInt64(&p.counters.TotalCachedBytes),
+		TotalHashedBytes:   atomic.LoadInt64(&p.counters.TotalHashedBytes),
+		TotalUploadedBytes: atomic.LoadInt64(&p.counters.TotalUploadedBytes),
+		EstimatedBytes:     atomic.LoadInt64(&p.counters.EstimatedBytes),
+		EstimatedFiles:     atomic.LoadInt32(&p.counters.EstimatedFiles),
+		CurrentDirectory:   p.counters.CurrentDirectory,
+		LastErrorPath:      p.counters.LastErrorPath,
+		LastError:          p.counters.LastError,
 	}
+
+	c.UploadThroughputEstimate = p.throughput.Update(clock.Now(), c.TotalHashedBytes+c.TotalCachedBytes, c.TotalUploadedBytes, c.EstimatedBytes)
+
+	return c
 }
 
 // UITaskCounters returns UI task counters.
@@ -2,9 +2,11 @@
 
 import (
 	"context"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"testing"
 	"time"
 
@@ -13,10 +15,12 @@
 	"github.com/stretchr/testify/require"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
 	"github.com/kopia/kopia/fs/virtualfs"
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/faketime"
 	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo"
@@ -392,6 +396,43 @@ func TestUpload_ErrorEntries(t *testing.T) {
 	}
 }
 
+func TestUpload_FileErrorRetriesAndSkip(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	th.sourceDir.AddFile("d1/unreadable", []byte{1, 2, 3}, defaultPermissions).FailOpen(100, errTest)
+	th.sourceDir.AddFile("d2/flaky", []byte{1, 2, 3}, defaultPermissions).FailOpen(2, errTest)
+
+	u := NewUploader(th.repo)
+	u.fileErrorRetryDelay = time.Millisecond
+
+	retries := 2
+
+	policyTree := policy.BuildTree(nil, &policy.Policy{
+		ErrorHandlingPolicy: policy.ErrorHandlingPolicy{
+			FileErrorAction:  policy.FileErrorActionSkip,
+			FileErrorRetries: &retries,
+		},
+	})
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*fs.EntryWithError{
+		{EntryPath: "d1/unreadable", Error: errTest.Error()},
+	}
+
+	verifyErrors(t, man, 0, 1, want)
+
+	if diff := pretty.Compare(man.SkippedFiles, want); diff != "" {
+		t.Errorf("unexpected skipped files, diff(-got,+want): %v\n", diff)
+	}
+}
+
 func TestUpload_SubDirectoryReadFailureNoFailFast(t *testing.T) {
 	ctx := testlogging.Context(t)
 	th := newUploadTestHarness(ctx, t)
@@ -667,3 +708,74 @@ func TestUpload_VirtualDirectoryWithStreamingFile(t *testing.T) {
 		t.Fatalf("unexpected manifest file count: %v, want %v", got, want)
 	}
 }
+
+func TestUploadStreamedDirManifest(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		NewRepositoryOptions: func(n *repo.NewRepositoryOptions) {
+			n.BlockFormat.Version = snapshot.MinFormatVersionStreamedDirectories
+		},
+	})
+
+	sourceDir := mockfs.NewDirectory()
+	sourceDir.AddFile("f1", []byte{1, 2, 3}, defaultPermissions)
+	sourceDir.AddDir("d1", defaultPermissions)
+	sourceDir.AddFile("d1/f2", []byte{1, 2, 3, 4}, defaultPermissions)
+
+	u := NewUploader(env.RepositoryWriter)
+
+	man, err := u.Upload(ctx, sourceDir, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	r, err := env.RepositoryWriter.OpenObject(ctx, man.RootObjectID())
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Contains(t, string(data), `"stream":"kopia:directory-stream"`)
+
+	root := DirectoryEntry(env.RepositoryWriter, man.RootObjectID(), nil)
+
+	entries, err := root.Readdir(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	summ, err := root.(fs.DirectoryWithSummary).Summary(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), summ.TotalFileCount)
+
+	d1, err := root.Child(ctx, "d1")
+	require.NoError(t, err)
+
+	f2, err := d1.(fs.Directory).Child(ctx, "f2")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), f2.Size())
+
+	_, err = root.Child(ctx, "no-such-entry")
+	require.ErrorIs(t, err, fs.ErrEntryNotFound)
+}
+
+func TestUploadHardLinkedFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inode numbers are not available on Windows")
+	}
+
+	ctx, env := repotesting.NewEnvironment(t)
+
+	tmp := testutil.TempDirectory(t)
+
+	require.NoError(t, os.Mkdir(filepath.Join(tmp, "d1"), defaultPermissions))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "f1"), []byte{1, 2, 3}, defaultPermissions))
+	require.NoError(t, os.Link(filepath.Join(tmp, "f1"), filepath.Join(tmp, "d1", "f1")))
+
+	source, err := localfs.NewMetadataCache(localfs.DefaultMetadataCacheSize).NewEntry(tmp)
+	require.NoError(t, err)
+
+	u := NewUploader(env.RepositoryWriter)
+
+	// second link is uploaded after the first one, because directories are processed after files.
+	man, err := u.Upload(ctx, source, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), man.Stats.NonCachedFiles)
+	require.Equal(t, int32(1), man.Stats.CachedFiles)
+}
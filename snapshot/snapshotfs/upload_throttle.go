@@ -0,0 +1,54 @@
+package snapshotfs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/efarrer/iothrottler"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type uploadThrottlerKey struct{}
+
+// uploadThrottler limits the rate of reading file contents in a directory tree, according to upload policy.
+type uploadThrottler struct {
+	bytesPerSecond int64
+	pool           *iothrottler.IOThrottlerPool
+}
+
+// withUploadThrottler returns a context that throttles file uploads according to the provided policy.
+// The throttler of the parent directory is shared unless the policy changes the limit.
+// The returned function must be called to release resources once all files have been uploaded.
+func withUploadThrottler(ctx context.Context, pol *policy.Policy) (context.Context, func()) {
+	limit := pol.UploadPolicy.MaxUploadSpeedBytesPerSecond
+
+	if current, ok := ctx.Value(uploadThrottlerKey{}).(*uploadThrottler); ok && current.bytesPerSecond == limit {
+		return ctx, func() {}
+	}
+
+	t := &uploadThrottler{bytesPerSecond: limit}
+	if limit > 0 {
+		t.pool = iothrottler.NewIOThrottlerPool(iothrottler.Bandwidth(limit) * iothrottler.BytesPerSecond)
+	}
+
+	return context.WithValue(ctx, uploadThrottlerKey{}, t), func() {
+		if t.pool != nil {
+			t.pool.ReleasePool()
+		}
+	}
+}
+
+// throttledReader returns the reader that is throttled according to upload throttler in the context, if any.
+func throttledReader(ctx context.Context, r io.Reader) (io.ReadCloser, error) {
+	t, ok := ctx.Value(uploadThrottlerKey{}).(*uploadThrottler)
+	if !ok || t.pool == nil {
+		return ioutil.NopCloser(r), nil
+	}
+
+	tr, err := t.pool.AddReader(ioutil.NopCloser(r))
+
+	return tr, errors.Wrap(err, "unable to throttle reader")
+}
@@ -0,0 +1,74 @@
+package snapshotfs
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func uploadPolicyWithSpeed(bytesPerSecond int64) *policy.Policy {
+	return &policy.Policy{
+		UploadPolicy: policy.UploadPolicy{
+			MaxUploadSpeedBytesPerSecond: bytesPerSecond,
+		},
+	}
+}
+
+func TestUploadThrottlerSharedWithinSameLimit(t *testing.T) {
+	ctx := context.Background()
+
+	ctx1, release1 := withUploadThrottler(ctx, uploadPolicyWithSpeed(1000))
+	defer release1()
+
+	ctx2, release2 := withUploadThrottler(ctx1, uploadPolicyWithSpeed(1000))
+	defer release2()
+
+	require.Equal(t, ctx1, ctx2, "throttler should be shared when the limit does not change")
+
+	ctx3, release3 := withUploadThrottler(ctx2, uploadPolicyWithSpeed(2000))
+	defer release3()
+
+	require.NotEqual(t, ctx2, ctx3, "new throttler should be created when the limit changes")
+
+	// unlimited subtree below a limited one.
+	ctx4, release4 := withUploadThrottler(ctx3, uploadPolicyWithSpeed(0))
+	defer release4()
+
+	require.Nil(t, ctx4.Value(uploadThrottlerKey{}).(*uploadThrottler).pool)
+}
+
+func TestThrottledReader(t *testing.T) {
+	data := bytes.Repeat([]byte{1, 2, 3, 4}, 5000)
+
+	// no throttler in context
+	r, err := throttledReader(context.Background(), bytes.NewReader(data))
+	require.NoError(t, err)
+
+	got, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	// throttled at 10000 bytes per second, reading 20000 bytes must take at least a second.
+	ctx, release := withUploadThrottler(context.Background(), uploadPolicyWithSpeed(10000))
+	defer release()
+
+	t0 := time.Now()
+
+	r, err = throttledReader(ctx, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	got, err = ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, data, got)
+
+	if dt := time.Since(t0); dt < time.Second {
+		t.Errorf("throttled read was too fast: %v", dt)
+	}
+}
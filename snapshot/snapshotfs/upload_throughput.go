@@ -0,0 +1,62 @@
+package snapshotfs
+
+import (
+	"time"
+
+	"github.com/kopia/kopia/internal/timetrack"
+)
+
+// uploadRateHalfLife determines how quickly smoothed upload rates react to changes in throughput.
+const uploadRateHalfLife = 10 * time.Second
+
+// UploadThroughputEstimate represents smoothed transfer rates and estimated completion of an upload.
+type UploadThroughputEstimate struct {
+	// ProcessedBytesPerSecond is the smoothed rate at which source bytes are hashed or found in cache.
+	ProcessedBytesPerSecond float64 `json:"processedBytesPerSecond,omitempty"`
+
+	// UploadedBytesPerSecond is the smoothed rate at which bytes are written to the repository.
+	UploadedBytesPerSecond float64 `json:"uploadedBytesPerSecond,omitempty"`
+
+	// RemainingBytes is the estimated number of source bytes left to process.
+	RemainingBytes int64 `json:"remainingBytes,omitempty"`
+
+	// RemainingUploadBytes is the estimated number of bytes left to upload, adjusted for
+	// deduplication and compression observed so far.
+	RemainingUploadBytes int64 `json:"remainingUploadBytes,omitempty"`
+
+	PercentComplete float64    `json:"percentComplete,omitempty"`
+	ETA             *time.Time `json:"eta,omitempty"`
+}
+
+// UploadThroughput computes UploadThroughputEstimate from upload counters sampled over time.
+// It is not safe for concurrent use.
+type UploadThroughput struct {
+	processed timetrack.SmoothedRate
+	uploaded  timetrack.SmoothedRate
+}
+
+// Update records current values of upload counters and returns the updated estimate.
+func (t *UploadThroughput) Update(now time.Time, processedBytes, uploadedBytes, estimatedBytes int64) UploadThroughputEstimate {
+	t.processed.HalfLife = uploadRateHalfLife
+	t.uploaded.HalfLife = uploadRateHalfLife
+
+	est := UploadThroughputEstimate{
+		ProcessedBytesPerSecond: t.processed.Update(now, float64(processedBytes)),
+		UploadedBytesPerSecond:  t.uploaded.Update(now, float64(uploadedBytes)),
+	}
+
+	if estimatedBytes <= 0 || processedBytes <= 0 || processedBytes > estimatedBytes {
+		return est
+	}
+
+	est.RemainingBytes = estimatedBytes - processedBytes
+	est.RemainingUploadBytes = int64(float64(est.RemainingBytes) * float64(uploadedBytes) / float64(processedBytes))
+	est.PercentComplete = 100 * float64(processedBytes) / float64(estimatedBytes) //nolint:gomnd
+
+	if est.ProcessedBytesPerSecond > 0 {
+		eta := now.Add(time.Duration(float64(est.RemainingBytes) / est.ProcessedBytesPerSecond * float64(time.Second)))
+		est.ETA = &eta
+	}
+
+	return est
+}
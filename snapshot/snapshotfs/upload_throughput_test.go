@@ -0,0 +1,39 @@
+package snapshotfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadThroughput(t *testing.T) {
+	var tp UploadThroughput
+
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// first sample only establishes the baseline.
+	est := tp.Update(t0, 0, 0, 1000)
+	require.Zero(t, est.ProcessedBytesPerSecond)
+	require.Nil(t, est.ETA)
+
+	// 100 bytes processed per second, half of them uploaded.
+	est = tp.Update(t0.Add(1*time.Second), 100, 50, 1000)
+	require.InDelta(t, 100, est.ProcessedBytesPerSecond, 0.001)
+	require.InDelta(t, 50, est.UploadedBytesPerSecond, 0.001)
+	require.Equal(t, int64(900), est.RemainingBytes)
+	require.Equal(t, int64(450), est.RemainingUploadBytes)
+	require.InDelta(t, 10, est.PercentComplete, 0.001)
+	require.NotNil(t, est.ETA)
+	require.Equal(t, t0.Add(10*time.Second), *est.ETA)
+
+	// a short burst moves the smoothed rate only partially towards the new rate.
+	est = tp.Update(t0.Add(2*time.Second), 400, 200, 1000)
+	require.Greater(t, est.ProcessedBytesPerSecond, 100.0)
+	require.Less(t, est.ProcessedBytesPerSecond, 300.0)
+
+	// no estimate when the total size is unknown.
+	est = tp.Update(t0.Add(3*time.Second), 500, 250, 0)
+	require.Nil(t, est.ETA)
+	require.Zero(t, est.RemainingBytes)
+}
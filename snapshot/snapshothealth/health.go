@@ -0,0 +1,287 @@
+// Package snapshothealth evaluates whether sources are being snapshotted according to their schedules.
+package snapshothealth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// Status is the overall health of a source.
+type Status string
+
+// Supported health statuses, ordered from best to worst.
+const (
+	StatusGreen  Status = "green"
+	StatusYellow Status = "yellow"
+	StatusRed    Status = "red"
+)
+
+var statusSeverity = map[Status]int{
+	StatusGreen:  0,
+	StatusYellow: 1,
+	StatusRed:    2, // nolint:gomnd
+}
+
+// Worse returns the worse of the two statuses.
+func Worse(a, b Status) Status {
+	if statusSeverity[b] > statusSeverity[a] {
+		return b
+	}
+
+	return a
+}
+
+// AtLeast returns true if the status is at least as bad as the other one.
+func (s Status) AtLeast(other Status) bool {
+	return statusSeverity[s] >= statusSeverity[other]
+}
+
+// maxMissedRunsCounted limits the number of scheduled runs evaluated for sources that have not
+// been snapshotted for a long time with a short interval.
+const maxMissedRunsCounted = 1000
+
+// Thresholds determine when a source is reported as yellow or red.
+type Thresholds struct {
+	WarnMissedRuns              int           `json:"warnMissedRuns"`
+	CriticalMissedRuns          int           `json:"criticalMissedRuns"`
+	WarnConsecutiveFailures     int           `json:"warnConsecutiveFailures"`
+	CriticalConsecutiveFailures int           `json:"criticalConsecutiveFailures"`
+	GracePeriod                 time.Duration `json:"gracePeriod"`
+}
+
+// DefaultThresholds are the default health thresholds.
+// nolint:gochecknoglobals
+var DefaultThresholds = Thresholds{
+	WarnMissedRuns:              1,
+	CriticalMissedRuns:          3, // nolint:gomnd
+	WarnConsecutiveFailures:     1,
+	CriticalConsecutiveFailures: 3,                // nolint:gomnd
+	GracePeriod:                 15 * time.Minute, // nolint:gomnd
+}
+
+// SourceHealth describes the health of a single source.
+type SourceHealth struct {
+	Source                  snapshot.SourceInfo `json:"source"`
+	Status                  Status              `json:"status"`
+	LastSnapshotTime        *time.Time          `json:"lastSnapshotTime,omitempty"`
+	LastSuccessTime         *time.Time          `json:"lastSuccessTime,omitempty"`
+	SecondsSinceLastSuccess int64               `json:"secondsSinceLastSuccess,omitempty"`
+	NextSnapshotTime        *time.Time          `json:"nextSnapshotTime,omitempty"`
+	MissedRuns              int                 `json:"missedRuns"`
+	ConsecutiveFailures     int                 `json:"consecutiveFailures"`
+	Reasons                 []string            `json:"reasons,omitempty"`
+}
+
+func (h *SourceHealth) report(st Status, format string, args ...interface{}) {
+	h.Status = Worse(h.Status, st)
+	h.Reasons = append(h.Reasons, fmt.Sprintf(format, args...))
+}
+
+// IsSuccessful determines whether the provided snapshot completed without fatal errors.
+func IsSuccessful(m *snapshot.Manifest) bool {
+	if m.IncompleteReason != "" {
+		return false
+	}
+
+	if m.RootEntry != nil && m.RootEntry.DirSummary != nil && m.RootEntry.DirSummary.FatalErrorCount > 0 {
+		return false
+	}
+
+	return true
+}
+
+// Evaluate computes the health of the source given its scheduling policy and snapshots.
+// failedAttempts is the number of recent failed attempts that did not produce a snapshot manifest,
+// which are only known to the process that attempted them.
+func Evaluate(src snapshot.SourceInfo, sched policy.SchedulingPolicy, snapshots []*snapshot.Manifest, failedAttempts int, now time.Time, th Thresholds) *SourceHealth {
+	h := &SourceHealth{
+		Source: src,
+		Status: StatusGreen,
+	}
+
+	var lastSuccess *snapshot.Manifest
+
+	h.ConsecutiveFailures = failedAttempts
+
+	for _, m := range snapshot.SortByTime(snapshots, true) {
+		if h.LastSnapshotTime == nil {
+			t := m.StartTime
+			h.LastSnapshotTime = &t
+		}
+
+		if IsSuccessful(m) {
+			lastSuccess = m
+			break
+		}
+
+		h.ConsecutiveFailures++
+	}
+
+	switch {
+	case th.CriticalConsecutiveFailures > 0 && h.ConsecutiveFailures >= th.CriticalConsecutiveFailures:
+		h.report(StatusRed, "%v consecutive failed snapshots", h.ConsecutiveFailures)
+	case th.WarnConsecutiveFailures > 0 && h.ConsecutiveFailures >= th.WarnConsecutiveFailures:
+		h.report(StatusYellow, "%v consecutive failed snapshots", h.ConsecutiveFailures)
+	}
+
+	if lastSuccess == nil {
+		if h.ConsecutiveFailures > 0 {
+			h.report(StatusRed, "no successful snapshots")
+		} else {
+			h.report(StatusYellow, "no snapshots")
+		}
+
+		return h
+	}
+
+	t := lastSuccess.StartTime
+	h.LastSuccessTime = &t
+	h.SecondsSinceLastSuccess = int64(now.Sub(t).Seconds())
+
+	if sched.Manual {
+		return h
+	}
+
+	h.MissedRuns, h.NextSnapshotTime = missedRuns(sched, t, now, th.GracePeriod)
+
+	switch {
+	case th.CriticalMissedRuns > 0 && h.MissedRuns >= th.CriticalMissedRuns:
+		h.report(StatusRed, "%v missed scheduled snapshots since %v", h.MissedRuns, t.Format(time.RFC3339))
+	case th.WarnMissedRuns > 0 && h.MissedRuns >= th.WarnMissedRuns:
+		h.report(StatusYellow, "%v missed scheduled snapshots since %v", h.MissedRuns, t.Format(time.RFC3339))
+	}
+
+	return h
+}
+
+// missedRuns returns the number of scheduled snapshot times after the last successful snapshot that
+// are older than the grace period and the first scheduled time that is not considered missed.
+func missedRuns(sched policy.SchedulingPolicy, lastSuccess, now time.Time, grace time.Duration) (int, *time.Time) {
+	missed := 0
+	prev := lastSuccess
+
+	for missed < maxMissedRunsCounted {
+		// times of day are computed relative to the second argument, advance it past the previous snapshot
+		// so that each scheduled time is only counted once.
+		next, ok := sched.NextSnapshotTime(prev, prev.Add(time.Minute))
+		if !ok {
+			return missed, nil
+		}
+
+		if !next.After(prev) {
+			next = prev.Add(time.Minute)
+		}
+
+		if next.Add(grace).After(now) {
+			return missed, &next
+		}
+
+		missed++
+		prev = next
+	}
+
+	return missed, nil
+}
+
+// Report contains health of multiple sources.
+type Report struct {
+	Status  Status          `json:"status"`
+	Sources []*SourceHealth `json:"sources"`
+}
+
+// Add adds the provided source health to the report.
+func (r *Report) Add(h *SourceHealth) {
+	r.Sources = append(r.Sources, h)
+	r.Status = Worse(r.Status, h.Status)
+}
+
+// Sort sorts sources in the report.
+func (r *Report) Sort() {
+	sort.Slice(r.Sources, func(i, j int) bool {
+		return r.Sources[i].Source.String() < r.Sources[j].Source.String()
+	})
+}
+
+// NewReport returns an empty report.
+func NewReport() *Report {
+	return &Report{Status: StatusGreen, Sources: []*SourceHealth{}}
+}
+
+// Sources returns the list of sources that have snapshots or policies defined.
+func Sources(ctx context.Context, rep repo.Repository) ([]snapshot.SourceInfo, error) {
+	sources := map[snapshot.SourceInfo]bool{}
+
+	snapshotSources, err := snapshot.ListSources(ctx, rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list sources")
+	}
+
+	for _, ss := range snapshotSources {
+		sources[ss] = true
+	}
+
+	policies, err := policy.ListPolicies(ctx, rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list policies")
+	}
+
+	for _, pol := range policies {
+		if pol.Target().Path != "" && pol.Target().Host != "" && pol.Target().UserName != "" {
+			sources[pol.Target()] = true
+		}
+	}
+
+	var result []snapshot.SourceInfo
+
+	for src := range sources {
+		result = append(result, src)
+	}
+
+	return result, nil
+}
+
+// EvaluateSource loads the snapshots and effective scheduling policy of the provided source and evaluates its health.
+func EvaluateSource(ctx context.Context, rep repo.Repository, src snapshot.SourceInfo, failedAttempts int, now time.Time, th Thresholds) (*SourceHealth, error) {
+	pol, _, err := policy.GetEffectivePolicy(ctx, rep, src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get effective policy for %v", src)
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, rep, src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list snapshots of %v", src)
+	}
+
+	return Evaluate(src, pol.SchedulingPolicy, snapshots, failedAttempts, now, th), nil
+}
+
+// EvaluateAll returns the health report for all sources that have snapshots or policies defined.
+func EvaluateAll(ctx context.Context, rep repo.Repository, now time.Time, th Thresholds) (*Report, error) {
+	sources, err := Sources(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewReport()
+
+	for _, src := range sources {
+		h, err := EvaluateSource(ctx, rep, src, 0, now, th)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Add(h)
+	}
+
+	r.Sort()
+
+	return r, nil
+}
@@ -0,0 +1,136 @@
+package snapshothealth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestEvaluate(t *testing.T) {
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/path"}
+	t0 := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	hourly := policy.SchedulingPolicy{IntervalSeconds: 3600}
+
+	good := func(start time.Time) *snapshot.Manifest {
+		return &snapshot.Manifest{StartTime: start, RootEntry: &snapshot.DirEntry{DirSummary: &fs.DirectorySummary{}}}
+	}
+
+	incomplete := func(start time.Time) *snapshot.Manifest {
+		return &snapshot.Manifest{StartTime: start, IncompleteReason: "canceled"}
+	}
+
+	withFatalErrors := func(start time.Time) *snapshot.Manifest {
+		return &snapshot.Manifest{StartTime: start, RootEntry: &snapshot.DirEntry{DirSummary: &fs.DirectorySummary{FatalErrorCount: 1}}}
+	}
+
+	cases := []struct {
+		desc           string
+		sched          policy.SchedulingPolicy
+		snapshots      []*snapshot.Manifest
+		failedAttempts int
+		now            time.Time
+		wantStatus     Status
+		wantMissed     int
+		wantFailures   int
+	}{
+		{
+			desc:       "no snapshots",
+			sched:      hourly,
+			now:        t0,
+			wantStatus: StatusYellow,
+		},
+		{
+			desc:       "up to date",
+			sched:      hourly,
+			snapshots:  []*snapshot.Manifest{good(t0.Add(-2 * time.Hour)), good(t0.Add(-30 * time.Minute))},
+			now:        t0,
+			wantStatus: StatusGreen,
+		},
+		{
+			desc:       "within grace period",
+			sched:      hourly,
+			snapshots:  []*snapshot.Manifest{good(t0)},
+			now:        t0.Add(70 * time.Minute),
+			wantStatus: StatusGreen,
+		},
+		{
+			desc:       "one missed run",
+			sched:      hourly,
+			snapshots:  []*snapshot.Manifest{good(t0)},
+			now:        t0.Add(80 * time.Minute),
+			wantStatus: StatusYellow,
+			wantMissed: 1,
+		},
+		{
+			desc:       "many missed runs",
+			sched:      hourly,
+			snapshots:  []*snapshot.Manifest{good(t0)},
+			now:        t0.Add(5 * time.Hour),
+			wantStatus: StatusRed,
+			wantMissed: 4,
+		},
+		{
+			desc:       "manual schedule is never missed",
+			sched:      policy.SchedulingPolicy{Manual: true},
+			snapshots:  []*snapshot.Manifest{good(t0)},
+			now:        t0.Add(50 * time.Hour),
+			wantStatus: StatusGreen,
+		},
+		{
+			desc:         "single failure",
+			sched:        hourly,
+			snapshots:    []*snapshot.Manifest{good(t0), incomplete(t0.Add(10 * time.Minute))},
+			now:          t0.Add(20 * time.Minute),
+			wantStatus:   StatusYellow,
+			wantFailures: 1,
+		},
+		{
+			desc:           "consecutive failures including unrecorded attempts",
+			sched:          hourly,
+			snapshots:      []*snapshot.Manifest{good(t0), incomplete(t0.Add(10 * time.Minute)), withFatalErrors(t0.Add(20 * time.Minute))},
+			failedAttempts: 1,
+			now:            t0.Add(30 * time.Minute),
+			wantStatus:     StatusRed,
+			wantFailures:   3,
+		},
+		{
+			desc:         "only failed snapshots",
+			sched:        hourly,
+			snapshots:    []*snapshot.Manifest{incomplete(t0)},
+			now:          t0.Add(time.Minute),
+			wantStatus:   StatusRed,
+			wantFailures: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.desc, func(t *testing.T) {
+			h := Evaluate(src, tc.sched, tc.snapshots, tc.failedAttempts, tc.now, DefaultThresholds)
+			require.Equal(t, tc.wantStatus, h.Status, h.Reasons)
+			require.Equal(t, tc.wantMissed, h.MissedRuns)
+			require.Equal(t, tc.wantFailures, h.ConsecutiveFailures)
+		})
+	}
+}
+
+func TestReportStatus(t *testing.T) {
+	r := NewReport()
+	require.Equal(t, StatusGreen, r.Status)
+
+	r.Add(&SourceHealth{Status: StatusYellow})
+	r.Add(&SourceHealth{Status: StatusGreen})
+	require.Equal(t, StatusYellow, r.Status)
+
+	r.Add(&SourceHealth{Status: StatusRed})
+	require.Equal(t, StatusRed, r.Status)
+
+	require.True(t, StatusRed.AtLeast(StatusYellow))
+	require.False(t, StatusGreen.AtLeast(StatusYellow))
+}
@@ -0,0 +1,454 @@
+package snapshotmaintenance
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// objectIDPrefixDirectory is the content prefix of directory objects written by snapshotfs.
+const objectIDPrefixDirectory = "k"
+
+// CompressionDuplicate describes identical data stored in multiple contents, because it was written
+// using different compression settings.
+type CompressionDuplicate struct {
+	// Length is the length of the data before compression.
+	Length int64 `json:"length"`
+
+	// Canonical is the chunk that remains referenced after duplicates are rewritten.
+	Canonical object.ID `json:"canonical"`
+
+	// Duplicates are chunks storing the same data as Canonical.
+	Duplicates []object.ID `json:"duplicates"`
+
+	// ReclaimableBytes is the total packed length of duplicate contents.
+	ReclaimableBytes int64 `json:"reclaimableBytes"`
+}
+
+// CompressionDuplicatesReport is the result of FindCompressionDuplicates.
+type CompressionDuplicatesReport struct {
+	SnapshotsAnalyzed int                     `json:"snapshotsAnalyzed"`
+	ChunksAnalyzed    int                     `json:"chunksAnalyzed"`
+	ChunksRead        int                     `json:"chunksRead"`
+	ReclaimableBytes  int64                   `json:"reclaimableBytes"`
+	Duplicates        []*CompressionDuplicate `json:"duplicates"`
+}
+
+type chunkInfo struct {
+	oid        object.ID
+	length     int64
+	compressed bool
+}
+
+// FindCompressionDuplicates finds file data referenced by snapshots, which is stored multiple times
+// because it was written using different compression settings, for example after a change of compression policy.
+//
+// Only chunks with identical length, of which at least one is compressed, are read and compared,
+// since identical data written with the same compression settings is always stored once.
+func FindCompressionDuplicates(ctx context.Context, rep repo.DirectRepository) (*CompressionDuplicatesReport, error) {
+	manifests, err := loadAllSnapshots(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CompressionDuplicatesReport{
+		SnapshotsAnalyzed: len(manifests),
+		Duplicates:        []*CompressionDuplicate{},
+	}
+
+	chunks := map[object.ID]chunkInfo{}
+	visited := map[object.ID]bool{}
+
+	for _, man := range manifests {
+		if err := collectChunks(ctx, rep, man.RootEntry, chunks, visited); err != nil {
+			return nil, errors.Wrapf(err, "error analyzing snapshot %v", man.ID)
+		}
+	}
+
+	report.ChunksAnalyzed = len(chunks)
+
+	byLength := map[int64][]chunkInfo{}
+	for _, ci := range chunks {
+		byLength[ci.length] = append(byLength[ci.length], ci)
+	}
+
+	for length, candidates := range byLength {
+		if !mayContainCompressionDuplicates(candidates) {
+			continue
+		}
+
+		dups, err := findDuplicatesAmong(ctx, rep, length, candidates)
+		if err != nil {
+			return nil, err
+		}
+
+		report.ChunksRead += len(candidates)
+
+		for _, d := range dups {
+			report.ReclaimableBytes += d.ReclaimableBytes
+			report.Duplicates = append(report.Duplicates, d)
+		}
+	}
+
+	sort.Slice(report.Duplicates, func(i, j int) bool {
+		return report.Duplicates[i].Canonical < report.Duplicates[j].Canonical
+	})
+
+	return report, nil
+}
+
+func loadAllSnapshots(ctx context.Context, rep repo.Repository) ([]*snapshot.Manifest, error) {
+	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing snapshot manifests")
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading snapshot manifests")
+	}
+
+	return manifests, nil
+}
+
+func collectChunks(ctx context.Context, rep repo.DirectRepository, e *snapshot.DirEntry, chunks map[object.ID]chunkInfo, visited map[object.ID]bool) error {
+	if e == nil || e.ObjectID == "" || visited[e.ObjectID] {
+		return nil
+	}
+
+	visited[e.ObjectID] = true
+
+	if e.Type == snapshot.EntryTypeDirectory {
+		dm, err := readDirManifest(ctx, rep, e.ObjectID)
+		if err != nil {
+			return err
+		}
+
+		for _, child := range dm.Entries {
+			if err := collectChunks(ctx, rep, child, chunks, visited); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// nolint:wrapcheck
+	return object.IterateChunks(ctx, rep.ContentReader(), e.ObjectID, func(chunk object.ID, length int64) error {
+		if length < 0 {
+			length = e.FileSize
+		}
+
+		_, compressed, _ := chunk.ContentID()
+		chunks[chunk] = chunkInfo{chunk, length, compressed}
+
+		return nil
+	})
+}
+
+// mayContainCompressionDuplicates returns true if the chunks of the same length need to be compared.
+func mayContainCompressionDuplicates(candidates []chunkInfo) bool {
+	if len(candidates) < 2 { //nolint:gomnd
+		return false
+	}
+
+	for _, ci := range candidates {
+		if ci.compressed {
+			return true
+		}
+	}
+
+	return false
+}
+
+func findDuplicatesAmong(ctx context.Context, rep repo.DirectRepository, length int64, candidates []chunkInfo) ([]*CompressionDuplicate, error) {
+	byHash := map[[sha256.Size]byte][]object.ID{}
+
+	for _, ci := range candidates {
+		h, err := hashObject(ctx, rep, ci.oid)
+		if err != nil {
+			return nil, err
+		}
+
+		byHash[h] = append(byHash[h], ci.oid)
+	}
+
+	var result []*CompressionDuplicate
+
+	for _, oids := range byHash {
+		if len(oids) < 2 { //nolint:gomnd
+			continue
+		}
+
+		d, err := newCompressionDuplicate(ctx, rep, length, oids)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, d)
+	}
+
+	return result, nil
+}
+
+// newCompressionDuplicate picks the chunk using the least space as canonical.
+func newCompressionDuplicate(ctx context.Context, rep repo.DirectRepository, length int64, oids []object.ID) (*CompressionDuplicate, error) {
+	packed := map[object.ID]int64{}
+
+	for _, oid := range oids {
+		cid, _, _ := oid.ContentID()
+
+		ci, err := rep.ContentReader().ContentInfo(ctx, cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting content info for %v", cid)
+		}
+
+		packed[oid] = int64(ci.GetPackedLength())
+	}
+
+	sort.Slice(oids, func(i, j int) bool {
+		if packed[oids[i]] != packed[oids[j]] {
+			return packed[oids[i]] < packed[oids[j]]
+		}
+
+		return oids[i] < oids[j]
+	})
+
+	d := &CompressionDuplicate{
+		Length:     length,
+		Canonical:  oids[0],
+		Duplicates: oids[1:],
+	}
+
+	for _, oid := range d.Duplicates {
+		d.ReclaimableBytes += packed[oid]
+	}
+
+	return d, nil
+}
+
+func hashObject(ctx context.Context, rep repo.Repository, oid object.ID) ([sha256.Size]byte, error) {
+	var result [sha256.Size]byte
+
+	r, err := rep.OpenObject(ctx, oid)
+	if err != nil {
+		return result, errors.Wrapf(err, "error opening %v", oid)
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return result, errors.Wrapf(err, "error reading %v", oid)
+	}
+
+	copy(result[:], h.Sum(nil))
+
+	return result, nil
+}
+
+func readDirManifest(ctx context.Context, rep repo.Repository, oid object.ID) (*snapshot.DirManifest, error) {
+	r, err := rep.OpenObject(ctx, oid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening directory %v", oid)
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	dm := &snapshot.DirManifest{}
+
+	dm.Summary, err = snapshot.ReadDirManifest(r, func(e *snapshot.DirEntry) error {
+		dm.Entries = append(dm.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading directory %v", oid)
+	}
+
+	return dm, nil
+}
+
+// CompressionDuplicatesRewriteStats describes the result of RewriteCompressionDuplicates.
+type CompressionDuplicatesRewriteStats struct {
+	SnapshotsRewritten int `json:"snapshotsRewritten"`
+	SnapshotsSkipped   int `json:"snapshotsSkipped"`
+}
+
+// RewriteCompressionDuplicates rewrites objects, directories and snapshot manifests to reference
+// canonical chunks instead of their duplicates, which makes duplicate contents unreferenced so that
+// they are reclaimed by subsequent snapshot garbage collection and maintenance.
+//
+// Rewritten snapshots are saved as new manifests that replace the original ones.
+// Snapshots under legal hold are never rewritten.
+func RewriteCompressionDuplicates(ctx context.Context, rep repo.DirectRepositoryWriter, report *CompressionDuplicatesReport) (*CompressionDuplicatesRewriteStats, error) {
+	stats := &CompressionDuplicatesRewriteStats{}
+
+	replacements := map[object.ID]object.ID{}
+
+	for _, d := range report.Duplicates {
+		for _, dup := range d.Duplicates {
+			replacements[dup] = d.Canonical
+		}
+	}
+
+	if len(replacements) == 0 {
+		return stats, nil
+	}
+
+	manifests, err := loadAllSnapshots(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	held, err := snapshot.SnapshotsUnderLegalHold(ctx, rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing legal holds")
+	}
+
+	f := rep.ContentReader().ContentFormat()
+
+	rw := &duplicateRewriter{
+		rep: rep,
+		replace: func(chunk object.ID) (object.ID, bool) {
+			r, ok := replacements[chunk]
+			return r, ok
+		},
+		rewritten: map[object.ID]object.ID{},
+		streamed:  f.EnabledFeatures().StreamedDirectories,
+	}
+
+	for _, man := range manifests {
+		if held[man.ID] {
+			log(ctx).Infof("Not rewriting snapshot %v under legal hold.", man.ID)
+
+			stats.SnapshotsSkipped++
+
+			continue
+		}
+
+		newRoot, err := rw.rewriteEntry(ctx, man.RootEntry)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error rewriting snapshot %v", man.ID)
+		}
+
+		if newRoot == man.RootEntry.ObjectID {
+			continue
+		}
+
+		if err := replaceSnapshotRoot(ctx, rep, man, newRoot); err != nil {
+			return nil, err
+		}
+
+		stats.SnapshotsRewritten++
+	}
+
+	return stats, errors.Wrap(rep.Flush(ctx), "error flushing repository")
+}
+
+type duplicateRewriter struct {
+	rep       repo.DirectRepositoryWriter
+	replace   func(chunk object.ID) (object.ID, bool)
+	rewritten map[object.ID]object.ID
+	streamed  bool
+}
+
+// rewriteEntry returns the object ID of the entry after rewriting.
+func (rw *duplicateRewriter) rewriteEntry(ctx context.Context, e *snapshot.DirEntry) (object.ID, error) {
+	if e == nil || e.ObjectID == "" {
+		return "", nil
+	}
+
+	if r, ok := rw.rewritten[e.ObjectID]; ok {
+		return r, nil
+	}
+
+	var (
+		result object.ID
+		err    error
+	)
+
+	if e.Type == snapshot.EntryTypeDirectory {
+		result, err = rw.rewriteDirectory(ctx, e.ObjectID)
+	} else {
+		result, _, err = object.ReplaceChunks(ctx, rw.rep.ContentReader(), rw.rep.NewObjectWriter, e.ObjectID, rw.replace)
+	}
+
+	if err != nil {
+		return "", errors.Wrapf(err, "error rewriting %v", e.Name)
+	}
+
+	rw.rewritten[e.ObjectID] = result
+
+	return result, nil
+}
+
+func (rw *duplicateRewriter) rewriteDirectory(ctx context.Context, oid object.ID) (object.ID, error) {
+	dm, err := readDirManifest(ctx, rw.rep, oid)
+	if err != nil {
+		return "", err
+	}
+
+	changed := false
+
+	for _, child := range dm.Entries {
+		r, err := rw.rewriteEntry(ctx, child)
+		if err != nil {
+			return "", err
+		}
+
+		if r != child.ObjectID {
+			child.ObjectID = r
+			changed = true
+		}
+	}
+
+	if !changed {
+		return oid, nil
+	}
+
+	w := rw.rep.NewObjectWriter(ctx, object.WriterOptions{
+		Description: "DIR:" + string(oid),
+		Prefix:      objectIDPrefixDirectory,
+	})
+
+	defer w.Close() //nolint:errcheck
+
+	if err := snapshot.WriteDirManifest(w, dm, rw.streamed); err != nil {
+		return "", errors.Wrap(err, "unable to write directory manifest")
+	}
+
+	// nolint:wrapcheck
+	return w.Result()
+}
+
+// replaceSnapshotRoot saves a copy of the snapshot manifest with a new root object and deletes the original.
+func replaceSnapshotRoot(ctx context.Context, rep repo.RepositoryWriter, man *snapshot.Manifest, newRoot object.ID) error {
+	oldID := man.ID
+
+	rootEntry := *man.RootEntry
+	rootEntry.ObjectID = newRoot
+
+	newMan := *man
+	newMan.ID = ""
+	newMan.RootEntry = &rootEntry
+
+	newID, err := snapshot.SaveSnapshot(ctx, rep, &newMan)
+	if err != nil {
+		return errors.Wrapf(err, "error saving rewritten snapshot %v", oldID)
+	}
+
+	if err := rep.DeleteManifest(ctx, oldID); err != nil {
+		return errors.Wrapf(err, "error deleting original snapshot %v", oldID)
+	}
+
+	log(ctx).Infof("Rewrote snapshot %v as %v.", oldID, newID)
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package snapshotmaintenance_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/snapshotgc"
+	"github.com/kopia/kopia/snapshot/snapshotmaintenance"
+)
+
+func TestCompressionDuplicates(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newTestHarness(t)
+
+	data := bytes.Repeat([]byte("compressible data "), 1000)
+
+	th.sourceDir.AddDir("d1", defaultPermissions)
+	th.sourceDir.AddFile("d1/f1", data, defaultPermissions)
+	th.sourceDir.AddFile("f2", []byte("small file"), defaultPermissions)
+
+	si1 := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/uncompressed"}
+	si2 := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/compressed"}
+
+	require.NoError(t, policy.SetPolicy(ctx, th.RepositoryWriter, si2, &policy.Policy{
+		CompressionPolicy: policy.CompressionPolicy{CompressorName: "gzip"},
+	}))
+
+	s1 := mustSnapshot(t, th.RepositoryWriter, th.sourceDir, si1)
+	mustSnapshot(t, th.RepositoryWriter, th.sourceDir, si2)
+	mustFlush(t, th.RepositoryWriter)
+
+	report, err := snapshotmaintenance.FindCompressionDuplicates(ctx, th.RepositoryWriter)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.SnapshotsAnalyzed)
+	require.Len(t, report.Duplicates, 1)
+
+	d := report.Duplicates[0]
+	require.Equal(t, int64(len(data)), d.Length)
+	require.Len(t, d.Duplicates, 1)
+	require.Greater(t, d.ReclaimableBytes, int64(0))
+
+	// compressed chunk is smaller, so it's canonical.
+	_, compressed, ok := d.Canonical.ContentID()
+	require.True(t, ok)
+	require.True(t, compressed)
+
+	stats, err := snapshotmaintenance.RewriteCompressionDuplicates(ctx, th.RepositoryWriter, report)
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.SnapshotsRewritten)
+
+	// the original manifest was replaced.
+	_, err = snapshot.LoadSnapshot(ctx, th.RepositoryWriter, s1.ID)
+	require.Error(t, err)
+
+	snapshots, err := snapshot.ListSnapshots(ctx, th.RepositoryWriter, si1)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	require.NotEqual(t, s1.RootObjectID(), snapshots[0].RootObjectID())
+	require.Equal(t, s1.StartTime, snapshots[0].StartTime)
+
+	// data in the rewritten snapshot is intact.
+	root, err := snapshotfs.SnapshotRoot(th.RepositoryWriter, snapshots[0])
+	require.NoError(t, err)
+
+	f1, err := snapshotfs.GetNestedEntry(ctx, root, []string{"d1", "f1"})
+	require.NoError(t, err)
+
+	r, err := f1.(fs.File).Open(ctx) // nolint:forcetypeassert
+	require.NoError(t, err)
+
+	got, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, data, got)
+
+	// no duplicates remain.
+	report, err = snapshotmaintenance.FindCompressionDuplicates(ctx, th.RepositoryWriter)
+	require.NoError(t, err)
+	require.Empty(t, report.Duplicates)
+
+	// the duplicate is no longer referenced and gets collected.
+	gcStats, err := snapshotgc.Run(ctx, th.RepositoryWriter, true, maintenance.SafetyNone)
+	require.NoError(t, err)
+
+	dupContentID, _, _ := d.Duplicates[0].ContentID()
+
+	ci, err := th.RepositoryWriter.ContentReader().ContentInfo(ctx, dupContentID)
+	require.NoError(t, err)
+	require.True(t, ci.GetDeleted(), "duplicate was not deleted, GC stats: %v", gcStats)
+}
@@ -0,0 +1,207 @@
+package snapshotmaintenance
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// RetentionGapKind describes the kind of retention gap.
+type RetentionGapKind string
+
+// Supported retention gap kinds.
+const (
+	// RetentionGapUnprotected indicates a blob that is not protected by retention at all.
+	RetentionGapUnprotected RetentionGapKind = "unprotected"
+
+	// RetentionGapExpired indicates a blob in use whose retention has already expired.
+	RetentionGapExpired RetentionGapKind = "expired"
+
+	// RetentionGapTooShort indicates a blob whose retention expires before the required protection window.
+	RetentionGapTooShort RetentionGapKind = "too-short"
+)
+
+// RetentionGap describes a blob that could be deleted by a compromised client before
+// the end of the required protection window.
+type RetentionGap struct {
+	BlobID        blob.ID          `json:"blobID"`
+	Kind          RetentionGapKind `json:"kind"`
+	Length        int64            `json:"length"`
+	Timestamp     time.Time        `json:"timestamp"`
+	RetainUntil   time.Time        `json:"retainUntil,omitempty"`
+	RequiredUntil time.Time        `json:"requiredUntil"`
+}
+
+// RetentionAuditOptions provides options for AuditRetention.
+type RetentionAuditOptions struct {
+	// Safety determines the maintenance window, during which blobs must be protected.
+	Safety maintenance.SafetyParameters
+
+	// MinProtection overrides the required protection window computed from maintenance safety
+	// parameters and snapshot retention.
+	MinProtection time.Duration
+}
+
+// RetentionAuditReport is the result of AuditRetention.
+type RetentionAuditReport struct {
+	// MaintenanceWindow is the minimum age at which maintenance may delete blobs.
+	MaintenanceWindow time.Duration `json:"maintenanceWindow"`
+
+	// SnapshotRetentionWindow is the age of the oldest snapshot retained by policies or legal holds.
+	SnapshotRetentionWindow time.Duration `json:"snapshotRetentionWindow"`
+
+	// RequiredProtection is the protection window required for each blob, measured from the time it was written.
+	RequiredProtection time.Duration `json:"requiredProtection"`
+
+	BlobsChecked int             `json:"blobsChecked"`
+	BytesChecked int64           `json:"bytesChecked"`
+	Gaps         []*RetentionGap `json:"gaps"`
+}
+
+// AuditRetention cross-checks retention of pack blobs in use and index blobs against maintenance safety
+// parameters and snapshot retention and returns blobs that could be deleted before the end of the required
+// protection window.
+func AuditRetention(ctx context.Context, rep repo.DirectRepository, opt RetentionAuditOptions) (*RetentionAuditReport, error) {
+	if !rep.BlobReader().Capabilities().Retention {
+		return nil, errors.Wrap(blob.ErrRetentionUnsupported, "storage does not report retention of blobs")
+	}
+
+	now := rep.Time()
+
+	retentionWindow, err := snapshotRetentionWindow(ctx, rep, now)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RetentionAuditReport{
+		MaintenanceWindow:       opt.Safety.MinContentAgeSubjectToGC + opt.Safety.BlobDeleteMinAge,
+		SnapshotRetentionWindow: retentionWindow,
+		Gaps:                    []*RetentionGap{},
+	}
+
+	report.RequiredProtection = report.MaintenanceWindow
+	if report.SnapshotRetentionWindow > report.RequiredProtection {
+		report.RequiredProtection = report.SnapshotRetentionWindow
+	}
+
+	if opt.MinProtection > 0 {
+		report.RequiredProtection = opt.MinProtection
+	}
+
+	inUse := map[blob.ID]bool{}
+
+	if err := rep.ContentReader().IteratePacks(ctx, content.IteratePackOptions{}, func(pi content.PackInfo) error {
+		inUse[pi.PackID] = true
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating packs")
+	}
+
+	for _, prefix := range append([]blob.ID{content.IndexBlobPrefix}, content.PackBlobIDPrefixes...) {
+		if err := rep.BlobReader().ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+			if prefix != content.IndexBlobPrefix && !inUse[bm.BlobID] {
+				// unreferenced packs are subject to deletion anyway.
+				return nil
+			}
+
+			ri, err := blob.GetRetention(ctx, rep.BlobReader(), bm.BlobID)
+			if err != nil {
+				return errors.Wrapf(err, "unable to get retention of %v", bm.BlobID)
+			}
+
+			report.BlobsChecked++
+			report.BytesChecked += bm.Length
+
+			if g := checkBlobRetention(bm, ri, now, report.RequiredProtection); g != nil {
+				report.Gaps = append(report.Gaps, g)
+			}
+
+			return nil
+		}); err != nil {
+			return nil, errors.Wrapf(err, "error auditing blobs with prefix %v", prefix)
+		}
+	}
+
+	sort.Slice(report.Gaps, func(i, j int) bool {
+		return report.Gaps[i].BlobID < report.Gaps[j].BlobID
+	})
+
+	return report, nil
+}
+
+func checkBlobRetention(bm blob.Metadata, ri blob.RetentionInfo, now time.Time, required time.Duration) *RetentionGap {
+	g := &RetentionGap{
+		BlobID:        bm.BlobID,
+		Length:        bm.Length,
+		Timestamp:     bm.Timestamp,
+		RetainUntil:   ri.RetainUntil,
+		RequiredUntil: bm.Timestamp.Add(required),
+	}
+
+	switch {
+	case ri.RetainUntil.IsZero():
+		g.Kind = RetentionGapUnprotected
+	case !ri.IsLocked(now):
+		g.Kind = RetentionGapExpired
+	case ri.RetainUntil.Before(g.RequiredUntil):
+		g.Kind = RetentionGapTooShort
+	default:
+		return nil
+	}
+
+	return g
+}
+
+// snapshotRetentionWindow returns the age of the oldest snapshot retained by the current policies or legal holds.
+func snapshotRetentionWindow(ctx context.Context, rep repo.Repository, now time.Time) (time.Duration, error) {
+	sources, err := snapshot.ListSources(ctx, rep)
+	if err != nil {
+		return 0, errors.Wrap(err, "error listing sources")
+	}
+
+	held, err := snapshot.SnapshotsUnderLegalHold(ctx, rep)
+	if err != nil {
+		return 0, errors.Wrap(err, "error listing legal holds")
+	}
+
+	var oldest time.Time
+
+	for _, src := range sources {
+		snapshots, err := snapshot.ListSnapshots(ctx, rep, src)
+		if err != nil {
+			return 0, errors.Wrapf(err, "error listing snapshots of %v", src)
+		}
+
+		pol, _, err := policy.GetEffectivePolicy(ctx, rep, src)
+		if err != nil {
+			return 0, errors.Wrapf(err, "error getting policy for %v", src)
+		}
+
+		pol.RetentionPolicy.ComputeRetentionReasons(snapshots)
+
+		for _, m := range snapshots {
+			if len(m.RetentionReasons) == 0 && !held[m.ID] {
+				continue
+			}
+
+			if oldest.IsZero() || m.StartTime.Before(oldest) {
+				oldest = m.StartTime
+			}
+		}
+	}
+
+	if oldest.IsZero() || oldest.After(now) {
+		return 0, nil
+	}
+
+	return now.Sub(oldest), nil
+}
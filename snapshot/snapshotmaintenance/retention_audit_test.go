@@ -0,0 +1,132 @@
+package snapshotmaintenance_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotmaintenance"
+)
+
+// retentionReader reports retention of blobs relative to their timestamps.
+type retentionReader struct {
+	blob.Reader
+
+	retainFor        map[blob.ID]time.Duration
+	expired          map[blob.ID]bool
+	defaultRetainFor time.Duration
+}
+
+func (r *retentionReader) Capabilities() blob.Capabilities {
+	c := r.Reader.Capabilities()
+	c.Retention = true
+
+	return c
+}
+
+func (r *retentionReader) GetRetention(ctx context.Context, id blob.ID) (blob.RetentionInfo, error) {
+	bm, err := r.GetMetadata(ctx, id)
+	if err != nil {
+		return blob.RetentionInfo{}, err
+	}
+
+	if r.expired[id] {
+		return blob.RetentionInfo{Mode: "COMPLIANCE", RetainUntil: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}, nil
+	}
+
+	d, ok := r.retainFor[id]
+	if !ok {
+		d = r.defaultRetainFor
+	}
+
+	if d == 0 {
+		return blob.RetentionInfo{}, nil
+	}
+
+	return blob.RetentionInfo{Mode: "COMPLIANCE", RetainUntil: bm.Timestamp.Add(d)}, nil
+}
+
+// repositoryWithBlobReader overrides blob reader of a repository.
+type repositoryWithBlobReader struct {
+	repo.DirectRepositoryWriter
+
+	br blob.Reader
+}
+
+func (r repositoryWithBlobReader) BlobReader() blob.Reader {
+	return r.br
+}
+
+func TestAuditRetention(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newTestHarness(t)
+
+	th.sourceDir.AddFile("f1", []byte{1, 2, 3, 4}, defaultPermissions)
+
+	mustSnapshot(t, th.RepositoryWriter, th.sourceDir, snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/foo"})
+	mustFlush(t, th.RepositoryWriter)
+
+	// storage does not support reporting retention.
+	_, err := snapshotmaintenance.AuditRetention(ctx, th.RepositoryWriter, snapshotmaintenance.RetentionAuditOptions{Safety: maintenance.SafetyFull})
+	require.True(t, errors.Is(err, blob.ErrRetentionUnsupported), "unexpected error: %v", err)
+
+	rr := &retentionReader{
+		Reader:           th.RepositoryWriter.BlobReader(),
+		retainFor:        map[blob.ID]time.Duration{},
+		expired:          map[blob.ID]bool{},
+		defaultRetainFor: 365 * 24 * time.Hour,
+	}
+	rep := repositoryWithBlobReader{th.RepositoryWriter, rr}
+
+	report, err := snapshotmaintenance.AuditRetention(ctx, rep, snapshotmaintenance.RetentionAuditOptions{Safety: maintenance.SafetyFull})
+	require.NoError(t, err)
+	require.Equal(t, maintenance.SafetyFull.MinContentAgeSubjectToGC+maintenance.SafetyFull.BlobDeleteMinAge, report.MaintenanceWindow)
+	require.Equal(t, report.MaintenanceWindow, report.RequiredProtection)
+	require.NotZero(t, report.BlobsChecked)
+	require.Empty(t, report.Gaps)
+
+	var ids []blob.ID
+
+	require.NoError(t, th.RepositoryWriter.BlobReader().ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		if bm.BlobID[0] == 'p' || bm.BlobID[0] == 'q' || bm.BlobID[0] == 'n' {
+			ids = append(ids, bm.BlobID)
+		}
+
+		return nil
+	}))
+	require.GreaterOrEqual(t, len(ids), 3)
+
+	rr.retainFor[ids[0]] = 0
+	rr.expired[ids[1]] = true
+	rr.retainFor[ids[2]] = time.Hour
+
+	report, err = snapshotmaintenance.AuditRetention(ctx, rep, snapshotmaintenance.RetentionAuditOptions{Safety: maintenance.SafetyFull})
+	require.NoError(t, err)
+
+	kinds := map[blob.ID]snapshotmaintenance.RetentionGapKind{}
+	for _, g := range report.Gaps {
+		kinds[g.BlobID] = g.Kind
+	}
+
+	require.Equal(t, map[blob.ID]snapshotmaintenance.RetentionGapKind{
+		ids[0]: snapshotmaintenance.RetentionGapUnprotected,
+		ids[1]: snapshotmaintenance.RetentionGapExpired,
+		ids[2]: snapshotmaintenance.RetentionGapTooShort,
+	}, kinds)
+
+	// retention shorter than the explicitly required protection.
+	report, err = snapshotmaintenance.AuditRetention(ctx, rep, snapshotmaintenance.RetentionAuditOptions{
+		Safety:        maintenance.SafetyFull,
+		MinProtection: 2 * 365 * 24 * time.Hour,
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Gaps, report.BlobsChecked)
+}
@@ -7,10 +7,13 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/snapshot/snapshotgc"
 )
 
+var log = logging.GetContextLoggerFunc("snapshotmaintenance")
+
 // Run runs the complete snapshot and repository maintenance.
 func Run(ctx context.Context, dr repo.DirectRepositoryWriter, mode maintenance.Mode, force bool, safety maintenance.SafetyParameters) error {
 	// nolint:wrapcheck
@@ -18,6 +21,13 @@ func Run(ctx context.Context, dr repo.DirectRepositoryWriter, mode maintenance.M
 		func(runParams maintenance.RunParameters) error {
 			// run snapshot GC before full maintenance
 			if runParams.Mode == maintenance.ModeFull {
+				// rewrite before snapshot GC, so that it can mark duplicates as deleted in the same cycle.
+				if runParams.Params.RewriteCompressionDuplicates {
+					if err := runTaskRewriteCompressionDuplicates(ctx, dr); err != nil {
+						return errors.Wrap(err, "error rewriting compression duplicates")
+					}
+				}
+
 				if _, err := snapshotgc.Run(ctx, dr, true, safety); err != nil {
 					return errors.Wrap(err, "snapshot GC failure")
 				}
@@ -27,3 +37,19 @@ func(runParams maintenance.RunParameters) error {
 			return maintenance.Run(ctx, runParams, safety)
 		})
 }
+
+func runTaskRewriteCompressionDuplicates(ctx context.Context, dr repo.DirectRepositoryWriter) error {
+	// nolint:wrapcheck
+	return maintenance.ReportRun(ctx, dr, maintenance.TaskRewriteCompressionDuplicates, nil, func() error {
+		report, err := FindCompressionDuplicates(ctx, dr)
+		if err != nil {
+			return err
+		}
+
+		log(ctx).Infof("Found %v chunks stored with different compression settings.", len(report.Duplicates))
+
+		_, err = RewriteCompressionDuplicates(ctx, dr, report)
+
+		return err
+	})
+}
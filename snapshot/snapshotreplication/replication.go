@@ -0,0 +1,278 @@
+// Package snapshotreplication implements incremental replication of snapshots between repositories.
+package snapshotreplication
+
+import (
+	"context"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+var log = logging.GetContextLoggerFunc("snapshotreplication")
+
+const (
+	// ManifestType is the type of manifest that holds replication state.
+	ManifestType = "replication"
+
+	// SourceRepositoryLabel is the manifest label that identifies the source repository.
+	SourceRepositoryLabel = "sourceRepository"
+
+	typeKey = manifest.TypeLabelKey
+)
+
+// State describes replication progress of a single snapshot source from a single source repository.
+// It is stored in the target repository.
+type State struct {
+	SourceRepositoryID string              `json:"sourceRepositoryID"`
+	Source             snapshot.SourceInfo `json:"source"`
+
+	// LastStartTime is the start time of the most recent replicated snapshot, only newer snapshots will be replicated.
+	LastStartTime   time.Time `json:"lastStartTime"`
+	LastReplication time.Time `json:"lastReplication"`
+	Replicated      int       `json:"replicated"`
+}
+
+// Options provides options for Replicate.
+type Options struct {
+	// Sources to replicate, if empty all sources in the source repository are replicated.
+	Sources []snapshot.SourceInfo
+}
+
+// Stats describes the result of a single replication pass.
+type Stats struct {
+	Replicated int `json:"replicated"`
+	Skipped    int `json:"skipped"`
+}
+
+// RepositoryID returns the identifier of the source repository that is used to track replication state.
+func RepositoryID(rep repo.DirectRepository) string {
+	return hex.EncodeToString(rep.UniqueID())
+}
+
+func labelsForState(sourceRepositoryID string, si snapshot.SourceInfo) map[string]string {
+	m := map[string]string{
+		typeKey:                ManifestType,
+		SourceRepositoryLabel:  sourceRepositoryID,
+		snapshot.HostnameLabel: si.Host,
+	}
+
+	if si.UserName != "" {
+		m[snapshot.UsernameLabel] = si.UserName
+	}
+
+	if si.Path != "" {
+		m[snapshot.PathLabel] = si.Path
+	}
+
+	return m
+}
+
+// ListStates returns replication states stored in the provided target repository.
+func ListStates(ctx context.Context, rep repo.Repository) ([]*State, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{typeKey: ManifestType})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find replication state manifests")
+	}
+
+	var result []*State
+
+	for _, e := range entries {
+		s := &State{}
+		if _, err := rep.GetManifest(ctx, e.ID, s); err != nil {
+			return nil, errors.Wrap(err, "unable to load replication state")
+		}
+
+		result = append(result, s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].SourceRepositoryID != result[j].SourceRepositoryID {
+			return result[i].SourceRepositoryID < result[j].SourceRepositoryID
+		}
+
+		return result[i].Source.String() < result[j].Source.String()
+	})
+
+	return result, nil
+}
+
+func getState(ctx context.Context, rep repo.Repository, sourceRepositoryID string, si snapshot.SourceInfo) (*State, error) {
+	entries, err := rep.FindManifests(ctx, labelsForState(sourceRepositoryID, si))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find replication state")
+	}
+
+	s := &State{SourceRepositoryID: sourceRepositoryID, Source: si}
+
+	if len(entries) == 0 {
+		return s, nil
+	}
+
+	if _, err := rep.GetManifest(ctx, manifest.PickLatestID(entries), s); err != nil {
+		return nil, errors.Wrap(err, "unable to load replication state")
+	}
+
+	return s, nil
+}
+
+func saveState(ctx context.Context, rep repo.RepositoryWriter, s *State) error {
+	labels := labelsForState(s.SourceRepositoryID, s.Source)
+
+	entries, err := rep.FindManifests(ctx, labels)
+	if err != nil {
+		return errors.Wrap(err, "unable to find replication state")
+	}
+
+	if _, err := rep.PutManifest(ctx, labels, s); err != nil {
+		return errors.Wrap(err, "unable to save replication state")
+	}
+
+	for _, e := range entries {
+		if err := rep.DeleteManifest(ctx, e.ID); err != nil {
+			return errors.Wrap(err, "unable to delete previous replication state")
+		}
+	}
+
+	return nil
+}
+
+// Replicate copies snapshots created in the source repository since the last replication to the target repository.
+// Replication state is saved in the target repository after each snapshot, so interrupted replication
+// resumes where it left off.
+func Replicate(ctx context.Context, uploader *snapshotfs.Uploader, src repo.DirectRepository, dst repo.RepositoryWriter, opt Options) (*Stats, error) {
+	sources := opt.Sources
+	if len(sources) == 0 {
+		var err error
+
+		if sources, err = snapshot.ListSources(ctx, src); err != nil {
+			return nil, errors.Wrap(err, "unable to list sources")
+		}
+	}
+
+	st := &Stats{}
+
+	for _, si := range sources {
+		if uploader.IsCanceled() {
+			break
+		}
+
+		if err := replicateSource(ctx, uploader, src, dst, si, st); err != nil {
+			return st, errors.Wrapf(err, "error replicating %v", si)
+		}
+	}
+
+	return st, nil
+}
+
+func replicateSource(ctx context.Context, uploader *snapshotfs.Uploader, src repo.DirectRepository, dst repo.RepositoryWriter, si snapshot.SourceInfo, st *Stats) error {
+	state, err := getState(ctx, dst, RepositoryID(src), si)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, src, si)
+	if err != nil {
+		return errors.Wrap(err, "error listing snapshots")
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].StartTime.Before(snapshots[j].StartTime)
+	})
+
+	for _, m := range snapshots {
+		if m.IncompleteReason != "" || !m.StartTime.After(state.LastStartTime) {
+			continue
+		}
+
+		if uploader.IsCanceled() {
+			return nil
+		}
+
+		copied, err := CopySnapshot(ctx, uploader, src, dst, m)
+		if err != nil {
+			return err
+		}
+
+		if copied {
+			st.Replicated++
+			state.Replicated++
+		} else {
+			st.Skipped++
+		}
+
+		state.LastStartTime = m.StartTime
+		state.LastReplication = dst.Time()
+
+		if err := saveState(ctx, dst, state); err != nil {
+			return err
+		}
+
+		// make sure that the copied snapshot and the state are persisted together.
+		if err := dst.Flush(ctx); err != nil {
+			return errors.Wrap(err, "error flushing target repository")
+		}
+	}
+
+	return nil
+}
+
+// CopySnapshot uploads the snapshot from the source repository to the target repository preserving its
+// metadata and returns true if it was copied or false if a snapshot of the same source
+// with the same start time already exists in the target repository.
+func CopySnapshot(ctx context.Context, uploader *snapshotfs.Uploader, src repo.Repository, dst repo.RepositoryWriter, m *snapshot.Manifest) (bool, error) {
+	existing, err := snapshot.ListSnapshots(ctx, dst, m.Source)
+	if err != nil {
+		return false, errors.Wrap(err, "error listing existing snapshots")
+	}
+
+	var previous []*snapshot.Manifest
+
+	for _, e := range existing {
+		if e.StartTime.Equal(m.StartTime) {
+			log(ctx).Infof("snapshot of %v at %v already exists", m.Source, m.StartTime)
+			return false, nil
+		}
+
+		if e.StartTime.Before(m.StartTime) && e.IncompleteReason == "" && (len(previous) == 0 || e.StartTime.After(previous[0].StartTime)) {
+			previous = []*snapshot.Manifest{e}
+		}
+	}
+
+	root, err := snapshotfs.SnapshotRoot(src, m)
+	if err != nil {
+		return false, errors.Wrap(err, "error getting snapshot root entry")
+	}
+
+	log(ctx).Infof("copying snapshot of %v at %v", m.Source, m.StartTime)
+
+	var policyTree *policy.Tree
+
+	newm, err := uploader.Upload(ctx, root, policyTree, m.Source, previous...)
+	if err != nil {
+		return false, errors.Wrapf(err, "error copying snapshot %v @ %v", m.Source, m.StartTime)
+	}
+
+	if newm.IncompleteReason != "" {
+		return false, errors.Errorf("copy of %v @ %v is incomplete: %v", m.Source, m.StartTime, newm.IncompleteReason)
+	}
+
+	newm.StartTime = m.StartTime
+	newm.EndTime = m.EndTime
+	newm.Description = m.Description
+	newm.Tags = m.Tags
+
+	if _, err := snapshot.SaveSnapshot(ctx, dst, newm); err != nil {
+		return false, errors.Wrap(err, "cannot save manifest")
+	}
+
+	return true, nil
+}
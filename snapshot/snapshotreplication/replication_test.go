@@ -0,0 +1,99 @@
+package snapshotreplication_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/snapshotreplication"
+)
+
+func TestReplicate(t *testing.T) {
+	ctx, srcEnv := repotesting.NewEnvironment(t)
+	_, dstEnv := repotesting.NewEnvironment(t)
+
+	si1 := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/foo"}
+	si2 := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/bar"}
+
+	dir := mockfs.NewDirectory()
+	dir.AddFile("f1", []byte{1, 2, 3, 4}, 0o644)
+
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	createSnapshot(ctx, t, srcEnv.RepositoryWriter, dir, si1, t0)
+	createSnapshot(ctx, t, srcEnv.RepositoryWriter, dir, si2, t0)
+
+	replicate := func(opt snapshotreplication.Options) *snapshotreplication.Stats {
+		t.Helper()
+
+		st, err := snapshotreplication.Replicate(ctx, snapshotfs.NewUploader(dstEnv.RepositoryWriter), srcEnv.RepositoryWriter, dstEnv.RepositoryWriter, opt)
+		require.NoError(t, err)
+
+		return st
+	}
+
+	require.Equal(t, snapshotreplication.Stats{Replicated: 2}, *replicate(snapshotreplication.Options{}))
+	require.Equal(t, snapshotreplication.Stats{}, *replicate(snapshotreplication.Options{}))
+
+	dir.AddFile("f2", []byte("new file"), 0o644)
+	createSnapshot(ctx, t, srcEnv.RepositoryWriter, dir, si1, t0.Add(time.Hour))
+	createSnapshot(ctx, t, srcEnv.RepositoryWriter, dir, si2, t0.Add(time.Hour))
+
+	// only the selected source is replicated.
+	require.Equal(t, snapshotreplication.Stats{Replicated: 1}, *replicate(snapshotreplication.Options{Sources: []snapshot.SourceInfo{si1}}))
+
+	// snapshot copied outside of replication is skipped.
+	m, err := snapshot.ListSnapshots(ctx, srcEnv.RepositoryWriter, si2)
+	require.NoError(t, err)
+
+	for _, sm := range m {
+		if sm.StartTime.Equal(t0.Add(time.Hour)) {
+			copied, err := snapshotreplication.CopySnapshot(ctx, snapshotfs.NewUploader(dstEnv.RepositoryWriter), srcEnv.RepositoryWriter, dstEnv.RepositoryWriter, sm)
+			require.NoError(t, err)
+			require.True(t, copied)
+		}
+	}
+
+	require.Equal(t, snapshotreplication.Stats{Skipped: 1}, *replicate(snapshotreplication.Options{}))
+
+	for _, si := range []snapshot.SourceInfo{si1, si2} {
+		snaps, err := snapshot.ListSnapshots(ctx, dstEnv.RepositoryWriter, si)
+		require.NoError(t, err)
+		require.Len(t, snaps, 2)
+	}
+
+	states, err := snapshotreplication.ListStates(ctx, dstEnv.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+
+	for _, s := range states {
+		require.Equal(t, snapshotreplication.RepositoryID(srcEnv.RepositoryWriter), s.SourceRepositoryID)
+		require.Equal(t, t0.Add(time.Hour), s.LastStartTime.UTC())
+	}
+
+	// state survives reopening the target repository.
+	dstEnv.MustReopen(t)
+
+	require.Equal(t, snapshotreplication.Stats{}, *replicate(snapshotreplication.Options{}))
+}
+
+func createSnapshot(ctx context.Context, t *testing.T, rep repo.RepositoryWriter, e fs.Entry, si snapshot.SourceInfo, startTime time.Time) {
+	t.Helper()
+
+	man, err := snapshotfs.NewUploader(rep).Upload(ctx, e, nil, si)
+	require.NoError(t, err)
+
+	man.StartTime = startTime
+	man.EndTime = startTime.Add(time.Minute)
+
+	_, err = snapshot.SaveSnapshot(ctx, rep, man)
+	require.NoError(t, err)
+}
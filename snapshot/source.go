@@ -30,6 +30,7 @@ func (ssi SourceInfo) String() string {
 // ParseSourceInfo parses a given path in the context of given hostname and username and returns
 // SourceInfo. The path may be bare (in which case it's interpreted as local path and canonicalized)
 // or may be 'username@host:path' where path, username and host are not processed.
+// Policy targets '@host', 'username@host' and 'username@' (the user on all hosts) are also supported.
 func ParseSourceInfo(path, hostname, username string) (SourceInfo, error) {
 	if path == "(global)" {
 		return SourceInfo{}, nil
@@ -55,6 +56,13 @@ func ParseSourceInfo(path, hostname, username string) (SourceInfo, error) {
 			}, nil
 		}
 
+		if p1 > 0 {
+			// support user@ which targets the user on all hosts
+			return SourceInfo{
+				UserName: path[0:p1],
+			}, nil
+		}
+
 		return SourceInfo{}, errors.Errorf("invalid hostname in %q", path)
 	}
 
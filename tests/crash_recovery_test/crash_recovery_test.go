@@ -0,0 +1,149 @@
+// Package crashrecovery_test repeatedly kills kopia processes at random points and verifies that the repository remains consistent.
+package crashrecovery_test
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/tests/testdirtree"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+const (
+	defaultIterations = 20
+	maxKillDelay      = 500 * time.Millisecond
+)
+
+type crashOperation struct {
+	name string
+	args []string
+}
+
+func TestKillAndVerify(t *testing.T) {
+	runner := testenv.NewExeRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	seed := envInt(t, "KOPIA_CRASH_RECOVERY_SEED", int(clock.Now().UnixNano()))
+	iterations := envInt(t, "KOPIA_CRASH_RECOVERY_ITERATIONS", defaultIterations)
+
+	t.Logf("using random seed %v (set KOPIA_CRASH_RECOVERY_SEED to reproduce)", seed)
+
+	rnd := rand.New(rand.NewSource(int64(seed))) //nolint:gosec
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	source := filepath.Join(e.ConfigDir, "source")
+	addRandomFiles(t, source, 0)
+
+	ops := []crashOperation{
+		{"snapshot", []string{"snapshot", "create", source, "--checkpoint-interval=1s", "--parallel=4"}},
+		{"quick-maintenance", []string{"maintenance", "run", "--safety=none"}},
+		{"full-maintenance", []string{"maintenance", "run", "--full", "--safety=none"}},
+	}
+
+	// kill delays are picked at random within the last observed duration of each operation,
+	// so that operations are interrupted at all stages regardless of how long they take.
+	durations := map[string]time.Duration{}
+
+	for i := 0; i < iterations; i++ {
+		// add new data, so that each snapshot has something to upload.
+		addRandomFiles(t, source, i+1)
+
+		op := ops[rnd.Intn(len(ops))]
+
+		maxDelay := durations[op.name]
+		if maxDelay == 0 {
+			maxDelay = maxKillDelay
+		}
+
+		delay := time.Duration(rnd.Int63n(int64(maxDelay)))
+
+		t.Logf("iteration %v: killing %v after %v", i, op.name, delay)
+
+		if elapsed, completed := startAndKill(t, e, delay, op.args...); completed {
+			durations[op.name] = elapsed
+		}
+
+		verifyRepository(t, e)
+	}
+
+	// after all crashes, a complete snapshot and maintenance must succeed and leave the repository consistent.
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+	e.RunAndExpectSuccess(t, "maintenance", "run", "--full", "--safety=none")
+	verifyRepository(t, e)
+}
+
+// startAndKill starts the command and kills it with SIGKILL after the provided delay, unless it completes sooner,
+// in which case it returns the time it took to complete.
+func startAndKill(t *testing.T, e *testenv.CLITest, delay time.Duration, args ...string) (elapsed time.Duration, completed bool) {
+	t.Helper()
+
+	t0 := clock.Now()
+
+	stdout, stderr, wait, kill := e.Start(t, args...)
+
+	go io.Copy(io.Discard, stdout) //nolint:errcheck
+	go io.Copy(io.Discard, stderr) //nolint:errcheck
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- wait()
+	}()
+
+	select {
+	case err := <-done:
+		t.Logf("completed before it could be killed: %v", err)
+
+		return clock.Since(t0), true
+
+	case <-time.After(delay):
+		kill()
+		t.Logf("killed: %v", <-done)
+
+		return 0, false
+	}
+}
+
+// verifyRepository verifies that every content is backed by a pack blob and all snapshots can be fully read.
+func verifyRepository(t *testing.T, e *testenv.CLITest) {
+	t.Helper()
+
+	e.RunAndExpectSuccess(t, "content", "verify")
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+	e.RunAndExpectSuccess(t, "snapshot", "list", "--all")
+}
+
+func addRandomFiles(t *testing.T, source string, n int) {
+	t.Helper()
+
+	testdirtree.MustCreateDirectoryTree(t, filepath.Join(source, "dir"+strconv.Itoa(n)), testdirtree.MaybeSimplifyFilesystem(testdirtree.DirectoryTreeOptions{
+		Depth:                  2,
+		MaxSubdirsPerDirectory: 3,
+		MaxFilesPerDirectory:   20,
+		MaxFileSize:            4 << 20,
+	}))
+}
+
+func envInt(t *testing.T, name string, def int) int {
+	t.Helper()
+
+	s := os.Getenv(name)
+	if s == "" {
+		return def
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatalf("invalid %v: %v", name, err)
+	}
+
+	return v
+}
@@ -0,0 +1,75 @@
+package endtoend_test
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestAppendOnlyUser(t *testing.T) {
+	t.Parallel()
+
+	serverRunner := testenv.NewExeRunner(t)
+	serverEnvironment := testenv.NewCLITest(t, serverRunner)
+
+	defer serverEnvironment.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	serverEnvironment.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", serverEnvironment.RepoDir, "--override-hostname=foo", "--override-username=foo")
+	serverEnvironment.RunAndExpectSuccess(t, "server", "users", "add", "foo@bar", "--user-password", "baz", "--append-only=true")
+
+	if lines := serverEnvironment.RunAndExpectSuccess(t, "server", "users", "list"); len(lines) != 1 || lines[0] != "foo@bar (append-only)" {
+		t.Fatalf("unexpected user list: %v", lines)
+	}
+
+	var sp serverParameters
+
+	kill := serverEnvironment.RunAndProcessStderr(t, sp.ProcessOutput,
+		"server", "start",
+		"--address=localhost:0",
+		"--server-username=admin-user",
+		"--server-password=admin-pwd",
+		"--tls-generate-cert",
+		"--tls-generate-rsa-key-size=2048", // use shorter key size to speed up generation
+	)
+
+	defer kill()
+
+	clientRunner := testenv.NewExeRunner(t)
+	clientEnvironment := testenv.NewCLITest(t, clientRunner)
+
+	defer clientEnvironment.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	clientRunner.RemoveDefaultPassword()
+
+	clientEnvironment.RunAndExpectSuccess(t, "repo", "connect", "server",
+		"--url", sp.baseURL+"/",
+		"--server-cert-fingerprint", sp.sha256Fingerprint,
+		"--override-username", "foo",
+		"--override-hostname", "bar",
+		"--password", "baz",
+	)
+
+	// creating snapshots works, even though retention can't be applied.
+	clientEnvironment.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	clientEnvironment.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	sources := clitestutil.ListSnapshotsAndExpectSuccess(t, clientEnvironment)
+	if len(sources) != 1 || len(sources[0].Snapshots) != 2 {
+		t.Fatalf("unexpected snapshots: %v", sources)
+	}
+
+	// own data can be read.
+	clientEnvironment.RunAndExpectSuccess(t, "snapshot", "verify")
+
+	// but not deleted.
+	clientEnvironment.RunAndExpectFailure(t, "snapshot", "delete", sources[0].Snapshots[0].SnapshotID, "--delete")
+
+	// retention can't be shortened by writing a newer policy either.
+	clientEnvironment.RunAndExpectFailure(t, "policy", "set", "--keep-latest=1", sharedTestDataDir1)
+
+	sources = clitestutil.ListSnapshotsAndExpectSuccess(t, clientEnvironment)
+	if len(sources) != 1 || len(sources[0].Snapshots) != 2 {
+		t.Fatalf("unexpected snapshots after failed delete: %v", sources)
+	}
+}
@@ -64,3 +64,43 @@ func TestCompression(t *testing.T) {
 		t.Errorf("invalid object contents")
 	}
 }
+
+func TestParallelCompression(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectFailure(t, "policy", "set", "--global", "--parallel-compression", "0")
+	e.RunAndExpectFailure(t, "policy", "set", "--global", "--parallel-compression", "-1")
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--compression", "zstd", "--parallel-compression", "4")
+
+	out := e.RunAndExpectSuccess(t, "policy", "show", "--global")
+	require.Contains(t, strings.Join(out, "\n"), "Parallel compression: 4 chunks")
+
+	dataDir := testutil.TempDirectory(t)
+	dataLines := make([]string, 1000000)
+	for i := range dataLines {
+		dataLines[i] = "hello world, how are you?"
+	}
+
+	data := []byte(strings.Join(dataLines, "\n"))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dataDir, "some-file1"), data, 0o600))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", dataDir)
+	sources := clitestutil.ListSnapshotsAndExpectSuccess(t, e)
+	oid := sources[0].Snapshots[0].ObjectID
+	entries := clitestutil.ListDirectory(t, e, oid)
+
+	if !strings.HasPrefix(entries[0].ObjectID, "I") {
+		t.Errorf("expected indirect object, got %v", entries[0].ObjectID)
+	}
+
+	if lines := e.RunAndExpectSuccess(t, "show", entries[0].ObjectID); !reflect.DeepEqual(dataLines, lines) {
+		t.Errorf("invalid object contents")
+	}
+}
@@ -0,0 +1,49 @@
+package endtoend_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/blob/faultinject"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotWithInjectedStorageFaults(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	cfg, err := json.Marshal(faultinject.Options{
+		Script: []faultinject.ScriptedFault{
+			{Method: faultinject.MethodPutBlob, PartialWrite: true},
+		},
+	})
+	require.NoError(t, err)
+
+	configFile := filepath.Join(testutil.TempDirectory(t), "faults.json")
+	require.NoError(t, ioutil.WriteFile(configFile, cfg, 0o600))
+
+	dataDir := testutil.TempDirectory(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dataDir, "some-file"), []byte("some data"), 0o600))
+
+	// injected faults are not retried, so the snapshot fails.
+	_, stderr, _ := e.Run(t, true, "snapshot", "create", dataDir, "--fault-injection-config", configFile)
+	require.Contains(t, strings.Join(stderr, "\n"), "injecting scripted fault")
+
+	// partially-written blob must not prevent subsequent snapshots.
+	e.RunAndExpectSuccess(t, "snapshot", "create", dataDir)
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+
+	e.RunAndExpectFailure(t, "snapshot", "list", "--fault-injection-config", filepath.Join(dataDir, "no-such-file"))
+}
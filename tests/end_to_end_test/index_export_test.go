@@ -0,0 +1,47 @@
+package endtoend_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/internal/indexexport"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestIndexExport(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	contents := e.RunAndExpectSuccess(t, "content", "ls", "--deleted")
+
+	lines := e.RunAndExpectSuccess(t, "index", "export")
+	if got, want := len(lines), len(contents)+1; got != want {
+		t.Fatalf("unexpected number of exported lines: %v, want %v", got, want)
+	}
+
+	if got, want := lines[0], strings.Join(indexexport.Columns, ","); got != want {
+		t.Fatalf("unexpected header: %v, want %v", got, want)
+	}
+
+	exportFile := filepath.Join(testutil.TempDirectory(t), "index.csv")
+	e.RunAndExpectSuccess(t, "index", "export", "--output", exportFile)
+
+	// analysis does not require the repository.
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	out := e.RunAndExpectSuccess(t, "index", "import-analysis", exportFile)
+	if !strings.Contains(strings.Join(out, "\n"), "Index entries: ") {
+		t.Fatalf("unexpected analysis output: %v", out)
+	}
+
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir)
+}
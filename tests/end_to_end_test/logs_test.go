@@ -0,0 +1,35 @@
+package endtoend_test
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestLogsCommands(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	if lines := e.RunAndExpectSuccess(t, "logs", "list"); len(lines) != 0 {
+		t.Errorf("unexpected logs: %v", lines)
+	}
+
+	e.RunAndExpectSuccess(t, "logs", "search", "--from", "1h", "--text", "error", "--regex", "blob.*")
+	e.RunAndExpectFailure(t, "logs", "search", "--regex", "[")
+	e.RunAndExpectFailure(t, "logs", "search", "--from", "yesterday")
+
+	e.RunAndExpectSuccess(t, "maintenance", "set", "--max-retained-log-count", "7", "--max-retained-log-age", "48h", "--max-total-retained-log-size-mb", "5")
+
+	info := e.RunAndExpectSuccess(t, "maintenance", "info")
+	if !containsLine(info, "max count:       7") || !containsLine(info, "max age:         48h0m0s") || !containsLine(info, "max total size:  5 MiB") {
+		t.Errorf("unexpected log retention: %v", info)
+	}
+
+	e.RunAndExpectSuccess(t, "maintenance", "run", "--full")
+}
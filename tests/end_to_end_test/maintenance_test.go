@@ -20,8 +20,8 @@ func TestFullMaintenance(t *testing.T) {
 
 	var snap snapshot.Manifest
 
-	// after creation we'll have kopia.repository, 1 index + 1 pack blob
-	if got, want := e.RunAndExpectSuccess(t, "blob", "list"), 3; len(got) != want {
+	// after creation we'll have kopia.repository, kopia.stats, 1 index + 1 pack blob
+	if got, want := e.RunAndExpectSuccess(t, "blob", "list"), 4; len(got) != want {
 		t.Fatalf("unexpected number of initial blobs: %v, want %v", got, want)
 	}
 
@@ -48,15 +48,38 @@ func TestFullMaintenance(t *testing.T) {
 		t.Fatalf("maintenance did not remove blobs: %v, had %v", got, originalBlobCount)
 	}
 
-	// we're expecting to have 5 or 6 blobs:
+	// we're expecting to have 6 or 7 blobs:
 	// - kopia.maintenance
 	// - kopia.repository
+	// - kopia.stats
 	// - 2 index blobs
 	// - 1 or 2 q blob
 
-	const blobCountAfterFullWipeout = 6
+	const blobCountAfterFullWipeout = 7
 
 	if got, want := e.RunAndExpectSuccess(t, "blob", "list"), blobCountAfterFullWipeout; len(got) > want {
 		t.Fatalf("maintenance left unwanted blobs: %v, want %v", got, want)
 	}
 }
+
+func TestMaintenanceTakeOwnership(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "maintenance", "set", "--owner", "gone@somehost")
+	e.RunAndExpectFailure(t, "maintenance", "run", "--full")
+
+	e.RunAndExpectSuccess(t, "maintenance", "set", "--take-ownership", "--owner", "new@otherhost", "--take-ownership-wait", "1s")
+
+	if !containsLine(e.RunAndExpectSuccess(t, "maintenance", "info"), "Owner: new@otherhost") {
+		t.Fatalf("maintenance ownership was not taken over")
+	}
+
+	// taking over again fails, since the owner is unchanged.
+	e.RunAndExpectFailure(t, "maintenance", "set", "--take-ownership", "--owner", "new@otherhost", "--take-ownership-wait", "1s")
+}
@@ -0,0 +1,59 @@
+package endtoend_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestMetricsPush(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		requests = map[string][]byte{}
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path] = b
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "file.txt"), []byte("hello"), 0o600))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source,
+		"--metrics-push-addr="+srv.URL,
+		"--metrics-push-job=backup",
+		"--metrics-push-grouping=instance:host1")
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	body, ok := requests["PUT /metrics/job/backup/instance/host1"]
+	require.True(t, ok, "metrics were not pushed: %v", requests)
+	require.Contains(t, string(body), "kopia_snapshot_count")
+
+	e.RunAndExpectFailure(t, "snapshot", "list", "--metrics-push-addr="+srv.URL, "--metrics-push-grouping=invalid")
+}
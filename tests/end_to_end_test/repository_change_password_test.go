@@ -0,0 +1,58 @@
+package endtoend_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryChangePassword(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	e.RunAndExpectFailure(t, "repo", "set-parameters", "--format-key-age-action", "invalid")
+	e.RunAndExpectSuccess(t, "repo", "set-parameters", "--max-format-key-age", "720h", "--format-key-age-action", "block")
+
+	if !containsLine(e.RunAndExpectSuccess(t, "repo", "status"), "720h0m0s (block)") {
+		t.Errorf("format key policy not reported in status")
+	}
+
+	e.RunAndExpectSuccess(t, "repo", "change-password", "--new-password", "new-password")
+
+	// old password no longer works.
+	e.RunAndExpectFailure(t, "repo", "status")
+
+	runner.Password = "new-password"
+
+	status := e.RunAndExpectSuccess(t, "repo", "status")
+	if got := formatKeyHistoryLength(status); got != 2 {
+		t.Errorf("unexpected number of format key history entries: %v\n%v", got, status)
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	e.RunAndExpectSuccess(t, "snapshot", "list")
+}
+
+func formatKeyHistoryLength(status []string) int {
+	n := -1
+
+	for _, l := range status {
+		switch {
+		case l == "Format key history:":
+			n = 0
+		case n >= 0 && strings.HasPrefix(l, "  "):
+			n++
+		case n >= 0:
+			return n
+		}
+	}
+
+	return n
+}
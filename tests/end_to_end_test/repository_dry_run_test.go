@@ -0,0 +1,81 @@
+package endtoend_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryDryRun(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir, "--dry-run")
+
+	sl := e.RunAndExpectSuccess(t, "repo", "status")
+	verifyHasLine(t, sl, func(l string) bool {
+		return strings.Contains(l, "Dry-run:") && strings.Contains(l, "true")
+	})
+
+	source := testutil.TempDirectory(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "file.txt"), []byte("hello"), 0o600))
+
+	blobsBefore := listRepoFiles(t, e.RepoDir)
+
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "create", source)
+	verifyHasLine(t, stderr, func(l string) bool {
+		return strings.Contains(l, "DRY RUN: would write blob")
+	})
+
+	// storage was not modified and the snapshot is not visible to subsequent commands.
+	require.Equal(t, blobsBefore, listRepoFiles(t, e.RepoDir))
+	require.Empty(t, e.RunAndExpectSuccess(t, "snapshot", "list", source))
+
+	e.RunAndExpectSuccess(t, "repo", "set-client", "--no-dry-run")
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	require.NotEqual(t, blobsBefore, listRepoFiles(t, e.RepoDir))
+	require.NotEmpty(t, e.RunAndExpectSuccess(t, "snapshot", "list", source))
+}
+
+// listRepoFiles returns sorted relative paths of all files in the provided directory tree.
+func listRepoFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var result []string
+
+	require.NoError(t, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+
+			result = append(result, rel)
+		}
+
+		return nil
+	}))
+
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,53 @@
+package endtoend_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryExportApplyBlobs(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	// initialize offline replica and record its state in the checkpoint.
+	replicaDir := testutil.TempDirectory(t)
+	checkpointFile := filepath.Join(testutil.TempDirectory(t), "checkpoint.json")
+	archiveFile := filepath.Join(testutil.TempDirectory(t), "blobs.kopia-archive")
+
+	e.RunAndExpectSuccess(t, "repo", "sync-to", "filesystem", "--path", replicaDir)
+	e.RunAndExpectSuccess(t, "repo", "export-blobs", "--checkpoint", checkpointFile, "--checkpoint-only")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir2)
+	e.RunAndExpectSuccess(t, "repo", "export-blobs", "--checkpoint", checkpointFile, "--output", archiveFile, "--archive-password", "archive-pass")
+
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", replicaDir)
+	require.Len(t, clitestutil.ListSnapshotsAndExpectSuccess(t, e), 1)
+
+	e.RunAndExpectFailure(t, "repo", "apply-blobs", archiveFile, "--archive-password", "wrong-pass")
+	e.RunAndExpectSuccess(t, "repo", "apply-blobs", archiveFile, "--archive-password", "archive-pass")
+
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", replicaDir)
+	require.Len(t, clitestutil.ListSnapshotsAndExpectSuccess(t, e), 2)
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+
+	// archives can't be applied to a different repository.
+	e2 := testenv.NewCLITest(t, runner)
+
+	defer e2.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e2.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e2.RepoDir)
+	e2.RunAndExpectFailure(t, "repo", "apply-blobs", archiveFile, "--archive-password", "archive-pass")
+}
@@ -0,0 +1,46 @@
+package endtoend_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryFreeze(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	e.RunAndExpectFailure(t, "repo", "unfreeze")
+	e.RunAndExpectSuccess(t, "repo", "freeze", "--reason", "storage migration")
+	e.RunAndExpectFailure(t, "repo", "freeze")
+
+	if !containsLine(e.RunAndExpectSuccess(t, "repo", "status"), "storage migration") {
+		t.Errorf("freeze reason not reported in status")
+	}
+
+	// reads still work, writes and maintenance are blocked.
+	e.RunAndExpectSuccess(t, "snapshot", "list")
+	e.RunAndExpectFailure(t, "snapshot", "create", sharedTestDataDir2)
+	e.RunAndExpectFailure(t, "maintenance", "run", "--full")
+
+	e.RunAndExpectSuccess(t, "repo", "unfreeze")
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir2)
+}
+
+func containsLine(lines []string, substr string) bool {
+	for _, l := range lines {
+		if strings.Contains(l, substr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,39 @@
+package endtoend_test
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryLabels(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--label", "env=prod", "--label", "owner=team-a")
+
+	status := e.RunAndExpectSuccess(t, "repo", "status")
+	if !containsLine(status, "env=prod") || !containsLine(status, "owner=team-a") {
+		t.Errorf("labels not reported in status: %v", status)
+	}
+
+	e.RunAndExpectSuccess(t, "repo", "set-parameters", "--label", "env=dev", "--remove-label", "owner")
+	e.RunAndExpectFailure(t, "repo", "set-parameters", "--label", "env=dev")
+
+	status = e.RunAndExpectSuccess(t, "repo", "status")
+	if !containsLine(status, "env=dev") || containsLine(status, "owner=") {
+		t.Errorf("labels not updated: %v", status)
+	}
+
+	// labels are visible to newly-connected clients.
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir)
+
+	if !containsLine(e.RunAndExpectSuccess(t, "repo", "status"), "env=dev") {
+		t.Errorf("labels not visible after reconnecting")
+	}
+}
@@ -0,0 +1,50 @@
+package endtoend_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryReadReplica(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	replicaDir := testutil.TempDirectory(t)
+
+	// only copies of the repository can be used as replicas.
+	e.RunAndExpectFailure(t, "repo", "read-replica", "add", "filesystem", "--path", replicaDir)
+
+	e.RunAndExpectSuccess(t, "repo", "sync-to", "filesystem", "--path", replicaDir)
+	e.RunAndExpectSuccess(t, "repo", "read-replica", "add", "filesystem", "--path", replicaDir)
+
+	// make primary storage unavailable by replacing it with a file.
+	require.NoError(t, os.Rename(e.RepoDir, e.RepoDir+".moved"))
+	require.NoError(t, ioutil.WriteFile(e.RepoDir, nil, 0o600))
+
+	sources := clitestutil.ListSnapshotsAndExpectSuccess(t, e)
+	require.Len(t, sources, 1)
+	require.Len(t, sources[0].Snapshots, 1)
+
+	restoreDir := filepath.Join(testutil.TempDirectory(t), "restored")
+	e.RunAndExpectSuccess(t, "snapshot", "restore", sources[0].Snapshots[0].SnapshotID, restoreDir)
+
+	e.RunAndExpectSuccess(t, "repo", "read-replica", "clear")
+
+	require.NoError(t, os.Remove(e.RepoDir))
+	require.NoError(t, os.Rename(e.RepoDir+".moved", e.RepoDir))
+}
@@ -0,0 +1,52 @@
+package endtoend_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryStats(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	if !containsLine(e.RunAndExpectSuccess(t, "repo", "stats"), "Dedup ratio:") {
+		t.Errorf("dedup ratio not reported")
+	}
+
+	var s repo.Stats
+
+	if err := json.Unmarshal([]byte(strings.Join(e.RunAndExpectSuccess(t, "repo", "stats", "--json"), "\n")), &s); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+
+	if s.LogicalBytes == 0 || s.Sum().Count == 0 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	e.RunAndExpectSuccess(t, "maintenance", "run", "--full")
+
+	var s2 repo.Stats
+
+	if err := json.Unmarshal([]byte(strings.Join(e.RunAndExpectSuccess(t, "repo", "stats", "--json"), "\n")), &s2); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+
+	if s2.RecomputedAt.IsZero() {
+		t.Errorf("stats were not recomputed during full maintenance")
+	}
+
+	if s2.LogicalBytes != s.LogicalBytes {
+		t.Errorf("logical bytes changed after recompute: %v, want %v", s2.LogicalBytes, s.LogicalBytes)
+	}
+}
@@ -0,0 +1,27 @@
+package endtoend_test
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryValidateProvider(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	blobsBefore := e.RunAndExpectSuccess(t, "blob", "list")
+
+	e.RunAndExpectSuccess(t, "repo", "validate-provider")
+	e.RunAndExpectSuccess(t, "repo", "validate-provider", "--stress", "--stress-workers=4", "--stress-duration=1s", "--stress-blob-size=1000")
+
+	if blobsAfter := e.RunAndExpectSuccess(t, "blob", "list"); len(blobsAfter) != len(blobsBefore) {
+		t.Errorf("unexpected blobs left after validation: %v, before %v", blobsAfter, blobsBefore)
+	}
+}
@@ -0,0 +1,83 @@
+package endtoend_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRestoreCaseCollisions(t *testing.T) {
+	t.Parallel()
+
+	source := testutil.TempDirectory(t)
+
+	if restore.IsCaseInsensitiveFilesystem(source) {
+		t.Skip("test requires case-sensitive filesystem")
+	}
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "README.txt"), []byte{1}, 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "readme.txt"), []byte{1, 2}, 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "other.txt"), []byte{1, 2, 3}, 0o600))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e, source)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	snapID := si[0].Snapshots[0].SnapshotID
+
+	// verify reports collisions without failing.
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "verify", "--report-case-collisions")
+	require.Contains(t, strings.Join(stderr, "\n"), "case collision in")
+	require.Contains(t, strings.Join(stderr, "\n"), "Found 1 case collisions.")
+
+	// default restore to case-sensitive filesystem keeps original names.
+	restoreDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, restoreDir)
+	require.Equal(t, []string{"README.txt", "other.txt", "readme.txt"}, listFileNames(t, restoreDir))
+
+	restoreDir = testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", "--case-collisions=rename", snapID, restoreDir)
+	require.Equal(t, []string{"README.txt", "other.txt", "readme (2).txt"}, listFileNames(t, restoreDir))
+
+	restoreDir = testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", "--case-collisions=skip", snapID, restoreDir)
+	require.Equal(t, []string{"README.txt", "other.txt"}, listFileNames(t, restoreDir))
+
+	restoreDir = testutil.TempDirectory(t)
+	e.RunAndExpectFailure(t, "snapshot", "restore", "--case-collisions=fail", snapID, restoreDir)
+}
+
+func listFileNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+
+	var names []string
+
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -7,6 +7,7 @@
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -559,3 +560,49 @@ func verifyValidTarGzipFile(t *testing.T, fname string) {
 
 	verifyValidTarReader(t, tar.NewReader(gz))
 }
+
+func TestRestorePreflight(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "f1"), []byte{1, 2, 3}, 0o600))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e, source)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	snapID := si[0].Snapshots[0].SnapshotID
+	restoreDir := filepath.Join(testutil.TempDirectory(t), "out")
+
+	// preflight alone does not write anything.
+	e.RunAndExpectSuccess(t, "snapshot", "restore", "--preflight-only", snapID, restoreDir)
+
+	_, err := os.Stat(restoreDir)
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, os.MkdirAll(restoreDir, 0o700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(restoreDir, "f1"), []byte{5}, 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(restoreDir, "f2"), []byte{5}, 0o600))
+
+	// issues are found before restoring anything.
+	e.RunAndExpectFailure(t, "snapshot", "restore", "--no-overwrite-files", snapID, restoreDir)
+
+	got, err := ioutil.ReadFile(filepath.Join(restoreDir, "f1"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{5}, got)
+
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, restoreDir)
+
+	got, err = ioutil.ReadFile(filepath.Join(restoreDir, "f1"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, got)
+}
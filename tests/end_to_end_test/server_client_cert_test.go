@@ -0,0 +1,137 @@
+package endtoend_test
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/tlsutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+const testCertKeySize = 2048
+
+func TestServerStartWithClientCertificates(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	// in-process runner always passes the test repository password.
+	e.RunAndExpectSuccess(t, "server", "users", "add", "foo@bar", "--user-password", testenv.TestRepoPassword)
+	e.RunAndExpectSuccess(t, "server", "users", "add", "other@bar", "--user-password", testenv.TestRepoPassword)
+
+	ca, caKey, err := tlsutil.GenerateCertificateAuthority(ctx, testCertKeySize, time.Hour)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(e.ConfigDir, "client-ca.cert")
+	require.NoError(t, tlsutil.WriteCertificateToFile(caFile, ca))
+
+	fooCert, fooKey := writeClientCertificate(ctx, t, e.ConfigDir, "foo@bar", ca, caKey)
+
+	// certificate issued by a different CA.
+	otherCA, otherCAKey, err := tlsutil.GenerateCertificateAuthority(ctx, testCertKeySize, time.Hour)
+	require.NoError(t, err)
+
+	untrustedCert, untrustedKey := writeClientCertificate(ctx, t, e.ConfigDir, "untrusted", otherCA, otherCAKey)
+
+	var sp serverParameters
+
+	e.RunAndProcessStderr(t, sp.ProcessOutput,
+		"server", "start",
+		"--address=localhost:0",
+		"--tls-generate-cert",
+		"--tls-generate-rsa-key-size=2048", // use shorter key size to speed up generation
+		"--tls-client-ca-file", caFile,
+		"--server-username", uiUsername,
+		"--server-password", uiPassword,
+	)
+	t.Logf("detected server parameters %#v", sp)
+
+	// UI user does not need client certificate.
+	uiUserCLI, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             sp.baseURL,
+		Username:                            uiUsername,
+		Password:                            uiPassword,
+		TrustedServerCertificateFingerprint: sp.sha256Fingerprint,
+	})
+	require.NoError(t, err)
+
+	defer serverapi.Shutdown(ctx, uiUserCLI)
+
+	waitUntilServerStarted(ctx, t, uiUserCLI)
+
+	openAs := func(username, certFile, keyFile string, useGRPC bool) error {
+		rep, err := repo.OpenAPIServer(ctx, &repo.APIServerInfo{
+			BaseURL:                             sp.baseURL,
+			TrustedServerCertificateFingerprint: sp.sha256Fingerprint,
+			DisableGRPC:                         !useGRPC,
+			ClientCertificateFile:               certFile,
+			ClientKeyFile:                       keyFile,
+		}, repo.ClientOptions{
+			Username: username,
+			Hostname: "bar",
+		}, nil, testenv.TestRepoPassword)
+		if err != nil {
+			return err
+		}
+
+		return rep.Close(ctx)
+	}
+
+	for _, useGRPC := range []bool{true, false} {
+		require.NoError(t, openAs("foo", fooCert, fooKey, useGRPC))
+
+		// no certificate.
+		require.Error(t, openAs("foo", "", "", useGRPC))
+
+		// certificate issued to a different user.
+		require.Error(t, openAs("other", fooCert, fooKey, useGRPC))
+
+		// certificate issued by untrusted CA.
+		require.Error(t, openAs("foo", untrustedCert, untrustedKey, useGRPC))
+	}
+
+	e2 := testenv.NewCLITest(t, runner)
+
+	defer e2.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	connectArgs := []string{
+		"repo", "connect", "server",
+		"--url", sp.baseURL,
+		"--server-cert-fingerprint", sp.sha256Fingerprint,
+		"--override-username", "foo",
+		"--override-hostname", "bar",
+	}
+
+	e2.RunAndExpectFailure(t, append(connectArgs, "--client-cert-file", fooCert)...)
+	e2.RunAndExpectSuccess(t, append(connectArgs, "--client-cert-file", fooCert, "--client-key-file", fooKey)...)
+	e2.RunAndExpectSuccess(t, "snapshot", "list")
+}
+
+func writeClientCertificate(ctx context.Context, t *testing.T, dir, commonName string, ca *x509.Certificate, caKey *rsa.PrivateKey) (certFile, keyFile string) {
+	t.Helper()
+
+	cert, key, err := tlsutil.GenerateClientCertificate(ctx, testCertKeySize, time.Hour, commonName, ca, caKey)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, commonName+"-"+ca.SerialNumber.String()+".cert")
+	keyFile = filepath.Join(dir, commonName+"-"+ca.SerialNumber.String()+".key")
+
+	require.NoError(t, tlsutil.WriteCertificateToFile(certFile, cert))
+	require.NoError(t, tlsutil.WritePrivateKeyToFile(keyFile, key))
+
+	return certFile, keyFile
+}
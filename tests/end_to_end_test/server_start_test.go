@@ -137,6 +137,24 @@ func TestServerStart(t *testing.T) {
 
 	snaps := verifySnapshotCount(t, cli, &snapshot.SourceInfo{Host: "fake-hostname", UserName: "fake-username", Path: sharedTestDataDir2}, 1)
 
+	// the result of the run is persisted by the server shortly after the snapshot completes.
+	var runs *serverapi.SourceRunsResponse
+
+	for i := 0; i < 100; i++ {
+		runs, err = serverapi.SourceRuns(ctx, cli, snapshot.SourceInfo{Host: "fake-hostname", UserName: "fake-username", Path: sharedTestDataDir2}, 10)
+		require.NoError(t, err)
+
+		if len(runs.Runs) > 0 {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	require.Len(t, runs.Runs, 1)
+	require.Equal(t, snaps[0].ID, runs.Runs[0].SnapshotID)
+	require.Empty(t, runs.Runs[0].Error)
+
 	rootPayload, err := serverapi.GetObject(ctx, cli, snaps[0].RootEntry)
 	require.NoError(t, err)
 
@@ -1,6 +1,7 @@
 package endtoend_test
 
 import (
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -12,6 +13,7 @@
 
 	"github.com/kylelemons/godebug/pretty"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
 
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo"
@@ -129,6 +131,47 @@ func TestTaggingBadTags(t *testing.T) {
 	}
 }
 
+func TestSnapshotCreateMultipleSourcesConcurrently(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1, sharedTestDataDir2, sharedTestDataDir3, "--parallel-sources=2", "--parallel=4")
+
+	var manifests []snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "list", "-a", "--json"), &manifests)
+	require.Len(t, manifests, 3)
+
+	// failure of one source does not prevent snapshotting the others.
+	e.RunAndExpectFailure(t, "snapshot", "create", sharedTestDataDir1, filepath.Join(testutil.TempDirectory(t), "notExist"), "--parallel-sources=2")
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "list", "-a", "--json"), &manifests)
+	require.Len(t, manifests, 4)
+}
+
+func TestSnapshotCreateCheckConsistency(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "create", sharedTestDataDir1, "--check-consistency", "--check-consistency-files-percent=100")
+	require.Contains(t, strings.Join(stderr, "\n"), "Checked ")
+
+	// nothing changed, so the second snapshot has no new data to check.
+	_, stderr = e.RunAndExpectSuccessWithErrOut(t, "snapshot", "create", sharedTestDataDir1, "--check-consistency")
+	require.Contains(t, strings.Join(stderr, "\n"), "Checked 0 new directories and 0 new files")
+}
+
 func TestStartTimeOverride(t *testing.T) {
 	t.Parallel()
 
@@ -622,6 +665,53 @@ func TestSnapshotCreateWithStdinStream(t *testing.T) {
 	}
 }
 
+func TestSnapshotCreateWithMultipleStreams(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewExeRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	stdinContent := []byte("first database dump")
+	fileContent := []byte("second database dump")
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	_, err = w.Write(stdinContent)
+	require.NoError(t, err)
+	w.Close()
+
+	dumpFile := filepath.Join(testutil.TempDirectory(t), "dump")
+	require.NoError(t, ioutil.WriteFile(dumpFile, fileContent, 0o600))
+
+	runner.NextCommandStdin = r
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", "rootdir", "--stream", "db1.sql=-", "--stream", "db2.sql="+dumpFile)
+
+	// stream names must be unique.
+	e.RunAndExpectFailure(t, "snapshot", "create", "rootdir", "--stream", "db1.sql="+dumpFile, "--stream", "db1.sql="+dumpFile)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	rootID := si[0].Snapshots[0].ObjectID
+	restoreDir := testutil.TempDirectory(t)
+
+	e.RunAndExpectSuccess(t, "snapshot", "restore", rootID, restoreDir)
+
+	got, err := ioutil.ReadFile(filepath.Join(restoreDir, "db1.sql"))
+	require.NoError(t, err)
+	require.Equal(t, stdinContent, got)
+
+	got, err = ioutil.ReadFile(filepath.Join(restoreDir, "db2.sql"))
+	require.NoError(t, err)
+	require.Equal(t, fileContent, got)
+}
+
 func appendIfMissing(slice []string, i string) []string {
 	for _, ele := range slice {
 		if ele == i {
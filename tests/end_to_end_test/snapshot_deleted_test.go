@@ -0,0 +1,53 @@
+package endtoend_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotDeleted(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	srcDir := testutil.TempDirectory(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "sub"), 0o700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(srcDir, "keep.txt"), []byte("keep"), 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(srcDir, "sub", "gone.txt"), []byte("gone"), 0o600))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", srcDir)
+
+	// nothing deleted yet.
+	var entries []*snapshotfs.DeletedEntry
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "deleted", srcDir, "--json"), &entries)
+	require.Empty(t, entries)
+
+	require.NoError(t, os.Remove(filepath.Join(srcDir, "sub", "gone.txt")))
+	e.RunAndExpectSuccess(t, "snapshot", "create", srcDir)
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "deleted", srcDir, "--json"), &entries)
+	require.Len(t, entries, 1)
+	require.Equal(t, "sub/gone.txt", entries[0].Path)
+
+	// restore the deleted file using the provided shortcut.
+	restoredFile := filepath.Join(testutil.TempDirectory(t), "restored.txt")
+	e.RunAndExpectSuccess(t, "restore", entries[0].RestoreRoot, restoredFile)
+
+	b, err := ioutil.ReadFile(restoredFile)
+	require.NoError(t, err)
+	require.Equal(t, "gone", string(b))
+}
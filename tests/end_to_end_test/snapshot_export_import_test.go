@@ -0,0 +1,45 @@
+package endtoend_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotExportImport(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir2)
+
+	archiveFile := filepath.Join(t.TempDir(), "snapshots.kopia-archive")
+
+	e.RunAndExpectFailure(t, "snapshot", "export", "--output", archiveFile, "--archive-password", "archive-pass")
+	e.RunAndExpectSuccess(t, "snapshot", "export", "--output", archiveFile, "--archive-password", "archive-pass", "--all")
+
+	sourceSnapshotCount := len(e.RunAndExpectSuccess(t, "snapshot", "list", "-a"))
+
+	dstenv := testenv.NewCLITest(t, runner)
+
+	defer dstenv.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	dstenv.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", dstenv.RepoDir)
+	dstenv.RunAndExpectFailure(t, "snapshot", "import", archiveFile, "--archive-password", "wrong-pass")
+	dstenv.RunAndExpectSuccess(t, "snapshot", "import", archiveFile, "--archive-password", "archive-pass")
+	dstenv.RunAndVerifyOutputLineCount(t, sourceSnapshotCount, "snapshot", "list", "-a")
+
+	// import again, which should be a no-op.
+	dstenv.RunAndExpectSuccess(t, "snapshot", "import", archiveFile, "--archive-password", "archive-pass")
+	dstenv.RunAndVerifyOutputLineCount(t, sourceSnapshotCount, "snapshot", "list", "-a")
+
+	// verify that imported data is fully readable.
+	dstenv.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+}
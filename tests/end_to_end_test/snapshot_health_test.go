@@ -0,0 +1,39 @@
+package endtoend_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/snapshothealth"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotHealth(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	var report snapshothealth.Report
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "health", "--json", "--fail-on=yellow"), &report)
+	require.Equal(t, snapshothealth.StatusGreen, report.Status)
+	require.Len(t, report.Sources, 1)
+
+	// source with policy but no snapshots yet.
+	e.RunAndExpectSuccess(t, "policy", "set", sharedTestDataDir2, "--snapshot-interval=1h")
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "health", "--json"), &report)
+	require.Equal(t, snapshothealth.StatusYellow, report.Status)
+	require.Len(t, report.Sources, 2)
+
+	e.RunAndExpectSuccess(t, "snapshot", "health", "--fail-on=red")
+	e.RunAndExpectFailure(t, "snapshot", "health", "--fail-on=yellow")
+}
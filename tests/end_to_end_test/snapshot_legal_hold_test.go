@@ -0,0 +1,57 @@
+package endtoend_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotLegalHold(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	sources := clitestutil.ListSnapshotsAndExpectSuccess(t, e)
+	require.Len(t, sources, 1)
+	require.Len(t, sources[0].Snapshots, 1)
+
+	snapID := sources[0].Snapshots[0].SnapshotID
+
+	e.RunAndExpectSuccess(t, "snapshot", "legal-hold", "add", snapID, "--reason", "litigation")
+	e.RunAndExpectFailure(t, "snapshot", "legal-hold", "add", "no-such-snapshot")
+
+	var holds []struct {
+		ID string `json:"id"`
+		snapshot.LegalHold
+	}
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "legal-hold", "list", "--json"), &holds)
+	require.Len(t, holds, 1)
+	require.Equal(t, snapID, string(holds[0].SnapshotID))
+	require.Equal(t, "litigation", holds[0].Reason)
+
+	holdID := holds[0].ID
+	require.NotEmpty(t, holdID)
+
+	e.RunAndExpectFailure(t, "snapshot", "delete", snapID, "--delete")
+
+	// dry run does not remove the hold.
+	e.RunAndExpectSuccess(t, "snapshot", "legal-hold", "remove", holdID)
+	e.RunAndExpectFailure(t, "snapshot", "delete", snapID, "--delete")
+
+	e.RunAndExpectSuccess(t, "snapshot", "legal-hold", "remove", holdID, "--delete")
+	require.Empty(t, e.RunAndExpectSuccess(t, "snapshot", "legal-hold", "list"))
+
+	e.RunAndExpectSuccess(t, "snapshot", "delete", snapID, "--delete")
+}
@@ -0,0 +1,61 @@
+package endtoend_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotCreateJSONProgress(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "file1.txt"), []byte("hello"), 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "file2.txt"), []byte("world"), 0o600))
+
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "create", source, "--progress-format=json")
+
+	var last struct {
+		Finished    bool  `json:"finished"`
+		HashedFiles int32 `json:"hashedFiles"`
+		HashedBytes int64 `json:"hashedBytes"`
+	}
+
+	records := 0
+
+	for _, l := range stderr {
+		if !strings.HasPrefix(l, "{") {
+			continue
+		}
+
+		require.NoError(t, json.Unmarshal([]byte(l), &last), "invalid progress record: %v", l)
+
+		records++
+	}
+
+	require.Greater(t, records, 0, "no progress records in %v", stderr)
+	require.True(t, last.Finished)
+	require.Equal(t, int32(2), last.HashedFiles)
+	require.Equal(t, int64(10), last.HashedBytes)
+
+	// no ANSI progress bar is emitted.
+	for _, l := range stderr {
+		require.NotContains(t, l, "\r")
+	}
+
+	e.RunAndExpectFailure(t, "snapshot", "create", source, "--progress-format=invalid")
+}
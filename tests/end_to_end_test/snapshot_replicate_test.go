@@ -0,0 +1,41 @@
+package endtoend_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotReplicate(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir2)
+
+	sourceConfig := filepath.Join(e.ConfigDir, ".kopia.config")
+
+	dstenv := testenv.NewCLITest(t, runner)
+
+	dstenv.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", dstenv.RepoDir)
+	dstenv.RunAndExpectSuccess(t, "snapshot", "replicate", "--source-config", sourceConfig)
+	dstenv.RunAndVerifyOutputLineCount(t, len(e.RunAndExpectSuccess(t, "snapshot", "list", "-a")), "snapshot", "list", "-a")
+	dstenv.RunAndVerifyOutputLineCount(t, 2, "snapshot", "replication-status")
+
+	// only new snapshots are replicated.
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir3)
+	dstenv.RunAndExpectSuccess(t, "snapshot", "replicate", "--source-config", sourceConfig, sharedTestDataDir3)
+	dstenv.RunAndVerifyOutputLineCount(t, len(e.RunAndExpectSuccess(t, "snapshot", "list", "-a")), "snapshot", "list", "-a")
+	dstenv.RunAndVerifyOutputLineCount(t, 3, "snapshot", "replication-status")
+
+	// replicating again is a no-op.
+	dstenv.RunAndExpectSuccess(t, "snapshot", "replicate", "--source-config", sourceConfig)
+	dstenv.RunAndVerifyOutputLineCount(t, len(e.RunAndExpectSuccess(t, "snapshot", "list", "-a")), "snapshot", "list", "-a")
+	dstenv.RunAndVerifyOutputLineCount(t, 3, "snapshot", "replication-status")
+}
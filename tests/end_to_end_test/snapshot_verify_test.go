@@ -1,9 +1,14 @@
 package endtoend_test
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/tests/testenv"
 )
 
@@ -30,3 +35,31 @@ func TestSnapshotVerifyTest(t *testing.T) {
 
 	e.RunAndExpectFailure(t, "snap", "verify")
 }
+
+func TestSnapshotVerifyWithBudget(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "a.txt"), []byte{1}, 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "b.txt"), []byte{1, 2}, 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(source, "c.txt"), []byte{1, 2, 3}, 0o600))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	// each verification reads a single file and the next one resumes after it.
+	for i := 0; i < 3; i++ {
+		_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "verify", "--max-read-bytes=1", "--parallel=1")
+		require.Contains(t, strings.Join(stderr, "\n"), "Read 1 out of 3 files")
+	}
+
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "verify", "--max-duration=1h")
+	require.Contains(t, strings.Join(stderr, "\n"), "Read all 3 files")
+}
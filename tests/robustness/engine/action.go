@@ -18,11 +18,18 @@
 
 // ExecAction executes the action denoted by the provided ActionKey.
 func (e *Engine) ExecAction(ctx context.Context, actionKey ActionKey, opts map[string]string) (map[string]string, error) {
+	return e.execAction(ctx, actionKey, opts, e.nextStepSeed())
+}
+
+// execAction executes the action after seeding the FileWriter with the
+// provided seed, and records it if scenario recording is enabled.
+func (e *Engine) execAction(ctx context.Context, actionKey ActionKey, opts map[string]string, seed int64) (map[string]string, error) {
 	if opts == nil {
 		opts = make(map[string]string)
 	}
 
 	e.statsIncrActionCountAndLog(actionKey)
+	e.seedFileWriter(seed)
 
 	action := actions[actionKey]
 	st := clock.Now()
@@ -48,6 +55,8 @@ func (e *Engine) ExecAction(ctx context.Context, actionKey ActionKey, opts map[s
 		}
 	}
 
+	e.recordStep(actionKey, opts, seed, logEntry, out, err)
+
 	// If error was just a no-op, don't bother logging the action
 	switch {
 	case errors.Is(err, robustness.ErrNoOp):
@@ -94,6 +103,10 @@ func (e *Engine) checkErrRecovery(ctx context.Context, incomingErr error, action
 	if errIsNotEnoughSpace(incomingErr) && ctrl[ThrowNoSpaceOnDeviceErrField] == "" {
 		// no space left on device
 		// Delete everything in the data directory
+		seed := e.nextStepSeed()
+		e.seedFileWriter(seed)
+		e.recordPurgeDataDir(seed)
+
 		outgoingErr = e.FileWriter.DeleteEverything(ctx)
 		if outgoingErr != nil {
 			return outgoingErr
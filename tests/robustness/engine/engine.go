@@ -102,6 +102,8 @@ type Engine struct {
 
 	EngineLog Log
 	logMux    sync.RWMutex
+
+	recorder *scenarioRecorder
 }
 
 // Shutdown makes a last snapshot then flushes the metadata and prints the final statistics.
@@ -30,6 +30,7 @@
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/tests/robustness"
 	"github.com/kopia/kopia/tests/robustness/fiofilewriter"
+	"github.com/kopia/kopia/tests/robustness/gofilewriter"
 	"github.com/kopia/kopia/tests/robustness/snapmeta"
 	"github.com/kopia/kopia/tests/tools/fio"
 	"github.com/kopia/kopia/tests/tools/fswalker"
@@ -827,8 +828,116 @@ func TestLogsPersist(t *testing.T) {
 	}
 }
 
+func TestScenarioRecordReplay(t *testing.T) {
+	os.Setenv(snapmeta.EngineModeEnvKey, snapmeta.EngineModeBasic)
+	os.Setenv(snapmeta.S3BucketNameEnvKey, "")
+
+	ctx := testlogging.Context(t)
+
+	defer os.RemoveAll(fsRepoBaseDirPath)
+
+	scenarioFile := filepath.Join(testutil.TempDirectory(t), "scenario.jsonl")
+
+	actionOpts := ActionOpts{
+		ActionControlActionKey: map[string]string{
+			string(SnapshotDirActionKey):              "2",
+			string(RestoreSnapshotActionKey):          "1",
+			string(DeleteRandomSnapshotActionKey):     "1",
+			string(WriteRandomFilesActionKey):         "4",
+			string(DeleteRandomSubdirectoryActionKey): "1",
+			string(DeleteDirectoryContentsActionKey):  "1",
+		},
+		WriteRandomFilesActionKey: map[string]string{
+			gofilewriter.MaxDirDepthField:         "3",
+			gofilewriter.MaxFileSizeField:         strconv.Itoa(64 * 1024),
+			gofilewriter.MinFileSizeField:         "1",
+			gofilewriter.MaxNumFilesPerWriteField: "10",
+			gofilewriter.ChurnPercentField:        "20",
+		},
+	}
+
+	// runEngine runs f with a new engine using fresh repositories and returns
+	// the contents of the data directory and the number of live snapshots.
+	runEngine := func(name string, f func(eng *Engine)) (map[string]string, int) {
+		fw, err := gofilewriter.New()
+		require.NoError(t, err)
+
+		th, eng, err := newTestHarnessWithFileWriter(ctx, t,
+			filepath.Join(fsRepoBaseDirPath, name, dataRepoPath),
+			filepath.Join(fsRepoBaseDirPath, name, metadataRepoPath),
+			fw)
+		if errors.Is(err, kopiarunner.ErrExeVariableNotSet) {
+			t.Skip(err)
+		}
+
+		require.NoError(t, err)
+
+		defer func() {
+			require.NoError(t, th.Cleanup(ctx))
+		}()
+
+		require.NoError(t, eng.Init(ctx))
+
+		f(eng)
+
+		contents := map[string]string{}
+
+		require.NoError(t, filepath.Walk(fw.DataDirectory(ctx), func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+
+			rel, err := filepath.Rel(fw.DataDirectory(ctx), path)
+			if err != nil {
+				return err
+			}
+
+			b, err := ioutil.ReadFile(path)
+			contents[rel] = hex.EncodeToString(b)
+
+			return err
+		}))
+
+		return contents, len(eng.Checker.GetLiveSnapIDs())
+	}
+
+	const numActions = 20
+
+	var steps []ScenarioStep
+
+	recordedContents, recordedSnapCount := runEngine("record", func(eng *Engine) {
+		require.NoError(t, eng.RecordScenario(scenarioFile))
+
+		for i := 0; i < numActions; i++ {
+			err := eng.RandomAction(ctx, actionOpts)
+			if !errors.Is(err, robustness.ErrNoOp) {
+				require.NoError(t, err)
+			}
+		}
+
+		// load steps before shutdown, which may record a final snapshot
+		var err error
+
+		steps, err = LoadScenario(scenarioFile)
+		require.NoError(t, err)
+		require.Len(t, steps, numActions)
+	})
+
+	replayedContents, replayedSnapCount := runEngine("replay", func(eng *Engine) {
+		require.NoError(t, eng.ReplayScenario(ctx, steps))
+	})
+
+	require.Equal(t, recordedSnapCount, replayedSnapCount)
+	require.Equal(t, recordedContents, replayedContents)
+}
+
+type testFileWriter interface {
+	robustness.FileWriter
+	Cleanup()
+}
+
 type testHarness struct {
-	fw *fiofilewriter.FileWriter
+	fw testFileWriter
 	ks *snapmeta.KopiaSnapshotter
 	kp *snapmeta.KopiaPersister
 
@@ -840,16 +949,23 @@ type testHarness struct {
 func newTestHarness(ctx context.Context, t *testing.T, dataRepoPath, metaRepoPath string) (*testHarness, *Engine, error) {
 	t.Helper()
 
+	fw, err := fiofilewriter.New()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return newTestHarnessWithFileWriter(ctx, t, dataRepoPath, metaRepoPath, fw)
+}
+
+func newTestHarnessWithFileWriter(ctx context.Context, t *testing.T, dataRepoPath, metaRepoPath string, fw testFileWriter) (*testHarness, *Engine, error) {
+	t.Helper()
+
 	var (
-		th  = &testHarness{}
+		th  = &testHarness{fw: fw}
 		err error
 	)
 
 	if th.baseDir, err = ioutil.TempDir("", "engine-data-"); err != nil {
-		return nil, nil, err
-	}
-
-	if th.fw, err = fiofilewriter.New(); err != nil {
 		th.Cleanup(ctx)
 		return nil, nil, err
 	}
@@ -892,7 +1008,7 @@ func (th *testHarness) args() *Args {
 }
 
 func (th *testHarness) FioRunner() *fio.Runner {
-	return th.fw.Runner
+	return th.fw.(*fiofilewriter.FileWriter).Runner
 }
 
 func (th *testHarness) Cleanup(ctx context.Context) error {
@@ -0,0 +1,218 @@
+// +build darwin,amd64 linux,amd64
+
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+
+	"github.com/kopia/kopia/tests/robustness"
+)
+
+// purgeDataDirStepKey denotes a scenario step that deletes all the contents
+// of the data directory during error recovery.
+const purgeDataDirStepKey ActionKey = "purge-data-dir"
+
+// ScenarioStep is a single action recorded in a scenario, with the parameters
+// needed to re-execute it exactly.
+type ScenarioStep struct {
+	Action ActionKey         `json:"action"`
+	Opts   map[string]string `json:"opts,omitempty"`
+
+	// Seed is provided to the FileWriter before the action is executed.
+	Seed int64 `json:"seed"`
+
+	// SnapID is the ID of the snapshot created by a snapshot action.
+	// It is used to map snapshot IDs referenced by subsequent steps to
+	// the IDs of snapshots created during replay.
+	SnapID string `json:"snapID,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// seedableFileWriter is implemented by FileWriters whose random choices
+// and generated data can be reproduced given a seed.
+type seedableFileWriter interface {
+	Seed(seed int64)
+}
+
+// scenarioRecorder appends scenario steps to a file, one JSON object per line.
+type scenarioRecorder struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func (r *scenarioRecorder) record(step *ScenarioStep) {
+	b, err := json.Marshal(step)
+	if err != nil {
+		log.Printf("Error marshaling scenario step: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.f.Write(append(b, '\n')); err != nil {
+		log.Printf("Error recording scenario step: %v", err)
+	}
+}
+
+func (r *scenarioRecorder) close() {
+	if err := r.f.Close(); err != nil {
+		log.Printf("Error closing scenario file: %v", err)
+	}
+}
+
+// RecordScenario makes the engine record all subsequently executed actions
+// in the provided file, so they can be re-executed with ReplayScenario.
+// Scenarios recorded while actions execute concurrently, such as with
+// multiple clients, are not guaranteed to replay deterministically.
+func (e *Engine) RecordScenario(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	e.recorder = &scenarioRecorder{f: f}
+	e.cleanupRoutines = append(e.cleanupRoutines, e.recorder.close)
+
+	if _, ok := e.FileWriter.(seedableFileWriter); !ok {
+		log.Printf("Warning: FileWriter does not support seeding, generated data will not be reproducible")
+	}
+
+	return nil
+}
+
+// LoadScenario loads scenario steps recorded with RecordScenario.
+func LoadScenario(path string) ([]ScenarioStep, error) {
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck,gosec
+
+	var steps []ScenarioStep
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20) //nolint:gomnd
+
+	for s.Scan() {
+		var step ScenarioStep
+
+		if err := json.Unmarshal(s.Bytes(), &step); err != nil {
+			return nil, fmt.Errorf("invalid scenario step %d: %w", len(steps), err)
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, s.Err()
+}
+
+// ReplayScenario re-executes the provided scenario steps in order. Replay
+// should start from the same state as the recorded run, typically empty
+// repositories and data directory. It stops at the first step that fails and
+// returns its error. A subset of steps may be replayed to bisect a failure.
+func (e *Engine) ReplayScenario(ctx context.Context, steps []ScenarioStep) error {
+	// maps IDs of recorded snapshots to IDs of snapshots created during replay
+	snapIDs := map[string]string{}
+
+	for i, step := range steps {
+		log.Printf("Replaying scenario step %d/%d: %v", i+1, len(steps), step.Action)
+
+		if step.Action == purgeDataDirStepKey {
+			e.seedFileWriter(step.Seed)
+
+			if err := e.FileWriter.DeleteEverything(ctx); err != nil {
+				return fmt.Errorf("scenario step %d (%v): %w", i, step.Action, err)
+			}
+
+			continue
+		}
+
+		opts := make(map[string]string, len(step.Opts))
+		for k, v := range step.Opts {
+			opts[k] = v
+		}
+
+		if id, ok := snapIDs[opts[SnapshotIDField]]; ok {
+			opts[SnapshotIDField] = id
+		}
+
+		out, err := e.execAction(ctx, step.Action, opts, step.Seed)
+
+		if step.SnapID != "" && out[SnapshotIDField] != "" {
+			snapIDs[step.SnapID] = out[SnapshotIDField]
+		}
+
+		switch {
+		case err != nil && !errors.Is(err, robustness.ErrNoOp):
+			log.Printf("Scenario step %d failed, recorded error: %q", i, step.Error)
+			return fmt.Errorf("scenario step %d (%v): %w", i, step.Action, err)
+
+		case step.Error != "" && step.Error != robustness.ErrNoOp.Error():
+			log.Printf("Scenario step %d succeeded, but recorded error was: %q", i, step.Error)
+		}
+	}
+
+	return nil
+}
+
+// nextStepSeed returns a seed for the next executed action.
+func (e *Engine) nextStepSeed() int64 {
+	return rand.Int63() //nolint:gosec
+}
+
+func (e *Engine) seedFileWriter(seed int64) {
+	if fw, ok := e.FileWriter.(seedableFileWriter); ok {
+		fw.Seed(seed)
+	}
+}
+
+// recordStep records an executed action if scenario recording is enabled.
+func (e *Engine) recordStep(actionKey ActionKey, opts map[string]string, seed int64, logEntry *LogEntry, out map[string]string, err error) {
+	if e.recorder == nil {
+		return
+	}
+
+	step := &ScenarioStep{
+		Action: actionKey,
+		Opts:   make(map[string]string, len(opts)),
+		Seed:   seed,
+	}
+
+	for k, v := range opts {
+		step.Opts[k] = v
+	}
+
+	// record the snapshot that was picked at random, if any
+	if snapID := logEntry.CmdOpts["snapID"]; snapID != "" && actionKey != SnapshotDirActionKey {
+		step.Opts[SnapshotIDField] = snapID
+	}
+
+	if actionKey == SnapshotDirActionKey {
+		step.SnapID = out[SnapshotIDField]
+	}
+
+	if err != nil {
+		step.Error = err.Error()
+	}
+
+	e.recorder.record(step)
+}
+
+// recordPurgeDataDir records deletion of all contents of the data directory.
+func (e *Engine) recordPurgeDataDir(seed int64) {
+	if e.recorder == nil {
+		return
+	}
+
+	e.recorder.record(&ScenarioStep{Action: purgeDataDirStepKey, Seed: seed})
+}
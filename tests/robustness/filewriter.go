@@ -1,5 +1,3 @@
-// +build darwin,amd64 linux,amd64
-
 package robustness
 
 import "context"
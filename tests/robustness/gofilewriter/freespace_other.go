@@ -0,0 +1,11 @@
+// +build !linux,!darwin,!freebsd,!windows
+
+package gofilewriter
+
+import (
+	"github.com/pkg/errors"
+)
+
+func getFreeSpaceB(path string) (uint64, error) {
+	return 0, errors.New("free space detection is not supported on this platform")
+}
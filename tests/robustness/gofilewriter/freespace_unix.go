@@ -0,0 +1,19 @@
+// +build linux darwin freebsd
+
+package gofilewriter
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func getFreeSpaceB(path string) (uint64, error) {
+	var stat unix.Statfs_t
+
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, errors.Wrapf(err, "unable to stat file system of %v", path)
+	}
+
+	// Available blocks * size per block = available space in bytes
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil //nolint:unconvert
+}
@@ -0,0 +1,21 @@
+package gofilewriter
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+func getFreeSpaceB(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid path %v", path)
+	}
+
+	var freeBytesAvailable uint64
+
+	if err := windows.GetDiskFreeSpaceEx(p, &freeBytesAvailable, nil, nil); err != nil {
+		return 0, errors.Wrapf(err, "unable to get free space of %v", path)
+	}
+
+	return freeBytesAvailable, nil
+}
@@ -0,0 +1,191 @@
+package gofilewriter
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// maxDedupeBlocks limits the number of blocks retained as a source of
+// duplicate data.
+const maxDedupeBlocks = 1024
+
+// writeJob writes and modifies files on behalf of a single WriteRandomFiles
+// call. It is used while holding the FileWriter lock.
+type writeJob struct {
+	fw *FileWriter
+
+	dedupPcnt    int
+	churnPattern string
+
+	remainingIO int64
+	unlimitedIO bool
+}
+
+func (w *writeJob) canWrite() bool {
+	return w.unlimitedIO || w.remainingIO > 0
+}
+
+// limit returns the number of bytes out of n that may be written.
+func (w *writeJob) limit(n int64) int64 {
+	if w.unlimitedIO || n <= w.remainingIO {
+		return n
+	}
+
+	return w.remainingIO
+}
+
+// writeData writes n bytes of generated data to the writer.
+func (w *writeJob) writeData(out io.Writer, n int64) error {
+	n = w.limit(n)
+
+	for n > 0 {
+		b := w.nextBlock()
+		if int64(len(b)) > n {
+			b = b[0:n]
+		}
+
+		if _, err := out.Write(b); err != nil {
+			return errors.Wrap(err, "error writing data")
+		}
+
+		n -= int64(len(b))
+
+		if !w.unlimitedIO {
+			w.remainingIO -= int64(len(b))
+		}
+	}
+
+	return nil
+}
+
+// nextBlock returns the next block of data, which is a copy of a previously
+// generated block with probability given by the dedupe percentage.
+func (w *writeJob) nextBlock() []byte {
+	const pcntConv = 100
+
+	fw := w.fw
+
+	if len(fw.dedupeBlocks) > 0 && fw.rnd.Intn(pcntConv) < w.dedupPcnt {
+		return fw.dedupeBlocks[fw.rnd.Intn(len(fw.dedupeBlocks))]
+	}
+
+	b := make([]byte, blockSize)
+	fw.rnd.Read(b) //nolint:errcheck
+
+	if len(fw.dedupeBlocks) < maxDedupeBlocks {
+		fw.dedupeBlocks = append(fw.dedupeBlocks, b)
+	} else {
+		fw.dedupeBlocks[fw.rnd.Intn(maxDedupeBlocks)] = b
+	}
+
+	return b
+}
+
+// writeNewFile writes a new file of the provided size in the directory.
+func (w *writeJob) writeNewFile(dirPath string, size int64) error {
+	var f *os.File
+
+	_, err := w.fw.createUnique(dirPath, "file_", func(p string) error {
+		var err error
+
+		f, err = os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:gosec
+
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to create file in %v", dirPath)
+	}
+
+	bw := bufio.NewWriter(f)
+
+	if err := w.writeData(bw, size); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return errors.Wrap(err, "error flushing file")
+	}
+
+	return errors.Wrap(f.Close(), "error closing file")
+}
+
+// churnFiles modifies the given percentage of existing files in the directory.
+func (w *writeJob) churnFiles(dirPath string, churnPcnt int) error {
+	if churnPcnt <= 0 {
+		return nil
+	}
+
+	const pcntConv = 100
+
+	entries, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read dir at path %v", dirPath)
+	}
+
+	fw := w.fw
+
+	for _, e := range entries {
+		if !e.Mode().IsRegular() || fw.rnd.Intn(pcntConv) >= churnPcnt || !w.canWrite() {
+			continue
+		}
+
+		pattern := w.churnPattern
+		if pattern == ChurnMixed {
+			patterns := []string{ChurnOverwrite, ChurnAppend, ChurnTruncate}
+			pattern = patterns[fw.rnd.Intn(len(patterns))]
+		}
+
+		if err := w.churnFile(filepath.Join(dirPath, e.Name()), e.Size(), pattern); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *writeJob) churnFile(path string, size int64, pattern string) error {
+	fw := w.fw
+
+	if pattern == ChurnTruncate {
+		if size == 0 {
+			return nil
+		}
+
+		return errors.Wrapf(os.Truncate(path, fw.rnd.Int63n(size)), "unable to truncate %v", path)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %v", path)
+	}
+
+	var offset, length int64
+
+	switch {
+	case pattern == ChurnAppend || size == 0:
+		offset = size
+		length = blockSize + fw.rnd.Int63n(size+1)
+	default:
+		offset = fw.rnd.Int63n(size)
+		length = 1 + fw.rnd.Int63n(size-offset)
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return errors.Wrapf(err, "unable to seek in %v", path)
+	}
+
+	if err := w.writeData(f, length); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return err
+	}
+
+	return errors.Wrapf(f.Close(), "error closing %v", path)
+}
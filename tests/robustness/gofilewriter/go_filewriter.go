@@ -0,0 +1,503 @@
+// Package gofilewriter provides a FileWriter implemented in Go, which does not
+// depend on external tools and can be used on all platforms.
+package gofilewriter
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/tests/robustness"
+)
+
+// LocalDataPathEnvKey is the local path where data will be written.
+// If not specified, defaults to the default temp directory (os.TempDir).
+const LocalDataPathEnvKey = "LOCAL_DATA_PATH"
+
+// Option field names. Field names shared with fiofilewriter have the same
+// meaning, so that options can be used with either FileWriter.
+const (
+	ChurnPatternField            = "churn-pattern"
+	ChurnPercentField            = "churn-percent"
+	DedupePercentStepField       = "dedupe-percent"
+	DeletePercentOfContentsField = "delete-contents-percent"
+	FileSizeDistributionField    = "file-size-distribution"
+	FreeSpaceLimitField          = "free-space-limit"
+	IOLimitPerWriteAction        = "io-limit-per-write"
+	MaxDedupePercentField        = "max-dedupe-percent"
+	MaxDirDepthField             = "max-dir-depth"
+	MaxFileSizeField             = "max-file-size"
+	MaxNumFilesPerWriteField     = "max-num-files-per-write"
+	MinDedupePercentField        = "min-dedupe-percent"
+	MinFileSizeField             = "min-file-size"
+	MinNumFilesPerWriteField     = "min-num-files-per-write"
+)
+
+// Supported values of FileSizeDistributionField.
+const (
+	// DistributionUniform picks file sizes uniformly from the size range.
+	DistributionUniform = "uniform"
+	// DistributionLogUniform picks file sizes with uniformly distributed
+	// logarithm, favoring small files as is common in real file systems.
+	DistributionLogUniform = "log-uniform"
+)
+
+// Supported values of ChurnPatternField.
+const (
+	// ChurnOverwrite overwrites a random range of an existing file.
+	ChurnOverwrite = "overwrite"
+	// ChurnAppend appends data to an existing file.
+	ChurnAppend = "append"
+	// ChurnTruncate truncates an existing file at a random offset.
+	ChurnTruncate = "truncate"
+	// ChurnMixed picks one of the above patterns for each modified file.
+	ChurnMixed = "mixed"
+)
+
+// Option defaults.
+const (
+	defaultChurnPattern            = ChurnMixed
+	defaultChurnPercent            = 0
+	defaultDedupePercentStep       = 25
+	defaultDeletePercentOfContents = 20
+	defaultFileSizeDistribution    = DistributionUniform
+	defaultFreeSpaceLimit          = 100 * 1024 * 1024 // 100 MB
+	defaultIOLimitPerWriteAction   = 0                 // A zero value does not impose any limit on IO
+	defaultMaxDedupePercent        = 100
+	defaultMaxDirDepth             = 20
+	defaultMaxFileSize             = 1 * 1024 * 1024 * 1024 // 1GB
+	defaultMaxNumFilesPerWrite     = 10000
+	defaultMinDedupePercent        = 0
+	defaultMinFileSize             = 4096
+	defaultMinNumFilesPerWrite     = 1
+)
+
+const blockSize = 4096
+
+// List of known errors.
+var (
+	ErrNoDirFound       = errors.New("no directory found at this depth")
+	ErrCanNotDeleteRoot = errors.New("can not delete root directory")
+)
+
+// New returns a FileWriter writing to a new temporary directory.
+// See LocalDataPathEnvKey for configuration details.
+func New() (*FileWriter, error) {
+	return NewWithSeed(clock.Now().UnixNano())
+}
+
+// NewWithSeed returns a FileWriter whose random choices and generated data
+// are derived from the provided seed.
+func NewWithSeed(seed int64) (*FileWriter, error) {
+	dataDir, err := ioutil.TempDir(os.Getenv(LocalDataPathEnvKey), "go-data-")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create temp directory for file writer")
+	}
+
+	return &FileWriter{
+		LocalDataDir: dataDir,
+		rnd:          rand.New(rand.NewSource(seed)), //nolint:gosec
+	}, nil
+}
+
+// FileWriter implements a FileWriter which generates data in-process.
+type FileWriter struct {
+	LocalDataDir string
+
+	mu  sync.Mutex // protects fields below
+	rnd *rand.Rand
+
+	// blocks that have been previously written, used as a source of duplicate data.
+	dedupeBlocks [][]byte
+}
+
+var _ robustness.FileWriter = (*FileWriter)(nil)
+
+// Seed resets the source of random choices and generated data, so that
+// subsequent operations can be reproduced.
+func (fw *FileWriter) Seed(seed int64) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	fw.rnd = rand.New(rand.NewSource(seed)) //nolint:gosec
+}
+
+// DataDirectory returns the data directory configured.
+func (fw *FileWriter) DataDirectory(ctx context.Context) string {
+	return fw.LocalDataDir
+}
+
+// WriteRandomFiles writes a number of files at some filesystem depth, based
+// on its input options.
+//
+//  - MaxDirDepthField
+//  - MaxFileSizeField
+//  - MinFileSizeField
+//  - FileSizeDistributionField
+//  - MaxNumFilesPerWriteField
+//  - MinNumFilesPerWriteField
+//  - MaxDedupePercentField
+//  - MinDedupePercentField
+//  - DedupePercentStepField
+//  - ChurnPercentField
+//  - ChurnPatternField
+//  - IOLimitPerWriteAction
+//  - FreeSpaceLimitField
+//
+// Default values are used for missing options. The method
+// returns the effective options used along with the selected depth
+// and the error if any.
+func (fw *FileWriter) WriteRandomFiles(ctx context.Context, opts map[string]string) (map[string]string, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	// Directory depth
+	maxDirDepth := robustness.GetOptAsIntOrDefault(MaxDirDepthField, opts, defaultMaxDirDepth)
+	dirDepth := fw.rnd.Intn(maxDirDepth + 1)
+
+	// File size range
+	maxFileSizeB := robustness.GetOptAsIntOrDefault(MaxFileSizeField, opts, defaultMaxFileSize)
+	minFileSizeB := robustness.GetOptAsIntOrDefault(MinFileSizeField, opts, defaultMinFileSize)
+
+	distribution := getOptOrDefault(FileSizeDistributionField, opts, defaultFileSizeDistribution)
+	if distribution != DistributionUniform && distribution != DistributionLogUniform {
+		return nil, robustness.ErrInvalidOption
+	}
+
+	// Number of files to write
+	maxNumFiles := robustness.GetOptAsIntOrDefault(MaxNumFilesPerWriteField, opts, defaultMaxNumFilesPerWrite)
+	minNumFiles := robustness.GetOptAsIntOrDefault(MinNumFilesPerWriteField, opts, defaultMinNumFilesPerWrite)
+
+	numFiles := fw.rnd.Intn(maxNumFiles-minNumFiles+1) + minNumFiles
+
+	// Dedup Percentage
+	maxDedupPcnt := robustness.GetOptAsIntOrDefault(MaxDedupePercentField, opts, defaultMaxDedupePercent)
+	minDedupPcnt := robustness.GetOptAsIntOrDefault(MinDedupePercentField, opts, defaultMinDedupePercent)
+
+	dedupStep := robustness.GetOptAsIntOrDefault(DedupePercentStepField, opts, defaultDedupePercentStep)
+
+	dedupPcnt := dedupStep * (fw.rnd.Intn(maxDedupPcnt/dedupStep-minDedupPcnt/dedupStep+1) + minDedupPcnt/dedupStep)
+
+	// Churn of existing files
+	churnPcnt := robustness.GetOptAsIntOrDefault(ChurnPercentField, opts, defaultChurnPercent)
+
+	churnPattern := getOptOrDefault(ChurnPatternField, opts, defaultChurnPattern)
+	if !isValidChurnPattern(churnPattern) {
+		return nil, robustness.ErrInvalidOption
+	}
+
+	ioLimit := robustness.GetOptAsIntOrDefault(IOLimitPerWriteAction, opts, defaultIOLimitPerWriteAction)
+
+	if ioLimit > 0 {
+		freeSpaceLimitB := robustness.GetOptAsIntOrDefault(FreeSpaceLimitField, opts, defaultFreeSpaceLimit)
+
+		freeSpaceB, err := getFreeSpaceB(fw.LocalDataDir)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Free Space %v B, limit %v B, ioLimit %v B\n", freeSpaceB, freeSpaceLimitB, ioLimit)
+
+		if int(freeSpaceB)-ioLimit < freeSpaceLimitB {
+			ioLimit = int(freeSpaceB) - freeSpaceLimitB
+
+			log.Printf("Cutting down I/O limit for space %v", ioLimit)
+
+			if ioLimit <= 0 {
+				return nil, robustness.ErrCannotPerformIO
+			}
+		}
+	}
+
+	relBasePath := "."
+
+	log.Printf("Writing files at depth %v (fileSize: %v-%v %v, numFiles: %v, dedupPcnt: %v, churn: %v%% %v, ioLimit: %v)\n",
+		dirDepth, minFileSizeB, maxFileSizeB, distribution, numFiles, dedupPcnt, churnPcnt, churnPattern, ioLimit)
+
+	retOpts := make(map[string]string, len(opts))
+	for k, v := range opts {
+		retOpts[k] = v
+	}
+
+	retOpts["dirDepth"] = strconv.Itoa(dirDepth)
+	retOpts["relBasePath"] = relBasePath
+	retOpts["numFiles"] = strconv.Itoa(numFiles)
+	retOpts["dedupePercent"] = strconv.Itoa(dedupPcnt)
+	retOpts[FileSizeDistributionField] = distribution
+	retOpts[ChurnPercentField] = strconv.Itoa(churnPcnt)
+	retOpts[ChurnPatternField] = churnPattern
+
+	dirPath, err := fw.dirAtDepthRandomBranch(filepath.Join(fw.LocalDataDir, relBasePath), dirDepth)
+	if err != nil {
+		return retOpts, err
+	}
+
+	w := &writeJob{
+		fw:           fw,
+		dedupPcnt:    dedupPcnt,
+		remainingIO:  int64(ioLimit),
+		unlimitedIO:  ioLimit <= 0,
+		churnPattern: churnPattern,
+	}
+
+	if err := w.churnFiles(dirPath, churnPcnt); err != nil {
+		return retOpts, err
+	}
+
+	for i := 0; i < numFiles && w.canWrite(); i++ {
+		size := fw.pickFileSize(int64(minFileSizeB), int64(maxFileSizeB), distribution)
+
+		if err := w.writeNewFile(dirPath, size); err != nil {
+			return retOpts, err
+		}
+	}
+
+	return retOpts, nil
+}
+
+// DeleteRandomSubdirectory deletes a random directory up to a specified depth,
+// based on its input options:
+//
+//  - MaxDirDepthField
+//
+// Default values are used for missing options. The method
+// returns the effective options used along with the selected depth
+// and the error if any. ErrNoOp is returned if no directory is found.
+func (fw *FileWriter) DeleteRandomSubdirectory(ctx context.Context, opts map[string]string) (map[string]string, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	maxDirDepth := robustness.GetOptAsIntOrDefault(MaxDirDepthField, opts, defaultMaxDirDepth)
+	if maxDirDepth <= 0 {
+		return nil, robustness.ErrInvalidOption
+	}
+
+	dirDepth := fw.rnd.Intn(maxDirDepth) + 1
+
+	log.Printf("Deleting directory at depth %v\n", dirDepth)
+
+	retOpts := make(map[string]string, len(opts))
+	for k, v := range opts {
+		retOpts[k] = v
+	}
+
+	retOpts["dirDepth"] = strconv.Itoa(dirDepth)
+
+	err := fw.operateAtDepth(fw.LocalDataDir, dirDepth, os.RemoveAll)
+	if errors.Is(err, ErrNoDirFound) {
+		log.Print(err)
+		err = robustness.ErrNoOp
+	}
+
+	return retOpts, err
+}
+
+// DeleteDirectoryContents deletes some of the contents of random directory up to a specified depth,
+// based on its input options:
+//
+//  - MaxDirDepthField
+//  - DeletePercentOfContentsField
+//
+// Default values are used for missing options. The method
+// returns the effective options used along with the selected depth
+// and the error if any. ErrNoOp is returned if no directory is found.
+func (fw *FileWriter) DeleteDirectoryContents(ctx context.Context, opts map[string]string) (map[string]string, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	maxDirDepth := robustness.GetOptAsIntOrDefault(MaxDirDepthField, opts, defaultMaxDirDepth)
+	dirDepth := fw.rnd.Intn(maxDirDepth + 1)
+
+	pcnt := robustness.GetOptAsIntOrDefault(DeletePercentOfContentsField, opts, defaultDeletePercentOfContents)
+
+	log.Printf("Deleting %d%% of directory contents at depth %v\n", pcnt, dirDepth)
+
+	retOpts := make(map[string]string, len(opts))
+	for k, v := range opts {
+		retOpts[k] = v
+	}
+
+	retOpts["dirDepth"] = strconv.Itoa(dirDepth)
+	retOpts["percent"] = strconv.Itoa(pcnt)
+
+	const pcntConv = 100
+
+	prob := float64(pcnt) / pcntConv
+
+	err := fw.operateAtDepth(fw.LocalDataDir, dirDepth, func(dirPath string) error {
+		entries, err := ioutil.ReadDir(dirPath)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read dir at path %v", dirPath)
+		}
+
+		for _, e := range entries {
+			if fw.rnd.Float64() < prob {
+				if err := os.RemoveAll(filepath.Join(dirPath, e.Name())); err != nil {
+					return errors.Wrap(err, "unable to remove directory entry")
+				}
+			}
+		}
+
+		return nil
+	})
+	if errors.Is(err, ErrNoDirFound) {
+		log.Print(err)
+		err = robustness.ErrNoOp
+	}
+
+	return retOpts, err
+}
+
+// DeleteEverything deletes all content.
+func (fw *FileWriter) DeleteEverything(ctx context.Context) error {
+	_, err := fw.DeleteDirectoryContents(ctx, map[string]string{
+		MaxDirDepthField:             strconv.Itoa(0),
+		DeletePercentOfContentsField: strconv.Itoa(100),
+	})
+
+	return err
+}
+
+// Cleanup is part of FileWriter.
+func (fw *FileWriter) Cleanup() {
+	if err := os.RemoveAll(fw.LocalDataDir); err != nil {
+		log.Printf("Error removing data directory %v: %v", fw.LocalDataDir, err)
+	}
+}
+
+// pickFileSize returns a random file size in the provided range.
+func (fw *FileWriter) pickFileSize(minSize, maxSize int64, distribution string) int64 {
+	if maxSize <= minSize {
+		return minSize
+	}
+
+	if distribution == DistributionLogUniform && minSize > 0 {
+		lo, hi := math.Log(float64(minSize)), math.Log(float64(maxSize))
+		return int64(math.Exp(lo + fw.rnd.Float64()*(hi-lo)))
+	}
+
+	return minSize + fw.rnd.Int63n(maxSize-minSize+1)
+}
+
+// operateAtDepth invokes f on a random directory at the provided depth below path.
+func (fw *FileWriter) operateAtDepth(path string, depth int, f func(string) error) error {
+	if depth <= 0 {
+		log.Printf("performing operation on directory %s\n", path)
+		return f(path)
+	}
+
+	dirList := subdirectories(path)
+
+	fw.rnd.Shuffle(len(dirList), func(i, j int) {
+		dirList[i], dirList[j] = dirList[j], dirList[i]
+	})
+
+	for _, dirName := range dirList {
+		err := fw.operateAtDepth(dirName, depth-1, f)
+		if !errors.Is(err, ErrNoDirFound) {
+			return err
+		}
+	}
+
+	return ErrNoDirFound
+}
+
+// dirAtDepthRandomBranch returns a directory "depth" layers below the provided
+// path, traversing existing directories up to a random depth and creating new
+// directories for the remainder of the path.
+func (fw *FileWriter) dirAtDepthRandomBranch(path string, depth int) (string, error) {
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return "", errors.Wrapf(err, "unable to make base dir %v", path)
+	}
+
+	branchDepth := fw.rnd.Intn(depth + 1)
+
+	for ; depth > 0; depth-- {
+		var subdirPath string
+
+		if branchDepth > 0 {
+			if dirs := subdirectories(path); len(dirs) > 0 {
+				subdirPath = dirs[fw.rnd.Intn(len(dirs))]
+			}
+		}
+
+		if subdirPath == "" {
+			var err error
+
+			// Couldn't find a subdir, create one instead
+			subdirPath, err = fw.createUnique(path, "dir_", func(p string) error {
+				return os.Mkdir(p, 0o700)
+			})
+			if err != nil {
+				return "", errors.Wrapf(err, "unable to create dir at %v", path)
+			}
+		}
+
+		path = subdirPath
+		branchDepth--
+	}
+
+	return path, nil
+}
+
+// createUnique invokes create with a new path in the directory, whose name
+// is derived from the writer's source of randomness so that it can be reproduced.
+func (fw *FileWriter) createUnique(dirPath, prefix string, create func(string) error) (string, error) {
+	const maxAttempts = 100
+
+	for i := 0; i < maxAttempts; i++ {
+		p := filepath.Join(dirPath, prefix+strconv.FormatUint(uint64(fw.rnd.Uint32()), 10))
+
+		err := create(p)
+		if os.IsExist(err) {
+			continue
+		}
+
+		return p, err
+	}
+
+	return "", errors.Errorf("unable to create unique name in %v", dirPath)
+}
+
+func subdirectories(path string) []string {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, filepath.Join(path, e.Name()))
+		}
+	}
+
+	return dirs
+}
+
+func getOptOrDefault(key string, opts map[string]string, def string) string {
+	if v := opts[key]; v != "" {
+		return v
+	}
+
+	return def
+}
+
+func isValidChurnPattern(p string) bool {
+	switch p {
+	case ChurnOverwrite, ChurnAppend, ChurnTruncate, ChurnMixed:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,363 @@
+package gofilewriter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/tests/robustness"
+)
+
+func TestWriteRandomFiles(t *testing.T) {
+	fw, err := New()
+	require.NoError(t, err)
+
+	defer fw.Cleanup()
+
+	ctx := context.Background()
+
+	const (
+		numFiles = 13
+		fileSize = 64 * 1024
+	)
+
+	retOpts, err := fw.WriteRandomFiles(ctx, map[string]string{
+		MaxDirDepthField:         "3",
+		MinFileSizeField:         strconv.Itoa(fileSize),
+		MaxFileSizeField:         strconv.Itoa(fileSize),
+		MinNumFilesPerWriteField: strconv.Itoa(numFiles),
+		MaxNumFilesPerWriteField: strconv.Itoa(numFiles),
+	})
+	require.NoError(t, err)
+	require.Equal(t, strconv.Itoa(numFiles), retOpts["numFiles"])
+
+	files := listFiles(t, fw.DataDirectory(ctx))
+	require.Len(t, files, numFiles)
+
+	for _, fi := range files {
+		require.Equal(t, int64(fileSize), fi.Size())
+	}
+}
+
+func TestWriteRandomFilesLogUniformSizes(t *testing.T) {
+	fw, err := NewWithSeed(1)
+	require.NoError(t, err)
+
+	defer fw.Cleanup()
+
+	ctx := context.Background()
+
+	const (
+		numFiles = 200
+		minSize  = 16
+		maxSize  = 1024 * 1024
+	)
+
+	_, err = fw.WriteRandomFiles(ctx, map[string]string{
+		MaxDirDepthField:          "0",
+		MinFileSizeField:          strconv.Itoa(minSize),
+		MaxFileSizeField:          strconv.Itoa(maxSize),
+		MinNumFilesPerWriteField:  strconv.Itoa(numFiles),
+		MaxNumFilesPerWriteField:  strconv.Itoa(numFiles),
+		FileSizeDistributionField: DistributionLogUniform,
+	})
+	require.NoError(t, err)
+
+	files := listFiles(t, fw.DataDirectory(ctx))
+	require.Len(t, files, numFiles)
+
+	small := 0
+
+	for _, fi := range files {
+		require.GreaterOrEqual(t, fi.Size(), int64(minSize))
+		require.LessOrEqual(t, fi.Size(), int64(maxSize))
+
+		if fi.Size() < maxSize/100 {
+			small++
+		}
+	}
+
+	// about 58% of files are expected to be this small, compared to 1% with
+	// uniform distribution.
+	require.Greater(t, small, numFiles/4)
+
+	_, err = fw.WriteRandomFiles(ctx, map[string]string{
+		FileSizeDistributionField: "no-such-distribution",
+	})
+	require.ErrorIs(t, err, robustness.ErrInvalidOption)
+}
+
+func TestWriteRandomFilesDedupe(t *testing.T) {
+	for _, tc := range []struct {
+		dedupePercent int
+		minUnique     int
+		maxUnique     int
+	}{
+		{dedupePercent: 0, minUnique: 100, maxUnique: 100},
+		{dedupePercent: 100, minUnique: 1, maxUnique: 1},
+		{dedupePercent: 50, minUnique: 20, maxUnique: 80},
+	} {
+		fw, err := NewWithSeed(1)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		_, err = fw.WriteRandomFiles(ctx, map[string]string{
+			MaxDirDepthField:         "0",
+			MinFileSizeField:         strconv.Itoa(100 * blockSize),
+			MaxFileSizeField:         strconv.Itoa(100 * blockSize),
+			MinNumFilesPerWriteField: "1",
+			MaxNumFilesPerWriteField: "1",
+			MinDedupePercentField:    strconv.Itoa(tc.dedupePercent),
+			MaxDedupePercentField:    strconv.Itoa(tc.dedupePercent),
+			DedupePercentStepField:   "1",
+		})
+		require.NoError(t, err)
+
+		files := listFiles(t, fw.DataDirectory(ctx))
+		require.Len(t, files, 1)
+
+		data, err := ioutil.ReadFile(files[0].path)
+		require.NoError(t, err)
+
+		unique := map[string]bool{}
+		for i := 0; i < len(data); i += blockSize {
+			unique[string(data[i:i+blockSize])] = true
+		}
+
+		require.GreaterOrEqual(t, len(unique), tc.minUnique, "dedupe %v", tc.dedupePercent)
+		require.LessOrEqual(t, len(unique), tc.maxUnique, "dedupe %v", tc.dedupePercent)
+
+		fw.Cleanup()
+	}
+}
+
+func TestWriteRandomFilesChurn(t *testing.T) {
+	for _, pattern := range []string{ChurnOverwrite, ChurnAppend, ChurnTruncate} {
+		fw, err := New()
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		opts := map[string]string{
+			MaxDirDepthField:         "0",
+			MinFileSizeField:         strconv.Itoa(8 * blockSize),
+			MaxFileSizeField:         strconv.Itoa(8 * blockSize),
+			MinNumFilesPerWriteField: "5",
+			MaxNumFilesPerWriteField: "5",
+			MaxDedupePercentField:    "0",
+		}
+
+		_, err = fw.WriteRandomFiles(ctx, opts)
+		require.NoError(t, err)
+
+		before := map[string][]byte{}
+
+		for _, fi := range listFiles(t, fw.DataDirectory(ctx)) {
+			before[fi.path], err = ioutil.ReadFile(fi.path)
+			require.NoError(t, err)
+		}
+
+		opts[ChurnPercentField] = "100"
+		opts[ChurnPatternField] = pattern
+		opts[MinNumFilesPerWriteField] = "0"
+		opts[MaxNumFilesPerWriteField] = "0"
+
+		_, err = fw.WriteRandomFiles(ctx, opts)
+		require.NoError(t, err)
+
+		after := listFiles(t, fw.DataDirectory(ctx))
+		require.Len(t, after, len(before))
+
+		for _, fi := range after {
+			data, err := ioutil.ReadFile(fi.path)
+			require.NoError(t, err)
+
+			prev := before[fi.path]
+			require.NotEqual(t, prev, data, pattern)
+
+			switch pattern {
+			case ChurnOverwrite:
+				require.Len(t, data, len(prev))
+			case ChurnAppend:
+				require.Greater(t, len(data), len(prev))
+				require.True(t, bytes.HasPrefix(data, prev))
+			case ChurnTruncate:
+				require.Less(t, len(data), len(prev))
+				require.True(t, bytes.HasPrefix(prev, data))
+			}
+		}
+
+		fw.Cleanup()
+	}
+}
+
+func TestWriteRandomFilesIOLimit(t *testing.T) {
+	fw, err := New()
+	require.NoError(t, err)
+
+	defer fw.Cleanup()
+
+	ctx := context.Background()
+
+	const ioLimit = 10 * blockSize
+
+	_, err = fw.WriteRandomFiles(ctx, map[string]string{
+		MaxDirDepthField:         "0",
+		MinFileSizeField:         strconv.Itoa(4 * blockSize),
+		MaxFileSizeField:         strconv.Itoa(4 * blockSize),
+		MinNumFilesPerWriteField: "100",
+		MaxNumFilesPerWriteField: "100",
+		IOLimitPerWriteAction:    strconv.Itoa(ioLimit),
+		FreeSpaceLimitField:      "0",
+	})
+	require.NoError(t, err)
+
+	var total int64
+
+	files := listFiles(t, fw.DataDirectory(ctx))
+	for _, fi := range files {
+		total += fi.Size()
+	}
+
+	require.Len(t, files, 3)
+	require.Equal(t, int64(ioLimit), total)
+}
+
+func TestDeleteRandomSubdirectory(t *testing.T) {
+	fw, err := New()
+	require.NoError(t, err)
+
+	defer fw.Cleanup()
+
+	ctx := context.Background()
+
+	_, err = fw.DeleteRandomSubdirectory(ctx, map[string]string{MaxDirDepthField: "1"})
+	require.ErrorIs(t, err, robustness.ErrNoOp)
+
+	_, err = fw.DeleteRandomSubdirectory(ctx, map[string]string{MaxDirDepthField: "0"})
+	require.ErrorIs(t, err, robustness.ErrInvalidOption)
+
+	_, err = fw.WriteRandomFiles(ctx, map[string]string{
+		MaxDirDepthField:         "1",
+		MinNumFilesPerWriteField: "1",
+		MaxNumFilesPerWriteField: "1",
+		MaxFileSizeField:         "100",
+		MinFileSizeField:         "100",
+	})
+	require.NoError(t, err)
+
+	// make sure there is a subdirectory to delete
+	require.NoError(t, os.MkdirAll(filepath.Join(fw.DataDirectory(ctx), "dir_x"), 0o700))
+
+	_, err = fw.DeleteRandomSubdirectory(ctx, map[string]string{MaxDirDepthField: "1"})
+	require.NoError(t, err)
+}
+
+func TestDeleteEverything(t *testing.T) {
+	fw, err := New()
+	require.NoError(t, err)
+
+	defer fw.Cleanup()
+
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		_, err = fw.WriteRandomFiles(ctx, map[string]string{
+			MaxDirDepthField:         "5",
+			MinNumFilesPerWriteField: "3",
+			MaxNumFilesPerWriteField: "3",
+			MaxFileSizeField:         "1000",
+			MinFileSizeField:         "100",
+		})
+		require.NoError(t, err)
+	}
+
+	require.NotEmpty(t, listFiles(t, fw.DataDirectory(ctx)))
+
+	require.NoError(t, fw.DeleteEverything(ctx))
+
+	entries, err := ioutil.ReadDir(fw.DataDirectory(ctx))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestSeedDeterminism(t *testing.T) {
+	ctx := context.Background()
+
+	opts := map[string]string{
+		MaxDirDepthField:         "3",
+		MinNumFilesPerWriteField: "1",
+		MaxNumFilesPerWriteField: "10",
+		MaxFileSizeField:         "100000",
+		MinFileSizeField:         "1",
+		ChurnPercentField:        "50",
+	}
+
+	contents := func() map[string][]byte {
+		fw, err := New()
+		require.NoError(t, err)
+
+		defer fw.Cleanup()
+
+		for i := 0; i < 5; i++ {
+			fw.Seed(int64(i))
+
+			_, err = fw.WriteRandomFiles(ctx, opts)
+			require.NoError(t, err)
+		}
+
+		fw.Seed(42)
+
+		_, err = fw.DeleteDirectoryContents(ctx, map[string]string{MaxDirDepthField: "1"})
+		if !errors.Is(err, robustness.ErrNoOp) {
+			require.NoError(t, err)
+		}
+
+		result := map[string][]byte{}
+
+		for _, fi := range listFiles(t, fw.DataDirectory(ctx)) {
+			rel, err := filepath.Rel(fw.DataDirectory(ctx), fi.path)
+			require.NoError(t, err)
+
+			result[rel], err = ioutil.ReadFile(fi.path)
+			require.NoError(t, err)
+		}
+
+		return result
+	}
+
+	require.Equal(t, contents(), contents())
+}
+
+type fileInfo struct {
+	os.FileInfo
+	path string
+}
+
+func listFiles(t *testing.T, dir string) []fileInfo {
+	t.Helper()
+
+	var result []fileInfo
+
+	require.NoError(t, filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			result = append(result, fileInfo{info, path})
+		}
+
+		return nil
+	}))
+
+	return result
+}
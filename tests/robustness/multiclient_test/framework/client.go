@@ -13,24 +13,56 @@
 
 var clientKey = struct{}{}
 
+// ClientRole determines the ACL entries granted to a client.
+type ClientRole string
+
+// Supported client roles.
+const (
+	// RoleUser has access granted by the default server ACL entries.
+	RoleUser ClientRole = "user"
+	// RoleAdmin additionally has full access to all policies.
+	RoleAdmin ClientRole = "admin"
+)
+
+// aclGrant describes ACL entry granted to a client.
+type aclGrant struct {
+	target string
+	access string
+}
+
+// roleACLs maps client roles to additional ACL entries granted to clients.
+var roleACLs = map[ClientRole][]aclGrant{
+	RoleUser: nil,
+	RoleAdmin: {
+		{target: "type=policy", access: "FULL"},
+	},
+}
+
 // Client is a unique client for use in multiclient robustness tests.
 type Client struct {
-	ID string
+	ID   string
+	Role ClientRole
 }
 
 func init() {
 	petname.NonDeterministicMode()
 }
 
-func newClient() *Client {
+func newClient(role ClientRole) *Client {
 	return &Client{
-		ID: petname.Generate(nameLen, "-") + "-" + uuid.NewString(),
+		ID:   petname.Generate(nameLen, "-") + "-" + uuid.NewString(),
+		Role: role,
 	}
 }
 
 // NewClientContext returns a copy of ctx with a new client.
 func NewClientContext(ctx context.Context) context.Context {
-	return context.WithValue(ctx, clientKey, newClient())
+	return NewClientContextWithRole(ctx, RoleUser)
+}
+
+// NewClientContextWithRole returns a copy of ctx with a new client with the given role.
+func NewClientContextWithRole(ctx context.Context, role ClientRole) context.Context {
+	return context.WithValue(ctx, clientKey, newClient(role))
 }
 
 // NewClientContexts returns copies of ctx with n new clients.
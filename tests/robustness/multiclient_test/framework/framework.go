@@ -15,7 +15,7 @@
 // methods for handling client connections to a server and cleanup.
 type ClientSnapshotter interface {
 	robustness.Snapshotter
-	ConnectClient(fingerprint, user string) error
+	ConnectClient(fingerprint, user string, args ...string) error
 	DisconnectClient(user string)
 	Cleanup()
 }
@@ -23,10 +23,12 @@ type ClientSnapshotter interface {
 // Server is an interface for a repository server.
 type Server interface {
 	// Initialize and cleanup the server
+	RequireClientCertificates(caFile string)
 	ConnectOrCreateRepo(repoPath string) error
 	Cleanup()
 
 	// Handle client authorization
+	AddClientACL(user, target, access string) error
 	AuthorizeClient(user string) error
 	RemoveClient(user string)
 
@@ -15,9 +15,8 @@
 	"testing"
 
 	"github.com/kopia/kopia/tests/robustness/engine"
-	"github.com/kopia/kopia/tests/robustness/fiofilewriter"
+	"github.com/kopia/kopia/tests/robustness/gofilewriter"
 	"github.com/kopia/kopia/tests/robustness/snapmeta"
-	"github.com/kopia/kopia/tests/tools/fio"
 	"github.com/kopia/kopia/tests/tools/kopiarunner"
 )
 
@@ -26,7 +25,10 @@
 	metadataSubPath = "robustness-metadata"
 )
 
-var repoPathPrefix = flag.String("repo-path-prefix", "", "Point the robustness tests at this path prefix")
+var (
+	repoPathPrefix     = flag.String("repo-path-prefix", "", "Point the robustness tests at this path prefix")
+	clientCertificates = flag.Bool("client-certificates", false, "Require clients to authenticate to the server using TLS client certificates")
+)
 
 // NewHarness returns a test harness. It requires a context that contains a client.
 func NewHarness(ctx context.Context) *TestHarness {
@@ -72,7 +74,7 @@ func (th *TestHarness) init(ctx context.Context) {
 	}
 
 	// the initialization state machine is linear and bails out on first failure
-	if th.makeBaseDir() && th.getFileWriter() && th.getSnapshotter() &&
+	if th.makeBaseDir() && th.getFileWriter() && th.getSnapshotter(ctx) &&
 		th.getPersister() && th.getEngine(ctx) {
 		return // success!
 	}
@@ -103,16 +105,8 @@ func (th *TestHarness) makeBaseDir() bool {
 }
 
 func (th *TestHarness) getFileWriter() bool {
-	if os.Getenv(fio.FioExeEnvKey) == "" && os.Getenv(fio.FioDockerImageEnvKey) == "" {
-		log.Println("Skipping robustness tests because FIO environment is not set")
-
-		th.skipTest = true
-
-		return false
-	}
-
 	fw := NewMultiClientFileWriter(
-		func() (FileWriter, error) { return fiofilewriter.New() },
+		func() (FileWriter, error) { return gofilewriter.New() },
 	)
 
 	th.fileWriter = fw
@@ -120,7 +114,7 @@ func() (FileWriter, error) { return fiofilewriter.New() },
 	return true
 }
 
-func (th *TestHarness) getSnapshotter() bool {
+func (th *TestHarness) getSnapshotter(ctx context.Context) bool {
 	newClientFn := func(baseDirPath string) (ClientSnapshotter, error) {
 		return snapmeta.NewSnapshotter(th.baseDirPath)
 	}
@@ -140,6 +134,13 @@ func (th *TestHarness) getSnapshotter() bool {
 
 	th.snapshotter = s
 
+	if *clientCertificates {
+		if err = s.EnableClientCertificates(ctx); err != nil {
+			log.Println("Error enabling client certificates:", err)
+			return false
+		}
+	}
+
 	if err = s.ConnectOrCreateRepo(th.dataRepoPath); err != nil {
 		log.Println("Error initializing kopia Snapshotter:", err)
 		return false
@@ -23,6 +23,10 @@ type MultiClientSnapshotter struct {
 	baseDirPath string
 	server      Server
 
+	// Certificate authority issuing client certificates, nil unless
+	// EnableClientCertificates has been called.
+	clientCA *certificateAuthority
+
 	// Map of client ID to ClientSnapshotter and associated lock
 	clients map[string]ClientSnapshotter
 	mu      sync.RWMutex
@@ -53,6 +57,21 @@ func NewMultiClientSnapshotter(baseDirPath string, f newClientFn) (*MultiClientS
 	}, nil
 }
 
+// EnableClientCertificates configures the server to require TLS client
+// certificates and issues a certificate to each new client. It must be
+// invoked before ConnectOrCreateRepo.
+func (mcs *MultiClientSnapshotter) EnableClientCertificates(ctx context.Context) error {
+	ca, err := newCertificateAuthority(ctx, mcs.baseDirPath)
+	if err != nil {
+		return err
+	}
+
+	mcs.clientCA = ca
+	mcs.server.RequireClientCertificates(ca.caFile)
+
+	return nil
+}
+
 // ConnectOrCreateRepo makes the MultiClientSnapshotter ready for use. It will
 // connect to an existing repository if possible or create a new one, and
 // start a repository server.
@@ -199,12 +218,30 @@ func (mcs *MultiClientSnapshotter) createOrGetSnapshotter(ctx context.Context) (
 		return nil, err
 	}
 
+	// Grant role-specific ACL entries before the client is authorized
+	for _, g := range roleACLs[c.Role] {
+		if err := mcs.server.AddClientACL(c.ID, g.target, g.access); err != nil {
+			return nil, err
+		}
+	}
+
 	// Register client with server and create connection
 	if err := mcs.server.AuthorizeClient(c.ID); err != nil {
 		return nil, err
 	}
 
-	if err := cs.ConnectClient(mcs.server.ServerFingerprint(), c.ID); err != nil {
+	var connectArgs []string
+
+	if mcs.clientCA != nil {
+		certFile, keyFile, err := mcs.clientCA.issue(ctx, snapmeta.ClientUsernameAtHost(c.ID))
+		if err != nil {
+			return nil, err
+		}
+
+		connectArgs = append(connectArgs, "--client-cert-file", certFile, "--client-key-file", keyFile)
+	}
+
+	if err := cs.ConnectClient(mcs.server.ServerFingerprint(), c.ID, connectArgs...); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,69 @@
+// +build darwin,amd64 linux,amd64
+
+package framework
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"path/filepath"
+	"time"
+
+	"github.com/kopia/kopia/internal/tlsutil"
+)
+
+const (
+	certKeySize  = 2048
+	certValidity = 7 * 24 * time.Hour
+)
+
+// certificateAuthority issues per-client TLS certificates trusted by the server.
+type certificateAuthority struct {
+	dir    string
+	caFile string
+	cert   *x509.Certificate
+	key    *rsa.PrivateKey
+}
+
+// newCertificateAuthority generates a new certificate authority and writes its
+// certificate to a file in the provided directory.
+func newCertificateAuthority(ctx context.Context, dir string) (*certificateAuthority, error) {
+	cert, key, err := tlsutil.GenerateCertificateAuthority(ctx, certKeySize, certValidity)
+	if err != nil {
+		return nil, err
+	}
+
+	caFile := filepath.Join(dir, "client-ca.cert")
+	if err := tlsutil.WriteCertificateToFile(caFile, cert); err != nil {
+		return nil, err
+	}
+
+	return &certificateAuthority{
+		dir:    dir,
+		caFile: caFile,
+		cert:   cert,
+		key:    key,
+	}, nil
+}
+
+// issue generates a client certificate for the given common name and returns
+// the paths to the certificate and key files.
+func (ca *certificateAuthority) issue(ctx context.Context, commonName string) (certFile, keyFile string, err error) {
+	cert, key, err := tlsutil.GenerateClientCertificate(ctx, certKeySize, certValidity, commonName, ca.cert, ca.key)
+	if err != nil {
+		return "", "", err
+	}
+
+	certFile = filepath.Join(ca.dir, commonName+".cert")
+	keyFile = filepath.Join(ca.dir, commonName+".key")
+
+	if err := tlsutil.WriteCertificateToFile(certFile, cert); err != nil {
+		return "", "", err
+	}
+
+	if err := tlsutil.WritePrivateKeyToFile(keyFile, key); err != nil {
+		return "", "", err
+	}
+
+	return certFile, keyFile, nil
+}
@@ -17,7 +17,8 @@
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/tests/robustness"
 	"github.com/kopia/kopia/tests/robustness/engine"
-	"github.com/kopia/kopia/tests/robustness/fiofilewriter"
+	"github.com/kopia/kopia/tests/robustness/gofilewriter"
+	"github.com/kopia/kopia/tests/robustness/multiclient_test/framework"
 )
 
 const defaultTestDur = 5 * time.Minute
@@ -30,11 +31,11 @@ func TestManySmallFiles(t *testing.T) {
 	numClients := 4
 
 	fileWriteOpts := map[string]string{
-		fiofilewriter.MaxDirDepthField:         strconv.Itoa(1),
-		fiofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(numFiles),
-		fiofilewriter.MinNumFilesPerWriteField: strconv.Itoa(numFiles),
+		gofilewriter.MaxDirDepthField:         strconv.Itoa(1),
+		gofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(numFiles),
+		gofilewriter.MinNumFilesPerWriteField: strconv.Itoa(numFiles),
 	}
 
 	f := func(ctx context.Context, t *testing.T) { //nolint:thelper
@@ -61,11 +62,11 @@ func TestOneLargeFile(t *testing.T) {
 	numClients := 4
 
 	fileWriteOpts := map[string]string{
-		fiofilewriter.MaxDirDepthField:         strconv.Itoa(1),
-		fiofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(numFiles),
-		fiofilewriter.MinNumFilesPerWriteField: strconv.Itoa(numFiles),
+		gofilewriter.MaxDirDepthField:         strconv.Itoa(1),
+		gofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(numFiles),
+		gofilewriter.MinNumFilesPerWriteField: strconv.Itoa(numFiles),
 	}
 
 	f := func(ctx context.Context, t *testing.T) { //nolint:thelper
@@ -95,11 +96,11 @@ func TestManySmallFilesAcrossDirecoryTree(t *testing.T) {
 	numClients := 4
 
 	fileWriteOpts := map[string]string{
-		fiofilewriter.MaxDirDepthField:         strconv.Itoa(15),
-		fiofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(filesPerWrite),
-		fiofilewriter.MinNumFilesPerWriteField: strconv.Itoa(filesPerWrite),
+		gofilewriter.MaxDirDepthField:         strconv.Itoa(15),
+		gofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(filesPerWrite),
+		gofilewriter.MinNumFilesPerWriteField: strconv.Itoa(filesPerWrite),
 		engine.ActionRepeaterField:             strconv.Itoa(actionRepeats),
 	}
 
@@ -121,6 +122,37 @@ func TestManySmallFilesAcrossDirecoryTree(t *testing.T) {
 	th.RunN(ctx, t, numClients, f)
 }
 
+func TestMixedClientRoles(t *testing.T) {
+	fileWriteOpts := map[string]string{
+		gofilewriter.MaxDirDepthField:         strconv.Itoa(1),
+		gofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(100),
+		gofilewriter.MinNumFilesPerWriteField: strconv.Itoa(100),
+	}
+
+	f := func(ctx context.Context, t *testing.T) { //nolint:thelper
+		err := tryRestoreIntoDataDirectory(ctx, t)
+		require.NoError(t, err)
+
+		_, err = eng.ExecAction(ctx, engine.WriteRandomFilesActionKey, fileWriteOpts)
+		require.NoError(t, err)
+
+		_, err = eng.ExecAction(ctx, engine.SnapshotDirActionKey, nil)
+		require.NoError(t, err)
+
+		_, err = eng.ExecAction(ctx, engine.RestoreSnapshotActionKey, nil)
+		require.NoError(t, err)
+	}
+
+	ctx := testlogging.Context(t)
+	ctxs := []context.Context{
+		framework.NewClientContextWithRole(ctx, framework.RoleUser),
+		framework.NewClientContextWithRole(ctx, framework.RoleUser),
+		framework.NewClientContextWithRole(ctx, framework.RoleAdmin),
+	}
+
+	th.Run(ctxs, t, true, f)
+}
+
 func TestRandomizedSmall(t *testing.T) {
 	numClients := 2
 	st := clock.Now()
@@ -134,10 +166,10 @@ func TestRandomizedSmall(t *testing.T) {
 			string(engine.DeleteRandomSubdirectoryActionKey): strconv.Itoa(1),
 		},
 		engine.WriteRandomFilesActionKey: map[string]string{
-			fiofilewriter.IOLimitPerWriteAction:    fmt.Sprintf("%d", 512*1024*1024),
-			fiofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(100),
-			fiofilewriter.MaxFileSizeField:         strconv.Itoa(64 * 1024 * 1024),
-			fiofilewriter.MaxDirDepthField:         strconv.Itoa(3),
+			gofilewriter.IOLimitPerWriteAction:    fmt.Sprintf("%d", 512*1024*1024),
+			gofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(100),
+			gofilewriter.MaxFileSizeField:         strconv.Itoa(64 * 1024 * 1024),
+			gofilewriter.MaxDirDepthField:         strconv.Itoa(3),
 		},
 	}
 
@@ -16,9 +16,8 @@
 
 	"github.com/kopia/kopia/tests/robustness"
 	"github.com/kopia/kopia/tests/robustness/engine"
-	"github.com/kopia/kopia/tests/robustness/fiofilewriter"
+	"github.com/kopia/kopia/tests/robustness/gofilewriter"
 	"github.com/kopia/kopia/tests/robustness/snapmeta"
-	"github.com/kopia/kopia/tests/tools/fio"
 	"github.com/kopia/kopia/tests/tools/kopiarunner"
 )
 
@@ -33,6 +32,10 @@
 var (
 	randomizedTestDur = flag.Duration("rand-test-duration", defaultTestDur, "Set the duration for the randomized test")
 	repoPathPrefix    = flag.String("repo-path-prefix", "", "Point the robustness tests at this path prefix")
+
+	recordScenario      = flag.String("record-scenario", "", "Record the actions executed by the engine in this file")
+	replayScenario      = flag.String("replay-scenario", "", "Replay actions recorded in this file with -run TestReplayScenario, against a new repo-path-prefix")
+	replayScenarioSteps = flag.Int("replay-scenario-steps", 0, "Replay only this number of recorded steps, to bisect a failure (0 replays all)")
 )
 
 func TestMain(m *testing.M) {
@@ -71,7 +74,7 @@ type kopiaRobustnessTestHarness struct {
 	metaRepoPath string
 
 	baseDirPath string
-	fileWriter  *fiofilewriter.FileWriter
+	fileWriter  *gofilewriter.FileWriter
 	snapshotter *snapmeta.KopiaSnapshotter
 	persister   *snapmeta.KopiaPersister
 	engine      *engine.Engine
@@ -111,16 +114,9 @@ func (th *kopiaRobustnessTestHarness) makeBaseDir() bool {
 }
 
 func (th *kopiaRobustnessTestHarness) getFileWriter() bool {
-	fw, err := fiofilewriter.New()
+	fw, err := gofilewriter.New()
 	if err != nil {
-		if errors.Is(err, fio.ErrEnvNotSet) {
-			log.Println("Skipping robustness tests because FIO environment is not set")
-
-			th.skipTest = true
-		} else {
-			log.Println("Error creating fio FileWriter:", err)
-		}
-
+		log.Println("Error creating FileWriter:", err)
 		return false
 	}
 
@@ -133,7 +129,7 @@ func (th *kopiaRobustnessTestHarness) getSnapshotter() bool {
 	ks, err := snapmeta.NewSnapshotter(th.baseDirPath)
 	if err != nil {
 		if errors.Is(err, kopiarunner.ErrExeVariableNotSet) {
-			log.Println("Skipping robustness tests because KOPIA_EXE is not set")
+			log.Println("Skipping robustness tests because neither KOPIA_EXE nor KOPIA_RUNNER_MODE=inproc is set")
 
 			th.skipTest = true
 		} else {
@@ -157,7 +153,7 @@ func (th *kopiaRobustnessTestHarness) getPersister() bool {
 	kp, err := snapmeta.NewPersister(th.baseDirPath)
 	if err != nil {
 		if errors.Is(err, kopiarunner.ErrExeVariableNotSet) {
-			log.Println("Skipping robustness tests because KOPIA_EXE is not set")
+			log.Println("Skipping robustness tests because neither KOPIA_EXE nor KOPIA_RUNNER_MODE=inproc is set")
 
 			th.skipTest = true
 		} else {
@@ -201,6 +197,13 @@ func (th *kopiaRobustnessTestHarness) getEngine() bool {
 		return false
 	}
 
+	if *recordScenario != "" {
+		if err = eng.RecordScenario(*recordScenario); err != nil {
+			log.Println("Error recording scenario:", err)
+			return false
+		}
+	}
+
 	th.engine = eng
 
 	return true
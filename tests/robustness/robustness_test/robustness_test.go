@@ -14,7 +14,7 @@
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/tests/robustness"
 	"github.com/kopia/kopia/tests/robustness/engine"
-	"github.com/kopia/kopia/tests/robustness/fiofilewriter"
+	"github.com/kopia/kopia/tests/robustness/gofilewriter"
 )
 
 func TestManySmallFiles(t *testing.T) {
@@ -22,11 +22,11 @@ func TestManySmallFiles(t *testing.T) {
 	numFiles := 10000
 
 	fileWriteOpts := map[string]string{
-		fiofilewriter.MaxDirDepthField:         strconv.Itoa(1),
-		fiofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(numFiles),
-		fiofilewriter.MinNumFilesPerWriteField: strconv.Itoa(numFiles),
+		gofilewriter.MaxDirDepthField:         strconv.Itoa(1),
+		gofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(numFiles),
+		gofilewriter.MinNumFilesPerWriteField: strconv.Itoa(numFiles),
 	}
 
 	ctx := testlogging.Context(t)
@@ -46,11 +46,11 @@ func TestOneLargeFile(t *testing.T) {
 	numFiles := 1
 
 	fileWriteOpts := map[string]string{
-		fiofilewriter.MaxDirDepthField:         strconv.Itoa(1),
-		fiofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(numFiles),
-		fiofilewriter.MinNumFilesPerWriteField: strconv.Itoa(numFiles),
+		gofilewriter.MaxDirDepthField:         strconv.Itoa(1),
+		gofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(numFiles),
+		gofilewriter.MinNumFilesPerWriteField: strconv.Itoa(numFiles),
 	}
 
 	ctx := testlogging.Context(t)
@@ -73,11 +73,11 @@ func TestManySmallFilesAcrossDirecoryTree(t *testing.T) {
 	actionRepeats := numFiles / filesPerWrite
 
 	fileWriteOpts := map[string]string{
-		fiofilewriter.MaxDirDepthField:         strconv.Itoa(15),
-		fiofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
-		fiofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(filesPerWrite),
-		fiofilewriter.MinNumFilesPerWriteField: strconv.Itoa(filesPerWrite),
+		gofilewriter.MaxDirDepthField:         strconv.Itoa(15),
+		gofilewriter.MaxFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MinFileSizeField:         strconv.Itoa(fileSize),
+		gofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(filesPerWrite),
+		gofilewriter.MinNumFilesPerWriteField: strconv.Itoa(filesPerWrite),
 		engine.ActionRepeaterField:             strconv.Itoa(actionRepeats),
 	}
 
@@ -105,10 +105,10 @@ func TestRandomizedSmall(t *testing.T) {
 			string(engine.DeleteRandomSubdirectoryActionKey): strconv.Itoa(1),
 		},
 		engine.WriteRandomFilesActionKey: map[string]string{
-			fiofilewriter.IOLimitPerWriteAction:    fmt.Sprintf("%d", 512*1024*1024),
-			fiofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(100),
-			fiofilewriter.MaxFileSizeField:         strconv.Itoa(64 * 1024 * 1024),
-			fiofilewriter.MaxDirDepthField:         strconv.Itoa(3),
+			gofilewriter.IOLimitPerWriteAction:    fmt.Sprintf("%d", 512*1024*1024),
+			gofilewriter.MaxNumFilesPerWriteField: strconv.Itoa(100),
+			gofilewriter.MaxFileSizeField:         strconv.Itoa(64 * 1024 * 1024),
+			gofilewriter.MaxDirDepthField:         strconv.Itoa(3),
 		},
 	}
 
@@ -125,3 +125,19 @@ func TestRandomizedSmall(t *testing.T) {
 		require.NoError(t, err)
 	}
 }
+
+func TestReplayScenario(t *testing.T) {
+	if *replayScenario == "" {
+		t.Skip("replay-scenario is not set")
+	}
+
+	steps, err := engine.LoadScenario(*replayScenario)
+	require.NoError(t, err)
+
+	if n := *replayScenarioSteps; n > 0 && n < len(steps) {
+		steps = steps[:n]
+	}
+
+	ctx := testlogging.Context(t)
+	require.NoError(t, eng.ReplayScenario(ctx, steps))
+}
@@ -12,6 +12,13 @@
 const (
 	// S3BucketNameEnvKey is the environment variable required to connect to a repo on S3.
 	S3BucketNameEnvKey = "S3_BUCKET_NAME"
+	// GCSBucketNameEnvKey is the environment variable required to connect to a repo on GCS.
+	GCSBucketNameEnvKey = "GCS_BUCKET_NAME"
+	// AzureContainerNameEnvKey is the environment variable required to connect to a repo on Azure.
+	AzureContainerNameEnvKey = "AZURE_CONTAINER_NAME"
+	// FaultInjectionConfigEnvKey is the environment variable pointing at a JSON file describing
+	// storage faults (see faultinject.Options) to inject into the snapshotted repository.
+	FaultInjectionConfigEnvKey = "FAULT_INJECTION_CONFIG"
 	// EngineModeEnvKey is the environment variable required to switch between basic and server/client model.
 	EngineModeEnvKey = "ENGINE_MODE"
 	// EngineModeBasic is a constant used to check the engineMode.
@@ -26,8 +33,8 @@
 
 // kopiaConnector is a base type for Persister and Snapshotter.
 // It provides a kopiarunner.KopiaSnapshotter and common initialization
-// behavior based on the values of the EngineModeEnvKey and
-// S3BucketNameEnvKey environment variables.
+// behavior based on the values of the EngineModeEnvKey, S3BucketNameEnvKey,
+// GCSBucketNameEnvKey and AzureContainerNameEnvKey environment variables.
 //
 // Derived types can customize the initialization behavior by overriding
 // the default handler functions.
@@ -36,6 +43,10 @@ type kopiaConnector struct {
 	snap                       *kopiarunner.KopiaSnapshotter
 	initS3Fn                   func(repoPath, bucketName string) error
 	initS3WithServerFn         func(repoPath, bucketName, addr string) error
+	initGCSFn                  func(repoPath, bucketName string) error
+	initGCSWithServerFn        func(repoPath, bucketName, addr string) error
+	initAzureFn                func(repoPath, containerName string) error
+	initAzureWithServerFn      func(repoPath, containerName, addr string) error
 	initFilesystemFn           func(repoPath string) error
 	initFilesystemWithServerFn func(repoPath, addr string) error
 
@@ -56,6 +67,10 @@ func (ki *kopiaConnector) initializeConnector(baseDirPath string) error {
 	ki.initS3Fn = ki.initS3
 	ki.initFilesystemFn = ki.initFilesystem
 	ki.initS3WithServerFn = ki.initS3WithServer
+	ki.initGCSFn = ki.initGCS
+	ki.initGCSWithServerFn = ki.initGCSWithServer
+	ki.initAzureFn = ki.initAzure
+	ki.initAzureWithServerFn = ki.initAzureWithServer
 	ki.initFilesystemWithServerFn = ki.initFilesystemWithServer
 
 	return nil
@@ -65,6 +80,8 @@ func (ki *kopiaConnector) initializeConnector(baseDirPath string) error {
 // It invokes the appropriate initialization routine based on the environment variables set.
 func (ki *kopiaConnector) connectOrCreateRepo(repoPath string) error {
 	bucketName := os.Getenv(S3BucketNameEnvKey)
+	gcsBucketName := os.Getenv(GCSBucketNameEnvKey)
+	azureContainerName := os.Getenv(AzureContainerNameEnvKey)
 	engineMode := os.Getenv(EngineModeEnvKey)
 
 	switch {
@@ -74,7 +91,19 @@ func (ki *kopiaConnector) connectOrCreateRepo(repoPath string) error {
 	case bucketName != "" && engineMode == EngineModeServer:
 		return ki.initS3WithServerFn(repoPath, bucketName, defaultAddr)
 
-	case bucketName == "" && engineMode == EngineModeServer:
+	case gcsBucketName != "" && engineMode == EngineModeBasic:
+		return ki.initGCSFn(repoPath, gcsBucketName)
+
+	case gcsBucketName != "" && engineMode == EngineModeServer:
+		return ki.initGCSWithServerFn(repoPath, gcsBucketName, defaultAddr)
+
+	case azureContainerName != "" && engineMode == EngineModeBasic:
+		return ki.initAzureFn(repoPath, azureContainerName)
+
+	case azureContainerName != "" && engineMode == EngineModeServer:
+		return ki.initAzureWithServerFn(repoPath, azureContainerName, defaultAddr)
+
+	case engineMode == EngineModeServer:
 		return ki.initFilesystemWithServerFn(repoPath, defaultAddr)
 
 	default:
@@ -82,6 +111,16 @@ func (ki *kopiaConnector) connectOrCreateRepo(repoPath string) error {
 	}
 }
 
+// enableFaultInjection makes all subsequent kopia commands inject storage faults
+// described by the file referenced by FaultInjectionConfigEnvKey, if set.
+// It is called after the repository has been connected, so that creating and
+// connecting to the repository is not subject to faults.
+func (ki *kopiaConnector) enableFaultInjection() {
+	if configFile := os.Getenv(FaultInjectionConfigEnvKey); configFile != "" {
+		ki.snap.Runner.SetEnv("KOPIA_FAULT_INJECTION_CONFIG", configFile)
+	}
+}
+
 // initS3 initializes basic mode with an S3 repository.
 func (ki *kopiaConnector) initS3(repoPath, bucketName string) error {
 	return ki.snap.ConnectOrCreateS3(bucketName, repoPath)
@@ -101,6 +140,34 @@ func (ki *kopiaConnector) initS3WithServer(repoPath, bucketName, addr string) er
 	return err
 }
 
+// initGCS initializes basic mode with a GCS repository.
+func (ki *kopiaConnector) initGCS(repoPath, bucketName string) error {
+	return ki.snap.ConnectOrCreateGCS(bucketName, repoPath)
+}
+
+// initGCSWithServer initializes server mode with a GCS repository.
+func (ki *kopiaConnector) initGCSWithServer(repoPath, bucketName, addr string) error {
+	cmd, fingerprint, err := ki.snap.ConnectOrCreateGCSWithServer(addr, bucketName, repoPath)
+	ki.serverCmd = cmd
+	ki.serverFingerprint = fingerprint
+
+	return err
+}
+
+// initAzure initializes basic mode with an Azure repository.
+func (ki *kopiaConnector) initAzure(repoPath, containerName string) error {
+	return ki.snap.ConnectOrCreateAzure(containerName, repoPath)
+}
+
+// initAzureWithServer initializes server mode with an Azure repository.
+func (ki *kopiaConnector) initAzureWithServer(repoPath, containerName, addr string) error {
+	cmd, fingerprint, err := ki.snap.ConnectOrCreateAzureWithServer(addr, containerName, repoPath)
+	ki.serverCmd = cmd
+	ki.serverFingerprint = fingerprint
+
+	return err
+}
+
 // initFilesystemWithServer initializes server mode with a filesystem repository.
 func (ki *kopiaConnector) initFilesystemWithServer(repoPath, addr string) error {
 	cmd, fingerprint, err := ki.snap.ConnectOrCreateFilesystemWithServer(addr, repoPath)
@@ -124,6 +191,12 @@ func (ki *kopiaConnector) authorizeClient(user string) error {
 	return err
 }
 
-func (ki *kopiaConnector) connectClient(fingerprint, user string) error {
-	return ki.snap.ConnectClient(defaultAddr, fingerprint, user, defaultHost)
+func (ki *kopiaConnector) connectClient(fingerprint, user string, args ...string) error {
+	return ki.snap.ConnectClient(defaultAddr, fingerprint, user, defaultHost, args...)
+}
+
+// ClientUsernameAtHost returns the username@hostname identity used by the
+// given client when connecting to the server.
+func ClientUsernameAtHost(user string) string {
+	return user + "@" + defaultHost
 }
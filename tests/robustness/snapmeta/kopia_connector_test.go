@@ -23,15 +23,26 @@ func TestKopiaConnector(t *testing.T) {
 	assert.NotNil(tc.initS3WithServerFn)
 	assert.NotNil(tc.initFilesystemFn)
 	assert.NotNil(tc.initFilesystemWithServerFn)
+	assert.NotNil(tc.initGCSFn)
+	assert.NotNil(tc.initGCSWithServerFn)
+	assert.NotNil(tc.initAzureFn)
+	assert.NotNil(tc.initAzureWithServerFn)
 
 	tc.initS3Fn = tc.testInitS3
 	tc.initFilesystemFn = tc.testInitFilesystem
 	tc.initS3WithServerFn = tc.testInitS3WithServer
 	tc.initFilesystemWithServerFn = tc.testInitFilesystemWithServer
+	tc.initGCSFn = tc.testInitGCS
+	tc.initGCSWithServerFn = tc.testInitGCSWithServer
+	tc.initAzureFn = tc.testInitAzure
+	tc.initAzureWithServerFn = tc.testInitAzureWithServer
 
 	repoPath := "repoPath"
 	bucketName := "bucketName"
 
+	os.Setenv(GCSBucketNameEnvKey, "")
+	os.Setenv(AzureContainerNameEnvKey, "")
+
 	os.Setenv(EngineModeEnvKey, EngineModeBasic)
 	os.Setenv(S3BucketNameEnvKey, "")
 	tc.reset()
@@ -63,6 +74,46 @@ func TestKopiaConnector(t *testing.T) {
 	assert.Equal(repoPath, tc.tcRepoPath)
 	assert.Equal(bucketName, tc.tcBucketName)
 	assert.Equal(defaultAddr, tc.tcAddr)
+
+	os.Setenv(S3BucketNameEnvKey, "")
+
+	os.Setenv(EngineModeEnvKey, EngineModeBasic)
+	os.Setenv(GCSBucketNameEnvKey, bucketName)
+	tc.reset()
+	assert.NoError(tc.connectOrCreateRepo(repoPath))
+	assert.True(tc.initGCSCalled)
+	assert.Equal(repoPath, tc.tcRepoPath)
+	assert.Equal(bucketName, tc.tcBucketName)
+
+	os.Setenv(EngineModeEnvKey, EngineModeServer)
+	os.Setenv(GCSBucketNameEnvKey, bucketName)
+	tc.reset()
+	assert.NoError(tc.connectOrCreateRepo(repoPath))
+	assert.True(tc.initGCSWithServerCalled)
+	assert.Equal(repoPath, tc.tcRepoPath)
+	assert.Equal(bucketName, tc.tcBucketName)
+	assert.Equal(defaultAddr, tc.tcAddr)
+
+	os.Setenv(GCSBucketNameEnvKey, "")
+
+	os.Setenv(EngineModeEnvKey, EngineModeBasic)
+	os.Setenv(AzureContainerNameEnvKey, bucketName)
+	tc.reset()
+	assert.NoError(tc.connectOrCreateRepo(repoPath))
+	assert.True(tc.initAzureCalled)
+	assert.Equal(repoPath, tc.tcRepoPath)
+	assert.Equal(bucketName, tc.tcBucketName)
+
+	os.Setenv(EngineModeEnvKey, EngineModeServer)
+	os.Setenv(AzureContainerNameEnvKey, bucketName)
+	tc.reset()
+	assert.NoError(tc.connectOrCreateRepo(repoPath))
+	assert.True(tc.initAzureWithServerCalled)
+	assert.Equal(repoPath, tc.tcRepoPath)
+	assert.Equal(bucketName, tc.tcBucketName)
+	assert.Equal(defaultAddr, tc.tcAddr)
+
+	os.Setenv(AzureContainerNameEnvKey, "")
 }
 
 type testConnector struct {
@@ -74,6 +125,10 @@ type testConnector struct {
 	initFilesystemCalled           bool
 	initS3WithServerCalled         bool
 	initFilesystemWithServerCalled bool
+	initGCSCalled                  bool
+	initGCSWithServerCalled        bool
+	initAzureCalled                bool
+	initAzureWithServerCalled      bool
 }
 
 func (tc *testConnector) reset() {
@@ -84,6 +139,10 @@ func (tc *testConnector) reset() {
 	tc.initFilesystemCalled = false
 	tc.initS3WithServerCalled = false
 	tc.initFilesystemWithServerCalled = false
+	tc.initGCSCalled = false
+	tc.initGCSWithServerCalled = false
+	tc.initAzureCalled = false
+	tc.initAzureWithServerCalled = false
 }
 
 func (tc *testConnector) testInitS3(repoPath, bucketName string) error {
@@ -117,3 +176,37 @@ func (tc *testConnector) testInitFilesystemWithServer(repoPath, addr string) err
 
 	return nil
 }
+
+func (tc *testConnector) testInitGCS(repoPath, bucketName string) error {
+	tc.tcRepoPath = repoPath
+	tc.tcBucketName = bucketName
+	tc.initGCSCalled = true
+
+	return nil
+}
+
+func (tc *testConnector) testInitGCSWithServer(repoPath, bucketName, addr string) error {
+	tc.tcRepoPath = repoPath
+	tc.tcBucketName = bucketName
+	tc.tcAddr = addr
+	tc.initGCSWithServerCalled = true
+
+	return nil
+}
+
+func (tc *testConnector) testInitAzure(repoPath, containerName string) error {
+	tc.tcRepoPath = repoPath
+	tc.tcBucketName = containerName
+	tc.initAzureCalled = true
+
+	return nil
+}
+
+func (tc *testConnector) testInitAzureWithServer(repoPath, containerName, addr string) error {
+	tc.tcRepoPath = repoPath
+	tc.tcBucketName = containerName
+	tc.tcAddr = addr
+	tc.initAzureWithServerCalled = true
+
+	return nil
+}
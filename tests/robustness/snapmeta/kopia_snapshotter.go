@@ -8,6 +8,7 @@
 	"log"
 	"os/exec"
 	"strconv"
+	"sync"
 
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/tests/robustness"
@@ -19,6 +20,9 @@
 type KopiaSnapshotter struct {
 	comparer *fswalker.WalkCompare
 	kopiaConnector
+
+	aclMu      sync.Mutex // protects aclEnabled
+	aclEnabled bool
 }
 
 // KopiaSnapshotter implements robustness.Snapshotter.
@@ -44,15 +48,21 @@ func (ks *KopiaSnapshotter) ConnectOrCreateRepo(repoPath string) error {
 		return err
 	}
 
-	_, _, err := ks.snap.Run("policy", "set", "--global", "--keep-latest", strconv.Itoa(1<<31-1), "--compression", "s2-default")
+	if _, _, err := ks.snap.Run("policy", "set", "--global", "--keep-latest", strconv.Itoa(1<<31-1), "--compression", "s2-default"); err != nil {
+		return err
+	}
+
+	ks.enableFaultInjection()
 
-	return err
+	return nil
 }
 
 // ConnectClient should be called by a client to connect itself to the server
 // using the given cert fingerprint.
-func (ks *KopiaSnapshotter) ConnectClient(fingerprint, user string) error {
-	return ks.connectClient(fingerprint, user)
+// Additional arguments, such as TLS client certificate flags, are passed to
+// 'repo connect server'.
+func (ks *KopiaSnapshotter) ConnectClient(fingerprint, user string, args ...string) error {
+	return ks.connectClient(fingerprint, user, args...)
 }
 
 // DisconnectClient should be called by a client to disconnect itself from the server.
@@ -68,8 +78,49 @@ func (ks *KopiaSnapshotter) AuthorizeClient(user string) error {
 	return ks.authorizeClient(user)
 }
 
-// RemoveClient should be called by a server to remove a client from its user list.
+// AddClientACL should be called by a server to grant a client access to the
+// manifests matching the target, before the client is authorized.
+// ACLs are enabled with default entries first, so that other clients retain
+// their access.
+func (ks *KopiaSnapshotter) AddClientACL(user, target, access string) error {
+	if err := ks.enableACLs(); err != nil {
+		return err
+	}
+
+	return ks.snap.AddACL(ClientUsernameAtHost(user), target, access)
+}
+
+func (ks *KopiaSnapshotter) enableACLs() error {
+	ks.aclMu.Lock()
+	defer ks.aclMu.Unlock()
+
+	if ks.aclEnabled {
+		return nil
+	}
+
+	if err := ks.snap.EnableACLs(); err != nil {
+		return err
+	}
+
+	ks.aclEnabled = true
+
+	return nil
+}
+
+// RequireClientCertificates makes the server require TLS client certificates
+// issued by the CA in the provided PEM file. It must be called before
+// ConnectOrCreateRepo.
+func (ks *KopiaSnapshotter) RequireClientCertificates(caFile string) {
+	ks.snap.ServerArgs = append(ks.snap.ServerArgs, "--tls-client-ca-file", caFile)
+}
+
+// RemoveClient should be called by a server to remove a client from its user
+// list along with its ACL entries.
 func (ks *KopiaSnapshotter) RemoveClient(user string) {
+	if err := ks.snap.DeleteACLs(ClientUsernameAtHost(user)); err != nil {
+		log.Printf("Error removing ACL entries of %s from server: %v\n", user, err)
+	}
+
 	if err := ks.snap.RemoveClient(user, defaultHost); err != nil {
 		log.Printf("Error removing %s from server: %v\n", user, err)
 	}
@@ -0,0 +1,229 @@
+// +build darwin,amd64 linux,amd64
+
+package snapmeta
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 database/sql driver
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/tests/robustness"
+)
+
+const (
+	// SQLiteDriverName is the default name of the database/sql driver used by
+	// SQLitePersister, registered by github.com/mattn/go-sqlite3.
+	SQLiteDriverName = "sqlite3"
+
+	sqliteMetadataFileName = "metadata.sqlite"
+)
+
+// SQLitePersister implements robustness.Persister by keeping metadata in a
+// local SQLite database. Unlike KopiaPersister, metadata is not held in
+// memory, which keeps memory usage bounded for engines tracking very many
+// snapshots. Changes are accumulated in a transaction that is committed
+// by FlushMetadata.
+// The database file survives Cleanup, so metadata can be loaded again by
+// a later run using the same directory.
+type SQLitePersister struct {
+	driverName string
+	dbPath     string
+
+	// persistenceDir holds the database file as well as logs and other
+	// files that are written to the directory returned by GetPersistDir.
+	persistenceDir string
+
+	mu sync.Mutex
+	db *sql.DB
+	tx *sql.Tx
+}
+
+var _ robustness.Persister = (*SQLitePersister)(nil)
+
+// NewSQLitePersister returns a Persister that keeps its metadata in a SQLite
+// database in the provided directory, which is created if needed. The
+// database/sql driver registered with the given name, or SQLiteDriverName
+// if empty, is used to open the database.
+func NewSQLitePersister(driverName, persistenceDir string) (*SQLitePersister, error) {
+	if driverName == "" {
+		driverName = SQLiteDriverName
+	}
+
+	if err := os.MkdirAll(persistenceDir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "unable to create persistence directory")
+	}
+
+	return &SQLitePersister{
+		driverName:     driverName,
+		dbPath:         filepath.Join(persistenceDir, sqliteMetadataFileName),
+		persistenceDir: persistenceDir,
+	}, nil
+}
+
+// NewSQLitePersisterInTempDir returns a SQLitePersister using a new temporary
+// directory under baseDir.
+func NewSQLitePersisterInTempDir(driverName, baseDir string) (*SQLitePersister, error) {
+	dir, err := ioutil.TempDir(baseDir, "kopia-sqlite-metadata-")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create temporary directory")
+	}
+
+	return NewSQLitePersister(driverName, dir)
+}
+
+// LoadMetadata implements the Persister interface, it opens the database and
+// creates the metadata table if it does not exist yet.
+func (store *SQLitePersister) LoadMetadata() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.db != nil {
+		return nil
+	}
+
+	db, err := sql.Open(store.driverName, store.dbPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open metadata database %v", store.dbPath)
+	}
+
+	// SQLite allows a single writer, use a single connection for the
+	// long-lived transaction.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS metadata (key TEXT PRIMARY KEY, value BLOB NOT NULL)`); err != nil {
+		db.Close() //nolint:errcheck
+
+		return errors.Wrap(err, "unable to create metadata table")
+	}
+
+	store.db = db
+
+	return nil
+}
+
+// currentTx returns the transaction accumulating changes, starting one
+// if needed. It must be called while holding the lock.
+func (store *SQLitePersister) currentTx() (*sql.Tx, error) {
+	if store.db == nil {
+		return nil, errors.New("metadata database is not loaded")
+	}
+
+	if store.tx == nil {
+		tx, err := store.db.Begin()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to begin transaction")
+		}
+
+		store.tx = tx
+	}
+
+	return store.tx, nil
+}
+
+// Store implements the Storer interface Store method.
+func (store *SQLitePersister) Store(key string, val []byte) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tx, err := store.currentTx()
+	if err != nil {
+		return err
+	}
+
+	if val == nil {
+		val = []byte{}
+	}
+
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO metadata (key, value) VALUES (?, ?)`, key, val); err != nil {
+		return errors.Wrapf(err, "unable to store key %q", key)
+	}
+
+	return nil
+}
+
+// Load implements the Storer interface Load method.
+func (store *SQLitePersister) Load(key string) ([]byte, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tx, err := store.currentTx()
+	if err != nil {
+		return nil, err
+	}
+
+	var val []byte
+
+	err = tx.QueryRow(`SELECT value FROM metadata WHERE key = ?`, key).Scan(&val)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, robustness.ErrKeyNotFound
+	case err != nil:
+		return nil, errors.Wrapf(err, "unable to load key %q", key)
+	}
+
+	return val, nil
+}
+
+// Delete implements the Storer interface Delete method.
+func (store *SQLitePersister) Delete(key string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tx, err := store.currentTx()
+	if err != nil {
+		log.Printf("Error deleting key %q: %v\n", key, err)
+		return
+	}
+
+	if _, err := tx.Exec(`DELETE FROM metadata WHERE key = ?`, key); err != nil {
+		log.Printf("Error deleting key %q: %v\n", key, err)
+	}
+}
+
+// FlushMetadata implements the Persister interface, committing the changes
+// made since the last flush to the database.
+func (store *SQLitePersister) FlushMetadata() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.tx == nil {
+		return nil
+	}
+
+	tx := store.tx
+	store.tx = nil
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "unable to commit metadata")
+	}
+
+	return nil
+}
+
+// GetPersistDir returns the path to the directory holding the database.
+func (store *SQLitePersister) GetPersistDir() string {
+	return store.persistenceDir
+}
+
+// Cleanup closes the database, discarding changes that were not flushed.
+func (store *SQLitePersister) Cleanup() {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.tx != nil {
+		store.tx.Rollback() //nolint:errcheck
+		store.tx = nil
+	}
+
+	if store.db != nil {
+		store.db.Close() //nolint:errcheck
+		store.db = nil
+	}
+}
@@ -0,0 +1,89 @@
+// +build darwin,amd64 linux,amd64
+
+package snapmeta
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/kopia/kopia/tests/robustness"
+)
+
+func TestSQLitePersisterMissingDriver(t *testing.T) {
+	store, err := NewSQLitePersister("no-such-driver", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer store.Cleanup()
+
+	if err := store.LoadMetadata(); err == nil {
+		t.Fatal("expected error loading metadata")
+	}
+
+	if err := store.Store("key", []byte("val")); err == nil {
+		t.Fatal("expected error storing before metadata is loaded")
+	}
+}
+
+func TestSQLitePersister(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewSQLitePersister("", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.LoadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.Load("key"); !errors.Is(err, robustness.ErrKeyNotFound) {
+		t.Fatalf("Did not get expected error: %q", err)
+	}
+
+	for key, val := range map[string]string{"flushed": "a", "deleted": "b", "unflushed": "c"} {
+		if err = store.Store(key, []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store.Delete("deleted")
+
+	if err = store.FlushMetadata(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Store("unflushed", []byte("d")); err != nil {
+		t.Fatal(err)
+	}
+
+	store.Cleanup()
+
+	store, err = NewSQLitePersister("", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer store.Cleanup()
+
+	if err = store.LoadMetadata(); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]string{"flushed": "a", "unflushed": "c"} {
+		got, err := store.Load(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, []byte(want)) {
+			t.Errorf("unexpected value of %v: %q, want %q", key, got, want)
+		}
+	}
+
+	if _, err = store.Load("deleted"); !errors.Is(err, robustness.ErrKeyNotFound) {
+		t.Fatalf("Did not get expected error: %q", err)
+	}
+}
@@ -0,0 +1,128 @@
+package soak_test
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/tests/testdirtree"
+)
+
+// churnOptions determines how much of the source tree changes between snapshots.
+type churnOptions struct {
+	ModifyPercent int // percentage of existing files modified in each round
+	DeletePercent int // percentage of existing files deleted in each round
+	NewDirs       int // number of new directory trees created in each round
+	MaxFileSize   int
+}
+
+// churner continuously mutates a directory tree to simulate a live filesystem being snapshotted.
+type churner struct {
+	root string
+	opt  churnOptions
+	rnd  *rand.Rand
+}
+
+func newChurner(root string, opt churnOptions, seed int64) *churner {
+	return &churner{
+		root: root,
+		opt:  opt,
+		rnd:  rand.New(rand.NewSource(seed)), //nolint:gosec
+	}
+}
+
+// populate creates the initial directory tree.
+func (c *churner) populate() error {
+	err := testdirtree.CreateDirectoryTree(c.root, testdirtree.DirectoryTreeOptions{
+		Depth:                  3,
+		MaxSubdirsPerDirectory: 5,
+		MaxFilesPerDirectory:   10,
+		MaxFileSize:            c.opt.MaxFileSize,
+	}, &testdirtree.DirectoryTreeCounters{})
+
+	return errors.Wrap(err, "unable to create directory tree")
+}
+
+// churn performs a single round of modifications, deletions and additions.
+func (c *churner) churn() error {
+	files, err := c.listFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		switch n := c.rnd.Intn(100); { //nolint:gomnd
+		case n < c.opt.DeletePercent:
+			if err := os.Remove(f); err != nil {
+				return errors.Wrap(err, "unable to delete file")
+			}
+
+		case n < c.opt.DeletePercent+c.opt.ModifyPercent:
+			if err := c.modifyFile(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := 0; i < c.opt.NewDirs; i++ {
+		if err := testdirtree.CreateDirectoryTree(filepath.Join(c.root, "churn"), testdirtree.DirectoryTreeOptions{
+			Depth:                  1,
+			MaxSubdirsPerDirectory: 3,
+			MaxFilesPerDirectory:   5,
+			MaxFileSize:            c.opt.MaxFileSize,
+		}, &testdirtree.DirectoryTreeCounters{}); err != nil {
+			return errors.Wrap(err, "unable to create directory tree")
+		}
+	}
+
+	return nil
+}
+
+// modifyFile overwrites a random range of the file with random data, possibly extending it.
+func (c *churner) modifyFile(fname string) error {
+	f, err := os.OpenFile(fname, os.O_RDWR, 0)
+	if err != nil {
+		return errors.Wrap(err, "unable to open file")
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	st, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "unable to stat file")
+	}
+
+	offset := int64(0)
+	if st.Size() > 0 {
+		offset = c.rnd.Int63n(st.Size())
+	}
+
+	data := make([]byte, c.rnd.Intn(c.opt.MaxFileSize/10+1)+1) //nolint:gomnd
+	c.rnd.Read(data)
+
+	if _, err := f.WriteAt(data, offset); err != nil {
+		return errors.Wrap(err, "unable to write file")
+	}
+
+	return nil
+}
+
+func (c *churner) listFiles() ([]string, error) {
+	var result []string
+
+	err := filepath.Walk(c.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			result = append(result, path)
+		}
+
+		return nil
+	})
+
+	return result, errors.Wrap(err, "unable to list files")
+}
@@ -0,0 +1,10 @@
+// +build !linux,!darwin,!freebsd,!openbsd,!netbsd
+
+package soak_test
+
+import "time"
+
+// processCPUTime is not supported on this platform, CPU time is always reported as zero.
+func processCPUTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,19 @@
+// +build linux darwin freebsd openbsd netbsd
+
+package soak_test
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime returns the total user and system CPU time consumed by the current process.
+func processCPUTime() time.Duration {
+	var ru syscall.Rusage
+
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
@@ -0,0 +1,177 @@
+package soak_test
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// sample is a single point of the time series collected during the soak test, recorded after each snapshot.
+type sample struct {
+	Iteration        int
+	Elapsed          time.Duration
+	SnapshotDuration time.Duration
+	SourceBytes      int64
+	HeapAllocBytes   uint64
+	HeapObjects      uint64
+	Goroutines       int
+	CPUTime          time.Duration
+}
+
+// BytesPerSecond returns the snapshot throughput.
+func (s sample) BytesPerSecond() float64 {
+	if s.SnapshotDuration <= 0 {
+		return 0
+	}
+
+	return float64(s.SourceBytes) / s.SnapshotDuration.Seconds()
+}
+
+var sampleCSVHeader = []string{
+	"iteration",
+	"elapsedSec",
+	"snapshotSec",
+	"sourceBytes",
+	"bytesPerSec",
+	"heapAllocBytes",
+	"heapObjects",
+	"goroutines",
+	"cpuSec",
+}
+
+func (s sample) csvRecord() []string {
+	return []string{
+		strconv.Itoa(s.Iteration),
+		fmt.Sprintf("%.3f", s.Elapsed.Seconds()),
+		fmt.Sprintf("%.3f", s.SnapshotDuration.Seconds()),
+		strconv.FormatInt(s.SourceBytes, 10),
+		fmt.Sprintf("%.0f", s.BytesPerSecond()),
+		strconv.FormatUint(s.HeapAllocBytes, 10),
+		strconv.FormatUint(s.HeapObjects, 10),
+		strconv.Itoa(s.Goroutines),
+		fmt.Sprintf("%.3f", s.CPUTime.Seconds()),
+	}
+}
+
+// collectRuntimeStats fills in memory, goroutine and CPU statistics of the current process.
+// Garbage collection is forced first so that heap size reflects live objects only.
+func collectRuntimeStats(s *sample) {
+	var ms runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+
+	s.HeapAllocBytes = ms.HeapAlloc
+	s.HeapObjects = ms.HeapObjects
+	s.Goroutines = runtime.NumGoroutine()
+	s.CPUTime = processCPUTime()
+}
+
+// sampleWriter writes samples as CSV, flushing after each one so that results
+// of a multi-day run can be inspected while it is in progress.
+type sampleWriter struct {
+	w *csv.Writer
+}
+
+func newSampleWriter(w io.Writer) (*sampleWriter, error) {
+	sw := &sampleWriter{csv.NewWriter(w)}
+
+	return sw, sw.write(sampleCSVHeader)
+}
+
+func (sw *sampleWriter) add(s sample) error {
+	return sw.write(s.csvRecord())
+}
+
+func (sw *sampleWriter) write(rec []string) error {
+	if err := sw.w.Write(rec); err != nil {
+		return errors.Wrap(err, "error writing sample")
+	}
+
+	sw.w.Flush()
+
+	return errors.Wrap(sw.w.Error(), "error flushing samples")
+}
+
+// thresholds determines when the soak test is considered to have failed.
+type thresholds struct {
+	MaxHeapGrowthPercent      float64 // maximum growth of the median heap size between baseline and final window
+	MaxGoroutineGrowth        int     // maximum growth of the median number of goroutines
+	MaxThroughputDropPercent  float64 // maximum drop of the median throughput between baseline and final window
+	MinSamplesForVerification int     // minimum number of samples required to verify thresholds
+}
+
+// analyzeSamples compares the baseline window (collected after warm-up) with the final window
+// of samples and returns the list of threshold violations.
+//
+// The samples are split into windows of 10% of the run each, the first window is treated as warm-up
+// and the second one as the baseline, since caches and indexes are still being populated at the start.
+func analyzeSamples(samples []sample, th thresholds) (violations []string, ok bool) {
+	if len(samples) < th.MinSamplesForVerification {
+		return nil, false
+	}
+
+	window := len(samples) / 10 //nolint:gomnd
+	if window < 1 {
+		window = 1
+	}
+
+	baseline := samples[window : 2*window]
+	final := samples[len(samples)-window:]
+
+	baseHeap := median(baseline, func(s sample) float64 { return float64(s.HeapAllocBytes) })
+	finalHeap := median(final, func(s sample) float64 { return float64(s.HeapAllocBytes) })
+
+	if growth := percentChange(baseHeap, finalHeap); growth > th.MaxHeapGrowthPercent {
+		violations = append(violations, fmt.Sprintf("heap grew by %.1f%% (%.0f -> %.0f bytes), max allowed %v%%", growth, baseHeap, finalHeap, th.MaxHeapGrowthPercent))
+	}
+
+	baseGoroutines := median(baseline, func(s sample) float64 { return float64(s.Goroutines) })
+	finalGoroutines := median(final, func(s sample) float64 { return float64(s.Goroutines) })
+
+	if growth := finalGoroutines - baseGoroutines; growth > float64(th.MaxGoroutineGrowth) {
+		violations = append(violations, fmt.Sprintf("number of goroutines grew by %.0f (%.0f -> %.0f), max allowed %v", growth, baseGoroutines, finalGoroutines, th.MaxGoroutineGrowth))
+	}
+
+	baseThroughput := median(baseline, sample.BytesPerSecond)
+	finalThroughput := median(final, sample.BytesPerSecond)
+
+	if drop := -percentChange(baseThroughput, finalThroughput); drop > th.MaxThroughputDropPercent {
+		violations = append(violations, fmt.Sprintf("throughput dropped by %.1f%% (%.0f -> %.0f bytes/s), max allowed %v%%", drop, baseThroughput, finalThroughput, th.MaxThroughputDropPercent))
+	}
+
+	return violations, true
+}
+
+func median(samples []sample, value func(s sample) float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	values := make([]float64, len(samples))
+	for i, s := range samples {
+		values[i] = value(s)
+	}
+
+	sort.Float64s(values)
+
+	if len(values)%2 == 1 {
+		return values[len(values)/2]
+	}
+
+	return (values[len(values)/2-1] + values[len(values)/2]) / 2 //nolint:gomnd
+}
+
+func percentChange(base, current float64) float64 {
+	if base == 0 {
+		return 0
+	}
+
+	return 100 * (current - base) / base //nolint:gomnd
+}
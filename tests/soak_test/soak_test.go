@@ -0,0 +1,390 @@
+// Package soak_test implements a long-running test which continuously snapshots a changing
+// directory tree for hours or days, collecting memory, CPU and throughput time series and
+// failing when resource usage grows or throughput degrades beyond configured thresholds.
+//
+// The test is skipped unless KOPIA_SOAK_TEST_DURATION is set (for example to '72h').
+// Other environment variables:
+//
+//	KOPIA_SOAK_TEST_STORAGE              - JSON file with storage connection info ({"type":...,"config":{...}}),
+//	                                       by default the repository is created in a local temporary directory
+//	KOPIA_SOAK_TEST_OUTPUT_DIR           - directory where metrics.csv is written
+//	KOPIA_SOAK_TEST_MAX_HEAP_GROWTH      - maximum allowed heap growth in percent (default 50)
+//	KOPIA_SOAK_TEST_MAX_GOROUTINE_GROWTH - maximum allowed growth of the number of goroutines (default 20)
+//	KOPIA_SOAK_TEST_MAX_THROUGHPUT_DROP  - maximum allowed throughput drop in percent (default 30)
+package soak_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/snapshot/snapshotmaintenance"
+
+	// register storage providers that can be selected using KOPIA_SOAK_TEST_STORAGE.
+	_ "github.com/kopia/kopia/repo/blob/azure"
+	_ "github.com/kopia/kopia/repo/blob/b2"
+	_ "github.com/kopia/kopia/repo/blob/gcs"
+	_ "github.com/kopia/kopia/repo/blob/rclone"
+	_ "github.com/kopia/kopia/repo/blob/s3"
+	_ "github.com/kopia/kopia/repo/blob/sftp"
+	_ "github.com/kopia/kopia/repo/blob/webdav"
+)
+
+const (
+	masterPassword = "soak-test-password"
+
+	// run maintenance (as scheduled) after this many snapshots.
+	maintenanceEveryIterations = 10
+
+	// pause between snapshots.
+	snapshotInterval = 10 * time.Second
+)
+
+var defaultThresholds = thresholds{
+	MaxHeapGrowthPercent:      50,
+	MaxGoroutineGrowth:        20,
+	MaxThroughputDropPercent:  30,
+	MinSamplesForVerification: 20,
+}
+
+var soakChurnOptions = churnOptions{
+	ModifyPercent: 5,
+	DeletePercent: 1,
+	NewDirs:       1,
+	MaxFileSize:   1 << 20,
+}
+
+func TestSoak(t *testing.T) {
+	d := os.Getenv("KOPIA_SOAK_TEST_DURATION")
+	if d == "" {
+		t.Skip("KOPIA_SOAK_TEST_DURATION not set")
+	}
+
+	duration, err := time.ParseDuration(d)
+	require.NoError(t, err)
+
+	th := thresholdsFromEnvironment(t)
+
+	// only log errors, since verbose logs from days of snapshots would distort memory usage of the test process.
+	ctx := testlogging.ContextWithLevel(t, testlogging.LevelError)
+
+	tmpDir, err := ioutil.TempDir("", "kopia-soak")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmpDir)
+
+	rep := connectAndOpen(ctx, t, tmpDir)
+
+	defer rep.Close(ctx) //nolint:errcheck
+
+	outputDir := os.Getenv("KOPIA_SOAK_TEST_OUTPUT_DIR")
+	if outputDir == "" {
+		outputDir = filepath.Join(os.TempDir(), "kopia-soak-"+clock.Now().Local().Format("20060102150405"))
+	}
+
+	require.NoError(t, os.MkdirAll(outputDir, 0o700))
+
+	f, err := os.Create(filepath.Join(outputDir, "metrics.csv"))
+	require.NoError(t, err)
+
+	defer f.Close() //nolint:errcheck
+
+	t.Logf("writing metrics to %v", f.Name())
+
+	sw, err := newSampleWriter(f)
+	require.NoError(t, err)
+
+	sourceDir := filepath.Join(tmpDir, "source")
+	ch := newChurner(sourceDir, soakChurnOptions, clock.Now().UnixNano())
+	require.NoError(t, ch.populate())
+
+	var (
+		samples  []sample
+		previous *snapshot.Manifest
+	)
+
+	start := clock.Now()
+	deadline := start.Add(duration)
+
+	for i := 0; clock.Now().Before(deadline); i++ {
+		if i > 0 {
+			require.NoError(t, ch.churn())
+		}
+
+		t0 := clock.Now()
+		man, err := snapshotOnce(ctx, rep, sourceDir, previous)
+		require.NoError(t, err)
+
+		previous = man
+
+		s := sample{
+			Iteration:        i,
+			Elapsed:          clock.Now().Sub(start),
+			SnapshotDuration: clock.Now().Sub(t0),
+			SourceBytes:      man.Stats.TotalFileSize,
+		}
+
+		collectRuntimeStats(&s)
+
+		samples = append(samples, s)
+		require.NoError(t, sw.add(s))
+
+		t.Logf("iteration %v: %v bytes in %v, heap %v, goroutines %v, cpu %v",
+			i, s.SourceBytes, s.SnapshotDuration, s.HeapAllocBytes, s.Goroutines, s.CPUTime)
+
+		if i%maintenanceEveryIterations == maintenanceEveryIterations-1 {
+			require.NoError(t, runMaintenance(ctx, rep))
+		}
+
+		time.Sleep(snapshotInterval)
+	}
+
+	violations, ok := analyzeSamples(samples, th)
+	if !ok {
+		t.Logf("not enough samples to verify thresholds (%v, need %v)", len(samples), th.MinSamplesForVerification)
+		return
+	}
+
+	for _, v := range violations {
+		t.Errorf("soak test threshold exceeded: %v", v)
+	}
+}
+
+func thresholdsFromEnvironment(t *testing.T) thresholds {
+	t.Helper()
+
+	th := defaultThresholds
+
+	if v := os.Getenv("KOPIA_SOAK_TEST_MAX_HEAP_GROWTH"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		require.NoError(t, err)
+
+		th.MaxHeapGrowthPercent = f
+	}
+
+	if v := os.Getenv("KOPIA_SOAK_TEST_MAX_GOROUTINE_GROWTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		require.NoError(t, err)
+
+		th.MaxGoroutineGrowth = n
+	}
+
+	if v := os.Getenv("KOPIA_SOAK_TEST_MAX_THROUGHPUT_DROP"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		require.NoError(t, err)
+
+		th.MaxThroughputDropPercent = f
+	}
+
+	return th
+}
+
+// connectAndOpen initializes (unless already initialized) and opens the repository in the storage
+// selected by KOPIA_SOAK_TEST_STORAGE. The repository stays open for the entire test,
+// as it would in a long-running server.
+func connectAndOpen(ctx context.Context, t *testing.T, tmpDir string) repo.DirectRepository {
+	t.Helper()
+
+	st, err := soakStorage(ctx, tmpDir)
+	require.NoError(t, err)
+
+	if err = repo.Initialize(ctx, st, &repo.NewRepositoryOptions{}, masterPassword); err != nil && !errors.Is(err, repo.ErrAlreadyInitialized) {
+		t.Fatalf("unable to initialize repository: %v", err)
+	}
+
+	configFile := filepath.Join(tmpDir, "kopia.config")
+
+	require.NoError(t, repo.Connect(ctx, configFile, st, masterPassword, &repo.ConnectOptions{
+		CachingOptions: content.CachingOptions{
+			CacheDirectory:    filepath.Join(tmpDir, "cache"),
+			MaxCacheSizeBytes: 500 << 20,
+		},
+	}))
+
+	r, err := repo.Open(ctx, configFile, masterPassword, &repo.Options{})
+	require.NoError(t, err)
+
+	dr, ok := r.(repo.DirectRepository)
+	require.True(t, ok, "not a direct repository")
+
+	require.NoError(t, repo.WriteSession(ctx, dr, repo.WriteSessionOptions{
+		Purpose: "soak-test-setup",
+	}, func(w repo.RepositoryWriter) error {
+		p := maintenance.DefaultParams()
+		p.Owner = w.ClientOptions().UsernameAtHost()
+
+		// nolint:wrapcheck
+		return maintenance.SetParams(ctx, w, &p)
+	}))
+
+	return dr
+}
+
+func soakStorage(ctx context.Context, tmpDir string) (blob.Storage, error) {
+	fname := os.Getenv("KOPIA_SOAK_TEST_STORAGE")
+	if fname == "" {
+		storageDir := filepath.Join(tmpDir, "storage")
+		if err := os.MkdirAll(storageDir, 0o700); err != nil {
+			return nil, errors.Wrap(err, "unable to create storage directory")
+		}
+
+		// nolint:wrapcheck
+		return filesystem.New(ctx, &filesystem.Options{
+			Path: storageDir,
+		})
+	}
+
+	b, err := ioutil.ReadFile(fname) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read storage configuration")
+	}
+
+	var ci blob.ConnectionInfo
+	if err := json.Unmarshal(b, &ci); err != nil {
+		return nil, errors.Wrap(err, "invalid storage configuration")
+	}
+
+	// nolint:wrapcheck
+	return blob.NewStorage(ctx, ci)
+}
+
+// snapshotOnce snapshots the provided directory using the previous manifest to benefit from hash cache.
+func snapshotOnce(ctx context.Context, rep repo.Repository, dir string, previous *snapshot.Manifest) (*snapshot.Manifest, error) {
+	source, err := localfs.NewEntry(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get source entry")
+	}
+
+	sourceInfo := snapshot.SourceInfo{
+		Host:     rep.ClientOptions().Hostname,
+		UserName: rep.ClientOptions().Username,
+		Path:     dir,
+	}
+
+	var man *snapshot.Manifest
+
+	err = repo.WriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: "soak-test-snapshot",
+	}, func(w repo.RepositoryWriter) error {
+		policyTree, err := policy.TreeForSource(ctx, w, sourceInfo)
+		if err != nil {
+			return errors.Wrap(err, "unable to get policy tree")
+		}
+
+		var previousManifests []*snapshot.Manifest
+		if previous != nil {
+			previousManifests = append(previousManifests, previous)
+		}
+
+		man, err = snapshotfs.NewUploader(w).Upload(ctx, source, policyTree, sourceInfo, previousManifests...)
+		if err != nil {
+			return errors.Wrap(err, "upload error")
+		}
+
+		if _, err := snapshot.SaveSnapshot(ctx, w, man); err != nil {
+			return errors.Wrap(err, "unable to save snapshot")
+		}
+
+		return nil
+	})
+
+	// nolint:wrapcheck
+	return man, err
+}
+
+// runMaintenance runs quick or full maintenance, as determined by the maintenance schedule.
+func runMaintenance(ctx context.Context, rep repo.DirectRepository) error {
+	// nolint:wrapcheck
+	return repo.DirectWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: "soak-test-maintenance",
+	}, func(dw repo.DirectRepositoryWriter) error {
+		return snapshotmaintenance.Run(ctx, dw, maintenance.ModeAuto, false, maintenance.SafetyFull)
+	})
+}
+
+func TestAnalyzeSamples(t *testing.T) {
+	th := thresholds{
+		MaxHeapGrowthPercent:      50,
+		MaxGoroutineGrowth:        10,
+		MaxThroughputDropPercent:  30,
+		MinSamplesForVerification: 20,
+	}
+
+	makeSamples := func(n int, f func(i int, s *sample)) []sample {
+		var result []sample
+
+		for i := 0; i < n; i++ {
+			s := sample{
+				Iteration:        i,
+				SnapshotDuration: time.Second,
+				SourceBytes:      1000,
+				HeapAllocBytes:   1000000,
+				Goroutines:       50,
+			}
+
+			f(i, &s)
+
+			result = append(result, s)
+		}
+
+		return result
+	}
+
+	// not enough samples.
+	_, ok := analyzeSamples(makeSamples(10, func(i int, s *sample) {}), th)
+	require.False(t, ok)
+
+	// stable run.
+	violations, ok := analyzeSamples(makeSamples(100, func(i int, s *sample) {}), th)
+	require.True(t, ok)
+	require.Empty(t, violations)
+
+	// high usage during warm-up is ignored.
+	violations, _ = analyzeSamples(makeSamples(100, func(i int, s *sample) {
+		if i < 10 {
+			s.HeapAllocBytes = 100
+			s.SnapshotDuration = time.Millisecond
+		}
+	}), th)
+	require.Empty(t, violations)
+
+	// steady memory growth.
+	violations, _ = analyzeSamples(makeSamples(100, func(i int, s *sample) {
+		s.HeapAllocBytes += uint64(i) * 20000
+	}), th)
+	require.Len(t, violations, 1)
+	require.Contains(t, violations[0], "heap grew")
+
+	// goroutine leak.
+	violations, _ = analyzeSamples(makeSamples(100, func(i int, s *sample) {
+		s.Goroutines += i
+	}), th)
+	require.Len(t, violations, 1)
+	require.Contains(t, violations[0], "goroutines")
+
+	// throughput degradation.
+	violations, _ = analyzeSamples(makeSamples(100, func(i int, s *sample) {
+		s.SnapshotDuration += time.Duration(i) * 20 * time.Millisecond
+	}), th)
+	require.Len(t, violations, 1)
+	require.Contains(t, violations[0], "throughput dropped")
+}
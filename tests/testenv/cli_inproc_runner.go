@@ -11,7 +11,10 @@
 )
 
 // CLIInProcRunner is a CLIRunner that invokes provided commands in the current process.
-type CLIInProcRunner struct{}
+type CLIInProcRunner struct {
+	// Password overrides TestRepoPassword passed to all commands, when set.
+	Password string
+}
 
 // Start implements CLIRunner.
 func (e *CLIInProcRunner) Start(t *testing.T, args []string) (stdout, stderr io.Reader, wait func() error, kill func()) {
@@ -22,8 +25,13 @@ func (e *CLIInProcRunner) Start(t *testing.T, args []string) (stdout, stderr io.
 	a := cli.NewApp()
 	a.AdvancedCommands = "enabled"
 
+	password := TestRepoPassword
+	if e.Password != "" {
+		password = e.Password
+	}
+
 	return a.RunSubcommand(ctx, append([]string{
-		"--password", TestRepoPassword,
+		"--password", password,
 	}, args...))
 }
 
@@ -143,16 +143,42 @@ func (e *CLITest) RunAndProcessStderr(t *testing.T, callback func(line string) b
 		}
 	}
 
-	// complete the scan in background without processing lines.
+	// complete the scan in background without processing lines,
+	// stop logging when the test completes since the command may outlive it.
+	var (
+		mu       sync.Mutex
+		testDone bool
+	)
+
+	t.Cleanup(func() {
+		mu.Lock()
+		testDone = true
+		mu.Unlock()
+	})
+
 	go func() {
 		for scanner.Scan() {
-			t.Logf("[stderr] %v", scanner.Text())
+			mu.Lock()
+			if !testDone {
+				t.Logf("[stderr] %v", scanner.Text())
+			}
+			mu.Unlock()
 		}
 	}()
 
 	return kill
 }
 
+// Start starts the given command without waiting for it to complete and returns its output readers
+// along with functions to wait for completion and to kill it.
+func (e *CLITest) Start(t *testing.T, args ...string) (stdout, stderr io.Reader, wait func() error, kill func()) {
+	t.Helper()
+
+	t.Logf("starting 'kopia %v'", strings.Join(args, " "))
+
+	return e.Runner.Start(t, e.cmdArgs(args))
+}
+
 // RunAndExpectSuccessWithErrOut runs the given command, expects it to succeed and returns its stdout and stderr lines.
 func (e *CLITest) RunAndExpectSuccessWithErrOut(t *testing.T, args ...string) (stdout, stderr []string) {
 	t.Helper()
@@ -5,6 +5,7 @@
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
@@ -44,6 +45,9 @@
 // KopiaSnapshotter implements the Snapshotter interface using Kopia commands.
 type KopiaSnapshotter struct {
 	Runner *Runner
+
+	// ServerArgs are additional arguments passed to 'server start'.
+	ServerArgs []string
 }
 
 // NewKopiaSnapshotter instantiates a new KopiaSnapshotter and returns its pointer.
@@ -115,6 +119,38 @@ func (ks *KopiaSnapshotter) ConnectOrCreateS3WithServer(serverAddr, bucketName,
 	return ks.ConnectOrCreateRepoWithServer(serverAddr, repoArgs...)
 }
 
+// ConnectOrCreateGCS attempts to connect to a kopia repo in the GCS bucket identified
+// by the provided bucketName, at the provided path prefix. It will attempt to
+// create one there if connection was unsuccessful. Credentials are taken from
+// the default application credentials.
+func (ks *KopiaSnapshotter) ConnectOrCreateGCS(bucketName, pathPrefix string) error {
+	args := []string{"gcs", "--bucket", bucketName, "--prefix", pathPrefix}
+
+	return ks.ConnectOrCreateRepo(args...)
+}
+
+// ConnectOrCreateGCSWithServer attempts to connect or create GCS bucket, but with TLS client/server Model.
+func (ks *KopiaSnapshotter) ConnectOrCreateGCSWithServer(serverAddr, bucketName, pathPrefix string) (*exec.Cmd, string, error) {
+	repoArgs := []string{"gcs", "--bucket", bucketName, "--prefix", pathPrefix}
+	return ks.ConnectOrCreateRepoWithServer(serverAddr, repoArgs...)
+}
+
+// ConnectOrCreateAzure attempts to connect to a kopia repo in the Azure blob container
+// identified by the provided containerName, at the provided path prefix. It will attempt to
+// create one there if connection was unsuccessful. The storage account and key are taken
+// from AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY environment variables.
+func (ks *KopiaSnapshotter) ConnectOrCreateAzure(containerName, pathPrefix string) error {
+	args := []string{"azure", "--container", containerName, "--prefix", pathPrefix}
+
+	return ks.ConnectOrCreateRepo(args...)
+}
+
+// ConnectOrCreateAzureWithServer attempts to connect or create Azure container, but with TLS client/server Model.
+func (ks *KopiaSnapshotter) ConnectOrCreateAzureWithServer(serverAddr, containerName, pathPrefix string) (*exec.Cmd, string, error) {
+	repoArgs := []string{"azure", "--container", containerName, "--prefix", pathPrefix}
+	return ks.ConnectOrCreateRepoWithServer(serverAddr, repoArgs...)
+}
+
 // ConnectOrCreateFilesystemWithServer attempts to connect or create repo in local filesystem,
 // but with TLS server/client Model.
 func (ks *KopiaSnapshotter) ConnectOrCreateFilesystemWithServer(serverAddr, repoPath string) (*exec.Cmd, string, error) {
@@ -216,6 +252,7 @@ func (ks *KopiaSnapshotter) CreateServer(addr string, args ...string) (*exec.Cmd
 		"--server-username", serverUser,
 		"--server-password", serverPassword,
 	}, args...)
+	args = append(args, ks.ServerArgs...)
 
 	return ks.Runner.RunAsync(args...)
 }
@@ -266,6 +303,75 @@ func (ks *KopiaSnapshotter) RefreshServer(addr, fingerprint string, args ...stri
 	return err
 }
 
+// AddACL adds an ACL entry granting the given user access to the manifests matching the target.
+func (ks *KopiaSnapshotter) AddACL(user, target, access string, args ...string) error {
+	args = append([]string{
+		"server", "acl", "add",
+		"--user", user,
+		"--target", target,
+		"--access", access,
+	}, args...)
+	_, _, err := ks.Runner.Run(args...)
+
+	return err
+}
+
+// EnableACLs installs default ACL entries unless ACLs are already enabled.
+func (ks *KopiaSnapshotter) EnableACLs(args ...string) error {
+	stdout, _, err := ks.Runner.Run(append([]string{"server", "acl", "list", "--json"}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	var entries []json.RawMessage
+
+	if err := json.Unmarshal([]byte(stdout), &entries); err != nil {
+		return errors.Wrap(err, "unable to parse ACL entries")
+	}
+
+	if len(entries) != 0 {
+		return nil
+	}
+
+	_, _, err = ks.Runner.Run(append([]string{"server", "acl", "enable"}, args...)...)
+
+	return err
+}
+
+// DeleteACLs deletes all ACL entries for the given user.
+func (ks *KopiaSnapshotter) DeleteACLs(user string, args ...string) error {
+	stdout, _, err := ks.Runner.Run(append([]string{"server", "acl", "list", "--json"}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	var entries []struct {
+		ID   string `json:"id"`
+		User string `json:"user"`
+	}
+
+	if err := json.Unmarshal([]byte(stdout), &entries); err != nil {
+		return errors.Wrap(err, "unable to parse ACL entries")
+	}
+
+	var ids []string
+
+	for _, e := range entries {
+		if e.User == user {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args = append(append([]string{"server", "acl", "delete", "--delete"}, ids...), args...)
+	_, _, err = ks.Runner.Run(args...)
+
+	return err
+}
+
 // ListClients lists the clients that are registered with the Kopia server.
 func (ks *KopiaSnapshotter) ListClients(addr, fingerprint string, args ...string) error {
 	args = append([]string{"server", "user", "list"}, args...)
@@ -3,37 +3,89 @@
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+
+	"github.com/kopia/kopia/cli"
 )
 
 const (
 	repoPassword = "qWQPJ2hiiLgWRRCr"
+
+	// RunnerModeEnvKey is the environment variable selecting how kopia commands are executed.
+	RunnerModeEnvKey = "KOPIA_RUNNER_MODE"
+	// RunnerModeExe executes the binary pointed to by KOPIA_EXE, this is the default.
+	RunnerModeExe = "exe"
+	// RunnerModeInProc executes kopia commands in the current process.
+	RunnerModeInProc = "inproc"
 )
 
+// envFlags maps environment variables understood by kopia to equivalent flags,
+// used to pass them to commands executed in-process.
+// nolint:gochecknoglobals
+var envFlags = map[string]string{
+	"KOPIA_PASSWORD":                "--password",
+	"KOPIA_FAULT_INJECTION_CONFIG": "--fault-injection-config",
+}
+
 // Runner is a helper for running kopia commands.
 type Runner struct {
 	Exe         string
 	ConfigDir   string
 	fixedArgs   []string
 	environment []string
+
+	// inProc is true when kopia commands are executed in the current process
+	// instead of by exec'ing Exe.
+	inProc bool
 }
 
-// ErrExeVariableNotSet is an exported error.
-var ErrExeVariableNotSet = errors.New("KOPIA_EXE variable has not been set")
+var (
+	// ErrExeVariableNotSet is an exported error.
+	ErrExeVariableNotSet = errors.New("KOPIA_EXE variable has not been set")
+
+	// ErrUnsupportedInProc is returned for operations that require a separate kopia process
+	// when running in-process and KOPIA_EXE is not set.
+	ErrUnsupportedInProc = errors.New("operation is not supported by in-process kopia runner")
+)
 
 // NewRunner initializes a new kopia runner and returns its pointer.
+// Commands are executed in-process if KOPIA_RUNNER_MODE is set to "inproc",
+// otherwise they are executed by the binary pointed to by KOPIA_EXE.
 func NewRunner(baseDir string) (*Runner, error) {
+	switch mode := os.Getenv(RunnerModeEnvKey); mode {
+	case RunnerModeInProc:
+		return NewInProcRunner(baseDir)
+	case "", RunnerModeExe:
+	default:
+		return nil, fmt.Errorf("invalid %v: %q", RunnerModeEnvKey, mode)
+	}
+
 	exe := os.Getenv("KOPIA_EXE")
 	if exe == "" {
 		return nil, ErrExeVariableNotSet
 	}
 
+	return newRunner(baseDir, exe, false)
+}
+
+// NewInProcRunner initializes a new kopia runner that executes kopia commands in
+// the current process, which is faster and allows collecting code coverage.
+// If KOPIA_EXE is set, it is used for commands that need to run in background.
+func NewInProcRunner(baseDir string) (*Runner, error) {
+	return newRunner(baseDir, os.Getenv("KOPIA_EXE"), true)
+}
+
+func newRunner(baseDir, exe string, inProc bool) (*Runner, error) {
 	configDir, err := ioutil.TempDir(baseDir, "kopia-config")
 	if err != nil {
 		return nil, err
@@ -49,9 +101,15 @@ func NewRunner(baseDir string) (*Runner, error) {
 		ConfigDir:   configDir,
 		fixedArgs:   fixedArgs,
 		environment: []string{"KOPIA_PASSWORD=" + repoPassword},
+		inProc:      inProc,
 	}, nil
 }
 
+// IsInProc returns true if the runner executes kopia commands in the current process.
+func (kr *Runner) IsInProc() bool {
+	return kr.inProc
+}
+
 // Cleanup cleans up the directories managed by the kopia Runner.
 func (kr *Runner) Cleanup() {
 	if kr.ConfigDir != "" {
@@ -59,8 +117,17 @@ func (kr *Runner) Cleanup() {
 	}
 }
 
+// SetEnv sets the environment variable passed to all subsequently executed kopia commands.
+func (kr *Runner) SetEnv(key, value string) {
+	kr.environment = append(kr.environment, key+"="+value)
+}
+
 // Run will execute the kopia command with the given args.
 func (kr *Runner) Run(args ...string) (stdout, stderr string, err error) {
+	if kr.inProc {
+		return kr.runInProc(args...)
+	}
+
 	argsStr := strings.Join(args, " ")
 	log.Printf("running '%s %v'", kr.Exe, argsStr)
 	cmdArgs := append(append([]string(nil), kr.fixedArgs...), args...)
@@ -77,7 +144,12 @@ func (kr *Runner) Run(args ...string) (stdout, stderr string, err error) {
 }
 
 // RunAsync will execute the kopia command with the given args in background.
+// In-process runners execute the command using KOPIA_EXE, if set.
 func (kr *Runner) RunAsync(args ...string) (*exec.Cmd, error) {
+	if kr.Exe == "" {
+		return nil, ErrUnsupportedInProc
+	}
+
 	log.Printf("running async '%s %v'", kr.Exe, strings.Join(args, " "))
 	cmdArgs := append(append([]string(nil), kr.fixedArgs...), args...)
 	//nolint:gosec //G204
@@ -94,3 +166,70 @@ func (kr *Runner) RunAsync(args ...string) (*exec.Cmd, error) {
 
 	return c, nil
 }
+
+// runInProc executes the kopia command with the given args in the current process.
+func (kr *Runner) runInProc(args ...string) (stdout, stderr string, err error) {
+	argsStr := strings.Join(args, " ")
+	log.Printf("running in-process 'kopia %v'", argsStr)
+
+	cmdArgs := append(append(kr.envArgs(), kr.fixedArgs...), args...)
+
+	a := cli.NewApp()
+	a.AdvancedCommands = "enabled"
+
+	stdoutReader, stderrReader, wait, _ := a.RunSubcommand(context.Background(), cmdArgs)
+
+	var (
+		wg             sync.WaitGroup
+		outBuf, errBuf bytes.Buffer
+	)
+
+	wg.Add(2) //nolint:gomnd
+
+	go func() {
+		defer wg.Done()
+		io.Copy(&outBuf, stdoutReader) //nolint:errcheck
+	}()
+
+	go func() {
+		defer wg.Done()
+		io.Copy(&errBuf, stderrReader) //nolint:errcheck
+	}()
+
+	wg.Wait()
+
+	err = wait()
+	log.Printf("finished in-process 'kopia %v' with err=%v and output:\nSTDOUT:\n%v\nSTDERR:\n%v", argsStr, err, outBuf.String(), errBuf.String())
+
+	return outBuf.String(), errBuf.String(), err
+}
+
+// envArgs converts the runner environment to flags, since the environment
+// of the current process can't be changed for a single command.
+func (kr *Runner) envArgs() []string {
+	var (
+		result []string
+		// index of each flag in result, so that later values override earlier ones
+		flagIndex = map[string]int{}
+	)
+
+	for _, kv := range kr.environment {
+		parts := strings.SplitN(kv, "=", 2) //nolint:gomnd
+
+		flag, ok := envFlags[parts[0]]
+		if !ok {
+			log.Printf("environment variable %v is not passed to in-process kopia commands", parts[0])
+			continue
+		}
+
+		if i, ok := flagIndex[flag]; ok {
+			result[i] = flag + "=" + parts[1]
+			continue
+		}
+
+		flagIndex[flag] = len(result)
+		result = append(result, flag+"="+parts[1])
+	}
+
+	return result
+}
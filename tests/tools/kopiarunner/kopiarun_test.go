@@ -78,3 +78,40 @@ func TestKopiaRunner(t *testing.T) {
 		}
 	}
 }
+
+func TestKopiaRunnerInProc(t *testing.T) {
+	runner, err := NewInProcRunner(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer runner.Cleanup()
+
+	if !runner.IsInProc() {
+		t.Fatal("expected in-process runner")
+	}
+
+	if _, _, err = runner.Run("no-such-command"); err == nil {
+		t.Fatal("expected error running invalid command")
+	}
+
+	repoDir := t.TempDir()
+	dataDir := t.TempDir()
+
+	if _, _, err = runner.Run("repo", "create", "filesystem", "--path", repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = runner.Run("snapshot", "create", dataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, err := runner.Run("snapshot", "list", "--all", "--manifest-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := parseSnapshotListForSnapshotIDs(stdout); len(got) != 1 {
+		t.Fatalf("unexpected snapshots: %v", got)
+	}
+}
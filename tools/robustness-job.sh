@@ -16,12 +16,19 @@ set -o nounset
 #
 # - AWS_ACCESS_KEY_ID: To access the repo bucket
 # - AWS_SECRET_ACCESS_KEY: To access the repo bucket
+# - AZURE_CONTAINER_NAME: Name of the Azure blob container for the repo
+# - AZURE_STORAGE_ACCOUNT: To access the repo container
+# - AZURE_STORAGE_KEY: To access the repo container
 # - ENGINE_MODE:
-# - FIO_EXE: Path to the fio executable, if unset a Docker container will be
-#       used to run fio.
-# - HOST_FIO_DATA_PATH:
-# - LOCAL_FIO_DATA_PATH: Path to the local directory where snapshots should be
-#       restored to and fio data should be written to.
+# - FAULT_INJECTION_CONFIG: Path to a JSON file describing storage faults to
+#       inject into the repository under test, see repo/blob/faultinject.
+# - GCS_BUCKET_NAME: Name of the GCS bucket for the repo
+# - GOOGLE_APPLICATION_CREDENTIALS: To access the GCS repo bucket
+# - LOCAL_DATA_PATH: Path to the local directory where snapshots should be
+#       restored to and test data should be written to.
+# - RECORD_SCENARIO_FILE: Path to a file where the actions executed by the
+#       robustness engine are recorded, so that the run can be replayed with
+#       the -replay-scenario test flag. Not supported when ENGINE_MODE=SERVER.
 # - S3_BUCKET_NAME: Name of the S3 bucket for the repo
 
 readonly kopia_robustness_dir="${1?Specify directory with kopia robustness git repo}"
@@ -46,10 +53,15 @@ additional_args: '${@}'
 --- Optional Job Parameters via Environment Variables ---
 AWS_ACCESS_KEY_ID=${AWS_ACCESS_KEY_ID-}
 AWS_SECRET_ACCESS_KEY=${AWS_SECRET_ACCESS_KEY:+<xxxx>}
+AZURE_CONTAINER_NAME=${AZURE_CONTAINER_NAME-}
+AZURE_STORAGE_ACCOUNT=${AZURE_STORAGE_ACCOUNT-}
+AZURE_STORAGE_KEY=${AZURE_STORAGE_KEY:+<xxxx>}
 ENGINE_MODE=${ENGINE_MODE-}
-FIO_EXE=${FIO_EXE-}
-HOST_FIO_DATA_PATH:${HOST_FIO_DATA_PATH-}
-LOCAL_FIO_DATA_PATH=${LOCAL_FIO_DATA_PATH-}
+FAULT_INJECTION_CONFIG=${FAULT_INJECTION_CONFIG-}
+GCS_BUCKET_NAME=${GCS_BUCKET_NAME-}
+GOOGLE_APPLICATION_CREDENTIALS=${GOOGLE_APPLICATION_CREDENTIALS-}
+LOCAL_DATA_PATH=${LOCAL_DATA_PATH-}
+RECORD_SCENARIO_FILE=${RECORD_SCENARIO_FILE-}
 S3_BUCKET_NAME=${S3_BUCKET_NAME-}
 
 --- Other Env Vars ---
@@ -60,12 +72,12 @@ GOPATH=${GOPATH-}
 
 EOF
 
-if [ -n "${LOCAL_FIO_DATA_PATH-}" ] ; then
-    echo "Contents of data dir: '${LOCAL_FIO_DATA_PATH}'"
-    ls -oF "${LOCAL_FIO_DATA_PATH}"
+if [ -n "${LOCAL_DATA_PATH-}" ] ; then
+    echo "Contents of data dir: '${LOCAL_DATA_PATH}'"
+    ls -oF "${LOCAL_DATA_PATH}"
 
-    echo "Storage used on: '${LOCAL_FIO_DATA_PATH}'"
-    df -h "${LOCAL_FIO_DATA_PATH}"
+    echo "Storage used on: '${LOCAL_DATA_PATH}'"
+    df -h "${LOCAL_DATA_PATH}"
 fi
 
 readonly kopia_exe="${kopia_exe_dir}/kopia"
@@ -98,18 +110,21 @@ readonly ld_flags="\
 -X github.com/kopia/kopia/tests/robustness/engine.testGitRevision=${robustness_git_dirty:-""}${robustness_git_revision} \
 -X github.com/kopia/kopia/tests/robustness/engine.testGitBranch=${robustness_git_branch}"
 
-readonly test_flags="-v -timeout=${test_timeout}\
- --rand-test-duration=${test_duration}\
- --repo-path-prefix=${test_repo_path_prefix}\
- -ldflags '${ld_flags}'"
-
 # Set the make target based on ENGINE_MODE
 ENGINE_MODE="${ENGINE_MODE:-}"
 make_target="robustness-tests"
+scenario_flags=""
 if [[ "${ENGINE_MODE}" = SERVER ]]; then
     make_target="robustness-server-tests"
+elif [[ -n "${RECORD_SCENARIO_FILE-}" ]]; then
+    scenario_flags=" --record-scenario=${RECORD_SCENARIO_FILE}"
 fi
 
+readonly test_flags="-v -timeout=${test_timeout}\
+ --rand-test-duration=${test_duration}\
+ --repo-path-prefix=${test_repo_path_prefix}${scenario_flags}\
+ -ldflags '${ld_flags}'"
+
 # Run the robustness tests
 set -o verbose
 